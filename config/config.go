@@ -40,6 +40,19 @@ type Config struct {
 	Credentials struct {
 		SSH []SSHCredentials `yaml:"ssh"`
 	} `yaml:"credentials"`
+	Findings struct {
+		CriticalCVSS  float64 `yaml:"critical_cvss"`
+		PotentialCVSS float64 `yaml:"potential_cvss"`
+	} `yaml:"findings"`
+	Watch struct {
+		PollIntervalSeconds int `yaml:"poll_interval_seconds"`
+	} `yaml:"watch"`
+	Wireless struct {
+		CorporateSSIDs []string `yaml:"corporate_ssids"` // Open look-alikes of these are reported as evil twins
+	} `yaml:"wireless"`
+	Rules struct {
+		Path string `yaml:"path"` // A YAML file or a directory of them with detection rules
+	} `yaml:"rules"`
 }
 
 // Cfg is a global variable that will hold the loaded configuration.
@@ -72,6 +85,22 @@ func LoadConfig() error {
 		}
 	}
 
+	// Ensure severity thresholds are set if the section is missing
+	if Cfg.Findings.CriticalCVSS == 0 && Cfg.Findings.PotentialCVSS == 0 {
+		Cfg.Findings.CriticalCVSS = 9.0
+		Cfg.Findings.PotentialCVSS = 4.0
+	}
+
+	// Ensure the watch-folder poll interval is set if the section is missing
+	if Cfg.Watch.PollIntervalSeconds <= 0 {
+		Cfg.Watch.PollIntervalSeconds = 30
+	}
+
+	// Ensure the detection rules location is set if the section is missing
+	if Cfg.Rules.Path == "" {
+		Cfg.Rules.Path = "./rules"
+	}
+
 	fmt.Println("✅ Configuration loaded from config.yaml.")
 	return nil
 }
@@ -124,6 +153,28 @@ func createDefaultConfig(path string) error {
 				{User: "root", Password: ""},
 			},
 		},
+		Findings: struct {
+			CriticalCVSS  float64 `yaml:"critical_cvss"`
+			PotentialCVSS float64 `yaml:"potential_cvss"`
+		}{
+			CriticalCVSS:  9.0,
+			PotentialCVSS: 4.0,
+		},
+		Watch: struct {
+			PollIntervalSeconds int `yaml:"poll_interval_seconds"`
+		}{
+			PollIntervalSeconds: 30,
+		},
+		Wireless: struct {
+			CorporateSSIDs []string `yaml:"corporate_ssids"`
+		}{
+			CorporateSSIDs: []string{},
+		},
+		Rules: struct {
+			Path string `yaml:"path"`
+		}{
+			Path: "./rules",
+		},
 	}
 
 	data, err := yaml.Marshal(&defaultConfig)
@@ -8,9 +8,11 @@ require (
 	github.com/google/gopacket v1.1.19
 	github.com/hirochachacha/go-smb2 v1.1.0
 	github.com/jlaffaye/ftp v0.2.0
+	github.com/klauspost/compress v1.18.0
 	github.com/klauspost/oui v0.0.0-20150225163751-35b4deb627f8
 	github.com/oschwald/maxminddb-golang v1.13.1
 	github.com/pkg/browser v0.0.0-20240102092130-5ac0b6a4141c
+	github.com/ulikunitz/xz v0.5.15
 	golang.org/x/crypto v0.39.0
 	gopkg.in/yaml.v2 v2.4.0
 	modernc.org/sqlite v1.38.0
@@ -1,3 +1,5 @@
+//go:build pcap
+
 package livecapture
 
 import (
@@ -11,13 +13,24 @@ import (
 	"github.com/google/gopacket/pcap"
 )
 
+// Available reports whether this binary was built with live capture support.
+const Available = true
+
 // ListInterfaces finds and returns all available network interfaces.
-func ListInterfaces() ([]pcap.Interface, error) {
+func ListInterfaces() ([]Interface, error) {
 	devices, err := pcap.FindAllDevs()
 	if err != nil {
 		return nil, fmt.Errorf("could not find devices: %w", err)
 	}
-	return devices, nil
+	interfaces := make([]Interface, 0, len(devices))
+	for _, device := range devices {
+		iface := Interface{Name: device.Name, Description: device.Description}
+		for _, address := range device.Addresses {
+			iface.Addresses = append(iface.Addresses, address.IP.String())
+		}
+		interfaces = append(interfaces, iface)
+	}
+	return interfaces, nil
 }
 
 // Start opens a network interface and processes packets in real-time.
@@ -34,7 +47,11 @@ func Start(ctx context.Context, interfaceName string, networkMap *model.NetworkM
 	}
 	defer handle.Close()
 
-	packetSource := gopacket.NewPacketSource(handle, handle.LinkType())
+	// A live handle reports its link type as gopacket's uint8 LinkType. That cannot hold
+	// LINKTYPE_LINUX_SLL2, but libpcap only uses SLL2 for the "any" device when asked to.
+	packetSource := gopacket.NewPacketSource(handle, processing.LinkTypeDecoder(uint32(handle.LinkType())))
+	// Connections still open when the capture stops are parsed with what was seen of them.
+	defer processing.FlushStreams(networkMap, summary)
 
 	for {
 		select {
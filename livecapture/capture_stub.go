@@ -0,0 +1,21 @@
+//go:build !pcap
+
+package livecapture
+
+import (
+	"SnailsHell/model"
+	"context"
+)
+
+// Available reports whether this binary was built with live capture support.
+const Available = false
+
+// ListInterfaces always fails: live capture needs libpcap and the "pcap" build tag.
+func ListInterfaces() ([]Interface, error) {
+	return nil, ErrLiveCaptureUnavailable
+}
+
+// Start always fails: live capture needs libpcap and the "pcap" build tag.
+func Start(ctx context.Context, interfaceName string, networkMap *model.NetworkMap, summary *model.PcapSummary) error {
+	return ErrLiveCaptureUnavailable
+}
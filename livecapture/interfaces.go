@@ -0,0 +1,14 @@
+package livecapture
+
+import "errors"
+
+// ErrLiveCaptureUnavailable is returned by live capture functions in binaries built without the
+// "pcap" build tag. Offline pcap files are read in pure Go and don't need it.
+var ErrLiveCaptureUnavailable = errors.New("live capture is not available: rebuild with libpcap and `-tags pcap`")
+
+// Interface is a network interface that packets can be captured on.
+type Interface struct {
+	Name        string
+	Description string
+	Addresses   []string
+}
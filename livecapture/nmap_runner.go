@@ -4,7 +4,6 @@ import (
 	"SnailsHell/config"
 	"SnailsHell/model"
 	"SnailsHell/processing"
-	"SnailsHell/storage"
 	"SnailsHell/webenum"
 	"bufio"
 	"context"
@@ -53,17 +52,13 @@ func printProgressBar(percent int) {
 	fmt.Printf("\r[%s%s] %d%% Complete", bar, spaces, percent)
 }
 
-// RunNmapScan executes an nmap scan and returns the processed network map.
-func RunNmapScan(ctx context.Context, target, campaignName string) (*model.NetworkMap, error) {
+// RunNmapScan executes an nmap scan and returns the processed network map. Saving it is left to
+// the caller, after its own checks and analysis have run.
+func RunNmapScan(ctx context.Context, target string) (*model.NetworkMap, error) {
 	if !IsNmapFound() {
 		return nil, fmt.Errorf("cannot run scan, nmap executable not found")
 	}
 
-	campaignID, err := storage.GetOrCreateCampaign(campaignName)
-	if err != nil {
-		return nil, fmt.Errorf("could not get or create campaign '%s': %w", campaignName, err)
-	}
-
 	tmpFile, err := os.CreateTemp("", "gonetmap-nmap-*.xml")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create temporary file for nmap output: %w", err)
@@ -127,12 +122,5 @@ func RunNmapScan(ctx context.Context, target, campaignName string) (*model.Netwo
 		webenum.ProbeWebServer(host)
 		webenum.TakeScreenshot(host)
 	}
-
-	summary := model.NewPcapSummary()
-	if err := storage.SaveScanResults(campaignID, networkMap, summary); err != nil {
-		return nil, fmt.Errorf("failed to save nmap scan results: %w", err)
-	}
-
-	log.Printf("✅ Nmap scan results for target '%s' saved to campaign '%s'.", target, campaignName)
 	return networkMap, nil
 }
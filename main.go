@@ -4,6 +4,7 @@ import (
 	"SnailsHell/config"
 	"SnailsHell/livecapture"
 	"SnailsHell/lookups"
+	"SnailsHell/processing"
 	"SnailsHell/scanner"
 	"SnailsHell/server"
 	"SnailsHell/storage"
@@ -18,7 +19,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/google/gopacket/pcap"
 	"github.com/pkg/browser"
 )
 
@@ -106,6 +106,11 @@ func main() {
 	compareFlag := flag.String("compare", "", "Compare two campaigns by name or ID, separated by a comma. e.g., 'CampaignA,CampaignB' or '1,2'")
 	nmapTarget := flag.String("nmap", "", "Run a live Nmap scan on the specified target (requires -campaign).")
 	noUI := flag.Bool("no-ui", false, "Run in CLI-only mode without starting the web server.")
+	force := flag.Bool("force", false, "Re-process files in -dir even if they were already ingested unchanged.")
+	watch := flag.Bool("watch", false, "Keep watching -dir and ingest new files as they appear (requires -campaign).")
+	importCVEs := flag.String("import-cves", "", "Import NVD or OSV vulnerability feeds (a file, archive or directory) into the offline CVE database and exit.")
+	importKEV := flag.String("import-kev", "", "Import a known exploited vulnerabilities list (CISA KEV JSON or CSV) for risk scoring and exit.")
+	importEPSS := flag.String("import-epss", "", "Import EPSS scores (FIRST CSV, optionally gzipped) for risk scoring and exit.")
 
 	flag.Parse()
 
@@ -120,6 +125,16 @@ func main() {
 		return
 	}
 
+	if *importCVEs != "" {
+		handleImportCVEsCLI(*importCVEs)
+		return
+	}
+
+	if *importKEV != "" || *importEPSS != "" {
+		handleImportExploitabilityCLI(*importKEV, *importEPSS)
+		return
+	}
+
 	if *compareFlag != "" {
 		parts := strings.Split(*compareFlag, ",")
 		if len(parts) != 2 {
@@ -130,6 +145,9 @@ func main() {
 	}
 
 	if *liveCapture {
+		if !livecapture.Available {
+			log.Fatalf("FATAL: %v", livecapture.ErrLiveCaptureUnavailable)
+		}
 		devices, err := livecapture.ListInterfaces()
 		if err != nil {
 			log.Fatalf("FATAL: Could not list network interfaces: %v", err)
@@ -181,8 +199,21 @@ func main() {
 		return
 	}
 
+	if *watch {
+		if *campaignName == "" {
+			log.Fatal("FATAL: A campaign name is required to watch a directory (-campaign).")
+		}
+		interval := time.Duration(config.Cfg.Watch.PollIntervalSeconds) * time.Second
+		if err := scanner.RunWatchBlocking(*campaignName, *dataDir, interval); err != nil {
+			log.Fatalf("FATAL: Watch failed: %v", err)
+		}
+		campaignID, _ := storage.GetOrCreateCampaign(*campaignName)
+		launchServerAndBrowser(fmt.Sprintf("http://localhost:8080/campaign/%d", campaignID), templatesFS, *noUI)
+		return
+	}
+
 	if *campaignName != "" {
-		if err := scanner.RunFileScanBlocking(*campaignName, *dataDir); err != nil {
+		if err := scanner.RunFileScanBlocking(*campaignName, *dataDir, *force); err != nil {
 			log.Fatalf("FATAL: File scan failed: %v", err)
 		}
 		campaignID, _ := storage.GetOrCreateCampaign(*campaignName)
@@ -227,7 +258,47 @@ func handleListCampaignsCLI() {
 	}
 }
 
-func handleListInterfacesCLI(devices []pcap.Interface) {
+func handleImportCVEsCLI(path string) {
+	records, err := processing.ImportCVEFeeds(path)
+	if err != nil {
+		log.Fatalf("FATAL: Could not read vulnerability feeds: %v", err)
+	}
+	if err := storage.SaveCVERecords(records); err != nil {
+		log.Fatalf("FATAL: Could not import vulnerability feeds: %v", err)
+	}
+	total, err := storage.CountCVERecords()
+	if err != nil {
+		log.Fatalf("Error counting CVEs: %v", err)
+	}
+	fmt.Printf("✅ Imported %d CVEs from %s (%d in the offline database).\n", len(records), path, total)
+}
+
+// handleImportExploitabilityCLI imports a KEV list and/or EPSS scores, each replacing the previous
+// import, and rescores the hosts of every campaign.
+func handleImportExploitabilityCLI(kevPath, epssPath string) {
+	if kevPath != "" {
+		entries, err := processing.ImportKEV(kevPath)
+		if err != nil {
+			log.Fatalf("FATAL: Could not read the KEV list: %v", err)
+		}
+		if err := storage.SaveKEVEntries(entries); err != nil {
+			log.Fatalf("FATAL: Could not import the KEV list: %v", err)
+		}
+		fmt.Printf("✅ Imported %d known exploited vulnerabilities from %s.\n", len(entries), kevPath)
+	}
+	if epssPath != "" {
+		scores, err := processing.ImportEPSS(epssPath)
+		if err != nil {
+			log.Fatalf("FATAL: Could not read the EPSS scores: %v", err)
+		}
+		if err := storage.SaveEPSSScores(scores); err != nil {
+			log.Fatalf("FATAL: Could not import the EPSS scores: %v", err)
+		}
+		fmt.Printf("✅ Imported %d EPSS scores from %s.\n", len(scores), epssPath)
+	}
+}
+
+func handleListInterfacesCLI(devices []livecapture.Interface) {
 	if len(devices) == 0 {
 		fmt.Println("No network interfaces found. Make sure you have the necessary permissions.")
 		return
@@ -235,12 +306,8 @@ func handleListInterfacesCLI(devices []pcap.Interface) {
 	fmt.Println("--- Available Network Interfaces ---")
 	for i, device := range devices {
 		fmt.Printf("\n[%d] %s\n", i+1, device.Description)
-		var ipAddresses []string
-		for _, address := range device.Addresses {
-			ipAddresses = append(ipAddresses, address.IP.String())
-		}
-		if len(ipAddresses) > 0 {
-			fmt.Printf("    IP Addresses: %s\n", strings.Join(ipAddresses, ", "))
+		if len(device.Addresses) > 0 {
+			fmt.Printf("    IP Addresses: %s\n", strings.Join(device.Addresses, ", "))
 		}
 		fmt.Printf("    Device Name:  %s\n", device.Name)
 	}
@@ -170,6 +170,445 @@ var allMigrations = []Migration{
             );
         `,
 	},
+	{
+		Version: 6,
+		Script: `
+            ALTER TABLE hosts ADD COLUMN distance INTEGER NOT NULL DEFAULT 0;
+            ALTER TABLE hosts ADD COLUMN uptime_seconds INTEGER NOT NULL DEFAULT 0;
+            ALTER TABLE hosts ADD COLUMN last_boot TEXT NOT NULL DEFAULT '';
+            ALTER TABLE hosts ADD COLUMN srtt INTEGER NOT NULL DEFAULT 0;
+            ALTER TABLE hosts ADD COLUMN rttvar INTEGER NOT NULL DEFAULT 0;
+            ALTER TABLE hosts ADD COLUMN rto INTEGER NOT NULL DEFAULT 0;
+            ALTER TABLE ports ADD COLUMN extra_info TEXT NOT NULL DEFAULT '';
+            ALTER TABLE ports ADD COLUMN os_type TEXT NOT NULL DEFAULT '';
+            ALTER TABLE ports ADD COLUMN tunnel TEXT NOT NULL DEFAULT '';
+            ALTER TABLE ports ADD COLUMN cpe TEXT NOT NULL DEFAULT '';
+            CREATE TABLE IF NOT EXISTS hostnames (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                host_id INTEGER NOT NULL,
+                name TEXT NOT NULL,
+                type TEXT,
+                FOREIGN KEY(host_id) REFERENCES hosts(id) ON DELETE CASCADE,
+                UNIQUE(host_id, name)
+            );
+            CREATE TABLE IF NOT EXISTS os_matches (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                host_id INTEGER NOT NULL,
+                name TEXT NOT NULL,
+                accuracy INTEGER,
+                os_family TEXT,
+                os_gen TEXT,
+                vendor TEXT,
+                device_type TEXT,
+                cpe TEXT,
+                FOREIGN KEY(host_id) REFERENCES hosts(id) ON DELETE CASCADE,
+                UNIQUE(host_id, name)
+            );
+            CREATE TABLE IF NOT EXISTS traceroute_hops (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                host_id INTEGER NOT NULL,
+                ttl INTEGER NOT NULL,
+                ip_address TEXT,
+                rtt REAL,
+                hostname TEXT,
+                FOREIGN KEY(host_id) REFERENCES hosts(id) ON DELETE CASCADE,
+                UNIQUE(host_id, ttl)
+            );
+            CREATE TABLE IF NOT EXISTS scan_runs (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                campaign_id INTEGER NOT NULL,
+                scanner TEXT,
+                args TEXT,
+                version TEXT,
+                start_time DATETIME,
+                end_time DATETIME,
+                elapsed REAL,
+                summary TEXT,
+                hosts_up INTEGER,
+                hosts_down INTEGER,
+                hosts_total INTEGER,
+                scan_info TEXT,
+                FOREIGN KEY(campaign_id) REFERENCES campaigns(id) ON DELETE CASCADE
+            );
+        `,
+	},
+	{
+		Version: 7,
+		Script: `
+            ALTER TABLE vulnerabilities ADD COLUMN cvss REAL NOT NULL DEFAULT 0;
+            ALTER TABLE vulnerabilities ADD COLUMN source TEXT NOT NULL DEFAULT '';
+            ALTER TABLE vulnerabilities ADD COLUMN refs TEXT NOT NULL DEFAULT '';
+        `,
+	},
+	{
+		Version: 8,
+		Script: `
+            ALTER TABLE scan_runs ADD COLUMN source_file TEXT NOT NULL DEFAULT '';
+            CREATE TABLE IF NOT EXISTS ingest_runs (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                campaign_id INTEGER NOT NULL,
+                directory TEXT NOT NULL,
+                started_at DATETIME NOT NULL,
+                finished_at DATETIME,
+                files_processed INTEGER NOT NULL DEFAULT 0,
+                files_skipped INTEGER NOT NULL DEFAULT 0,
+                forced BOOLEAN NOT NULL DEFAULT 0,
+                error TEXT NOT NULL DEFAULT '',
+                FOREIGN KEY(campaign_id) REFERENCES campaigns(id) ON DELETE CASCADE
+            );
+            CREATE TABLE IF NOT EXISTS ingested_files (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                campaign_id INTEGER NOT NULL,
+                path TEXT NOT NULL,
+                size INTEGER NOT NULL,
+                mod_time DATETIME NOT NULL,
+                sha256 TEXT NOT NULL,
+                file_type TEXT NOT NULL,
+                ingest_run_id INTEGER,
+                ingested_at DATETIME NOT NULL,
+                FOREIGN KEY(campaign_id) REFERENCES campaigns(id) ON DELETE CASCADE,
+                FOREIGN KEY(ingest_run_id) REFERENCES ingest_runs(id) ON DELETE SET NULL,
+                UNIQUE(campaign_id, path)
+            );
+        `,
+	},
+	{
+		// Adds natural-key uniqueness to tables that were plain INSERTs. Existing duplicates are
+		// collapsed first: communications add up their packet counts, other tables keep the newest row.
+		Version: 9,
+		Script: `
+            UPDATE communications SET packet_count = (
+                SELECT SUM(c.packet_count) FROM communications c
+                WHERE c.host_id = communications.host_id AND c.counterpart_ip = communications.counterpart_ip
+            ) WHERE id IN (SELECT MIN(id) FROM communications GROUP BY host_id, counterpart_ip HAVING COUNT(*) > 1);
+            DELETE FROM communications WHERE id NOT IN (SELECT MIN(id) FROM communications GROUP BY host_id, counterpart_ip);
+            DELETE FROM vulnerabilities WHERE id NOT IN (SELECT MAX(id) FROM vulnerabilities GROUP BY host_id, IFNULL(port_id, 0), IFNULL(cve, ''), source);
+            DELETE FROM credentials WHERE id NOT IN (SELECT MAX(id) FROM credentials GROUP BY host_id, IFNULL(endpoint, ''), IFNULL(type, ''), IFNULL(value, ''));
+            DELETE FROM handshakes WHERE id NOT IN (SELECT MAX(id) FROM handshakes GROUP BY campaign_id, ap_mac, client_mac, IFNULL(pcap_file, ''));
+            DELETE FROM web_responses WHERE id NOT IN (SELECT MAX(id) FROM web_responses GROUP BY port_id, method);
+            DELETE FROM ftp_results WHERE id NOT IN (SELECT MAX(id) FROM ftp_results GROUP BY port_id);
+            DELETE FROM ssh_results WHERE id NOT IN (SELECT MAX(id) FROM ssh_results GROUP BY port_id, IFNULL(user, ''));
+            DELETE FROM smb_results WHERE id NOT IN (SELECT MAX(id) FROM smb_results GROUP BY port_id);
+            UPDATE vulnerabilities SET cve = '' WHERE cve IS NULL;
+            UPDATE credentials SET endpoint = IFNULL(endpoint, ''), type = IFNULL(type, ''), value = IFNULL(value, '');
+            UPDATE handshakes SET pcap_file = '' WHERE pcap_file IS NULL;
+            UPDATE ssh_results SET user = '' WHERE user IS NULL;
+            CREATE UNIQUE INDEX IF NOT EXISTS idx_communications_key ON communications(host_id, counterpart_ip);
+            CREATE UNIQUE INDEX IF NOT EXISTS idx_vulnerabilities_key ON vulnerabilities(host_id, IFNULL(port_id, 0), cve, source);
+            CREATE UNIQUE INDEX IF NOT EXISTS idx_credentials_key ON credentials(host_id, endpoint, type, value);
+            CREATE UNIQUE INDEX IF NOT EXISTS idx_handshakes_key ON handshakes(campaign_id, ap_mac, client_mac, pcap_file);
+            CREATE UNIQUE INDEX IF NOT EXISTS idx_web_responses_key ON web_responses(port_id, method);
+            CREATE UNIQUE INDEX IF NOT EXISTS idx_ftp_results_key ON ftp_results(port_id);
+            CREATE UNIQUE INDEX IF NOT EXISTS idx_ssh_results_key ON ssh_results(port_id, user);
+            CREATE UNIQUE INDEX IF NOT EXISTS idx_smb_results_key ON smb_results(port_id);
+        `,
+	},
+	{
+		Version: 10,
+		Script: `
+            CREATE TABLE IF NOT EXISTS wifi_access_points (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                campaign_id INTEGER NOT NULL,
+                bssid TEXT NOT NULL,
+                ssid TEXT NOT NULL DEFAULT '',
+                vendor TEXT NOT NULL DEFAULT '',
+                channel INTEGER NOT NULL DEFAULT 0,
+                frequency INTEGER NOT NULL DEFAULT 0,
+                encryption TEXT NOT NULL DEFAULT '',
+                akm TEXT NOT NULL DEFAULT '',
+                ciphers TEXT NOT NULL DEFAULT '',
+                wps BOOLEAN NOT NULL DEFAULT 0,
+                rssi INTEGER NOT NULL DEFAULT 0,
+                beacons INTEGER NOT NULL DEFAULT 0,
+                first_seen DATETIME,
+                last_seen DATETIME,
+                FOREIGN KEY(campaign_id) REFERENCES campaigns(id) ON DELETE CASCADE,
+                UNIQUE(campaign_id, bssid)
+            );
+            CREATE TABLE IF NOT EXISTS wifi_clients (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                campaign_id INTEGER NOT NULL,
+                mac_address TEXT NOT NULL,
+                vendor TEXT NOT NULL DEFAULT '',
+                associated_bssid TEXT NOT NULL DEFAULT '',
+                rssi INTEGER NOT NULL DEFAULT 0,
+                frames INTEGER NOT NULL DEFAULT 0,
+                first_seen DATETIME,
+                last_seen DATETIME,
+                FOREIGN KEY(campaign_id) REFERENCES campaigns(id) ON DELETE CASCADE,
+                UNIQUE(campaign_id, mac_address)
+            );
+            CREATE TABLE IF NOT EXISTS wifi_probes (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                client_id INTEGER NOT NULL,
+                ssid TEXT NOT NULL,
+                FOREIGN KEY(client_id) REFERENCES wifi_clients(id) ON DELETE CASCADE,
+                UNIQUE(client_id, ssid)
+            );
+        `,
+	},
+	{
+		Version: 11,
+		Script: `
+            CREATE TABLE IF NOT EXISTS deauth_pairs (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                campaign_id INTEGER NOT NULL,
+                ap_mac TEXT NOT NULL,
+                client_mac TEXT NOT NULL,
+                ssid TEXT NOT NULL DEFAULT '',
+                deauth_count INTEGER NOT NULL DEFAULT 0,
+                disassoc_count INTEGER NOT NULL DEFAULT 0,
+                first_seen DATETIME,
+                last_seen DATETIME,
+                reconnected_at DATETIME,
+                handshake_state TEXT NOT NULL DEFAULT '',
+                pcap_file TEXT NOT NULL DEFAULT '',
+                FOREIGN KEY(campaign_id) REFERENCES campaigns(id) ON DELETE CASCADE,
+                UNIQUE(campaign_id, ap_mac, client_mac)
+            );
+            CREATE TABLE IF NOT EXISTS deauth_reasons (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                pair_id INTEGER NOT NULL,
+                reason INTEGER NOT NULL,
+                frame_count INTEGER NOT NULL DEFAULT 0,
+                FOREIGN KEY(pair_id) REFERENCES deauth_pairs(id) ON DELETE CASCADE,
+                UNIQUE(pair_id, reason)
+            );
+        `,
+	},
+	{
+		Version: 12,
+		Script: `
+            CREATE TABLE IF NOT EXISTS tls_sessions (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                host_id INTEGER NOT NULL,
+                client_ip TEXT NOT NULL,
+                server_ip TEXT NOT NULL,
+                server_port INTEGER NOT NULL,
+                sni TEXT NOT NULL DEFAULT '',
+                alpn TEXT NOT NULL DEFAULT '',
+                version TEXT NOT NULL DEFAULT '',
+                cipher TEXT NOT NULL DEFAULT '',
+                ja3 TEXT NOT NULL DEFAULT '',
+                ja3s TEXT NOT NULL DEFAULT '',
+                ja4 TEXT NOT NULL DEFAULT '',
+                cert_subject TEXT NOT NULL DEFAULT '',
+                cert_issuer TEXT NOT NULL DEFAULT '',
+                cert_sans TEXT NOT NULL DEFAULT '',
+                cert_not_before DATETIME,
+                cert_not_after DATETIME,
+                cert_self_signed BOOLEAN NOT NULL DEFAULT 0,
+                cert_sha256 TEXT NOT NULL DEFAULT '',
+                connections INTEGER NOT NULL DEFAULT 0,
+                first_seen DATETIME,
+                last_seen DATETIME,
+                pcap_file TEXT NOT NULL DEFAULT '',
+                FOREIGN KEY(host_id) REFERENCES hosts(id) ON DELETE CASCADE,
+                UNIQUE(host_id, client_ip, server_ip, server_port, sni, ja3)
+            );
+        `,
+	},
+	{
+		Version: 13,
+		Script: `
+            CREATE TABLE IF NOT EXISTS http_transactions (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                host_id INTEGER NOT NULL,
+                communication_id INTEGER,
+                client_ip TEXT NOT NULL,
+                client_port INTEGER NOT NULL DEFAULT 0,
+                server_ip TEXT NOT NULL,
+                server_port INTEGER NOT NULL,
+                stream_offset INTEGER NOT NULL DEFAULT 0,
+                method TEXT NOT NULL,
+                host TEXT NOT NULL DEFAULT '',
+                uri TEXT NOT NULL,
+                status_code INTEGER NOT NULL DEFAULT 0,
+                user_agent TEXT NOT NULL DEFAULT '',
+                server TEXT NOT NULL DEFAULT '',
+                content_type TEXT NOT NULL DEFAULT '',
+                request_size INTEGER NOT NULL DEFAULT 0,
+                response_size INTEGER NOT NULL DEFAULT 0,
+                timestamp DATETIME,
+                pcap_file TEXT NOT NULL DEFAULT '',
+                FOREIGN KEY(host_id) REFERENCES hosts(id) ON DELETE CASCADE,
+                FOREIGN KEY(communication_id) REFERENCES communications(id) ON DELETE SET NULL,
+                UNIQUE(host_id, client_ip, client_port, server_ip, server_port, timestamp, stream_offset, method, uri)
+            );
+        `,
+	},
+	{
+		Version: 14,
+		Script: `
+            CREATE TABLE IF NOT EXISTS carved_files (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                campaign_id INTEGER NOT NULL,
+                host_id INTEGER,
+                filename TEXT NOT NULL,
+                mime_type TEXT NOT NULL DEFAULT '',
+                size INTEGER NOT NULL,
+                md5 TEXT NOT NULL,
+                sha1 TEXT NOT NULL,
+                sha256 TEXT NOT NULL,
+                protocol TEXT NOT NULL,
+                source_ip TEXT NOT NULL,
+                destination_ip TEXT NOT NULL,
+                timestamp DATETIME,
+                pcap_file TEXT NOT NULL DEFAULT '',
+                data BLOB NOT NULL,
+                FOREIGN KEY(campaign_id) REFERENCES campaigns(id) ON DELETE CASCADE,
+                FOREIGN KEY(host_id) REFERENCES hosts(id) ON DELETE SET NULL,
+                UNIQUE(campaign_id, sha256, protocol, filename, source_ip, destination_ip)
+            );
+        `,
+	},
+	{
+		Version: 15,
+		Script: `
+            CREATE TABLE IF NOT EXISTS dns_answers (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                host_id INTEGER NOT NULL,
+                name TEXT NOT NULL,
+                type TEXT NOT NULL,
+                answer TEXT NOT NULL,
+                ttl INTEGER NOT NULL DEFAULT 0,
+                resolver_ip TEXT NOT NULL DEFAULT '',
+                count INTEGER NOT NULL DEFAULT 0,
+                first_seen DATETIME,
+                last_seen DATETIME,
+                FOREIGN KEY(host_id) REFERENCES hosts(id) ON DELETE CASCADE,
+                UNIQUE(host_id, name, type, answer)
+            );
+            CREATE INDEX IF NOT EXISTS idx_dns_answers_answer ON dns_answers(answer);
+            CREATE TABLE IF NOT EXISTS dns_resolvers (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                host_id INTEGER NOT NULL,
+                resolver_ip TEXT NOT NULL,
+                protocol TEXT NOT NULL,
+                hostname TEXT NOT NULL DEFAULT '',
+                queries INTEGER NOT NULL DEFAULT 0,
+                responses INTEGER NOT NULL DEFAULT 0,
+                nxdomain INTEGER NOT NULL DEFAULT 0,
+                connections INTEGER NOT NULL DEFAULT 0,
+                avg_response_ms REAL NOT NULL DEFAULT 0,
+                timed_responses INTEGER NOT NULL DEFAULT 0,
+                first_seen DATETIME,
+                last_seen DATETIME,
+                FOREIGN KEY(host_id) REFERENCES hosts(id) ON DELETE CASCADE,
+                UNIQUE(host_id, protocol, resolver_ip)
+            );
+        `,
+	},
+	{
+		Version: 16,
+		Script: `
+            ALTER TABLE vulnerabilities ADD COLUMN evidence_file TEXT NOT NULL DEFAULT '';
+            ALTER TABLE vulnerabilities ADD COLUMN evidence_packet INTEGER NOT NULL DEFAULT 0;
+        `,
+	},
+	{
+		Version: 17,
+		Script: `
+            CREATE TABLE IF NOT EXISTS ot_operations (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                host_id INTEGER NOT NULL,
+                protocol TEXT NOT NULL,
+                role TEXT NOT NULL,
+                peer TEXT NOT NULL,
+                port INTEGER NOT NULL DEFAULT 0,
+                unit_id TEXT NOT NULL DEFAULT '',
+                function TEXT NOT NULL,
+                operation TEXT NOT NULL DEFAULT '',
+                count INTEGER NOT NULL DEFAULT 0,
+                first_seen DATETIME,
+                last_seen DATETIME,
+                FOREIGN KEY(host_id) REFERENCES hosts(id) ON DELETE CASCADE,
+                UNIQUE(host_id, protocol, role, peer, unit_id, function)
+            );
+            CREATE TABLE IF NOT EXISTS ot_identities (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                host_id INTEGER NOT NULL,
+                protocol TEXT NOT NULL,
+                identity TEXT NOT NULL,
+                FOREIGN KEY(host_id) REFERENCES hosts(id) ON DELETE CASCADE,
+                UNIQUE(host_id, protocol)
+            );
+        `,
+	},
+	{
+		Version: 18,
+		Script: `
+            CREATE TABLE IF NOT EXISTS cve_records (
+                id TEXT PRIMARY KEY,
+                description TEXT NOT NULL DEFAULT '',
+                cvss REAL NOT NULL DEFAULT 0,
+                published DATETIME,
+                refs TEXT NOT NULL DEFAULT ''
+            );
+            CREATE TABLE IF NOT EXISTS cve_affected (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                cve_id TEXT NOT NULL,
+                vendor TEXT NOT NULL DEFAULT '',
+                ecosystem TEXT NOT NULL DEFAULT '',
+                product TEXT NOT NULL,
+                version TEXT NOT NULL DEFAULT '',
+                start_including TEXT NOT NULL DEFAULT '',
+                start_excluding TEXT NOT NULL DEFAULT '',
+                end_including TEXT NOT NULL DEFAULT '',
+                end_excluding TEXT NOT NULL DEFAULT '',
+                FOREIGN KEY(cve_id) REFERENCES cve_records(id) ON DELETE CASCADE
+            );
+            CREATE INDEX IF NOT EXISTS idx_cve_affected_product ON cve_affected(product);
+            CREATE INDEX IF NOT EXISTS idx_cve_affected_cve ON cve_affected(cve_id);
+        `,
+	},
+	{
+		Version: 19,
+		Script: `
+            ALTER TABLE hosts ADD COLUMN risk_score REAL NOT NULL DEFAULT 0;
+            CREATE TABLE IF NOT EXISTS kev_entries (
+                cve_id TEXT PRIMARY KEY,
+                name TEXT NOT NULL DEFAULT '',
+                date_added TEXT NOT NULL DEFAULT '',
+                ransomware BOOLEAN NOT NULL DEFAULT 0
+            );
+            CREATE TABLE IF NOT EXISTS epss_scores (
+                cve_id TEXT PRIMARY KEY,
+                epss REAL NOT NULL,
+                percentile REAL NOT NULL DEFAULT 0
+            );
+            CREATE INDEX IF NOT EXISTS idx_hosts_risk ON hosts(campaign_id, risk_score);
+        `,
+	},
+	{
+		Version: 20,
+		Script: `
+            CREATE TABLE IF NOT EXISTS suppression_rules (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                campaign_id INTEGER NOT NULL,
+                cve TEXT NOT NULL,
+                host TEXT NOT NULL DEFAULT '',
+                port INTEGER NOT NULL DEFAULT 0,
+                reason TEXT NOT NULL DEFAULT '',
+                created_at DATETIME,
+                FOREIGN KEY(campaign_id) REFERENCES campaigns(id) ON DELETE CASCADE
+            );
+            ALTER TABLE vulnerabilities ADD COLUMN triage_status TEXT NOT NULL DEFAULT 'New';
+            ALTER TABLE vulnerabilities ADD COLUMN assignee TEXT NOT NULL DEFAULT '';
+            ALTER TABLE vulnerabilities ADD COLUMN suppression_id INTEGER REFERENCES suppression_rules(id) ON DELETE SET NULL;
+            ALTER TABLE vulnerabilities ADD COLUMN triage_manual INTEGER NOT NULL DEFAULT 0;
+            CREATE TABLE IF NOT EXISTS finding_notes (
+                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                vulnerability_id INTEGER NOT NULL,
+                author TEXT NOT NULL DEFAULT '',
+                text TEXT NOT NULL,
+                evidence TEXT NOT NULL DEFAULT '',
+                created_at DATETIME,
+                FOREIGN KEY(vulnerability_id) REFERENCES vulnerabilities(id) ON DELETE CASCADE
+            );
+            CREATE INDEX IF NOT EXISTS idx_finding_notes_vulnerability ON finding_notes(vulnerability_id);
+        `,
+	},
 }
 
 // GetMigrations returns the list of all defined migrations.
@@ -3,6 +3,10 @@ package model
 import (
 	"encoding/base64"
 	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/google/gopacket"
@@ -10,7 +14,8 @@ import (
 
 // NetworkMap is the master data structure that holds all discovered hosts.
 type NetworkMap struct {
-	Hosts map[string]*Host `json:"hosts"` // Keyed by MAC Address
+	Hosts    map[string]*Host `json:"hosts"` // Keyed by MAC Address
+	ScanRuns []ScanRun        `json:"scan_runs,omitempty"`
 }
 
 // NewNetworkMap creates an initialized NetworkMap.
@@ -38,6 +43,17 @@ type Host struct {
 	FTPResults     []FTPResult                         `json:"ftp_results,omitempty"`
 	SSHResults     []SSHResult                         `json:"ssh_results,omitempty"`
 	SMBResults     []SMBResult                         `json:"smb_results,omitempty"`
+	Hostnames      map[string]string                   `json:"hostnames,omitempty"` // Hostname -> type (e.g., "user", "PTR")
+	Uptime         *Uptime                             `json:"uptime,omitempty"`
+	Distance       int                                 `json:"distance,omitempty"`
+	Timing         *HostTiming                         `json:"timing,omitempty"`
+	Traceroute     []TraceHop                          `json:"traceroute,omitempty"`
+	TLSSessions    map[string]*TLSSession              `json:"tls_sessions,omitempty"` // Keyed by TLSSession.Key()
+	HTTPRequests   []HTTPTransaction                   `json:"http_requests,omitempty"`
+	DNSAnswers     map[string]*DNSAnswer               `json:"dns_answers,omitempty"`   // Keyed by DNSAnswer.Key()
+	DNSResolvers   map[string]*DNSResolver             `json:"dns_resolvers,omitempty"` // Keyed by DNSResolver.Key()
+	OTOperations   map[string]*OTOperation             `json:"ot_operations,omitempty"` // Keyed by OTOperation.Key()
+	OTIdentities   map[string]string                   `json:"ot_identities,omitempty"` // Protocol -> identity the device reported
 }
 
 // NewHost creates an initialized Host.
@@ -56,16 +72,26 @@ func NewHost(mac string) *Host {
 		FTPResults:     make([]FTPResult, 0),
 		SSHResults:     make([]SSHResult, 0),
 		SMBResults:     make([]SMBResult, 0),
+		Hostnames:      make(map[string]string),
+		TLSSessions:    make(map[string]*TLSSession),
+		DNSAnswers:     make(map[string]*DNSAnswer),
+		DNSResolvers:   make(map[string]*DNSResolver),
+		OTOperations:   make(map[string]*OTOperation),
+		OTIdentities:   make(map[string]string),
 	}
 }
 
 // Port represents a TCP/UDP port on a host.
 type Port struct {
-	ID       int    `json:"id"`
-	Protocol string `json:"protocol"`
-	State    string `json:"state"`
-	Service  string `json:"service"`
-	Version  string `json:"version"`
+	ID        int      `json:"id"`
+	Protocol  string   `json:"protocol"`
+	State     string   `json:"state"`
+	Service   string   `json:"service"`
+	Version   string   `json:"version"`
+	ExtraInfo string   `json:"extra_info,omitempty"`
+	OSType    string   `json:"os_type,omitempty"`
+	Tunnel    string   `json:"tunnel,omitempty"` // e.g., "ssl"
+	CPEs      []string `json:"cpes,omitempty"`
 }
 
 // Fingerprint holds OS and device type information.
@@ -74,6 +100,75 @@ type Fingerprint struct {
 	DeviceType      string          `json:"device_type"`
 	Vendor          string          `json:"vendor"`
 	BehavioralClues map[string]bool `json:"behavioral_clues"`
+	OSMatches       []OSMatch       `json:"os_matches,omitempty"`
+}
+
+// OSMatch is a single operating system guess, ordered by accuracy.
+type OSMatch struct {
+	Name       string   `json:"name"`
+	Accuracy   int      `json:"accuracy"`
+	Family     string   `json:"family,omitempty"`
+	Generation string   `json:"generation,omitempty"`
+	Vendor     string   `json:"vendor,omitempty"`
+	DeviceType string   `json:"device_type,omitempty"`
+	CPEs       []string `json:"cpes,omitempty"`
+}
+
+// Uptime holds the uptime estimate for a host.
+type Uptime struct {
+	Seconds  int64  `json:"seconds"`
+	LastBoot string `json:"last_boot"`
+}
+
+// HostTiming holds round-trip timing values in microseconds.
+type HostTiming struct {
+	SRTT    int64 `json:"srtt"`
+	RTTVar  int64 `json:"rttvar"`
+	Timeout int64 `json:"timeout"`
+}
+
+// TraceHop represents a single hop on the traceroute path to a host.
+type TraceHop struct {
+	TTL      int     `json:"ttl"`
+	IP       string  `json:"ip"`
+	RTT      float64 `json:"rtt"` // Milliseconds
+	Hostname string  `json:"hostname,omitempty"`
+}
+
+// ScanRun records the command line, timing and statistics of a single scanner run.
+type ScanRun struct {
+	ID         int64      `json:"id"`
+	Scanner    string     `json:"scanner"`
+	Args       string     `json:"args"`
+	Version    string     `json:"version"`
+	StartTime  time.Time  `json:"start_time"`
+	EndTime    time.Time  `json:"end_time"`
+	Elapsed    float64    `json:"elapsed"` // Seconds
+	Summary    string     `json:"summary"`
+	HostsUp    int        `json:"hosts_up"`
+	HostsDown  int        `json:"hosts_down"`
+	HostsTotal int        `json:"hosts_total"`
+	ScanInfo   []ScanInfo `json:"scan_info,omitempty"`
+	SourceFile string     `json:"source_file,omitempty"` // Data file the run was imported from
+}
+
+// IngestedFile records a data file that has been processed into a campaign, so that later
+// file scans of the same directory can skip it while it is unchanged.
+type IngestedFile struct {
+	Path        string    `json:"path"`
+	Size        int64     `json:"size"`
+	ModTime     time.Time `json:"mod_time"`
+	SHA256      string    `json:"sha256"`
+	FileType    string    `json:"file_type"`
+	IngestRunID int64     `json:"ingest_run_id"`
+	IngestedAt  time.Time `json:"ingested_at"`
+}
+
+// ScanInfo describes one scan type performed during a run.
+type ScanInfo struct {
+	Type        string `json:"type"`
+	Protocol    string `json:"protocol"`
+	NumServices int    `json:"num_services"`
 }
 
 // Communication represents a conversation between a local host and a remote IP.
@@ -81,6 +176,272 @@ type Communication struct {
 	CounterpartIP string   `json:"counterpart_ip"`
 	PacketCount   int      `json:"packet_count"`
 	Geo           *GeoInfo `json:"geo,omitempty"`
+	Hostnames     []string `json:"hostnames,omitempty"` // Names that DNS answers resolved to the counterpart
+}
+
+// DNSAnswer is a record a resolver returned for a name the host looked up.
+type DNSAnswer struct {
+	Name       string    `json:"name"`
+	Type       string    `json:"type"` // e.g. "A", "AAAA", "CNAME"
+	Answer     string    `json:"answer"`
+	TTL        uint32    `json:"ttl"`
+	ResolverIP string    `json:"resolver_ip"`
+	Count      int       `json:"count"`
+	FirstSeen  time.Time `json:"first_seen"`
+	LastSeen   time.Time `json:"last_seen"`
+}
+
+// Key identifies the answer within its host.
+func (a *DNSAnswer) Key() string {
+	return a.Name + "|" + a.Type + "|" + a.Answer
+}
+
+// DNSResolver records a host's use of one resolver over plain DNS, DNS over TLS or DNS over HTTPS.
+type DNSResolver struct {
+	IP             string    `json:"ip"`
+	Protocol       string    `json:"protocol"`           // "DNS", "DoT" or "DoH"
+	Hostname       string    `json:"hostname,omitempty"` // SNI of an encrypted resolver
+	Queries        int       `json:"queries"`
+	Responses      int       `json:"responses"`
+	NXDomain       int       `json:"nxdomain"`
+	Connections    int       `json:"connections"`     // TLS connections to an encrypted resolver, whose queries are hidden
+	AvgResponseMs  float64   `json:"avg_response_ms"` // Over the responses matched to a query
+	TimedResponses int       `json:"timed_responses"`
+	FirstSeen      time.Time `json:"first_seen"`
+	LastSeen       time.Time `json:"last_seen"`
+}
+
+// Key identifies the resolver within its host.
+func (r *DNSResolver) Key() string {
+	return r.Protocol + "|" + r.IP
+}
+
+// OT device roles, derived from the side of the industrial protocols a host speaks.
+const (
+	OTRoleController  = "OT controller"              // Answers requests: PLC, RTU, DNP3 outstation, BACnet or OPC UA server
+	OTRoleGateway     = "OT gateway"                 // Both answers and issues requests
+	OTRoleEngineering = "OT engineering workstation" // Downloads programs or changes run state
+	OTRoleHMI         = "OT HMI/SCADA client"        // Polls and writes values
+)
+
+// OT operation kinds.
+const (
+	OTRead    = "read"
+	OTWrite   = "write"
+	OTProgram = "program" // Program download, run state change or restart
+)
+
+// OTOperation aggregates the industrial protocol requests of one function a host issued to or
+// received from a peer.
+type OTOperation struct {
+	Protocol  string    `json:"protocol"` // e.g. "Modbus/TCP", "S7comm"
+	Role      string    `json:"role"`     // "client" if the host issued the requests, "server" if it received them
+	Peer      string    `json:"peer"`     // IP address, or MAC address for layer 2 protocols
+	Port      int       `json:"port,omitempty"`
+	UnitID    string    `json:"unit_id,omitempty"` // Modbus unit, DNP3 outstation address, S7 rack/slot
+	Function  string    `json:"function"`          // e.g. "Write Single Register (6)"
+	Operation string    `json:"operation,omitempty"`
+	Count     int       `json:"count"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// Key identifies the operation within its host.
+func (o *OTOperation) Key() string {
+	return o.Protocol + "|" + o.Role + "|" + o.Peer + "|" + o.UnitID + "|" + o.Function
+}
+
+// OTRole returns the OT device role of the host, or "" if it speaks no industrial protocol.
+func (h *Host) OTRole() string {
+	var client, server, program bool
+	for _, op := range h.OTOperations {
+		switch {
+		case op.Role == "server":
+			server = true
+		case op.Operation == OTProgram:
+			client, program = true, true
+		default:
+			client = true
+		}
+	}
+	if len(h.OTIdentities) > 0 {
+		server = true
+	}
+	switch {
+	case server && client:
+		return OTRoleGateway
+	case server:
+		return OTRoleController
+	case program:
+		return OTRoleEngineering
+	case client:
+		return OTRoleHMI
+	}
+	return ""
+}
+
+// TLSSession aggregates the TLS handshakes a host took part in with one client and server
+// endpoint, SNI and client fingerprint.
+type TLSSession struct {
+	ID          int64           `json:"id,omitempty"`
+	ClientIP    string          `json:"client_ip"`
+	ServerIP    string          `json:"server_ip"`
+	ServerPort  int             `json:"server_port"`
+	SNI         string          `json:"sni,omitempty"`
+	ALPN        string          `json:"alpn,omitempty"`    // Protocols offered by the client, e.g. "h2, http/1.1"
+	Version     string          `json:"version,omitempty"` // Negotiated, e.g. "TLS 1.2"; empty until the server answers
+	Cipher      string          `json:"cipher,omitempty"`  // Negotiated cipher suite
+	JA3         string          `json:"ja3"`
+	JA3S        string          `json:"ja3s,omitempty"`
+	JA4         string          `json:"ja4,omitempty"`
+	Certificate *TLSCertificate `json:"certificate,omitempty"` // Server leaf certificate; TLS 1.3 encrypts it
+	Connections int             `json:"connections"`
+	FirstSeen   time.Time       `json:"first_seen"`
+	LastSeen    time.Time       `json:"last_seen"`
+	PcapFile    string          `json:"pcap_file,omitempty"`
+}
+
+// Key identifies the session within its host.
+func (s *TLSSession) Key() string {
+	return fmt.Sprintf("%s|%s|%d|%s|%s", s.ClientIP, s.ServerIP, s.ServerPort, s.SNI, s.JA3)
+}
+
+// TLSCertificate describes an X.509 certificate presented in a TLS handshake.
+type TLSCertificate struct {
+	Subject    string    `json:"subject"`
+	Issuer     string    `json:"issuer"`
+	SANs       []string  `json:"sans,omitempty"`
+	NotBefore  time.Time `json:"not_before"`
+	NotAfter   time.Time `json:"not_after"`
+	SelfSigned bool      `json:"self_signed"`
+	SHA256     string    `json:"sha256"`
+}
+
+// HTTPTransaction is an HTTP/1.x request, and the response to it if one was captured.
+type HTTPTransaction struct {
+	ID           int64     `json:"id,omitempty"`
+	ClientIP     string    `json:"client_ip"`
+	ClientPort   int       `json:"client_port"`
+	ServerIP     string    `json:"server_ip"`
+	ServerPort   int       `json:"server_port"`
+	StreamOffset int       `json:"stream_offset"` // Where the request starts in the client's byte stream
+	Method       string    `json:"method"`
+	Host         string    `json:"host,omitempty"` // Host header
+	URI          string    `json:"uri"`
+	StatusCode   int       `json:"status_code,omitempty"` // 0 when no response was captured
+	UserAgent    string    `json:"user_agent,omitempty"`
+	Server       string    `json:"server,omitempty"`       // Server response header
+	ContentType  string    `json:"content_type,omitempty"` // Of the response
+	RequestSize  int       `json:"request_size"`           // Body bytes
+	ResponseSize int       `json:"response_size"`          // Body bytes
+	Timestamp    time.Time `json:"timestamp"`
+	PcapFile     string    `json:"pcap_file,omitempty"`
+}
+
+// URL returns the request's URL as the client addressed it.
+func (t *HTTPTransaction) URL() string {
+	host := t.Host
+	if host == "" {
+		host = t.ServerIP
+		if t.ServerPort != 80 {
+			host = fmt.Sprintf("%s:%d", host, t.ServerPort)
+		}
+	}
+	return "http://" + host + t.URI
+}
+
+// CarvedFile is a file reassembled from traffic: an HTTP body, an FTP or TFTP transfer, or an
+// SMB2 read or write.
+type CarvedFile struct {
+	ID            int64     `json:"id,omitempty"`
+	HostMAC       string    `json:"host_mac,omitempty"` // Local host that sent or received the file
+	Filename      string    `json:"filename"`
+	MIMEType      string    `json:"mime_type"`
+	Size          int       `json:"size"`
+	MD5           string    `json:"md5"`
+	SHA1          string    `json:"sha1"`
+	SHA256        string    `json:"sha256"`
+	Protocol      string    `json:"protocol"` // HTTP, FTP-DATA, TFTP or SMB2
+	SourceIP      string    `json:"source_ip"`
+	DestinationIP string    `json:"destination_ip"`
+	Timestamp     time.Time `json:"timestamp"`
+	PcapFile      string    `json:"pcap_file,omitempty"`
+	Data          []byte    `json:"-"`
+}
+
+// FTPTransfer is a data connection announced on an FTP control connection, and the file the
+// command that used it named.
+type FTPTransfer struct {
+	Command  string // RETR, STOR, STOU or APPE; empty until the command is seen
+	Filename string
+}
+
+// TFTPTransfer is a TFTP read or write in progress, keyed by the client's address and port.
+type TFTPTransfer struct {
+	ClientIP  string
+	ServerIP  string
+	Filename  string
+	Write     bool // WRQ: the client sends the file
+	BlockSize int
+	NextBlock uint16
+	Data      []byte
+	Host      *Host
+	PcapFile  string
+	Started   time.Time
+}
+
+// DNSActivity accumulates a client's DNS traffic for the tunnelling checks run once all captures
+// have been read.
+type DNSActivity struct {
+	Domains  map[string]*DNSDomainActivity // Keyed by registered domain
+	NXDomain []time.Time                   // Times of NXDOMAIN answers, in capture order
+}
+
+// DNSDomainActivity counts a client's queries under one registered domain.
+type DNSDomainActivity struct {
+	Queries      int
+	TXT          int
+	EncodedNames map[string]bool // Distinct names with long, high-entropy labels
+}
+
+// TCPStream holds the reassembled payload of a TCP connection whose application protocol is
+// parsed when the connection closes or the capture ends.
+type TCPStream struct {
+	ClientIP   string
+	ServerIP   string
+	ClientPort int
+	ServerPort int
+	Protocol   string       // e.g. "http"
+	FTP        *FTPTransfer // For "ftp-data" streams
+	Host       *Host        // Local host the connection belongs to, the client's if both are local
+	PcapFile   string
+	Client     TCPFlow // Sent by the client
+	Server     TCPFlow // Sent by the server
+}
+
+// TCPFlow is one direction of a TCPStream.
+type TCPFlow struct {
+	Data      []byte
+	Marks     []FlowMark        // Where in Data each segment started, to timestamp messages
+	NextSeq   uint32            // Sequence number expected next
+	Pending   map[uint32][]byte // Segments that arrived ahead of NextSeq
+	Started   bool
+	Truncated bool // A gap or the size limit cut the data short
+	Finished  bool // FIN or RST seen
+}
+
+// FlowMark records when the data at an offset of a TCPFlow was captured.
+type FlowMark struct {
+	Offset    int
+	Timestamp time.Time
+}
+
+// TLSStream buffers one direction of a TCP connection until its TLS handshake has been parsed.
+type TLSStream struct {
+	Flow    TCPFlow
+	Session *TLSSession
+	Host    *Host // Host the session belongs to
+	Done    bool
 }
 
 // GeoInfo holds geolocation data for an IP address.
@@ -106,6 +467,248 @@ type Vulnerability struct {
 	State       string          `json:"state"`
 	Category    FindingCategory `json:"category"`
 	PortID      int             `json:"port_id,omitempty"`
+	CVSS        float64         `json:"cvss,omitempty"`
+	Source      string          `json:"source,omitempty"` // e.g., the NSE script ID that reported it
+	References  []string        `json:"references,omitempty"`
+	// EvidenceFile and EvidencePacket point at the packet a finding from traffic was based on.
+	EvidenceFile   string `json:"evidence_file,omitempty"`
+	EvidencePacket int    `json:"evidence_packet,omitempty"` // 1-based, within EvidenceFile
+	// KnownExploited and EPSS come from the imported KEV list and EPSS scores when findings are loaded.
+	KnownExploited bool    `json:"known_exploited,omitempty"`
+	EPSS           float64 `json:"epss,omitempty"`
+	// ID and the triage fields are set on findings loaded from the database.
+	ID            int64         `json:"id,omitempty"`
+	TriageStatus  string        `json:"triage_status,omitempty"`
+	Assignee      string        `json:"assignee,omitempty"`
+	SuppressionID int64         `json:"suppression_id,omitempty"` // Suppression rule that set the status
+	Notes         []FindingNote `json:"notes,omitempty"`
+}
+
+// Triage statuses of a finding. Findings start as TriageNew until someone reviews them.
+const (
+	TriageNew           = "New"
+	TriageConfirmed     = "Confirmed"
+	TriageFalsePositive = "False Positive"
+	TriageFixed         = "Fixed"
+)
+
+// TriageStatuses lists the triage statuses in workflow order.
+var TriageStatuses = []string{TriageNew, TriageConfirmed, TriageFalsePositive, TriageFixed}
+
+// ValidTriageStatus reports whether status is one of TriageStatuses.
+func ValidTriageStatus(status string) bool {
+	for _, s := range TriageStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Dismissed reports whether the finding was triaged as a false positive or as fixed, and so no
+// longer needs attention.
+func (v Vulnerability) Dismissed() bool {
+	return v.TriageStatus == TriageFalsePositive || v.TriageStatus == TriageFixed
+}
+
+// FindingNote is a comment on a finding, optionally with evidence such as a command's output, a
+// screenshot path or a ticket link.
+type FindingNote struct {
+	ID        int64     `json:"id"`
+	Author    string    `json:"author,omitempty"`
+	Text      string    `json:"text"`
+	Evidence  string    `json:"evidence,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SuppressionRule marks matching findings of a campaign as false positives, including those of
+// later scans. An empty Host (a MAC or IP address) or a zero Port matches any.
+type SuppressionRule struct {
+	ID        int64     `json:"id"`
+	CVE       string    `json:"cve"` // CVE or script/check ID of the finding
+	Host      string    `json:"host,omitempty"`
+	Port      int       `json:"port,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Matches reports whether the rule applies to a finding with the given ID on a port of a host.
+func (r SuppressionRule) Matches(cve, mac, ip string, port int) bool {
+	if !strings.EqualFold(r.CVE, cve) {
+		return false
+	}
+	if r.Host != "" && !strings.EqualFold(r.Host, mac) && r.Host != ip {
+		return false
+	}
+	return r.Port == 0 || r.Port == port
+}
+
+// Evidence describes where a finding's evidence is, e.g. "capture.pcap, packet 42".
+func (v Vulnerability) Evidence() string {
+	if v.EvidenceFile == "" {
+		return ""
+	}
+	if v.EvidencePacket == 0 {
+		return v.EvidenceFile
+	}
+	return fmt.Sprintf("%s, packet %d", v.EvidenceFile, v.EvidencePacket)
+}
+
+// CVERecord is a known vulnerability from an offline database (NVD or OSV feeds).
+type CVERecord struct {
+	ID          string        `json:"id"`
+	Description string        `json:"description"`
+	CVSS        float64       `json:"cvss,omitempty"`
+	Published   time.Time     `json:"published"`
+	References  []string      `json:"references,omitempty"`
+	Affected    []CVEAffected `json:"affected,omitempty"`
+}
+
+// CVEAffected is a product and the versions of it a CVE applies to. An empty Vendor matches any
+// vendor; Version is an exact version, or the range is given by the Start and End bounds.
+// Ecosystem is the OSV package ecosystem (e.g. "PyPI" or "Debian:12"), empty for NVD entries.
+type CVEAffected struct {
+	Vendor         string `json:"vendor,omitempty"`
+	Ecosystem      string `json:"ecosystem,omitempty"`
+	Product        string `json:"product"`
+	Version        string `json:"version,omitempty"`
+	StartIncluding string `json:"start_including,omitempty"`
+	StartExcluding string `json:"start_excluding,omitempty"`
+	EndIncluding   string `json:"end_including,omitempty"`
+	EndExcluding   string `json:"end_excluding,omitempty"`
+}
+
+// KEVEntry is a CVE from a known exploited vulnerabilities list, such as the CISA KEV catalog.
+type KEVEntry struct {
+	CVE           string `json:"cve"`
+	Name          string `json:"name,omitempty"`
+	DateAdded     string `json:"date_added,omitempty"`
+	RansomwareUse bool   `json:"ransomware_use,omitempty"`
+}
+
+// EPSSScore is the probability, from FIRST's Exploit Prediction Scoring System, that a CVE is
+// exploited in the next 30 days.
+type EPSSScore struct {
+	CVE        string  `json:"cve"`
+	Score      float64 `json:"epss"`
+	Percentile float64 `json:"percentile"`
+}
+
+// Risk levels, from the risk score of a host.
+const (
+	RiskCritical = "Critical"
+	RiskHigh     = "High"
+	RiskMedium   = "Medium"
+	RiskLow      = "Low"
+	RiskNone     = "None"
+)
+
+// ManagementPorts are remote administration ports that add to a host's exposure when open.
+var ManagementPorts = map[int]string{
+	22:   "SSH",
+	23:   "Telnet",
+	161:  "SNMP",
+	623:  "IPMI",
+	2375: "Docker API",
+	3389: "RDP",
+	5900: "VNC",
+	5985: "WinRM",
+	5986: "WinRM",
+}
+
+// HostRisk is the risk score of a host, from 0 to 100, and the factors it was computed from.
+type HostRisk struct {
+	Score          float64  `json:"score"`
+	Level          string   `json:"level"`
+	MaxSeverity    float64  `json:"max_severity"` // Highest CVSS base score, or an estimate from the finding category
+	KnownExploited int      `json:"known_exploited"`
+	MaxEPSS        float64  `json:"max_epss"`
+	Exposures      []string `json:"exposures,omitempty"`
+}
+
+// RiskLevel returns the risk level of a risk score.
+func RiskLevel(score float64) string {
+	switch {
+	case score >= 80:
+		return RiskCritical
+	case score >= 60:
+		return RiskHigh
+	case score >= 30:
+		return RiskMedium
+	case score > 0:
+		return RiskLow
+	}
+	return RiskNone
+}
+
+// Risk scores the host from the severity of its findings, whether any of them are known to be
+// exploited, their EPSS probability and its exposure: successful post-exploitation checks and open
+// management ports. The most severe finding contributes up to 60 points, a known exploited one 15,
+// the highest EPSS up to 10 and exposure up to 25; the score is capped at 100. Findings dismissed
+// in triage are left out.
+func (h *Host) Risk() HostRisk {
+	var risk HostRisk
+	for category, vulns := range h.Findings {
+		if category == InformationalFinding {
+			continue
+		}
+		for _, v := range vulns {
+			if v.State == "NOT VULNERABLE" || v.Dismissed() {
+				continue
+			}
+			severity := v.CVSS
+			if severity == 0 {
+				severity = 5.0
+				if category == CriticalFinding {
+					severity = 9.0
+				}
+			}
+			risk.MaxSeverity = math.Max(risk.MaxSeverity, severity)
+			risk.MaxEPSS = math.Max(risk.MaxEPSS, v.EPSS)
+			if v.KnownExploited {
+				risk.KnownExploited++
+			}
+		}
+	}
+
+	exposure := 0.0
+	for _, r := range h.FTPResults {
+		if r.AnonymousLoginPossible {
+			risk.Exposures = append(risk.Exposures, fmt.Sprintf("Anonymous FTP on port %d", r.PortID))
+			exposure += 10
+		}
+	}
+	for _, r := range h.SSHResults {
+		if r.Successful {
+			risk.Exposures = append(risk.Exposures, fmt.Sprintf("SSH login on port %d", r.PortID))
+			exposure += 10
+		}
+	}
+	for _, r := range h.SMBResults {
+		if r.Successful {
+			risk.Exposures = append(risk.Exposures, fmt.Sprintf("SMB access on port %d", r.PortID))
+			exposure += 10
+		}
+	}
+	ports := make([]int, 0, len(h.Ports))
+	for id, p := range h.Ports {
+		if _, ok := ManagementPorts[id]; ok && p.State == "open" {
+			ports = append(ports, id)
+		}
+	}
+	sort.Ints(ports)
+	for _, id := range ports {
+		risk.Exposures = append(risk.Exposures, fmt.Sprintf("%s open on port %d", ManagementPorts[id], id))
+		exposure += 3
+	}
+
+	score := 6*risk.MaxSeverity + 10*risk.MaxEPSS + math.Min(exposure, 25)
+	if risk.KnownExploited > 0 {
+		score += 15
+	}
+	risk.Score = math.Round(math.Min(score, 100)*10) / 10
+	risk.Level = RiskLevel(risk.Score)
+	return risk
 }
 
 // WifiInfo holds 802.11-specific details.
@@ -117,16 +720,128 @@ type WifiInfo struct {
 	HandshakeState string          `json:"handshake_state,omitempty"` // "Full", "Partial"
 }
 
+// WirelessAP is an 802.11 access point (BSS) seen in beacons or probe responses.
+type WirelessAP struct {
+	ID         int64           `json:"id,omitempty"`
+	BSSID      string          `json:"bssid"`
+	SSID       string          `json:"ssid"` // Empty for hidden networks whose name was never revealed
+	Vendor     string          `json:"vendor,omitempty"`
+	Channel    int             `json:"channel,omitempty"`
+	Frequency  int             `json:"frequency,omitempty"` // MHz, from radiotap
+	Encryption string          `json:"encryption"`          // "Open", "WEP", "WPA", "WPA2", "WPA3", "OWE", ... (+ "-Enterprise")
+	AKM        string          `json:"akm,omitempty"`       // Key management suites, e.g. "PSK, SAE"
+	Ciphers    string          `json:"ciphers,omitempty"`   // Pairwise ciphers, e.g. "CCMP"
+	WPS        bool            `json:"wps"`
+	RSSI       int             `json:"rssi,omitempty"` // Strongest signal seen, in dBm; 0 when unknown
+	Beacons    int             `json:"beacons"`
+	Clients    int             `json:"clients,omitempty"` // Associated stations; only set when read from the database
+	SSIDs      map[string]bool `json:"ssids,omitempty"`   // Every SSID the BSSID advertised; more than one suggests a karma AP
+	FirstSeen  time.Time       `json:"first_seen"`
+	LastSeen   time.Time       `json:"last_seen"`
+}
+
+// WirelessClient is an 802.11 station seen probing for or talking to access points.
+type WirelessClient struct {
+	ID              int64           `json:"id,omitempty"`
+	MAC             string          `json:"mac"`
+	Vendor          string          `json:"vendor,omitempty"`
+	AssociatedBSSID string          `json:"associated_bssid,omitempty"`
+	LastAssociation time.Time       `json:"-"` // Latest (re)association request; used to spot reconnects after deauths
+	ProbedSSIDs     map[string]bool `json:"probed_ssids,omitempty"`
+	RSSI            int             `json:"rssi,omitempty"` // Strongest signal seen, in dBm; 0 when unknown
+	Frames          int             `json:"frames"`
+	FirstSeen       time.Time       `json:"first_seen"`
+	LastSeen        time.Time       `json:"last_seen"`
+}
+
+// DeauthEvent is an 802.11 deauthentication or disassociation frame.
+type DeauthEvent struct {
+	BSSID       string    `json:"bssid"`
+	Source      string    `json:"source"`      // Transmitter; often spoofed by attack tools
+	Destination string    `json:"destination"` // The client, or the broadcast address
+	Subtype     string    `json:"subtype"`     // "Deauthentication" or "Disassociation"
+	Reason      int       `json:"reason"`      // IEEE 802.11 reason code
+	Timestamp   time.Time `json:"timestamp"`
+	PcapFile    string    `json:"pcap_file"`
+}
+
+// DeauthPair aggregates the deauthentication and disassociation frames seen between an access
+// point and one client, or the broadcast address, and whether the client came back afterwards.
+type DeauthPair struct {
+	ID             int64       `json:"id,omitempty"`
+	APMAC          string      `json:"ap_mac"`
+	ClientMAC      string      `json:"client_mac"` // FF:FF:FF:FF:FF:FF for broadcast deauths
+	SSID           string      `json:"ssid,omitempty"`
+	Deauths        int         `json:"deauths"`
+	Disassocs      int         `json:"disassocs"`
+	Reasons        map[int]int `json:"reasons"` // Frame count per IEEE 802.11 reason code
+	FirstSeen      time.Time   `json:"first_seen"`
+	LastSeen       time.Time   `json:"last_seen"`
+	ReconnectedAt  time.Time   `json:"reconnected_at"`  // First association or EAPOL frame after the last deauth
+	HandshakeState string      `json:"handshake_state"` // "Full", "Partial" or "" when none was captured
+	PcapFile       string      `json:"pcap_file"`
+}
+
+// deauthReasons names the common IEEE 802.11 reason codes.
+var deauthReasons = map[int]string{
+	1: "Unspecified", 2: "Previous authentication no longer valid", 3: "Station is leaving",
+	4: "Inactivity", 5: "AP unable to handle all associated stations", 6: "Class 2 frame from nonauthenticated station",
+	7: "Class 3 frame from nonassociated station", 8: "Station has left the BSS", 9: "Station not authenticated",
+	14: "MIC failure", 15: "4-way handshake timeout", 16: "Group key handshake timeout", 23: "802.1X authentication failed",
+}
+
+// IsBroadcast reports whether the frames were sent to every client of the access point.
+func (p *DeauthPair) IsBroadcast() bool {
+	return p.ClientMAC == "FF:FF:FF:FF:FF:FF"
+}
+
+// ReasonSummary lists the reason codes with their names and frame counts, most frequent first.
+func (p *DeauthPair) ReasonSummary() string {
+	codes := make([]int, 0, len(p.Reasons))
+	for code := range p.Reasons {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		if p.Reasons[codes[i]] != p.Reasons[codes[j]] {
+			return p.Reasons[codes[i]] > p.Reasons[codes[j]]
+		}
+		return codes[i] < codes[j]
+	})
+	parts := make([]string, 0, len(codes))
+	for _, code := range codes {
+		name := deauthReasons[code]
+		if name == "" {
+			name = "Reserved"
+		}
+		parts = append(parts, fmt.Sprintf("%d %s (x%d)", code, name, p.Reasons[code]))
+	}
+	return strings.Join(parts, "; ")
+}
+
 // PcapSummary holds global statistics from pcap processing.
 type PcapSummary struct {
 	TotalPackets       int
 	ProtocolCounts     map[string]int
-	AdvertisedAPs      map[string]map[string]bool
-	AllProbeRequests   map[string]map[string]bool
+	AccessPoints       map[string]*WirelessAP     // Keyed by upper-case BSSID
+	WirelessClients    map[string]*WirelessClient // Keyed by upper-case MAC
 	UnidentifiedMACs   map[string]string
 	CapturedHandshakes []Handshake
+	DeauthEvents       []DeauthEvent
+	DeauthPairs        []DeauthPair // Built from DeauthEvents by ProcessHandshakes
 	Credentials        []Credential
+	CarvedFiles        []CarvedFile
 	EapolTracker       map[string][]gopacket.Packet `json:"-"`
+	EAPState           map[string][]byte            `json:"-"` // Pending EAP challenges and TLS fragments, by exchange
+	TLSStreams         map[string]*TLSStream        `json:"-"` // Keyed by "srcIP:port>dstIP:port"
+	TCPStreams         map[string]*TCPStream        `json:"-"` // Keyed by "clientIP:port>serverIP:port"
+	FTPTransfers       map[string]*FTPTransfer      `json:"-"` // Announced data connections, keyed by "ip:port"
+	FTPCommands        map[string]*FTPTransfer      `json:"-"` // Latest data connection of each control connection
+	TFTPTransfers      map[string]*TFTPTransfer     `json:"-"` // Keyed by the client's "ip:port"
+	DNSQueries         map[string]time.Time         `json:"-"` // Outstanding queries, keyed by "clientIP>resolverIP#id"
+	DNSActivity        map[string]*DNSActivity      `json:"-"` // Keyed by client IP
+	SourcePackets      map[string]int               `json:"-"` // Packets read so far from each capture
+	InsecureProtocols  map[string]bool              `json:"-"` // Insecure protocol uses already reported, by "ip|rule|port"
+	OPCUAChannels      map[string]string            `json:"-"` // Security policy of OPC UA connections, by "clientIP:port>serverIP:port"
 	PacketSources      map[gopacket.Packet]string   `json:"-"`
 }
 
@@ -134,12 +849,24 @@ type PcapSummary struct {
 func NewPcapSummary() *PcapSummary {
 	return &PcapSummary{
 		ProtocolCounts:     make(map[string]int),
-		AdvertisedAPs:      make(map[string]map[string]bool),
-		AllProbeRequests:   make(map[string]map[string]bool),
+		AccessPoints:       make(map[string]*WirelessAP),
+		WirelessClients:    make(map[string]*WirelessClient),
 		UnidentifiedMACs:   make(map[string]string),
 		CapturedHandshakes: []Handshake{},
+		DeauthEvents:       []DeauthEvent{},
 		Credentials:        []Credential{},
 		EapolTracker:       make(map[string][]gopacket.Packet),
+		EAPState:           make(map[string][]byte),
+		TLSStreams:         make(map[string]*TLSStream),
+		TCPStreams:         make(map[string]*TCPStream),
+		FTPTransfers:       make(map[string]*FTPTransfer),
+		FTPCommands:        make(map[string]*FTPTransfer),
+		TFTPTransfers:      make(map[string]*TFTPTransfer),
+		DNSQueries:         make(map[string]time.Time),
+		DNSActivity:        make(map[string]*DNSActivity),
+		SourcePackets:      make(map[string]int),
+		InsecureProtocols:  make(map[string]bool),
+		OPCUAChannels:      make(map[string]string),
 		PacketSources:      make(map[gopacket.Packet]string),
 	}
 }
@@ -0,0 +1,365 @@
+package processing
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// ArchiveSeparator joins an archive's path and a member name into the virtual path of a file
+// inside the archive, e.g. "evidence.zip::captures/office.pcap.gz". Nested archives repeat it.
+const ArchiveSeparator = "::"
+
+// maxCompressionLayers bounds how many compression layers are unwrapped, and maxArchiveDepth how
+// many archives may be nested, so a crafted file cannot make the scanner loop.
+const (
+	maxCompressionLayers = 4
+	maxArchiveDepth      = 4
+)
+
+// maxInMemoryZip is the largest nested ZIP archive that is buffered in memory. ZIP needs random
+// access, which a decompressed stream or an archive member cannot provide.
+const maxInMemoryZip = 512 << 20
+
+// maxDataFileBytes is the most ReadDataFile reads from a decompressed data file, so a small
+// compression bomb cannot exhaust memory. It is a variable so tests can lower it.
+var maxDataFileBytes int64 = 512 << 20
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	bzip2Magic = []byte("BZh")
+	xzMagic    = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	zipMagic   = []byte("PK\x03\x04")
+)
+
+type archiveKind int
+
+const (
+	notArchive archiveKind = iota
+	zipArchive
+	tarArchive
+)
+
+// readCloser combines a reader with a close function that releases everything beneath it.
+type readCloser struct {
+	io.Reader
+	close func() error
+}
+
+func (rc readCloser) Close() error { return rc.close() }
+
+// OpenDataFile opens a data file for reading. Gzip, zstd, bzip2 and xz content is decompressed
+// transparently, and paths containing ArchiveSeparator are read from inside ZIP or tar archives
+// without extracting anything to disk.
+func OpenDataFile(path string) (io.ReadCloser, error) {
+	archivePath, member, inArchive := cutLast(path, ArchiveSeparator)
+	if !inArchive {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not open %s: %w", path, err)
+		}
+		rc, err := decompress(f)
+		if err != nil {
+			return nil, fmt.Errorf("could not decompress %s: %w", path, err)
+		}
+		return rc, nil
+	}
+
+	rc, err := openArchiveMember(archivePath, member)
+	if err != nil {
+		return nil, fmt.Errorf("could not open %s: %w", path, err)
+	}
+	if rc, err = decompress(rc); err != nil {
+		return nil, fmt.Errorf("could not decompress %s: %w", path, err)
+	}
+	return rc, nil
+}
+
+// ReadDataFile reads a whole data file like os.ReadFile, decompressing it and reading it from
+// inside an archive as OpenDataFile does. Files larger than maxDataFileBytes once decompressed
+// are rejected.
+func ReadDataFile(path string) ([]byte, error) {
+	rc, err := OpenDataFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, maxDataFileBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", path, err)
+	}
+	if int64(len(data)) > maxDataFileBytes {
+		return nil, fmt.Errorf("%s is larger than %d MB once decompressed", path, maxDataFileBytes>>20)
+	}
+	return data, nil
+}
+
+// ExpandArchive lists the data files addressed by a path. A plain or compressed file is returned
+// as is; a ZIP or tar archive (compressed or not) is replaced by the virtual paths of its members,
+// descending into nested archives.
+func ExpandArchive(path string) ([]string, error) {
+	var paths []string
+	err := WalkDataFile(path, func(member string, _ FileType, _ io.Reader) error {
+		paths = append(paths, member)
+		return nil
+	})
+	return paths, err
+}
+
+// WalkDataFile reads the data file at path in a single pass, decompressing it and descending into
+// ZIP and tar archives, and calls fn with the virtual path, detected type and decompressed content
+// of every file it contains. fn may leave content unread. Unlike opening each path returned by
+// ExpandArchive, a tar archive is read only once however many members it has.
+func WalkDataFile(path string, fn func(member string, fileType FileType, content io.Reader) error) error {
+	rc, err := OpenDataFile(path)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return walkContent(path, rc, 0, fn)
+}
+
+func walkContent(path string, r io.Reader, depth int, fn func(string, FileType, io.Reader) error) error {
+	br := bufio.NewReaderSize(r, sniffSize)
+	head, err := br.Peek(sniffSize)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("could not read %s: %w", path, err)
+	}
+	kind := archiveKindOfHead(head)
+	if kind == notArchive {
+		return fn(path, detectContentType(head), br)
+	}
+	if depth >= maxArchiveDepth {
+		return fmt.Errorf("archives nested too deeply in %s", path)
+	}
+
+	if kind == tarArchive {
+		tr := tar.NewReader(br)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("could not read tar archive %s: %w", path, err)
+			}
+			if hdr.Typeflag != tar.TypeReg {
+				continue
+			}
+			if err := walkMember(path+ArchiveSeparator+hdr.Name, io.NopCloser(tr), depth, fn); err != nil {
+				return err
+			}
+		}
+	}
+
+	// An uncompressed ZIP file on disk is read in place; others are buffered from the stream.
+	var zr *zip.Reader
+	if depth == 0 {
+		if onDisk, err := zip.OpenReader(path); err == nil {
+			defer onDisk.Close()
+			zr = &onDisk.Reader
+		}
+	}
+	if zr == nil {
+		if zr, err = zipFromReader(path, br); err != nil {
+			return fmt.Errorf("could not read zip archive %s: %w", path, err)
+		}
+	}
+	for _, file := range zr.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		fr, err := file.Open()
+		if err != nil {
+			return fmt.Errorf("could not open %s%s%s: %w", path, ArchiveSeparator, file.Name, err)
+		}
+		if err := walkMember(path+ArchiveSeparator+file.Name, fr, depth, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkMember decompresses an archive member and walks its content one level deeper.
+func walkMember(member string, rc io.ReadCloser, depth int, fn func(string, FileType, io.Reader) error) error {
+	content, err := decompress(rc)
+	if err != nil {
+		return fmt.Errorf("could not decompress %s: %w", member, err)
+	}
+	defer content.Close()
+	return walkContent(member, content, depth+1, fn)
+}
+
+// decompress unwraps any gzip, zstd, bzip2 or xz layers, recognised by their magic bytes.
+func decompress(rc io.ReadCloser) (io.ReadCloser, error) {
+	for i := 0; i < maxCompressionLayers; i++ {
+		br := bufio.NewReader(rc)
+		head, _ := br.Peek(len(xzMagic))
+		underlying := rc
+
+		var r io.Reader
+		closeLayer := func() error { return nil }
+		switch {
+		case bytes.HasPrefix(head, gzipMagic):
+			gz, err := gzip.NewReader(br)
+			if err != nil {
+				rc.Close()
+				return nil, err
+			}
+			r, closeLayer = gz, gz.Close
+		case bytes.HasPrefix(head, zstdMagic):
+			zr, err := zstd.NewReader(br)
+			if err != nil {
+				rc.Close()
+				return nil, err
+			}
+			r, closeLayer = zr, func() error { zr.Close(); return nil }
+		case bytes.HasPrefix(head, bzip2Magic):
+			r = bzip2.NewReader(br)
+		case bytes.HasPrefix(head, xzMagic):
+			xr, err := xz.NewReader(br)
+			if err != nil {
+				rc.Close()
+				return nil, err
+			}
+			r = xr
+		default:
+			return readCloser{br, rc.Close}, nil
+		}
+		rc = readCloser{r, func() error {
+			closeLayer()
+			return underlying.Close()
+		}}
+	}
+	return rc, nil
+}
+
+// archiveKindOf reports whether the (decompressed) content at path is a ZIP or tar archive.
+func archiveKindOf(path string) (archiveKind, error) {
+	rc, err := OpenDataFile(path)
+	if err != nil {
+		return notArchive, err
+	}
+	defer rc.Close()
+
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(rc, head)
+	return archiveKindOfHead(head[:n]), nil
+}
+
+// archiveKindOfHead recognises a ZIP or tar archive from the first bytes of its content.
+func archiveKindOfHead(head []byte) archiveKind {
+	switch {
+	case bytes.HasPrefix(head, zipMagic):
+		return zipArchive
+	case len(head) >= 262 && bytes.Equal(head[257:262], []byte("ustar")):
+		return tarArchive
+	}
+	return notArchive
+}
+
+// openArchiveMember returns a reader for a member of the ZIP or tar archive at archivePath.
+func openArchiveMember(archivePath, member string) (io.ReadCloser, error) {
+	kind, err := archiveKindOf(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case zipArchive:
+		zr, closeZip, err := openZip(archivePath)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range zr.File {
+			if file.Name != member {
+				continue
+			}
+			fr, err := file.Open()
+			if err != nil {
+				closeZip()
+				return nil, err
+			}
+			return readCloser{fr, func() error {
+				fr.Close()
+				return closeZip()
+			}}, nil
+		}
+		closeZip()
+	case tarArchive:
+		// Tar is sequential, so the archive is read up to the member and then handed over.
+		rc, err := OpenDataFile(archivePath)
+		if err != nil {
+			return nil, err
+		}
+		tr := tar.NewReader(rc)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				rc.Close()
+				return nil, err
+			}
+			if hdr.Name == member {
+				return readCloser{tr, rc.Close}, nil
+			}
+		}
+		rc.Close()
+	default:
+		return nil, fmt.Errorf("%s is not a zip or tar archive", archivePath)
+	}
+	return nil, fmt.Errorf("archive member %s not found", member)
+}
+
+// openZip opens the ZIP archive at a path. Uncompressed archives on disk are read in place;
+// nested or compressed ones are buffered in memory, since ZIP needs random access.
+func openZip(path string) (*zip.Reader, func() error, error) {
+	if !strings.Contains(path, ArchiveSeparator) {
+		if zr, err := zip.OpenReader(path); err == nil {
+			return &zr.Reader, zr.Close, nil
+		}
+	}
+
+	rc, err := OpenDataFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rc.Close()
+	zr, err := zipFromReader(path, rc)
+	if err != nil {
+		return nil, nil, err
+	}
+	return zr, func() error { return nil }, nil
+}
+
+// zipFromReader buffers a ZIP archive of at most maxInMemoryZip bytes from r.
+func zipFromReader(path string, r io.Reader) (*zip.Reader, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxInMemoryZip+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxInMemoryZip {
+		return nil, fmt.Errorf("zip archive %s is larger than %d MB", path, maxInMemoryZip>>20)
+	}
+	return zip.NewReader(bytes.NewReader(data), int64(len(data)))
+}
+
+// cutLast splits s around the last instance of sep.
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
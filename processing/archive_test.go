@@ -0,0 +1,187 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+const archiveTestNmap = `<?xml version="1.0" encoding="UTF-8"?>
+<nmaprun scanner="nmap" args="nmap -sV 10.9.9.9" start="1700000000" version="7.94">
+<host>
+<status state="up" reason="syn-ack"/>
+<address addr="10.9.9.9" addrtype="ipv4"/>
+<ports><port protocol="tcp" portid="22"><state state="open" reason="syn-ack"/><service name="ssh"/></port></ports>
+</host>
+</nmaprun>
+`
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	gz := gzip.NewWriter(&out)
+	gz.Write(data)
+	if err := gz.Close(); err != nil {
+		t.Fatalf("gzip failed: %v", err)
+	}
+	return out.Bytes()
+}
+
+func writeTempBytes(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("could not write temp file: %v", err)
+	}
+	return path
+}
+
+// TestCompressedFiles verifies that gzip, zstd and xz content is detected and parsed transparently.
+func TestCompressedFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	var zst bytes.Buffer
+	zw, _ := zstd.NewWriter(&zst)
+	zw.Write([]byte(archiveTestNmap))
+	zw.Close()
+
+	var xzBuf bytes.Buffer
+	xw, _ := xz.NewWriter(&xzBuf)
+	xw.Write([]byte(archiveTestNmap))
+	xw.Close()
+
+	for name, data := range map[string][]byte{
+		"scan.xml.gz":  gzipBytes(t, []byte(archiveTestNmap)),
+		"scan.xml.zst": zst.Bytes(),
+		"scan.xml.xz":  xzBuf.Bytes(),
+		"scan.gz.gz":   gzipBytes(t, gzipBytes(t, []byte(archiveTestNmap))),
+	} {
+		path := writeTempBytes(t, dir, name, data)
+		if got, err := DetectFileType(path); err != nil || got != FileTypeNmapXML {
+			t.Errorf("%s: expected Nmap XML detection, got %q (%v)", name, got, err)
+			continue
+		}
+		networkMap := model.NewNetworkMap()
+		if err := MergeFromFile(path, networkMap); err != nil {
+			t.Errorf("%s: MergeFromFile failed: %v", name, err)
+			continue
+		}
+		host := networkMap.Hosts["IP:10.9.9.9"]
+		if host == nil {
+			t.Errorf("%s: expected host 10.9.9.9, got %v", name, networkMap.Hosts)
+			continue
+		}
+		if _, ok := host.Ports[22]; !ok {
+			t.Errorf("%s: expected port 22, got %v", name, host.Ports)
+		}
+	}
+}
+
+// TestReadDataFileLimit verifies that a file that decompresses past the limit is rejected rather
+// than read into memory.
+func TestReadDataFileLimit(t *testing.T) {
+	defer func(limit int64) { maxDataFileBytes = limit }(maxDataFileBytes)
+	maxDataFileBytes = 1 << 20
+
+	dir := t.TempDir()
+	bomb := writeTempBytes(t, dir, "bomb.xml.gz", gzipBytes(t, make([]byte, 2<<20)))
+	if _, err := ReadDataFile(bomb); err == nil {
+		t.Error("Expected an error for a file larger than the limit")
+	}
+	small := writeTempBytes(t, dir, "scan.xml.gz", gzipBytes(t, []byte(archiveTestNmap)))
+	if data, err := ReadDataFile(small); err != nil || string(data) != archiveTestNmap {
+		t.Errorf("Expected the small file to be read, got %d bytes (%v)", len(data), err)
+	}
+}
+
+// TestArchiveMembers verifies that ZIP and tar.gz archives, including nested ones, expand into
+// virtual member paths that can be detected and parsed without extracting them.
+func TestArchiveMembers(t *testing.T) {
+	dir := t.TempDir()
+	pcapData := testPcap(t)
+
+	var tarBuf bytes.Buffer
+	tw := tar.NewWriter(&tarBuf)
+	for name, data := range map[string][]byte{"logs/scan.xml": []byte(archiveTestNmap), "capture.pcap.gz": gzipBytes(t, pcapData)} {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg})
+		tw.Write(data)
+	}
+	tw.Close()
+	tarGz := gzipBytes(t, tarBuf.Bytes())
+
+	var zipBuf bytes.Buffer
+	zw := zip.NewWriter(&zipBuf)
+	for name, data := range map[string][]byte{"office.pcap": pcapData, "inner.tar.gz": tarGz, "notes/": nil} {
+		fw, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("zip create failed: %v", err)
+		}
+		fw.Write(data)
+	}
+	zw.Close()
+
+	zipPath := writeTempBytes(t, dir, "evidence.zip", zipBuf.Bytes())
+	paths, err := ExpandArchive(zipPath)
+	if err != nil {
+		t.Fatalf("ExpandArchive failed: %v", err)
+	}
+
+	want := map[string]FileType{
+		zipPath + "::office.pcap":                   FileTypePcap,
+		zipPath + "::inner.tar.gz::logs/scan.xml":   FileTypeNmapXML,
+		zipPath + "::inner.tar.gz::capture.pcap.gz": FileTypePcap,
+	}
+	if len(paths) != len(want) {
+		t.Fatalf("Expected %d members, got %v", len(want), paths)
+	}
+	for _, path := range paths {
+		wantType, ok := want[path]
+		if !ok {
+			t.Errorf("Unexpected member %s", path)
+			continue
+		}
+		if got, err := DetectFileType(path); err != nil || got != wantType {
+			t.Errorf("%s: expected %q, got %q (%v)", path, wantType, got, err)
+		}
+	}
+
+	walked := make(map[string]FileType)
+	err = WalkDataFile(zipPath, func(member string, fileType FileType, content io.Reader) error {
+		data, err := io.ReadAll(content)
+		if err == nil && fileType == FileTypeNmapXML && string(data) != archiveTestNmap {
+			t.Errorf("%s: walked content differs from the member", member)
+		}
+		walked[member] = fileType
+		return err
+	})
+	if err != nil {
+		t.Fatalf("WalkDataFile failed: %v", err)
+	}
+	for path, wantType := range want {
+		if walked[path] != wantType {
+			t.Errorf("WalkDataFile: expected %s to be %q, got %q", path, wantType, walked[path])
+		}
+	}
+
+	networkMap, summary := model.NewNetworkMap(), model.NewPcapSummary()
+	if err := EnrichData(zipPath+"::inner.tar.gz::capture.pcap.gz", networkMap, summary); err != nil {
+		t.Fatalf("EnrichData failed on an archive member: %v", err)
+	}
+	if _, ok := networkMap.Hosts["00:0C:29:AA:BB:01"]; !ok {
+		t.Errorf("Expected the packet's source host, got %v", networkMap.Hosts)
+	}
+
+	plain := writeTempBytes(t, dir, "plain.xml", []byte(archiveTestNmap))
+	if paths, err := ExpandArchive(plain); err != nil || len(paths) != 1 || paths[0] != plain {
+		t.Errorf("Expected a plain file to expand to itself, got %v (%v)", paths, err)
+	}
+}
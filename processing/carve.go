@@ -0,0 +1,79 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// addCarvedFile records a file reassembled from traffic against the local host that sent or
+// received it. The same content moving between the same hosts under the same name is only
+// recorded once.
+func addCarvedFile(summary *model.PcapSummary, host *model.Host, protocol, filename, declaredType, srcIP, dstIP string, ts time.Time, pcapFile string, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+	for _, existing := range summary.CarvedFiles {
+		if existing.SHA256 == digest && existing.Protocol == protocol && existing.Filename == filename && existing.SourceIP == srcIP && existing.DestinationIP == dstIP {
+			return
+		}
+	}
+	md5Sum, sha1Sum := md5.Sum(data), sha1.Sum(data)
+	file := model.CarvedFile{
+		Filename:      filename,
+		MIMEType:      carvedMIMEType(data, declaredType),
+		Size:          len(data),
+		MD5:           hex.EncodeToString(md5Sum[:]),
+		SHA1:          hex.EncodeToString(sha1Sum[:]),
+		SHA256:        digest,
+		Protocol:      protocol,
+		SourceIP:      srcIP,
+		DestinationIP: dstIP,
+		Timestamp:     ts,
+		PcapFile:      pcapFile,
+		Data:          data,
+	}
+	if host != nil {
+		file.HostMAC = host.MACAddress
+	}
+	summary.CarvedFiles = append(summary.CarvedFiles, file)
+}
+
+// carvedMIMEType sniffs a file's type from its content, falling back to the type the protocol
+// declared when the content is not recognised.
+func carvedMIMEType(data []byte, declared string) string {
+	detected, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	if detected == "application/octet-stream" && declared != "" {
+		if mediaType, _, err := mime.ParseMediaType(declared); err == nil {
+			return mediaType
+		}
+	}
+	return detected
+}
+
+// carvedFilename returns the last element of a URL, FTP, TFTP or SMB path, or fallback when the
+// path does not name a file.
+func carvedFilename(path, fallback string) string {
+	path = strings.TrimRight(strings.ReplaceAll(path, "\\", "/"), "/")
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		path = path[i+1:]
+	}
+	path = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, path))
+	if path == "" || path == "." || path == ".." {
+		return fallback
+	}
+	return path
+}
@@ -0,0 +1,225 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/google/gopacket"
+)
+
+// smb2Frame wraps SMB2 messages in a NetBIOS session message.
+func smb2Frame(messages ...[]byte) []byte {
+	var data []byte
+	for _, m := range messages {
+		data = append(data, m...)
+	}
+	return append([]byte{0, byte(len(data) >> 16), byte(len(data) >> 8), byte(len(data))}, data...)
+}
+
+// smb2TestMessage builds an SMB2 message whose body is filled in by fields, a map of offsets
+// within the body to little-endian values or raw bytes.
+func smb2TestMessage(command uint16, messageID uint64, response bool, bodySize int, fields map[int]interface{}, tail []byte) []byte {
+	m := make([]byte, smb2HeaderSize+bodySize)
+	copy(m, smb2ProtocolID)
+	binary.LittleEndian.PutUint16(m[4:], smb2HeaderSize)
+	binary.LittleEndian.PutUint16(m[12:], command)
+	if response {
+		binary.LittleEndian.PutUint32(m[16:], smb2FlagServerToRedir)
+	}
+	binary.LittleEndian.PutUint64(m[24:], messageID)
+	body := m[smb2HeaderSize:]
+	for offset, value := range fields {
+		switch v := value.(type) {
+		case uint16:
+			binary.LittleEndian.PutUint16(body[offset:], v)
+		case uint32:
+			binary.LittleEndian.PutUint32(body[offset:], v)
+		case uint64:
+			binary.LittleEndian.PutUint64(body[offset:], v)
+		case byte:
+			body[offset] = v
+		case []byte:
+			copy(body[offset:], v)
+		}
+	}
+	return append(m, tail...)
+}
+
+func utf16le(s string) []byte {
+	var b []byte
+	for _, u := range utf16.Encode([]rune(s)) {
+		b = binary.LittleEndian.AppendUint16(b, u)
+	}
+	return b
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// TestFileCarving verifies that complete files are carved from HTTP downloads and uploads, FTP
+// data connections, TFTP reads and SMB2 reads and writes, and that web pages, listings and
+// partial transfers are not.
+func TestFileCarving(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var packets []gopacket.Packet
+
+	// HTTP: a gzip-encoded download, a page, and a multipart upload.
+	firmware := bytes.Repeat([]byte{0x7f, 'E', 'L', 'F', 1, 2, 3, 4}, 64)
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(firmware)
+	zw.Close()
+	requests := "GET /dl?id=7 HTTP/1.1\r\nHost: updates.corp.example\r\n\r\n" +
+		"GET / HTTP/1.1\r\nHost: updates.corp.example\r\n\r\n"
+	responses := "HTTP/1.1 200 OK\r\nContent-Type: application/octet-stream\r\nContent-Encoding: gzip\r\nContent-Disposition: attachment; filename=\"fw-1.2.bin\"\r\n" +
+		"Content-Length: " + strconv.Itoa(gz.Len()) + "\r\n\r\n" + gz.String() +
+		"HTTP/1.1 200 OK\r\nContent-Type: text/html\r\nContent-Length: 13\r\n\r\n<html></html>"
+	packets = append(packets,
+		httpSegment(t, true, 41000, 1, true, requests, start),
+		httpSegment(t, false, 41000, 1, true, responses, start),
+	)
+	upload := "--XYZ\r\nContent-Disposition: form-data; name=\"comment\"\r\n\r\nhello\r\n" +
+		"--XYZ\r\nContent-Disposition: form-data; name=\"file\"; filename=\"notes.txt\"\r\nContent-Type: text/plain\r\n\r\nremember the admin password\r\n--XYZ--\r\n"
+	packets = append(packets, httpSegment(t, true, 41001, 1, false, "POST /upload HTTP/1.1\r\nHost: files.corp.example\r\nContent-Type: multipart/form-data; boundary=XYZ\r\n"+
+		"Content-Length: "+strconv.Itoa(len(upload))+"\r\n\r\n"+upload, start))
+
+	// FTP: a listing and a download over passive data connections.
+	control := func(fromClient bool, line string) gopacket.Packet {
+		return tcpTestSegment(t, fromClient, 40000, 21, 0, false, []byte(line+"\r\n"), start)
+	}
+	config := []byte("hostname core-sw1\nenable secret 5 $1$abcd$efgh\n")
+	packets = append(packets,
+		control(true, "PASV"),
+		control(false, "227 Entering Passive Mode (10,0,0,10,195,81)."),
+		control(true, "LIST"),
+		tcpTestSegment(t, false, 40001, 50001, 1, true, []byte("-rw-r--r-- 1 ftp ftp 48 May 1 config.txt\r\n"), start),
+		tcpTestSegment(t, true, 40001, 50001, 1, true, nil, start),
+		control(true, "PASV"),
+		control(false, "227 Entering Passive Mode (10,0,0,10,195,80)."),
+		control(true, "RETR /pub/config.txt"),
+		tcpTestSegment(t, false, 40002, 50000, 1, true, config, start),
+		tcpTestSegment(t, true, 40002, 50000, 1, true, nil, start),
+	)
+
+	// TFTP: a read of two blocks, with the first retransmitted.
+	tftpFile := bytes.Repeat([]byte("boot "), 120) // 600 bytes
+	block := func(n uint16, data []byte) []byte {
+		return append([]byte{0, tftpData, byte(n >> 8), byte(n)}, data...)
+	}
+	packets = append(packets,
+		udpTestPacket(t, true, 3000, 69, append([]byte{0, tftpRRQ}, "pxe/boot.cfg\x00octet\x00"...), start),
+		udpTestPacket(t, false, 3000, 4000, block(1, tftpFile[:512]), start),
+		udpTestPacket(t, false, 3000, 4000, block(1, tftpFile[:512]), start),
+		udpTestPacket(t, false, 3000, 4000, block(2, tftpFile[512:]), start),
+	)
+
+	// SMB2: a file read in two parts, a file only partly read, and a file written.
+	document := []byte("%PDF-1.4 quarterly results and other confidential content")
+	fileID := func(n byte) []byte { return bytes.Repeat([]byte{n}, 16) }
+	create := func(id uint64, name string) []byte {
+		return smb2TestMessage(smb2Create, id, false, 56, map[int]interface{}{0: uint16(57), 44: uint16(smb2HeaderSize + 56), 46: uint16(len(utf16le(name)))}, utf16le(name))
+	}
+	created := func(id uint64, size uint64, handle []byte) []byte {
+		return smb2TestMessage(smb2Create, id, true, 88, map[int]interface{}{0: uint16(89), 48: size, 64: handle}, nil)
+	}
+	read := func(id uint64, offset uint64, handle []byte) []byte {
+		return smb2TestMessage(smb2Read, id, false, 49, map[int]interface{}{0: uint16(49), 8: offset, 16: handle}, nil)
+	}
+	readData := func(id uint64, data []byte) []byte {
+		return smb2TestMessage(smb2Read, id, true, 16, map[int]interface{}{0: uint16(17), 2: byte(smb2HeaderSize + 16), 4: uint32(len(data))}, data)
+	}
+	script := []byte("net user backdoor P@ssw0rd /add\r\n")
+	clientSMB := smb2Frame(create(1, `finance\q3.pdf`))
+	clientSMB = append(clientSMB, smb2Frame(read(2, 0, fileID(1)))...)
+	clientSMB = append(clientSMB, smb2Frame(read(3, 20, fileID(1)))...)
+	clientSMB = append(clientSMB, smb2Frame(create(4, `finance\big.xlsx`))...)
+	clientSMB = append(clientSMB, smb2Frame(read(5, 0, fileID(2)))...)
+	clientSMB = append(clientSMB, smb2Frame(create(6, `scripts\run.bat`))...)
+	clientSMB = append(clientSMB, smb2Frame(smb2TestMessage(smb2Write, 7, false, 48, map[int]interface{}{0: uint16(49), 2: uint16(smb2HeaderSize + 48), 4: uint32(len(script)), 16: fileID(3)}, script))...)
+	// Offsets near the int64 limit must be ignored rather than overflow the file buffer.
+	huge := uint64(1<<63 - 8)
+	clientSMB = append(clientSMB, smb2Frame(create(8, `evil.bin`))...)
+	clientSMB = append(clientSMB, smb2Frame(smb2TestMessage(smb2Write, 9, false, 48, map[int]interface{}{0: uint16(49), 2: uint16(smb2HeaderSize + 48), 4: uint32(len(script)), 8: huge, 16: fileID(4)}, script))...)
+	clientSMB = append(clientSMB, smb2Frame(read(10, huge, fileID(4)))...)
+	// So must an end of file that does not fit an int64.
+	clientSMB = append(clientSMB, smb2Frame(create(11, `bomb.bin`))...)
+	clientSMB = append(clientSMB, smb2Frame(read(12, 0, fileID(5)))...)
+	serverSMB := smb2Frame(created(1, uint64(len(document)), fileID(1)))
+	serverSMB = append(serverSMB, smb2Frame(readData(3, document[20:]))...) // Answered out of order
+	serverSMB = append(serverSMB, smb2Frame(readData(2, document[:20]))...)
+	serverSMB = append(serverSMB, smb2Frame(created(4, 4096, fileID(2)))...)
+	serverSMB = append(serverSMB, smb2Frame(readData(5, make([]byte, 1024)))...)
+	serverSMB = append(serverSMB, smb2Frame(created(6, 0, fileID(3)))...)
+	serverSMB = append(serverSMB, smb2Frame(created(8, 0, fileID(4)))...)
+	serverSMB = append(serverSMB, smb2Frame(readData(10, script))...)
+	serverSMB = append(serverSMB, smb2Frame(created(11, 1<<64-1, fileID(5)))...)
+	serverSMB = append(serverSMB, smb2Frame(readData(12, script))...)
+	packets = append(packets,
+		tcpTestSegment(t, true, 49000, 445, 1, false, clientSMB, start),
+		tcpTestSegment(t, false, 49000, 445, 1, false, serverSMB, start),
+	)
+
+	networkMap, summary := model.NewNetworkMap(), model.NewPcapSummary()
+	for _, packet := range packets {
+		ProcessPacket(packet, networkMap, summary, "carve.pcap")
+	}
+	FlushStreams(networkMap, summary)
+
+	files := make(map[string]model.CarvedFile)
+	for _, f := range summary.CarvedFiles {
+		if _, dup := files[f.Filename]; dup {
+			t.Errorf("Duplicate carved file %s", f.Filename)
+		}
+		files[f.Filename] = f
+	}
+	expected := []struct {
+		name, protocol, src, dst, mimeType string
+		data                               []byte
+	}{
+		{"fw-1.2.bin", "HTTP", "10.0.0.10", "10.0.0.5", "application/octet-stream", firmware},
+		{"notes.txt", "HTTP", "10.0.0.5", "10.0.0.10", "text/plain", []byte("remember the admin password")},
+		{"config.txt", "FTP-DATA", "10.0.0.10", "10.0.0.5", "text/plain", config},
+		{"boot.cfg", "TFTP", "10.0.0.10", "10.0.0.5", "text/plain", tftpFile},
+		{"q3.pdf", "SMB2", "10.0.0.10", "10.0.0.5", "application/pdf", document},
+		{"run.bat", "SMB2", "10.0.0.5", "10.0.0.10", "text/plain", script},
+	}
+	if len(files) != len(expected) {
+		t.Errorf("Expected %d carved files, got %d: %v", len(expected), len(files), summary.CarvedFiles)
+	}
+	for _, want := range expected {
+		got, ok := files[want.name]
+		if !ok {
+			t.Errorf("Expected %s to be carved", want.name)
+			continue
+		}
+		if got.Protocol != want.protocol || got.SourceIP != want.src || got.DestinationIP != want.dst || got.MIMEType != want.mimeType {
+			t.Errorf("Unexpected metadata for %s: %+v", want.name, got)
+		}
+		if !bytes.Equal(got.Data, want.data) || got.Size != len(want.data) || got.SHA256 != sha256Hex(want.data) || got.MD5 == "" || got.SHA1 == "" {
+			t.Errorf("Unexpected contents or hashes for %s: %d bytes, sha256 %s", want.name, got.Size, got.SHA256)
+		}
+		if got.HostMAC != "02:00:00:00:00:05" || got.PcapFile != "carve.pcap" || !got.Timestamp.Equal(start) {
+			t.Errorf("Unexpected host, source or time for %s: %+v", want.name, got)
+		}
+	}
+	if len(summary.TFTPTransfers) != 0 || len(summary.FTPTransfers) != 0 {
+		t.Errorf("Expected finished transfers to be cleared, got %v and %v", summary.TFTPTransfers, summary.FTPTransfers)
+	}
+	if _, ok := (&smb2File{size: -1}).complete(); ok {
+		t.Error("Expected a file with a negative size not to be complete")
+	}
+	if strings.Contains(carvedFilename(`..\..\windows\system32`, "x"), `\`) || carvedFilename("/", "index") != "index" {
+		t.Error("Expected carved file names to be reduced to their last element")
+	}
+}
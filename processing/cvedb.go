@@ -0,0 +1,748 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	cveDatabaseSource    = "Offline CVE database"
+	stateVersionInferred = "VERSION-INFERRED"
+)
+
+// CVELookup returns the known CVEs for a product; storage.LookupCVEs is the implementation used
+// outside of tests.
+type CVELookup func(vendor, product string) ([]model.CVERecord, error)
+
+// serviceProducts maps Nmap product names to their CPE vendor and product, for services that
+// were identified without a CPE.
+var serviceProducts = map[string][2]string{
+	"apache httpd":                {"apache", "http_server"},
+	"apache tomcat":               {"apache", "tomcat"},
+	"nginx":                       {"f5", "nginx"},
+	"microsoft iis httpd":         {"microsoft", "internet_information_services"},
+	"lighttpd":                    {"lighttpd", "lighttpd"},
+	"openssh":                     {"openbsd", "openssh"},
+	"dropbear sshd":               {"dropbear_ssh_project", "dropbear_ssh"},
+	"vsftpd":                      {"beasts", "vsftpd"},
+	"proftpd":                     {"proftpd", "proftpd"},
+	"pure-ftpd":                   {"pureftpd", "pure-ftpd"},
+	"isc bind":                    {"isc", "bind"},
+	"samba smbd":                  {"samba", "samba"},
+	"mysql":                       {"oracle", "mysql"},
+	"mariadb":                     {"mariadb", "mariadb"},
+	"postgresql db":               {"postgresql", "postgresql"},
+	"exim smtpd":                  {"exim", "exim"},
+	"postfix smtpd":               {"postfix", "postfix"},
+	"openssl":                     {"openssl", "openssl"},
+	"redis key-value store":       {"redis", "redis"},
+	"elasticsearch rest api":      {"elastic", "elasticsearch"},
+	"microsoft sql server":        {"microsoft", "sql_server"},
+	"microsoft terminal services": {"microsoft", "remote_desktop_services"},
+}
+
+// cpeAliases lists the NVD names of products that Nmap's CPEs (or the table above) name
+// differently. NVD moved some products to a new vendor, so older CVEs keep the old name.
+var cpeAliases = map[[2]string][][2]string{
+	{"igor_sysoev", "nginx"}:                 {{"f5", "nginx"}, {"nginx", "nginx"}},
+	{"f5", "nginx"}:                          {{"nginx", "nginx"}},
+	{"mysql", "mysql"}:                       {{"oracle", "mysql"}},
+	{"microsoft", "iis"}:                     {{"microsoft", "internet_information_services"}},
+	{"matt_johnston", "dropbear_ssh_server"}: {{"dropbear_ssh_project", "dropbear_ssh"}},
+	{"vsftpd", "vsftpd"}:                     {{"beasts", "vsftpd"}},
+	{"redislabs", "redis"}:                   {{"redis", "redis"}},
+	{"elasticsearch", "elasticsearch"}:       {{"elastic", "elasticsearch"}},
+}
+
+// languageEcosystems are the OSV ecosystems of programming language packages. A PyPI package
+// called redis is a client library, not the server a port runs, so they are not matched against
+// services.
+var languageEcosystems = map[string]bool{
+	"pypi": true, "npm": true, "go": true, "maven": true, "crates.io": true, "rubygems": true,
+	"nuget": true, "packagist": true, "pub": true, "hex": true, "hackage": true, "cran": true,
+	"swifturl": true, "github actions": true, "conancenter": true, "bioconductor": true,
+}
+
+// ImportCVEFeeds reads NVD JSON feeds (1.1 or the 2.0 API format) and OSV records from a file,
+// an archive or a directory of them. Files that are not vulnerability feeds are skipped.
+func ImportCVEFeeds(path string) ([]model.CVERecord, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", path, err)
+	}
+	files := []string{path}
+	if info.IsDir() {
+		files = nil
+		err := filepath.Walk(path, func(file string, fi os.FileInfo, err error) error {
+			if err == nil && !fi.IsDir() {
+				files = append(files, file)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("could not list %s: %w", path, err)
+		}
+	}
+
+	byID := make(map[string]model.CVERecord)
+	parsed := 0
+	for _, file := range files {
+		// OSV publishes whole ecosystems as one archive, so members are read in a single pass.
+		err := WalkDataFile(file, func(member string, _ FileType, content io.Reader) error {
+			data, err := io.ReadAll(content)
+			if err != nil {
+				return fmt.Errorf("could not read %s: %w", member, err)
+			}
+			records, err := ParseCVEFeed(data)
+			if err != nil {
+				log.Printf("Skipping %s: %v", member, err)
+				return nil
+			}
+			parsed++
+			for _, r := range records {
+				// A CVE in several feeds (e.g. NVD and OSV) affects the products of all of them.
+				if existing, ok := byID[r.ID]; ok {
+					r.Affected = append(existing.Affected, r.Affected...)
+					if r.CVSS == 0 {
+						r.CVSS = existing.CVSS
+					}
+					r.Description = defaultIfEmpty(r.Description, existing.Description)
+				}
+				byID[r.ID] = r
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if parsed == 0 {
+		return nil, fmt.Errorf("no NVD or OSV feeds found in %s", path)
+	}
+	records := make([]model.CVERecord, 0, len(byID))
+	for _, r := range byID {
+		records = append(records, r)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+	return records, nil
+}
+
+type nvdLangString struct {
+	Lang  string `json:"lang"`
+	Value string `json:"value"`
+}
+
+type nvdNode struct {
+	Children  []nvdNode     `json:"children"`  // NVD 1.1
+	CPEMatch  []nvdCPEMatch `json:"cpe_match"` // NVD 1.1
+	CPEMatch2 []nvdCPEMatch `json:"cpeMatch"`  // NVD 2.0
+}
+
+type nvdCPEMatch struct {
+	Vulnerable     bool   `json:"vulnerable"`
+	CPE23URI       string `json:"cpe23Uri"` // NVD 1.1
+	Criteria       string `json:"criteria"` // NVD 2.0
+	StartIncluding string `json:"versionStartIncluding"`
+	StartExcluding string `json:"versionStartExcluding"`
+	EndIncluding   string `json:"versionEndIncluding"`
+	EndExcluding   string `json:"versionEndExcluding"`
+}
+
+type nvdMetric struct {
+	Data struct {
+		BaseScore float64 `json:"baseScore"`
+	} `json:"cvssData"`
+}
+
+type nvdFeed struct {
+	// NVD 1.1 data feeds.
+	Items []struct {
+		CVE struct {
+			Meta struct {
+				ID string `json:"ID"`
+			} `json:"CVE_data_meta"`
+			References struct {
+				Data []struct {
+					URL string `json:"url"`
+				} `json:"reference_data"`
+			} `json:"references"`
+			Description struct {
+				Data []nvdLangString `json:"description_data"`
+			} `json:"description"`
+		} `json:"cve"`
+		Configurations struct {
+			Nodes []nvdNode `json:"nodes"`
+		} `json:"configurations"`
+		Impact struct {
+			V3 struct {
+				CVSS struct {
+					BaseScore float64 `json:"baseScore"`
+				} `json:"cvssV3"`
+			} `json:"baseMetricV3"`
+			V2 struct {
+				CVSS struct {
+					BaseScore float64 `json:"baseScore"`
+				} `json:"cvssV2"`
+			} `json:"baseMetricV2"`
+		} `json:"impact"`
+		Published string `json:"publishedDate"`
+	} `json:"CVE_Items"`
+
+	// NVD 2.0 API responses and feeds.
+	Vulnerabilities []struct {
+		CVE struct {
+			ID           string          `json:"id"`
+			Published    string          `json:"published"`
+			Descriptions []nvdLangString `json:"descriptions"`
+			Metrics      struct {
+				V31 []nvdMetric `json:"cvssMetricV31"`
+				V30 []nvdMetric `json:"cvssMetricV30"`
+				V2  []nvdMetric `json:"cvssMetricV2"`
+			} `json:"metrics"`
+			Configurations []struct {
+				Nodes []nvdNode `json:"nodes"`
+			} `json:"configurations"`
+			References []struct {
+				URL string `json:"url"`
+			} `json:"references"`
+		} `json:"cve"`
+	} `json:"vulnerabilities"`
+}
+
+type osvRecord struct {
+	ID        string   `json:"id"`
+	Aliases   []string `json:"aliases"`
+	Summary   string   `json:"summary"`
+	Details   string   `json:"details"`
+	Published string   `json:"published"`
+	Severity  []struct {
+		Type  string `json:"type"`
+		Score string `json:"score"`
+	} `json:"severity"`
+	References []struct {
+		URL string `json:"url"`
+	} `json:"references"`
+	Affected []struct {
+		Package struct {
+			Ecosystem string `json:"ecosystem"`
+			Name      string `json:"name"`
+		} `json:"package"`
+		Ranges []struct {
+			Type   string              `json:"type"`
+			Events []map[string]string `json:"events"`
+		} `json:"ranges"`
+		Versions []string `json:"versions"`
+	} `json:"affected"`
+}
+
+// ParseCVEFeed parses an NVD 1.1 or 2.0 JSON feed, or a single OSV record or a list of them.
+func ParseCVEFeed(data []byte) ([]model.CVERecord, error) {
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("[")) {
+		var entries []osvRecord
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, fmt.Errorf("could not parse OSV records: %w", err)
+		}
+		records := make([]model.CVERecord, 0, len(entries))
+		for _, entry := range entries {
+			records = append(records, entry.toRecord())
+		}
+		return records, nil
+	}
+
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("not a JSON vulnerability feed: %w", err)
+	}
+	if _, ok := probe["CVE_Items"]; ok {
+		return parseNVDFeed(data)
+	}
+	if _, ok := probe["vulnerabilities"]; ok {
+		return parseNVDFeed(data)
+	}
+	if _, ok := probe["affected"]; ok {
+		var entry osvRecord
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return nil, fmt.Errorf("could not parse OSV record: %w", err)
+		}
+		return []model.CVERecord{entry.toRecord()}, nil
+	}
+	return nil, fmt.Errorf("not an NVD or OSV feed")
+}
+
+func parseNVDFeed(data []byte) ([]model.CVERecord, error) {
+	var feed nvdFeed
+	if err := json.Unmarshal(data, &feed); err != nil {
+		return nil, fmt.Errorf("could not parse NVD feed: %w", err)
+	}
+	var records []model.CVERecord
+	for _, item := range feed.Items {
+		r := model.CVERecord{
+			ID:          item.CVE.Meta.ID,
+			Description: englishText(item.CVE.Description.Data),
+			CVSS:        item.Impact.V3.CVSS.BaseScore,
+			Published:   parseFeedTime(item.Published),
+			Affected:    nvdAffected(item.Configurations.Nodes),
+		}
+		if r.CVSS == 0 {
+			r.CVSS = item.Impact.V2.CVSS.BaseScore
+		}
+		for _, ref := range item.CVE.References.Data {
+			r.References = append(r.References, ref.URL)
+		}
+		records = append(records, r)
+	}
+	for _, vuln := range feed.Vulnerabilities {
+		cve := vuln.CVE
+		r := model.CVERecord{ID: cve.ID, Description: englishText(cve.Descriptions), Published: parseFeedTime(cve.Published)}
+		for _, metrics := range [][]nvdMetric{cve.Metrics.V31, cve.Metrics.V30, cve.Metrics.V2} {
+			if len(metrics) > 0 {
+				r.CVSS = metrics[0].Data.BaseScore
+				break
+			}
+		}
+		for _, config := range cve.Configurations {
+			r.Affected = append(r.Affected, nvdAffected(config.Nodes)...)
+		}
+		for _, ref := range cve.References {
+			r.References = append(r.References, ref.URL)
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// nvdAffected flattens the vulnerable CPE matches of a configuration.
+func nvdAffected(nodes []nvdNode) []model.CVEAffected {
+	var affected []model.CVEAffected
+	for _, node := range nodes {
+		for _, match := range append(node.CPEMatch, node.CPEMatch2...) {
+			if !match.Vulnerable {
+				continue
+			}
+			_, vendor, product, version, ok := parseCPE(defaultIfEmpty(match.Criteria, match.CPE23URI))
+			if !ok {
+				continue
+			}
+			affected = append(affected, model.CVEAffected{
+				Vendor: vendor, Product: product, Version: version,
+				StartIncluding: match.StartIncluding, StartExcluding: match.StartExcluding,
+				EndIncluding: match.EndIncluding, EndExcluding: match.EndExcluding,
+			})
+		}
+		affected = append(affected, nvdAffected(node.Children)...)
+	}
+	return affected
+}
+
+func (entry osvRecord) toRecord() model.CVERecord {
+	r := model.CVERecord{ID: entry.ID, Description: defaultIfEmpty(entry.Summary, entry.Details), Published: parseFeedTime(entry.Published)}
+	if !strings.HasPrefix(r.ID, "CVE-") {
+		for _, alias := range entry.Aliases {
+			if strings.HasPrefix(alias, "CVE-") {
+				r.ID = alias
+				break
+			}
+		}
+	}
+	for _, severity := range entry.Severity {
+		if severity.Type == "CVSS_V3" {
+			r.CVSS = cvss3BaseScore(severity.Score)
+		}
+	}
+	for _, ref := range entry.References {
+		r.References = append(r.References, ref.URL)
+	}
+	for _, affected := range entry.Affected {
+		product, ecosystem := osvProduct(affected.Package.Name), affected.Package.Ecosystem
+		if product == "" {
+			continue
+		}
+		for _, version := range affected.Versions {
+			r.Affected = append(r.Affected, model.CVEAffected{Ecosystem: ecosystem, Product: product, Version: version})
+		}
+		for _, rng := range affected.Ranges {
+			if rng.Type == "GIT" {
+				continue
+			}
+			var start string
+			open := false
+			for _, event := range rng.Events {
+				switch {
+				case event["introduced"] != "":
+					start, open = event["introduced"], true
+					if start == "0" {
+						start = ""
+					}
+				case event["fixed"] != "" && open:
+					r.Affected = append(r.Affected, model.CVEAffected{Ecosystem: ecosystem, Product: product, StartIncluding: start, EndExcluding: event["fixed"]})
+					open = false
+				case event["last_affected"] != "" && open:
+					r.Affected = append(r.Affected, model.CVEAffected{Ecosystem: ecosystem, Product: product, StartIncluding: start, EndIncluding: event["last_affected"]})
+					open = false
+				}
+			}
+			if open {
+				r.Affected = append(r.Affected, model.CVEAffected{Ecosystem: ecosystem, Product: product, StartIncluding: start})
+			}
+		}
+	}
+	return r
+}
+
+// osvProduct turns an OSV package name (e.g. "org.apache.tomcat:tomcat" or "github.com/x/y")
+// into a product name comparable with CPE products.
+func osvProduct(name string) string {
+	if i := strings.LastIndexAny(name, ":/"); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+func englishText(texts []nvdLangString) string {
+	for _, text := range texts {
+		if text.Lang == "en" {
+			return text.Value
+		}
+	}
+	if len(texts) > 0 {
+		return texts[0].Value
+	}
+	return ""
+}
+
+func parseFeedTime(value string) time.Time {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04Z", "2006-01-02T15:04:05.000", "2006-01-02T15:04:05"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC()
+		}
+	}
+	return time.Time{}
+}
+
+// parseCPE splits a CPE 2.3 formatted string or a CPE 2.2 URI into its part, vendor, product and
+// version. A version of "*" or "-" is returned as empty.
+func parseCPE(cpe string) (part, vendor, product, version string, ok bool) {
+	var fields []string
+	switch {
+	case strings.HasPrefix(cpe, "cpe:2.3:"):
+		fields = splitEscaped(strings.TrimPrefix(cpe, "cpe:2.3:"))
+	case strings.HasPrefix(cpe, "cpe:/"):
+		fields = strings.Split(strings.TrimPrefix(cpe, "cpe:/"), ":")
+	default:
+		return "", "", "", "", false
+	}
+	if len(fields) < 3 || fields[1] == "" || fields[2] == "" {
+		return "", "", "", "", false
+	}
+	part, vendor, product = fields[0], strings.ToLower(fields[1]), strings.ToLower(fields[2])
+	if len(fields) > 3 && fields[3] != "*" && fields[3] != "-" {
+		version = fields[3]
+	}
+	return part, vendor, product, version, true
+}
+
+// splitEscaped splits a CPE 2.3 string on unescaped colons and removes the escapes.
+func splitEscaped(s string) []string {
+	var fields []string
+	var field strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s):
+			i++
+			field.WriteByte(s[i])
+		case s[i] == ':':
+			fields = append(fields, field.String())
+			field.Reset()
+		default:
+			field.WriteByte(s[i])
+		}
+	}
+	return append(fields, field.String())
+}
+
+// compareVersions compares version strings segment by segment: numbers numerically, other text
+// alphabetically. Trailing pre-release markers (alpha, beta, rc, ...) sort before the release.
+func compareVersions(a, b string) int {
+	as, bs := versionSegments(a), versionSegments(b)
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		if i >= len(as) {
+			return -tailOrder(bs[i])
+		}
+		if i >= len(bs) {
+			return tailOrder(as[i])
+		}
+		x, y := as[i], bs[i]
+		xNum, yNum := isDigit(x[0]), isDigit(y[0])
+		switch {
+		case xNum && yNum:
+			x, y = strings.TrimLeft(x, "0"), strings.TrimLeft(y, "0")
+			if len(x) != len(y) {
+				return sign(len(x) - len(y))
+			}
+			if c := strings.Compare(x, y); c != 0 {
+				return c
+			}
+		case xNum != yNum:
+			if xNum {
+				return 1
+			}
+			return -1
+		default:
+			if c := strings.Compare(x, y); c != 0 {
+				return c
+			}
+		}
+	}
+	return 0
+}
+
+// tailOrder is how a version with the extra segment compares to one without it.
+func tailOrder(segment string) int {
+	switch segment {
+	case "alpha", "a", "beta", "b", "rc", "pre", "dev", "preview":
+		return -1
+	}
+	return 1
+}
+
+func versionSegments(version string) []string {
+	version = strings.ToLower(version)
+	isSeparator := func(b byte) bool { return b == '.' || b == '-' || b == '_' || b == '+' }
+	var segments []string
+	start := -1
+	for i := 0; i < len(version); i++ {
+		c := version[i]
+		if start >= 0 && (isSeparator(c) || isDigit(c) != isDigit(version[start])) {
+			segments = append(segments, version[start:i])
+			start = -1
+		}
+		if start < 0 && !isSeparator(c) {
+			start = i
+		}
+	}
+	if start >= 0 {
+		segments = append(segments, version[start:])
+	}
+	return segments
+}
+
+func isDigit(b byte) bool { return b >= '0' && b <= '9' }
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+// affectsVersion reports whether the version is within the affected entry.
+func affectsVersion(a model.CVEAffected, version string) bool {
+	if a.Version != "" {
+		return compareVersions(version, a.Version) == 0
+	}
+	return (a.StartIncluding == "" || compareVersions(version, a.StartIncluding) >= 0) &&
+		(a.StartExcluding == "" || compareVersions(version, a.StartExcluding) > 0) &&
+		(a.EndIncluding == "" || compareVersions(version, a.EndIncluding) <= 0) &&
+		(a.EndExcluding == "" || compareVersions(version, a.EndExcluding) < 0)
+}
+
+// serviceProduct identifies what runs on a port for CVE matching.
+type serviceProduct struct {
+	Vendor, Product, Version string
+}
+
+// portProducts returns the products identified on a port, from its CPEs and else from the
+// product and version the scanner reported.
+func portProducts(port model.Port) []serviceProduct {
+	name, version := splitProductVersion(port.Version)
+	var products []serviceProduct
+	for _, cpe := range port.CPEs {
+		part, vendor, product, cpeVersion, ok := parseCPE(cpe)
+		if !ok || part != "a" {
+			continue
+		}
+		products = append(products, serviceProduct{vendor, product, defaultIfEmpty(cpeVersion, version)})
+	}
+	if len(products) == 0 && name != "" {
+		if known, ok := serviceProducts[strings.ToLower(name)]; ok {
+			products = append(products, serviceProduct{known[0], known[1], version})
+		} else {
+			products = append(products, serviceProduct{"", strings.ReplaceAll(strings.ToLower(name), " ", "_"), version})
+		}
+	}
+	for _, p := range products {
+		for _, alias := range cpeAliases[[2]string{p.Vendor, p.Product}] {
+			products = append(products, serviceProduct{alias[0], alias[1], p.Version})
+		}
+	}
+	return products
+}
+
+// splitProductVersion splits "Apache httpd 2.4.49 (Debian)" at the first word that starts with a
+// digit.
+func splitProductVersion(value string) (product, version string) {
+	words := strings.Fields(value)
+	for i, word := range words {
+		if isDigit(word[0]) {
+			return strings.Join(words[:i], " "), word
+		}
+	}
+	return strings.Join(words, " "), ""
+}
+
+// InferVulnerabilities matches the products and versions identified on open ports against the
+// offline CVE database, and adds each affected CVE the host does not already have as a
+// version-inferred finding on the port. It returns the number of findings added.
+func InferVulnerabilities(networkMap *model.NetworkMap, lookup CVELookup) int {
+	cache := make(map[string][]model.CVERecord)
+	added := 0
+	for _, host := range networkMap.Hosts {
+		for portID, port := range host.Ports {
+			if port.State == "closed" || port.State == "filtered" {
+				continue
+			}
+			for _, p := range portProducts(port) {
+				if p.Version == "" {
+					continue
+				}
+				key := p.Vendor + "|" + p.Product
+				records, cached := cache[key]
+				if !cached {
+					var err error
+					if records, err = lookup(p.Vendor, p.Product); err != nil {
+						log.Printf("Could not look up CVEs for %s: %v", p.Product, err)
+						return added
+					}
+					cache[key] = records
+				}
+				for _, record := range records {
+					if affectsProduct(record, p) && addInferredFinding(host, portID, port, record) {
+						added++
+					}
+				}
+			}
+		}
+	}
+	if added > 0 {
+		fmt.Printf("  -> %d CVEs inferred from service versions.\n", added)
+	}
+	return added
+}
+
+func affectsProduct(record model.CVERecord, p serviceProduct) bool {
+	for _, a := range record.Affected {
+		if isLanguageEcosystem(a.Ecosystem) {
+			continue
+		}
+		if a.Product == p.Product && (a.Vendor == "" || p.Vendor == "" || a.Vendor == p.Vendor) && affectsVersion(a, p.Version) {
+			return true
+		}
+	}
+	return false
+}
+
+// isLanguageEcosystem reports whether an OSV ecosystem, such as "PyPI" or "Debian:12", is one of
+// programming language packages.
+func isLanguageEcosystem(ecosystem string) bool {
+	name, _, _ := strings.Cut(ecosystem, ":")
+	return languageEcosystems[strings.ToLower(name)]
+}
+
+// addInferredFinding adds the CVE to the port unless the host already has it from any source.
+func addInferredFinding(host *model.Host, portID int, port model.Port, record model.CVERecord) bool {
+	for _, vulns := range host.Findings {
+		for _, existing := range vulns {
+			if existing.CVE == record.ID && existing.PortID == portID {
+				return false
+			}
+		}
+	}
+	category := classifyFinding(stateVersionInferred, record.CVSS, "")
+	host.Findings[category] = append(host.Findings[category], model.Vulnerability{
+		CVE:         record.ID,
+		Description: fmt.Sprintf("%s is in the affected versions. %s", strings.TrimSpace(port.Version), record.Description),
+		State:       stateVersionInferred,
+		Category:    category,
+		PortID:      portID,
+		CVSS:        record.CVSS,
+		Source:      cveDatabaseSource,
+		References:  record.References,
+	})
+	return true
+}
+
+// cvss3BaseScore computes the base score of a CVSS v3 vector such as
+// "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H". It returns 0 for vectors it cannot read.
+func cvss3BaseScore(vector string) float64 {
+	metrics := make(map[string]string)
+	for _, part := range strings.Split(vector, "/") {
+		if key, value, ok := strings.Cut(part, ":"); ok {
+			metrics[key] = value
+		}
+	}
+	weights := map[string]map[string]float64{
+		"AV": {"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2},
+		"AC": {"L": 0.77, "H": 0.44},
+		"UI": {"N": 0.85, "R": 0.62},
+		"C":  {"H": 0.56, "L": 0.22, "N": 0},
+		"I":  {"H": 0.56, "L": 0.22, "N": 0},
+		"A":  {"H": 0.56, "L": 0.22, "N": 0},
+	}
+	value := make(map[string]float64)
+	for metric, options := range weights {
+		w, ok := options[metrics[metric]]
+		if !ok {
+			return 0
+		}
+		value[metric] = w
+	}
+	changed := metrics["S"] == "C"
+	if !changed && metrics["S"] != "U" {
+		return 0
+	}
+	pr := map[string]float64{"N": 0.85, "L": 0.62, "H": 0.27}
+	if changed {
+		pr["L"], pr["H"] = 0.68, 0.5
+	}
+	privileges, ok := pr[metrics["PR"]]
+	if !ok {
+		return 0
+	}
+
+	iss := 1 - (1-value["C"])*(1-value["I"])*(1-value["A"])
+	impact := 6.42 * iss
+	if changed {
+		impact = 7.52*(iss-0.029) - 3.25*math.Pow(iss-0.02, 15)
+	}
+	if impact <= 0 {
+		return 0
+	}
+	exploitability := 8.22 * value["AV"] * value["AC"] * privileges * value["UI"]
+	if changed {
+		return roundUpCVSS(math.Min(1.08*(impact+exploitability), 10))
+	}
+	return roundUpCVSS(math.Min(impact+exploitability, 10))
+}
+
+// roundUpCVSS rounds up to one decimal as defined in the CVSS v3.1 specification.
+func roundUpCVSS(x float64) float64 {
+	i := int(math.Round(x * 100000))
+	if i%10000 == 0 {
+		return float64(i) / 100000
+	}
+	return (math.Floor(float64(i)/10000) + 1) / 10
+}
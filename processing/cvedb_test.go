@@ -0,0 +1,242 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const nvd11Feed = `{"CVE_data_type": "CVE", "CVE_Items": [{
+  "cve": {"CVE_data_meta": {"ID": "CVE-2021-41773"},
+          "references": {"reference_data": [{"url": "https://httpd.apache.org/security/vulnerabilities_24.html"}]},
+          "description": {"description_data": [{"lang": "en", "value": "Path traversal in Apache HTTP Server 2.4.49."}]}},
+  "configurations": {"nodes": [{"operator": "OR", "children": [{"cpe_match": [
+      {"vulnerable": true, "cpe23Uri": "cpe:2.3:a:apache:http_server:2.4.49:*:*:*:*:*:*:*"},
+      {"vulnerable": false, "cpe23Uri": "cpe:2.3:o:fedoraproject:fedora:35:*:*:*:*:*:*:*"}]}]}]},
+  "impact": {"baseMetricV3": {"cvssV3": {"baseScore": 7.5}}, "baseMetricV2": {"cvssV2": {"baseScore": 4.3}}},
+  "publishedDate": "2021-10-05T09:15Z"}]}`
+
+const nvd20Feed = `{"resultsPerPage": 1, "vulnerabilities": [{"cve": {
+  "id": "CVE-2023-38408", "published": "2023-07-20T03:15:10.170",
+  "descriptions": [{"lang": "es", "value": "..."}, {"lang": "en", "value": "The PKCS#11 feature in ssh-agent allows remote code execution."}],
+  "metrics": {"cvssMetricV31": [{"cvssData": {"baseScore": 9.8}}]},
+  "configurations": [{"nodes": [{"cpeMatch": [{"vulnerable": true, "criteria": "cpe:2.3:a:openbsd:openssh:*:*:*:*:*:*:*:*", "versionEndExcluding": "9.3"}]}]}],
+  "references": [{"url": "https://www.openssh.com/txt/release-9.3p2"}]}}]}`
+
+const osvEntry = `{"id": "GHSA-xxxx-yyyy-zzzz", "aliases": ["CVE-2020-1938"], "summary": "AJP Ghostcat file read",
+  "severity": [{"type": "CVSS_V3", "score": "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H"}],
+  "affected": [{"package": {"ecosystem": "Maven", "name": "org.apache.tomcat:tomcat"},
+    "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "9.0.0"}, {"fixed": "9.0.31"}, {"introduced": "8.5.0"}, {"last_affected": "8.5.50"}]},
+               {"type": "GIT", "events": [{"introduced": "0"}, {"fixed": "abc123"}]}],
+    "versions": ["7.0.99"]}]}`
+
+func TestParseCVEFeed(t *testing.T) {
+	records, err := ParseCVEFeed([]byte(nvd11Feed))
+	if err != nil || len(records) != 1 {
+		t.Fatalf("NVD 1.1: got %v (%v)", records, err)
+	}
+	r := records[0]
+	if r.ID != "CVE-2021-41773" || r.CVSS != 7.5 || r.Published.Year() != 2021 || len(r.References) != 1 ||
+		len(r.Affected) != 1 || r.Affected[0] != (model.CVEAffected{Vendor: "apache", Product: "http_server", Version: "2.4.49"}) {
+		t.Errorf("Unexpected NVD 1.1 record %+v", r)
+	}
+
+	records, err = ParseCVEFeed([]byte(nvd20Feed))
+	if err != nil || len(records) != 1 {
+		t.Fatalf("NVD 2.0: got %v (%v)", records, err)
+	}
+	r = records[0]
+	if r.ID != "CVE-2023-38408" || r.CVSS != 9.8 || !strings.HasPrefix(r.Description, "The PKCS#11") || r.Published.IsZero() ||
+		len(r.Affected) != 1 || r.Affected[0] != (model.CVEAffected{Vendor: "openbsd", Product: "openssh", EndExcluding: "9.3"}) {
+		t.Errorf("Unexpected NVD 2.0 record %+v", r)
+	}
+
+	records, err = ParseCVEFeed([]byte(osvEntry))
+	if err != nil || len(records) != 1 {
+		t.Fatalf("OSV: got %v (%v)", records, err)
+	}
+	r = records[0]
+	want := []model.CVEAffected{
+		{Ecosystem: "Maven", Product: "tomcat", Version: "7.0.99"},
+		{Ecosystem: "Maven", Product: "tomcat", StartIncluding: "9.0.0", EndExcluding: "9.0.31"},
+		{Ecosystem: "Maven", Product: "tomcat", StartIncluding: "8.5.0", EndIncluding: "8.5.50"},
+	}
+	if r.ID != "CVE-2020-1938" || r.CVSS != 9.8 || r.Description != "AJP Ghostcat file read" || len(r.Affected) != len(want) {
+		t.Fatalf("Unexpected OSV record %+v", r)
+	}
+	for i := range want {
+		if r.Affected[i] != want[i] {
+			t.Errorf("OSV affected %d = %+v, want %+v", i, r.Affected[i], want[i])
+		}
+	}
+
+	if _, err := ParseCVEFeed([]byte(`{"nmaprun": {}}`)); err == nil {
+		t.Error("Expected an error for a file that is not a feed")
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"2.4.49", "2.4.49", 0},
+		{"2.4.9", "2.4.49", -1},
+		{"2.4.50", "2.4.49", 1},
+		{"7.4p1", "9.3", -1},
+		{"7.4p1", "7.4", 1},
+		{"1.0.0-rc1", "1.0.0", -1},
+		{"1.0.0beta", "1.0.0", -1},
+		{"1.10", "1.9", 1},
+		{"1.2", "1.2.0.1", -1},
+		{"010", "10", 0},
+	} {
+		if got := compareVersions(tc.a, tc.b); got != tc.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestCVSS3BaseScore(t *testing.T) {
+	for vector, want := range map[string]float64{
+		"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H": 9.8,
+		"CVSS:3.1/AV:N/AC:L/PR:L/UI:N/S:C/C:H/I:H/A:H": 9.9,
+		"CVSS:3.0/AV:N/AC:H/PR:N/UI:R/S:U/C:L/I:N/A:N": 3.1,
+		"CVSS:3.1/AV:L/AC:L/PR:L/UI:N/S:U/C:N/I:N/A:N": 0,
+		"CVSS:3.1/AV:N/AC:L":                           0,
+	} {
+		if got := cvss3BaseScore(vector); got != want {
+			t.Errorf("cvss3BaseScore(%q) = %v, want %v", vector, got, want)
+		}
+	}
+}
+
+// TestInferVulnerabilities verifies that CPEs and product strings of open ports are matched
+// against the CVE database by version, and that CVEs a scanner already reported are not repeated.
+func TestInferVulnerabilities(t *testing.T) {
+	var db []model.CVERecord
+	for _, feed := range []string{nvd11Feed, nvd20Feed, osvEntry} {
+		records, err := ParseCVEFeed([]byte(feed))
+		if err != nil {
+			t.Fatal(err)
+		}
+		db = append(db, records...)
+	}
+	lookups := 0
+	lookup := func(vendor, product string) ([]model.CVERecord, error) {
+		lookups++
+		var found []model.CVERecord
+		for _, r := range db {
+			for _, a := range r.Affected {
+				if a.Product == product && (a.Vendor == "" || vendor == "" || a.Vendor == vendor) {
+					found = append(found, r)
+					break
+				}
+			}
+		}
+		return found, nil
+	}
+
+	host := model.NewHost("02:00:00:00:00:0A")
+	host.Ports[22] = model.Port{ID: 22, State: "open", Service: "ssh", Version: "OpenSSH 7.4p1 Debian 10+deb9u7"}
+	host.Ports[80] = model.Port{ID: 80, State: "open", Service: "http", Version: "Apache httpd 2.4.49", CPEs: []string{"cpe:/a:apache:http_server:2.4.49"}}
+	host.Ports[8009] = model.Port{ID: 8009, State: "open", Service: "ajp13", Version: "Apache Tomcat 9.0.31"}
+	host.Ports[8080] = model.Port{ID: 8080, State: "closed", Service: "http", Version: "Apache httpd 2.4.49", CPEs: []string{"cpe:/a:apache:http_server:2.4.49"}}
+	host.Findings[model.PotentialFinding] = []model.Vulnerability{{CVE: "CVE-2021-41773", State: "VULNERABLE", Category: model.PotentialFinding, PortID: 80, Source: "vulners"}}
+	other := model.NewHost("02:00:00:00:00:0B")
+	other.Ports[22] = model.Port{ID: 22, State: "open", Service: "ssh", Version: "OpenSSH 9.3p2"}
+	other.Ports[80] = model.Port{ID: 80, State: "open", Service: "http", Version: "Apache httpd 2.4.49"}
+	other.Ports[8009] = model.Port{ID: 8009, State: "open", Service: "ajp13", Version: "Apache Tomcat 8.5.50"}
+	networkMap := model.NewNetworkMap()
+	networkMap.Hosts[host.MACAddress], networkMap.Hosts[other.MACAddress] = host, other
+
+	if added := InferVulnerabilities(networkMap, lookup); added != 2 {
+		t.Errorf("Expected 2 inferred CVEs, got %d", added)
+	}
+	if lookups != 3 {
+		t.Errorf("Expected one lookup per product, got %d", lookups)
+	}
+	if vulns := host.Findings[model.CriticalFinding]; len(vulns) != 1 || vulns[0].CVE != "CVE-2023-38408" || vulns[0].PortID != 22 ||
+		vulns[0].State != stateVersionInferred || vulns[0].Source != cveDatabaseSource || vulns[0].CVSS != 9.8 ||
+		!strings.HasPrefix(vulns[0].Description, "OpenSSH 7.4p1 Debian 10+deb9u7 is in the affected versions.") {
+		t.Errorf("Unexpected findings %+v", host.Findings)
+	}
+	if len(host.Findings[model.PotentialFinding]) != 1 {
+		t.Errorf("Expected the scanner's CVE not to be repeated, got %+v", host.Findings[model.PotentialFinding])
+	}
+	found := make(map[string]int)
+	for _, vulns := range other.Findings {
+		for _, v := range vulns {
+			found[v.CVE] = v.PortID
+		}
+	}
+	// The Tomcat entry comes from the Maven ecosystem, which is a library and not the server.
+	if len(found) != 1 || found["CVE-2021-41773"] != 80 {
+		t.Errorf("Unexpected findings on the second host %v", found)
+	}
+}
+
+func TestInferVulnerabilitiesAliasesAndEcosystems(t *testing.T) {
+	db := []model.CVERecord{
+		{ID: "CVE-2021-23017", CVSS: 7.7, Affected: []model.CVEAffected{{Vendor: "f5", Product: "nginx", StartIncluding: "0.6.18", EndExcluding: "1.21.0"}}},
+		{ID: "CVE-2013-2028", CVSS: 7.5, Affected: []model.CVEAffected{{Vendor: "nginx", Product: "nginx", StartIncluding: "1.3.9", EndIncluding: "1.4.0"}}},
+		{ID: "CVE-2023-28858", CVSS: 3.7, Affected: []model.CVEAffected{{Ecosystem: "PyPI", Product: "redis", EndExcluding: "4.5.4"}}},
+		{ID: "CVE-2023-28856", CVSS: 5.5, Affected: []model.CVEAffected{{Ecosystem: "Debian:12", Product: "redis", EndExcluding: "7.0.11"}}},
+	}
+	lookup := func(vendor, product string) ([]model.CVERecord, error) {
+		var found []model.CVERecord
+		for _, r := range db {
+			for _, a := range r.Affected {
+				if a.Product == product && (a.Vendor == "" || vendor == "" || a.Vendor == vendor) {
+					found = append(found, r)
+					break
+				}
+			}
+		}
+		return found, nil
+	}
+
+	host := model.NewHost("02:00:00:00:00:0C")
+	host.Ports[80] = model.Port{ID: 80, State: "open", Service: "http", Version: "nginx 1.4.0", CPEs: []string{"cpe:/a:igor_sysoev:nginx:1.4.0"}}
+	host.Ports[6379] = model.Port{ID: 6379, State: "open", Service: "redis", Version: "Redis key-value store 4.0.9"}
+	networkMap := model.NewNetworkMap()
+	networkMap.Hosts[host.MACAddress] = host
+
+	InferVulnerabilities(networkMap, lookup)
+	found := make(map[string]int)
+	for _, vulns := range host.Findings {
+		for _, v := range vulns {
+			found[v.CVE] = v.PortID
+		}
+	}
+	if len(found) != 3 || found["CVE-2021-23017"] != 80 || found["CVE-2013-2028"] != 80 || found["CVE-2023-28856"] != 6379 {
+		t.Errorf("Expected both nginx vendors and only the Debian redis entry, got %v", found)
+	}
+}
+
+func TestImportCVEFeeds(t *testing.T) {
+	dir := t.TempDir()
+	f, err := os.Create(filepath.Join(dir, "nvdcve-1.1-2021.json.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	gz.Write([]byte(nvd11Feed))
+	gz.Close()
+	f.Close()
+	sub := filepath.Join(dir, "osv")
+	os.Mkdir(sub, 0755)
+	os.WriteFile(filepath.Join(sub, "GHSA-xxxx-yyyy-zzzz.json"), []byte(osvEntry), 0644)
+	os.WriteFile(filepath.Join(dir, "README.txt"), []byte("feeds downloaded 2024-05-01"), 0644)
+
+	records, err := ImportCVEFeeds(dir)
+	if err != nil || len(records) != 2 || records[0].ID != "CVE-2020-1938" || records[1].ID != "CVE-2021-41773" {
+		t.Errorf("Unexpected records %+v (%v)", records, err)
+	}
+	if _, err := ImportCVEFeeds(filepath.Join(dir, "README.txt")); err == nil {
+		t.Error("Expected an error when no feed was found")
+	}
+}
@@ -0,0 +1,95 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"sort"
+	"strings"
+	"time"
+)
+
+const broadcastMAC = "FF:FF:FF:FF:FF:FF"
+
+// summarizeDeauths groups the deauthentication and disassociation frames by access point and
+// client, and records for each pair whether the client reconnected afterwards and which
+// handshake was captured for it. Broadcast deauths are matched against every client of the AP.
+// The pairs replace any built by an earlier call.
+func summarizeDeauths(summary *model.PcapSummary) {
+	pairs := make(map[[2]string]*model.DeauthPair)
+	for _, event := range summary.DeauthEvents {
+		client := event.Destination
+		if event.Destination == event.BSSID {
+			client = event.Source
+		}
+		key := [2]string{event.BSSID, client}
+		pair, ok := pairs[key]
+		if !ok {
+			pair = &model.DeauthPair{APMAC: event.BSSID, ClientMAC: client, Reasons: make(map[int]int), PcapFile: event.PcapFile}
+			if ap, ok := summary.AccessPoints[event.BSSID]; ok {
+				pair.SSID = ap.SSID
+			}
+			pairs[key] = pair
+		}
+		if event.Subtype == "Disassociation" {
+			pair.Disassocs++
+		} else {
+			pair.Deauths++
+		}
+		pair.Reasons[event.Reason]++
+		seen(&pair.FirstSeen, &pair.LastSeen, event.Timestamp)
+	}
+
+	fullHandshakes := make(map[[2]string]bool)
+	for _, hs := range summary.CapturedHandshakes {
+		fullHandshakes[[2]string{hs.APMAC, hs.ClientMAC}] = true
+	}
+
+	summary.DeauthPairs = summary.DeauthPairs[:0]
+	for _, pair := range pairs {
+		matches := func(ap, client string) bool {
+			return ap == pair.APMAC && (pair.ClientMAC == broadcastMAC || client == pair.ClientMAC)
+		}
+
+		// A client is back once it sends a (re)association request or takes part in a
+		// 4-way handshake after the last deauth; frames in between a burst don't count.
+		var reconnected time.Time
+		after := func(ts time.Time) {
+			if ts.After(pair.LastSeen) && (reconnected.IsZero() || ts.Before(reconnected)) {
+				reconnected = ts
+			}
+		}
+		for mac, client := range summary.WirelessClients {
+			if matches(client.AssociatedBSSID, mac) && !client.LastAssociation.IsZero() {
+				after(client.LastAssociation)
+			}
+		}
+		eapolSeen := false
+		for sessionKey, packets := range summary.EapolTracker {
+			macs := strings.Split(strings.ToUpper(sessionKey), "-")
+			if len(macs) != 2 || !(matches(macs[0], macs[1]) || matches(macs[1], macs[0])) {
+				continue
+			}
+			eapolSeen = true
+			for _, pkt := range packets {
+				after(pkt.Metadata().Timestamp)
+			}
+		}
+		pair.ReconnectedAt = reconnected
+
+		for key := range fullHandshakes {
+			if matches(key[0], key[1]) {
+				pair.HandshakeState = "Full"
+			}
+		}
+		if pair.HandshakeState == "" && eapolSeen {
+			pair.HandshakeState = "Partial"
+		}
+		summary.DeauthPairs = append(summary.DeauthPairs, *pair)
+	}
+	sort.Slice(summary.DeauthPairs, func(i, j int) bool {
+		a, b := summary.DeauthPairs[i], summary.DeauthPairs[j]
+		if a.APMAC != b.APMAC {
+			return a.APMAC < b.APMAC
+		}
+		return a.ClientMAC < b.ClientMAC
+	})
+}
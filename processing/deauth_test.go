@@ -0,0 +1,87 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+)
+
+// TestDeauthAnalytics verifies that deauth and disassoc frames are recorded with their reason
+// codes and grouped per AP and client, and that reconnects and handshakes are correlated.
+func TestDeauthAnalytics(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	otherClient := []byte{0x02, 0xaa, 0xbb, 0xcc, 0xdd, 0x02}
+
+	deauth := append(dot11Header(0xc0, 0x00, wifiTestClient, wifiTestBSSID, wifiTestBSSID), 7, 0)
+	broadcast := append(dot11Header(0xc0, 0x00, wifiBroadcast, wifiTestBSSID, wifiTestBSSID), 7, 0)
+	disassoc := append(dot11Header(0xa0, 0x00, wifiTestBSSID, otherClient, wifiTestBSSID), 8, 0) // Sent by the client
+	assoc := append(dot11Header(0x00, 0x00, wifiTestBSSID, wifiTestClient, wifiTestBSSID), 0x11, 0x00, 0x0a, 0x00)
+
+	networkMap, summary := model.NewNetworkMap(), model.NewPcapSummary()
+	for i, frame := range [][]byte{deauth, deauth, broadcast, disassoc, assoc} {
+		ProcessPacket(wifiTestPacket(t, radiotapFrame(frame, 2412, -50), start.Add(time.Duration(i)*time.Second)), networkMap, summary, "deauth.pcap")
+	}
+	if len(summary.DeauthEvents) != 4 {
+		t.Fatalf("Expected 4 deauth/disassoc events, got %+v", summary.DeauthEvents)
+	}
+
+	// The other client completed a handshake after its disassociation.
+	eapol := gopacket.NewPacket(nil, gopacket.DecodePayload, gopacket.Default)
+	eapol.Metadata().Timestamp = start.Add(10 * time.Second)
+	summary.EapolTracker["00:11:22:33:44:55-02:aa:bb:cc:dd:02"] = []gopacket.Packet{eapol}
+	summary.CapturedHandshakes = append(summary.CapturedHandshakes, model.Handshake{APMAC: "00:11:22:33:44:55", ClientMAC: "02:AA:BB:CC:DD:02", HandshakeState: "Full"})
+	summarizeDeauths(summary)
+
+	pairs := make(map[string]model.DeauthPair)
+	for _, p := range summary.DeauthPairs {
+		pairs[p.ClientMAC] = p
+	}
+	if len(pairs) != 3 {
+		t.Fatalf("Expected three AP/client pairs, got %+v", summary.DeauthPairs)
+	}
+
+	direct := pairs["02:AA:BB:CC:DD:01"]
+	if direct.APMAC != "00:11:22:33:44:55" || direct.Deauths != 2 || direct.Reasons[7] != 2 || direct.PcapFile != "deauth.pcap" {
+		t.Errorf("Unexpected direct deauth pair: %+v", direct)
+	}
+	if !direct.FirstSeen.Equal(start) || !direct.LastSeen.Equal(start.Add(time.Second)) || !direct.ReconnectedAt.Equal(start.Add(4*time.Second)) || direct.HandshakeState != "" {
+		t.Errorf("Expected a reconnect without a handshake, got %+v", direct)
+	}
+	if got := direct.ReasonSummary(); got != "7 Class 3 frame from nonassociated station (x2)" {
+		t.Errorf("Unexpected reason summary %q", got)
+	}
+
+	disassocPair := pairs["02:AA:BB:CC:DD:02"]
+	if disassocPair.Disassocs != 1 || disassocPair.Reasons[8] != 1 || disassocPair.HandshakeState != "Full" || !disassocPair.ReconnectedAt.Equal(start.Add(10*time.Second)) {
+		t.Errorf("Expected the disassociated client's handshake to be correlated, got %+v", disassocPair)
+	}
+
+	if b := pairs["FF:FF:FF:FF:FF:FF"]; !b.IsBroadcast() || b.HandshakeState != "Full" || !b.ReconnectedAt.Equal(start.Add(4*time.Second)) {
+		t.Errorf("Expected the broadcast deauth to match any client of the AP, got %+v", b)
+	}
+}
+
+// TestDeauthReconnectAfterLastDeauth verifies that a handshake between two deauths of a burst is
+// not taken as the client reconnecting.
+func TestDeauthReconnectAfterLastDeauth(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	summary := model.NewPcapSummary()
+	for _, offset := range []time.Duration{0, 5 * time.Second} {
+		summary.DeauthEvents = append(summary.DeauthEvents, model.DeauthEvent{BSSID: "00:11:22:33:44:55", Source: "00:11:22:33:44:55",
+			Destination: "02:AA:BB:CC:DD:01", Subtype: "Deauthentication", Reason: 7, Timestamp: start.Add(offset)})
+	}
+	var eapol []gopacket.Packet
+	for _, offset := range []time.Duration{2 * time.Second, 8 * time.Second} {
+		p := gopacket.NewPacket(nil, gopacket.DecodePayload, gopacket.Default)
+		p.Metadata().Timestamp = start.Add(offset)
+		eapol = append(eapol, p)
+	}
+	summary.EapolTracker["00:11:22:33:44:55-02:aa:bb:cc:dd:01"] = eapol
+	summarizeDeauths(summary)
+
+	if len(summary.DeauthPairs) != 1 || !summary.DeauthPairs[0].ReconnectedAt.Equal(start.Add(8*time.Second)) {
+		t.Errorf("Expected the reconnect after the last deauth, got %+v", summary.DeauthPairs)
+	}
+}
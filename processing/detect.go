@@ -0,0 +1,143 @@
+package processing
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+// FileType identifies the format of a data file, as detected from its content.
+type FileType string
+
+const (
+	FileTypeUnknown     FileType = ""
+	FileTypeNmapXML     FileType = "nmap-xml"
+	FileTypeMasscanXML  FileType = "masscan-xml"
+	FileTypeMasscanJSON FileType = "masscan-json"
+	FileTypeMasscanList FileType = "masscan-list"
+	FileTypeRustScan    FileType = "rustscan"
+	FileTypeNaabuJSON   FileType = "naabu-json"
+	FileTypeNessus      FileType = "nessus"
+	FileTypeOpenVAS     FileType = "openvas"
+	FileTypeZeekTSV     FileType = "zeek-tsv"
+	FileTypeZeekJSON    FileType = "zeek-json"
+	FileTypeSuricataEVE FileType = "suricata-eve"
+	FileTypePcap        FileType = "pcap"
+)
+
+// sniffSize is the number of bytes read from the start of a file to detect its type.
+const sniffSize = 8192
+
+var (
+	pcapMagics = [][]byte{
+		{0xd4, 0xc3, 0xb2, 0xa1}, {0xa1, 0xb2, 0xc3, 0xd4}, // Microsecond resolution
+		{0x4d, 0x3c, 0xb2, 0xa1}, {0xa1, 0xb2, 0x3c, 0x4d}, // Nanosecond resolution
+		{0x0a, 0x0d, 0x0d, 0x0a}, // pcapng section header block
+	}
+	masscanListRegex = regexp.MustCompile(`(?m)^(open|closed) (tcp|udp|sctp) \d+ \S+ \d+`)
+	rustScanRegex    = regexp.MustCompile(`(?m)^(\S+ -> \[[\d,\s]*\]|Open \S+:\d+)\s*$`)
+)
+
+// DetectFileType reads the beginning of a file and identifies its format by content rather
+// than by extension. Compressed files and archive members are looked at after decompression.
+// Unrecognised files return FileTypeUnknown without an error.
+func DetectFileType(path string) (FileType, error) {
+	f, err := OpenDataFile(path)
+	if err != nil {
+		return FileTypeUnknown, err
+	}
+	defer f.Close()
+
+	head := make([]byte, sniffSize)
+	n, err := io.ReadFull(bufio.NewReader(f), head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return FileTypeUnknown, fmt.Errorf("could not read %s: %w", path, err)
+	}
+	return detectContentType(head[:n]), nil
+}
+
+// detectContentType identifies a data format from the first bytes of a file.
+func detectContentType(head []byte) FileType {
+	for _, magic := range pcapMagics {
+		if bytes.HasPrefix(head, magic) {
+			return FileTypePcap
+		}
+	}
+
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(head, []byte("\xef\xbb\xbf")))
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		if bytes.Contains(trimmed, []byte("<NessusClientData_v2")) {
+			return FileTypeNessus
+		}
+		if isOpenVASReport(trimmed) {
+			return FileTypeOpenVAS
+		}
+		if !bytes.Contains(trimmed, []byte("<nmaprun")) {
+			return FileTypeUnknown
+		}
+		if bytes.Contains(trimmed, []byte(`scanner="masscan"`)) {
+			return FileTypeMasscanXML
+		}
+		return FileTypeNmapXML
+	case bytes.HasPrefix(trimmed, []byte("#separator")):
+		return FileTypeZeekTSV
+	case bytes.HasPrefix(trimmed, []byte("[")) || bytes.HasPrefix(trimmed, []byte("{")):
+		firstLine, _, _ := bytes.Cut(trimmed, []byte("\n"))
+		if bytes.Contains(firstLine, []byte(`"event_type"`)) {
+			return FileTypeSuricataEVE
+		}
+		if bytes.Contains(firstLine, []byte(`"ts"`)) && (bytes.Contains(firstLine, []byte(`"uid"`)) || bytes.Contains(firstLine, []byte(`"uids"`))) {
+			return FileTypeZeekJSON
+		}
+		if bytes.Contains(trimmed, []byte(`"ports"`)) {
+			return FileTypeMasscanJSON
+		}
+		if bytes.Contains(trimmed, []byte(`"port"`)) {
+			return FileTypeNaabuJSON
+		}
+	case bytes.HasPrefix(trimmed, []byte("#masscan")) || masscanListRegex.Match(trimmed):
+		return FileTypeMasscanList
+	case rustScanRegex.Match(trimmed):
+		return FileTypeRustScan
+	}
+	return FileTypeUnknown
+}
+
+// isOpenVASReport recognises GVM XML reports, both exported report files and raw GMP
+// get_reports responses.
+func isOpenVASReport(head []byte) bool {
+	if bytes.Contains(head, []byte("<get_reports_response")) {
+		return true
+	}
+	if !bytes.Contains(head, []byte("<report")) {
+		return false
+	}
+	for _, marker := range []string{"format_id=", "<nvt", "<results", "<scan_run_status>"} {
+		if bytes.Contains(head, []byte(marker)) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsVulnScan reports whether the file type is handled by MergeFromVulnScanFile.
+func (t FileType) IsVulnScan() bool {
+	return t == FileTypeNessus || t == FileTypeOpenVAS
+}
+
+// IsNetworkLog reports whether the file type is handled by ProcessNetworkLogFile.
+func (t FileType) IsNetworkLog() bool {
+	return t == FileTypeZeekTSV || t == FileTypeZeekJSON || t == FileTypeSuricataEVE
+}
+
+// IsPortScan reports whether the file type is handled by MergeFromPortScanFile.
+func (t FileType) IsPortScan() bool {
+	switch t {
+	case FileTypeMasscanJSON, FileTypeMasscanList, FileTypeRustScan, FileTypeNaabuJSON:
+		return true
+	}
+	return false
+}
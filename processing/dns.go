@@ -0,0 +1,383 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/gopacket/layers"
+)
+
+// Thresholds for the DNS tunnelling checks.
+const (
+	encodedLabelLength   = 24          // A label at least this long ...
+	encodedLabelEntropy  = 3.5         // ... whose characters carry at least this many bits looks encoded
+	encodedNameCount     = 10          // Distinct encoded names under one domain that indicate tunnelling
+	txtQueryCount        = 50          // TXT queries under one domain that indicate tunnelling
+	nxdomainBurstCount   = 20          // NXDOMAIN answers to one client ...
+	nxdomainBurstWindow  = time.Minute // ... within this window indicate a DGA or tunnel probing
+	maxEncodedNames      = 1000        // Per domain, to bound memory on long captures
+	maxNXDomainTimes     = 10000       // Per client
+	maxPendingDNSQueries = 10000
+	dnsSource            = "DNS analysis"
+)
+
+// dohHostnames are public DNS over HTTPS endpoints, recognised by the SNI of connections to them.
+var dohHostnames = map[string]bool{
+	"dns.google": true, "dns.google.com": true, "dns64.dns.google": true,
+	"cloudflare-dns.com": true, "mozilla.cloudflare-dns.com": true, "chrome.cloudflare-dns.com": true,
+	"one.one.one.one": true, "1dot1dot1dot1.cloudflare-dns.com": true, "security.cloudflare-dns.com": true, "family.cloudflare-dns.com": true,
+	"dns.quad9.net": true, "dns9.quad9.net": true, "dns10.quad9.net": true, "dns11.quad9.net": true,
+	"doh.opendns.com": true, "doh.familyshield.opendns.com": true, "dns.nextdns.io": true, "doh.cleanbrowsing.org": true,
+	"dns.adguard.com": true, "dns.adguard-dns.com": true, "doh.mullvad.net": true, "dns.mullvad.net": true,
+	"doh.dns.sb": true, "dns.controld.com": true, "freedns.controld.com": true,
+}
+
+// dohAddresses are public resolvers that also serve DNS over HTTPS, for connections without SNI.
+var dohAddresses = map[string]bool{
+	"1.1.1.1": true, "1.0.0.1": true, "8.8.8.8": true, "8.8.4.4": true, "9.9.9.9": true, "149.112.112.112": true,
+	"2606:4700:4700::1111": true, "2606:4700:4700::1001": true, "2001:4860:4860::8888": true, "2001:4860:4860::8844": true, "2620:fe::fe": true,
+}
+
+// processDNS records a DNS message between a local client and its resolver: the names looked up,
+// the answers and which resolver gave them, the resolver's use and response time, and the
+// activity the tunnelling checks in AnalyzeDNS look at. Queries to multicast addresses (mDNS)
+// only add lookups.
+func processDNS(dns *layers.DNS, client *model.Host, clientIP, resolverIP string, networkMap *model.NetworkMap, summary *model.PcapSummary, ts time.Time) {
+	for _, q := range dns.Questions {
+		client.DNSLookups[string(q.Name)] = true
+	}
+	if ip := net.ParseIP(resolverIP); ip == nil || ip.IsMulticast() {
+		return
+	}
+
+	resolver := dnsResolver(client, "DNS", resolverIP)
+	seen(&resolver.FirstSeen, &resolver.LastSeen, ts)
+	queryKey := fmt.Sprintf("%s>%s#%d", clientIP, resolverIP, dns.ID)
+	if !dns.QR {
+		resolver.Queries++
+		for _, q := range dns.Questions {
+			recordDNSQuestion(summary, clientIP, string(q.Name), q.Type)
+		}
+		if len(summary.DNSQueries) < maxPendingDNSQueries {
+			summary.DNSQueries[queryKey] = ts
+		}
+		return
+	}
+
+	resolver.Responses++
+	if sent, ok := summary.DNSQueries[queryKey]; ok {
+		delete(summary.DNSQueries, queryKey)
+		if !sent.IsZero() && !ts.Before(sent) {
+			resolver.TimedResponses++
+			ms := float64(ts.Sub(sent).Microseconds()) / 1000
+			resolver.AvgResponseMs += (ms - resolver.AvgResponseMs) / float64(resolver.TimedResponses)
+		}
+	}
+	if dns.ResponseCode == layers.DNSResponseCodeNXDomain {
+		resolver.NXDomain++
+		if activity := dnsActivity(summary, clientIP); len(activity.NXDomain) < maxNXDomainTimes {
+			activity.NXDomain = append(activity.NXDomain, ts)
+		}
+		return
+	}
+
+	for _, rr := range dns.Answers {
+		value := dnsRecordData(rr)
+		if value == "" {
+			continue
+		}
+		answer := &model.DNSAnswer{Name: string(rr.Name), Type: rr.Type.String(), Answer: value}
+		if existing, ok := client.DNSAnswers[answer.Key()]; ok {
+			answer = existing
+		} else {
+			client.DNSAnswers[answer.Key()] = answer
+		}
+		answer.TTL, answer.ResolverIP = rr.TTL, resolverIP
+		answer.Count++
+		seen(&answer.FirstSeen, &answer.LastSeen, ts)
+
+		// Local servers are named after the names that resolve to them.
+		if (rr.Type == layers.DNSTypeA || rr.Type == layers.DNSTypeAAAA) && isPrivateIP(rr.IP) {
+			if named := findHostByIP(networkMap, value); named != nil {
+				named.Hostnames[answer.Name] = "DNS"
+			}
+		}
+	}
+}
+
+// dnsRecordData returns the data of an answer record as text, or "" for record types that are
+// not kept. TXT records are left out: in tunnels they carry the data itself.
+func dnsRecordData(rr layers.DNSResourceRecord) string {
+	switch rr.Type {
+	case layers.DNSTypeA, layers.DNSTypeAAAA:
+		if rr.IP != nil {
+			return rr.IP.String()
+		}
+	case layers.DNSTypeCNAME:
+		return string(rr.CNAME)
+	case layers.DNSTypePTR:
+		return string(rr.PTR)
+	case layers.DNSTypeNS:
+		return string(rr.NS)
+	case layers.DNSTypeMX:
+		return fmt.Sprintf("%d %s", rr.MX.Preference, rr.MX.Name)
+	case layers.DNSTypeSRV:
+		return fmt.Sprintf("%d %d %d %s", rr.SRV.Priority, rr.SRV.Weight, rr.SRV.Port, rr.SRV.Name)
+	}
+	return ""
+}
+
+// recordDoTConnection counts a connection attempt from a local client to port 853.
+func recordDoTConnection(tcp *layers.TCP, client *model.Host, resolverIP string, ts time.Time) {
+	if tcp.DstPort != 853 || !tcp.SYN || tcp.ACK {
+		return
+	}
+	resolver := dnsResolver(client, "DoT", resolverIP)
+	resolver.Connections++
+	seen(&resolver.FirstSeen, &resolver.LastSeen, ts)
+}
+
+// dnsResolver returns a host's record of a resolver, creating it if needed.
+func dnsResolver(host *model.Host, protocol, ip string) *model.DNSResolver {
+	if host.DNSResolvers == nil {
+		host.DNSResolvers = make(map[string]*model.DNSResolver)
+	}
+	resolver := &model.DNSResolver{IP: ip, Protocol: protocol}
+	if existing, ok := host.DNSResolvers[resolver.Key()]; ok {
+		return existing
+	}
+	host.DNSResolvers[resolver.Key()] = resolver
+	return resolver
+}
+
+func dnsActivity(summary *model.PcapSummary, clientIP string) *model.DNSActivity {
+	if summary.DNSActivity == nil {
+		summary.DNSActivity = make(map[string]*model.DNSActivity)
+	}
+	activity, ok := summary.DNSActivity[clientIP]
+	if !ok {
+		activity = &model.DNSActivity{Domains: make(map[string]*model.DNSDomainActivity)}
+		summary.DNSActivity[clientIP] = activity
+	}
+	return activity
+}
+
+// recordDNSQuestion counts a query under its registered domain, noting TXT queries and names
+// that look like encoded data.
+func recordDNSQuestion(summary *model.PcapSummary, clientIP, name string, qtype layers.DNSType) {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	domain := registeredDomain(name)
+	if domain == "" {
+		return
+	}
+	activity := dnsActivity(summary, clientIP)
+	d, ok := activity.Domains[domain]
+	if !ok {
+		d = &model.DNSDomainActivity{EncodedNames: make(map[string]bool)}
+		activity.Domains[domain] = d
+	}
+	d.Queries++
+	if qtype == layers.DNSTypeTXT {
+		d.TXT++
+	}
+	if len(d.EncodedNames) < maxEncodedNames && looksEncoded(strings.TrimSuffix(name, domain)) {
+		d.EncodedNames[name] = true
+	}
+}
+
+// registeredDomain approximates the domain a name was registered under: its last two labels, or
+// three under second-level domains such as co.uk.
+func registeredDomain(name string) string {
+	labels := strings.Split(name, ".")
+	if len(labels) < 2 {
+		return ""
+	}
+	n := 2
+	if last, second := labels[len(labels)-1], labels[len(labels)-2]; len(labels) > 2 && len(last) == 2 && len(second) <= 3 {
+		switch second {
+		case "co", "com", "net", "org", "gov", "ac", "edu", "ne", "or", "go":
+			n = 3
+		}
+	}
+	return strings.Join(labels[len(labels)-n:], ".")
+}
+
+// looksEncoded reports whether the subdomain part of a name has a label long and random enough
+// to be carrying encoded data.
+func looksEncoded(subdomain string) bool {
+	for _, label := range strings.Split(subdomain, ".") {
+		if len(label) >= encodedLabelLength && shannonEntropy(label) >= encodedLabelEntropy {
+			return true
+		}
+	}
+	return false
+}
+
+// shannonEntropy returns the entropy of s in bits per character.
+func shannonEntropy(s string) float64 {
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+	var entropy float64
+	n := float64(len(s))
+	for _, c := range counts {
+		p := float64(c) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// AnalyzeDNS runs the DNS checks that need the whole capture set: connections to public DNS over
+// HTTPS endpoints and TLS sessions on port 853 are recorded as encrypted resolvers of the client,
+// and each client's queries are checked for tunnelling (many long high-entropy names or TXT
+// queries under one domain) and bursts of NXDOMAIN answers. Encrypted resolvers are reported as
+// informational findings, tunnelling indicators as potential ones.
+func AnalyzeDNS(networkMap *model.NetworkMap, summary *model.PcapSummary) {
+	for _, host := range networkMap.Hosts {
+		detectEncryptedDNS(host)
+	}
+
+	clients := make([]string, 0, len(summary.DNSActivity))
+	for clientIP := range summary.DNSActivity {
+		clients = append(clients, clientIP)
+	}
+	sort.Strings(clients)
+	for _, clientIP := range clients {
+		if host := findHostByIP(networkMap, clientIP); host != nil {
+			checkDNSTunnelling(host, summary.DNSActivity[clientIP])
+		}
+	}
+}
+
+// detectEncryptedDNS records a host's DNS over HTTPS and DNS over TLS resolvers from its TLS
+// sessions and HTTP requests, and reports them.
+func detectEncryptedDNS(host *model.Host) {
+	for _, session := range host.TLSSessions {
+		if !host.IPv4Addresses[session.ClientIP] {
+			continue
+		}
+		sni := strings.ToLower(session.SNI)
+		var resolver *model.DNSResolver
+		switch {
+		case session.ServerPort == 853:
+			// Connections were counted from the client's SYNs, unless those were not captured.
+			resolver = dnsResolver(host, "DoT", session.ServerIP)
+			if resolver.Connections == 0 {
+				resolver.Connections = session.Connections
+			}
+		case session.ServerPort == 443 && (dohHostnames[sni] || (dohAddresses[session.ServerIP] && (sni == "" || sni == session.ServerIP))):
+			resolver = dnsResolver(host, "DoH", session.ServerIP)
+			resolver.Connections += session.Connections
+		default:
+			continue
+		}
+		if resolver.Hostname == "" {
+			resolver.Hostname = session.SNI
+		}
+		seen(&resolver.FirstSeen, &resolver.LastSeen, session.FirstSeen)
+		seen(&resolver.FirstSeen, &resolver.LastSeen, session.LastSeen)
+	}
+	for _, t := range host.HTTPRequests {
+		if !host.IPv4Addresses[t.ClientIP] || (!strings.HasPrefix(t.URI, "/dns-query") && !strings.HasPrefix(t.ContentType, "application/dns-message")) {
+			continue
+		}
+		resolver := dnsResolver(host, "DoH", t.ServerIP)
+		resolver.Queries++
+		if resolver.Hostname == "" {
+			resolver.Hostname = t.Host
+		}
+		seen(&resolver.FirstSeen, &resolver.LastSeen, t.Timestamp)
+	}
+
+	var encrypted []string
+	for _, resolver := range host.DNSResolvers {
+		if resolver.Protocol == "DNS" {
+			continue
+		}
+		name := resolver.IP
+		if resolver.Hostname != "" && resolver.Hostname != resolver.IP {
+			name = resolver.Hostname + " (" + resolver.IP + ")"
+		}
+		encrypted = append(encrypted, resolver.Protocol+" to "+name)
+	}
+	if len(encrypted) > 0 {
+		sort.Strings(encrypted)
+		addFinding(host, model.Vulnerability{
+			CVE:         "DNS-ENCRYPTED-RESOLVER",
+			Description: fmt.Sprintf("Host resolves names over encrypted DNS (%s), bypassing the network's DNS logging and filtering.", strings.Join(encrypted, ", ")),
+			Category:    model.InformationalFinding,
+			State:       stateDetected,
+			Source:      dnsSource,
+		})
+	}
+}
+
+// checkDNSTunnelling reports a client's DNS activity that looks like tunnelling or a DGA.
+func checkDNSTunnelling(host *model.Host, activity *model.DNSActivity) {
+	var encoded, txt []string
+	for domain, d := range activity.Domains {
+		if len(d.EncodedNames) >= encodedNameCount {
+			encoded = append(encoded, fmt.Sprintf("%s (%d names, e.g. %s)", domain, len(d.EncodedNames), exampleName(d.EncodedNames)))
+		}
+		if d.TXT >= txtQueryCount {
+			txt = append(txt, fmt.Sprintf("%s (%d of %d queries)", domain, d.TXT, d.Queries))
+		}
+	}
+	if len(encoded) > 0 {
+		sort.Strings(encoded)
+		addFinding(host, model.Vulnerability{
+			CVE:         "DNS-TUNNEL-ENCODED-NAMES",
+			Description: fmt.Sprintf("Host queried many long, high-entropy names under %s, consistent with data encoded in DNS queries (tunnelling or exfiltration).", strings.Join(encoded, ", ")),
+			Category:    model.PotentialFinding,
+			State:       stateDetected,
+			Source:      dnsSource,
+		})
+	}
+	if len(txt) > 0 {
+		sort.Strings(txt)
+		addFinding(host, model.Vulnerability{
+			CVE:         "DNS-TUNNEL-TXT",
+			Description: fmt.Sprintf("Host sent a high volume of TXT queries under %s, a common channel for DNS tunnels and command and control.", strings.Join(txt, ", ")),
+			Category:    model.PotentialFinding,
+			State:       stateDetected,
+			Source:      dnsSource,
+		})
+	}
+
+	// The largest number of NXDOMAIN answers within any window of nxdomainBurstWindow.
+	times := append([]time.Time(nil), activity.NXDomain...)
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	burst, start := 0, 0
+	for end := range times {
+		for times[end].Sub(times[start]) > nxdomainBurstWindow {
+			start++
+		}
+		burst = max(burst, end-start+1)
+	}
+	if burst >= nxdomainBurstCount {
+		addFinding(host, model.Vulnerability{
+			CVE:         "DNS-NXDOMAIN-BURST",
+			Description: fmt.Sprintf("Host received %d NXDOMAIN answers within %s, typical of malware trying domains from a domain generation algorithm or of tunnel probing.", burst, nxdomainBurstWindow),
+			Category:    model.PotentialFinding,
+			State:       stateDetected,
+			Source:      dnsSource,
+		})
+	}
+}
+
+// exampleName returns the alphabetically first name of a set, so descriptions are stable.
+func exampleName(names map[string]bool) string {
+	var first string
+	for name := range names {
+		if first == "" || name < first {
+			first = name
+		}
+	}
+	return first
+}
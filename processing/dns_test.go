@@ -0,0 +1,174 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+var dnsLocalResolverMAC = net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+
+// dnsTestPacket builds a DNS message between the test client (10.0.0.5) and a resolver. Remote
+// resolvers are reached through the gateway MAC of the TLS tests.
+func dnsTestPacket(t *testing.T, resolverIP net.IP, msg *layers.DNS, ts time.Time) gopacket.Packet {
+	t.Helper()
+	resolverMAC := tlsServerMAC
+	if isPrivateIP(resolverIP) {
+		resolverMAC = dnsLocalResolverMAC
+	}
+	return testPacket(t, !msg.QR, resolverMAC, resolverIP, &layers.UDP{SrcPort: 53000, DstPort: 53}, msg, ts)
+}
+
+func dnsQuery(id uint16, name string, qtype layers.DNSType) *layers.DNS {
+	return &layers.DNS{ID: id, RD: true, Questions: []layers.DNSQuestion{{Name: []byte(name), Type: qtype, Class: layers.DNSClassIN}}}
+}
+
+func dnsResponse(query *layers.DNS, code layers.DNSResponseCode, answers ...layers.DNSResourceRecord) *layers.DNS {
+	response := *query
+	response.QR, response.RA, response.ResponseCode, response.Answers = true, true, code, answers
+	return &response
+}
+
+// TestDNSIntelligence verifies that DNS answers, resolver use and timing are recorded for the
+// client, that local servers are named from answers, and that encrypted DNS and tunnelling
+// indicators are reported.
+func TestDNSIntelligence(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	networkMap, summary := model.NewNetworkMap(), model.NewPcapSummary()
+	fileServer := model.NewHost("02:00:00:00:00:0A")
+	fileServer.IPv4Addresses["10.0.0.10"] = true
+	networkMap.Hosts[fileServer.MACAddress] = fileServer
+
+	public, local := net.IP{8, 8, 8, 8}, net.IP{10, 0, 0, 1}
+	www := dnsQuery(1, "www.example.com", layers.DNSTypeA)
+	files := dnsQuery(2, "files.corp.example", layers.DNSTypeA)
+	packets := []gopacket.Packet{
+		dnsTestPacket(t, public, www, start),
+		dnsTestPacket(t, public, dnsResponse(www, layers.DNSResponseCodeNoErr,
+			layers.DNSResourceRecord{Name: []byte("www.example.com"), Type: layers.DNSTypeCNAME, Class: layers.DNSClassIN, TTL: 300, CNAME: []byte("edge.cdn.example")},
+			layers.DNSResourceRecord{Name: []byte("edge.cdn.example"), Type: layers.DNSTypeA, Class: layers.DNSClassIN, TTL: 60, IP: net.IP{93, 184, 216, 34}},
+		), start.Add(20*time.Millisecond)),
+		dnsTestPacket(t, local, files, start),
+		dnsTestPacket(t, local, dnsResponse(files, layers.DNSResponseCodeNoErr,
+			layers.DNSResourceRecord{Name: []byte("files.corp.example"), Type: layers.DNSTypeA, Class: layers.DNSClassIN, TTL: 3600, IP: net.IP{10, 0, 0, 10}},
+		), start.Add(2*time.Millisecond)),
+	}
+
+	// Tunnelling: encoded names and TXT queries under one domain, and a burst of NXDOMAIN answers.
+	const base32 = "abcdefghijklmnopqrstuvwxyz234567"
+	seed := uint32(1)
+	for i := 0; i < 60; i++ {
+		label := make([]byte, 40)
+		for j := range label {
+			seed = seed*1664525 + 1013904223
+			label[j] = base32[seed>>27]
+		}
+		name := string(label) + ".t.tunnel.example"
+		packets = append(packets, dnsTestPacket(t, public, dnsQuery(uint16(100+i), name, layers.DNSTypeTXT), start.Add(time.Duration(i)*time.Second)))
+	}
+	for i := 0; i < 25; i++ {
+		query := dnsQuery(uint16(200+i), fmt.Sprintf("qx%dzv.example.net", i), layers.DNSTypeA)
+		packets = append(packets, dnsTestPacket(t, public, dnsResponse(query, layers.DNSResponseCodeNXDomain), start.Add(time.Duration(i)*time.Second)))
+	}
+
+	// DNS over TLS: a connection attempt to 1.1.1.1:853.
+	eth := &layers.Ethernet{SrcMAC: tlsClientMAC, DstMAC: tlsServerMAC, EthernetType: layers.EthernetTypeIPv4}
+	ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolTCP, SrcIP: net.IP{10, 0, 0, 5}, DstIP: net.IP{1, 1, 1, 1}}
+	tcp := &layers.TCP{SrcPort: 45000, DstPort: 853, SYN: true, Window: 65535}
+	tcp.SetNetworkLayerForChecksum(ip)
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}, eth, ip, tcp); err != nil {
+		t.Fatalf("could not build TCP packet: %v", err)
+	}
+	syn := gopacket.NewPacket(buf.Bytes(), layers.LayerTypeEthernet, gopacket.Default)
+	syn.Metadata().Timestamp = start
+	packets = append(packets, syn)
+
+	for _, packet := range packets {
+		ProcessPacket(packet, networkMap, summary, "dns.pcap")
+	}
+	client := networkMap.Hosts["02:00:00:00:00:05"]
+	if client == nil {
+		t.Fatal("Expected the client host to be created")
+	}
+	// DNS over HTTPS: a TLS session to a public endpoint, as processTLS would record it.
+	client.TLSSessions["doh"] = &model.TLSSession{ClientIP: "10.0.0.5", ServerIP: "8.8.4.4", ServerPort: 443, SNI: "dns.google", Connections: 3, FirstSeen: start, LastSeen: start}
+	AnalyzeDNS(networkMap, summary)
+
+	if resolver := networkMap.Hosts[strings.ToUpper(dnsLocalResolverMAC.String())]; resolver == nil || len(resolver.DNSAnswers) != 0 || len(resolver.DNSResolvers) != 0 {
+		t.Errorf("Expected the local resolver's answers to be recorded on the client only, got %+v", resolver)
+	}
+	cname := client.DNSAnswers["www.example.com|CNAME|edge.cdn.example"]
+	a := client.DNSAnswers["edge.cdn.example|A|93.184.216.34"]
+	if cname == nil || a == nil || a.TTL != 60 || a.ResolverIP != "8.8.8.8" || a.Count != 1 || !a.FirstSeen.Equal(start.Add(20*time.Millisecond)) {
+		t.Errorf("Unexpected answers %v", client.DNSAnswers)
+	}
+	if answer := client.DNSAnswers["files.corp.example|A|10.0.0.10"]; answer == nil || answer.ResolverIP != "10.0.0.1" {
+		t.Errorf("Expected the local resolver's answer on the client, got %v", client.DNSAnswers)
+	}
+	if fileServer.Hostnames["files.corp.example"] != "DNS" {
+		t.Errorf("Expected the file server to be named from the answer, got %v", fileServer.Hostnames)
+	}
+	if !client.DNSLookups["www.example.com"] || !client.DNSLookups["files.corp.example"] {
+		t.Errorf("Expected the questions as lookups, got %v", client.DNSLookups)
+	}
+
+	google := client.DNSResolvers["DNS|8.8.8.8"]
+	if google == nil || google.Queries != 61 || google.Responses != 26 || google.NXDomain != 25 || google.TimedResponses != 1 || google.AvgResponseMs != 20 {
+		t.Errorf("Unexpected use of 8.8.8.8: %+v", google)
+	}
+	if r := client.DNSResolvers["DNS|10.0.0.1"]; r == nil || r.Queries != 1 || r.Responses != 1 || r.AvgResponseMs != 2 {
+		t.Errorf("Unexpected use of the local resolver: %+v", r)
+	}
+	if r := client.DNSResolvers["DoT|1.1.1.1"]; r == nil || r.Connections != 1 {
+		t.Errorf("Expected a DoT connection to 1.1.1.1, got %+v", r)
+	}
+	if r := client.DNSResolvers["DoH|8.8.4.4"]; r == nil || r.Connections != 3 || r.Hostname != "dns.google" {
+		t.Errorf("Expected DoH to dns.google, got %+v", r)
+	}
+
+	findings := make(map[string]model.Vulnerability)
+	for _, vulns := range client.Findings {
+		for _, v := range vulns {
+			findings[v.CVE] = v
+		}
+	}
+	for id, category := range map[string]model.FindingCategory{
+		"DNS-ENCRYPTED-RESOLVER":   model.InformationalFinding,
+		"DNS-TUNNEL-ENCODED-NAMES": model.PotentialFinding,
+		"DNS-TUNNEL-TXT":           model.PotentialFinding,
+		"DNS-NXDOMAIN-BURST":       model.PotentialFinding,
+	} {
+		if v, ok := findings[id]; !ok || v.Category != category || v.Source != dnsSource {
+			t.Errorf("Expected a %s %s finding, got %+v", category, id, v)
+		}
+	}
+	if d := findings["DNS-TUNNEL-ENCODED-NAMES"].Description; !strings.Contains(d, "tunnel.example (60 names") {
+		t.Errorf("Unexpected tunnelling description %q", d)
+	}
+	if d := findings["DNS-ENCRYPTED-RESOLVER"].Description; !strings.Contains(d, "DoH to dns.google (8.8.4.4), DoT to 1.1.1.1") {
+		t.Errorf("Unexpected encrypted DNS description %q", d)
+	}
+	if len(fileServer.Findings) != 0 {
+		t.Errorf("Expected no findings on the file server, got %v", fileServer.Findings)
+	}
+}
+
+func TestRegisteredDomain(t *testing.T) {
+	for name, want := range map[string]string{
+		"a.b.example.com": "example.com",
+		"www.bbc.co.uk":   "bbc.co.uk",
+		"example.com":     "example.com",
+		"localhost":       "",
+	} {
+		if got := registeredDomain(name); got != want {
+			t.Errorf("registeredDomain(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"fmt"
+	"strings"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// EAP method types used in WPA-Enterprise and wired 802.1X authentication. gopacket only names
+// the first few (and calls type 4 OTP, which is MD5-Challenge).
+const (
+	eapTypeIdentity = 1
+	eapTypeMD5      = 4
+	eapTypeTLS      = 13
+	eapTypeLEAP     = 17
+	eapTypeTTLS     = 21
+	eapTypePEAP     = 25
+	eapTypeFAST     = 43
+)
+
+var eapTLSMethods = map[layers.EAPType]string{
+	eapTypeTLS: "EAP-TLS", eapTypeTTLS: "EAP-TTLS", eapTypePEAP: "PEAP", eapTypeFAST: "EAP-FAST",
+}
+
+// Flags at the start of EAP-TLS, TTLS, PEAP and FAST messages (RFC 5216).
+const (
+	eapTLSLengthIncluded = 0x80
+	eapTLSMoreFragments  = 0x40
+)
+
+// maxEAPTLSBuffer bounds the reassembled TLS data of one exchange.
+const maxEAPTLSBuffer = 64 * 1024
+
+// processEAP extracts credentials from an EAP exchange: identities, crackable EAP-MD5 and LEAP
+// challenge/responses, the tunnelled method a client uses, and the authentication server's
+// certificate when the TLS handshake is in the clear. Credentials belong to the client and name
+// the authenticator (the AP's BSSID on Wi-Fi) as their endpoint.
+func processEAP(packet gopacket.Packet, eap *layers.EAP, networkMap *model.NetworkMap, summary *model.PcapSummary, pcapFile string) {
+	if eap.Code != layers.EAPCodeRequest && eap.Code != layers.EAPCodeResponse {
+		return
+	}
+	client, authenticator, wireless := eapEndpoints(packet, eap.Code)
+	if client == "" {
+		return
+	}
+	if summary.EAPState == nil {
+		summary.EAPState = make(map[string][]byte)
+	}
+	// gopacket does not trim the type data to the EAP length, so frame padding can trail it.
+	data := eap.TypeData
+	if n := int(eap.Length) - 5; n >= 0 && n < len(data) {
+		data = data[:n]
+	}
+	exchange := client + "|" + authenticator
+	credential := func(credType, value string) {
+		addEAPCredential(networkMap, summary, client, authenticator, wireless, credType, value, pcapFile)
+	}
+
+	switch eap.Type {
+	case eapTypeIdentity:
+		if eap.Code == layers.EAPCodeResponse {
+			if identity := strings.TrimRight(string(data), "\x00"); identity != "" {
+				credential("EAP Identity", identity)
+			}
+		}
+
+	case eapTypeMD5:
+		// Value-Size (16), the challenge or response, then an optional name.
+		if len(data) < 17 || data[0] != 16 {
+			return
+		}
+		key := fmt.Sprintf("%s|md5|%d", exchange, eap.Id)
+		if eap.Code == layers.EAPCodeRequest {
+			summary.EAPState[key] = append([]byte(nil), data[1:17]...)
+			return
+		}
+		if challenge, ok := summary.EAPState[key]; ok {
+			credential("EAP-MD5 (hashcat -m 4800)", fmt.Sprintf("%x:%x:%02x", data[1:17], challenge, eap.Id))
+			delete(summary.EAPState, key)
+		}
+
+	case eapTypeLEAP:
+		// Version, reserved, count, then the 8-byte challenge or 24-byte response and the user name.
+		if len(data) < 3 || len(data) < 3+int(data[2]) {
+			return
+		}
+		value, user := data[3:3+int(data[2])], string(data[3+int(data[2]):])
+		key := exchange + "|leap"
+		switch {
+		case eap.Code == layers.EAPCodeRequest && len(value) == 8:
+			summary.EAPState[key] = append([]byte(nil), value...)
+		case eap.Code == layers.EAPCodeResponse && len(value) == 24:
+			if challenge, ok := summary.EAPState[key]; ok {
+				credential("LEAP (hashcat -m 5500)", fmt.Sprintf("%s::::%x:%x", user, value, challenge))
+				delete(summary.EAPState, key)
+			}
+		}
+
+	case eapTypeTLS, eapTypeTTLS, eapTypePEAP, eapTypeFAST:
+		method := eapTLSMethods[eap.Type]
+		if eap.Code == layers.EAPCodeResponse {
+			// The client answering in a method (rather than NAKing it) means it is the one in use.
+			credential("EAP Method", method)
+			return
+		}
+		if len(data) < 1 {
+			return
+		}
+		flags, body := data[0], data[1:]
+		if flags&eapTLSLengthIncluded != 0 {
+			if len(body) < 4 {
+				return
+			}
+			body = body[4:]
+		}
+		key := exchange + "|tls"
+		if len(summary.EAPState[key])+len(body) <= maxEAPTLSBuffer {
+			summary.EAPState[key] = append(summary.EAPState[key], body...)
+		}
+		if flags&eapTLSMoreFragments != 0 {
+			return
+		}
+		certs := tlsCertificates(summary.EAPState[key])
+		delete(summary.EAPState, key)
+		if len(certs) > 0 {
+			credential("RADIUS Certificate", method+": "+describeCertificate(tlsCertificateInfo(certs[0])))
+		}
+	}
+}
+
+// eapEndpoints returns the supplicant (client) and authenticator (AP or switch port) of an EAP
+// frame, and whether it was sent over 802.11.
+func eapEndpoints(packet gopacket.Packet, code layers.EAPCode) (client, authenticator string, wireless bool) {
+	var src, dst string
+	if dot11, ok := packet.Layer(layers.LayerTypeDot11).(*layers.Dot11); ok {
+		toDS, fromDS := dot11.Flags.ToDS(), dot11.Flags.FromDS()
+		switch {
+		case toDS && !fromDS:
+			return strings.ToUpper(dot11.Address2.String()), strings.ToUpper(dot11.Address1.String()), true
+		case fromDS && !toDS:
+			return strings.ToUpper(dot11.Address1.String()), strings.ToUpper(dot11.Address2.String()), true
+		}
+		src, dst, wireless = dot11.Address2.String(), dot11.Address1.String(), true
+	} else if eth, ok := packet.Layer(layers.LayerTypeEthernet).(*layers.Ethernet); ok {
+		src, dst = eth.SrcMAC.String(), eth.DstMAC.String()
+	} else {
+		return "", "", false
+	}
+	if code == layers.EAPCodeResponse {
+		return strings.ToUpper(src), strings.ToUpper(dst), wireless
+	}
+	return strings.ToUpper(dst), strings.ToUpper(src), wireless
+}
+
+// addEAPCredential records a credential for an EAP client, creating its host if needed. The
+// same credential seen in several exchanges is only recorded once.
+func addEAPCredential(networkMap *model.NetworkMap, summary *model.PcapSummary, client, authenticator string, wireless bool, credType, value, pcapFile string) {
+	host, ok := networkMap.Hosts[client]
+	if !ok {
+		host = model.NewHost(client)
+		host.DiscoveredBy = "Pcap (EAP)"
+		networkMap.Hosts[client] = host
+	}
+	if wireless {
+		if host.Wifi == nil {
+			host.Wifi = &model.WifiInfo{ProbeRequests: make(map[string]bool)}
+		}
+		host.Wifi.DeviceRole = "Client"
+		host.Wifi.AssociatedAP = authenticator
+	}
+
+	for _, existing := range summary.Credentials {
+		if existing.HostMAC == client && existing.Endpoint == authenticator && existing.Type == credType && existing.Value == value {
+			return
+		}
+	}
+	summary.Credentials = append(summary.Credentials, model.Credential{
+		HostMAC:  client,
+		Endpoint: authenticator,
+		Type:     credType,
+		Value:    value,
+		PcapFile: pcapFile,
+	})
+}
@@ -0,0 +1,114 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+// eapFrame builds an 802.11 data frame carrying an EAP packet between the test client and AP.
+func eapFrame(fromClient bool, code, id, eapType byte, typeData ...byte) []byte {
+	frame := dot11Header(0x08, 0x02, wifiTestClient, wifiTestBSSID, wifiTestBSSID)
+	if fromClient {
+		frame = dot11Header(0x08, 0x01, wifiTestBSSID, wifiTestClient, wifiTestBSSID)
+	}
+	eapLen := 5 + len(typeData)
+	frame = append(frame, 0xaa, 0xaa, 0x03, 0, 0, 0, 0x88, 0x8e) // LLC/SNAP: 802.1X
+	frame = append(frame, 1, 0, byte(eapLen>>8), byte(eapLen))   // EAPOL: EAP packet
+	frame = append(frame, code, id, byte(eapLen>>8), byte(eapLen), eapType)
+	return append(frame, typeData...)
+}
+
+func u24(n int) []byte {
+	return []byte{byte(n >> 16), byte(n >> 8), byte(n)}
+}
+
+// TestEAPCredentials verifies that identities, EAP-MD5 and LEAP challenge/responses, tunnelled
+// methods and the RADIUS server certificate are recorded as credentials of the client.
+func TestEAPCredentials(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "radius.corp.example"},
+		NotBefore:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A TLS handshake record holding the Certificate message, split over two EAP fragments.
+	certMsg := append(append([]byte{11}, u24(len(der)+6)...), u24(len(der)+3)...)
+	certMsg = append(append(certMsg, u24(len(der))...), der...)
+	record := append([]byte{22, 3, 3, byte(len(certMsg) >> 8), byte(len(certMsg))}, certMsg...)
+	half := len(record) / 2
+	first := append([]byte{eapTLSLengthIncluded | eapTLSMoreFragments, 0, 0, byte(len(record) >> 8), byte(len(record))}, record[:half]...)
+	second := append([]byte{0}, record[half:]...)
+
+	md5Challenge := []byte("0123456789abcdef")
+	md5Response := []byte("fedcba9876543210")
+	leapChallenge := []byte("8bytes!!")
+	leapResponse := []byte("twenty-four-byte-answer!")
+
+	frames := [][]byte{
+		eapFrame(true, 2, 1, eapTypeIdentity, []byte("alice@corp.example")...),
+		eapFrame(false, 1, 2, eapTypeMD5, append([]byte{16}, md5Challenge...)...),
+		eapFrame(true, 2, 2, eapTypeMD5, append([]byte{16}, md5Response...)...),
+		eapFrame(false, 1, 3, eapTypeLEAP, append([]byte{1, 0, 8}, append(leapChallenge, "alice"...)...)...),
+		eapFrame(true, 2, 3, eapTypeLEAP, append([]byte{1, 0, 24}, append(leapResponse, "alice"...)...)...),
+		eapFrame(false, 1, 4, eapTypePEAP, 0x20), // Start
+		eapFrame(true, 2, 4, eapTypePEAP, 0),
+		eapFrame(false, 1, 5, eapTypePEAP, first...),
+		eapFrame(false, 1, 6, eapTypePEAP, second...),
+		eapFrame(true, 2, 1, eapTypeIdentity, []byte("alice@corp.example")...), // Re-authentication
+	}
+	networkMap, summary := model.NewNetworkMap(), model.NewPcapSummary()
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, frame := range frames {
+		ProcessPacket(wifiTestPacket(t, radiotapFrame(frame, 2412, -50), start.Add(time.Duration(i)*time.Second)), networkMap, summary, "eap.pcap")
+	}
+
+	creds := make(map[string]string)
+	for _, c := range summary.Credentials {
+		if c.HostMAC != "02:AA:BB:CC:DD:01" || c.Endpoint != "00:11:22:33:44:55" || c.PcapFile != "eap.pcap" {
+			t.Errorf("Credential not tied to the client and AP: %+v", c)
+		}
+		if _, dup := creds[c.Type]; dup {
+			t.Errorf("Duplicate %s credential", c.Type)
+		}
+		creds[c.Type] = c.Value
+	}
+	if got := creds["EAP Identity"]; got != "alice@corp.example" {
+		t.Errorf("Unexpected identity %q", got)
+	}
+	if got, want := creds["EAP-MD5 (hashcat -m 4800)"], "66656463626139383736353433323130:30313233343536373839616263646566:02"; got != want {
+		t.Errorf("Unexpected EAP-MD5 hash %q, want %q", got, want)
+	}
+	if got, want := creds["LEAP (hashcat -m 5500)"], "alice::::7477656e74792d666f75722d627974652d616e7377657221:3862797465732121"; got != want {
+		t.Errorf("Unexpected LEAP hash %q, want %q", got, want)
+	}
+	if got := creds["EAP Method"]; got != "PEAP" {
+		t.Errorf("Unexpected EAP method %q", got)
+	}
+	if got := creds["RADIUS Certificate"]; !strings.HasPrefix(got, "PEAP: CN=radius.corp.example") || !strings.Contains(got, "valid 2024-01-01 to 2025-01-01") {
+		t.Errorf("Unexpected RADIUS certificate %q", got)
+	}
+
+	host := networkMap.Hosts["02:AA:BB:CC:DD:01"]
+	if host == nil || host.Wifi == nil || host.Wifi.DeviceRole != "Client" || host.Wifi.AssociatedAP != "00:11:22:33:44:55" {
+		t.Errorf("Expected a client host associated with the AP, got %+v", host)
+	}
+	if len(summary.EAPState) != 0 {
+		t.Errorf("Expected completed exchanges to be cleared, got %v", summary.EAPState)
+	}
+}
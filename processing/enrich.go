@@ -49,6 +49,12 @@ func EnrichWithLookups(networkMap *model.NetworkMap, summary *model.PcapSummary)
 			allMacsToLookup[host.MACAddress] = ""
 		}
 	}
+	for mac := range summary.AccessPoints {
+		allMacsToLookup[mac] = ""
+	}
+	for mac := range summary.WirelessClients {
+		allMacsToLookup[mac] = ""
+	}
 
 	if len(allMacsToLookup) > 0 {
 		fmt.Printf("  -> Found %d unique MACs to look up.\n", len(allMacsToLookup))
@@ -71,6 +77,12 @@ func EnrichWithLookups(networkMap *model.NetworkMap, summary *model.PcapSummary)
 			if _, ok := summary.UnidentifiedMACs[mac]; ok {
 				summary.UnidentifiedMACs[mac] = vendor
 			}
+			if ap, ok := summary.AccessPoints[mac]; ok {
+				ap.Vendor = vendor
+			}
+			if client, ok := summary.WirelessClients[mac]; ok {
+				client.Vendor = vendor
+			}
 		}
 		fmt.Println("✅ Local MAC Vendor lookup complete.")
 	} else {
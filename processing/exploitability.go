@@ -0,0 +1,141 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ImportKEV reads a known exploited vulnerabilities list, such as the CISA KEV catalog in its JSON
+// or CSV form. The file may be compressed.
+func ImportKEV(path string) ([]model.KEVEntry, error) {
+	data, err := ReadDataFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", path, err)
+	}
+	return ParseKEV(data)
+}
+
+// ParseKEV parses a KEV catalog: a JSON document with a "vulnerabilities" list, or a CSV file with
+// a "cveID" column.
+func ParseKEV(data []byte) ([]model.KEVEntry, error) {
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("{")) {
+		var catalog struct {
+			Vulnerabilities []struct {
+				CVEID                      string `json:"cveID"`
+				VulnerabilityName          string `json:"vulnerabilityName"`
+				DateAdded                  string `json:"dateAdded"`
+				KnownRansomwareCampaignUse string `json:"knownRansomwareCampaignUse"`
+			} `json:"vulnerabilities"`
+		}
+		if err := json.Unmarshal(data, &catalog); err != nil {
+			return nil, fmt.Errorf("could not parse KEV catalog: %w", err)
+		}
+		var entries []model.KEVEntry
+		for _, v := range catalog.Vulnerabilities {
+			if v.CVEID == "" {
+				continue
+			}
+			entries = append(entries, model.KEVEntry{
+				CVE:           strings.ToUpper(v.CVEID),
+				Name:          v.VulnerabilityName,
+				DateAdded:     v.DateAdded,
+				RansomwareUse: strings.EqualFold(v.KnownRansomwareCampaignUse, "Known"),
+			})
+		}
+		if len(entries) == 0 {
+			return nil, fmt.Errorf("KEV catalog lists no vulnerabilities")
+		}
+		return entries, nil
+	}
+
+	records, columns, err := readCSVTable(data, "cveid")
+	if err != nil {
+		return nil, fmt.Errorf("could not parse KEV list: %w", err)
+	}
+	var entries []model.KEVEntry
+	for _, record := range records {
+		e := model.KEVEntry{
+			CVE:           strings.ToUpper(csvField(record, columns, "cveid")),
+			Name:          csvField(record, columns, "vulnerabilityname"),
+			DateAdded:     csvField(record, columns, "dateadded"),
+			RansomwareUse: strings.EqualFold(csvField(record, columns, "knownransomwarecampaignuse"), "Known"),
+		}
+		if e.CVE != "" {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// ImportEPSS reads the EPSS scores published by FIRST, usually as epss_scores-<date>.csv.gz.
+func ImportEPSS(path string) ([]model.EPSSScore, error) {
+	data, err := ReadDataFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", path, err)
+	}
+	return ParseEPSS(data)
+}
+
+// ParseEPSS parses an EPSS CSV file with "cve", "epss" and "percentile" columns. The "#model_version"
+// comment line FIRST puts before the header is skipped.
+func ParseEPSS(data []byte) ([]model.EPSSScore, error) {
+	records, columns, err := readCSVTable(data, "cve")
+	if err != nil {
+		return nil, fmt.Errorf("could not parse EPSS scores: %w", err)
+	}
+	if _, ok := columns["epss"]; !ok {
+		return nil, fmt.Errorf("could not parse EPSS scores: no epss column")
+	}
+	var scores []model.EPSSScore
+	for i, record := range records {
+		s := model.EPSSScore{CVE: strings.ToUpper(csvField(record, columns, "cve"))}
+		if s.Score, err = strconv.ParseFloat(csvField(record, columns, "epss"), 64); err != nil {
+			return nil, fmt.Errorf("could not parse the EPSS score on row %d: %w", i+1, err)
+		}
+		s.Percentile, _ = strconv.ParseFloat(csvField(record, columns, "percentile"), 64)
+		if s.CVE != "" {
+			scores = append(scores, s)
+		}
+	}
+	return scores, nil
+}
+
+// readCSVTable reads a CSV file whose header contains the key column, skipping "#" comment lines.
+// It returns the rows after the header and the index of each lowercased column name.
+func readCSVTable(data []byte, key string) ([][]string, map[string]int, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, fmt.Errorf("file is empty")
+	} else if err != nil {
+		return nil, nil, err
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	if _, ok := columns[key]; !ok {
+		return nil, nil, fmt.Errorf("no %s column", key)
+	}
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	return records, columns, nil
+}
+
+func csvField(record []string, columns map[string]int, name string) string {
+	if i, ok := columns[name]; ok && i < len(record) {
+		return strings.TrimSpace(record[i])
+	}
+	return ""
+}
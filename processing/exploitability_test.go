@@ -0,0 +1,57 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"testing"
+)
+
+const kevCatalog = `{"title": "CISA Catalog of Known Exploited Vulnerabilities", "catalogVersion": "2024.05.01", "count": 2,
+  "vulnerabilities": [
+    {"cveID": "CVE-2021-44228", "vendorProject": "Apache", "product": "Log4j2", "vulnerabilityName": "Apache Log4j2 Remote Code Execution Vulnerability",
+     "dateAdded": "2021-12-10", "knownRansomwareCampaignUse": "Known"},
+    {"cveID": "CVE-2023-20198", "vendorProject": "Cisco", "product": "IOS XE", "vulnerabilityName": "Cisco IOS XE Web UI Privilege Escalation Vulnerability",
+     "dateAdded": "2023-10-16", "knownRansomwareCampaignUse": "Unknown"}]}`
+
+const kevCSV = "\ufeffcveID,vendorProject,product,vulnerabilityName,dateAdded,shortDescription,requiredAction,dueDate,knownRansomwareCampaignUse,notes\n" +
+	"CVE-2021-44228,Apache,Log4j2,\"Apache Log4j2 Remote Code Execution Vulnerability\",2021-12-10,\"JNDI features, used in configuration\",Apply updates,2021-12-24,Known,\n"
+
+const epssCSV = "#model_version:v2023.03.01,score_date:2024-05-01T00:00:00+0000\n" +
+	"cve,epss,percentile\n" +
+	"CVE-2021-44228,0.97565,0.99995\n" +
+	"cve-2023-20198,0.94,0.9985\n"
+
+func TestParseKEV(t *testing.T) {
+	entries, err := ParseKEV([]byte(kevCatalog))
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("JSON: got %+v (%v)", entries, err)
+	}
+	if entries[0] != (model.KEVEntry{CVE: "CVE-2021-44228", Name: "Apache Log4j2 Remote Code Execution Vulnerability", DateAdded: "2021-12-10", RansomwareUse: true}) ||
+		entries[1].RansomwareUse {
+		t.Errorf("Unexpected JSON entries %+v", entries)
+	}
+
+	entries, err = ParseKEV([]byte(kevCSV))
+	if err != nil || len(entries) != 1 || entries[0].CVE != "CVE-2021-44228" || !entries[0].RansomwareUse || entries[0].DateAdded != "2021-12-10" {
+		t.Errorf("CSV: got %+v (%v)", entries, err)
+	}
+
+	if _, err := ParseKEV([]byte(epssCSV)); err == nil {
+		t.Error("Expected an error for a file without a cveID column")
+	}
+}
+
+func TestParseEPSS(t *testing.T) {
+	scores, err := ParseEPSS([]byte(epssCSV))
+	if err != nil || len(scores) != 2 {
+		t.Fatalf("Got %+v (%v)", scores, err)
+	}
+	if scores[0] != (model.EPSSScore{CVE: "CVE-2021-44228", Score: 0.97565, Percentile: 0.99995}) || scores[1].CVE != "CVE-2023-20198" {
+		t.Errorf("Unexpected scores %+v", scores)
+	}
+	if _, err := ParseEPSS([]byte("cve,epss\nCVE-2021-44228,high\n")); err == nil {
+		t.Error("Expected an error for a score that is not a number")
+	}
+	if _, err := ParseEPSS(nil); err == nil {
+		t.Error("Expected an error for an empty file")
+	}
+}
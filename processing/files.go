@@ -7,21 +7,57 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
-
-	"github.com/google/gopacket"
-	"github.com/google/gopacket/pcap"
 )
 
-// ProcessFiles handles the core logic of parsing Nmap and Pcap files concurrently.
-func ProcessFiles(xmlFiles, pcapFiles []string) (*model.NetworkMap, *model.PcapSummary) {
+// FileSet groups the data files found for a campaign by the parser that handles them.
+type FileSet struct {
+	Nmap      []string // Nmap (and masscan) XML output
+	PortScans []string // masscan JSON/list, RustScan and naabu output
+	VulnScans []string // Nessus and OpenVAS/GVM reports
+	NetLogs   []string // Zeek logs and Suricata eve.json
+	Pcap      []string
+}
+
+// Total returns the number of files in the set.
+func (fs FileSet) Total() int {
+	return len(fs.Nmap) + len(fs.PortScans) + len(fs.VulnScans) + len(fs.NetLogs) + len(fs.Pcap)
+}
+
+// Add files a path under the parser for the given file type. Unknown types are ignored.
+func (fs *FileSet) Add(path string, fileType FileType) {
+	switch {
+	case fileType == FileTypeNmapXML || fileType == FileTypeMasscanXML:
+		fs.Nmap = append(fs.Nmap, path)
+	case fileType.IsPortScan():
+		fs.PortScans = append(fs.PortScans, path)
+	case fileType.IsVulnScan():
+		fs.VulnScans = append(fs.VulnScans, path)
+	case fileType.IsNetworkLog():
+		fs.NetLogs = append(fs.NetLogs, path)
+	case fileType == FileTypePcap:
+		fs.Pcap = append(fs.Pcap, path)
+	}
+}
+
+// ProcessFiles handles the core logic of parsing scan and Pcap files concurrently. It also
+// returns the paths of files that could not be processed, so callers don't treat them as ingested.
+func ProcessFiles(files FileSet) (*model.NetworkMap, *model.PcapSummary, []string) {
 	masterMap := model.NewNetworkMap()
 	var mapMutex sync.Mutex
 
 	var wg sync.WaitGroup
-	errChan := make(chan error, len(xmlFiles)+len(pcapFiles))
+	errChan := make(chan error, files.Total())
+	var failed []string
+	var failedMutex sync.Mutex
+	fail := func(path string, err error) {
+		failedMutex.Lock()
+		failed = append(failed, path)
+		failedMutex.Unlock()
+		errChan <- err
+	}
 
 	var processedCount int32
-	totalFiles := int32(len(xmlFiles) + len(pcapFiles))
+	totalFiles := int32(files.Total())
 	done := make(chan bool)
 
 	go func() {
@@ -36,36 +72,64 @@ func ProcessFiles(xmlFiles, pcapFiles []string) (*model.NetworkMap, *model.PcapS
 		}
 	}()
 
-	if len(xmlFiles) > 0 {
-		fmt.Println("\n--- Parsing Nmap files ---")
-		for _, file := range xmlFiles {
+	// parseScanFiles runs a scan parser over each file and merges the results into the master map.
+	parseScanFiles := func(label string, paths []string, parse func(string, *model.NetworkMap) error) {
+		if len(paths) == 0 {
+			return
+		}
+		fmt.Printf("\n--- Parsing %s files ---\n", label)
+		for _, file := range paths {
 			wg.Add(1)
 			go func(filePath string) {
 				defer wg.Done()
 				defer atomic.AddInt32(&processedCount, 1)
 
 				tempMap := model.NewNetworkMap()
-				if err := MergeFromFile(filePath, tempMap); err != nil {
-					errChan <- fmt.Errorf("could not parse Nmap file %s: %w", filePath, err)
+				if err := parse(filePath, tempMap); err != nil {
+					fail(filePath, fmt.Errorf("could not parse %s file %s: %w", label, filePath, err))
 					return
 				}
+				for i := range tempMap.ScanRuns {
+					tempMap.ScanRuns[i].SourceFile = filePath
+				}
 
 				mapMutex.Lock()
-				for k, v := range tempMap.Hosts {
-					masterMap.Hosts[k] = v
-				}
+				MergeNetworkMaps(masterMap, tempMap)
 				mapMutex.Unlock()
 			}(file)
 		}
 		wg.Wait()
 	}
 
+	parseScanFiles("Nmap", files.Nmap, MergeFromFile)
+	parseScanFiles("port scan", files.PortScans, MergeFromPortScanFile)
+	parseScanFiles("vulnerability report", files.VulnScans, MergeFromVulnScanFile)
+
 	globalSummary := model.NewPcapSummary()
 	var pcapMutex sync.Mutex
 
-	if len(pcapFiles) > 0 {
+	if len(files.NetLogs) > 0 {
+		fmt.Println("\n--- Ingesting Zeek/Suricata logs ---")
+		for _, file := range files.NetLogs {
+			wg.Add(1)
+			go func(filePath string) {
+				defer wg.Done()
+				defer atomic.AddInt32(&processedCount, 1)
+
+				pcapMutex.Lock()
+				defer pcapMutex.Unlock()
+
+				if err := ProcessNetworkLogFile(filePath, masterMap, globalSummary); err != nil {
+					fail(filePath, fmt.Errorf("could not process network log %s: %w", filePath, err))
+				}
+			}(file)
+		}
+		wg.Wait()
+	}
+
+	if len(files.Pcap) > 0 {
 		fmt.Println("\n--- Enriching with Pcap files ---")
-		for _, file := range pcapFiles {
+		for _, file := range files.Pcap {
 			wg.Add(1)
 			go func(filePath string) {
 				defer wg.Done()
@@ -75,7 +139,7 @@ func ProcessFiles(xmlFiles, pcapFiles []string) (*model.NetworkMap, *model.PcapS
 				defer pcapMutex.Unlock()
 
 				if err := EnrichData(filePath, masterMap, globalSummary); err != nil {
-					errChan <- fmt.Errorf("could not process pcap file %s: %w", filePath, err)
+					fail(filePath, fmt.Errorf("could not process pcap file %s: %w", filePath, err))
 					return
 				}
 			}(file)
@@ -83,6 +147,8 @@ func ProcessFiles(xmlFiles, pcapFiles []string) (*model.NetworkMap, *model.PcapS
 		wg.Wait()
 	}
 
+	ResolveCredentialHosts(masterMap, globalSummary)
+
 	done <- true
 	fmt.Printf("\rProcessing files: %d/%d... Done.\n", atomic.LoadInt32(&processedCount), totalFiles)
 
@@ -101,20 +167,5 @@ func ProcessFiles(xmlFiles, pcapFiles []string) (*model.NetworkMap, *model.PcapS
 
 	fmt.Printf("\n✅ File processing complete. Found %d unique hosts.\n\n", len(masterMap.Hosts))
 
-	return masterMap, globalSummary
-}
-
-// EnrichData opens a pcap file and processes its packets.
-func EnrichData(file string, networkMap *model.NetworkMap, summary *model.PcapSummary) error {
-	handle, err := pcap.OpenOffline(file)
-	if err != nil {
-		return fmt.Errorf("could not open pcap file %s: %w", file, err)
-	}
-	defer handle.Close()
-
-	packetSource := gopacket.NewPacketSource(handle, handle.LinkType())
-	for packet := range packetSource.Packets() {
-		ProcessPacket(packet, networkMap, summary, file)
-	}
-	return nil
+	return masterMap, globalSummary, failed
 }
@@ -0,0 +1,134 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/gopacket/layers"
+)
+
+// ftpFileCommands are the commands whose data connection carries a file rather than a listing.
+var ftpFileCommands = map[string]bool{"RETR": true, "STOR": true, "STOU": true, "APPE": true}
+
+// processFTPControl follows an FTP control connection for the data connections it sets up
+// (PORT/EPRT from the client, or the server's answer to PASV/EPSV) and the file command that
+// uses each one, so the data connection can be carved when it closes.
+func processFTPControl(tcp *layers.TCP, srcIP, dstIP string, summary *model.PcapSummary) {
+	if len(tcp.Payload) == 0 {
+		return
+	}
+	if summary.FTPTransfers == nil {
+		summary.FTPTransfers = make(map[string]*model.FTPTransfer)
+		summary.FTPCommands = make(map[string]*model.FTPTransfer)
+	}
+	fromClient := tcp.DstPort == 21
+	control := streamKey(dstIP, int(tcp.DstPort), srcIP, int(tcp.SrcPort))
+	if fromClient {
+		control = streamKey(srcIP, int(tcp.SrcPort), dstIP, int(tcp.DstPort))
+	}
+	announce := func(endpoints ...string) {
+		transfer := &model.FTPTransfer{}
+		for _, endpoint := range endpoints {
+			summary.FTPTransfers[endpoint] = transfer
+		}
+		summary.FTPCommands[control] = transfer
+	}
+
+	for _, line := range strings.Split(string(tcp.Payload), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if !fromClient {
+			switch {
+			case strings.HasPrefix(line, "227 "):
+				// 227 Entering Passive Mode (h1,h2,h3,h4,p1,p2). Behind NAT the address may not be the
+				// one the client connects to, so the control connection's server address is expected too.
+				if open, end := strings.Index(line, "("), strings.LastIndex(line, ")"); open >= 0 && end > open {
+					if ip, port, ok := parseFTPHostPort(line[open+1 : end]); ok {
+						announce(fmt.Sprintf("%s:%d", ip, port), fmt.Sprintf("%s:%d", srcIP, port))
+					}
+				}
+			case strings.HasPrefix(line, "229 "):
+				// 229 Entering Extended Passive Mode (|||port|)
+				if open, end := strings.Index(line, "("), strings.LastIndex(line, ")"); open >= 0 && end > open {
+					if fields := strings.Split(line[open+1:end], "|"); len(fields) == 5 {
+						if port, err := strconv.Atoi(fields[3]); err == nil {
+							announce(fmt.Sprintf("%s:%d", srcIP, port))
+						}
+					}
+				}
+			}
+			continue
+		}
+
+		command, argument, _ := strings.Cut(line, " ")
+		switch command = strings.ToUpper(command); {
+		case command == "PORT":
+			if ip, port, ok := parseFTPHostPort(argument); ok {
+				announce(fmt.Sprintf("%s:%d", ip, port))
+			}
+		case command == "EPRT":
+			// EPRT |1|ip|port|
+			if fields := strings.Split(argument, "|"); len(fields) == 5 {
+				if port, err := strconv.Atoi(fields[3]); err == nil {
+					announce(fmt.Sprintf("%s:%d", fields[2], port))
+				}
+			}
+		case ftpFileCommands[command]:
+			if transfer := summary.FTPCommands[control]; transfer != nil && transfer.Command == "" {
+				transfer.Command, transfer.Filename = command, strings.TrimSpace(argument)
+			}
+		}
+	}
+}
+
+// parseFTPHostPort parses the h1,h2,h3,h4,p1,p2 address of PORT and PASV.
+func parseFTPHostPort(value string) (string, int, bool) {
+	fields := strings.Split(strings.TrimSpace(value), ",")
+	if len(fields) != 6 {
+		return "", 0, false
+	}
+	var numbers [6]int
+	for i, field := range fields {
+		n, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || n < 0 || n > 255 {
+			return "", 0, false
+		}
+		numbers[i] = n
+	}
+	return fmt.Sprintf("%d.%d.%d.%d", numbers[0], numbers[1], numbers[2], numbers[3]), numbers[4]<<8 | numbers[5], true
+}
+
+// claimFTPTransfer returns the transfer announced for a data connection to ip:port, which is
+// then no longer expected.
+func claimFTPTransfer(summary *model.PcapSummary, ip string, port int) *model.FTPTransfer {
+	endpoint := fmt.Sprintf("%s:%d", ip, port)
+	transfer, ok := summary.FTPTransfers[endpoint]
+	if !ok {
+		return nil
+	}
+	for key, t := range summary.FTPTransfers {
+		if t == transfer {
+			delete(summary.FTPTransfers, key)
+		}
+	}
+	return transfer
+}
+
+// carveFTPData carves the file a data connection carried for RETR, STOR, STOU or APPE, if the
+// whole transfer up to the close was captured. Listings and transfers cut short are skipped.
+func carveFTPData(stream *model.TCPStream, summary *model.PcapSummary) {
+	if !ftpFileCommands[stream.FTP.Command] {
+		return
+	}
+	// Data flows in one direction; the side that sent it is the source.
+	flow, srcIP, dstIP := &stream.Server, stream.ServerIP, stream.ClientIP
+	if len(stream.Client.Data) > len(stream.Server.Data) {
+		flow, srcIP, dstIP = &stream.Client, stream.ClientIP, stream.ServerIP
+	}
+	if !flow.Finished || flow.Truncated || len(flow.Marks) == 0 {
+		return
+	}
+	addCarvedFile(summary, stream.Host, "FTP-DATA", carvedFilename(stream.FTP.Filename, "ftp-data"), "", srcIP, dstIP,
+		flow.Marks[0].Timestamp, stream.PcapFile, flow.Data)
+}
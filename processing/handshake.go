@@ -47,8 +47,12 @@ func isMessage4(keyInfo uint16) bool {
 	return !keyInfoACK.isSet(keyInfo) && keyInfoMIC.isSet(keyInfo)
 }
 
-// ProcessHandshakes analyzes captured EAPOL packets to identify WPA handshakes.
+// ProcessHandshakes analyzes captured EAPOL packets to identify WPA handshakes. Hosts that
+// appear in the wireless inventory are first given their role, SSID and probed networks, and
+// deauthentication frames are finally correlated with the handshakes that followed them.
 func ProcessHandshakes(networkMap *model.NetworkMap, summary *model.PcapSummary) {
+	linkWirelessHosts(networkMap, summary)
+
 	for sessionKey, packets := range summary.EapolTracker {
 		macs := strings.Split(sessionKey, "-")
 		if len(macs) != 2 {
@@ -133,6 +137,10 @@ func ProcessHandshakes(networkMap *model.NetworkMap, summary *model.PcapSummary)
 				host.Wifi.DeviceRole = "Access Point"
 			} else {
 				host.Wifi.DeviceRole = "Client"
+				host.Wifi.AssociatedAP = strings.ToUpper(apMAC)
+			}
+			if ap, ok := summary.AccessPoints[strings.ToUpper(apMAC)]; ok && ap.SSID != "" {
+				host.Wifi.SSID = ap.SSID
 			}
 		}
 
@@ -166,4 +174,6 @@ func ProcessHandshakes(networkMap *model.NetworkMap, summary *model.PcapSummary)
 			})
 		}
 	}
+
+	summarizeDeauths(summary)
 }
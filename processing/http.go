@@ -0,0 +1,226 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// webAssetTypes are page resources that are not carved as files unless served as an attachment.
+var webAssetTypes = map[string]bool{
+	"text/html": true, "text/css": true, "text/javascript": true, "application/javascript": true, "application/x-javascript": true,
+}
+
+// httpMethods are the request methods recognised at the start of a connection.
+var httpMethods = []string{
+	"GET", "POST", "HEAD", "PUT", "DELETE", "OPTIONS", "PATCH", "TRACE", "CONNECT",
+	"PROPFIND", "PROPPATCH", "MKCOL", "COPY", "MOVE", "LOCK", "UNLOCK", "REPORT", "SEARCH",
+}
+
+func isHTTPRequestStart(payload []byte) bool {
+	for _, method := range httpMethods {
+		if len(payload) > len(method) && payload[len(method)] == ' ' && string(payload[:len(method)]) == method {
+			return true
+		}
+	}
+	return false
+}
+
+// httpMessage is a parsed request or response with its body, de-chunked but still in its
+// content encoding.
+type httpMessage struct {
+	request  *http.Request
+	response *http.Response
+	body     []byte
+	complete bool // The whole body was captured
+	time     time.Time
+	offset   int // Where the message starts in its flow
+}
+
+// parseHTTPStream records the HTTP/1.x transactions of a connection on its host. Responses are
+// paired with requests in order, as pipelining requires. Complete files that were downloaded or
+// uploaded are carved.
+func parseHTTPStream(stream *model.TCPStream, summary *model.PcapSummary) {
+	requests := readHTTPRequests(&stream.Client)
+	responses := readHTTPResponses(&stream.Server, requests)
+	for i, request := range requests {
+		t := model.HTTPTransaction{
+			ClientIP:     stream.ClientIP,
+			ClientPort:   stream.ClientPort,
+			ServerIP:     stream.ServerIP,
+			ServerPort:   stream.ServerPort,
+			StreamOffset: request.offset,
+			Method:       request.request.Method,
+			Host:         request.request.Host,
+			URI:          request.request.RequestURI,
+			UserAgent:    request.request.UserAgent(),
+			RequestSize:  len(request.body),
+			Timestamp:    request.time,
+			PcapFile:     stream.PcapFile,
+		}
+		if i < len(responses) {
+			response := responses[i].response
+			t.StatusCode = response.StatusCode
+			t.Server = response.Header.Get("Server")
+			t.ContentType = response.Header.Get("Content-Type")
+			t.ResponseSize = len(responses[i].body)
+			carveHTTPResponse(stream, summary, request, responses[i])
+		}
+		carveHTTPRequest(stream, summary, request)
+		stream.Host.HTTPRequests = append(stream.Host.HTTPRequests, t)
+	}
+}
+
+// readHTTPRequests parses the requests a client sent. Parsing stops at the first malformed
+// message, and after one whose body was cut short.
+func readHTTPRequests(flow *model.TCPFlow) []httpMessage {
+	r := bytes.NewReader(flow.Data)
+	br := bufio.NewReader(r)
+	var messages []httpMessage
+	for {
+		offset := len(flow.Data) - r.Len() - br.Buffered()
+		if offset >= len(flow.Data) {
+			break
+		}
+		request, err := http.ReadRequest(br)
+		if err != nil {
+			break
+		}
+		body, err := io.ReadAll(request.Body)
+		messages = append(messages, httpMessage{request: request, body: body, complete: err == nil, time: flowTime(flow, offset), offset: offset})
+		if err != nil {
+			break
+		}
+	}
+	return messages
+}
+
+// readHTTPResponses parses the server's responses to requests. Interim (1xx) responses are
+// skipped, and parsing stops once the connection is switched to another protocol or tunnelled.
+func readHTTPResponses(flow *model.TCPFlow, requests []httpMessage) []httpMessage {
+	r := bytes.NewReader(flow.Data)
+	br := bufio.NewReader(r)
+	var messages []httpMessage
+	for len(messages) < len(requests) {
+		offset := len(flow.Data) - r.Len() - br.Buffered()
+		if offset >= len(flow.Data) {
+			break
+		}
+		request := requests[len(messages)].request
+		response, err := http.ReadResponse(br, request)
+		if err != nil {
+			break
+		}
+		body, err := io.ReadAll(response.Body)
+		if response.StatusCode < 200 && response.StatusCode != http.StatusSwitchingProtocols {
+			continue
+		}
+		// A body delimited by the connection closing is only whole if the close was captured.
+		complete := err == nil && (response.ContentLength >= 0 || len(response.TransferEncoding) > 0 || (flow.Finished && !flow.Truncated))
+		messages = append(messages, httpMessage{response: response, body: body, complete: complete, time: flowTime(flow, offset)})
+		tunnelled := request.Method == http.MethodConnect && response.StatusCode < 300
+		if err != nil || response.StatusCode == http.StatusSwitchingProtocols || tunnelled {
+			break
+		}
+	}
+	return messages
+}
+
+// carveHTTPResponse carves a downloaded file. Pages and their scripts and stylesheets are left
+// out unless the server offered them as an attachment.
+func carveHTTPResponse(stream *model.TCPStream, summary *model.PcapSummary, request, response httpMessage) {
+	if !response.complete || len(response.body) == 0 || request.request.Method == http.MethodHead {
+		return
+	}
+	header := response.response.Header
+	disposition, params, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	declared, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	data := decodeHTTPBody(response.body, header.Get("Content-Encoding"))
+	if disposition != "attachment" && (webAssetTypes[declared] || webAssetTypes[carvedMIMEType(data, declared)]) {
+		return
+	}
+	filename := params["filename"]
+	if filename == "" {
+		filename = httpPathName(request.request)
+	}
+	addCarvedFile(summary, stream.Host, "HTTP", carvedFilename(filename, "index"), declared, stream.ServerIP, stream.ClientIP,
+		response.time, stream.PcapFile, data)
+}
+
+// carveHTTPRequest carves uploaded files: PUT bodies and the file parts of multipart forms.
+func carveHTTPRequest(stream *model.TCPStream, summary *model.PcapSummary, request httpMessage) {
+	if !request.complete || len(request.body) == 0 {
+		return
+	}
+	carve := func(filename, declared string, data []byte) {
+		addCarvedFile(summary, stream.Host, "HTTP", carvedFilename(filename, "upload"), declared, stream.ClientIP, stream.ServerIP,
+			request.time, stream.PcapFile, data)
+	}
+	header := request.request.Header
+	mediaType, params, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	switch {
+	case request.request.Method == http.MethodPut:
+		carve(httpPathName(request.request), mediaType, decodeHTTPBody(request.body, header.Get("Content-Encoding")))
+	case mediaType == "multipart/form-data" && params["boundary"] != "":
+		reader := multipart.NewReader(bytes.NewReader(request.body), params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err != nil {
+				return
+			}
+			if part.FileName() == "" {
+				continue
+			}
+			data, err := io.ReadAll(io.LimitReader(part, maxStreamBytes))
+			if err != nil {
+				return
+			}
+			partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+			carve(part.FileName(), partType, data)
+		}
+	}
+}
+
+// httpPathName returns the path of a request's URL, without its query.
+func httpPathName(request *http.Request) string {
+	if u, err := url.ParseRequestURI(request.RequestURI); err == nil {
+		return u.Path
+	}
+	return request.RequestURI
+}
+
+// decodeHTTPBody undoes a gzip or deflate content encoding, keeping the body as sent when it
+// cannot be decoded.
+func decodeHTTPBody(body []byte, encoding string) []byte {
+	var reader io.Reader
+	var err error
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "gzip", "x-gzip":
+		reader, err = gzip.NewReader(bytes.NewReader(body))
+	case "deflate":
+		// Servers disagree on whether deflate means zlib-wrapped or raw.
+		if reader, err = zlib.NewReader(bytes.NewReader(body)); err != nil {
+			reader, err = flate.NewReader(bytes.NewReader(body)), nil
+		}
+	default:
+		return body
+	}
+	if err != nil {
+		return body
+	}
+	decoded, err := io.ReadAll(io.LimitReader(reader, maxStreamBytes))
+	if err != nil {
+		return body
+	}
+	return decoded
+}
@@ -0,0 +1,85 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+)
+
+// httpSegment builds a TCP segment between the test client and a web server on 10.0.0.10:80.
+func httpSegment(t *testing.T, fromClient bool, clientPort uint16, seq uint32, fin bool, payload string, ts time.Time) gopacket.Packet {
+	t.Helper()
+	return tcpTestSegment(t, fromClient, clientPort, 80, seq, fin, []byte(payload), ts)
+}
+
+// TestHTTPTransactions verifies that pipelined requests are paired with their responses across
+// reordered and retransmitted segments, that chunked and close-delimited bodies are sized, and
+// that connections still open at the end of a capture are parsed by FlushStreams.
+func TestHTTPTransactions(t *testing.T) {
+	requests := "GET /wiki/Main HTTP/1.1\r\nHost: wiki.corp.example\r\nUser-Agent: TestAgent/1.0\r\n\r\n" +
+		"POST /login HTTP/1.1\r\nHost: wiki.corp.example\r\nContent-Length: 11\r\n\r\nuser=alice&"
+	first := "HTTP/1.1 200 OK\r\nServer: nginx\r\nContent-Type: text/html\r\nContent-Length: 5\r\n\r\nhello"
+	second := "HTTP/1.1 302 Found\r\nTransfer-Encoding: chunked\r\n\r\n3\r\nabc\r\n4\r\ndefg\r\n0\r\n\r\n"
+	responses := first + second
+	split := len(first) + 10
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	const clientSeq, serverSeq = 1000, 5000
+	packets := []gopacket.Packet{
+		httpSegment(t, true, 50000, clientSeq, false, requests[:70], start),
+		httpSegment(t, true, 50000, clientSeq+70, false, requests[70:], start.Add(time.Second)),
+		// The end of the responses arrives before their middle, and the start is retransmitted.
+		httpSegment(t, false, 50000, serverSeq, false, responses[:20], start.Add(2*time.Second)),
+		httpSegment(t, false, 50000, serverSeq+uint32(split), false, responses[split:], start.Add(2*time.Second)),
+		httpSegment(t, false, 50000, serverSeq+20, false, responses[20:split], start.Add(2*time.Second)),
+		httpSegment(t, false, 50000, serverSeq, false, responses[:split], start.Add(2*time.Second)),
+		httpSegment(t, true, 50000, clientSeq+uint32(len(requests)), true, "", start.Add(3*time.Second)),
+		httpSegment(t, false, 50000, serverSeq+uint32(len(responses)), true, "", start.Add(3*time.Second)),
+		// A download delimited by the connection closing, still open when the capture ends.
+		httpSegment(t, true, 50001, 1, false, "GET /files/report.pdf HTTP/1.0\r\n\r\n", start.Add(time.Minute)),
+		httpSegment(t, false, 50001, 1, false, "HTTP/1.0 200 OK\r\nContent-Type: application/pdf\r\n\r\n%PDF-1.4", start.Add(time.Minute)),
+		// Not HTTP, so never tracked.
+		httpSegment(t, true, 50002, 1, false, "SSH-2.0-OpenSSH_9.6\r\n", start),
+	}
+	networkMap, summary := model.NewNetworkMap(), model.NewPcapSummary()
+	for _, packet := range packets {
+		ProcessPacket(packet, networkMap, summary, "http.pcap")
+	}
+	if len(summary.TCPStreams) != 1 {
+		t.Fatalf("Expected only the unfinished connection to be open, got %d", len(summary.TCPStreams))
+	}
+	FlushStreams(networkMap, summary)
+	if len(summary.TCPStreams) != 0 {
+		t.Errorf("Expected FlushStreams to clear the open connections")
+	}
+
+	client := networkMap.Hosts["02:00:00:00:00:05"]
+	if client == nil || len(client.HTTPRequests) != 3 {
+		t.Fatalf("Expected three transactions on the client, got %+v", client)
+	}
+	get, post, download := client.HTTPRequests[0], client.HTTPRequests[1], client.HTTPRequests[2]
+	if get.Method != "GET" || get.URL() != "http://wiki.corp.example/wiki/Main" || get.UserAgent != "TestAgent/1.0" || !get.Timestamp.Equal(start) {
+		t.Errorf("Unexpected GET request: %+v", get)
+	}
+	if get.StatusCode != 200 || get.Server != "nginx" || get.ContentType != "text/html" || get.ResponseSize != 5 {
+		t.Errorf("Unexpected GET response: %+v", get)
+	}
+	if post.Method != "POST" || post.URI != "/login" || post.RequestSize != 11 || !post.Timestamp.Equal(start.Add(time.Second)) {
+		t.Errorf("Unexpected POST request: %+v", post)
+	}
+	if post.StatusCode != 302 || post.ResponseSize != 7 {
+		t.Errorf("Unexpected POST response: %+v", post)
+	}
+	if get.ClientPort != 50000 || get.StreamOffset != 0 || post.StreamOffset != strings.Index(requests, "POST") {
+		t.Errorf("Expected each request's position in its connection, got %d:%d and %d:%d", get.ClientPort, get.StreamOffset, post.ClientPort, post.StreamOffset)
+	}
+	if download.URL() != "http://10.0.0.10/files/report.pdf" || download.ContentType != "application/pdf" || download.ResponseSize != 8 || download.PcapFile != "http.pcap" {
+		t.Errorf("Unexpected download: %+v", download)
+	}
+	if server := networkMap.Hosts["02:00:00:00:00:0A"]; len(server.HTTPRequests) != 0 {
+		t.Errorf("Expected transactions only on the client, got %+v", server.HTTPRequests)
+	}
+}
@@ -0,0 +1,300 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+const insecureSource = "Passive protocol analysis"
+
+// transportPacket is the part of a TCP or UDP packet the payload-based detectors look at.
+type transportPacket struct {
+	Transport        string // "TCP" or "UDP"
+	SrcPort, DstPort int
+	Payload          []byte
+}
+
+// insecureRule flags hosts that speak a protocol which exposes credentials or sessions on the
+// wire. A rule applies to packets with a payload on one of its ports (any port if Ports is empty).
+type insecureRule struct {
+	ID       string
+	Protocol string
+	Category model.FindingCategory
+	Risk     string
+	// Transport and Ports select the packets the rule is applied to.
+	Transport string
+	Ports     []int
+	// ClientOnly rules flag only the sender of requests, e.g. for name resolution broadcasts.
+	ClientOnly bool
+	// match reports whether the packet uses the protocol insecurely and whether it was sent to
+	// the server side of the conversation.
+	match func(p *transportPacket) (toServer, ok bool)
+}
+
+var insecureRules = []insecureRule{
+	{
+		ID: "INSECURE-TELNET", Protocol: "Telnet", Category: model.CriticalFinding, Transport: "TCP", Ports: []int{23},
+		Risk:  "Telnet sends sessions and credentials in cleartext; use SSH instead.",
+		match: func(p *transportPacket) (bool, bool) { return p.DstPort == 23, !looksLikeTLS(p.Payload) },
+	},
+	{
+		ID: "INSECURE-FTP", Protocol: "FTP", Category: model.PotentialFinding, Transport: "TCP", Ports: []int{21},
+		Risk:  "FTP credentials were sent without TLS; use SFTP or FTPS.",
+		match: toServerCommand(21, "USER ", "PASS "),
+	},
+	{
+		ID: "INSECURE-HTTP-BASIC", Protocol: "HTTP Basic authentication", Category: model.CriticalFinding, Transport: "TCP",
+		Risk:  "HTTP Basic credentials were sent over cleartext HTTP and are trivially decoded; require HTTPS.",
+		match: matchHTTPBasic,
+	},
+	{
+		ID: "INSECURE-SNMP-V1V2C", Protocol: "SNMPv1/v2c", Category: model.PotentialFinding, Transport: "UDP", Ports: []int{161, 162},
+		Risk:  "SNMPv1/v2c authenticates with a cleartext community string; use SNMPv3 with authPriv.",
+		match: matchSNMPCommunity,
+	},
+	{
+		ID: "INSECURE-TFTP", Protocol: "TFTP", Category: model.PotentialFinding, Transport: "UDP", Ports: []int{69},
+		Risk: "TFTP transfers files without authentication or encryption.",
+		match: func(p *transportPacket) (bool, bool) {
+			return true, p.DstPort == 69 && len(p.Payload) >= 4 && p.Payload[0] == 0 && (p.Payload[1] == tftpRRQ || p.Payload[1] == tftpWRQ)
+		},
+	},
+	{
+		ID: "INSECURE-R-SERVICES", Protocol: "rexec/rlogin/rsh", Category: model.CriticalFinding, Transport: "TCP", Ports: []int{512, 513, 514},
+		Risk:  "The r-services trust source addresses and send credentials in cleartext; use SSH instead.",
+		match: func(p *transportPacket) (bool, bool) { return p.DstPort >= 512 && p.DstPort <= 514, true },
+	},
+	{
+		ID: "INSECURE-LLMNR", Protocol: "LLMNR", Category: model.PotentialFinding, Transport: "UDP", Ports: []int{5355}, ClientOnly: true,
+		Risk:  "LLMNR queries can be answered by any host on the segment, which enables credential relay attacks; disable LLMNR.",
+		match: matchNameQuery(5355),
+	},
+	{
+		ID: "INSECURE-NBT-NS", Protocol: "NBT-NS", Category: model.PotentialFinding, Transport: "UDP", Ports: []int{137}, ClientOnly: true,
+		Risk:  "NetBIOS name queries can be answered by any host on the segment, which enables credential relay attacks; disable NetBIOS over TCP/IP.",
+		match: matchNameQuery(137),
+	},
+	{
+		ID: "INSECURE-SMBV1", Protocol: "SMBv1", Category: model.CriticalFinding, Transport: "TCP", Ports: []int{139, 445},
+		Risk:  "SMBv1 lacks modern integrity protection and is exposed to wormable vulnerabilities such as EternalBlue; disable it.",
+		match: matchSMBv1,
+	},
+	{
+		ID: "INSECURE-LDAP-SIMPLE-BIND", Protocol: "LDAP simple bind", Category: model.CriticalFinding, Transport: "TCP", Ports: []int{389, 3268},
+		Risk:  "An LDAP simple bind sent a password without TLS; require LDAPS or StartTLS and LDAP signing.",
+		match: matchLDAPSimpleBind,
+	},
+	{
+		ID: "INSECURE-POP3", Protocol: "POP3", Category: model.PotentialFinding, Transport: "TCP", Ports: []int{110},
+		Risk:  "POP3 credentials were sent without TLS; use POP3S or STLS.",
+		match: toServerCommand(110, "USER ", "PASS ", "APOP "),
+	},
+	{
+		ID: "INSECURE-IMAP", Protocol: "IMAP", Category: model.PotentialFinding, Transport: "TCP", Ports: []int{143},
+		Risk:  "IMAP credentials were sent without TLS; use IMAPS or STARTTLS.",
+		match: matchIMAPLogin,
+	},
+	{
+		ID: "INSECURE-SMTP-AUTH", Protocol: "SMTP AUTH", Category: model.PotentialFinding, Transport: "TCP", Ports: []int{25, 587},
+		Risk: "SMTP credentials were sent without TLS; require STARTTLS before AUTH.",
+		match: func(p *transportPacket) (bool, bool) {
+			return true, (p.DstPort == 25 || p.DstPort == 587) && hasPrefixFold(p.Payload, "AUTH PLAIN", "AUTH LOGIN")
+		},
+	},
+}
+
+// checkInsecureProtocols applies the insecure protocol rules to a TCP or UDP packet and records
+// a finding on each local host taking part, once per host, rule and server port. localHost
+// returns the host for a local address and nil for remote ones.
+func checkInsecureProtocols(p *transportPacket, srcIP, dstIP string, localHost func(ip string) *model.Host, summary *model.PcapSummary, sourceName string) {
+	if len(p.Payload) == 0 {
+		return
+	}
+	for i := range insecureRules {
+		rule := &insecureRules[i]
+		if rule.Transport != p.Transport || (len(rule.Ports) > 0 && !hasPort(rule.Ports, p.SrcPort) && !hasPort(rule.Ports, p.DstPort)) {
+			continue
+		}
+		toServer, ok := rule.match(p)
+		if !ok {
+			continue
+		}
+		clientIP, serverIP, port := srcIP, dstIP, p.DstPort
+		if !toServer {
+			clientIP, serverIP, port = dstIP, srcIP, p.SrcPort
+		}
+		evidence := model.Vulnerability{EvidenceFile: sourceName, EvidencePacket: summary.SourcePackets[sourceName]}
+		if client := localHost(clientIP); client != nil {
+			evidence.Description = fmt.Sprintf("Host used %s with %s (port %d). %s", rule.Protocol, serverIP, port, rule.Risk)
+			if rule.ClientOnly {
+				evidence.Description = fmt.Sprintf("Host sent %s queries. %s", rule.Protocol, rule.Risk)
+			}
+			addInsecureFinding(client, clientIP, rule, 0, evidence, summary)
+		}
+		if rule.ClientOnly {
+			continue
+		}
+		if server := localHost(serverIP); server != nil {
+			evidence.Description = fmt.Sprintf("Host serves %s on port %d. %s", rule.Protocol, port, rule.Risk)
+			addInsecureFinding(server, serverIP, rule, port, evidence, summary)
+		}
+	}
+}
+
+// addInsecureFinding records a rule match on a host unless it was already reported.
+func addInsecureFinding(host *model.Host, ip string, rule *insecureRule, port int, vuln model.Vulnerability, summary *model.PcapSummary) {
+	key := fmt.Sprintf("%s|%s|%d", ip, rule.ID, port)
+	if summary.InsecureProtocols[key] {
+		return
+	}
+	summary.InsecureProtocols[key] = true
+	vuln.CVE, vuln.Category, vuln.State, vuln.Source, vuln.PortID = rule.ID, rule.Category, stateDetected, insecureSource, port
+	addFinding(host, vuln)
+}
+
+func hasPort(ports []int, port int) bool {
+	for _, p := range ports {
+		if p == port {
+			return true
+		}
+	}
+	return false
+}
+
+// looksLikeTLS reports whether a payload starts with a TLS record header.
+func looksLikeTLS(payload []byte) bool {
+	return len(payload) >= 3 && payload[0] >= 20 && payload[0] <= 23 && payload[1] == 3
+}
+
+func hasPrefixFold(payload []byte, prefixes ...string) bool {
+	for _, prefix := range prefixes {
+		if len(payload) >= len(prefix) && strings.EqualFold(string(payload[:len(prefix)]), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// toServerCommand matches clients sending one of the given line commands to the server port.
+func toServerCommand(port int, commands ...string) func(p *transportPacket) (bool, bool) {
+	return func(p *transportPacket) (bool, bool) {
+		return true, p.DstPort == port && hasPrefixFold(p.Payload, commands...)
+	}
+}
+
+// matchHTTPBasic matches HTTP requests carrying Basic credentials.
+func matchHTTPBasic(p *transportPacket) (bool, bool) {
+	if !isHTTPRequestStart(p.Payload) {
+		return false, false
+	}
+	end := bytes.Index(p.Payload, []byte("\r\n\r\n"))
+	if end < 0 {
+		end = len(p.Payload)
+	}
+	headers := bytes.ToLower(p.Payload[:end])
+	return true, bytes.Contains(headers, []byte("\nauthorization: basic ")) || bytes.Contains(headers, []byte("\nproxy-authorization: basic "))
+}
+
+// matchSNMPCommunity matches SNMPv1 and v2c messages, whose version field is 0 or 1.
+func matchSNMPCommunity(p *transportPacket) (bool, bool) {
+	tag, content, _, ok := berElement(p.Payload)
+	if !ok || tag != 0x30 {
+		return false, false
+	}
+	tag, version, _, ok := berElement(content)
+	if !ok || tag != 0x02 || len(version) != 1 || version[0] > 1 {
+		return false, false
+	}
+	return p.DstPort == 161 || p.DstPort == 162, true
+}
+
+// matchNameQuery matches LLMNR and NetBIOS name queries sent to the given port.
+func matchNameQuery(port int) func(p *transportPacket) (bool, bool) {
+	return func(p *transportPacket) (bool, bool) {
+		if p.DstPort != port || len(p.Payload) < 12 {
+			return false, false
+		}
+		flags := p.Payload[2]
+		return true, flags&0x80 == 0 && (flags>>3)&0x0f == 0 // A query with the standard opcode
+	}
+}
+
+// matchSMBv1 matches SMBv1 replies, and SMBv1 requests other than the multi-dialect Negotiate
+// that current clients still open with.
+func matchSMBv1(p *transportPacket) (bool, bool) {
+	if len(p.Payload) < 4+32 || p.Payload[0] != 0 || !bytes.HasPrefix(p.Payload[4:], smb1ProtocolID) {
+		return false, false
+	}
+	header := p.Payload[4:]
+	if header[9]&0x80 != 0 { // FLAGS_REPLY
+		return false, true
+	}
+	return true, header[4] != 0x72 // SMB_COM_NEGOTIATE
+}
+
+// matchLDAPSimpleBind matches LDAP BindRequests using simple authentication with a password.
+func matchLDAPSimpleBind(p *transportPacket) (bool, bool) {
+	tag, message, _, ok := berElement(p.Payload)
+	if !ok || tag != 0x30 {
+		return false, false
+	}
+	tag, _, rest, ok := berElement(message) // messageID
+	if !ok || tag != 0x02 {
+		return false, false
+	}
+	tag, bind, _, ok := berElement(rest)
+	if !ok || tag != 0x60 { // [APPLICATION 0] BindRequest
+		return false, false
+	}
+	_, _, rest, ok = berElement(bind) // version
+	if !ok {
+		return false, false
+	}
+	_, _, rest, ok = berElement(rest) // name
+	if !ok {
+		return false, false
+	}
+	tag, password, _, ok := berElement(rest)
+	return true, ok && tag == 0x80 && len(password) > 0 // [0] simple; empty is an anonymous bind
+}
+
+// matchIMAPLogin matches IMAP LOGIN commands and cleartext SASL mechanisms.
+func matchIMAPLogin(p *transportPacket) (bool, bool) {
+	if p.DstPort != 143 {
+		return false, false
+	}
+	fields := strings.Fields(string(p.Payload[:min(len(p.Payload), 64)]))
+	if len(fields) < 2 {
+		return false, false
+	}
+	command := strings.ToUpper(fields[1])
+	if command == "LOGIN" {
+		return true, true
+	}
+	return true, command == "AUTHENTICATE" && len(fields) > 2 && (strings.EqualFold(fields[2], "PLAIN") || strings.EqualFold(fields[2], "LOGIN"))
+}
+
+// berElement splits the first BER/DER element off data, returning its tag and contents.
+func berElement(data []byte) (tag byte, content, rest []byte, ok bool) {
+	if len(data) < 2 {
+		return 0, nil, nil, false
+	}
+	tag, length, offset := data[0], int(data[1]), 2
+	if length&0x80 != 0 {
+		n := length & 0x7f
+		if n == 0 || n > 3 || len(data) < 2+n {
+			return 0, nil, nil, false
+		}
+		length = 0
+		for _, b := range data[2 : 2+n] {
+			length = length<<8 | int(b)
+		}
+		offset += n
+	}
+	if len(data) < offset+length {
+		return 0, nil, nil, false
+	}
+	return tag, data[offset : offset+length], data[offset+length:], true
+}
@@ -0,0 +1,127 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+)
+
+func smb1TestMessage(command byte, reply bool) []byte {
+	header := make([]byte, 32)
+	copy(header, smb1ProtocolID)
+	header[4] = command
+	if reply {
+		header[9] = 0x80
+	}
+	return append([]byte{0, 0, 0, byte(len(header))}, header...)
+}
+
+func ldapBindRequest(name, password string) []byte {
+	bind := append([]byte{0x02, 0x01, 0x03, 0x04, byte(len(name))}, name...)
+	bind = append(append(bind, 0x80, byte(len(password))), password...)
+	message := append([]byte{0x02, 0x01, 0x01, 0x60, byte(len(bind))}, bind...)
+	return append([]byte{0x30, byte(len(message))}, message...)
+}
+
+// TestInsecureProtocols verifies that clients and servers of cleartext protocols are flagged once
+// per rule with a pointer to the first matching packet, and that traffic without credentials or
+// using the secure variant of a protocol is not.
+func TestInsecureProtocols(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	networkMap, summary := model.NewNetworkMap(), model.NewPcapSummary()
+	packets := []gopacket.Packet{
+		tcpTestSegment(t, true, 40000, 513, 1, false, nil, start), // A SYN scan is not use of the protocol
+		tcpTestSegment(t, true, 40001, 23, 1, false, []byte("\xff\xfd\x18"), start),
+		tcpTestSegment(t, false, 40001, 23, 1, false, []byte("login: "), start),
+		tcpTestSegment(t, true, 40002, 8080, 1, false, []byte("GET /admin HTTP/1.1\r\nHost: nas\r\nAuthorization: Basic YWRtaW46YWRtaW4=\r\n\r\n"), start),
+		tcpTestSegment(t, true, 40003, 80, 1, false, []byte("GET / HTTP/1.1\r\nHost: nas\r\n\r\n"), start),
+		udpTestPacket(t, true, 40004, 161, append([]byte{0x30, 0x0b, 0x02, 0x01, 0x01, 0x04, 0x06}, "public"...), start),
+		udpTestPacket(t, true, 40005, 5355, make([]byte, 12), start),
+		tcpTestSegment(t, true, 40006, 139, 1, false, smb1TestMessage(0x72, false), start),
+		tcpTestSegment(t, false, 40007, 445, 1, false, smb1TestMessage(0x72, true), start),
+		tcpTestSegment(t, true, 40008, 389, 1, false, ldapBindRequest("cn=svc,dc=corp", "Winter2024"), start),
+		tcpTestSegment(t, true, 40009, 21, 1, false, []byte("AUTH TLS\r\n"), start),
+		tcpTestSegment(t, true, 40010, 110, 1, false, []byte("USER alice\r\n"), start),
+	}
+	for _, packet := range packets {
+		ProcessPacket(packet, networkMap, summary, "insecure.pcap")
+	}
+	client, server := networkMap.Hosts["02:00:00:00:00:05"], networkMap.Hosts["02:00:00:00:00:0A"]
+	if client == nil || server == nil {
+		t.Fatalf("Expected both hosts, got %v", networkMap.Hosts)
+	}
+
+	findings := func(host *model.Host) map[string]model.Vulnerability {
+		found := make(map[string]model.Vulnerability)
+		for _, vulns := range host.Findings {
+			for _, v := range vulns {
+				if _, dup := found[v.CVE]; dup {
+					t.Errorf("Duplicate %s finding on %s", v.CVE, host.MACAddress)
+				}
+				found[v.CVE] = v
+			}
+		}
+		return found
+	}
+	clientFindings, serverFindings := findings(client), findings(server)
+
+	for id, want := range map[string]struct {
+		category model.FindingCategory
+		port     int
+		packet   int
+	}{
+		"INSECURE-TELNET":           {model.CriticalFinding, 23, 2},
+		"INSECURE-HTTP-BASIC":       {model.CriticalFinding, 8080, 4},
+		"INSECURE-SNMP-V1V2C":       {model.PotentialFinding, 161, 6},
+		"INSECURE-SMBV1":            {model.CriticalFinding, 445, 9},
+		"INSECURE-LDAP-SIMPLE-BIND": {model.CriticalFinding, 389, 10},
+		"INSECURE-POP3":             {model.PotentialFinding, 110, 12},
+	} {
+		v, ok := serverFindings[id]
+		if !ok || v.Category != want.category || v.PortID != want.port || v.Source != insecureSource || v.EvidenceFile != "insecure.pcap" || v.EvidencePacket != want.packet {
+			t.Errorf("Unexpected server finding for %s: %+v", id, v)
+		}
+		if v, ok := clientFindings[id]; !ok || v.PortID != 0 || v.EvidencePacket != want.packet {
+			t.Errorf("Unexpected client finding for %s: %+v", id, v)
+		}
+	}
+	if v, ok := clientFindings["INSECURE-LLMNR"]; !ok || v.EvidencePacket != 7 {
+		t.Errorf("Expected the LLMNR query to be flagged on the client, got %+v", v)
+	}
+	if _, ok := serverFindings["INSECURE-LLMNR"]; ok {
+		t.Error("Expected LLMNR to be flagged on the querying host only")
+	}
+	for _, id := range []string{"INSECURE-R-SERVICES", "INSECURE-FTP"} {
+		if _, ok := serverFindings[id]; ok {
+			t.Errorf("Unexpected %s finding", id)
+		}
+	}
+	if got := serverFindings["INSECURE-TELNET"].Evidence(); got != "insecure.pcap, packet 2" {
+		t.Errorf("Unexpected evidence %q", got)
+	}
+	if len(clientFindings) != 7 || len(serverFindings) != 6 {
+		t.Errorf("Unexpected findings: client %v, server %v", clientFindings, serverFindings)
+	}
+}
+
+func TestInsecureProtocolMatchers(t *testing.T) {
+	for name, tc := range map[string]struct {
+		match func(*transportPacket) (bool, bool)
+		p     transportPacket
+		want  bool
+	}{
+		"anonymous LDAP bind": {matchLDAPSimpleBind, transportPacket{DstPort: 389, Payload: ldapBindRequest("", "")}, false},
+		"SNMPv3":              {matchSNMPCommunity, transportPacket{DstPort: 161, Payload: []byte{0x30, 0x05, 0x02, 0x01, 0x03, 0x04, 0x00}}, false},
+		"SNMPv1 trap":         {matchSNMPCommunity, transportPacket{DstPort: 162, Payload: []byte{0x30, 0x05, 0x02, 0x01, 0x00, 0x04, 0x00}}, true},
+		"SMB1 session setup":  {matchSMBv1, transportPacket{DstPort: 445, Payload: smb1TestMessage(0x73, false)}, true},
+		"IMAP LOGIN":          {matchIMAPLogin, transportPacket{DstPort: 143, Payload: []byte("a1 LOGIN alice secret\r\n")}, true},
+		"IMAP STARTTLS":       {matchIMAPLogin, transportPacket{DstPort: 143, Payload: []byte("a1 STARTTLS\r\n")}, false},
+		"NBT-NS response":     {matchNameQuery(137), transportPacket{DstPort: 137, Payload: append([]byte{0, 1, 0x85, 0}, make([]byte, 8)...)}, false},
+	} {
+		if _, got := tc.match(&tc.p); got != tc.want {
+			t.Errorf("%s: got %v, want %v", name, got, tc.want)
+		}
+	}
+}
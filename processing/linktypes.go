@@ -0,0 +1,192 @@
+package processing
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"net"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// Link types that gopacket has no decoder for. Link types are handled as the uint32 stored in the
+// capture file, because gopacket's layers.LinkType is a uint8 that cannot hold LINKTYPE_LINUX_SLL2.
+const (
+	linkTypeLinuxSLL2 uint32 = 276
+	linkTypePPI       uint32 = 192
+)
+
+// ARPHRD_* hardware types used in Linux cooked capture headers.
+const (
+	arphrdEther     = 1
+	arphrdIEEE80211 = 801
+)
+
+var (
+	LayerTypeLinuxSLL2 = gopacket.RegisterLayerType(2000, gopacket.LayerTypeMetadata{Name: "LinuxSLL2", Decoder: gopacket.DecodeFunc(decodeLinuxSLL2)})
+	LayerTypePPI       = gopacket.RegisterLayerType(2001, gopacket.LayerTypeMetadata{Name: "PPI", Decoder: gopacket.DecodeFunc(decodePPI)})
+)
+
+// LinkTypeDecoder returns the decoder for packets of a capture's link type. It adds Linux cooked
+// capture v2, PPI and the raw IPv4/IPv6 link types to the ones gopacket decodes itself.
+func LinkTypeDecoder(linkType uint32) gopacket.Decoder {
+	switch linkType {
+	case linkTypeLinuxSLL2:
+		return LayerTypeLinuxSLL2
+	case linkTypePPI:
+		return LayerTypePPI
+	case uint32(layers.LinkTypeIPv4):
+		return layers.LayerTypeIPv4
+	case uint32(layers.LinkTypeIPv6):
+		return layers.LayerTypeIPv6
+	}
+	if linkType > 0xff {
+		return gopacket.DecodeUnknown
+	}
+	return layers.LinkType(linkType)
+}
+
+// linkTypeName returns a readable name for a link type, including the ones decoded here.
+func linkTypeName(linkType uint32) string {
+	switch linkType {
+	case linkTypeLinuxSLL2:
+		return "Linux SLL2"
+	case linkTypePPI:
+		return "PPI"
+	case uint32(layers.LinkTypeIPv4):
+		return "Raw IPv4"
+	case uint32(layers.LinkTypeIPv6):
+		return "Raw IPv6"
+	}
+	if linkType > 0xff {
+		return fmt.Sprintf("link type %d", linkType)
+	}
+	return layers.LinkType(linkType).String()
+}
+
+// LinuxSLL2 is the Linux cooked capture v2 header, written by `tcpdump -i any` on recent libpcap.
+type LinuxSLL2 struct {
+	layers.BaseLayer
+	EthernetType   layers.EthernetType
+	InterfaceIndex uint32
+	ARPHRDType     uint16
+	PacketType     layers.LinuxSLLPacketType
+	Addr           net.HardwareAddr
+}
+
+func (sll *LinuxSLL2) LayerType() gopacket.LayerType { return LayerTypeLinuxSLL2 }
+
+func (sll *LinuxSLL2) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 20 {
+		return fmt.Errorf("Linux SLL2 header of %d bytes is too short", len(data))
+	}
+	sll.EthernetType = layers.EthernetType(binary.BigEndian.Uint16(data[0:2]))
+	sll.InterfaceIndex = binary.BigEndian.Uint32(data[4:8])
+	sll.ARPHRDType = binary.BigEndian.Uint16(data[8:10])
+	sll.PacketType = layers.LinuxSLLPacketType(data[10])
+	addrLen := int(data[11])
+	if addrLen > 8 {
+		addrLen = 8
+	}
+	sll.Addr = net.HardwareAddr(data[12 : 12+addrLen])
+	sll.BaseLayer = layers.BaseLayer{Contents: data[:20], Payload: data[20:]}
+	return nil
+}
+
+func decodeLinuxSLL2(data []byte, p gopacket.PacketBuilder) error {
+	sll := &LinuxSLL2{}
+	if err := sll.DecodeFromBytes(data, p); err != nil {
+		return err
+	}
+	p.AddLayer(sll)
+	return p.NextDecoder(sll.EthernetType)
+}
+
+// PPI is the Per-Packet Information header that some 802.11 capture tools wrap frames in.
+type PPI struct {
+	layers.BaseLayer
+	Version  uint8
+	Flags    uint8
+	LinkType layers.LinkType
+	FCS      bool // The captured 802.11 frame ends in a frame check sequence
+}
+
+func (ppi *PPI) LayerType() gopacket.LayerType { return LayerTypePPI }
+
+func (ppi *PPI) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	if len(data) < 8 {
+		return fmt.Errorf("PPI header of %d bytes is too short", len(data))
+	}
+	headerLen := int(binary.LittleEndian.Uint16(data[2:4]))
+	if headerLen < 8 || headerLen > len(data) {
+		return fmt.Errorf("invalid PPI header length %d", headerLen)
+	}
+	ppi.Version = data[0]
+	ppi.Flags = data[1]
+	ppi.LinkType = layers.LinkType(binary.LittleEndian.Uint32(data[4:8]))
+
+	// The 802.11-common field (type 2) carries a flag telling whether the frame includes its FCS.
+	ppi.FCS = false
+	for fields := data[8:headerLen]; len(fields) >= 4; {
+		fieldType := binary.LittleEndian.Uint16(fields[0:2])
+		fieldLen := int(binary.LittleEndian.Uint16(fields[2:4]))
+		if 4+fieldLen > len(fields) {
+			break
+		}
+		if fieldType == 2 && fieldLen >= 10 {
+			ppi.FCS = binary.LittleEndian.Uint16(fields[4+8:4+10])&0x0001 != 0
+		}
+		fields = fields[4+fieldLen:]
+	}
+
+	payload := data[headerLen:]
+	if ppi.LinkType == layers.LinkTypeIEEE802_11 && !ppi.FCS {
+		// gopacket's 802.11 decoder always strips a trailing FCS, so supply one as its radiotap
+		// decoder does.
+		withFCS := make([]byte, len(payload)+4)
+		copy(withFCS, payload)
+		binary.LittleEndian.PutUint32(withFCS[len(payload):], crc32.ChecksumIEEE(payload))
+		payload = withFCS
+	}
+	ppi.BaseLayer = layers.BaseLayer{Contents: data[:headerLen], Payload: payload}
+	return nil
+}
+
+func decodePPI(data []byte, p gopacket.PacketBuilder) error {
+	ppi := &PPI{}
+	if err := ppi.DecodeFromBytes(data, p); err != nil {
+		return err
+	}
+	p.AddLayer(ppi)
+	return p.NextDecoder(ppi.LinkType)
+}
+
+// cookedCaptureAddress returns the sender's MAC address from a Linux cooked capture header
+// (SLL or SLL2), or "" when the packet has none or it is not an Ethernet/802.11 address.
+func cookedCaptureAddress(packet gopacket.Packet) string {
+	var addr net.HardwareAddr
+	var hwType uint16
+	if sllLayer := packet.Layer(layers.LayerTypeLinuxSLL); sllLayer != nil {
+		sll, _ := sllLayer.(*layers.LinuxSLL)
+		addr, hwType = sll.Addr, sll.AddrType
+	} else if sllLayer := packet.Layer(LayerTypeLinuxSLL2); sllLayer != nil {
+		sll, _ := sllLayer.(*LinuxSLL2)
+		addr, hwType = sll.Addr, sll.ARPHRDType
+	} else {
+		return ""
+	}
+	if (hwType != arphrdEther && hwType != arphrdIEEE80211) || len(addr) != 6 || isZeroMAC(addr) {
+		return ""
+	}
+	return addr.String()
+}
+
+func isZeroMAC(addr net.HardwareAddr) bool {
+	for _, b := range addr {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
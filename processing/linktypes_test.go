@@ -0,0 +1,162 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+var linkTestMAC = net.HardwareAddr{0x02, 0x42, 0xac, 0x11, 0x00, 0x07}
+
+// sllHeader builds a Linux cooked capture (v1) header for an IPv4 packet sent by linkTestMAC.
+func sllHeader() []byte {
+	h := make([]byte, 16)
+	binary.BigEndian.PutUint16(h[0:2], 4) // Outgoing
+	binary.BigEndian.PutUint16(h[2:4], arphrdEther)
+	binary.BigEndian.PutUint16(h[4:6], 6)
+	copy(h[6:14], linkTestMAC)
+	binary.BigEndian.PutUint16(h[14:16], uint16(layers.EthernetTypeIPv4))
+	return h
+}
+
+// sll2Header builds a Linux cooked capture v2 header for an IPv4 packet sent by linkTestMAC.
+func sll2Header() []byte {
+	h := make([]byte, 20)
+	binary.BigEndian.PutUint16(h[0:2], uint16(layers.EthernetTypeIPv4))
+	binary.BigEndian.PutUint32(h[4:8], 3)
+	binary.BigEndian.PutUint16(h[8:10], arphrdEther)
+	h[10] = 4 // Outgoing
+	h[11] = 6
+	copy(h[12:18], linkTestMAC)
+	return h
+}
+
+// ppiDot11Frame wraps an 802.11 to-DS data frame from linkTestMAC in a PPI header.
+func ppiDot11Frame(t *testing.T) []byte {
+	t.Helper()
+	dot11 := &layers.Dot11{
+		Type:     layers.Dot11TypeData,
+		Flags:    layers.Dot11FlagsToDS,
+		Address1: net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55},
+		Address2: linkTestMAC,
+		Address3: net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x66},
+	}
+	llc := &layers.LLC{DSAP: 0xaa, SSAP: 0xaa, Control: 3}
+	snap := &layers.SNAP{OrganizationalCode: []byte{0, 0, 0}, Type: layers.EthernetTypeIPv4}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{}, dot11, llc, snap, gopacket.Payload(testFrame(t, true))); err != nil {
+		t.Fatalf("could not build 802.11 frame: %v", err)
+	}
+
+	header := make([]byte, 8)
+	binary.LittleEndian.PutUint16(header[2:4], 8)
+	binary.LittleEndian.PutUint32(header[4:8], uint32(layers.LinkTypeIEEE802_11))
+	return append(header, buf.Bytes()...)
+}
+
+// TestProcessPacketLinkTypes verifies that host identity is resolved for cooked, PPI-wrapped
+// 802.11 and raw IP captures, and that hosts without a link-layer address are keyed by IP.
+func TestProcessPacketLinkTypes(t *testing.T) {
+	testCases := []struct {
+		name     string
+		linkType uint32
+		data     []byte
+		wantKey  string
+	}{
+		{"Linux SLL", uint32(layers.LinkTypeLinuxSLL), append(sllHeader(), testFrame(t, true)...), "02:42:AC:11:00:07"},
+		{"Linux SLL2", linkTypeLinuxSLL2, append(sll2Header(), testFrame(t, true)...), "02:42:AC:11:00:07"},
+		{"PPI 802.11", linkTypePPI, ppiDot11Frame(t), "02:42:AC:11:00:07"},
+		{"Raw IP", uint32(layers.LinkTypeRaw), testFrame(t, true), "IP:10.9.9.1"},
+		{"Raw IPv4", uint32(layers.LinkTypeIPv4), testFrame(t, true), "IP:10.9.9.1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			networkMap, summary := model.NewNetworkMap(), model.NewPcapSummary()
+			packet := gopacket.NewPacket(tc.data, LinkTypeDecoder(tc.linkType), gopacket.Default)
+			ProcessPacket(packet, networkMap, summary, "test.pcap")
+
+			host, ok := networkMap.Hosts[tc.wantKey]
+			if !ok {
+				t.Fatalf("Expected host %s, got %v (layers %v)", tc.wantKey, networkMap.Hosts, packet.Layers())
+			}
+			if !host.IPv4Addresses["10.9.9.1"] || host.Communications["10.9.9.2"] == nil {
+				t.Errorf("Expected 10.9.9.1 talking to 10.9.9.2, got %v / %v", host.IPv4Addresses, host.Communications)
+			}
+		})
+	}
+}
+
+// TestPacketPlaceholderAdoptedByMAC verifies that an IP placeholder host from a raw capture is
+// merged into the MAC-keyed host once a frame reveals the address's MAC.
+func TestPacketPlaceholderAdoptedByMAC(t *testing.T) {
+	networkMap, summary := model.NewNetworkMap(), model.NewPcapSummary()
+	for _, p := range []gopacket.Packet{
+		gopacket.NewPacket(testFrame(t, true), LinkTypeDecoder(uint32(layers.LinkTypeRaw)), gopacket.Default),
+		gopacket.NewPacket(testFrame(t, false), LinkTypeDecoder(uint32(layers.LinkTypeEthernet)), gopacket.Default),
+	} {
+		ProcessPacket(p, networkMap, summary, "test.pcap")
+	}
+
+	if _, ok := networkMap.Hosts["IP:10.9.9.1"]; ok {
+		t.Error("Expected the IP placeholder to be merged away")
+	}
+	host, ok := networkMap.Hosts["00:0C:29:AA:BB:01"]
+	if !ok {
+		t.Fatalf("Expected the MAC-keyed host, got %v", networkMap.Hosts)
+	}
+	if comm := host.Communications["10.9.9.2"]; comm == nil || comm.PacketCount != 2 {
+		t.Errorf("Expected both packets counted on the MAC-keyed host, got %+v", comm)
+	}
+}
+
+// TestSLL2CaptureFiles verifies that LINKTYPE_LINUX_SLL2 (276) is recognised from the value stored
+// in pcap and pcapng files, and that link type 20 (276&0xff) is not mistaken for it.
+func TestSLL2CaptureFiles(t *testing.T) {
+	data := append(sll2Header(), testFrame(t, true)...)
+	ci := gopacket.CaptureInfo{Timestamp: time.Unix(1700000000, 0), CaptureLength: len(data), Length: len(data)}
+
+	var pcapFile bytes.Buffer
+	w := pcapgo.NewWriter(&pcapFile)
+	w.WriteFileHeader(65535, layers.LinkTypeNull)
+	w.WritePacket(ci, data)
+	capture := pcapFile.Bytes()
+	binary.LittleEndian.PutUint32(capture[20:24], linkTypeLinuxSLL2)
+
+	var ngFile bytes.Buffer
+	ng, err := pcapgo.NewNgWriterInterface(&ngFile, pcapgo.NgInterface{Name: "any", LinkType: layers.LinkTypeNull, TimestampResolution: 9}, pcapgo.NgWriterOptions{})
+	if err != nil {
+		t.Fatalf("could not create pcapng writer: %v", err)
+	}
+	ng.WritePacket(ci, data)
+	ng.Flush()
+	ngCapture := ngFile.Bytes()
+	idb := binary.LittleEndian.Uint32(ngCapture[4:8]) // The interface block follows the section header
+	binary.LittleEndian.PutUint16(ngCapture[idb+8:idb+10], uint16(linkTypeLinuxSLL2))
+
+	dir := t.TempDir()
+	for name, content := range map[string][]byte{"any.pcap": capture, "any.pcapng": ngCapture} {
+		networkMap, summary := model.NewNetworkMap(), model.NewPcapSummary()
+		if err := EnrichData(writeTempBytes(t, dir, name, content), networkMap, summary); err != nil {
+			t.Fatalf("%s: EnrichData failed: %v", name, err)
+		}
+		if _, ok := networkMap.Hosts["02:42:AC:11:00:07"]; !ok {
+			t.Errorf("%s: expected the SLL2 sender, got %v", name, networkMap.Hosts)
+		}
+		if name == "any.pcapng" && !strings.Contains(networkMap.ScanRuns[0].Summary, "any (Linux SLL2)") {
+			t.Errorf("%s: expected the interface's link type in the summary, got %q", name, networkMap.ScanRuns[0].Summary)
+		}
+	}
+
+	if LinkTypeDecoder(276&0xff) == LayerTypeLinuxSLL2 {
+		t.Error("Link type 20 must not decode as Linux SLL2")
+	}
+}
@@ -0,0 +1,214 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"strings"
+)
+
+// discoveryRank orders the sources a host can be discovered by, so merging keeps the most
+// detailed one regardless of the order in which files were processed.
+var discoveryRank = map[string]int{"Nmap": 3, "Masscan": 2, "RustScan": 2, "Naabu": 2, "Nessus": 1, "OpenVAS": 1}
+
+// MergeNetworkMaps merges all hosts and scan runs of src into dst. Hosts are matched by key
+// first; IP placeholder hosts ("IP:<addr>") are matched against hosts that own the address.
+func MergeNetworkMaps(dst, src *model.NetworkMap) {
+	for key, host := range src.Hosts {
+		target := dst.Hosts[key]
+		if target == nil && strings.HasPrefix(key, "IP:") {
+			target = findHostByIP(dst, strings.TrimPrefix(key, "IP:"))
+		}
+		if target == nil && !strings.HasPrefix(key, "IP:") {
+			// A host with a real MAC absorbs any placeholder created for one of its IPs.
+			for ip := range host.IPv4Addresses {
+				if placeholder, ok := dst.Hosts["IP:"+ip]; ok {
+					delete(dst.Hosts, "IP:"+ip)
+					mergeHost(host, placeholder)
+				}
+			}
+		}
+		if target == nil {
+			dst.Hosts[key] = host
+			continue
+		}
+		mergeHost(target, host)
+	}
+	dst.ScanRuns = append(dst.ScanRuns, src.ScanRuns...)
+}
+
+// mergeHost copies everything src knows about a host into dst without discarding details dst
+// already holds.
+func mergeHost(dst, src *model.Host) {
+	for ip := range src.IPv4Addresses {
+		dst.IPv4Addresses[ip] = true
+	}
+	if dst.Status == "" || src.Status == "up" {
+		dst.Status = src.Status
+	}
+	if discoveryRank[src.DiscoveredBy] > discoveryRank[dst.DiscoveredBy] || dst.DiscoveredBy == "" {
+		dst.DiscoveredBy = src.DiscoveredBy
+	}
+
+	if src.Fingerprint != nil {
+		if dst.Fingerprint == nil {
+			dst.Fingerprint = &model.Fingerprint{BehavioralClues: make(map[string]bool)}
+		}
+		if dst.Fingerprint.OperatingSystem == "" {
+			dst.Fingerprint.OperatingSystem = src.Fingerprint.OperatingSystem
+		}
+		if dst.Fingerprint.DeviceType == "" {
+			dst.Fingerprint.DeviceType = src.Fingerprint.DeviceType
+		}
+		if dst.Fingerprint.Vendor == "" {
+			dst.Fingerprint.Vendor = src.Fingerprint.Vendor
+		}
+		if len(dst.Fingerprint.OSMatches) == 0 {
+			dst.Fingerprint.OSMatches = src.Fingerprint.OSMatches
+		}
+		for clue := range src.Fingerprint.BehavioralClues {
+			dst.Fingerprint.BehavioralClues[clue] = true
+		}
+	}
+
+	for id, port := range src.Ports {
+		existing, ok := dst.Ports[id]
+		if !ok || portDetail(port) >= portDetail(existing) {
+			dst.Ports[id] = port
+		}
+	}
+	for category, findings := range src.Findings {
+		dst.Findings[category] = append(dst.Findings[category], findings...)
+	}
+	for ip, comm := range src.Communications {
+		if existing, ok := dst.Communications[ip]; ok {
+			existing.PacketCount += comm.PacketCount
+			if existing.Geo == nil {
+				existing.Geo = comm.Geo
+			}
+		} else {
+			dst.Communications[ip] = comm
+		}
+	}
+	for domain := range src.DNSLookups {
+		dst.DNSLookups[domain] = true
+	}
+	for name, nameType := range src.Hostnames {
+		dst.Hostnames[name] = nameType
+	}
+	for key, session := range src.TLSSessions {
+		existing, ok := dst.TLSSessions[key]
+		if !ok {
+			if dst.TLSSessions == nil {
+				dst.TLSSessions = make(map[string]*model.TLSSession)
+			}
+			dst.TLSSessions[key] = session
+			continue
+		}
+		existing.Connections += session.Connections
+		seen(&existing.FirstSeen, &existing.LastSeen, session.FirstSeen)
+		seen(&existing.FirstSeen, &existing.LastSeen, session.LastSeen)
+		if existing.Version == "" {
+			existing.Version, existing.Cipher, existing.JA3S = session.Version, session.Cipher, session.JA3S
+		}
+		if existing.Certificate == nil {
+			existing.Certificate = session.Certificate
+		}
+	}
+
+	for key, answer := range src.DNSAnswers {
+		existing, ok := dst.DNSAnswers[key]
+		if !ok {
+			if dst.DNSAnswers == nil {
+				dst.DNSAnswers = make(map[string]*model.DNSAnswer)
+			}
+			dst.DNSAnswers[key] = answer
+			continue
+		}
+		existing.Count += answer.Count
+		if answer.LastSeen.After(existing.LastSeen) {
+			existing.TTL, existing.ResolverIP = answer.TTL, answer.ResolverIP
+		}
+		seen(&existing.FirstSeen, &existing.LastSeen, answer.FirstSeen)
+		seen(&existing.FirstSeen, &existing.LastSeen, answer.LastSeen)
+	}
+	for key, resolver := range src.DNSResolvers {
+		existing, ok := dst.DNSResolvers[key]
+		if !ok {
+			if dst.DNSResolvers == nil {
+				dst.DNSResolvers = make(map[string]*model.DNSResolver)
+			}
+			dst.DNSResolvers[key] = resolver
+			continue
+		}
+		if timed := existing.TimedResponses + resolver.TimedResponses; timed > 0 {
+			existing.AvgResponseMs = (existing.AvgResponseMs*float64(existing.TimedResponses) + resolver.AvgResponseMs*float64(resolver.TimedResponses)) / float64(timed)
+			existing.TimedResponses = timed
+		}
+		existing.Queries += resolver.Queries
+		existing.Responses += resolver.Responses
+		existing.NXDomain += resolver.NXDomain
+		existing.Connections += resolver.Connections
+		if existing.Hostname == "" {
+			existing.Hostname = resolver.Hostname
+		}
+		seen(&existing.FirstSeen, &existing.LastSeen, resolver.FirstSeen)
+		seen(&existing.FirstSeen, &existing.LastSeen, resolver.LastSeen)
+	}
+
+	for key, op := range src.OTOperations {
+		existing, ok := dst.OTOperations[key]
+		if !ok {
+			if dst.OTOperations == nil {
+				dst.OTOperations = make(map[string]*model.OTOperation)
+			}
+			dst.OTOperations[key] = op
+			continue
+		}
+		existing.Count += op.Count
+		seen(&existing.FirstSeen, &existing.LastSeen, op.FirstSeen)
+		seen(&existing.FirstSeen, &existing.LastSeen, op.LastSeen)
+	}
+	for protocol, identity := range src.OTIdentities {
+		if dst.OTIdentities == nil {
+			dst.OTIdentities = make(map[string]string)
+		}
+		dst.OTIdentities[protocol] = identity
+	}
+
+	if dst.Uptime == nil {
+		dst.Uptime = src.Uptime
+	}
+	if dst.Distance == 0 {
+		dst.Distance = src.Distance
+	}
+	if dst.Timing == nil {
+		dst.Timing = src.Timing
+	}
+	if len(dst.Traceroute) == 0 {
+		dst.Traceroute = src.Traceroute
+	}
+	if dst.Wifi == nil || (dst.Wifi.DeviceRole == "" && src.Wifi != nil) {
+		dst.Wifi = src.Wifi
+	}
+
+	dst.WebResponses = append(dst.WebResponses, src.WebResponses...)
+	dst.Screenshots = append(dst.Screenshots, src.Screenshots...)
+	dst.FTPResults = append(dst.FTPResults, src.FTPResults...)
+	dst.SSHResults = append(dst.SSHResults, src.SSHResults...)
+	dst.SMBResults = append(dst.SMBResults, src.SMBResults...)
+	dst.HTTPRequests = append(dst.HTTPRequests, src.HTTPRequests...)
+}
+
+// portDetail scores how much service information a port entry carries.
+func portDetail(port model.Port) int {
+	score := 0
+	if port.Service != "" {
+		score++
+	}
+	if strings.TrimSpace(port.Version) != "" {
+		score++
+	}
+	if len(port.CPEs) > 0 {
+		score++
+	}
+	return score
+}
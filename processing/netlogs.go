@@ -0,0 +1,178 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// established TCP connection states in Zeek's conn.log; the responder port was open.
+var zeekEstablishedStates = map[string]bool{"SF": true, "S1": true, "S2": true, "S3": true, "RSTO": true, "RSTR": true}
+
+// ProcessNetworkLogFile ingests a Zeek log (TSV or JSON) or a Suricata eve.json file. It adds
+// hosts, communications, DNS lookups, hostnames and credentials the same way pcap processing
+// does, so these logs can stand in for raw captures.
+func ProcessNetworkLogFile(filename string, networkMap *model.NetworkMap, summary *model.PcapSummary) error {
+	fileType, err := DetectFileType(filename)
+	if err != nil {
+		return err
+	}
+	switch fileType {
+	case FileTypeZeekTSV, FileTypeZeekJSON:
+		return processZeekFile(filename, fileType, networkMap, summary)
+	case FileTypeSuricataEVE:
+		return processSuricataFile(filename, networkMap, summary)
+	}
+	return fmt.Errorf("unrecognised network log format in %s", filename)
+}
+
+// logHost returns the host for a local IP seen in a network log, creating an IP placeholder
+// host if needed. Remote addresses are not tracked as hosts, matching pcap processing.
+func logHost(networkMap *model.NetworkMap, ip, source string) *model.Host {
+	if !isPrivateIP(net.ParseIP(ip)) {
+		return nil
+	}
+	host := findHostByIP(networkMap, ip)
+	if host == nil {
+		host = model.NewHost("IP:" + ip)
+		host.DiscoveredBy = source
+		networkMap.Hosts[host.MACAddress] = host
+	}
+	host.IPv4Addresses[ip] = true
+	return host
+}
+
+// localAndRemote picks the local side of a connection, preferring the originator as pcap
+// processing does. It returns an empty local IP when neither side is local.
+func localAndRemote(srcIP, dstIP string) (localIP, remoteIP string) {
+	if isPrivateIP(net.ParseIP(srcIP)) {
+		return srcIP, dstIP
+	}
+	if isPrivateIP(net.ParseIP(dstIP)) {
+		return dstIP, srcIP
+	}
+	return "", ""
+}
+
+// addLogFlow records a connection between two endpoints.
+func addLogFlow(networkMap *model.NetworkMap, srcIP, dstIP string, packets int, source string) {
+	localIP, remoteIP := localAndRemote(srcIP, dstIP)
+	if localIP == "" || remoteIP == "" {
+		return
+	}
+	host := logHost(networkMap, localIP, source)
+	if _, ok := host.Communications[remoteIP]; !ok {
+		host.Communications[remoteIP] = &model.Communication{CounterpartIP: remoteIP}
+	}
+	if packets < 1 {
+		packets = 1
+	}
+	host.Communications[remoteIP].PacketCount += packets
+}
+
+// addLogOpenPort records a port that accepted a connection on a local responder.
+func addLogOpenPort(networkMap *model.NetworkMap, ip string, port int, protocol, service, source string) {
+	host := logHost(networkMap, ip, source)
+	if host == nil || port <= 0 {
+		return
+	}
+	if existing, ok := host.Ports[port]; ok && existing.Service != "" {
+		return
+	}
+	// Zeek reports a comma-separated guess list (e.g. "ssl,http"); the first entry is the best one.
+	service, _, _ = strings.Cut(service, ",")
+	host.Ports[port] = model.Port{ID: port, Protocol: strings.ToLower(protocol), State: "open", Service: service}
+}
+
+// addLogDNSQuery records a DNS lookup by a client, with the addresses it resolved to as answers
+// from the resolver, and names the local hosts it resolved to.
+func addLogDNSQuery(networkMap *model.NetworkMap, clientIP, resolverIP, query string, answers []string, source string) {
+	if query == "" {
+		return
+	}
+	client := logHost(networkMap, clientIP, source)
+	if client != nil {
+		client.DNSLookups[query] = true
+	}
+	for _, answer := range answers {
+		ip := net.ParseIP(answer)
+		if ip == nil {
+			continue
+		}
+		if client != nil {
+			record := &model.DNSAnswer{Name: query, Type: "A", Answer: ip.String(), ResolverIP: resolverIP}
+			if ip.To4() == nil {
+				record.Type = "AAAA"
+			}
+			if existing, ok := client.DNSAnswers[record.Key()]; ok {
+				record = existing
+			} else {
+				client.DNSAnswers[record.Key()] = record
+			}
+			record.Count++
+		}
+		if resolved := logHost(networkMap, answer, source); resolved != nil {
+			resolved.Hostnames[query] = "DNS"
+		}
+	}
+}
+
+// addLogHostname names a local server after the hostname a client used to reach it.
+func addLogHostname(networkMap *model.NetworkMap, serverIP, hostname, nameType, source string) {
+	hostname, _, _ = strings.Cut(hostname, ":") // Strip any port from HTTP Host headers
+	if hostname == "" || net.ParseIP(hostname) != nil {
+		return
+	}
+	if server := logHost(networkMap, serverIP, source); server != nil {
+		server.Hostnames[hostname] = nameType
+	}
+}
+
+// addLogDHCPLease ties an IP address to the MAC of the client it was leased to.
+func addLogDHCPLease(networkMap *model.NetworkMap, mac, ip, hostname, source string) {
+	if mac == "" || ip == "" || ip == "0.0.0.0" {
+		return
+	}
+	host := findOrCreateScannedHost(networkMap, mac, ip, source)
+	if host != nil && hostname != "" {
+		host.Hostnames[hostname] = "DHCP"
+	}
+}
+
+// addLogCredential records a secret seen in a log against the local client host.
+func addLogCredential(networkMap *model.NetworkMap, summary *model.PcapSummary, clientIP, serverIP, credType, value, logFile, source string) {
+	host := logHost(networkMap, clientIP, source)
+	if host == nil || value == "" {
+		return
+	}
+	summary.Credentials = append(summary.Credentials, model.Credential{
+		HostMAC:  host.MACAddress,
+		Endpoint: serverIP,
+		Type:     credType,
+		Value:    value,
+		PcapFile: logFile,
+	})
+}
+
+// ResolveCredentialHosts re-points credentials and carved files recorded against an IP
+// placeholder host to the host that now owns the address, for placeholders that were re-keyed
+// or merged by MAC later on.
+func ResolveCredentialHosts(networkMap *model.NetworkMap, summary *model.PcapSummary) {
+	for i, cred := range summary.Credentials {
+		summary.Credentials[i].HostMAC = resolvePlaceholderMAC(networkMap, cred.HostMAC)
+	}
+	for i, file := range summary.CarvedFiles {
+		summary.CarvedFiles[i].HostMAC = resolvePlaceholderMAC(networkMap, file.HostMAC)
+	}
+}
+
+func resolvePlaceholderMAC(networkMap *model.NetworkMap, mac string) string {
+	if _, ok := networkMap.Hosts[mac]; ok || !strings.HasPrefix(mac, "IP:") {
+		return mac
+	}
+	if host := findHostByIP(networkMap, strings.TrimPrefix(mac, "IP:")); host != nil {
+		return host.MACAddress
+	}
+	return mac
+}
@@ -0,0 +1,144 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"strings"
+	"testing"
+)
+
+// TestProcessZeekTSVLogs verifies conn, dns, http and dhcp logs in Zeek's default TSV format.
+func TestProcessZeekTSVLogs(t *testing.T) {
+	header := func(path, fields string) string {
+		return "#separator \\x09\n#set_separator\t,\n#empty_field\t(empty)\n#unset_field\t-\n#path\t" + path + "\n#fields\t" + strings.ReplaceAll(fields, " ", "\t") + "\n"
+	}
+	row := func(values string) string { return strings.ReplaceAll(values, " ", "\t") + "\n" }
+
+	conn := header("conn", "ts uid id.orig_h id.orig_p id.resp_h id.resp_p proto service conn_state orig_pkts resp_pkts") +
+		row("1700000000.0 C1 192.168.1.50 51000 93.184.216.34 443 tcp ssl SF 10 12") +
+		row("1700000001.0 C2 192.168.1.50 51001 192.168.1.10 445 tcp - SF 5 5") +
+		row("1700000002.0 C3 192.168.1.50 51002 192.168.1.10 3389 tcp - REJ 1 1")
+	dns := header("dns", "ts uid id.orig_h id.orig_p id.resp_h id.resp_p query qtype_name answers") +
+		row("1700000000.0 D1 192.168.1.50 5353 192.168.1.1 53 fileserver.corp.local A 192.168.1.10") +
+		row("1700000001.0 D2 192.168.1.50 5354 192.168.1.1 53 example.com A 93.184.216.34")
+	http := header("http", "ts uid id.orig_h id.orig_p id.resp_h id.resp_p method host uri username password") +
+		row("1700000000.0 H1 192.168.1.50 51003 192.168.1.10 80 GET intranet.corp.local /admin admin s3cret")
+	dhcp := header("dhcp", "ts uids client_addr server_addr mac host_name assigned_addr") +
+		row("1700000000.0 U1 - 192.168.1.1 00:0c:29:11:22:33 WKS-050 192.168.1.50")
+
+	networkMap, summary := model.NewNetworkMap(), model.NewPcapSummary()
+	for name, content := range map[string]string{"conn.log": conn, "dns.log": dns, "http.log": http, "dhcp.log": dhcp} {
+		path := writeTempFile(t, name, content)
+		if got, _ := DetectFileType(path); got != FileTypeZeekTSV {
+			t.Fatalf("%s: expected Zeek TSV detection, got %q", name, got)
+		}
+		if err := ProcessNetworkLogFile(path, networkMap, summary); err != nil {
+			t.Fatalf("%s: ProcessNetworkLogFile failed: %v", name, err)
+		}
+	}
+	ResolveCredentialHosts(networkMap, summary)
+
+	client, ok := networkMap.Hosts["00:0C:29:11:22:33"]
+	if !ok {
+		t.Fatalf("Expected the client to be keyed by its DHCP MAC, got %v", networkMap.Hosts)
+	}
+	if client.Hostnames["WKS-050"] != "DHCP" {
+		t.Errorf("Expected DHCP hostname, got %v", client.Hostnames)
+	}
+	if comm := client.Communications["93.184.216.34"]; comm == nil || comm.PacketCount != 22 {
+		t.Errorf("Expected 22 packets to 93.184.216.34, got %+v", comm)
+	}
+	if !client.DNSLookups["fileserver.corp.local"] || !client.DNSLookups["example.com"] {
+		t.Errorf("Expected DNS lookups, got %v", client.DNSLookups)
+	}
+
+	server, ok := networkMap.Hosts["IP:192.168.1.10"]
+	if !ok {
+		t.Fatalf("Expected placeholder host for 192.168.1.10")
+	}
+	if _, ok := server.Ports[445]; !ok {
+		t.Error("Expected port 445 from an established connection")
+	}
+	if _, ok := server.Ports[3389]; ok {
+		t.Error("Rejected connection should not mark port 3389 as open")
+	}
+	if server.Hostnames["fileserver.corp.local"] != "DNS" || server.Hostnames["intranet.corp.local"] != "HTTP" {
+		t.Errorf("Expected hostnames from DNS and HTTP, got %v", server.Hostnames)
+	}
+
+	if len(summary.Credentials) != 1 || summary.Credentials[0].Value != "admin:s3cret" || summary.Credentials[0].HostMAC != "00:0C:29:11:22:33" {
+		t.Errorf("Expected the basic auth credential on the client host, got %+v", summary.Credentials)
+	}
+}
+
+// TestProcessZeekJSONLog verifies Zeek's JSON log format, where the log type is inferred from the fields.
+func TestProcessZeekJSONLog(t *testing.T) {
+	content := `{"ts":1700000000.0,"uid":"C1","id.orig_h":"10.1.1.5","id.orig_p":50000,"id.resp_h":"10.1.1.20","id.resp_p":22,"proto":"tcp","service":"ssh","conn_state":"SF","orig_pkts":3,"resp_pkts":4}
+{"ts":1700000001.0,"uid":"C2","id.orig_h":"10.1.1.5","id.orig_p":50001,"id.resp_h":"10.1.1.20","id.resp_p":443,"version":"TLSv12","cipher":"TLS_AES_128_GCM_SHA256","server_name":"vault.corp.local"}
+`
+	path := writeTempFile(t, "zeek.json", content)
+	if got, _ := DetectFileType(path); got != FileTypeZeekJSON {
+		t.Fatalf("Expected Zeek JSON detection, got %q", got)
+	}
+
+	networkMap, summary := model.NewNetworkMap(), model.NewPcapSummary()
+	if err := ProcessNetworkLogFile(path, networkMap, summary); err != nil {
+		t.Fatalf("ProcessNetworkLogFile failed: %v", err)
+	}
+
+	server := networkMap.Hosts["IP:10.1.1.20"]
+	if server == nil {
+		t.Fatal("Expected placeholder host for 10.1.1.20")
+	}
+	if server.Ports[22].Service != "ssh" {
+		t.Errorf("Expected ssh on port 22, got %+v", server.Ports[22])
+	}
+	if server.Hostnames["vault.corp.local"] != "TLS SNI" {
+		t.Errorf("Expected SNI hostname, got %v", server.Hostnames)
+	}
+	if comm := networkMap.Hosts["IP:10.1.1.5"].Communications["10.1.1.20"]; comm == nil || comm.PacketCount != 7 {
+		t.Errorf("Expected 7 packets between the hosts, got %+v", comm)
+	}
+}
+
+// TestProcessSuricataEVE verifies that flows, DNS, DHCP and alerts from eve.json populate the model.
+func TestProcessSuricataEVE(t *testing.T) {
+	content := `{"timestamp":"2024-01-01T00:00:00.000000+0000","event_type":"dhcp","src_ip":"192.168.5.1","dest_ip":"192.168.5.30","dhcp":{"type":"reply","client_mac":"aa:bb:cc:dd:ee:ff","assigned_ip":"192.168.5.30","hostname":"laptop-7"}}
+{"timestamp":"2024-01-01T00:00:01.000000+0000","event_type":"flow","src_ip":"192.168.5.30","src_port":40000,"dest_ip":"8.8.8.8","dest_port":53,"proto":"UDP","flow":{"pkts_toserver":2,"pkts_toclient":2,"state":"established"}}
+{"timestamp":"2024-01-01T00:00:02.000000+0000","event_type":"dns","src_ip":"192.168.5.30","src_port":40000,"dest_ip":"8.8.8.8","dest_port":53,"proto":"UDP","dns":{"type":"query","rrname":"updates.example.org","rrtype":"A"}}
+{"timestamp":"2024-01-01T00:00:03.000000+0000","event_type":"alert","src_ip":"203.0.113.9","src_port":55555,"dest_ip":"192.168.5.40","dest_port":8080,"proto":"TCP","alert":{"action":"allowed","signature_id":2034647,"signature":"ET EXPLOIT Apache log4j RCE Attempt (CVE-2021-44228)","category":"Attempted Administrator Privilege Gain","severity":1,"metadata":{"cve":["CVE_2021_44228"]}}}
+{"timestamp":"2024-01-01T00:00:04.000000+0000","event_type":"alert","src_ip":"203.0.113.9","src_port":55556,"dest_ip":"192.168.5.40","dest_port":8080,"proto":"TCP","alert":{"action":"allowed","signature_id":2034647,"signature":"ET EXPLOIT Apache log4j RCE Attempt (CVE-2021-44228)","category":"Attempted Administrator Privilege Gain","severity":1}}
+{"timestamp":"2024-01-01T00:00:05.000000+0000","event_type":"alert","src_ip":"192.168.5.30","src_port":41000,"dest_ip":"198.51.100.7","dest_port":443,"proto":"TCP","alert":{"action":"allowed","signature_id":2027863,"signature":"ET POLICY Observed DNS Query to .onion proxy Domain","category":"Potentially Bad Traffic","severity":2}}
+`
+	path := writeTempFile(t, "eve.json", content)
+	if got, _ := DetectFileType(path); got != FileTypeSuricataEVE {
+		t.Fatalf("Expected Suricata detection, got %q", got)
+	}
+
+	networkMap, summary := model.NewNetworkMap(), model.NewPcapSummary()
+	if err := ProcessNetworkLogFile(path, networkMap, summary); err != nil {
+		t.Fatalf("ProcessNetworkLogFile failed: %v", err)
+	}
+
+	laptop, ok := networkMap.Hosts["AA:BB:CC:DD:EE:FF"]
+	if !ok {
+		t.Fatalf("Expected the DHCP client keyed by MAC, got %v", networkMap.Hosts)
+	}
+	if laptop.Communications["8.8.8.8"] == nil || !laptop.DNSLookups["updates.example.org"] {
+		t.Errorf("Expected flow and DNS lookup on the laptop, got %+v / %v", laptop.Communications, laptop.DNSLookups)
+	}
+	if potential := laptop.Findings[model.PotentialFinding]; len(potential) != 1 || potential[0].PortID != 0 {
+		t.Errorf("Expected the outbound alert on the source host, got %+v", potential)
+	}
+
+	target := networkMap.Hosts["IP:192.168.5.40"]
+	if target == nil {
+		t.Fatal("Expected the alert target host")
+	}
+	critical := target.Findings[model.CriticalFinding]
+	if len(critical) != 1 {
+		t.Fatalf("Expected repeated alerts to be reported once, got %d", len(critical))
+	}
+	if critical[0].CVE != "CVE-2021-44228" || critical[0].PortID != 8080 || critical[0].Source != "Suricata SID 2034647" {
+		t.Errorf("Unexpected alert finding: %+v", critical[0])
+	}
+}
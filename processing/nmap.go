@@ -5,22 +5,65 @@ import (
 	"encoding/xml"
 	"fmt"
 	"io"
-	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // NmapRun represents the top-level structure of an Nmap XML output.
 type NmapRun struct {
-	Hosts []NmapHost `xml:"host"`
+	Scanner  string         `xml:"scanner,attr"`
+	Args     string         `xml:"args,attr"`
+	Start    int64          `xml:"start,attr"`
+	Version  string         `xml:"version,attr"`
+	ScanInfo []NmapScanInfo `xml:"scaninfo"`
+	Hosts    []NmapHost     `xml:"host"`
+	RunStats NmapRunStats   `xml:"runstats"`
+}
+
+// NmapScanInfo describes one scan type performed during the run (e.g. a SYN scan of 1000 TCP ports).
+type NmapScanInfo struct {
+	Type        string `xml:"type,attr"`
+	Protocol    string `xml:"protocol,attr"`
+	NumServices int    `xml:"numservices,attr"`
+	Services    string `xml:"services,attr"`
+}
+
+// NmapRunStats holds the statistics Nmap prints when the run finishes.
+type NmapRunStats struct {
+	Finished NmapFinished  `xml:"finished"`
+	Hosts    NmapHostStats `xml:"hosts"`
+}
+
+// NmapFinished holds the end time and duration of a run.
+type NmapFinished struct {
+	Time    int64   `xml:"time,attr"`
+	Elapsed float64 `xml:"elapsed,attr"`
+	Summary string  `xml:"summary,attr"`
+	Exit    string  `xml:"exit,attr"`
+}
+
+// NmapHostStats holds the number of hosts found up and down during a run.
+type NmapHostStats struct {
+	Up    int `xml:"up,attr"`
+	Down  int `xml:"down,attr"`
+	Total int `xml:"total,attr"`
 }
 
 // NmapHost represents a single host in the Nmap output.
 type NmapHost struct {
-	Status    NmapStatus     `xml:"status"`
-	Addresses []NmapAddress  `xml:"address"`
-	Hostnames []NmapHostname `xml:"hostnames>hostname"`
-	Ports     []NmapPort     `xml:"ports>port"`
-	OS        NmapOS         `xml:"os"`
+	StartTime   int64          `xml:"starttime,attr"`
+	EndTime     int64          `xml:"endtime,attr"`
+	Status      NmapStatus     `xml:"status"`
+	Addresses   []NmapAddress  `xml:"address"`
+	Hostnames   []NmapHostname `xml:"hostnames>hostname"`
+	Ports       []NmapPort     `xml:"ports>port"`
+	OS          NmapOS         `xml:"os"`
+	Uptime      NmapUptime     `xml:"uptime"`
+	Distance    NmapDistance   `xml:"distance"`
+	Times       NmapTimes      `xml:"times"`
+	Trace       NmapTrace      `xml:"trace"`
+	HostScripts []NmapScript   `xml:"hostscript>script"`
 }
 
 // NmapStatus holds the state of a host (e.g., "up").
@@ -57,9 +100,13 @@ type NmapState struct {
 
 // NmapService holds information about the service running on a port.
 type NmapService struct {
-	Name    string `xml:"name,attr"`
-	Product string `xml:"product,attr"`
-	Version string `xml:"version,attr"`
+	Name      string   `xml:"name,attr"`
+	Product   string   `xml:"product,attr"`
+	Version   string   `xml:"version,attr"`
+	ExtraInfo string   `xml:"extrainfo,attr"`
+	OSType    string   `xml:"ostype,attr"`
+	Tunnel    string   `xml:"tunnel,attr"`
+	CPEs      []string `xml:"cpe"`
 }
 
 // NmapOS holds operating system detection results.
@@ -76,21 +123,71 @@ type OSMatch struct {
 
 // OSClass provides more detail about the OS guess.
 type OSClass struct {
-	Type     string `xml:"type,attr"`
-	Vendor   string `xml:"vendor,attr"`
-	OSFamily string `xml:"osfamily,attr"`
-	OSGen    string `xml:"osgen,attr"`
+	Type     string   `xml:"type,attr"`
+	Vendor   string   `xml:"vendor,attr"`
+	OSFamily string   `xml:"osfamily,attr"`
+	OSGen    string   `xml:"osgen,attr"`
+	CPEs     []string `xml:"cpe"`
+}
+
+// NmapUptime holds Nmap's uptime guess, derived from TCP timestamps.
+type NmapUptime struct {
+	Seconds  int64  `xml:"seconds,attr"`
+	LastBoot string `xml:"lastboot,attr"`
+}
+
+// NmapDistance holds the estimated number of network hops to the host.
+type NmapDistance struct {
+	Value int `xml:"value,attr"`
 }
 
-// NmapScript holds the output of an NSE script.
+// NmapTimes holds the round-trip timing values (in microseconds) Nmap used for the host.
+type NmapTimes struct {
+	SRTT   int64 `xml:"srtt,attr"`
+	RTTVar int64 `xml:"rttvar,attr"`
+	To     int64 `xml:"to,attr"`
+}
+
+// NmapTrace holds the result of a --traceroute run.
+type NmapTrace struct {
+	Port     int       `xml:"port,attr"`
+	Protocol string    `xml:"proto,attr"`
+	Hops     []NmapHop `xml:"hop"`
+}
+
+// NmapHop represents a single traceroute hop.
+type NmapHop struct {
+	TTL    int     `xml:"ttl,attr"`
+	IPAddr string  `xml:"ipaddr,attr"`
+	RTT    float64 `xml:"rtt,attr"`
+	Host   string  `xml:"host,attr"`
+}
+
+// NmapScript holds the output of an NSE script, both as plain text and as
+// the structured <table>/<elem> tree most scripts also emit.
 type NmapScript struct {
-	ID     string `xml:"id,attr"`
-	Output string `xml:"output,attr"`
+	ID     string      `xml:"id,attr"`
+	Output string      `xml:"output,attr"`
+	Elems  []NmapElem  `xml:"elem"`
+	Tables []NmapTable `xml:"table"`
+}
+
+// NmapTable is a (possibly keyed) table in structured NSE output.
+type NmapTable struct {
+	Key    string      `xml:"key,attr"`
+	Elems  []NmapElem  `xml:"elem"`
+	Tables []NmapTable `xml:"table"`
+}
+
+// NmapElem is a single (possibly keyed) value in structured NSE output.
+type NmapElem struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
 }
 
 // MergeFromFile parses an Nmap XML file and merges its data into the NetworkMap.
 func MergeFromFile(filename string, networkMap *model.NetworkMap) error {
-	xmlFile, err := os.Open(filename)
+	xmlFile, err := OpenDataFile(filename)
 	if err != nil {
 		return fmt.Errorf("could not open nmap file %s: %w", filename, err)
 	}
@@ -111,6 +208,10 @@ func MergeFromXML(byteValue []byte, networkMap *model.NetworkMap) error {
 		return fmt.Errorf("could not unmarshal nmap xml: %w", err)
 	}
 
+	networkMap.ScanRuns = append(networkMap.ScanRuns, toScanRun(nmapRun))
+	// Masscan's XML output reuses the Nmap format.
+	discoveredBy := scannerDisplayName(nmapRun.Scanner)
+
 	for _, nmapHost := range nmapRun.Hosts {
 		var mac, ip, vendor string
 		for _, addr := range nmapHost.Addresses {
@@ -143,8 +244,11 @@ func MergeFromXML(byteValue []byte, networkMap *model.NetworkMap) error {
 			networkMap.Hosts[hostKey] = host
 		}
 
-		host.DiscoveredBy = "Nmap"
+		host.DiscoveredBy = discoveredBy
 		host.Status = nmapHost.Status.State
+		if host.Status == "" && len(nmapHost.Ports) > 0 {
+			host.Status = "up"
+		}
 		if ip != "" {
 			host.IPv4Addresses[ip] = true
 		}
@@ -153,37 +257,115 @@ func MergeFromXML(byteValue []byte, networkMap *model.NetworkMap) error {
 			host.Fingerprint.Vendor = vendor
 		}
 
+		for _, hostname := range nmapHost.Hostnames {
+			if hostname.Name != "" {
+				host.Hostnames[hostname.Name] = hostname.Type
+			}
+		}
+
+		if nmapHost.Uptime.Seconds > 0 {
+			host.Uptime = &model.Uptime{Seconds: nmapHost.Uptime.Seconds, LastBoot: nmapHost.Uptime.LastBoot}
+		}
+		if nmapHost.Distance.Value > 0 {
+			host.Distance = nmapHost.Distance.Value
+		}
+		if nmapHost.Times.SRTT > 0 || nmapHost.Times.To > 0 {
+			host.Timing = &model.HostTiming{SRTT: nmapHost.Times.SRTT, RTTVar: nmapHost.Times.RTTVar, Timeout: nmapHost.Times.To}
+		}
+		if len(nmapHost.Trace.Hops) > 0 {
+			host.Traceroute = host.Traceroute[:0]
+			for _, hop := range nmapHost.Trace.Hops {
+				host.Traceroute = append(host.Traceroute, model.TraceHop{TTL: hop.TTL, IP: hop.IPAddr, RTT: hop.RTT, Hostname: hop.Host})
+			}
+		}
+
 		if len(nmapHost.OS.OSMatches) > 0 {
 			bestMatch := nmapHost.OS.OSMatches[0]
 			host.Fingerprint.OperatingSystem = bestMatch.Name
 			if len(bestMatch.OSClasses) > 0 {
 				host.Fingerprint.DeviceType = bestMatch.OSClasses[0].Type
 			}
+			host.Fingerprint.OSMatches = host.Fingerprint.OSMatches[:0]
+			for _, match := range nmapHost.OS.OSMatches {
+				host.Fingerprint.OSMatches = append(host.Fingerprint.OSMatches, toOSMatch(match))
+			}
 		}
 
 		for _, nmapPort := range nmapHost.Ports {
 			port := model.Port{
-				ID:       nmapPort.PortID,
-				Protocol: nmapPort.Protocol,
-				State:    nmapPort.State.State,
-				Service:  nmapPort.Service.Name,
-				Version:  nmapPort.Service.Product + " " + nmapPort.Service.Version,
+				ID:        nmapPort.PortID,
+				Protocol:  nmapPort.Protocol,
+				State:     nmapPort.State.State,
+				Service:   nmapPort.Service.Name,
+				Version:   nmapPort.Service.Product + " " + nmapPort.Service.Version,
+				ExtraInfo: nmapPort.Service.ExtraInfo,
+				OSType:    nmapPort.Service.OSType,
+				Tunnel:    nmapPort.Service.Tunnel,
+				CPEs:      nmapPort.Service.CPEs,
 			}
 			host.Ports[port.ID] = port
 
 			for _, script := range nmapPort.Scripts {
-				vuln := model.Vulnerability{
-					CVE:         script.ID,
-					Description: script.Output,
-					PortID:      port.ID,
-					Category:    model.InformationalFinding, // Default category
-				}
-				if strings.Contains(script.ID, "vuln") {
-					vuln.Category = model.PotentialFinding
-				}
-				host.Findings[vuln.Category] = append(host.Findings[vuln.Category], vuln)
+				addScriptFinding(host, script, port.ID)
 			}
 		}
+
+		// Host-level scripts (e.g. smb-vuln-ms17-010) are not tied to a port.
+		for _, script := range nmapHost.HostScripts {
+			addScriptFinding(host, script, 0)
+		}
 	}
 	return nil
 }
+
+// addScriptFinding turns the output of an NSE script into findings on the host.
+func addScriptFinding(host *model.Host, script NmapScript, portID int) {
+	for _, vuln := range parseScriptFindings(script, portID) {
+		host.Findings[vuln.Category] = append(host.Findings[vuln.Category], vuln)
+	}
+}
+
+// toOSMatch converts an Nmap OS guess into the model representation, collecting
+// the CPEs of all of its OS classes.
+func toOSMatch(match OSMatch) model.OSMatch {
+	accuracy, _ := strconv.Atoi(match.Accuracy)
+	osMatch := model.OSMatch{Name: match.Name, Accuracy: accuracy}
+	for i, class := range match.OSClasses {
+		if i == 0 {
+			osMatch.Family = class.OSFamily
+			osMatch.Generation = class.OSGen
+			osMatch.Vendor = class.Vendor
+			osMatch.DeviceType = class.Type
+		}
+		osMatch.CPEs = append(osMatch.CPEs, class.CPEs...)
+	}
+	return osMatch
+}
+
+// toScanRun extracts the command line, timing and statistics of an Nmap run.
+func toScanRun(nmapRun NmapRun) model.ScanRun {
+	scanner := nmapRun.Scanner
+	if scanner == "" {
+		scanner = "nmap"
+	}
+	run := model.ScanRun{
+		Scanner:    scanner,
+		Args:       nmapRun.Args,
+		Version:    nmapRun.Version,
+		Elapsed:    nmapRun.RunStats.Finished.Elapsed,
+		Summary:    nmapRun.RunStats.Finished.Summary,
+		HostsUp:    nmapRun.RunStats.Hosts.Up,
+		HostsDown:  nmapRun.RunStats.Hosts.Down,
+		HostsTotal: nmapRun.RunStats.Hosts.Total,
+	}
+	if nmapRun.Start > 0 {
+		run.StartTime = time.Unix(nmapRun.Start, 0)
+	}
+	if nmapRun.RunStats.Finished.Time > 0 {
+		run.EndTime = time.Unix(nmapRun.RunStats.Finished.Time, 0)
+	}
+	for _, info := range nmapRun.ScanInfo {
+		run.ScanInfo = append(run.ScanInfo, model.ScanInfo{Type: info.Type, Protocol: info.Protocol, NumServices: info.NumServices})
+	}
+	return run
+}
@@ -110,3 +110,108 @@ func TestMergeFromFile(t *testing.T) {
 		t.Errorf("Finding CVE incorrect, got: %s, want: ssl-cert", infoFindings[0].CVE)
 	}
 }
+
+// TestMergeFromXMLMetadata verifies that hostnames, uptime, traceroute, CPEs,
+// host scripts and run statistics are kept.
+func TestMergeFromXMLMetadata(t *testing.T) {
+	xmlData := `<?xml version="1.0" encoding="UTF-8"?>
+<nmaprun scanner="nmap" args="nmap -sS -sV -O --traceroute -oX out.xml 10.0.0.5" start="1700000000" version="7.94">
+<scaninfo type="syn" protocol="tcp" numservices="1000" services="1-1000"/>
+<host starttime="1700000001" endtime="1700000050">
+<status state="up" reason="echo-reply"/>
+<address addr="10.0.0.5" addrtype="ipv4"/>
+<hostnames>
+<hostname name="fileserver.corp.local" type="PTR"/>
+</hostnames>
+<ports>
+<port protocol="tcp" portid="443">
+<state state="open" reason="syn-ack"/>
+<service name="http" product="Apache httpd" version="2.4.57" extrainfo="(Debian)" ostype="Linux" tunnel="ssl">
+<cpe>cpe:/a:apache:http_server:2.4.57</cpe>
+</service>
+</port>
+</ports>
+<os>
+<osmatch name="Linux 5.0 - 5.14" accuracy="96">
+<osclass type="general purpose" vendor="Linux" osfamily="Linux" osgen="5.X" accuracy="96"><cpe>cpe:/o:linux:linux_kernel:5</cpe></osclass>
+</osmatch>
+<osmatch name="Linux 4.15" accuracy="90">
+<osclass type="general purpose" vendor="Linux" osfamily="Linux" osgen="4.X" accuracy="90"><cpe>cpe:/o:linux:linux_kernel:4.15</cpe></osclass>
+</osmatch>
+</os>
+<uptime seconds="86400" lastboot="Mon Nov 13 22:13:20 2023"/>
+<distance value="3"/>
+<hostscript>
+<script id="smb-vuln-ms17-010" output="VULNERABLE"/>
+</hostscript>
+<trace port="443" proto="tcp">
+<hop ttl="1" ipaddr="10.0.0.1" rtt="0.50" host="gw.corp.local"/>
+<hop ttl="3" ipaddr="10.0.0.5" rtt="1.20"/>
+</trace>
+<times srtt="1200" rttvar="300" to="100000"/>
+</host>
+<runstats>
+<finished time="1700000060" elapsed="60.00" summary="Nmap done; 1 IP address (1 host up) scanned in 60.00 seconds" exit="success"/>
+<hosts up="1" down="0" total="1"/>
+</runstats>
+</nmaprun>`
+
+	networkMap := model.NewNetworkMap()
+	if err := MergeFromXML([]byte(xmlData), networkMap); err != nil {
+		t.Fatalf("MergeFromXML failed: %v", err)
+	}
+
+	host, ok := networkMap.Hosts["IP:10.0.0.5"]
+	if !ok {
+		t.Fatal("Expected placeholder host IP:10.0.0.5")
+	}
+	if host.Hostnames["fileserver.corp.local"] != "PTR" {
+		t.Errorf("Hostname not kept, got: %v", host.Hostnames)
+	}
+	if host.Uptime == nil || host.Uptime.Seconds != 86400 {
+		t.Errorf("Uptime incorrect, got: %+v", host.Uptime)
+	}
+	if host.Distance != 3 {
+		t.Errorf("Distance incorrect, got: %d, want: 3", host.Distance)
+	}
+	if host.Timing == nil || host.Timing.SRTT != 1200 {
+		t.Errorf("Timing incorrect, got: %+v", host.Timing)
+	}
+	if len(host.Traceroute) != 2 || host.Traceroute[0].Hostname != "gw.corp.local" {
+		t.Errorf("Traceroute incorrect, got: %+v", host.Traceroute)
+	}
+	if len(host.Fingerprint.OSMatches) != 2 || host.Fingerprint.OSMatches[0].CPEs[0] != "cpe:/o:linux:linux_kernel:5" {
+		t.Errorf("OS matches incorrect, got: %+v", host.Fingerprint.OSMatches)
+	}
+
+	port := host.Ports[443]
+	if port.Tunnel != "ssl" || port.ExtraInfo != "(Debian)" || port.OSType != "Linux" {
+		t.Errorf("Service attributes incorrect, got: %+v", port)
+	}
+	if len(port.CPEs) != 1 || port.CPEs[0] != "cpe:/a:apache:http_server:2.4.57" {
+		t.Errorf("Port CPEs incorrect, got: %v", port.CPEs)
+	}
+
+	foundHostScript := false
+	for _, findings := range host.Findings {
+		for _, f := range findings {
+			if f.CVE == "smb-vuln-ms17-010" && f.PortID == 0 {
+				foundHostScript = true
+			}
+		}
+	}
+	if !foundHostScript {
+		t.Error("Expected a host-level finding from the hostscript section")
+	}
+
+	if len(networkMap.ScanRuns) != 1 {
+		t.Fatalf("Expected 1 scan run, got %d", len(networkMap.ScanRuns))
+	}
+	run := networkMap.ScanRuns[0]
+	if run.Args == "" || run.Elapsed != 60 || run.HostsUp != 1 {
+		t.Errorf("Scan run incorrect, got: %+v", run)
+	}
+	if len(run.ScanInfo) != 1 || run.ScanInfo[0].Type != "syn" {
+		t.Errorf("Scan info incorrect, got: %+v", run.ScanInfo)
+	}
+}
@@ -0,0 +1,316 @@
+package processing
+
+import (
+	"SnailsHell/config"
+	"SnailsHell/model"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Default CVSS thresholds, used when no configuration has been loaded.
+const (
+	defaultCriticalCVSS  = 9.0
+	defaultPotentialCVSS = 4.0
+)
+
+// Vulnerability states reported by the NSE vulns library.
+const (
+	stateVulnerable       = "VULNERABLE"
+	stateLikelyVulnerable = "LIKELY VULNERABLE"
+	stateNotVulnerable    = "NOT VULNERABLE"
+)
+
+// stateDetected is the state of findings raised by the passive detectors.
+const stateDetected = "DETECTED"
+
+var (
+	cveRegex   = regexp.MustCompile(`CVE-\d{4}-\d{4,}`)
+	stateRegex = regexp.MustCompile(`(?i)\b(NOT VULNERABLE|LIKELY VULNERABLE|VULNERABLE)\b`)
+)
+
+// deprecatedTLSVersions are protocol versions that should no longer be offered.
+var deprecatedTLSVersions = map[string]bool{"SSLv2": true, "SSLv3": true, "TLSv1.0": true, "TLSv1.1": true}
+
+// parseScriptFindings converts the output of a single NSE script into one or more findings.
+// Structured output is preferred; the plain text output is used as a fallback.
+func parseScriptFindings(script NmapScript, portID int) []model.Vulnerability {
+	var findings []model.Vulnerability
+	switch {
+	case script.ID == "vulners":
+		findings = parseVulnersScript(script, portID)
+	case script.ID == "ssl-enum-ciphers":
+		findings = parseSSLEnumCiphersScript(script, portID)
+	case hasVulnsTables(script.Tables):
+		findings = parseVulnsScript(script, portID)
+	}
+	if len(findings) > 0 {
+		return findings
+	}
+	return []model.Vulnerability{parseUnstructuredScript(script, portID)}
+}
+
+// parseVulnersScript reads the per-CPE tables of the vulners script. Only CVE entries become
+// findings; vulners matches on version alone, so they are reported as likely vulnerable.
+func parseVulnersScript(script NmapScript, portID int) []model.Vulnerability {
+	var findings []model.Vulnerability
+	seen := make(map[string]bool)
+	for _, cpeTable := range script.Tables {
+		for _, entry := range cpeTable.Tables {
+			id := elemValue(entry.Elems, "id")
+			if !strings.EqualFold(elemValue(entry.Elems, "type"), "cve") || id == "" || seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			cvss, _ := strconv.ParseFloat(elemValue(entry.Elems, "cvss"), 64)
+			description := fmt.Sprintf("%s matched by version (%s)", id, cpeTable.Key)
+			if elemValue(entry.Elems, "is_exploit") == "true" {
+				description += "; public exploit available"
+			}
+			findings = append(findings, model.Vulnerability{
+				CVE:         id,
+				Description: description,
+				State:       stateLikelyVulnerable,
+				Category:    classifyFinding(stateLikelyVulnerable, cvss, script.ID),
+				PortID:      portID,
+				CVSS:        cvss,
+				Source:      script.ID,
+				References:  []string{"https://vulners.com/cve/" + id},
+			})
+		}
+	}
+	return findings
+}
+
+// parseVulnsScript reads scripts built on the NSE vulns library (smb-vuln-*, http-vuln-*,
+// ssl-heartbleed, ...). Each vulnerability is a table keyed by its primary ID.
+func parseVulnsScript(script NmapScript, portID int) []model.Vulnerability {
+	var findings []model.Vulnerability
+	for _, table := range script.Tables {
+		state := strings.ToUpper(strings.TrimSpace(elemValue(table.Elems, "state")))
+		if state == "" {
+			continue
+		}
+
+		vuln := model.Vulnerability{
+			State:  normalizeState(state),
+			PortID: portID,
+			Source: script.ID,
+		}
+		for _, score := range subTable(table, "scores").Elems {
+			if cvss, err := strconv.ParseFloat(score.Value, 64); err == nil && cvss > vuln.CVSS {
+				vuln.CVSS = cvss
+			}
+		}
+		for _, ref := range subTable(table, "refs").Elems {
+			vuln.References = append(vuln.References, strings.TrimSpace(ref.Value))
+		}
+
+		description := elemValue(table.Elems, "title")
+		var details []string
+		for _, line := range subTable(table, "description").Elems {
+			details = append(details, strings.TrimSpace(line.Value))
+		}
+		if len(details) > 0 {
+			description = strings.TrimSpace(description + "\n" + strings.Join(details, " "))
+		}
+		if description == "" {
+			description = script.Output
+		}
+		vuln.Description = description
+		vuln.Category = classifyFinding(vuln.State, vuln.CVSS, script.ID)
+
+		// An entry can cover several CVEs (MS17-010 lists three); each gets its own finding.
+		var cves []string
+		for _, id := range subTable(table, "ids").Elems {
+			if strings.HasPrefix(id.Value, "CVE:") {
+				cves = append(cves, strings.TrimPrefix(id.Value, "CVE:"))
+			}
+		}
+		if len(cves) == 0 {
+			cves = append(cves, table.Key)
+		}
+		for _, cve := range cves {
+			if cve == "" {
+				cve = script.ID
+			}
+			finding := vuln
+			finding.CVE = cve
+			findings = append(findings, finding)
+		}
+	}
+	return findings
+}
+
+// parseSSLEnumCiphersScript summarises ssl-enum-ciphers into a single finding graded by the
+// weakest cipher offered, raised to at least Potential when deprecated protocols are enabled.
+func parseSSLEnumCiphersScript(script NmapScript, portID int) []model.Vulnerability {
+	leastStrength := strings.ToUpper(elemValue(script.Elems, "least strength"))
+	var protocols, deprecated, warnings []string
+	for _, table := range script.Tables {
+		protocols = append(protocols, table.Key)
+		if deprecatedTLSVersions[table.Key] {
+			deprecated = append(deprecated, table.Key)
+		}
+		for _, warning := range subTable(table, "warnings").Elems {
+			warnings = append(warnings, fmt.Sprintf("%s: %s", table.Key, strings.TrimSpace(warning.Value)))
+		}
+	}
+	if len(protocols) == 0 {
+		return nil
+	}
+	sort.Strings(protocols)
+	sort.Strings(deprecated)
+
+	category := model.InformationalFinding
+	switch leastStrength {
+	case "F":
+		category = model.CriticalFinding
+	case "C", "D", "E":
+		category = model.PotentialFinding
+	}
+	if len(deprecated) > 0 && category == model.InformationalFinding {
+		category = model.PotentialFinding
+	}
+
+	description := fmt.Sprintf("Protocols offered: %s. Least cipher strength: %s.", strings.Join(protocols, ", "), defaultIfEmpty(leastStrength, "unknown"))
+	if len(deprecated) > 0 {
+		description += fmt.Sprintf(" Deprecated protocols enabled: %s.", strings.Join(deprecated, ", "))
+	}
+	if len(warnings) > 0 {
+		description += "\n" + strings.Join(warnings, "\n")
+	}
+
+	state := stateNotVulnerable
+	if category != model.InformationalFinding {
+		state = stateVulnerable
+	}
+	return []model.Vulnerability{{
+		CVE:         script.ID,
+		Description: description,
+		State:       state,
+		Category:    category,
+		PortID:      portID,
+		Source:      script.ID,
+	}}
+}
+
+// parseUnstructuredScript builds a finding from the plain text output of a script,
+// picking up the first CVE ID and vulnerability state it mentions.
+func parseUnstructuredScript(script NmapScript, portID int) model.Vulnerability {
+	vuln := model.Vulnerability{
+		CVE:         script.ID,
+		Description: script.Output,
+		PortID:      portID,
+		Source:      script.ID,
+	}
+	if cve := cveRegex.FindString(script.Output); cve != "" {
+		vuln.CVE = cve
+	}
+	if match := stateRegex.FindString(script.Output); match != "" {
+		vuln.State = normalizeState(strings.ToUpper(match))
+	}
+	vuln.Category = classifyFinding(vuln.State, 0, script.ID)
+	return vuln
+}
+
+// classifyFinding maps a vulnerability state and CVSS score to a finding category
+// using the thresholds from the configuration.
+func classifyFinding(state string, cvss float64, scriptID string) model.FindingCategory {
+	if state == stateNotVulnerable {
+		return model.InformationalFinding
+	}
+	criticalCVSS, potentialCVSS := cvssThresholds()
+	switch {
+	case cvss >= criticalCVSS:
+		return model.CriticalFinding
+	case cvss >= potentialCVSS:
+		return model.PotentialFinding
+	case cvss > 0:
+		return model.InformationalFinding
+	}
+	// Without a score, fall back to the state and the script's purpose.
+	if state == stateVulnerable || state == stateLikelyVulnerable || strings.Contains(scriptID, "vuln") {
+		return model.PotentialFinding
+	}
+	return model.InformationalFinding
+}
+
+// addFinding adds a finding to a host unless the host already has the same one, from the same
+// source, on the same port.
+func addFinding(host *model.Host, vuln model.Vulnerability) {
+	for _, existing := range host.Findings[vuln.Category] {
+		if existing.CVE == vuln.CVE && existing.Source == vuln.Source && existing.PortID == vuln.PortID {
+			return
+		}
+	}
+	host.Findings[vuln.Category] = append(host.Findings[vuln.Category], vuln)
+}
+
+// cvssThresholds returns the minimum CVSS scores for critical and potential findings.
+func cvssThresholds() (critical, potential float64) {
+	critical, potential = defaultCriticalCVSS, defaultPotentialCVSS
+	if config.Cfg != nil {
+		if config.Cfg.Findings.CriticalCVSS > 0 {
+			critical = config.Cfg.Findings.CriticalCVSS
+		}
+		if config.Cfg.Findings.PotentialCVSS > 0 {
+			potential = config.Cfg.Findings.PotentialCVSS
+		}
+	}
+	return critical, potential
+}
+
+// normalizeState collapses the state variants of the vulns library (e.g.
+// "VULNERABLE (Exploitable)") onto the three states used for classification.
+func normalizeState(state string) string {
+	switch {
+	case strings.HasPrefix(state, stateNotVulnerable):
+		return stateNotVulnerable
+	case strings.HasPrefix(state, stateLikelyVulnerable):
+		return stateLikelyVulnerable
+	case strings.HasPrefix(state, stateVulnerable):
+		return stateVulnerable
+	}
+	return state
+}
+
+// hasVulnsTables reports whether the script output follows the vulns library layout.
+func hasVulnsTables(tables []NmapTable) bool {
+	for _, table := range tables {
+		if elemValue(table.Elems, "state") != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// elemValue returns the value of the element with the given key.
+func elemValue(elems []NmapElem, key string) string {
+	for _, elem := range elems {
+		if elem.Key == key {
+			return strings.TrimSpace(elem.Value)
+		}
+	}
+	return ""
+}
+
+// subTable returns the nested table with the given key, or an empty table.
+func subTable(table NmapTable, key string) NmapTable {
+	for _, t := range table.Tables {
+		if t.Key == key {
+			return t
+		}
+	}
+	return NmapTable{}
+}
+
+func defaultIfEmpty(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,161 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"encoding/xml"
+	"testing"
+)
+
+func unmarshalScript(t *testing.T, data string) NmapScript {
+	t.Helper()
+	var script NmapScript
+	if err := xml.Unmarshal([]byte(data), &script); err != nil {
+		t.Fatalf("could not unmarshal script: %v", err)
+	}
+	return script
+}
+
+// TestParseVulnersScript verifies that vulners entries become CVE findings classified by CVSS.
+func TestParseVulnersScript(t *testing.T) {
+	script := unmarshalScript(t, `<script id="vulners" output="...">
+<table key="cpe:/a:openbsd:openssh:7.4">
+<table><elem key="is_exploit">false</elem><elem key="cvss">9.8</elem><elem key="id">CVE-2023-38408</elem><elem key="type">cve</elem></table>
+<table><elem key="is_exploit">true</elem><elem key="cvss">5.3</elem><elem key="id">CVE-2018-15473</elem><elem key="type">cve</elem></table>
+<table><elem key="is_exploit">true</elem><elem key="cvss">5.0</elem><elem key="id">EDB-ID:45233</elem><elem key="type">exploitdb</elem></table>
+<table><elem key="is_exploit">false</elem><elem key="cvss">2.1</elem><elem key="id">CVE-2017-0001</elem><elem key="type">cve</elem></table>
+</table>
+</script>`)
+
+	findings := parseScriptFindings(script, 22)
+	if len(findings) != 3 {
+		t.Fatalf("Expected 3 CVE findings, got %d: %+v", len(findings), findings)
+	}
+
+	want := map[string]model.FindingCategory{
+		"CVE-2023-38408": model.CriticalFinding,
+		"CVE-2018-15473": model.PotentialFinding,
+		"CVE-2017-0001":  model.InformationalFinding,
+	}
+	for _, f := range findings {
+		if f.Category != want[f.CVE] {
+			t.Errorf("%s: category got %s, want %s", f.CVE, f.Category, want[f.CVE])
+		}
+		if f.PortID != 22 || f.Source != "vulners" || f.State != stateLikelyVulnerable {
+			t.Errorf("%s: unexpected finding fields: %+v", f.CVE, f)
+		}
+	}
+	if findings[0].CVSS != 9.8 {
+		t.Errorf("CVSS got %.1f, want 9.8", findings[0].CVSS)
+	}
+}
+
+// TestParseVulnsLibraryScript verifies that scripts built on the vulns library yield the real CVE and state.
+func TestParseVulnsLibraryScript(t *testing.T) {
+	script := unmarshalScript(t, `<script id="smb-vuln-ms17-010" output="...">
+<table key="CVE-2017-0143">
+<elem key="title">Remote Code Execution vulnerability in Microsoft SMBv1 servers (ms17-010)</elem>
+<elem key="state">VULNERABLE</elem>
+<table key="ids"><elem>CVE:CVE-2017-0143</elem></table>
+<elem key="risk_factor">HIGH</elem>
+<table key="description"><elem>A critical remote code execution vulnerability exists in Microsoft SMBv1.</elem></table>
+<table key="refs"><elem>https://technet.microsoft.com/en-us/library/security/ms17-010.aspx</elem></table>
+</table>
+</script>`)
+
+	findings := parseScriptFindings(script, 0)
+	if len(findings) != 1 {
+		t.Fatalf("Expected 1 finding, got %d", len(findings))
+	}
+	f := findings[0]
+	if f.CVE != "CVE-2017-0143" || f.State != stateVulnerable || f.Category != model.PotentialFinding {
+		t.Errorf("Unexpected finding: %+v", f)
+	}
+	if len(f.References) != 1 {
+		t.Errorf("Expected 1 reference, got %v", f.References)
+	}
+
+	multi := unmarshalScript(t, `<script id="smb-vuln-ms17-010" output="...">
+<table key="CVE-2017-0143">
+<elem key="state">VULNERABLE</elem>
+<table key="ids"><elem>CVE:CVE-2017-0143</elem><elem>CVE:CVE-2017-0144</elem><elem>CVE:CVE-2017-0145</elem></table>
+</table>
+</script>`)
+	findings = parseScriptFindings(multi, 445)
+	if len(findings) != 3 || findings[0].CVE != "CVE-2017-0143" || findings[2].CVE != "CVE-2017-0145" {
+		t.Errorf("Expected one finding per CVE id, got: %+v", findings)
+	}
+
+	notVulnerable := unmarshalScript(t, `<script id="http-vuln-cve2017-5638" output="...">
+<table key="CVE-2017-5638">
+<elem key="title">Apache Struts Remote Code Execution Vulnerability</elem>
+<elem key="state">NOT VULNERABLE</elem>
+<table key="scores"><elem key="CVSSv2">10.0</elem></table>
+</table>
+</script>`)
+	findings = parseScriptFindings(notVulnerable, 80)
+	if len(findings) != 1 || findings[0].Category != model.InformationalFinding || findings[0].State != stateNotVulnerable {
+		t.Errorf("NOT VULNERABLE should be informational, got: %+v", findings)
+	}
+
+	scored := unmarshalScript(t, `<script id="http-vuln-cve2017-5638" output="...">
+<table key="CVE-2017-5638">
+<elem key="state">VULNERABLE (Exploitable)</elem>
+<table key="scores"><elem key="CVSSv2">10.0</elem></table>
+</table>
+</script>`)
+	findings = parseScriptFindings(scored, 80)
+	if len(findings) != 1 || findings[0].Category != model.CriticalFinding || findings[0].State != stateVulnerable {
+		t.Errorf("Exploitable CVSS 10 should be critical, got: %+v", findings)
+	}
+}
+
+// TestParseSSLEnumCiphersScript verifies that weak ciphers and deprecated protocols raise the category.
+func TestParseSSLEnumCiphersScript(t *testing.T) {
+	script := unmarshalScript(t, `<script id="ssl-enum-ciphers" output="...">
+<table key="TLSv1.0">
+<table key="ciphers"><table><elem key="name">TLS_RSA_WITH_3DES_EDE_CBC_SHA</elem><elem key="strength">C</elem></table></table>
+<table key="warnings"><elem>64-bit block cipher 3DES vulnerable to SWEET32 attack</elem></table>
+</table>
+<table key="TLSv1.2">
+<table key="ciphers"><table><elem key="name">TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256</elem><elem key="strength">A</elem></table></table>
+</table>
+<elem key="least strength">C</elem>
+</script>`)
+
+	findings := parseScriptFindings(script, 443)
+	if len(findings) != 1 {
+		t.Fatalf("Expected 1 finding, got %d", len(findings))
+	}
+	if findings[0].Category != model.PotentialFinding || findings[0].State != stateVulnerable {
+		t.Errorf("Unexpected ssl-enum-ciphers finding: %+v", findings[0])
+	}
+}
+
+// TestParseUnstructuredScript verifies the plain text fallback.
+func TestParseUnstructuredScript(t *testing.T) {
+	findings := parseScriptFindings(NmapScript{ID: "ssl-cert", Output: "Subject: commonName=test.local"}, 443)
+	if len(findings) != 1 || findings[0].CVE != "ssl-cert" || findings[0].Category != model.InformationalFinding {
+		t.Errorf("Unexpected fallback finding: %+v", findings)
+	}
+
+	findings = parseScriptFindings(NmapScript{ID: "custom-check", Output: "State: VULNERABLE\nIDs: CVE-2021-44228"}, 8080)
+	if len(findings) != 1 || findings[0].CVE != "CVE-2021-44228" || findings[0].State != stateVulnerable || findings[0].Category != model.PotentialFinding {
+		t.Errorf("Unexpected fallback finding: %+v", findings)
+	}
+}
+
+// TestAddFinding verifies that a finding is added once per ID, source and port.
+func TestAddFinding(t *testing.T) {
+	host := model.NewHost("02:00:00:00:00:01")
+	vuln := model.Vulnerability{CVE: "TLS-WEAK-VERSION", Category: model.PotentialFinding, State: stateDetected, Source: tlsSource, PortID: 443}
+	addFinding(host, vuln)
+	addFinding(host, vuln)
+	other := vuln
+	other.PortID = 8443
+	addFinding(host, other)
+	other.Source = insecureSource
+	addFinding(host, other)
+	if n := len(host.Findings[model.PotentialFinding]); n != 3 {
+		t.Errorf("Expected 3 findings, got %d", n)
+	}
+}
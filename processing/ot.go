@@ -0,0 +1,828 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+const otSource = "OT protocol analysis"
+
+// Well-known ports of the industrial protocols.
+const (
+	modbusPort = 502
+	dnp3Port   = 20000
+	s7Port     = 102 // ISO-TSAP
+	bacnetPort = 47808
+	enipPort   = 44818
+	opcuaPort  = 4840
+
+	profinetEtherType = 0x8892
+)
+
+// otProtocol describes an industrial protocol for operations and findings.
+type otProtocol struct {
+	Name string
+	Code string // Used in finding IDs
+	Risk string
+}
+
+var (
+	otModbus   = otProtocol{"Modbus/TCP", "MODBUS", "Modbus/TCP has no authentication; any host that can reach port 502 can change values."}
+	otDNP3     = otProtocol{"DNP3", "DNP3", "DNP3 without Secure Authentication accepts commands from any host that can reach the outstation."}
+	otS7       = otProtocol{"S7comm", "S7COMM", "S7comm has no authentication beyond an optional, easily bypassed protection level."}
+	otBACnet   = otProtocol{"BACnet/IP", "BACNET", "BACnet/IP has no authentication; any host on the network can change properties or reinitialize devices."}
+	otENIP     = otProtocol{"EtherNet/IP", "ENIP", "EtherNet/IP and CIP have no authentication; any host that can reach port 44818 can write tags or change the run state."}
+	otOPCUA    = otProtocol{"OPC UA", "OPCUA", "The OPC UA channel used the security policy None, so requests are neither signed nor encrypted."}
+	otProfinet = otProtocol{"PROFINET DCP", "PNDCP", "PROFINET DCP has no authentication; any host on the segment can rename devices or change their IP configuration."}
+)
+
+// otMessage is one industrial protocol request, or a response that identifies the device.
+type otMessage struct {
+	ToServer  bool
+	UnitID    string
+	Function  string // Empty for responses
+	Operation string // model.OTRead, model.OTWrite, model.OTProgram or empty
+	Identity  string // Reported by the server
+}
+
+// otFunction names a function code and classifies what it does to the device.
+type otFunction struct {
+	Name      string
+	Operation string
+}
+
+// request returns a request message for a function code, e.g. "Write Single Register (6)".
+func (m otMessage) request(functions map[int]otFunction, code int, format string) otMessage {
+	m.ToServer = true
+	if f, ok := functions[code]; ok {
+		m.Function, m.Operation = fmt.Sprintf("%s ("+format+")", f.Name, code), f.Operation
+	} else {
+		m.Function = fmt.Sprintf("Function "+format, code)
+	}
+	return m
+}
+
+// processOT runs the industrial protocol decoder for the packet's ports, records the operations
+// on the local hosts taking part and reports unauthenticated writes and program changes.
+func processOT(p *transportPacket, srcIP, dstIP string, localHost func(ip string) *model.Host, summary *model.PcapSummary, sourceName string, ts time.Time) {
+	if len(p.Payload) == 0 {
+		return
+	}
+	var proto otProtocol
+	var messages []otMessage
+	switch {
+	case p.Transport == "TCP" && (p.SrcPort == modbusPort || p.DstPort == modbusPort):
+		proto, messages = otModbus, parseModbus(p.Payload, p.DstPort == modbusPort)
+	case p.SrcPort == dnp3Port || p.DstPort == dnp3Port:
+		proto, messages = otDNP3, parseDNP3(p.Payload)
+	case p.Transport == "TCP" && (p.SrcPort == s7Port || p.DstPort == s7Port):
+		proto, messages = otS7, parseS7(p.Payload, p.DstPort == s7Port)
+	case p.Transport == "UDP" && (p.SrcPort == bacnetPort || p.DstPort == bacnetPort):
+		proto, messages = otBACnet, parseBACnet(p.Payload)
+	case p.SrcPort == enipPort || p.DstPort == enipPort:
+		proto, messages = otENIP, parseENIP(p.Payload, p.DstPort == enipPort)
+	case p.Transport == "TCP" && p.DstPort == opcuaPort:
+		channel := fmt.Sprintf("%s:%d>%s", srcIP, p.SrcPort, dstIP)
+		proto, messages = otOPCUA, parseOPCUA(p.Payload, summary.OPCUAChannels, channel)
+	default:
+		return
+	}
+
+	for _, m := range messages {
+		clientIP, serverIP, port := srcIP, dstIP, p.DstPort
+		if !m.ToServer {
+			clientIP, serverIP, port = dstIP, srcIP, p.SrcPort
+		}
+		if m.Identity != "" {
+			if server := localHost(serverIP); server != nil {
+				server.OTIdentities[proto.Name] = m.Identity
+			}
+		}
+		if m.Function == "" {
+			continue
+		}
+		op := model.OTOperation{Protocol: proto.Name, Port: port, UnitID: m.UnitID, Function: m.Function, Operation: m.Operation}
+		evidence := model.Vulnerability{EvidenceFile: sourceName, EvidencePacket: summary.SourcePackets[sourceName]}
+		example := m.Function
+		if m.UnitID != "" {
+			example += " on unit " + m.UnitID
+		}
+		if client := localHost(clientIP); client != nil {
+			op.Role, op.Peer = "client", serverIP
+			recordOTOperation(client, op, ts)
+			evidence.Description = fmt.Sprintf("Host issued unauthenticated %s %s to %s, e.g. %s. %s", proto.Name, otOperationKinds[m.Operation], serverIP, example, proto.Risk)
+			addOTFinding(client, proto, m.Operation, 0, evidence)
+		}
+		if server := localHost(serverIP); server != nil {
+			op.Role, op.Peer = "server", clientIP
+			recordOTOperation(server, op, ts)
+			evidence.Description = fmt.Sprintf("Host received unauthenticated %s %s from %s, e.g. %s. %s", proto.Name, otOperationKinds[m.Operation], clientIP, example, proto.Risk)
+			addOTFinding(server, proto, m.Operation, port, evidence)
+		}
+	}
+}
+
+// recordOTOperation counts an operation on a host.
+func recordOTOperation(host *model.Host, op model.OTOperation, ts time.Time) {
+	existing, ok := host.OTOperations[op.Key()]
+	if !ok {
+		if host.OTOperations == nil {
+			host.OTOperations = make(map[string]*model.OTOperation)
+		}
+		existing = &op
+		host.OTOperations[op.Key()] = existing
+	}
+	existing.Count++
+	seen(&existing.FirstSeen, &existing.LastSeen, ts)
+}
+
+// otOperationKinds describe the operations that are reported as findings.
+var otOperationKinds = map[string]string{model.OTWrite: "writes", model.OTProgram: "program changes"}
+
+// addOTFinding reports a write or program change on a host, once per protocol, kind and port.
+func addOTFinding(host *model.Host, proto otProtocol, operation string, port int, vuln model.Vulnerability) {
+	switch operation {
+	case model.OTWrite:
+		vuln.CVE, vuln.Category = "OT-"+proto.Code+"-WRITE", model.PotentialFinding
+	case model.OTProgram:
+		vuln.CVE, vuln.Category = "OT-"+proto.Code+"-PROGRAM", model.CriticalFinding
+	default:
+		return
+	}
+	vuln.State, vuln.Source, vuln.PortID = stateDetected, otSource, port
+	addFinding(host, vuln)
+}
+
+// --- Modbus/TCP ---
+
+var modbusFunctions = map[int]otFunction{
+	1: {"Read Coils", model.OTRead}, 2: {"Read Discrete Inputs", model.OTRead},
+	3: {"Read Holding Registers", model.OTRead}, 4: {"Read Input Registers", model.OTRead},
+	5: {"Write Single Coil", model.OTWrite}, 6: {"Write Single Register", model.OTWrite},
+	7: {"Read Exception Status", model.OTRead}, 8: {"Diagnostics", ""},
+	11: {"Get Comm Event Counter", model.OTRead}, 12: {"Get Comm Event Log", model.OTRead},
+	15: {"Write Multiple Coils", model.OTWrite}, 16: {"Write Multiple Registers", model.OTWrite},
+	17: {"Report Server ID", model.OTRead}, 20: {"Read File Record", model.OTRead},
+	21: {"Write File Record", model.OTWrite}, 22: {"Mask Write Register", model.OTWrite},
+	23: {"Read/Write Multiple Registers", model.OTWrite}, 24: {"Read FIFO Queue", model.OTRead},
+	43: {"Encapsulated Interface Transport", model.OTRead},
+}
+
+// modbusDeviceObjects are the Read Device Identification objects that make up the identity.
+var modbusDeviceObjects = []byte{0x00, 0x04, 0x05, 0x01, 0x02} // Vendor, product and model name, product code, revision
+
+// parseModbus decodes the Modbus application PDUs in a segment.
+func parseModbus(payload []byte, toServer bool) []otMessage {
+	var messages []otMessage
+	for len(payload) >= 8 && binary.BigEndian.Uint16(payload[2:]) == 0 {
+		length := int(binary.BigEndian.Uint16(payload[4:]))
+		if length < 2 || len(payload) < 6+length {
+			break
+		}
+		m := otMessage{UnitID: strconv.Itoa(int(payload[6]))}
+		pdu := payload[7 : 6+length]
+		payload = payload[6+length:]
+		function := int(pdu[0])
+		switch {
+		case toServer && function == 43 && len(pdu) > 1 && pdu[1] == 0x0e:
+			m.ToServer, m.Function, m.Operation = true, "Read Device Identification (43/14)", model.OTRead
+		case toServer:
+			m = m.request(modbusFunctions, function, "%d")
+		case function == 43 && len(pdu) > 7 && pdu[1] == 0x0e:
+			m.Identity = modbusDeviceIdentity(pdu)
+		}
+		if m.Function != "" || m.Identity != "" {
+			messages = append(messages, m)
+		}
+	}
+	return messages
+}
+
+// modbusDeviceIdentity joins the identification objects of a Read Device Identification response.
+func modbusDeviceIdentity(pdu []byte) string {
+	objects := make(map[byte]string)
+	data, count := pdu[7:], int(pdu[6])
+	for i := 0; i < count && len(data) >= 2 && len(data) >= 2+int(data[1]); i++ {
+		objects[data[0]] = strings.TrimSpace(string(data[2 : 2+int(data[1])]))
+		data = data[2+int(data[1]):]
+	}
+	var parts []string
+	for _, id := range modbusDeviceObjects {
+		if value := objects[id]; value != "" && !containsString(parts, value) {
+			parts = append(parts, value)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// --- DNP3 ---
+
+var dnp3Functions = map[int]otFunction{
+	1: {"Read", model.OTRead}, 2: {"Write", model.OTWrite}, 3: {"Select", model.OTWrite}, 4: {"Operate", model.OTWrite},
+	5: {"Direct Operate", model.OTWrite}, 6: {"Direct Operate No Ack", model.OTWrite},
+	7: {"Immediate Freeze", model.OTWrite}, 8: {"Immediate Freeze No Ack", model.OTWrite},
+	9: {"Freeze Clear", model.OTWrite}, 10: {"Freeze Clear No Ack", model.OTWrite},
+	13: {"Cold Restart", model.OTProgram}, 14: {"Warm Restart", model.OTProgram},
+	15: {"Initialize Data", model.OTProgram}, 16: {"Initialize Application", model.OTProgram},
+	17: {"Start Application", model.OTProgram}, 18: {"Stop Application", model.OTProgram},
+	19: {"Save Configuration", model.OTProgram}, 20: {"Enable Unsolicited", model.OTWrite},
+	21: {"Disable Unsolicited", model.OTWrite}, 22: {"Assign Class", model.OTWrite},
+	23: {"Delay Measurement", ""}, 24: {"Record Current Time", model.OTWrite},
+	25: {"Open File", ""}, 26: {"Close File", ""}, 27: {"Delete File", model.OTWrite},
+	28: {"Get File Info", model.OTRead}, 29: {"Authenticate File", ""}, 30: {"Abort File", ""},
+	32: {"Authenticate Request", ""},
+}
+
+// parseDNP3 decodes the application function of master requests in DNP3 link frames. The
+// direction comes from the frame, so the decoder works whichever side opened the connection.
+func parseDNP3(payload []byte) []otMessage {
+	var messages []otMessage
+	for len(payload) >= 10 && payload[0] == 0x05 && payload[1] == 0x64 {
+		userData := int(payload[2]) - 5
+		if userData < 0 {
+			break
+		}
+		size := 10 + userData + 2*((userData+15)/16)
+		if len(payload) < size {
+			size = len(payload)
+		}
+		control := payload[3]
+		fromMaster, primary, linkFunction := control&0x80 != 0, control&0x40 != 0, control&0x0f
+		destination := binary.LittleEndian.Uint16(payload[4:])
+		// Application data follows the transport header in the first block; a request starts
+		// with the first fragment (FIR) of a primary frame carrying user data.
+		if fromMaster && primary && (linkFunction == 3 || linkFunction == 4) && userData >= 3 && len(payload) >= 13 && payload[10]&0x40 != 0 {
+			if function := int(payload[12]); function != 0 && function < 0x80 {
+				messages = append(messages, otMessage{UnitID: strconv.Itoa(int(destination))}.request(dnp3Functions, function, "%d"))
+			}
+		}
+		payload = payload[size:]
+	}
+	return messages
+}
+
+// --- Siemens S7comm ---
+
+var s7Functions = map[int]otFunction{
+	0x00: {"CPU Services", ""}, 0x04: {"Read Var", model.OTRead}, 0x05: {"Write Var", model.OTWrite},
+	0x1a: {"Request Download", model.OTProgram}, 0x1b: {"Download Block", model.OTProgram}, 0x1c: {"Download Ended", model.OTProgram},
+	0x1d: {"Start Upload", model.OTRead}, 0x1e: {"Upload", model.OTRead}, 0x1f: {"End Upload", model.OTRead},
+	0x28: {"PI Service", model.OTProgram}, 0x29: {"PLC Stop", model.OTProgram}, 0xf0: {"Setup Communication", ""},
+}
+
+var s7UserdataGroups = map[int]otFunction{
+	1: {"Programmer Commands", ""}, 2: {"Cyclic Data", model.OTRead}, 3: {"Block Functions", model.OTRead},
+	4: {"CPU Functions", model.OTRead}, 5: {"Security", ""}, 7: {"Time Functions", ""},
+}
+
+var s7ConnectionTypes = map[byte]string{1: "PG", 2: "OP", 3: "S7 basic"}
+
+// parseS7 decodes S7comm jobs and connection requests, and the component identification
+// (SZL 0x001C) in CPU function responses.
+func parseS7(payload []byte, toServer bool) []otMessage {
+	// TPKT (RFC 1006) carrying a COTP PDU.
+	if len(payload) < 7 || payload[0] != 3 {
+		return nil
+	}
+	tpktLength := int(binary.BigEndian.Uint16(payload[2:]))
+	if tpktLength < 7 || tpktLength > len(payload) {
+		return nil
+	}
+	cotp := payload[4:tpktLength]
+	cotpLength := int(cotp[0])
+	if len(cotp) < 1+cotpLength || cotpLength < 2 {
+		return nil
+	}
+	switch cotp[1] {
+	case 0xe0: // Connection request; the destination TSAP selects the rack and slot
+		if !toServer || cotpLength < 6 {
+			return nil
+		}
+		for params := cotp[7 : 1+cotpLength]; len(params) >= 2 && len(params) >= 2+int(params[1]); params = params[2+int(params[1]):] {
+			if params[0] == 0xc2 && params[1] == 2 {
+				kind, ok := s7ConnectionTypes[params[2]]
+				if !ok {
+					kind = fmt.Sprintf("type %d", params[2])
+				}
+				return []otMessage{{ToServer: true, UnitID: fmt.Sprintf("rack %d slot %d", params[3]>>5, params[3]&0x1f), Function: "Connect (" + kind + ")"}}
+			}
+		}
+		return nil
+	case 0xf0: // Data
+	default:
+		return nil
+	}
+
+	s7 := cotp[1+cotpLength:]
+	if len(s7) < 10 || s7[0] != 0x32 {
+		return nil
+	}
+	rosctr := s7[1]
+	paramLength, dataLength := int(binary.BigEndian.Uint16(s7[6:])), int(binary.BigEndian.Uint16(s7[8:]))
+	header := 10
+	if rosctr == 2 || rosctr == 3 { // Ack and Ack_Data carry an error class and code
+		header = 12
+	}
+	if len(s7) < header+paramLength+dataLength || paramLength == 0 {
+		return nil
+	}
+	params, data := s7[header:header+paramLength], s7[header+paramLength:header+paramLength+dataLength]
+	switch {
+	case rosctr == 1 && toServer: // Job
+		return []otMessage{otMessage{}.request(s7Functions, int(params[0]), "0x%02X")}
+	case rosctr == 7 && len(params) >= 8: // Userdata
+		method, group := params[4], int(params[5]&0x0f)
+		if kind := params[5] >> 4; toServer && kind == 4 {
+			m := otMessage{}.request(s7UserdataGroups, group, "%d")
+			if group == 4 && params[6] == 1 {
+				m.Function, m.Operation = "Read SZL", model.OTRead
+			} else if group == 7 && params[6] == 2 {
+				m.Function, m.Operation = "Set Clock", model.OTWrite
+			}
+			return []otMessage{m}
+		} else if !toServer && method == 0x12 && group == 4 && params[6] == 1 {
+			if identity := s7ComponentIdentity(data); identity != "" {
+				return []otMessage{{Identity: identity}}
+			}
+		}
+	}
+	return nil
+}
+
+// s7ComponentIdentity reads the module type, name and serial number from an SZL 0x001C
+// (component identification) response.
+func s7ComponentIdentity(data []byte) string {
+	if len(data) < 12 || data[0] != 0xff || binary.BigEndian.Uint16(data[4:])&0x0fff != 0x001c {
+		return ""
+	}
+	recordLength, count := int(binary.BigEndian.Uint16(data[8:])), int(binary.BigEndian.Uint16(data[10:]))
+	if recordLength < 3 {
+		return ""
+	}
+	names := make(map[uint16]string)
+	for records := data[12:]; count > 0 && len(records) >= recordLength; count-- {
+		names[binary.BigEndian.Uint16(records)] = strings.TrimSpace(string(bytes.TrimRight(records[2:recordLength], "\x00 ")))
+		records = records[recordLength:]
+	}
+	var parts []string
+	for _, index := range []uint16{7, 2, 1} { // Module type, module name, automation system name
+		if name := names[index]; name != "" && !containsString(parts, name) {
+			parts = append(parts, name)
+		}
+	}
+	if serial := names[5]; serial != "" {
+		parts = append(parts, "serial "+serial)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// --- BACnet/IP ---
+
+var bacnetConfirmed = map[int]otFunction{
+	5: {"SubscribeCOV", model.OTRead}, 6: {"AtomicReadFile", model.OTRead}, 7: {"AtomicWriteFile", model.OTWrite},
+	8: {"AddListElement", model.OTWrite}, 9: {"RemoveListElement", model.OTWrite}, 10: {"CreateObject", model.OTWrite},
+	11: {"DeleteObject", model.OTWrite}, 12: {"ReadProperty", model.OTRead}, 14: {"ReadPropertyMultiple", model.OTRead},
+	15: {"WriteProperty", model.OTWrite}, 16: {"WritePropertyMultiple", model.OTWrite},
+	17: {"DeviceCommunicationControl", model.OTProgram}, 20: {"ReinitializeDevice", model.OTProgram},
+	26: {"ReadRange", model.OTRead},
+}
+
+var bacnetUnconfirmed = map[int]otFunction{
+	6: {"TimeSynchronization", model.OTWrite}, 7: {"Who-Has", model.OTRead}, 8: {"Who-Is", model.OTRead},
+	9: {"UTCTimeSynchronization", model.OTWrite}, 10: {"WriteGroup", model.OTWrite},
+}
+
+// parseBACnet decodes the service of a BACnet/IP request and the device instance and vendor
+// announced in I-Am messages.
+func parseBACnet(payload []byte) []otMessage {
+	if len(payload) < 6 || payload[0] != 0x81 {
+		return nil
+	}
+	npdu := payload[4:]
+	switch payload[1] {
+	case 0x0a, 0x0b: // Original-Unicast-NPDU, Original-Broadcast-NPDU
+	case 0x04: // Forwarded-NPDU carries the original source address
+		if len(npdu) < 6 {
+			return nil
+		}
+		npdu = npdu[6:]
+	default:
+		return nil
+	}
+	if len(npdu) < 2 || npdu[0] != 1 || npdu[1]&0x80 != 0 { // Network layer messages have no APDU
+		return nil
+	}
+	control, i := npdu[1], 2
+	if control&0x20 != 0 { // Destination network and address
+		if len(npdu) < i+3 {
+			return nil
+		}
+		i += 3 + int(npdu[i+2])
+	}
+	if control&0x08 != 0 { // Source network and address
+		if len(npdu) < i+3 {
+			return nil
+		}
+		i += 3 + int(npdu[i+2])
+	}
+	if control&0x20 != 0 {
+		i++ // Hop count
+	}
+	if len(npdu) < i+2 {
+		return nil
+	}
+	apdu := npdu[i:]
+	switch apdu[0] >> 4 {
+	case 0: // Confirmed request; segmented requests carry a sequence number and window size
+		service := 3
+		if apdu[0]&0x08 != 0 {
+			service = 5
+		}
+		if len(apdu) <= service {
+			return nil
+		}
+		return []otMessage{otMessage{}.request(bacnetConfirmed, int(apdu[service]), "%d")}
+	case 1: // Unconfirmed request
+		if apdu[1] == 0 { // I-Am, sent by the device
+			if identity := bacnetIAm(apdu[2:]); identity != "" {
+				return []otMessage{{Identity: identity}}
+			}
+			return nil
+		}
+		if _, ok := bacnetUnconfirmed[int(apdu[1])]; ok {
+			return []otMessage{otMessage{}.request(bacnetUnconfirmed, int(apdu[1]), "%d")}
+		}
+	}
+	return nil
+}
+
+// bacnetIAm reads the device instance and vendor ID of an I-Am request.
+func bacnetIAm(data []byte) string {
+	if len(data) < 5 || data[0] != 0xc4 { // Application tag 12 (object identifier), length 4
+		return ""
+	}
+	object := binary.BigEndian.Uint32(data[1:])
+	identity := fmt.Sprintf("device %d", object&0x3fffff)
+	// Max APDU length (unsigned), segmentation (enumerated), vendor ID (unsigned).
+	values := data[5:]
+	for field := 0; field < 3 && len(values) > 0; field++ {
+		length := int(values[0] & 0x07)
+		if len(values) < 1+length || length > 4 {
+			break
+		}
+		if field == 2 && values[0]>>4 == 2 {
+			vendor := 0
+			for _, b := range values[1 : 1+length] {
+				vendor = vendor<<8 | int(b)
+			}
+			identity += fmt.Sprintf(", vendor ID %d", vendor)
+		}
+		values = values[1+length:]
+	}
+	return identity
+}
+
+// --- EtherNet/IP and CIP ---
+
+var enipCommands = map[int]otFunction{
+	0x0004: {"ListServices", model.OTRead}, 0x0063: {"ListIdentity", model.OTRead}, 0x0064: {"ListInterfaces", model.OTRead},
+	0x0065: {"RegisterSession", ""}, 0x0066: {"UnRegisterSession", ""},
+}
+
+var cipServices = map[int]otFunction{
+	0x01: {"Get Attributes All", model.OTRead}, 0x02: {"Set Attributes All", model.OTWrite},
+	0x03: {"Get Attribute List", model.OTRead}, 0x04: {"Set Attribute List", model.OTWrite},
+	0x05: {"Reset", model.OTProgram}, 0x06: {"Start", model.OTProgram}, 0x07: {"Stop", model.OTProgram},
+	0x0e: {"Get Attribute Single", model.OTRead}, 0x10: {"Set Attribute Single", model.OTWrite},
+	0x4b: {"Execute PCCC", ""}, 0x4c: {"Read Tag", model.OTRead}, 0x4d: {"Write Tag", model.OTWrite},
+	0x4e: {"Forward Close", ""}, 0x52: {"Read Tag Fragmented", model.OTRead}, 0x53: {"Write Tag Fragmented", model.OTWrite},
+	0x54: {"Forward Open", ""}, 0x55: {"Get Instance Attribute List", model.OTRead},
+}
+
+const (
+	enipListIdentity = 0x0063
+	enipSendRRData   = 0x006f
+	enipSendUnitData = 0x0070
+)
+
+// parseENIP decodes EtherNet/IP encapsulation commands, the CIP services they carry and the
+// identity in ListIdentity replies.
+func parseENIP(payload []byte, toServer bool) []otMessage {
+	if len(payload) < 24 {
+		return nil
+	}
+	command := int(binary.LittleEndian.Uint16(payload))
+	length := int(binary.LittleEndian.Uint16(payload[2:]))
+	if len(payload) < 24+length {
+		return nil
+	}
+	data := payload[24 : 24+length]
+	if !toServer {
+		if command == enipListIdentity {
+			if identity := enipIdentity(data); identity != "" {
+				return []otMessage{{Identity: identity}}
+			}
+		}
+		return nil
+	}
+	if command != enipSendRRData && command != enipSendUnitData {
+		if _, ok := enipCommands[command]; !ok {
+			return nil
+		}
+		return []otMessage{otMessage{}.request(enipCommands, command, "0x%04X")}
+	}
+
+	// Common packet format: interface handle, timeout and the address and data items.
+	if len(data) < 8 {
+		return nil
+	}
+	items, count := data[8:], int(binary.LittleEndian.Uint16(data[6:]))
+	for ; count > 0 && len(items) >= 4; count-- {
+		itemType, itemLength := binary.LittleEndian.Uint16(items), int(binary.LittleEndian.Uint16(items[2:]))
+		if len(items) < 4+itemLength {
+			return nil
+		}
+		item := items[4 : 4+itemLength]
+		items = items[4+itemLength:]
+		switch itemType {
+		case 0x00b1: // Connected data starts with a sequence count
+			if len(item) < 2 {
+				return nil
+			}
+			item = item[2:]
+		case 0x00b2: // Unconnected data
+		default:
+			continue
+		}
+		if m, ok := cipRequest(item, true); ok {
+			return []otMessage{m}
+		}
+	}
+	return nil
+}
+
+// cipRequest decodes a CIP request, looking into the request embedded in an Unconnected Send
+// to the Connection Manager.
+func cipRequest(message []byte, unwrap bool) (otMessage, bool) {
+	if len(message) < 2 || message[0]&0x80 != 0 || len(message) < 2+2*int(message[1]) {
+		return otMessage{}, false
+	}
+	service, path := int(message[0]), message[2:2+2*int(message[1])]
+	rest := message[2+2*int(message[1]):]
+	if unwrap && service == 0x52 && bytes.HasPrefix(path, []byte{0x20, 0x06}) && len(rest) >= 4 {
+		size := int(binary.LittleEndian.Uint16(rest[2:]))
+		if len(rest) >= 4+size {
+			return cipRequest(rest[4:4+size], false)
+		}
+	}
+	return otMessage{}.request(cipServices, service, "0x%02X"), true
+}
+
+// enipIdentity reads the product name, revision and serial number of a ListIdentity reply.
+func enipIdentity(data []byte) string {
+	// Item count, then the CIP identity item: type, length, encapsulation version and socket address.
+	if len(data) < 2+4+2+16+15 || binary.LittleEndian.Uint16(data[2:]) != 0x000c {
+		return ""
+	}
+	item := data[6+2+16:]
+	vendor, productCode := binary.LittleEndian.Uint16(item), binary.LittleEndian.Uint16(item[4:])
+	major, minor := item[6], item[7]
+	serial := binary.LittleEndian.Uint32(item[10:])
+	nameLength := int(item[14])
+	if len(item) < 15+nameLength {
+		return ""
+	}
+	name := strings.TrimSpace(string(item[15 : 15+nameLength]))
+	return fmt.Sprintf("%s, revision %d.%03d, serial %08X (vendor %d, product code %d)", name, major, minor, serial, vendor, productCode)
+}
+
+// --- OPC UA ---
+
+// opcuaServices are the binary encoding IDs of the common service requests.
+var opcuaServices = map[int]otFunction{
+	422: {"FindServers", model.OTRead}, 428: {"GetEndpoints", model.OTRead},
+	461: {"CreateSession", ""}, 467: {"ActivateSession", ""}, 473: {"CloseSession", ""},
+	488: {"AddNodes", model.OTWrite}, 500: {"DeleteNodes", model.OTWrite}, 527: {"Browse", model.OTRead},
+	554: {"TranslateBrowsePathsToNodeIds", model.OTRead}, 631: {"Read", model.OTRead}, 664: {"HistoryRead", model.OTRead},
+	673: {"Write", model.OTWrite}, 712: {"Call", model.OTWrite}, 751: {"CreateMonitoredItems", model.OTRead},
+	787: {"CreateSubscription", model.OTRead}, 826: {"Publish", model.OTRead},
+}
+
+// parseOPCUA decodes client messages of OPC UA binary connections. The security policy of each
+// channel is remembered from its OpenSecureChannel request; service requests are only readable
+// on channels without security.
+func parseOPCUA(payload []byte, channels map[string]string, channel string) []otMessage {
+	if len(payload) < 8 {
+		return nil
+	}
+	size := int(binary.LittleEndian.Uint32(payload[4:]))
+	if size < 8 || size > len(payload) {
+		size = len(payload)
+	}
+	body := payload[8:size]
+	switch string(payload[:3]) {
+	case "HEL":
+		if len(body) < 24 {
+			return nil
+		}
+		if url, ok := opcuaString(body[20:]); ok && url != "" {
+			return []otMessage{{ToServer: true, Function: "Hello", Identity: "endpoint " + url}}
+		}
+	case "OPN":
+		if len(body) < 8 {
+			return nil
+		}
+		policy, ok := opcuaString(body[4:])
+		if !ok {
+			return nil
+		}
+		channels[channel] = policy
+		name := policy
+		if i := strings.LastIndex(policy, "#"); i >= 0 {
+			name = policy[i+1:]
+		}
+		return []otMessage{{ToServer: true, Function: "OpenSecureChannel (" + name + ")"}}
+	case "MSG":
+		if !strings.HasSuffix(channels[channel], "#None") || len(body) < 18 {
+			return nil
+		}
+		// Secure channel and token IDs, sequence and request IDs, then the request's type ID.
+		typeID := body[16:]
+		var id int
+		switch typeID[0] {
+		case 0x00:
+			id = int(typeID[1])
+		case 0x01:
+			if len(typeID) < 4 {
+				return nil
+			}
+			id = int(binary.LittleEndian.Uint16(typeID[2:]))
+		default:
+			return nil
+		}
+		if f, ok := opcuaServices[id]; ok {
+			return []otMessage{{ToServer: true, Function: f.Name, Operation: f.Operation}}
+		}
+	}
+	return nil
+}
+
+// opcuaString reads a length-prefixed UA string.
+func opcuaString(data []byte) (string, bool) {
+	if len(data) < 4 {
+		return "", false
+	}
+	length := int(int32(binary.LittleEndian.Uint32(data)))
+	if length < 0 {
+		return "", true
+	}
+	if len(data) < 4+length {
+		return "", false
+	}
+	return string(data[4 : 4+length]), true
+}
+
+// --- PROFINET DCP ---
+
+const (
+	dcpServiceGet      = 3
+	dcpServiceSet      = 4
+	dcpServiceIdentify = 5
+)
+
+// processProfinetDCP records PROFINET DCP requests and the identity of responding devices.
+// DCP runs directly over Ethernet, so hosts are found by MAC address.
+func processProfinetDCP(packet gopacket.Packet, networkMap *model.NetworkMap, summary *model.PcapSummary, sourceName string) {
+	eth, ok := packet.Layer(layers.LayerTypeEthernet).(*layers.Ethernet)
+	if !ok {
+		return
+	}
+	etherType, payload := eth.EthernetType, eth.Payload
+	if dot1q, ok := packet.Layer(layers.LayerTypeDot1Q).(*layers.Dot1Q); ok {
+		etherType, payload = dot1q.Type, dot1q.Payload
+	}
+	if etherType != profinetEtherType || len(payload) < 12 {
+		return
+	}
+	if frameID := binary.BigEndian.Uint16(payload); frameID < 0xfefc || frameID > 0xfeff {
+		return
+	}
+	service, response := payload[2], payload[3] != 0
+	dataLength := int(binary.BigEndian.Uint16(payload[10:]))
+	if len(payload) < 12+dataLength {
+		return
+	}
+	blocks := dcpBlocks(payload[12:12+dataLength], response || service == dcpServiceSet)
+	ts := packet.Metadata().Timestamp
+	srcMAC, dstMAC := strings.ToUpper(eth.SrcMAC.String()), strings.ToUpper(eth.DstMAC.String())
+
+	if response {
+		if service != dcpServiceIdentify && service != dcpServiceGet {
+			return
+		}
+		device := dcpHost(networkMap, srcMAC)
+		if ip := blocks[[2]byte{1, 2}]; len(ip) >= 4 && !bytes.Equal(ip[:4], []byte{0, 0, 0, 0}) {
+			device.IPv4Addresses[fmt.Sprintf("%d.%d.%d.%d", ip[0], ip[1], ip[2], ip[3])] = true
+		}
+		var parts []string
+		if name := strings.TrimRight(string(blocks[[2]byte{2, 2}]), "\x00"); name != "" {
+			parts = append(parts, name)
+		}
+		if vendor := strings.TrimRight(string(blocks[[2]byte{2, 1}]), "\x00"); vendor != "" {
+			parts = append(parts, vendor)
+		}
+		if id := blocks[[2]byte{2, 3}]; len(id) >= 4 {
+			parts = append(parts, fmt.Sprintf("vendor ID 0x%04X, device ID 0x%04X", binary.BigEndian.Uint16(id), binary.BigEndian.Uint16(id[2:])))
+		}
+		if len(parts) > 0 {
+			device.OTIdentities[otProfinet.Name] = strings.Join(parts, ", ")
+		}
+		return
+	}
+
+	var m otMessage
+	switch service {
+	case dcpServiceIdentify:
+		m = otMessage{Function: "Identify", Operation: model.OTRead}
+	case dcpServiceGet:
+		m = otMessage{Function: "Get", Operation: model.OTRead}
+	case dcpServiceSet:
+		m = otMessage{Function: "Set", Operation: model.OTWrite}
+		switch {
+		case blocks[[2]byte{5, 5}] != nil || blocks[[2]byte{5, 6}] != nil: // Factory reset, reset to factory
+			m.Function, m.Operation = "Set (factory reset)", model.OTProgram
+		case blocks[[2]byte{2, 2}] != nil:
+			m.Function = "Set (NameOfStation)"
+		case blocks[[2]byte{1, 2}] != nil:
+			m.Function = "Set (IP)"
+		}
+	default:
+		return
+	}
+	op := model.OTOperation{Protocol: otProfinet.Name, Function: m.Function, Operation: m.Operation}
+	evidence := model.Vulnerability{EvidenceFile: sourceName, EvidencePacket: summary.SourcePackets[sourceName]}
+	client := dcpHost(networkMap, srcMAC)
+	op.Role, op.Peer = "client", dstMAC
+	recordOTOperation(client, op, ts)
+	evidence.Description = fmt.Sprintf("Host issued unauthenticated %s %s to %s, e.g. %s. %s", otProfinet.Name, otOperationKinds[m.Operation], dstMAC, m.Function, otProfinet.Risk)
+	addOTFinding(client, otProfinet, m.Operation, 0, evidence)
+	if eth.DstMAC[0]&0x01 == 0 { // Identify requests go to a multicast address
+		device := dcpHost(networkMap, dstMAC)
+		op.Role, op.Peer = "server", srcMAC
+		recordOTOperation(device, op, ts)
+		evidence.Description = fmt.Sprintf("Host received unauthenticated %s %s from %s, e.g. %s. %s", otProfinet.Name, otOperationKinds[m.Operation], srcMAC, m.Function, otProfinet.Risk)
+		addOTFinding(device, otProfinet, m.Operation, 0, evidence)
+	}
+}
+
+// dcpBlocks indexes the blocks of a DCP PDU by option and suboption. Response blocks start
+// with block info and Set request blocks with a qualifier, which are skipped.
+func dcpBlocks(data []byte, skip bool) map[[2]byte][]byte {
+	blocks := make(map[[2]byte][]byte)
+	for len(data) >= 4 {
+		length := int(binary.BigEndian.Uint16(data[2:]))
+		if len(data) < 4+length {
+			break
+		}
+		value := data[4 : 4+length]
+		if skip && len(value) >= 2 {
+			value = value[2:]
+		}
+		blocks[[2]byte{data[0], data[1]}] = value
+		// Blocks are padded to an even length, but the last one's padding may be missing.
+		next := 4 + length + length%2
+		if next > len(data) {
+			next = len(data)
+		}
+		data = data[next:]
+	}
+	return blocks
+}
+
+// dcpHost returns the host for a MAC address seen in a DCP frame.
+func dcpHost(networkMap *model.NetworkMap, mac string) *model.Host {
+	host, ok := networkMap.Hosts[mac]
+	if !ok {
+		host = model.NewHost(mac)
+		host.DiscoveredBy = "Pcap"
+		networkMap.Hosts[mac] = host
+	}
+	return host
+}
@@ -0,0 +1,268 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+var dcpDeviceMAC = net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x20}
+
+func modbusADU(transaction uint16, unit byte, pdu ...byte) []byte {
+	adu := make([]byte, 7, 7+len(pdu))
+	binary.BigEndian.PutUint16(adu, transaction)
+	binary.BigEndian.PutUint16(adu[4:], uint16(1+len(pdu)))
+	adu[6] = unit
+	return append(adu, pdu...)
+}
+
+// tpkt wraps a COTP PDU and its payload in a TPKT header.
+func tpkt(cotp []byte, payload ...byte) []byte {
+	packet := append([]byte{3, 0, 0, 0}, cotp...)
+	packet = append(packet, payload...)
+	binary.BigEndian.PutUint16(packet[2:], uint16(len(packet)))
+	return packet
+}
+
+func s7Message(rosctr byte, params, data []byte) []byte {
+	header := []byte{0x32, rosctr, 0, 0, 0, 1, 0, 0, 0, 0}
+	binary.BigEndian.PutUint16(header[6:], uint16(len(params)))
+	binary.BigEndian.PutUint16(header[8:], uint16(len(data)))
+	return tpkt([]byte{2, 0xf0, 0x80}, append(append(header, params...), data...)...)
+}
+
+func enipMessage(command uint16, data []byte) []byte {
+	header := make([]byte, 24)
+	binary.LittleEndian.PutUint16(header, command)
+	binary.LittleEndian.PutUint16(header[2:], uint16(len(data)))
+	return append(header, data...)
+}
+
+func opcuaMessage(messageType string, body []byte) []byte {
+	header := append([]byte(messageType), 'F', 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(header[4:], uint32(8+len(body)))
+	return append(header, body...)
+}
+
+func opcuaOpen(policy string) []byte {
+	body := make([]byte, 8, 8+len(policy))
+	binary.LittleEndian.PutUint32(body[4:], uint32(len(policy)))
+	return opcuaMessage("OPN", append(body, policy...))
+}
+
+func dcpBlock(option, suboption byte, prefix bool, value string) []byte {
+	data := []byte(value)
+	if prefix {
+		data = append([]byte{0, 0}, data...)
+	}
+	block := []byte{option, suboption, 0, 0}
+	binary.BigEndian.PutUint16(block[2:], uint16(len(data)))
+	block = append(block, data...)
+	if len(data)%2 == 1 {
+		block = append(block, 0)
+	}
+	return block
+}
+
+func dcpFrame(t *testing.T, src, dst net.HardwareAddr, frameID uint16, service, serviceType byte, blocks ...[]byte) gopacket.Packet {
+	t.Helper()
+	pdu := make([]byte, 12)
+	binary.BigEndian.PutUint16(pdu, frameID)
+	pdu[2], pdu[3] = service, serviceType
+	for _, block := range blocks {
+		pdu = append(pdu, block...)
+	}
+	binary.BigEndian.PutUint16(pdu[10:], uint16(len(pdu)-12))
+	eth := &layers.Ethernet{SrcMAC: src, DstMAC: dst, EthernetType: profinetEtherType}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{}, eth, gopacket.Payload(pdu)); err != nil {
+		t.Fatalf("could not build DCP frame: %v", err)
+	}
+	return gopacket.NewPacket(buf.Bytes(), layers.LayerTypeEthernet, gopacket.Default)
+}
+
+// TestOTProtocols verifies that industrial protocol requests are recorded with their function
+// codes and units on both hosts, that device identities are read from responses, that roles are
+// assigned and that writes and program changes are reported with evidence.
+func TestOTProtocols(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	networkMap, summary := model.NewNetworkMap(), model.NewPcapSummary()
+
+	identification := []byte{0x2b, 0x0e, 1, 1, 0, 0, 3}
+	for id, value := range []string{"Schneider Electric", "BMX P34 2020", "v2.70"} {
+		identification = append(append(identification, byte(id), byte(len(value))), value...)
+	}
+	szl := []byte{0xff, 0x09, 0, 0, 0x00, 0x1c, 0, 0, 0, 34, 0, 2}
+	for index, name := range map[uint16]string{2: "PLC_1", 7: "CPU 1516-3 PN/DP"} {
+		record := make([]byte, 34)
+		binary.BigEndian.PutUint16(record, index)
+		copy(record[2:], name)
+		szl = append(szl, record...)
+	}
+	dnp3 := []byte{0x05, 0x64, 8, 0xc4, 10, 0, 1, 0, 0, 0, 0xc0, 0xc1, 0x05, 0, 0}
+	identity := make([]byte, 2+4+2+16+15)
+	binary.LittleEndian.PutUint16(identity, 1)
+	binary.LittleEndian.PutUint16(identity[2:], 0x000c)
+	item := identity[24:]
+	binary.LittleEndian.PutUint16(item, 1)
+	binary.LittleEndian.PutUint16(item[4:], 54)
+	item[6], item[7] = 32, 11
+	binary.LittleEndian.PutUint32(item[10:], 0x12345678)
+	item[14] = 20
+	identity = append(identity, "1756-L71/B LOGIX5571"...)
+	writeTag := append([]byte{0x4d, 0x03, 0x91, 0x04}, "Tag1\xc4\x00\x01\x00\x2a\x00\x00\x00"...)
+	unconnectedSend := append([]byte{0x52, 0x02, 0x20, 0x06, 0x24, 0x01, 0x0a, 0x0e, byte(len(writeTag)), 0}, writeTag...)
+	sendRRData := append([]byte{0, 0, 0, 0, 0x0a, 0, 2, 0, 0, 0, 0, 0, 0xb2, 0, byte(len(unconnectedSend)), 0}, unconnectedSend...)
+	opcuaWrite := opcuaMessage("MSG", []byte{1, 0, 0, 0, 1, 0, 0, 0, 2, 0, 0, 0, 2, 0, 0, 0, 0x01, 0x00, 0xa1, 0x02})
+
+	packets := []gopacket.Packet{
+		tcpTestSegment(t, true, 40000, modbusPort, 1, false, modbusADU(1, 1, 3, 0, 0, 0, 10), start),
+		tcpTestSegment(t, true, 40000, modbusPort, 1, false, modbusADU(2, 1, 6, 0, 10, 0, 100), start),
+		tcpTestSegment(t, true, 40000, modbusPort, 1, false, modbusADU(3, 1, 0x2b, 0x0e, 1, 0), start),
+		tcpTestSegment(t, false, 40000, modbusPort, 1, false, modbusADU(3, 1, identification...), start),
+		tcpTestSegment(t, true, 40001, s7Port, 1, false, tpkt([]byte{17, 0xe0, 0, 0, 0, 1, 0, 0xc1, 2, 1, 0, 0xc2, 2, 1, 2, 0xc0, 1, 0x0a}), start),
+		tcpTestSegment(t, true, 40001, s7Port, 1, false, s7Message(1, []byte{0x1b, 0}, nil), start),
+		tcpTestSegment(t, false, 40001, s7Port, 1, false, s7Message(7, []byte{0, 1, 0x12, 8, 0x12, 0x84, 1, 1, 0, 0, 0, 0}, szl), start),
+		tcpTestSegment(t, true, 40002, dnp3Port, 1, false, dnp3, start),
+		udpTestPacket(t, true, bacnetPort, bacnetPort, []byte{0x81, 0x0a, 0, 12, 0x01, 0x04, 0x00, 0x05, 0x01, 0x0f, 0x0c, 0x00}, start),
+		udpTestPacket(t, false, bacnetPort, bacnetPort, []byte{0x81, 0x0b, 0, 20, 0x01, 0x00, 0x10, 0x00, 0xc4, 0x02, 0x00, 0x04, 0xd2, 0x22, 0x05, 0xc4, 0x91, 0x00, 0x21, 0x05}, start),
+		udpTestPacket(t, false, 50000, enipPort, enipMessage(enipListIdentity, identity), start),
+		tcpTestSegment(t, true, 40003, enipPort, 1, false, enipMessage(enipSendRRData, sendRRData), start),
+		tcpTestSegment(t, true, 40004, opcuaPort, 1, false, opcuaOpen("http://opcfoundation.org/UA/SecurityPolicy#None"), start),
+		tcpTestSegment(t, true, 40004, opcuaPort, 1, false, opcuaWrite, start),
+		tcpTestSegment(t, true, 40005, opcuaPort, 1, false, opcuaOpen("http://opcfoundation.org/UA/SecurityPolicy#Basic256Sha256"), start),
+		tcpTestSegment(t, true, 40005, opcuaPort, 1, false, opcuaWrite, start),
+		dcpFrame(t, dcpDeviceMAC, tlsClientMAC, 0xfeff, dcpServiceIdentify, 1,
+			dcpBlock(2, 2, true, "plc-line1"), dcpBlock(2, 1, true, "S7-1500"), dcpBlock(1, 2, true, "\x0a\x00\x00\x14\xff\xff\xff\x00\x0a\x00\x00\x01")),
+		dcpFrame(t, tlsClientMAC, dcpDeviceMAC, 0xfefd, dcpServiceSet, 0, dcpBlock(2, 2, true, "renamed")),
+	}
+
+	// A Who-Is broadcast must not create a host for the broadcast address.
+	eth := &layers.Ethernet{SrcMAC: tlsClientMAC, DstMAC: layers.EthernetBroadcast, EthernetType: layers.EthernetTypeIPv4}
+	ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolUDP, SrcIP: net.IP{10, 0, 0, 5}, DstIP: net.IP{10, 0, 0, 255}}
+	udp := &layers.UDP{SrcPort: bacnetPort, DstPort: bacnetPort}
+	udp.SetNetworkLayerForChecksum(ip)
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}, eth, ip, udp, gopacket.Payload{0x81, 0x0b, 0, 8, 0x01, 0x00, 0x10, 0x08}); err != nil {
+		t.Fatalf("could not build Who-Is: %v", err)
+	}
+	packets = append(packets, gopacket.NewPacket(buf.Bytes(), layers.LayerTypeEthernet, gopacket.Default))
+
+	for _, packet := range packets {
+		ProcessPacket(packet, networkMap, summary, "plant.pcap")
+	}
+	client, server, device := networkMap.Hosts["02:00:00:00:00:05"], networkMap.Hosts["02:00:00:00:00:0A"], networkMap.Hosts["02:00:00:00:00:20"]
+	if client == nil || server == nil || device == nil {
+		t.Fatalf("Expected the client, server and DCP device hosts, got %v", networkMap.Hosts)
+	}
+	if _, ok := networkMap.Hosts["FF:FF:FF:FF:FF:FF"]; ok {
+		t.Error("Expected no host for the broadcast address")
+	}
+
+	for key, count := range map[string]int{
+		"Modbus/TCP|server|10.0.0.5|1|Read Holding Registers (3)":         1,
+		"Modbus/TCP|server|10.0.0.5|1|Write Single Register (6)":          1,
+		"Modbus/TCP|server|10.0.0.5|1|Read Device Identification (43/14)": 1,
+		"S7comm|server|10.0.0.5|rack 0 slot 2|Connect (PG)":               1,
+		"S7comm|server|10.0.0.5||Download Block (0x1B)":                   1,
+		"DNP3|server|10.0.0.5|10|Direct Operate (5)":                      1,
+		"BACnet/IP|server|10.0.0.5||WriteProperty (15)":                   1,
+		"EtherNet/IP|server|10.0.0.5||Write Tag (0x4D)":                   1,
+		"OPC UA|server|10.0.0.5||OpenSecureChannel (None)":                1,
+		"OPC UA|server|10.0.0.5||OpenSecureChannel (Basic256Sha256)":      1,
+		"OPC UA|server|10.0.0.5||Write":                                   1,
+	} {
+		if op := server.OTOperations[key]; op == nil || op.Count != count {
+			t.Errorf("Expected %s %d times, got %+v", key, count, op)
+		}
+	}
+	if op := client.OTOperations["Modbus/TCP|client|10.0.0.10|1|Write Single Register (6)"]; op == nil || op.Port != modbusPort || op.Operation != model.OTWrite {
+		t.Errorf("Unexpected client operation %+v", op)
+	}
+	if len(client.OTOperations) != len(server.OTOperations)+2 { // BACnet Who-Is and the DCP Set
+		t.Errorf("Unexpected client operations %v", client.OTOperations)
+	}
+
+	for protocol, want := range map[string]string{
+		"Modbus/TCP":  "Schneider Electric BMX P34 2020 v2.70",
+		"S7comm":      "CPU 1516-3 PN/DP, PLC_1",
+		"BACnet/IP":   "device 1234, vendor ID 5",
+		"EtherNet/IP": "1756-L71/B LOGIX5571, revision 32.011, serial 12345678 (vendor 1, product code 54)",
+	} {
+		if got := server.OTIdentities[protocol]; got != want {
+			t.Errorf("%s identity = %q, want %q", protocol, got, want)
+		}
+	}
+	if got := device.OTIdentities["PROFINET DCP"]; got != "plc-line1, S7-1500" || !device.IPv4Addresses["10.0.0.20"] {
+		t.Errorf("Unexpected DCP device %v %v", got, device.IPv4Addresses)
+	}
+
+	if client.OTRole() != model.OTRoleEngineering || server.OTRole() != model.OTRoleController || device.OTRole() != model.OTRoleController {
+		t.Errorf("Unexpected roles %q, %q, %q", client.OTRole(), server.OTRole(), device.OTRole())
+	}
+
+	findings := make(map[string]model.Vulnerability)
+	for _, vulns := range server.Findings {
+		for _, v := range vulns {
+			findings[v.CVE] = v
+		}
+	}
+	for id, want := range map[string]struct {
+		category model.FindingCategory
+		port     int
+		packet   int
+	}{
+		"OT-MODBUS-WRITE":   {model.PotentialFinding, modbusPort, 2},
+		"OT-S7COMM-PROGRAM": {model.CriticalFinding, s7Port, 6},
+		"OT-DNP3-WRITE":     {model.PotentialFinding, dnp3Port, 8},
+		"OT-BACNET-WRITE":   {model.PotentialFinding, bacnetPort, 9},
+		"OT-ENIP-WRITE":     {model.PotentialFinding, enipPort, 12},
+		"OT-OPCUA-WRITE":    {model.PotentialFinding, opcuaPort, 14},
+	} {
+		if v, ok := findings[id]; !ok || v.Category != want.category || v.PortID != want.port || v.Source != otSource || v.EvidencePacket != want.packet {
+			t.Errorf("Unexpected %s finding %+v", id, v)
+		}
+	}
+	if len(findings) != 6 {
+		t.Errorf("Unexpected server findings %v", findings)
+	}
+	if d := findings["OT-MODBUS-WRITE"].Description; !strings.HasPrefix(d, "Host received unauthenticated Modbus/TCP writes from 10.0.0.5, e.g. Write Single Register (6) on unit 1.") {
+		t.Errorf("Unexpected description %q", d)
+	}
+	if len(device.Findings[model.PotentialFinding]) != 1 || device.Findings[model.PotentialFinding][0].CVE != "OT-PNDCP-WRITE" {
+		t.Errorf("Expected the DCP Set to be reported on the device, got %v", device.Findings)
+	}
+	if len(client.Findings[model.PotentialFinding])+len(client.Findings[model.CriticalFinding]) != 7 {
+		t.Errorf("Unexpected client findings %v", client.Findings)
+	}
+}
+
+func TestOTRole(t *testing.T) {
+	host := model.NewHost("02:00:00:00:00:30")
+	if role := host.OTRole(); role != "" {
+		t.Errorf("Expected no role, got %q", role)
+	}
+	host.OTOperations["a"] = &model.OTOperation{Role: "client", Operation: model.OTWrite}
+	if role := host.OTRole(); role != model.OTRoleHMI {
+		t.Errorf("Expected %q, got %q", model.OTRoleHMI, role)
+	}
+	host.OTIdentities["Modbus/TCP"] = "gateway"
+	if role := host.OTRole(); role != model.OTRoleGateway {
+		t.Errorf("Expected %q, got %q", model.OTRoleGateway, role)
+	}
+}
+
+// TestDCPBlocksMissingPadding verifies that a final odd-length block without its padding byte is
+// still read.
+func TestDCPBlocksMissingPadding(t *testing.T) {
+	blocks := dcpBlocks([]byte{2, 2, 0, 3, 'a', 'b', 'c'}, false)
+	if got := string(blocks[[2]byte{2, 2}]); got != "abc" {
+		t.Errorf("Expected the unpadded block, got %q", got)
+	}
+}
@@ -20,6 +20,7 @@ var (
 // ProcessPacket contains the core logic for analyzing a single packet.
 func ProcessPacket(packet gopacket.Packet, networkMap *model.NetworkMap, summary *model.PcapSummary, sourceName string) {
 	summary.TotalPackets++
+	summary.SourcePackets[sourceName]++
 
 	for _, layer := range packet.Layers() {
 		summary.ProtocolCounts[layer.LayerType().String()]++
@@ -33,36 +34,19 @@ func ProcessPacket(packet gopacket.Packet, networkMap *model.NetworkMap, summary
 			summary.PacketSources[packet] = sourceName
 		}
 	}
+	if eap, ok := packet.Layer(layers.LayerTypeEAP).(*layers.EAP); ok {
+		processEAP(packet, eap, networkMap, summary, sourceName)
+	}
 
 	// --- 802.11 Wireless Frame Processing ---
 	dot11Layer := packet.Layer(layers.LayerTypeDot11)
 	if dot11Layer != nil {
 		dot11, _ := dot11Layer.(*layers.Dot11)
-		var mgmtPayload []byte
-		if beaconLayer := packet.Layer(layers.LayerTypeDot11MgmtBeacon); beaconLayer != nil {
-			mgmtPayload = beaconLayer.LayerPayload()
-			ssid := getSSIDFromPayload(mgmtPayload)
-			if _, ok := summary.AdvertisedAPs[ssid]; !ok {
-				summary.AdvertisedAPs[ssid] = make(map[string]bool)
-			}
-			summary.AdvertisedAPs[ssid][dot11.Address2.String()] = true
-		} else if probeRespLayer := packet.Layer(layers.LayerTypeDot11MgmtProbeResp); probeRespLayer != nil {
-			mgmtPayload = probeRespLayer.LayerPayload()
-			ssid := getSSIDFromPayload(mgmtPayload)
-			if _, ok := summary.AdvertisedAPs[ssid]; !ok {
-				summary.AdvertisedAPs[ssid] = make(map[string]bool)
-			}
-			summary.AdvertisedAPs[ssid][dot11.Address2.String()] = true
-		} else if probeReqLayer := packet.Layer(layers.LayerTypeDot11MgmtProbeReq); probeReqLayer != nil {
-			mgmtPayload = probeReqLayer.LayerPayload()
-			ssid := getSSIDFromPayload(mgmtPayload)
-			if _, ok := summary.AllProbeRequests[ssid]; !ok {
-				summary.AllProbeRequests[ssid] = make(map[string]bool)
-			}
-			summary.AllProbeRequests[ssid][dot11.Address2.String()] = true
-		}
+		processWirelessFrame(packet, dot11, summary, sourceName)
 	}
 
+	processProfinetDCP(packet, networkMap, summary, sourceName)
+
 	// --- IP-Based Traffic Processing (Layer 3) ---
 
 	var srcMAC, dstMAC, srcIP, dstIP string
@@ -71,6 +55,9 @@ func ProcessPacket(packet gopacket.Packet, networkMap *model.NetworkMap, summary
 		eth, _ := ethLayer.(*layers.Ethernet)
 		srcMAC = eth.SrcMAC.String()
 		dstMAC = eth.DstMAC.String()
+	} else if addr := cookedCaptureAddress(packet); addr != "" {
+		// Cooked captures only record the sender's address.
+		srcMAC = addr
 	}
 
 	if dot11Layer != nil && (packet.Layer(layers.LayerTypeIPv4) != nil || packet.Layer(layers.LayerTypeIPv6) != nil) {
@@ -113,30 +100,58 @@ func ProcessPacket(packet gopacket.Packet, networkMap *model.NetworkMap, summary
 		localMAC, localIP, remoteIP = dstMAC, dstIP, srcIP
 	}
 
-	if localMAC == "" {
-		return
-	}
-
-	host, found := networkMap.Hosts[strings.ToUpper(localMAC)]
-	if !found {
-		host = model.NewHost(strings.ToUpper(localMAC))
-		host.DiscoveredBy = "Pcap"
-		networkMap.Hosts[strings.ToUpper(localMAC)] = host
-	}
-	host.IPv4Addresses[localIP] = true
+	host := packetHost(networkMap, strings.ToUpper(localMAC), localIP)
 
 	if _, ok := host.Communications[remoteIP]; !ok {
 		host.Communications[remoteIP] = &model.Communication{CounterpartIP: remoteIP}
 	}
 	host.Communications[remoteIP].PacketCount++
 
-	if dnsLayer := packet.Layer(layers.LayerTypeDNS); dnsLayer != nil {
-		dns, _ := dnsLayer.(*layers.DNS)
-		if dns.QR == false {
-			for _, q := range dns.Questions {
-				host.DNSLookups[string(q.Name)] = true
+	if dns, ok := packet.Layer(layers.LayerTypeDNS).(*layers.DNS); ok {
+		// The client sends the query and receives the response; only local clients are tracked.
+		clientIP, resolverIP, clientIsLocal := srcIP, dstIP, srcIsLocal
+		if dns.QR {
+			clientIP, resolverIP, clientIsLocal = dstIP, srcIP, dstIsLocal
+		}
+		if clientIsLocal {
+			client := host
+			if clientIP != localIP {
+				client = packetHost(networkMap, strings.ToUpper(dstMAC), dstIP)
 			}
+			processDNS(dns, client, clientIP, resolverIP, networkMap, summary, packet.Metadata().Timestamp)
+		}
+	}
+
+	// Protocol findings and operations go on the local hosts taking part; the other local host
+	// is only created once a detector matches, and never for a broadcast or multicast address.
+	localHost := func(ip string) *model.Host {
+		switch {
+		case ip == localIP:
+			return host
+		case ip == dstIP && dstIsLocal && !isGroupMAC(dstMAC):
+			return packetHost(networkMap, strings.ToUpper(dstMAC), dstIP)
+		}
+		return nil
+	}
+
+	if tcp, ok := packet.Layer(layers.LayerTypeTCP).(*layers.TCP); ok {
+		segment := &transportPacket{Transport: "TCP", SrcPort: int(tcp.SrcPort), DstPort: int(tcp.DstPort), Payload: tcp.Payload}
+		checkInsecureProtocols(segment, srcIP, dstIP, localHost, summary, sourceName)
+		processOT(segment, srcIP, dstIP, localHost, summary, sourceName, packet.Metadata().Timestamp)
+		if srcIsLocal {
+			recordDoTConnection(tcp, host, dstIP, packet.Metadata().Timestamp)
+		}
+		processTLS(packet, tcp, srcIP, dstIP, host, networkMap, summary, sourceName)
+		if tcp.SrcPort == 21 || tcp.DstPort == 21 {
+			processFTPControl(tcp, srcIP, dstIP, summary)
 		}
+		trackTCPStream(packet, tcp, srcIP, dstIP, host, networkMap, summary, sourceName)
+	}
+	if udp, ok := packet.Layer(layers.LayerTypeUDP).(*layers.UDP); ok {
+		datagram := &transportPacket{Transport: "UDP", SrcPort: int(udp.SrcPort), DstPort: int(udp.DstPort), Payload: udp.Payload}
+		checkInsecureProtocols(datagram, srcIP, dstIP, localHost, summary, sourceName)
+		processOT(datagram, srcIP, dstIP, localHost, summary, sourceName, packet.Metadata().Timestamp)
+		processTFTP(udp, srcIP, dstIP, host, summary, packet.Metadata().Timestamp, sourceName)
 	}
 
 	// Check for secrets in the application layer payload
@@ -145,6 +160,41 @@ func ProcessPacket(packet gopacket.Packet, networkMap *model.NetworkMap, summary
 	}
 }
 
+// packetHost returns the host for a local address seen in a packet. Without a link-layer
+// address (raw IP, VPN and tunnel interfaces, or the receiving side of a cooked capture) the host
+// is keyed by its IP, as for scanner imports; a placeholder is adopted once its MAC shows up.
+func packetHost(networkMap *model.NetworkMap, mac, ip string) *model.Host {
+	if mac == "" {
+		host := findHostByIP(networkMap, ip)
+		if host == nil {
+			host = model.NewHost("IP:" + ip)
+			host.DiscoveredBy = "Pcap"
+			networkMap.Hosts[host.MACAddress] = host
+		}
+		host.IPv4Addresses[ip] = true
+		return host
+	}
+
+	host, found := networkMap.Hosts[mac]
+	if !found {
+		host = model.NewHost(mac)
+		host.DiscoveredBy = "Pcap"
+		networkMap.Hosts[mac] = host
+	}
+	if placeholder, ok := networkMap.Hosts["IP:"+ip]; ok {
+		mergeHost(host, placeholder)
+		delete(networkMap.Hosts, "IP:"+ip)
+	}
+	host.IPv4Addresses[ip] = true
+	return host
+}
+
+// isGroupMAC reports whether a MAC address is a broadcast or multicast address.
+func isGroupMAC(mac string) bool {
+	hw, err := net.ParseMAC(mac)
+	return err == nil && hw[0]&0x01 != 0
+}
+
 func checkForSecrets(payload []byte, hostMAC, remoteIP string, summary *model.PcapSummary, pcapFile string) {
 	payloadStr := string(payload)
 
@@ -179,24 +229,6 @@ func checkForSecrets(payload []byte, hostMAC, remoteIP string, summary *model.Pc
 	}
 }
 
-func getSSIDFromPayload(payload []byte) string {
-	for len(payload) >= 2 {
-		id := layers.Dot11InformationElementID(payload[0])
-		length := int(payload[1])
-
-		if len(payload) < 2+length {
-			return ""
-		}
-
-		if id == layers.Dot11InformationElementIDSSID {
-			return string(payload[2 : 2+length])
-		}
-
-		payload = payload[2+length:]
-	}
-	return ""
-}
-
 func getSessionKey(packet gopacket.Packet) string {
 	if dot11Layer := packet.Layer(layers.LayerTypeDot11); dot11Layer != nil {
 		dot11, _ := dot11Layer.(*layers.Dot11)
@@ -0,0 +1,280 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/pcapgo"
+)
+
+var pcapngMagic = []byte{0x0a, 0x0d, 0x0d, 0x0a}
+
+// captureFile reads packets from a pcap or pcapng stream with the pure-Go readers from pcapgo,
+// so offline captures need neither cgo nor libpcap.
+type captureFile struct {
+	format   string // "pcap" or "pcapng"
+	pcap     *pcapgo.Reader
+	ng       *pcapgo.NgReader
+	sections []captureSection // Completed pcapng sections; the current one is read at the end
+
+	// pcapgo truncates link types to gopacket's uint8 LinkType, so the values stored in the file
+	// are read alongside it: from the pcap file header, or by ngBlocks for pcapng interfaces.
+	linkType uint32
+	ngBlocks *ngBlockScanner
+}
+
+// captureSection is the metadata of one pcapng section: who wrote it and on which interfaces.
+type captureSection struct {
+	info       pcapgo.NgSectionInfo
+	interfaces []pcapgo.NgInterface
+	linkTypes  []uint32 // Link type of each interface as stored in the file
+}
+
+// openCapture detects the capture format from the stream's magic number. pcapng files may
+// contain several sections and interfaces with different link types; each packet is decoded
+// with the link type of the interface it was captured on.
+func openCapture(r io.Reader) (*captureFile, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(len(pcapngMagic))
+	if !bytes.Equal(magic, pcapngMagic) {
+		header, _ := br.Peek(24)
+		reader, err := pcapgo.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return &captureFile{format: "pcap", pcap: reader, linkType: pcapHeaderLinkType(header)}, nil
+	}
+
+	c := &captureFile{format: "pcapng", ngBlocks: &ngBlockScanner{}}
+	opts := pcapgo.NgReaderOptions{
+		WantMixedLinkType: true,
+		SectionEndCallback: func(interfaces []pcapgo.NgInterface, info pcapgo.NgSectionInfo) {
+			c.sections = append(c.sections, captureSection{info: info, interfaces: interfaces})
+		},
+	}
+	reader, err := pcapgo.NewNgReader(io.TeeReader(br, c.ngBlocks), opts)
+	if err != nil {
+		return nil, err
+	}
+	c.ng = reader
+	return c, nil
+}
+
+// next returns the next packet, or io.EOF once the capture has been read.
+func (c *captureFile) next() (gopacket.Packet, error) {
+	var data []byte
+	var ci gopacket.CaptureInfo
+	var err error
+	linkType := c.linkType
+	if c.ng != nil {
+		data, ci, err = c.ng.ReadPacketData()
+		if err == nil {
+			linkType = c.ngBlocks.linkType(len(c.sections), ci.InterfaceIndex)
+		}
+	} else {
+		data, ci, err = c.pcap.ReadPacketData()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	packet := gopacket.NewPacket(data, LinkTypeDecoder(linkType), gopacket.NoCopy)
+	md := packet.Metadata()
+	md.CaptureInfo = ci
+	md.Truncated = md.Truncated || ci.CaptureLength < ci.Length
+	return packet, nil
+}
+
+// allSections returns the metadata of every section read so far, including the current one.
+func (c *captureFile) allSections() []captureSection {
+	if c.ng == nil {
+		return nil
+	}
+	current := captureSection{info: c.ng.SectionInfo()}
+	for i := 0; i < c.ng.NInterfaces(); i++ {
+		if intf, err := c.ng.Interface(i); err == nil {
+			current.interfaces = append(current.interfaces, intf)
+		}
+	}
+	sections := append(append([]captureSection(nil), c.sections...), current)
+	for i := range sections {
+		for j := range sections[i].interfaces {
+			sections[i].linkTypes = append(sections[i].linkTypes, c.ngBlocks.linkType(i, j))
+		}
+	}
+	return sections
+}
+
+// pcapHeaderLinkType returns the link type stored in a pcap file header, in the byte order its
+// magic number indicates.
+func pcapHeaderLinkType(header []byte) uint32 {
+	if len(header) < 24 {
+		return 0
+	}
+	var order binary.ByteOrder = binary.BigEndian
+	if magic := binary.LittleEndian.Uint32(header); magic == 0xa1b2c3d4 || magic == 0xa1b23c4d {
+		order = binary.LittleEndian
+	}
+	return order.Uint32(header[20:24])
+}
+
+// ngBlockScanner follows the block structure of a pcapng stream as pcapgo reads it and records
+// the link type of every interface description block, per section.
+type ngBlockScanner struct {
+	order    binary.ByteOrder
+	head     []byte // Start of the current block: type, length and the first body bytes
+	skip     int    // Bytes left in the current block after head
+	sections [][]uint32
+}
+
+// Write consumes the next bytes of the stream.
+func (s *ngBlockScanner) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		if s.skip > 0 {
+			k := min(s.skip, len(p))
+			s.skip, p = s.skip-k, p[k:]
+			continue
+		}
+		k := min(12-len(s.head), len(p))
+		s.head, p = append(s.head, p[:k]...), p[k:]
+		if len(s.head) == 12 {
+			s.block(s.head)
+			s.head = s.head[:0]
+		}
+	}
+	return n, nil
+}
+
+// block handles the first 12 bytes of a block. A section header starts a new list of interfaces
+// and sets the byte order; an interface description adds its link type.
+func (s *ngBlockScanner) block(head []byte) {
+	if bytes.Equal(head[:4], pcapngMagic) {
+		s.order = binary.BigEndian
+		if binary.LittleEndian.Uint32(head[8:12]) == 0x1a2b3c4d {
+			s.order = binary.LittleEndian
+		}
+		s.sections = append(s.sections, nil)
+	}
+	if s.order == nil {
+		return
+	}
+	if s.order.Uint32(head[:4]) == 1 && len(s.sections) > 0 {
+		last := len(s.sections) - 1
+		s.sections[last] = append(s.sections[last], uint32(s.order.Uint16(head[8:10])))
+	}
+	s.skip = max(int(s.order.Uint32(head[4:8]))-12, 0)
+}
+
+// linkType returns the link type of an interface in a section, both counted from zero.
+func (s *ngBlockScanner) linkType(section, intf int) uint32 {
+	if section < len(s.sections) && intf < len(s.sections[section]) {
+		return s.sections[section][intf]
+	}
+	return 0
+}
+
+// EnrichData reads a pcap or pcapng file and processes its packets. The capture is streamed,
+// so compressed files and archive members are read without extracting them first. The file
+// itself is recorded as a scan run, carrying the capture's time span, the application that
+// wrote it (as the run's version, since a capture has no command line) and any section and
+// interface comments.
+func EnrichData(file string, networkMap *model.NetworkMap, summary *model.PcapSummary) error {
+	rc, err := OpenDataFile(file)
+	if err != nil {
+		return fmt.Errorf("could not open pcap file %s: %w", file, err)
+	}
+	defer rc.Close()
+
+	capture, err := openCapture(rc)
+	if err != nil {
+		return fmt.Errorf("could not read pcap file %s: %w", file, err)
+	}
+
+	run := model.ScanRun{Scanner: capture.format, SourceFile: file}
+	packets := 0
+	for {
+		packet, err := capture.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// Captures cut off mid-packet are common (e.g. a sensor that was stopped); keep what was read.
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				log.Printf("Warning: %s is truncated after %d packets", file, packets)
+				break
+			}
+			return fmt.Errorf("could not read packet %d of %s: %w", packets+1, file, err)
+		}
+		packets++
+
+		if ts := packet.Metadata().Timestamp; !ts.IsZero() {
+			if run.StartTime.IsZero() || ts.Before(run.StartTime) {
+				run.StartTime = ts
+			}
+			if ts.After(run.EndTime) {
+				run.EndTime = ts
+			}
+		}
+		ProcessPacket(packet, networkMap, summary, file)
+	}
+	FlushStreams(networkMap, summary)
+
+	if !run.StartTime.IsZero() {
+		run.Elapsed = run.EndTime.Sub(run.StartTime).Seconds()
+	}
+	sections := capture.allSections()
+	run.Version = captureApplication(sections)
+	run.Summary = captureSummary(packets, sections)
+	networkMap.ScanRuns = append(networkMap.ScanRuns, run)
+	return nil
+}
+
+// captureApplication returns the application that wrote a pcapng capture, if it recorded one.
+func captureApplication(sections []captureSection) string {
+	for _, s := range sections {
+		if s.info.Application != "" {
+			return s.info.Application
+		}
+	}
+	return ""
+}
+
+// captureSummary describes a capture's packets, interfaces and comments in one line.
+func captureSummary(packets int, sections []captureSection) string {
+	parts := []string{fmt.Sprintf("%d packets", packets)}
+	var interfaces, comments []string
+	for _, s := range sections {
+		if s.info.Comment != "" {
+			comments = append(comments, s.info.Comment)
+		}
+		for i, intf := range s.interfaces {
+			name := intf.Name
+			if name == "" {
+				name = intf.Description
+			}
+			if name == "" {
+				name = "unnamed"
+			}
+			interfaces = append(interfaces, fmt.Sprintf("%s (%s)", name, linkTypeName(s.linkTypes[i])))
+			if intf.Comment != "" {
+				comments = append(comments, name+": "+intf.Comment)
+			}
+		}
+	}
+	if len(interfaces) > 0 {
+		parts = append(parts, "interfaces: "+strings.Join(interfaces, ", "))
+	}
+	if len(comments) > 0 {
+		parts = append(parts, "comments: "+strings.Join(comments, " | "))
+	}
+	return strings.Join(parts, "; ")
+}
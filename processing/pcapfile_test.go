@@ -0,0 +1,112 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+// testFrame builds a TCP SYN from 10.9.9.1 to 10.9.9.2, wrapped in Ethernet unless raw is set.
+func testFrame(t *testing.T, raw bool) []byte {
+	t.Helper()
+	eth := &layers.Ethernet{
+		SrcMAC:       net.HardwareAddr{0x00, 0x0c, 0x29, 0xaa, 0xbb, 0x01},
+		DstMAC:       net.HardwareAddr{0x00, 0x0c, 0x29, 0xaa, 0xbb, 0x02},
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolTCP, SrcIP: net.IP{10, 9, 9, 1}, DstIP: net.IP{10, 9, 9, 2}}
+	tcp := &layers.TCP{SrcPort: 50000, DstPort: 80, SYN: true, Window: 1024}
+	tcp.SetNetworkLayerForChecksum(ip)
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	var err error
+	if raw {
+		err = gopacket.SerializeLayers(buf, opts, ip, tcp)
+	} else {
+		err = gopacket.SerializeLayers(buf, opts, eth, ip, tcp)
+	}
+	if err != nil {
+		t.Fatalf("could not build packet: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// testPcap returns a small classic pcap holding one Ethernet frame.
+func testPcap(t *testing.T) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	w := pcapgo.NewWriter(&out)
+	if err := w.WriteFileHeader(65535, layers.LinkTypeEthernet); err != nil {
+		t.Fatalf("could not write pcap header: %v", err)
+	}
+	data := testFrame(t, false)
+	ci := gopacket.CaptureInfo{Timestamp: time.Unix(1700000000, 0), CaptureLength: len(data), Length: len(data)}
+	if err := w.WritePacket(ci, data); err != nil {
+		t.Fatalf("could not write packet: %v", err)
+	}
+	return out.Bytes()
+}
+
+// TestEnrichDataPcapng verifies that pcapng files with interfaces of different link types are
+// read in full, and that the capture's application and comments are recorded as a scan run.
+func TestEnrichDataPcapng(t *testing.T) {
+	var out bytes.Buffer
+	w, err := pcapgo.NewNgWriterInterface(&out,
+		pcapgo.NgInterface{Name: "eth0", LinkType: layers.LinkTypeEthernet, Comment: "span port", TimestampResolution: 9},
+		pcapgo.NgWriterOptions{SectionInfo: pcapgo.NgSectionInfo{Application: "Dumpcap (Wireshark) 4.2.0", Comment: "office uplink, day 1"}})
+	if err != nil {
+		t.Fatalf("could not create pcapng writer: %v", err)
+	}
+	tun, err := w.AddInterface(pcapgo.NgInterface{Name: "tun0", LinkType: layers.LinkTypeRaw, TimestampResolution: 9})
+	if err != nil {
+		t.Fatalf("could not add interface: %v", err)
+	}
+	for i, frame := range [][]byte{testFrame(t, false), testFrame(t, true)} {
+		ci := gopacket.CaptureInfo{Timestamp: time.Unix(1700000000+int64(i)*10, 0), CaptureLength: len(frame), Length: len(frame), InterfaceIndex: i * tun}
+		if err := w.WritePacket(ci, frame); err != nil {
+			t.Fatalf("could not write packet: %v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("could not flush pcapng: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "mixed.pcapng")
+	if err := os.WriteFile(path, out.Bytes(), 0644); err != nil {
+		t.Fatalf("could not write temp file: %v", err)
+	}
+	if got, _ := DetectFileType(path); got != FileTypePcap {
+		t.Fatalf("Expected pcap detection, got %q", got)
+	}
+
+	networkMap, summary := model.NewNetworkMap(), model.NewPcapSummary()
+	if err := EnrichData(path, networkMap, summary); err != nil {
+		t.Fatalf("EnrichData failed: %v", err)
+	}
+	if summary.TotalPackets != 2 || summary.ProtocolCounts["IPv4"] != 2 {
+		t.Errorf("Expected both packets to be decoded as IPv4, got %d packets and %v", summary.TotalPackets, summary.ProtocolCounts)
+	}
+
+	if len(networkMap.ScanRuns) != 1 {
+		t.Fatalf("Expected one scan run for the capture, got %d", len(networkMap.ScanRuns))
+	}
+	run := networkMap.ScanRuns[0]
+	if run.Scanner != "pcapng" || run.Version != "Dumpcap (Wireshark) 4.2.0" || run.Args != "" || run.SourceFile != path || run.Elapsed != 10 {
+		t.Errorf("Unexpected scan run: %+v", run)
+	}
+	for _, want := range []string{"2 packets", "eth0 (Ethernet)", "tun0 (Raw)", "office uplink, day 1", "eth0: span port"} {
+		if !strings.Contains(run.Summary, want) {
+			t.Errorf("Expected %q in the summary, got %q", want, run.Summary)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// portScanResult is a single open port reported by a fast port scanner.
+type portScanResult struct {
+	IP       string
+	Hostname string
+	Port     int
+	Protocol string
+	State    string
+	Service  string
+	Banner   string
+	Seen     time.Time // when the scanner reported the port; zero if the format has no timestamps
+}
+
+// masscanRecord is one host entry in masscan's JSON output (-oJ / -oD).
+type masscanRecord struct {
+	IP        string `json:"ip"`
+	Timestamp string `json:"timestamp"`
+	Ports     []struct {
+		Port    int    `json:"port"`
+		Proto   string `json:"proto"`
+		Status  string `json:"status"`
+		Reason  string `json:"reason"`
+		TTL     int    `json:"ttl"`
+		Service *struct {
+			Name   string `json:"name"`
+			Banner string `json:"banner"`
+		} `json:"service"`
+	} `json:"ports"`
+}
+
+// naabuRecord is one line of naabu's JSON output (-json).
+type naabuRecord struct {
+	Host     string          `json:"host"`
+	IP       string          `json:"ip"`
+	Port     json.RawMessage `json:"port"`
+	Protocol string          `json:"protocol"`
+	TLS      bool            `json:"tls"`
+}
+
+// MergeFromPortScanFile parses the output of masscan, RustScan or naabu and merges the open
+// ports into the NetworkMap. The format is detected from the file content.
+func MergeFromPortScanFile(filename string, networkMap *model.NetworkMap) error {
+	fileType, err := DetectFileType(filename)
+	if err != nil {
+		return err
+	}
+	data, err := ReadDataFile(filename)
+	if err != nil {
+		return fmt.Errorf("could not read port scan file %s: %w", filename, err)
+	}
+
+	var results []portScanResult
+	var scanner string
+	switch fileType {
+	case FileTypeMasscanJSON:
+		scanner, results = "masscan", parseMasscanJSON(data)
+	case FileTypeMasscanList:
+		scanner, results = "masscan", parseMasscanList(data)
+	case FileTypeRustScan:
+		scanner, results = "rustscan", parseRustScan(data)
+	case FileTypeNaabuJSON:
+		scanner, results = "naabu", parseNaabuJSON(data)
+	case FileTypeMasscanXML:
+		return MergeFromXML(data, networkMap)
+	default:
+		return fmt.Errorf("unrecognised port scan format in %s", filename)
+	}
+
+	mergePortScanResults(scanner, results, networkMap)
+	return nil
+}
+
+// parseMasscanJSON handles both well-formed JSON arrays and the line-oriented output of older
+// masscan versions, which leaves trailing commas and a final "finished" record.
+func parseMasscanJSON(data []byte) []portScanResult {
+	var records []masscanRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		records = records[:0]
+		lines := bufio.NewScanner(bytes.NewReader(data))
+		lines.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for lines.Scan() {
+			line := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(lines.Text()), ","))
+			if !strings.HasPrefix(line, "{") {
+				continue
+			}
+			var record masscanRecord
+			if json.Unmarshal([]byte(line), &record) == nil {
+				records = append(records, record)
+			}
+		}
+	}
+
+	var results []portScanResult
+	for _, record := range records {
+		for _, p := range record.Ports {
+			result := portScanResult{IP: record.IP, Port: p.Port, Protocol: p.Proto, State: defaultIfEmpty(p.Status, "open"), Seen: unixTimestamp(record.Timestamp)}
+			if p.Service != nil {
+				result.Service, result.Banner = p.Service.Name, p.Service.Banner
+			}
+			if result.IP != "" && result.Port > 0 {
+				results = append(results, result)
+			}
+		}
+	}
+	return results
+}
+
+// parseMasscanList parses masscan's list output (-oL):
+//
+//	open tcp 80 10.0.0.1 1700000000
+//	banner tcp 22 10.0.0.1 1700000000 ssh SSH-2.0-OpenSSH_8.9
+func parseMasscanList(data []byte) []portScanResult {
+	var results []portScanResult
+	lines := bufio.NewScanner(bytes.NewReader(data))
+	for lines.Scan() {
+		fields := strings.Fields(lines.Text())
+		if len(fields) < 4 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		port, err := strconv.Atoi(fields[2])
+		if err != nil {
+			continue
+		}
+		result := portScanResult{IP: fields[3], Port: port, Protocol: fields[1], State: fields[0]}
+		if len(fields) > 4 {
+			result.Seen = unixTimestamp(fields[4])
+		}
+		if fields[0] == "banner" {
+			result.State = "open"
+			if len(fields) > 5 {
+				result.Service = fields[5]
+			}
+			if len(fields) > 6 {
+				result.Banner = strings.Join(fields[6:], " ")
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// parseRustScan parses RustScan's greppable output ("10.0.0.1 -> [22,80]") as well as the
+// "Open 10.0.0.1:22" lines of its default output.
+func parseRustScan(data []byte) []portScanResult {
+	var results []portScanResult
+	lines := bufio.NewScanner(bytes.NewReader(data))
+	for lines.Scan() {
+		line := strings.TrimSpace(lines.Text())
+		if ip, ports, found := strings.Cut(line, " -> "); found {
+			ports = strings.Trim(ports, "[] ")
+			for _, p := range strings.Split(ports, ",") {
+				if port, err := strconv.Atoi(strings.TrimSpace(p)); err == nil {
+					results = append(results, portScanResult{IP: strings.TrimSpace(ip), Port: port, Protocol: "tcp", State: "open"})
+				}
+			}
+			continue
+		}
+		if address, found := strings.CutPrefix(line, "Open "); found {
+			host, p, err := net.SplitHostPort(strings.TrimSpace(address))
+			if err != nil {
+				continue
+			}
+			if port, err := strconv.Atoi(p); err == nil {
+				results = append(results, portScanResult{IP: host, Port: port, Protocol: "tcp", State: "open"})
+			}
+		}
+	}
+	return results
+}
+
+// parseNaabuJSON parses naabu's JSON lines output. Older naabu versions encode the port
+// as an object rather than a number.
+func parseNaabuJSON(data []byte) []portScanResult {
+	var results []portScanResult
+	lines := bufio.NewScanner(bytes.NewReader(data))
+	lines.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for lines.Scan() {
+		var record naabuRecord
+		if err := json.Unmarshal(lines.Bytes(), &record); err != nil {
+			continue
+		}
+		port := naabuPort(record.Port)
+		ip := record.IP
+		if ip == "" && net.ParseIP(record.Host) != nil {
+			ip = record.Host
+		}
+		if ip == "" || port == 0 {
+			continue
+		}
+		result := portScanResult{IP: ip, Port: port, Protocol: defaultIfEmpty(record.Protocol, "tcp"), State: "open"}
+		if record.Host != ip {
+			result.Hostname = record.Host
+		}
+		if record.TLS {
+			result.Banner = "TLS"
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// unixTimestamp parses the seconds-since-epoch timestamps masscan writes, returning the zero time
+// when the value is missing or malformed.
+func unixTimestamp(value string) time.Time {
+	seconds, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || seconds <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0)
+}
+
+func naabuPort(raw json.RawMessage) int {
+	var port int
+	if json.Unmarshal(raw, &port) == nil {
+		return port
+	}
+	var object struct {
+		Port  int `json:"port"`
+		Port2 int `json:"Port"`
+	}
+	if json.Unmarshal(raw, &object) == nil {
+		if object.Port != 0 {
+			return object.Port
+		}
+		return object.Port2
+	}
+	return 0
+}
+
+// mergePortScanResults adds port scanner results to the NetworkMap. Hosts that are already
+// known by IP are reused; service details reported by Nmap are never overwritten.
+func mergePortScanResults(scanner string, results []portScanResult, networkMap *model.NetworkMap) {
+	discoveredBy := scannerDisplayName(scanner)
+	hostsUp := make(map[string]bool)
+	var firstSeen, lastSeen time.Time
+	for _, result := range results {
+		if !result.Seen.IsZero() {
+			if firstSeen.IsZero() || result.Seen.Before(firstSeen) {
+				firstSeen = result.Seen
+			}
+			if result.Seen.After(lastSeen) {
+				lastSeen = result.Seen
+			}
+		}
+		host := findHostByIP(networkMap, result.IP)
+		if host == nil {
+			host = model.NewHost("IP:" + result.IP)
+			networkMap.Hosts[host.MACAddress] = host
+		}
+		host.IPv4Addresses[result.IP] = true
+		if host.DiscoveredBy == "" {
+			host.DiscoveredBy = discoveredBy
+		}
+		if result.State == "open" {
+			host.Status = "up"
+			hostsUp[result.IP] = true
+		}
+		if result.Hostname != "" {
+			host.Hostnames[result.Hostname] = "user"
+		}
+
+		protocol := strings.ToLower(result.Protocol)
+		if existing, ok := host.Ports[result.Port]; ok && existing.Protocol == protocol && existing.Service != "" {
+			continue
+		}
+		host.Ports[result.Port] = model.Port{
+			ID:       result.Port,
+			Protocol: protocol,
+			State:    result.State,
+			Service:  result.Service,
+			Version:  result.Banner,
+		}
+	}
+
+	networkMap.ScanRuns = append(networkMap.ScanRuns, model.ScanRun{
+		Scanner:    scanner,
+		StartTime:  firstSeen,
+		EndTime:    lastSeen,
+		HostsUp:    len(hostsUp),
+		HostsTotal: len(hostsUp),
+		Summary:    fmt.Sprintf("%d open port(s) on %d host(s)", len(results), len(hostsUp)),
+	})
+}
+
+// findHostByIP returns the host that owns the given IP address, if any.
+func findHostByIP(networkMap *model.NetworkMap, ip string) *model.Host {
+	if host, ok := networkMap.Hosts["IP:"+ip]; ok {
+		return host
+	}
+	for _, host := range networkMap.Hosts {
+		if host.IPv4Addresses[ip] {
+			return host
+		}
+	}
+	return nil
+}
+
+// scannerDisplayName returns the value stored in Host.DiscoveredBy for a scanner.
+func scannerDisplayName(scanner string) string {
+	switch strings.ToLower(scanner) {
+	case "masscan":
+		return "Masscan"
+	case "rustscan":
+		return "RustScan"
+	case "naabu":
+		return "Naabu"
+	}
+	return "Nmap"
+}
@@ -0,0 +1,175 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTempFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("could not write temp file: %v", err)
+	}
+	return path
+}
+
+// TestDetectContentType verifies that formats are recognised without relying on extensions.
+func TestDetectContentType(t *testing.T) {
+	tests := map[string]struct {
+		content string
+		want    FileType
+	}{
+		"nmap xml":     {`<?xml version="1.0"?><nmaprun scanner="nmap" args="nmap -sV"></nmaprun>`, FileTypeNmapXML},
+		"masscan xml":  {`<?xml version="1.0"?><nmaprun scanner="masscan" start="1"></nmaprun>`, FileTypeMasscanXML},
+		"masscan json": {`[{"ip": "10.0.0.1", "ports": [{"port": 80, "proto": "tcp", "status": "open"}]}]`, FileTypeMasscanJSON},
+		"masscan list": {"#masscan\nopen tcp 80 10.0.0.1 1700000000\n# end\n", FileTypeMasscanList},
+		"rustscan":     {"10.0.0.1 -> [22,80]\n", FileTypeRustScan},
+		"naabu":        {`{"host":"web.local","ip":"10.0.0.1","port":443,"protocol":"tcp","tls":true}` + "\n", FileTypeNaabuJSON},
+		"pcap":         {"\xd4\xc3\xb2\xa1\x02\x00\x04\x00", FileTypePcap},
+		"other xml":    {`<?xml version="1.0"?><config></config>`, FileTypeUnknown},
+		"text":         {"just some notes\n", FileTypeUnknown},
+	}
+	for name, tc := range tests {
+		if got := detectContentType([]byte(tc.content)); got != tc.want {
+			t.Errorf("%s: got %q, want %q", name, got, tc.want)
+		}
+	}
+}
+
+// TestMergeFromPortScanFile verifies each port scanner format ends up in the ports model.
+func TestMergeFromPortScanFile(t *testing.T) {
+	tests := map[string]struct {
+		content  string
+		ip       string
+		ports    []int
+		scanner  string
+		hostname string
+	}{
+		"masscan json": {
+			content: "[\n{   \"ip\": \"10.0.0.1\",   \"timestamp\": \"1700000000\", \"ports\": [ {\"port\": 80, \"proto\": \"tcp\", \"status\": \"open\", \"reason\": \"syn-ack\", \"ttl\": 64} ] }\n,\n" +
+				"{   \"ip\": \"10.0.0.1\",   \"timestamp\": \"1700000000\", \"ports\": [ {\"port\": 22, \"proto\": \"tcp\", \"service\": {\"name\": \"ssh\", \"banner\": \"SSH-2.0-OpenSSH_8.9\"}} ] }\n,\n{finished: 1}\n]\n",
+			ip: "10.0.0.1", ports: []int{22, 80}, scanner: "Masscan",
+		},
+		"masscan list": {
+			content: "#masscan\nopen tcp 443 10.0.0.2 1700000000\nopen udp 53 10.0.0.2 1700000000\n# end\n",
+			ip:      "10.0.0.2", ports: []int{53, 443}, scanner: "Masscan",
+		},
+		"rustscan greppable": {
+			content: "10.0.0.3 -> [21,3389]\n",
+			ip:      "10.0.0.3", ports: []int{21, 3389}, scanner: "RustScan",
+		},
+		"rustscan default": {
+			content: "Open 10.0.0.4:8080\nOpen 10.0.0.4:8443\n",
+			ip:      "10.0.0.4", ports: []int{8080, 8443}, scanner: "RustScan",
+		},
+		"naabu json": {
+			content: `{"host":"app.corp.local","ip":"10.0.0.5","port":443,"protocol":"tcp","tls":true}` + "\n" + `{"ip":"10.0.0.5","port":{"Port":8000}}` + "\n",
+			ip:      "10.0.0.5", ports: []int{443, 8000}, scanner: "Naabu", hostname: "app.corp.local",
+		},
+	}
+
+	for name, tc := range tests {
+		networkMap := model.NewNetworkMap()
+		if err := MergeFromPortScanFile(writeTempFile(t, "scan.out", tc.content), networkMap); err != nil {
+			t.Errorf("%s: MergeFromPortScanFile failed: %v", name, err)
+			continue
+		}
+		host, ok := networkMap.Hosts["IP:"+tc.ip]
+		if !ok {
+			t.Errorf("%s: expected host IP:%s, got %d hosts", name, tc.ip, len(networkMap.Hosts))
+			continue
+		}
+		if host.DiscoveredBy != tc.scanner || host.Status != "up" {
+			t.Errorf("%s: got DiscoveredBy=%q Status=%q", name, host.DiscoveredBy, host.Status)
+		}
+		for _, port := range tc.ports {
+			if _, ok := host.Ports[port]; !ok {
+				t.Errorf("%s: expected port %d, got %v", name, port, host.Ports)
+			}
+		}
+		if tc.hostname != "" && host.Hostnames[tc.hostname] == "" {
+			t.Errorf("%s: expected hostname %s, got %v", name, tc.hostname, host.Hostnames)
+		}
+		if len(networkMap.ScanRuns) != 1 {
+			t.Errorf("%s: expected 1 scan run, got %d", name, len(networkMap.ScanRuns))
+		}
+	}
+
+	networkMap := model.NewNetworkMap()
+	if err := MergeFromPortScanFile(writeTempFile(t, "scan.json", tests["masscan json"].content), networkMap); err != nil {
+		t.Fatalf("MergeFromPortScanFile failed: %v", err)
+	}
+	if ssh := networkMap.Hosts["IP:10.0.0.1"].Ports[22]; ssh.Service != "ssh" || ssh.Version != "SSH-2.0-OpenSSH_8.9" {
+		t.Errorf("Expected masscan banner to be kept, got %+v", ssh)
+	}
+
+	networkMap = model.NewNetworkMap()
+	if err := MergeFromPortScanFile(writeTempFile(t, "scan.lst", "open tcp 80 10.0.0.6 1700000100\nopen tcp 22 10.0.0.6 1700000000\n"), networkMap); err != nil {
+		t.Fatalf("MergeFromPortScanFile failed: %v", err)
+	}
+	if run := networkMap.ScanRuns[0]; run.StartTime.Unix() != 1700000000 || run.EndTime.Unix() != 1700000100 {
+		t.Errorf("Expected scan times from the masscan timestamps, got %v - %v", run.StartTime, run.EndTime)
+	}
+
+	networkMap = model.NewNetworkMap()
+	if err := MergeFromPortScanFile(writeTempFile(t, "scan.txt", tests["rustscan greppable"].content), networkMap); err != nil {
+		t.Fatalf("MergeFromPortScanFile failed: %v", err)
+	}
+	if run := networkMap.ScanRuns[0]; !run.EndTime.IsZero() {
+		t.Errorf("Expected no end time for output without timestamps, got %v", run.EndTime)
+	}
+}
+
+// TestMergeNetworkMapsKeepsNmapDetail verifies that a masscan sweep followed by an Nmap scan
+// of the same host merges into a single host without losing service details.
+func TestMergeNetworkMapsKeepsNmapDetail(t *testing.T) {
+	sweep := model.NewNetworkMap()
+	mergePortScanResults("masscan", []portScanResult{
+		{IP: "10.0.0.9", Port: 22, Protocol: "tcp", State: "open"},
+		{IP: "10.0.0.9", Port: 8080, Protocol: "tcp", State: "open"},
+	}, sweep)
+
+	detailed := model.NewNetworkMap()
+	host := model.NewHost("00:11:22:33:44:55")
+	host.IPv4Addresses["10.0.0.9"] = true
+	host.DiscoveredBy = "Nmap"
+	host.Status = "up"
+	host.Ports[22] = model.Port{ID: 22, Protocol: "tcp", State: "open", Service: "ssh", Version: "OpenSSH 8.9"}
+	detailed.Hosts[host.MACAddress] = host
+
+	for _, order := range [][]*model.NetworkMap{{sweep, detailed}, {detailed, sweep}} {
+		master := model.NewNetworkMap()
+		for _, m := range order {
+			copied := model.NewNetworkMap()
+			for k, h := range m.Hosts {
+				c := *h
+				c.Ports = make(map[int]model.Port)
+				for id, p := range h.Ports {
+					c.Ports[id] = p
+				}
+				copied.Hosts[k] = &c
+			}
+			MergeNetworkMaps(master, copied)
+		}
+
+		if len(master.Hosts) != 1 {
+			t.Fatalf("Expected 1 merged host, got %d", len(master.Hosts))
+		}
+		merged, ok := master.Hosts["00:11:22:33:44:55"]
+		if !ok {
+			t.Fatalf("Expected the MAC-keyed host to survive the merge, got %v", master.Hosts)
+		}
+		if merged.DiscoveredBy != "Nmap" {
+			t.Errorf("DiscoveredBy got %q, want Nmap", merged.DiscoveredBy)
+		}
+		if merged.Ports[22].Version != "OpenSSH 8.9" {
+			t.Errorf("Nmap service detail lost: %+v", merged.Ports[22])
+		}
+		if _, ok := merged.Ports[8080]; !ok {
+			t.Error("Masscan-only port 8080 lost in merge")
+		}
+	}
+}
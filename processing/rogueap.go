@@ -0,0 +1,320 @@
+package processing
+
+import (
+	"SnailsHell/config"
+	"SnailsHell/model"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Thresholds for the wireless anomaly checks.
+const (
+	deauthFloodFrames = 20               // Deauth/disassoc frames for one BSSID ...
+	deauthFloodWindow = 10 * time.Second // ... within this window count as a flood
+	karmaSSIDCount    = 3                // Distinct SSIDs advertised by one BSSID that indicate a karma AP
+	wirelessSource    = "Wi-Fi analysis"
+)
+
+// securityRank orders encryption labels from weakest to strongest.
+var securityRank = map[string]int{
+	"Open": 0, "WEP": 1, "WPA": 2, "WPA/WPA2": 3, "WPA2": 4, "OWE": 4, "WPA2/WPA3": 5, "WPA3": 6,
+}
+
+// AnalyzeWireless looks for rogue access points in the wireless inventory: SSIDs served by
+// BSSIDs from different vendors or with different security, open networks imitating corporate
+// SSIDs, karma APs answering for many SSIDs and deauthentication floods. Each detection is added
+// as a finding on the access point's host, which is created if the AP was only seen over the air.
+// It should run after EnrichWithLookups so that AP vendors are known.
+func AnalyzeWireless(networkMap *model.NetworkMap, summary *model.PcapSummary) {
+	bySSID := make(map[string][]*model.WirelessAP)
+	for _, ap := range summary.AccessPoints {
+		if ap.SSID != "" {
+			bySSID[ap.SSID] = append(bySSID[ap.SSID], ap)
+		}
+	}
+	for _, aps := range bySSID {
+		sort.Slice(aps, func(i, j int) bool { return aps[i].BSSID < aps[j].BSSID })
+	}
+
+	securityTwins := make(map[string]bool)
+	for ssid, aps := range bySSID {
+		if len(aps) < 2 {
+			continue
+		}
+		checkVendorMismatch(networkMap, ssid, aps)
+		for _, ap := range checkSecurityMismatch(networkMap, ssid, aps) {
+			securityTwins[ap.BSSID] = true
+		}
+	}
+	checkOpenLookalikes(networkMap, bySSID, securityTwins)
+
+	for _, ap := range summary.AccessPoints {
+		if len(ap.SSIDs) >= karmaSSIDCount {
+			ssids := joinSorted(ap.SSIDs)
+			addWirelessFinding(networkMap, ap, model.Vulnerability{
+				CVE:         "WIFI-KARMA-AP",
+				Description: fmt.Sprintf("BSSID %s advertised %d different SSIDs (%s). It is likely a karma/MANA rogue AP answering clients' probe requests.", ap.BSSID, len(ap.SSIDs), ssids),
+				Category:    model.CriticalFinding,
+			})
+		}
+	}
+
+	checkDeauthFloods(networkMap, summary)
+}
+
+// checkVendorMismatch flags BSSIDs of an SSID whose vendor differs from the one most of its
+// BSSIDs have. With no majority, every BSSID with a known vendor is flagged.
+func checkVendorMismatch(networkMap *model.NetworkMap, ssid string, aps []*model.WirelessAP) {
+	counts := make(map[string]int)
+	for _, ap := range aps {
+		if knownVendor(ap.Vendor) {
+			counts[ap.Vendor]++
+		}
+	}
+	if len(counts) < 2 {
+		return
+	}
+	best, tie := 0, false
+	for _, n := range counts {
+		if n > best {
+			best, tie = n, false
+		} else if n == best {
+			tie = true
+		}
+	}
+	vendors := joinSorted(boolSet(counts))
+	for _, ap := range aps {
+		if !knownVendor(ap.Vendor) || (counts[ap.Vendor] == best && !tie) {
+			continue
+		}
+		addWirelessFinding(networkMap, ap, model.Vulnerability{
+			CVE:         "WIFI-EVIL-TWIN-VENDOR",
+			Description: fmt.Sprintf("SSID %q is advertised by access points from different vendors (%s); BSSID %s (%s) may be an evil twin.", ssid, vendors, ap.BSSID, ap.Vendor),
+			Category:    model.PotentialFinding,
+		})
+	}
+}
+
+// checkSecurityMismatch flags BSSIDs of an SSID that offer different security than its strongest
+// BSSID, and returns them. An open twin of a protected network is critical.
+func checkSecurityMismatch(networkMap *model.NetworkMap, ssid string, aps []*model.WirelessAP) []*model.WirelessAP {
+	var strongest *model.WirelessAP
+	for _, ap := range aps {
+		if ap.Encryption == "" {
+			continue
+		}
+		if strongest == nil || securityStrength(ap.Encryption) > securityStrength(strongest.Encryption) {
+			strongest = ap
+		}
+	}
+	if strongest == nil {
+		return nil
+	}
+
+	var flagged []*model.WirelessAP
+	for _, ap := range aps {
+		if ap.Encryption == "" || ap.Encryption == strongest.Encryption {
+			continue
+		}
+		category := model.PotentialFinding
+		if ap.Encryption == "Open" || ap.Encryption == "WEP" {
+			category = model.CriticalFinding
+		}
+		addWirelessFinding(networkMap, ap, model.Vulnerability{
+			CVE: "WIFI-EVIL-TWIN-SECURITY",
+			Description: fmt.Sprintf("SSID %q is advertised as %s by BSSID %s but as %s by BSSID %s; the differing access point may be an evil twin.",
+				ssid, ap.Encryption, ap.BSSID, strongest.Encryption, strongest.BSSID),
+			Category: category,
+		})
+		flagged = append(flagged, ap)
+	}
+	return flagged
+}
+
+// checkOpenLookalikes flags open networks whose SSID matches or imitates a corporate SSID: one
+// seen with Enterprise authentication, or one listed under wireless.corporate_ssids in the config.
+func checkOpenLookalikes(networkMap *model.NetworkMap, bySSID map[string][]*model.WirelessAP, alreadyFlagged map[string]bool) {
+	corporate := make(map[string]bool)
+	for ssid, aps := range bySSID {
+		for _, ap := range aps {
+			if strings.HasSuffix(ap.Encryption, "-Enterprise") {
+				corporate[ssid] = true
+			}
+		}
+	}
+	if config.Cfg != nil {
+		for _, ssid := range config.Cfg.Wireless.CorporateSSIDs {
+			corporate[ssid] = true
+		}
+	}
+	if len(corporate) == 0 {
+		return
+	}
+	corporateList := make([]string, 0, len(corporate))
+	for ssid := range corporate {
+		corporateList = append(corporateList, ssid)
+	}
+	sort.Strings(corporateList)
+
+	for ssid, aps := range bySSID {
+		for _, ap := range aps {
+			if ap.Encryption != "Open" || alreadyFlagged[ap.BSSID] {
+				continue
+			}
+			for _, corp := range corporateList {
+				category, match := ssidLookalike(ssid, corp)
+				if !match {
+					continue
+				}
+				addWirelessFinding(networkMap, ap, model.Vulnerability{
+					CVE:         "WIFI-OPEN-LOOKALIKE",
+					Description: fmt.Sprintf("Open network %q (BSSID %s) imitates the corporate SSID %q; clients may connect to it and expose their traffic.", ssid, ap.BSSID, corp),
+					Category:    category,
+				})
+				break
+			}
+		}
+	}
+}
+
+// ssidLookalike reports whether ssid imitates corporate. Identical names and names that only
+// differ in case, punctuation or one character are critical; names that merely contain the
+// corporate name (e.g. "Corp-Guest") may be legitimate guest networks and are only potential.
+func ssidLookalike(ssid, corporate string) (model.FindingCategory, bool) {
+	a, b := normalizeSSID(ssid), normalizeSSID(corporate)
+	if a == "" || b == "" {
+		return "", false
+	}
+	if a == b || (len(b) >= 4 && editDistance(a, b) <= 1) {
+		return model.CriticalFinding, true
+	}
+	if len(b) >= 4 && strings.Contains(a, b) {
+		return model.PotentialFinding, true
+	}
+	return "", false
+}
+
+// normalizeSSID lower-cases an SSID and drops everything but letters and digits.
+func normalizeSSID(ssid string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(ssid) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// editDistance is the Levenshtein distance between two strings.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(rb)]
+}
+
+// checkDeauthFloods flags BSSIDs that received a burst of deauthentication or disassociation
+// frames, as sent by tools that kick clients off a network to capture handshakes or push them
+// onto an evil twin.
+func checkDeauthFloods(networkMap *model.NetworkMap, summary *model.PcapSummary) {
+	byBSSID := make(map[string][]model.DeauthEvent)
+	for _, event := range summary.DeauthEvents {
+		byBSSID[event.BSSID] = append(byBSSID[event.BSSID], event)
+	}
+	for bssid, events := range byBSSID {
+		if len(events) < deauthFloodFrames {
+			continue
+		}
+		sort.Slice(events, func(i, j int) bool { return events[i].Timestamp.Before(events[j].Timestamp) })
+
+		peak, start := 0, 0
+		for end := range events {
+			for events[end].Timestamp.Sub(events[start].Timestamp) > deauthFloodWindow {
+				start++
+			}
+			peak = max(peak, end-start+1)
+		}
+		if peak < deauthFloodFrames {
+			continue
+		}
+
+		targets := make(map[string]bool)
+		for _, event := range events {
+			targets[event.Destination] = true
+		}
+		ap := summary.AccessPoints[bssid]
+		if ap == nil {
+			ap = &model.WirelessAP{BSSID: bssid}
+		}
+		addWirelessFinding(networkMap, ap, model.Vulnerability{
+			CVE: "WIFI-DEAUTH-FLOOD",
+			Description: fmt.Sprintf("%d deauthentication/disassociation frames for BSSID %s (%d within %s), aimed at %d destination(s); a deauthentication attack is likely in progress.",
+				len(events), bssid, peak, deauthFloodWindow, len(targets)),
+			Category: model.PotentialFinding,
+		})
+	}
+}
+
+// addWirelessFinding attaches a finding to an access point's host, creating the host when the
+// AP was only seen over the air. A finding already on the host is not added twice.
+func addWirelessFinding(networkMap *model.NetworkMap, ap *model.WirelessAP, vuln model.Vulnerability) {
+	if len(ap.BSSID) != 17 || ap.BSSID == broadcastMAC {
+		return
+	}
+	host, ok := networkMap.Hosts[ap.BSSID]
+	if !ok {
+		host = model.NewHost(ap.BSSID)
+		host.DiscoveredBy = "Pcap (Wi-Fi)"
+		host.Fingerprint.Vendor = ap.Vendor
+		networkMap.Hosts[ap.BSSID] = host
+	}
+	if host.Wifi == nil {
+		host.Wifi = &model.WifiInfo{ProbeRequests: make(map[string]bool)}
+	}
+	host.Wifi.DeviceRole = "Access Point"
+	if ap.SSID != "" {
+		host.Wifi.SSID = ap.SSID
+	}
+
+	vuln.State, vuln.Source = stateDetected, wirelessSource
+	addFinding(host, vuln)
+}
+
+// securityStrength ranks an encryption label, placing an Enterprise variant just above its
+// personal counterpart.
+func securityStrength(encryption string) int {
+	rank := 2 * securityRank[strings.TrimSuffix(encryption, "-Enterprise")]
+	if strings.HasSuffix(encryption, "-Enterprise") {
+		rank++
+	}
+	return rank
+}
+
+func knownVendor(vendor string) bool {
+	return vendor != "" && vendor != "Unknown Vendor"
+}
+
+func boolSet(counts map[string]int) map[string]bool {
+	set := make(map[string]bool, len(counts))
+	for k := range counts {
+		set[k] = true
+	}
+	return set
+}
@@ -0,0 +1,90 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"testing"
+	"time"
+)
+
+func hasWirelessFinding(host *model.Host, category model.FindingCategory, id string) bool {
+	if host == nil {
+		return false
+	}
+	for _, v := range host.Findings[category] {
+		if v.CVE == id && v.Source == wirelessSource {
+			return true
+		}
+	}
+	return false
+}
+
+// TestAnalyzeWireless verifies the rogue AP detections and that findings land on the AP's host.
+func TestAnalyzeWireless(t *testing.T) {
+	networkMap, summary := model.NewNetworkMap(), model.NewPcapSummary()
+	aps := []*model.WirelessAP{
+		// "CorpNet" is served by two Cisco APs; a TP-Link AP with the same SSID is open.
+		{BSSID: "00:00:00:00:00:01", SSID: "CorpNet", Vendor: "Cisco", Encryption: "WPA2-Enterprise"},
+		{BSSID: "00:00:00:00:00:02", SSID: "CorpNet", Vendor: "Cisco", Encryption: "WPA2-Enterprise"},
+		{BSSID: "00:00:00:00:00:03", SSID: "CorpNet", Vendor: "TP-Link", Encryption: "Open"},
+		// Look-alikes of the corporate SSID.
+		{BSSID: "00:00:00:00:00:04", SSID: "Corp_Net", Encryption: "Open"},
+		{BSSID: "00:00:00:00:00:05", SSID: "CorpNet Guest", Encryption: "Open"},
+		{BSSID: "00:00:00:00:00:06", SSID: "CoffeeShop", Encryption: "Open"},
+		// A karma AP answering for whatever clients probe for.
+		{BSSID: "00:00:00:00:00:07", SSID: "Home", Encryption: "Open", SSIDs: map[string]bool{"Home": true, "Airport": true, "Hotel": true}},
+	}
+	for _, ap := range aps {
+		summary.AccessPoints[ap.BSSID] = ap
+	}
+	networkMap.Hosts["00:00:00:00:00:01"] = model.NewHost("00:00:00:00:00:01")
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 30; i++ {
+		summary.DeauthEvents = append(summary.DeauthEvents, model.DeauthEvent{
+			BSSID: "00:00:00:00:00:01", Source: "00:00:00:00:00:01", Destination: "FF:FF:FF:FF:FF:FF",
+			Subtype: "Deauthentication", Reason: 7, Timestamp: start.Add(time.Duration(i) * 100 * time.Millisecond),
+		})
+	}
+	// Occasional deauths spread over minutes are normal roaming, not a flood.
+	for i := 0; i < 30; i++ {
+		summary.DeauthEvents = append(summary.DeauthEvents, model.DeauthEvent{
+			BSSID: "00:00:00:00:00:02", Source: "00:00:00:00:00:02", Destination: "02:00:00:00:00:01",
+			Subtype: "Disassociation", Reason: 8, Timestamp: start.Add(time.Duration(i) * time.Minute),
+		})
+	}
+
+	AnalyzeWireless(networkMap, summary)
+	AnalyzeWireless(networkMap, summary) // Running again must not duplicate findings
+
+	hosts := networkMap.Hosts
+	testCases := []struct {
+		bssid    string
+		category model.FindingCategory
+		id       string
+		want     bool
+	}{
+		{"00:00:00:00:00:03", model.PotentialFinding, "WIFI-EVIL-TWIN-VENDOR", true},
+		{"00:00:00:00:00:01", model.PotentialFinding, "WIFI-EVIL-TWIN-VENDOR", false},
+		{"00:00:00:00:00:03", model.CriticalFinding, "WIFI-EVIL-TWIN-SECURITY", true},
+		{"00:00:00:00:00:03", model.CriticalFinding, "WIFI-OPEN-LOOKALIKE", false},
+		{"00:00:00:00:00:04", model.CriticalFinding, "WIFI-OPEN-LOOKALIKE", true},
+		{"00:00:00:00:00:05", model.PotentialFinding, "WIFI-OPEN-LOOKALIKE", true},
+		{"00:00:00:00:00:07", model.CriticalFinding, "WIFI-KARMA-AP", true},
+		{"00:00:00:00:00:01", model.PotentialFinding, "WIFI-DEAUTH-FLOOD", true},
+		{"00:00:00:00:00:02", model.PotentialFinding, "WIFI-DEAUTH-FLOOD", false},
+	}
+	for _, tc := range testCases {
+		if got := hasWirelessFinding(hosts[tc.bssid], tc.category, tc.id); got != tc.want {
+			t.Errorf("%s %s (%s): expected %v, got %v", tc.bssid, tc.id, tc.category, tc.want, got)
+		}
+	}
+	if _, ok := hosts["00:00:00:00:00:06"]; ok {
+		t.Error("Expected no host for an unrelated open network without findings")
+	}
+	if host := hosts["00:00:00:00:00:03"]; host == nil || host.Wifi.DeviceRole != "Access Point" || host.Fingerprint.Vendor != "TP-Link" {
+		t.Errorf("Expected an AP host to be created for the evil twin, got %+v", host)
+	}
+	if n := len(hosts["00:00:00:00:00:03"].Findings[model.CriticalFinding]); n != 1 {
+		t.Errorf("Expected a single critical finding after two runs, got %d", n)
+	}
+}
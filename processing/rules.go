@@ -0,0 +1,404 @@
+package processing
+
+import (
+	"SnailsHell/config"
+	"SnailsHell/model"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const ruleSource = "Detection rule"
+
+// RuleFile is the layout of a YAML rules file.
+type RuleFile struct {
+	Rules []*Rule `yaml:"rules"`
+}
+
+// Rule is a user-defined check. A host matches when all of its conditions hold; conditions on
+// ports must be met by the same port, and a finding is added for each such port (or once for the
+// host when no condition concerns a port).
+type Rule struct {
+	ID          string          `yaml:"id"`          // Used as the finding's identifier
+	Description string          `yaml:"description"` // May use {ip}, {port}, {service} and {version}
+	Category    string          `yaml:"category"`    // critical, potential or informational; potential by default
+	CVSS        float64         `yaml:"cvss"`
+	References  []string        `yaml:"references"`
+	Conditions  []RuleCondition `yaml:"conditions"`
+
+	category model.FindingCategory
+}
+
+// RuleCondition is one condition of a rule. All fields that are set must hold; text fields are
+// case-insensitive regular expressions except DNSDomain, which matches the domain and its
+// subdomains. Not inverts the condition, in which case it no longer restricts the ports.
+type RuleCondition struct {
+	// Port conditions, met by a single open port of the host.
+	Port         int    `yaml:"port"`
+	Protocol     string `yaml:"protocol"`
+	Service      string `yaml:"service"`
+	Version      string `yaml:"version"` // Product and version as identified by the scanner
+	CPE          string `yaml:"cpe"`
+	Header       string `yaml:"header"` // Name of an HTTP response header from the web probe
+	Value        string `yaml:"value"`  // Its value; any value if empty
+	FTPAnonymous *bool  `yaml:"ftp_anonymous"`
+	SMBAccess    *bool  `yaml:"smb_access"`
+	SSHLogin     *bool  `yaml:"ssh_login"`
+
+	// Host conditions.
+	Vendor     string `yaml:"vendor"`
+	OS         string `yaml:"os"`
+	DeviceType string `yaml:"device_type"`
+	Hostname   string `yaml:"hostname"`
+	DNSDomain  string `yaml:"dns_domain"` // Matched against the names the host looked up
+
+	Not bool `yaml:"not"`
+
+	patterns map[string]*regexp.Regexp
+}
+
+var ruleCategories = map[string]model.FindingCategory{
+	"critical":      model.CriticalFinding,
+	"potential":     model.PotentialFinding,
+	"informational": model.InformationalFinding,
+}
+
+// LoadRules reads the rules in a YAML file, or in all .yaml and .yml files of a directory. A
+// path that does not exist holds no rules.
+func LoadRules(path string) ([]*Rule, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("could not read rules from %s: %w", path, err)
+	}
+	files := []string{path}
+	if info.IsDir() {
+		files = nil
+		for _, pattern := range []string{"*.yaml", "*.yml"} {
+			matches, _ := filepath.Glob(filepath.Join(path, pattern))
+			files = append(files, matches...)
+		}
+		sort.Strings(files)
+	}
+
+	var rules []*Rule
+	ids := make(map[string]string)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("could not read rules file %s: %w", file, err)
+		}
+		var ruleFile RuleFile
+		if err := yaml.UnmarshalStrict(data, &ruleFile); err != nil {
+			return nil, fmt.Errorf("could not parse rules file %s: %w", file, err)
+		}
+		for i, rule := range ruleFile.Rules {
+			if err := rule.compile(); err != nil {
+				return nil, fmt.Errorf("%s: rule %d: %w", file, i+1, err)
+			}
+			if other, dup := ids[rule.ID]; dup {
+				return nil, fmt.Errorf("%s: rule %s is already defined in %s", file, rule.ID, other)
+			}
+			ids[rule.ID] = file
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+// compile validates the rule and compiles its patterns.
+func (r *Rule) compile() error {
+	if r.ID == "" {
+		return fmt.Errorf("missing id")
+	}
+	if r.Description == "" {
+		return fmt.Errorf("%s: missing description", r.ID)
+	}
+	category, ok := ruleCategories[strings.ToLower(defaultIfEmpty(r.Category, "potential"))]
+	if !ok {
+		return fmt.Errorf("%s: unknown category %q", r.ID, r.Category)
+	}
+	r.category = category
+	if len(r.Conditions) == 0 {
+		return fmt.Errorf("%s: no conditions", r.ID)
+	}
+	for i := range r.Conditions {
+		c := &r.Conditions[i]
+		c.patterns = make(map[string]*regexp.Regexp)
+		for name, expr := range map[string]string{
+			"protocol": c.Protocol, "service": c.Service, "version": c.Version, "cpe": c.CPE, "value": c.Value,
+			"vendor": c.Vendor, "os": c.OS, "device_type": c.DeviceType, "hostname": c.Hostname,
+		} {
+			if expr == "" {
+				continue
+			}
+			re, err := regexp.Compile("(?i)" + expr)
+			if err != nil {
+				return fmt.Errorf("%s: condition %d: invalid %s pattern: %w", r.ID, i+1, name, err)
+			}
+			c.patterns[name] = re
+		}
+		if c.Value != "" && c.Header == "" {
+			return fmt.Errorf("%s: condition %d: value needs a header", r.ID, i+1)
+		}
+		if !c.portScoped() && len(c.patterns) == 0 && c.DNSDomain == "" {
+			return fmt.Errorf("%s: condition %d is empty", r.ID, i+1)
+		}
+	}
+	return nil
+}
+
+// portScoped reports whether the condition is evaluated against the host's ports.
+func (c *RuleCondition) portScoped() bool {
+	return c.Port != 0 || c.Protocol != "" || c.Service != "" || c.Version != "" || c.CPE != "" ||
+		c.Header != "" || c.FTPAnonymous != nil || c.SMBAccess != nil || c.SSHLogin != nil
+}
+
+// ApplyDetectionRules evaluates the rules configured under rules.path against every host and adds
+// a finding for each match. It should run after enrichment, web probing and the post-exploitation
+// checks so that their results can be used by the rules.
+func ApplyDetectionRules(networkMap *model.NetworkMap) {
+	path := "./rules"
+	if config.Cfg != nil && config.Cfg.Rules.Path != "" {
+		path = config.Cfg.Rules.Path
+	}
+	rules, err := LoadRules(path)
+	if err != nil {
+		log.Printf("Could not load detection rules: %v", err)
+		return
+	}
+	if len(rules) == 0 {
+		return
+	}
+	matches := ApplyRules(networkMap, rules)
+	fmt.Printf("  -> %d detection rules from %s matched %d times.\n", len(rules), path, matches)
+}
+
+// ApplyRules evaluates the rules against every host, adds their findings and returns the number
+// of findings added.
+func ApplyRules(networkMap *model.NetworkMap, rules []*Rule) int {
+	added := 0
+	for _, host := range networkMap.Hosts {
+		for _, rule := range rules {
+			for _, port := range rule.match(host) {
+				if addRuleFinding(host, rule, port) {
+					added++
+				}
+			}
+		}
+	}
+	return added
+}
+
+// match returns the ports the rule matched on, or a single 0 if it matched the host as a whole.
+func (r *Rule) match(host *model.Host) []int {
+	var ports map[int]bool // nil while no condition restricted the ports
+	for i := range r.Conditions {
+		c := &r.Conditions[i]
+		if !c.matchHost(host) {
+			if c.Not {
+				continue
+			}
+			return nil
+		}
+		if !c.portScoped() {
+			if c.Not {
+				return nil
+			}
+			continue
+		}
+		matched := c.matchPorts(host)
+		if c.Not {
+			if len(matched) > 0 {
+				return nil
+			}
+			continue
+		}
+		if ports == nil {
+			ports = matched
+		} else {
+			for port := range ports {
+				if !matched[port] {
+					delete(ports, port)
+				}
+			}
+		}
+		if len(ports) == 0 {
+			return nil
+		}
+	}
+	if ports == nil {
+		return []int{0}
+	}
+	result := make([]int, 0, len(ports))
+	for port := range ports {
+		result = append(result, port)
+	}
+	sort.Ints(result)
+	return result
+}
+
+// matchHost checks the host conditions.
+func (c *RuleCondition) matchHost(host *model.Host) bool {
+	fp := host.Fingerprint
+	if fp == nil {
+		fp = &model.Fingerprint{}
+	}
+	if !c.matches("vendor", fp.Vendor) || !c.matches("os", fp.OperatingSystem) || !c.matches("device_type", fp.DeviceType) {
+		return false
+	}
+	if re := c.patterns["hostname"]; re != nil {
+		found := false
+		for name := range host.Hostnames {
+			found = found || re.MatchString(name)
+		}
+		if !found {
+			return false
+		}
+	}
+	if c.DNSDomain != "" {
+		domain := strings.ToLower(strings.Trim(c.DNSDomain, "."))
+		inDomain := func(name string) bool {
+			name = strings.ToLower(strings.TrimSuffix(name, "."))
+			return name == domain || strings.HasSuffix(name, "."+domain)
+		}
+		found := false
+		for name := range host.DNSLookups {
+			found = found || inDomain(name)
+		}
+		for _, answer := range host.DNSAnswers {
+			found = found || inDomain(answer.Name)
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// matchPorts returns the open ports that meet the port conditions.
+func (c *RuleCondition) matchPorts(host *model.Host) map[int]bool {
+	matched := make(map[int]bool)
+	for id, port := range host.Ports {
+		if port.State == "closed" || port.State == "filtered" || (c.Port != 0 && id != c.Port) {
+			continue
+		}
+		if !c.matches("protocol", port.Protocol) || !c.matches("service", port.Service) || !c.matches("version", strings.TrimSpace(port.Version)) {
+			continue
+		}
+		if re := c.patterns["cpe"]; re != nil && !anyString(port.CPEs, re.MatchString) {
+			continue
+		}
+		if c.Header != "" && !c.matchHeader(host, id) {
+			continue
+		}
+		if c.FTPAnonymous != nil && !c.matchFTP(host, id) {
+			continue
+		}
+		if c.SMBAccess != nil && !c.matchSMB(host, id) {
+			continue
+		}
+		if c.SSHLogin != nil && !c.matchSSH(host, id) {
+			continue
+		}
+		matched[id] = true
+	}
+	return matched
+}
+
+func (c *RuleCondition) matchHeader(host *model.Host, port int) bool {
+	for _, response := range host.WebResponses {
+		if response.PortID != port {
+			continue
+		}
+		for name, value := range response.Headers {
+			if strings.EqualFold(name, c.Header) && c.matches("value", value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *RuleCondition) matchFTP(host *model.Host, port int) bool {
+	for _, result := range host.FTPResults {
+		if result.PortID == port && result.AnonymousLoginPossible == *c.FTPAnonymous {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *RuleCondition) matchSMB(host *model.Host, port int) bool {
+	for _, result := range host.SMBResults {
+		if result.PortID == port && result.Successful == *c.SMBAccess {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *RuleCondition) matchSSH(host *model.Host, port int) bool {
+	for _, result := range host.SSHResults {
+		if result.PortID == port && result.Successful == *c.SSHLogin {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether the named pattern, if set, matches the value.
+func (c *RuleCondition) matches(name, value string) bool {
+	re := c.patterns[name]
+	return re == nil || re.MatchString(value)
+}
+
+func anyString(values []string, match func(string) bool) bool {
+	for _, value := range values {
+		if match(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// addRuleFinding adds the rule's finding for the port unless the host already has it, and
+// reports whether it was added.
+func addRuleFinding(host *model.Host, rule *Rule, portID int) bool {
+	for _, existing := range host.Findings[rule.category] {
+		if existing.CVE == rule.ID && existing.Source == ruleSource && existing.PortID == portID {
+			return false
+		}
+	}
+	port := host.Ports[portID]
+	ips := make([]string, 0, len(host.IPv4Addresses))
+	for ip := range host.IPv4Addresses {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+	description := strings.NewReplacer(
+		"{ip}", strings.Join(ips, ", "),
+		"{port}", strconv.Itoa(portID),
+		"{service}", port.Service,
+		"{version}", strings.TrimSpace(port.Version),
+	).Replace(rule.Description)
+	host.Findings[rule.category] = append(host.Findings[rule.category], model.Vulnerability{
+		CVE:         rule.ID,
+		Description: description,
+		State:       stateDetected,
+		Category:    rule.category,
+		PortID:      portID,
+		CVSS:        rule.CVSS,
+		Source:      ruleSource,
+		References:  rule.References,
+	})
+	return true
+}
@@ -0,0 +1,138 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testRules = `
+rules:
+  - id: LOCAL-APACHE-2449
+    description: "{service} on port {port} runs {version}, which is vulnerable to path traversal."
+    category: critical
+    cvss: 7.5
+    references: ["https://httpd.apache.org/security/vulnerabilities_24.html"]
+    conditions:
+      - service: ^http$
+        version: apache httpd 2\.4\.49
+      - header: server
+        value: apache
+  - id: LOCAL-FTP-ANON
+    description: Anonymous FTP on {ip}.
+    conditions:
+      - ftp_anonymous: true
+  - id: LOCAL-CAMERA-NO-SSH
+    description: Camera without SSH.
+    category: informational
+    conditions:
+      - vendor: hikvision
+      - service: ^ssh$
+        not: true
+  - id: LOCAL-SPLIT-PORTS
+    description: Never matches, the conditions hold on different ports.
+    conditions:
+      - port: 80
+      - port: 21
+`
+
+const testDNSRules = `
+rules:
+  - id: LOCAL-BAD-DOMAIN
+    description: Looked up a known bad domain.
+    conditions:
+      - dns_domain: evil.example.
+`
+
+func writeRules(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatalf("could not write rules: %v", err)
+	}
+}
+
+// TestDetectionRules verifies that rules are loaded from a directory, that port conditions must be
+// met by the same port, that host conditions and negations are evaluated, and that applying the
+// rules again does not duplicate their findings.
+func TestDetectionRules(t *testing.T) {
+	dir := t.TempDir()
+	writeRules(t, dir, "local.yaml", testRules)
+	writeRules(t, dir, "dns.yml", testDNSRules)
+	writeRules(t, dir, "notes.txt", "not a rules file")
+	rules, err := LoadRules(dir)
+	if err != nil || len(rules) != 5 {
+		t.Fatalf("LoadRules returned %d rules (%v)", len(rules), err)
+	}
+
+	host := model.NewHost("02:00:00:00:00:0A")
+	host.IPv4Addresses["10.0.0.10"] = true
+	host.Fingerprint.Vendor = "Hangzhou Hikvision Digital Technology"
+	host.Ports[21] = model.Port{ID: 21, Protocol: "tcp", State: "open", Service: "ftp", Version: "vsftpd 3.0.3"}
+	host.Ports[80] = model.Port{ID: 80, Protocol: "tcp", State: "open", Service: "http", Version: "Apache httpd 2.4.49 "}
+	host.Ports[8080] = model.Port{ID: 8080, Protocol: "tcp", State: "open", Service: "http", Version: "Apache httpd 2.4.49 "}
+	host.WebResponses = append(host.WebResponses, model.WebResponse{PortID: 80, Headers: map[string]string{"Server": "Apache/2.4.49 (Unix)"}})
+	host.FTPResults = append(host.FTPResults, model.FTPResult{PortID: 21, AnonymousLoginPossible: true})
+	host.DNSLookups["www.evil.example"] = true
+	other := model.NewHost("02:00:00:00:00:0B")
+	other.Fingerprint.Vendor = "Hikvision"
+	other.Ports[22] = model.Port{ID: 22, Protocol: "tcp", State: "open", Service: "ssh"}
+	networkMap := model.NewNetworkMap()
+	networkMap.Hosts[host.MACAddress], networkMap.Hosts[other.MACAddress] = host, other
+
+	if added := ApplyRules(networkMap, rules); added != 4 {
+		t.Errorf("Expected 4 findings, got %d", added)
+	}
+	if added := ApplyRules(networkMap, rules); added != 0 {
+		t.Errorf("Expected no new findings on the second run, got %d", added)
+	}
+
+	findings := make(map[string]model.Vulnerability)
+	for _, vulns := range host.Findings {
+		for _, v := range vulns {
+			findings[v.CVE] = v
+		}
+	}
+	if v := findings["LOCAL-APACHE-2449"]; v.Category != model.CriticalFinding || v.PortID != 80 || v.CVSS != 7.5 || v.Source != ruleSource ||
+		v.Description != "http on port 80 runs Apache httpd 2.4.49, which is vulnerable to path traversal." || len(v.References) != 1 {
+		t.Errorf("Unexpected Apache finding %+v", v)
+	}
+	if v := findings["LOCAL-FTP-ANON"]; v.Category != model.PotentialFinding || v.PortID != 21 || v.Description != "Anonymous FTP on 10.0.0.10." {
+		t.Errorf("Unexpected FTP finding %+v", v)
+	}
+	if v := findings["LOCAL-CAMERA-NO-SSH"]; v.Category != model.InformationalFinding || v.PortID != 0 {
+		t.Errorf("Unexpected camera finding %+v", v)
+	}
+	if _, ok := findings["LOCAL-BAD-DOMAIN"]; !ok || len(findings) != 4 {
+		t.Errorf("Unexpected findings %v", findings)
+	}
+	if len(other.Findings) != 0 {
+		t.Errorf("Expected the camera with SSH not to match, got %v", other.Findings)
+	}
+}
+
+func TestLoadRulesErrors(t *testing.T) {
+	if rules, err := LoadRules(filepath.Join(t.TempDir(), "missing")); rules != nil || err != nil {
+		t.Errorf("Expected no rules for a missing path, got %v (%v)", rules, err)
+	}
+	for name, tc := range map[string]struct {
+		content string
+		want    string
+	}{
+		"bad pattern":      {"rules:\n  - id: A\n    description: a\n    conditions:\n      - service: '('\n", "invalid service pattern"},
+		"unknown category": {"rules:\n  - id: A\n    description: a\n    category: severe\n    conditions:\n      - port: 80\n", "unknown category"},
+		"unknown field":    {"rules:\n  - id: A\n    description: a\n    conditions:\n      - sevice: http\n", "sevice"},
+		"empty condition":  {"rules:\n  - id: A\n    description: a\n    conditions:\n      - not: true\n", "is empty"},
+		"value only":       {"rules:\n  - id: A\n    description: a\n    conditions:\n      - value: nginx\n", "needs a header"},
+		"duplicate id":     {"rules:\n  - id: A\n    description: a\n    conditions:\n      - port: 80\n  - id: A\n    description: b\n    conditions:\n      - port: 81\n", "already defined"},
+	} {
+		path := filepath.Join(t.TempDir(), "rules.yaml")
+		if err := os.WriteFile(path, []byte(tc.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadRules(path); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected an error containing %q, got %v", name, tc.want, err)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"bytes"
+	"encoding/binary"
+	"sort"
+	"time"
+	"unicode/utf16"
+)
+
+// SMB2 commands whose messages carry file names and contents (MS-SMB2 2.2).
+const (
+	smb2Create = 5
+	smb2Read   = 8
+	smb2Write  = 9
+)
+
+const (
+	smb2HeaderSize        = 64
+	smb2FlagServerToRedir = 0x1
+)
+
+var (
+	smb2ProtocolID = []byte{0xfe, 'S', 'M', 'B'}
+	smb1ProtocolID = []byte{0xff, 'S', 'M', 'B'}
+)
+
+// smbMessageDirection recognises a NetBIOS session message carrying SMB, returning whether it is
+// a request. SMB1 is accepted too, as clients often negotiate SMB2 with an SMB1 message.
+func smbMessageDirection(payload []byte) (request, ok bool) {
+	if len(payload) < 4+smb2HeaderSize || payload[0] != 0 {
+		return false, false
+	}
+	header := payload[4:]
+	switch {
+	case bytes.HasPrefix(header, smb2ProtocolID):
+		return binary.LittleEndian.Uint32(header[16:])&smb2FlagServerToRedir == 0, true
+	case bytes.HasPrefix(header, smb1ProtocolID):
+		return header[9]&0x80 == 0, true // FLAGS_REPLY
+	}
+	return false, false
+}
+
+// smb2Message is one SMB2 message of a stream, including those chained in a compound.
+type smb2Message struct {
+	command   uint16
+	status    uint32
+	messageID uint64
+	data      []byte // From the start of the header, as offsets in the body are
+	offset    int    // Of the message in its flow
+}
+
+func (m smb2Message) body() []byte { return m.data[smb2HeaderSize:] }
+
+// smb2Messages splits a flow into SMB2 messages. Other frames (SMB1, encrypted SMB3) are
+// skipped, and the walk stops at a frame that was not captured whole.
+func smb2Messages(data []byte) []smb2Message {
+	var messages []smb2Message
+	for pos := 0; pos+4 <= len(data); {
+		length := int(data[pos+1])<<16 | int(data[pos+2])<<8 | int(data[pos+3])
+		frameEnd := pos + 4 + length
+		if frameEnd > len(data) {
+			break
+		}
+		for start := pos + 4; start+smb2HeaderSize <= frameEnd; {
+			header := data[start:frameEnd]
+			if !bytes.HasPrefix(header, smb2ProtocolID) {
+				break
+			}
+			next := int(binary.LittleEndian.Uint32(header[20:]))
+			end := frameEnd
+			if next > 0 && start+next < frameEnd {
+				end = start + next
+			}
+			messages = append(messages, smb2Message{
+				command:   binary.LittleEndian.Uint16(header[12:]),
+				status:    binary.LittleEndian.Uint32(header[8:]),
+				messageID: binary.LittleEndian.Uint64(header[24:]),
+				data:      data[start:end],
+				offset:    start,
+			})
+			if next == 0 || end == frameEnd {
+				break
+			}
+			start = end
+		}
+		pos = frameEnd
+	}
+	return messages
+}
+
+// smb2File is an open file whose contents are rebuilt from reads and writes at their offsets.
+type smb2File struct {
+	name    string
+	size    int64 // End of file when opened
+	written bool
+	data    []byte
+	ranges  [][2]int64 // Byte ranges of data that were seen
+	time    time.Time
+}
+
+func (f *smb2File) put(offset int64, data []byte) {
+	// Offsets come from the wire; the bound is checked before adding so a huge one cannot overflow.
+	if len(data) == 0 || offset < 0 || offset > maxStreamBytes-int64(len(data)) {
+		return
+	}
+	end := offset + int64(len(data))
+	if int64(len(f.data)) < end {
+		f.data = append(f.data, make([]byte, end-int64(len(f.data)))...)
+	}
+	copy(f.data[offset:], data)
+	f.ranges = append(f.ranges, [2]int64{offset, end})
+}
+
+// complete returns the file's contents if every byte of it was seen.
+func (f *smb2File) complete() ([]byte, bool) {
+	size := f.size
+	if size < 0 {
+		return nil, false
+	}
+	if f.written && int64(len(f.data)) > size {
+		size = int64(len(f.data))
+	}
+	if size == 0 || int64(len(f.data)) < size {
+		return nil, false
+	}
+	sort.Slice(f.ranges, func(i, j int) bool { return f.ranges[i][0] < f.ranges[j][0] })
+	var covered int64
+	for _, r := range f.ranges {
+		if r[0] > covered {
+			return nil, false
+		}
+		if r[1] > covered {
+			covered = r[1]
+		}
+	}
+	if covered < size {
+		return nil, false
+	}
+	return f.data[:size], true
+}
+
+// parseSMB2Stream carves files that were read or written whole over an SMB2 connection. Opens
+// are matched to the server's responses by message ID for the file's handle and size; reads
+// take their data from the response, writes from the request.
+func parseSMB2Stream(stream *model.TCPStream, summary *model.PcapSummary) {
+	responses := make(map[uint64]smb2Message)
+	for _, m := range smb2Messages(stream.Server.Data) {
+		responses[m.messageID] = m // A final response replaces an interim STATUS_PENDING one
+	}
+	files := make(map[string]*smb2File)
+	var order []*smb2File
+	for _, request := range smb2Messages(stream.Client.Data) {
+		response, answered := responses[request.messageID]
+		succeeded := answered && response.status == 0 && response.command == request.command
+		body := request.body()
+		switch request.command {
+		case smb2Create:
+			// NameOffset and NameLength, then the response's EndofFile and FileId.
+			if !succeeded || len(body) < 48 || len(response.body()) < 80 {
+				continue
+			}
+			nameOffset, nameLength := int(binary.LittleEndian.Uint16(body[44:])), int(binary.LittleEndian.Uint16(body[46:]))
+			// The size comes from the wire too: one that would not fit the buffer is never carved.
+			size := binary.LittleEndian.Uint64(response.body()[48:])
+			if nameOffset+nameLength > len(request.data) || size > maxStreamBytes {
+				continue
+			}
+			file := &smb2File{
+				name: decodeUTF16(request.data[nameOffset : nameOffset+nameLength]),
+				size: int64(size),
+				time: flowTime(&stream.Client, request.offset),
+			}
+			files[string(response.body()[64:80])] = file
+			order = append(order, file)
+
+		case smb2Read:
+			// Length, Offset and FileId; the response has DataOffset and DataLength.
+			if !succeeded || len(body) < 32 || len(response.body()) < 8 {
+				continue
+			}
+			file := files[string(body[16:32])]
+			dataOffset, dataLength := int(response.body()[2]), int(binary.LittleEndian.Uint32(response.body()[4:]))
+			if file == nil || dataOffset+dataLength > len(response.data) {
+				continue
+			}
+			file.put(int64(binary.LittleEndian.Uint64(body[8:])), response.data[dataOffset:dataOffset+dataLength])
+
+		case smb2Write:
+			// DataOffset, Length, Offset and FileId, followed by the data. A write whose response
+			// was not captured is assumed to have succeeded.
+			if (answered && !succeeded) || len(body) < 32 {
+				continue
+			}
+			file := files[string(body[16:32])]
+			dataOffset, dataLength := int(binary.LittleEndian.Uint16(body[2:])), int(binary.LittleEndian.Uint32(body[4:]))
+			if file == nil || dataOffset+dataLength > len(request.data) {
+				continue
+			}
+			file.written = true
+			file.put(int64(binary.LittleEndian.Uint64(body[8:])), request.data[dataOffset:dataOffset+dataLength])
+		}
+	}
+
+	for _, file := range order {
+		data, ok := file.complete()
+		if !ok {
+			continue
+		}
+		srcIP, dstIP := stream.ServerIP, stream.ClientIP
+		if file.written {
+			srcIP, dstIP = dstIP, srcIP
+		}
+		addCarvedFile(summary, stream.Host, "SMB2", carvedFilename(file.name, "smb2"), "", srcIP, dstIP, file.time, stream.PcapFile, data)
+	}
+}
+
+// decodeUTF16 decodes the little-endian UTF-16 used for SMB2 names.
+func decodeUTF16(b []byte) string {
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		units[i] = binary.LittleEndian.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(units))
+}
@@ -0,0 +1,187 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SuricataEvent is a single line of Suricata's eve.json output. Only the event types used by
+// SnailsHell are mapped.
+type SuricataEvent struct {
+	EventType string `json:"event_type"`
+	SrcIP     string `json:"src_ip"`
+	SrcPort   int    `json:"src_port"`
+	DestIP    string `json:"dest_ip"`
+	DestPort  int    `json:"dest_port"`
+	Proto     string `json:"proto"`
+	AppProto  string `json:"app_proto"`
+	Alert     *struct {
+		Action      string              `json:"action"`
+		SignatureID int                 `json:"signature_id"`
+		Signature   string              `json:"signature"`
+		Category    string              `json:"category"`
+		Severity    int                 `json:"severity"`
+		Metadata    map[string][]string `json:"metadata"`
+	} `json:"alert"`
+	Flow *struct {
+		PktsToServer int    `json:"pkts_toserver"`
+		PktsToClient int    `json:"pkts_toclient"`
+		State        string `json:"state"`
+	} `json:"flow"`
+	DNS *struct {
+		Type    string `json:"type"` // "query" or "answer" (version 1 format)
+		RRName  string `json:"rrname"`
+		RData   string `json:"rdata"`
+		Answers []struct {
+			RRName string `json:"rrname"`
+			RRType string `json:"rrtype"`
+			RData  string `json:"rdata"`
+		} `json:"answers"`
+	} `json:"dns"`
+	HTTP *struct {
+		Hostname string `json:"hostname"`
+		URL      string `json:"url"`
+	} `json:"http"`
+	TLS *struct {
+		SNI string `json:"sni"`
+	} `json:"tls"`
+	DHCP *struct {
+		ClientMAC  string `json:"client_mac"`
+		AssignedIP string `json:"assigned_ip"`
+		ClientIP   string `json:"client_ip"`
+		Hostname   string `json:"hostname"`
+	} `json:"dhcp"`
+}
+
+// processSuricataFile reads an eve.json file line by line.
+func processSuricataFile(filename string, networkMap *model.NetworkMap, summary *model.PcapSummary) error {
+	f, err := OpenDataFile(filename)
+	if err != nil {
+		return fmt.Errorf("could not open eve.json %s: %w", filename, err)
+	}
+	defer f.Close()
+
+	lines := bufio.NewScanner(f)
+	lines.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	logFile := filepath.Base(filename)
+	seenAlerts := make(map[string]bool)
+	for lines.Scan() {
+		var event SuricataEvent
+		if err := json.Unmarshal(lines.Bytes(), &event); err != nil {
+			continue
+		}
+		applySuricataEvent(event, networkMap, summary, logFile, seenAlerts)
+	}
+	return lines.Err()
+}
+
+// applySuricataEvent merges a single eve.json event into the model.
+func applySuricataEvent(e SuricataEvent, networkMap *model.NetworkMap, summary *model.PcapSummary, logFile string, seenAlerts map[string]bool) {
+	const source = "Suricata"
+	switch e.EventType {
+	case "flow":
+		packets := 0
+		if e.Flow != nil {
+			packets = e.Flow.PktsToServer + e.Flow.PktsToClient
+			if strings.EqualFold(e.Proto, "TCP") && e.Flow.PktsToClient > 0 && e.Flow.State != "new" {
+				addLogOpenPort(networkMap, e.DestIP, e.DestPort, "tcp", e.AppProto, source)
+			}
+		}
+		addLogFlow(networkMap, e.SrcIP, e.DestIP, packets, source)
+	case "dns":
+		if e.DNS == nil {
+			return
+		}
+		var answers []string
+		for _, answer := range e.DNS.Answers {
+			answers = append(answers, answer.RData)
+		}
+		switch {
+		case e.DNS.Type == "query":
+			addLogDNSQuery(networkMap, e.SrcIP, e.DestIP, e.DNS.RRName, nil, source)
+		case e.DNS.Type == "answer" && len(answers) == 0 && e.DNS.RData != "":
+			// Version 1 format: one event per answer, sent from the resolver to the client.
+			addLogDNSQuery(networkMap, e.DestIP, e.SrcIP, e.DNS.RRName, []string{e.DNS.RData}, source)
+		case len(answers) > 0:
+			addLogDNSQuery(networkMap, e.DestIP, e.SrcIP, e.DNS.RRName, answers, source)
+		}
+	case "http":
+		if e.HTTP == nil {
+			return
+		}
+		addLogHostname(networkMap, e.DestIP, e.HTTP.Hostname, "HTTP", source)
+		if host := logHost(networkMap, e.SrcIP, source); host != nil && e.HTTP.URL != "" {
+			checkForSecrets([]byte(e.HTTP.URL), host.MACAddress, e.DestIP, summary, logFile)
+		}
+	case "tls":
+		if e.TLS != nil {
+			addLogHostname(networkMap, e.DestIP, e.TLS.SNI, "TLS SNI", source)
+		}
+	case "dhcp":
+		if e.DHCP != nil {
+			addLogDHCPLease(networkMap, e.DHCP.ClientMAC, defaultIfEmpty(e.DHCP.AssignedIP, e.DHCP.ClientIP), e.DHCP.Hostname, source)
+		}
+	case "alert":
+		addSuricataAlert(e, networkMap, seenAlerts)
+	}
+}
+
+// addSuricataAlert turns an alert into a finding. It is attached to the destination host when
+// that host is local (the usual target of an attack), otherwise to the local source host
+// (e.g. malware calling out). Repeated alerts for the same signature and port are reported once.
+func addSuricataAlert(e SuricataEvent, networkMap *model.NetworkMap, seenAlerts map[string]bool) {
+	if e.Alert == nil {
+		return
+	}
+	const source = "Suricata"
+	host, portID := logHost(networkMap, e.DestIP, source), e.DestPort
+	if host == nil {
+		host, portID = logHost(networkMap, e.SrcIP, source), 0
+	}
+	if host == nil {
+		return
+	}
+
+	sid := strconv.Itoa(e.Alert.SignatureID)
+	key := host.MACAddress + "|" + sid + "|" + strconv.Itoa(portID)
+	if seenAlerts[key] {
+		return
+	}
+	seenAlerts[key] = true
+
+	vuln := model.Vulnerability{
+		CVE:         "SURICATA-" + sid,
+		Description: fmt.Sprintf("%s [%s] %s:%d -> %s:%d", e.Alert.Signature, e.Alert.Category, e.SrcIP, e.SrcPort, e.DestIP, e.DestPort),
+		State:       "ALERT",
+		Category:    suricataCategory(e.Alert.Severity),
+		PortID:      portID,
+		Source:      "Suricata SID " + sid,
+	}
+	// ET rules tag CVEs in metadata as "CVE_2021_44228".
+	for _, cve := range e.Alert.Metadata["cve"] {
+		vuln.References = append(vuln.References, strings.ReplaceAll(strings.ToUpper(cve), "_", "-"))
+	}
+	if cve := cveRegex.FindString(e.Alert.Signature); cve != "" {
+		vuln.References = append([]string{cve}, vuln.References...)
+	}
+	if len(vuln.References) > 0 {
+		vuln.CVE = vuln.References[0]
+	}
+	host.Findings[vuln.Category] = append(host.Findings[vuln.Category], vuln)
+}
+
+// suricataCategory maps an alert severity (1 = high ... 3 = low) to a finding category.
+func suricataCategory(severity int) model.FindingCategory {
+	switch severity {
+	case 1:
+		return model.CriticalFinding
+	case 2:
+		return model.PotentialFinding
+	}
+	return model.InformationalFinding
+}
@@ -0,0 +1,210 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+const (
+	maxStreamBytes     = 32 << 20 // Per direction; the rest of a larger transfer is dropped
+	maxPendingSegments = 256      // Out-of-order segments buffered per direction
+)
+
+// trackTCPStream adds a TCP segment to the connection it belongs to. A connection is tracked
+// once newTCPStream recognises it as one we parse; the reassembled stream is handed
+// to that protocol's parser when both sides have closed or either has reset it, and otherwise
+// by FlushStreams.
+func trackTCPStream(packet gopacket.Packet, tcp *layers.TCP, srcIP, dstIP string, host *model.Host, networkMap *model.NetworkMap, summary *model.PcapSummary, pcapFile string) {
+	if summary.TCPStreams == nil {
+		summary.TCPStreams = make(map[string]*model.TCPStream)
+	}
+	srcPort, dstPort := int(tcp.SrcPort), int(tcp.DstPort)
+	key, fromClient := streamKey(srcIP, srcPort, dstIP, dstPort), true
+	stream := summary.TCPStreams[key]
+	if stream == nil {
+		key, fromClient = streamKey(dstIP, dstPort, srcIP, srcPort), false
+		stream = summary.TCPStreams[key]
+	}
+	if stream == nil {
+		if stream, fromClient = newTCPStream(tcp, srcIP, srcPort, dstIP, dstPort, summary, pcapFile); stream == nil {
+			return
+		}
+		key = streamKey(stream.ClientIP, stream.ClientPort, stream.ServerIP, stream.ServerPort)
+		summary.TCPStreams[key] = stream
+	}
+	if stream.Host == nil || (host.IPv4Addresses[stream.ClientIP] && !stream.Host.IPv4Addresses[stream.ClientIP]) {
+		stream.Host = host
+	}
+
+	flow := &stream.Server
+	if fromClient {
+		flow = &stream.Client
+	}
+	addSegment(flow, tcp, packet.Metadata().Timestamp)
+	if tcp.FIN || tcp.RST {
+		flow.Finished = true
+	}
+	if tcp.RST || (stream.Client.Finished && stream.Server.Finished) {
+		finishStream(networkMap, summary, stream)
+		delete(summary.TCPStreams, key)
+	}
+}
+
+// FlushStreams parses every connection that is still open, as at the end of a capture. TLS
+// handshakes that are still incomplete are dropped.
+func FlushStreams(networkMap *model.NetworkMap, summary *model.PcapSummary) {
+	clear(summary.TLSStreams)
+	keys := make([]string, 0, len(summary.TCPStreams))
+	for key := range summary.TCPStreams {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		finishStream(networkMap, summary, summary.TCPStreams[key])
+		delete(summary.TCPStreams, key)
+	}
+}
+
+// newTCPStream starts tracking a connection that is an announced FTP data connection or whose
+// first payload identifies a protocol we parse, returning whether the segment came from the
+// client. Connections are picked up from a client's request, so one whose start was missed is
+// still tracked from its next request.
+func newTCPStream(tcp *layers.TCP, srcIP string, srcPort int, dstIP string, dstPort int, summary *model.PcapSummary, pcapFile string) (*model.TCPStream, bool) {
+	stream := &model.TCPStream{PcapFile: pcapFile}
+	fromClient := true
+	if transfer := claimFTPTransfer(summary, dstIP, dstPort); transfer != nil {
+		stream.Protocol, stream.FTP = "ftp-data", transfer
+	} else if transfer := claimFTPTransfer(summary, srcIP, srcPort); transfer != nil {
+		stream.Protocol, stream.FTP, fromClient = "ftp-data", transfer, false
+	} else if protocol, sentByClient := detectStreamProtocol(tcp.Payload); protocol != "" {
+		stream.Protocol, fromClient = protocol, sentByClient
+	} else {
+		return nil, false
+	}
+	if fromClient {
+		stream.ClientIP, stream.ClientPort, stream.ServerIP, stream.ServerPort = srcIP, srcPort, dstIP, dstPort
+	} else {
+		stream.ClientIP, stream.ClientPort, stream.ServerIP, stream.ServerPort = dstIP, dstPort, srcIP, srcPort
+	}
+	return stream, fromClient
+}
+
+// detectStreamProtocol recognises the first payload of a connection, returning the protocol
+// and whether the payload was sent by the client.
+func detectStreamProtocol(payload []byte) (string, bool) {
+	if isHTTPRequestStart(payload) {
+		return "http", true
+	}
+	if request, ok := smbMessageDirection(payload); ok {
+		return "smb2", request
+	}
+	return "", false
+}
+
+func finishStream(networkMap *model.NetworkMap, summary *model.PcapSummary, stream *model.TCPStream) {
+	if len(stream.Client.Pending) > 0 {
+		stream.Client.Truncated = true
+	}
+	if len(stream.Server.Pending) > 0 {
+		stream.Server.Truncated = true
+	}
+	// A host keyed by IP is merged into its MAC-keyed host once the MAC address shows up.
+	if networkMap.Hosts[stream.Host.MACAddress] != stream.Host {
+		ip := stream.ServerIP
+		if stream.Host.IPv4Addresses[stream.ClientIP] {
+			ip = stream.ClientIP
+		}
+		if host := findHostByIP(networkMap, ip); host != nil {
+			stream.Host = host
+		}
+	}
+	switch stream.Protocol {
+	case "http":
+		parseHTTPStream(stream, summary)
+	case "ftp-data":
+		carveFTPData(stream, summary)
+	case "smb2":
+		parseSMB2Stream(stream, summary)
+	}
+}
+
+// addSegment appends a segment's payload to a flow in sequence order. Retransmitted bytes are
+// skipped and segments that arrive early wait until the gap before them is filled.
+func addSegment(flow *model.TCPFlow, tcp *layers.TCP, ts time.Time) {
+	seq := tcp.Seq
+	if tcp.SYN {
+		seq++ // The SYN takes up a sequence number
+	}
+	payload := tcp.Payload
+	if !flow.Started {
+		if len(payload) == 0 && !tcp.SYN {
+			return
+		}
+		flow.NextSeq, flow.Started = seq, true
+	}
+	if len(payload) == 0 {
+		return
+	}
+
+	switch diff := int32(seq - flow.NextSeq); {
+	case diff > 0:
+		if flow.Pending == nil {
+			flow.Pending = make(map[uint32][]byte)
+		}
+		if len(flow.Pending) < maxPendingSegments {
+			flow.Pending[seq] = append([]byte(nil), payload...)
+		} else {
+			flow.Truncated = true
+		}
+		return
+	case diff < 0:
+		if int(-diff) >= len(payload) {
+			return
+		}
+		payload = payload[-diff:]
+	}
+	appendFlow(flow, payload, ts)
+
+	for progressed := true; progressed && len(flow.Pending) > 0; {
+		progressed = false
+		for seq, data := range flow.Pending {
+			diff := int32(seq - flow.NextSeq)
+			if diff > 0 {
+				continue
+			}
+			delete(flow.Pending, seq)
+			if int(-diff) < len(data) {
+				appendFlow(flow, data[-diff:], ts)
+				progressed = true
+			}
+		}
+	}
+}
+
+func appendFlow(flow *model.TCPFlow, data []byte, ts time.Time) {
+	flow.NextSeq += uint32(len(data))
+	if len(flow.Data)+len(data) > maxStreamBytes {
+		flow.Truncated = true
+		return
+	}
+	flow.Marks = append(flow.Marks, model.FlowMark{Offset: len(flow.Data), Timestamp: ts})
+	flow.Data = append(flow.Data, data...)
+}
+
+// flowTime returns when the byte at offset of a flow was captured.
+func flowTime(flow *model.TCPFlow, offset int) time.Time {
+	i := sort.Search(len(flow.Marks), func(i int) bool { return flow.Marks[i].Offset > offset })
+	if i == 0 {
+		return time.Time{}
+	}
+	return flow.Marks[i-1].Timestamp
+}
+
+func streamKey(clientIP string, clientPort int, serverIP string, serverPort int) string {
+	return fmt.Sprintf("%s:%d>%s:%d", clientIP, clientPort, serverIP, serverPort)
+}
@@ -0,0 +1,103 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/gopacket/layers"
+)
+
+// TFTP opcodes (RFC 1350 and RFC 2347).
+const (
+	tftpRRQ   = 1
+	tftpWRQ   = 2
+	tftpData  = 3
+	tftpError = 5
+	tftpOACK  = 6
+)
+
+const tftpDefaultBlockSize = 512
+
+// processTFTP reassembles TFTP transfers. A read or write request to port 69 starts a transfer;
+// the server answers from another port, so its DATA blocks are matched by the client's address.
+// The file is carved when a block shorter than the block size ends the transfer.
+func processTFTP(udp *layers.UDP, srcIP, dstIP string, host *model.Host, summary *model.PcapSummary, ts time.Time, pcapFile string) {
+	payload := udp.Payload
+	if len(payload) < 4 {
+		return
+	}
+	if summary.TFTPTransfers == nil {
+		summary.TFTPTransfers = make(map[string]*model.TFTPTransfer)
+	}
+	src, dst := fmt.Sprintf("%s:%d", srcIP, udp.SrcPort), fmt.Sprintf("%s:%d", dstIP, udp.DstPort)
+	opcode := binary.BigEndian.Uint16(payload)
+
+	switch {
+	case udp.DstPort == 69 && (opcode == tftpRRQ || opcode == tftpWRQ):
+		fields := bytes.Split(payload[2:], []byte{0})
+		if len(fields) < 2 || len(fields[0]) == 0 {
+			return
+		}
+		summary.TFTPTransfers[src] = &model.TFTPTransfer{
+			ClientIP:  srcIP,
+			ServerIP:  dstIP,
+			Filename:  string(fields[0]),
+			Write:     opcode == tftpWRQ,
+			BlockSize: tftpDefaultBlockSize,
+			NextBlock: 1,
+			Host:      host,
+			PcapFile:  pcapFile,
+			Started:   ts,
+		}
+
+	case opcode == tftpOACK:
+		// The server accepted options; a negotiated block size changes how the end is recognised.
+		if transfer := summary.TFTPTransfers[dst]; transfer != nil {
+			fields := bytes.Split(payload[2:], []byte{0})
+			for i := 0; i+1 < len(fields); i += 2 {
+				if strings.EqualFold(string(fields[i]), "blksize") {
+					if size, err := strconv.Atoi(string(fields[i+1])); err == nil && size > 0 {
+						transfer.BlockSize = size
+					}
+				}
+			}
+		}
+
+	case opcode == tftpData:
+		key, transfer := dst, summary.TFTPTransfers[dst]
+		if transfer == nil || transfer.Write {
+			key, transfer = src, summary.TFTPTransfers[src]
+			if transfer == nil || !transfer.Write {
+				return
+			}
+		}
+		block, data := binary.BigEndian.Uint16(payload[2:]), payload[4:]
+		if block != transfer.NextBlock {
+			return // A retransmission, or a block after one that was lost
+		}
+		if len(transfer.Data)+len(data) > maxStreamBytes {
+			delete(summary.TFTPTransfers, key)
+			return
+		}
+		transfer.Data = append(transfer.Data, data...)
+		transfer.NextBlock++
+		if len(data) < transfer.BlockSize {
+			srcIP, dstIP := transfer.ServerIP, transfer.ClientIP
+			if transfer.Write {
+				srcIP, dstIP = dstIP, srcIP
+			}
+			addCarvedFile(summary, transfer.Host, "TFTP", carvedFilename(transfer.Filename, "tftp"), "", srcIP, dstIP,
+				transfer.Started, transfer.PcapFile, transfer.Data)
+			delete(summary.TFTPTransfers, key)
+		}
+
+	case opcode == tftpError:
+		delete(summary.TFTPTransfers, src)
+		delete(summary.TFTPTransfers, dst)
+	}
+}
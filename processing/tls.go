@@ -0,0 +1,640 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// TLS record and handshake message types.
+const (
+	tlsRecordHandshake = 22
+
+	tlsClientHello     = 1
+	tlsServerHello     = 2
+	tlsCertificate     = 11
+	tlsServerHelloDone = 14
+)
+
+// TLS extensions that feed the fingerprints.
+const (
+	tlsExtServerName          = 0x0000
+	tlsExtSupportedGroups     = 0x000a
+	tlsExtECPointFormats      = 0x000b
+	tlsExtSignatureAlgorithms = 0x000d
+	tlsExtALPN                = 0x0010
+	tlsExtSupportedVersions   = 0x002b
+)
+
+const (
+	maxTLSHandshake = 64 * 1024 // Bytes buffered per direction before giving up on a handshake
+	tlsSource       = "TLS analysis"
+)
+
+var tlsVersionNames = map[uint16]string{
+	0x0300: "SSL 3.0", 0x0301: "TLS 1.0", 0x0302: "TLS 1.1", 0x0303: "TLS 1.2", 0x0304: "TLS 1.3",
+}
+
+// ja4Versions are the version codes used in JA4 fingerprints.
+var ja4Versions = map[uint16]string{
+	0x0002: "s2", 0x0300: "s3", 0x0301: "10", 0x0302: "11", 0x0303: "12", 0x0304: "13",
+}
+
+// weakCiphers lists cipher suites that offer no or broken protection. Suites without encryption
+// or authentication, export-grade and single DES suites are critical; RC4 and 3DES are potential.
+var weakCiphers = map[uint16]struct {
+	name     string
+	category model.FindingCategory
+}{
+	0x0000: {"TLS_NULL_WITH_NULL_NULL", model.CriticalFinding},
+	0x0001: {"TLS_RSA_WITH_NULL_MD5", model.CriticalFinding},
+	0x0002: {"TLS_RSA_WITH_NULL_SHA", model.CriticalFinding},
+	0x003b: {"TLS_RSA_WITH_NULL_SHA256", model.CriticalFinding},
+	0xc006: {"TLS_ECDHE_ECDSA_WITH_NULL_SHA", model.CriticalFinding},
+	0xc010: {"TLS_ECDHE_RSA_WITH_NULL_SHA", model.CriticalFinding},
+	0x0003: {"TLS_RSA_EXPORT_WITH_RC4_40_MD5", model.CriticalFinding},
+	0x0006: {"TLS_RSA_EXPORT_WITH_RC2_CBC_40_MD5", model.CriticalFinding},
+	0x0008: {"TLS_RSA_EXPORT_WITH_DES40_CBC_SHA", model.CriticalFinding},
+	0x0014: {"TLS_DHE_RSA_EXPORT_WITH_DES40_CBC_SHA", model.CriticalFinding},
+	0x0009: {"TLS_RSA_WITH_DES_CBC_SHA", model.CriticalFinding},
+	0x0015: {"TLS_DHE_RSA_WITH_DES_CBC_SHA", model.CriticalFinding},
+	0x0018: {"TLS_DH_anon_WITH_RC4_128_MD5", model.CriticalFinding},
+	0x001b: {"TLS_DH_anon_WITH_3DES_EDE_CBC_SHA", model.CriticalFinding},
+	0x0034: {"TLS_DH_anon_WITH_AES_128_CBC_SHA", model.CriticalFinding},
+	0x003a: {"TLS_DH_anon_WITH_AES_256_CBC_SHA", model.CriticalFinding},
+	0xc018: {"TLS_ECDH_anon_WITH_AES_128_CBC_SHA", model.CriticalFinding},
+	0xc019: {"TLS_ECDH_anon_WITH_AES_256_CBC_SHA", model.CriticalFinding},
+	0x0004: {"TLS_RSA_WITH_RC4_128_MD5", model.PotentialFinding},
+	0x0005: {"TLS_RSA_WITH_RC4_128_SHA", model.PotentialFinding},
+	0xc007: {"TLS_ECDHE_ECDSA_WITH_RC4_128_SHA", model.PotentialFinding},
+	0xc011: {"TLS_ECDHE_RSA_WITH_RC4_128_SHA", model.PotentialFinding},
+	0x000a: {"TLS_RSA_WITH_3DES_EDE_CBC_SHA", model.PotentialFinding},
+	0x0016: {"TLS_DHE_RSA_WITH_3DES_EDE_CBC_SHA", model.PotentialFinding},
+	0xc008: {"TLS_ECDHE_ECDSA_WITH_3DES_EDE_CBC_SHA", model.PotentialFinding},
+	0xc012: {"TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA", model.PotentialFinding},
+}
+
+// clientHello holds the ClientHello fields used for session metadata and fingerprints.
+type clientHello struct {
+	version           uint16
+	ciphers           []uint16
+	extensions        []uint16
+	groups            []uint16
+	pointFormats      []uint16
+	signatureAlgs     []uint16
+	supportedVersions []uint16
+	sni               string
+	alpn              []string
+}
+
+type serverHello struct {
+	version    uint16
+	cipher     uint16
+	extensions []uint16
+}
+
+type tlsMessage struct {
+	msgType byte
+	body    []byte
+}
+
+// processTLS follows the handshake of a TLS connection over TCP. A ClientHello starts tracking
+// a connection and records the session on host; the server's first flight then fills in the
+// negotiated parameters and certificate and raises findings for weak protection. Each direction
+// is reassembled in sequence order, and the connection stops being tracked once the server's
+// flight has been parsed.
+func processTLS(packet gopacket.Packet, tcp *layers.TCP, srcIP, dstIP string, host *model.Host, networkMap *model.NetworkMap, summary *model.PcapSummary, pcapFile string) {
+	if summary.TLSStreams == nil {
+		summary.TLSStreams = make(map[string]*model.TLSStream)
+	}
+	key := fmt.Sprintf("%s:%d>%s:%d", srcIP, tcp.SrcPort, dstIP, tcp.DstPort)
+	if tcp.SYN {
+		delete(summary.TLSStreams, key) // A new connection on the same ports
+	}
+	payload := tcp.Payload
+	if len(payload) == 0 {
+		return
+	}
+
+	stream := summary.TLSStreams[key]
+	if stream == nil {
+		if len(payload) < 6 || payload[0] != tlsRecordHandshake || payload[1] != 3 || payload[5] != tlsClientHello {
+			return
+		}
+		stream = &model.TLSStream{Host: host}
+		summary.TLSStreams[key] = stream
+	}
+	if stream.Done {
+		return
+	}
+	ts := packet.Metadata().Timestamp
+	addSegment(&stream.Flow, tcp, ts)
+	messages, finished := tlsHandshakeMessages(stream.Flow.Data)
+	complete := finished || len(stream.Flow.Data) >= maxTLSHandshake || len(stream.Flow.Pending) >= maxPendingSegments
+	reverse := fmt.Sprintf("%s:%d>%s:%d", dstIP, tcp.DstPort, srcIP, tcp.SrcPort)
+
+	if stream.Session == nil {
+		for _, msg := range messages {
+			if msg.msgType != tlsClientHello {
+				continue
+			}
+			if hello := parseClientHello(msg.body); hello != nil {
+				session := recordClientHello(stream.Host, hello, srcIP, dstIP, int(tcp.DstPort), ts, pcapFile)
+				summary.TLSStreams[reverse] = &model.TLSStream{Session: session, Host: stream.Host}
+			}
+			complete = true
+			break
+		}
+	} else {
+		for _, msg := range messages {
+			if msg.msgType == tlsServerHelloDone {
+				complete = true
+			}
+		}
+		if complete {
+			recordServerFlight(stream.Session, messages)
+			checkTLSSession(networkMap, stream.Host, stream.Session, ts)
+			// Both directions are parsed; later records of the connection are not handshakes.
+			delete(summary.TLSStreams, key)
+			delete(summary.TLSStreams, reverse)
+			return
+		}
+	}
+	if complete {
+		stream.Done, stream.Flow = true, model.TCPFlow{}
+		if summary.TLSStreams[reverse] == nil {
+			delete(summary.TLSStreams, key) // No ClientHello was found, so no reply is awaited
+		}
+	}
+}
+
+// recordClientHello adds a handshake to the matching session of host, creating it if needed. The
+// SNI is recorded as a lookup of the client when the client is a local host.
+func recordClientHello(host *model.Host, hello *clientHello, clientIP, serverIP string, serverPort int, ts time.Time, pcapFile string) *model.TLSSession {
+	if host.TLSSessions == nil {
+		host.TLSSessions = make(map[string]*model.TLSSession)
+	}
+	session := &model.TLSSession{
+		ClientIP:   clientIP,
+		ServerIP:   serverIP,
+		ServerPort: serverPort,
+		SNI:        hello.sni,
+		ALPN:       strings.Join(hello.alpn, ", "),
+		JA3:        ja3(hello),
+		JA4:        ja4(hello),
+		PcapFile:   pcapFile,
+	}
+	if existing, ok := host.TLSSessions[session.Key()]; ok {
+		session = existing
+	} else {
+		host.TLSSessions[session.Key()] = session
+	}
+	session.Connections++
+	seen(&session.FirstSeen, &session.LastSeen, ts)
+
+	if hello.sni != "" && isPrivateIP(net.ParseIP(clientIP)) {
+		host.DNSLookups[hello.sni] = true
+	}
+	return session
+}
+
+// recordServerFlight copies the negotiated version, cipher and leaf certificate from the
+// server's handshake messages onto the session.
+func recordServerFlight(session *model.TLSSession, messages []tlsMessage) {
+	for _, msg := range messages {
+		switch msg.msgType {
+		case tlsServerHello:
+			hello := parseServerHello(msg.body)
+			if hello == nil {
+				continue
+			}
+			session.Version = tlsVersionName(hello.version)
+			session.Cipher = cipherSuiteName(hello.cipher)
+			session.JA3S = ja3s(hello)
+		case tlsCertificate:
+			if certs := parseCertificateList(msg.body); len(certs) > 0 {
+				session.Certificate = tlsCertificateInfo(certs[0])
+			}
+		}
+	}
+}
+
+// checkTLSSession raises findings for a deprecated protocol version, a weak cipher suite or an
+// expired certificate. They go on the server's host with its port when the server is local, and
+// on the client's host otherwise.
+func checkTLSSession(networkMap *model.NetworkMap, clientHost *model.Host, session *model.TLSSession, ts time.Time) {
+	target, portID := clientHost, 0
+	if isPrivateIP(net.ParseIP(session.ServerIP)) {
+		if server := findHostByIP(networkMap, session.ServerIP); server != nil {
+			target, portID = server, session.ServerPort
+		}
+	}
+	endpoint := fmt.Sprintf("%s:%d", session.ServerIP, session.ServerPort)
+	if session.SNI != "" {
+		endpoint += " (" + session.SNI + ")"
+	}
+
+	switch session.Version {
+	case "SSL 3.0":
+		addFinding(target, model.Vulnerability{
+			CVE:         "TLS-WEAK-VERSION",
+			Description: fmt.Sprintf("%s negotiated SSL 3.0 with %s. SSL 3.0 is broken (POODLE) and must be disabled.", endpoint, session.ClientIP),
+			Category:    model.CriticalFinding,
+			PortID:      portID,
+			State:       stateDetected,
+			Source:      tlsSource,
+		})
+	case "TLS 1.0", "TLS 1.1":
+		addFinding(target, model.Vulnerability{
+			CVE:         "TLS-WEAK-VERSION",
+			Description: fmt.Sprintf("%s negotiated %s with %s. TLS 1.0 and 1.1 are deprecated (RFC 8996).", endpoint, session.Version, session.ClientIP),
+			Category:    model.PotentialFinding,
+			PortID:      portID,
+			State:       stateDetected,
+			Source:      tlsSource,
+		})
+	}
+
+	for _, weak := range weakCiphers {
+		if session.Cipher == weak.name {
+			addFinding(target, model.Vulnerability{
+				CVE:         "TLS-WEAK-CIPHER",
+				Description: fmt.Sprintf("%s negotiated the weak cipher suite %s with %s.", endpoint, session.Cipher, session.ClientIP),
+				Category:    weak.category,
+				PortID:      portID,
+				State:       stateDetected,
+				Source:      tlsSource,
+			})
+			break
+		}
+	}
+
+	if cert := session.Certificate; cert != nil {
+		if ts.IsZero() {
+			ts = time.Now()
+		}
+		if ts.After(cert.NotAfter) {
+			addFinding(target, model.Vulnerability{
+				CVE:         "TLS-EXPIRED-CERT",
+				Description: fmt.Sprintf("%s presented a certificate for %s that expired on %s.", endpoint, cert.Subject, cert.NotAfter.Format("2006-01-02")),
+				Category:    model.PotentialFinding,
+				PortID:      portID,
+				State:       stateDetected,
+				Source:      tlsSource,
+			})
+		}
+	}
+}
+
+// tlsHandshakeMessages splits a stream of TLS records into its complete handshake messages. It
+// also reports whether a record of another type follows them, after which the rest of the
+// handshake, if any, is encrypted.
+func tlsHandshakeMessages(records []byte) (messages []tlsMessage, finished bool) {
+	var handshake []byte
+	for len(records) >= 5 {
+		if records[0] != tlsRecordHandshake {
+			finished = true
+			break
+		}
+		end := min(5+int(binary.BigEndian.Uint16(records[3:5])), len(records))
+		handshake = append(handshake, records[5:end]...)
+		records = records[end:]
+	}
+
+	for len(handshake) >= 4 {
+		msgLen := uint24(handshake[1:4])
+		if len(handshake) < 4+msgLen {
+			break
+		}
+		messages = append(messages, tlsMessage{msgType: handshake[0], body: handshake[4 : 4+msgLen]})
+		handshake = handshake[4+msgLen:]
+	}
+	return messages, finished
+}
+
+// tlsCertificates returns the certificate chain from the Certificate message in a stream of TLS
+// records. TLS 1.3 encrypts that message, so nothing is found for it.
+func tlsCertificates(records []byte) []*x509.Certificate {
+	messages, _ := tlsHandshakeMessages(records)
+	for _, msg := range messages {
+		if msg.msgType == tlsCertificate {
+			return parseCertificateList(msg.body)
+		}
+	}
+	return nil
+}
+
+// parseCertificateList parses the body of a TLS 1.2 Certificate message, skipping entries that
+// are not valid X.509.
+func parseCertificateList(body []byte) []*x509.Certificate {
+	if len(body) < 3 {
+		return nil
+	}
+	var certs []*x509.Certificate
+	for list := body[3:]; len(list) >= 3; {
+		certLen := uint24(list[:3])
+		if len(list) < 3+certLen {
+			break
+		}
+		if cert, err := x509.ParseCertificate(list[3 : 3+certLen]); err == nil {
+			certs = append(certs, cert)
+		}
+		list = list[3+certLen:]
+	}
+	return certs
+}
+
+// tlsCertificateInfo extracts what we report about a certificate.
+func tlsCertificateInfo(cert *x509.Certificate) *model.TLSCertificate {
+	info := &model.TLSCertificate{
+		Subject:   cert.Subject.String(),
+		Issuer:    cert.Issuer.String(),
+		NotBefore: cert.NotBefore.UTC(),
+		NotAfter:  cert.NotAfter.UTC(),
+		SHA256:    fmt.Sprintf("%x", sha256.Sum256(cert.Raw)),
+	}
+	info.SANs = append(info.SANs, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		info.SANs = append(info.SANs, ip.String())
+	}
+	info.SelfSigned = bytes.Equal(cert.RawSubject, cert.RawIssuer) &&
+		cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+	return info
+}
+
+// describeCertificate summarises a certificate's subject, issuer, validity and fingerprint.
+func describeCertificate(cert *model.TLSCertificate) string {
+	return fmt.Sprintf("%s (issuer %s, valid %s to %s, SHA-256 %s)", cert.Subject, cert.Issuer,
+		cert.NotBefore.Format("2006-01-02"), cert.NotAfter.Format("2006-01-02"), cert.SHA256)
+}
+
+// parseClientHello parses the body of a ClientHello message.
+func parseClientHello(body []byte) *clientHello {
+	r := &tlsReader{data: body}
+	hello := &clientHello{version: r.u16()}
+	r.skip(32)  // Random
+	r.vector(1) // Session ID
+	suites := r.vector(2)
+	r.vector(1) // Compression methods
+	extensions := r.vector(2)
+	if r.err {
+		return nil
+	}
+	for s := (&tlsReader{data: suites}); s.remaining() >= 2; {
+		hello.ciphers = append(hello.ciphers, s.u16())
+	}
+
+	for e := (&tlsReader{data: extensions}); e.remaining() >= 4; {
+		extType, data := e.u16(), e.vector(2)
+		if e.err {
+			break
+		}
+		hello.extensions = append(hello.extensions, extType)
+		d := &tlsReader{data: data}
+		switch extType {
+		case tlsExtServerName:
+			list := &tlsReader{data: d.vector(2)}
+			for list.remaining() >= 3 {
+				nameType, name := list.u8(), list.vector(2)
+				if nameType == 0 && hello.sni == "" {
+					hello.sni = string(name)
+				}
+			}
+		case tlsExtSupportedGroups:
+			hello.groups = u16List(d.vector(2))
+		case tlsExtECPointFormats:
+			for _, f := range d.vector(1) {
+				hello.pointFormats = append(hello.pointFormats, uint16(f))
+			}
+		case tlsExtSignatureAlgorithms:
+			hello.signatureAlgs = u16List(d.vector(2))
+		case tlsExtALPN:
+			list := &tlsReader{data: d.vector(2)}
+			for list.remaining() >= 1 {
+				if proto := list.vector(1); !list.err {
+					hello.alpn = append(hello.alpn, string(proto))
+				}
+			}
+		case tlsExtSupportedVersions:
+			hello.supportedVersions = u16List(d.vector(1))
+		}
+	}
+	return hello
+}
+
+// parseServerHello parses the body of a ServerHello message. The version is the one selected in
+// the supported_versions extension when present (TLS 1.3).
+func parseServerHello(body []byte) *serverHello {
+	r := &tlsReader{data: body}
+	hello := &serverHello{version: r.u16()}
+	r.skip(32)  // Random
+	r.vector(1) // Session ID
+	hello.cipher = r.u16()
+	r.skip(1) // Compression method
+	if r.err {
+		return nil
+	}
+	extensions := &tlsReader{data: r.vector(2)}
+	for extensions.remaining() >= 4 {
+		extType, data := extensions.u16(), extensions.vector(2)
+		if extensions.err {
+			break
+		}
+		hello.extensions = append(hello.extensions, extType)
+		if extType == tlsExtSupportedVersions && len(data) == 2 {
+			hello.version = binary.BigEndian.Uint16(data)
+		}
+	}
+	return hello
+}
+
+// ja3 computes the JA3 fingerprint of a ClientHello: the MD5 of its version, cipher suites,
+// extensions, groups and point formats, ignoring GREASE values.
+func ja3(hello *clientHello) string {
+	full := fmt.Sprintf("%d,%s,%s,%s,%s", hello.version, joinDecimal(hello.ciphers), joinDecimal(hello.extensions),
+		joinDecimal(hello.groups), joinDecimal(hello.pointFormats))
+	return fmt.Sprintf("%x", md5.Sum([]byte(full)))
+}
+
+// ja3s computes the JA3S fingerprint of a ServerHello.
+func ja3s(hello *serverHello) string {
+	full := fmt.Sprintf("%d,%d,%s", hello.version, hello.cipher, joinDecimal(hello.extensions))
+	return fmt.Sprintf("%x", md5.Sum([]byte(full)))
+}
+
+// ja4 computes the JA4 fingerprint of a ClientHello seen over TCP, e.g.
+// "t13d1516h2_8daaf6152771_e5627efa2ab1".
+func ja4(hello *clientHello) string {
+	version := hello.version
+	for _, v := range hello.supportedVersions {
+		if !isGREASE(v) && v > version {
+			version = v
+		}
+	}
+	versionCode, ok := ja4Versions[version]
+	if !ok {
+		versionCode = "00"
+	}
+	destination := "i"
+	if hello.sni != "" {
+		destination = "d"
+	}
+
+	ciphers := withoutGREASE(hello.ciphers)
+	extensions := withoutGREASE(hello.extensions)
+	alpn := "00"
+	if len(hello.alpn) > 0 && hello.alpn[0] != "" {
+		first := hello.alpn[0]
+		a, b := first[0], first[len(first)-1]
+		if isAlphanumeric(a) && isAlphanumeric(b) {
+			alpn = string([]byte{a, b})
+		} else {
+			h := fmt.Sprintf("%x", first)
+			alpn = string([]byte{h[0], h[len(h)-1]})
+		}
+	}
+	prefix := fmt.Sprintf("t%s%s%02d%02d%s", versionCode, destination, min(len(ciphers), 99), min(len(extensions), 99), alpn)
+
+	sortedCiphers := append([]uint16(nil), ciphers...)
+	sort.Slice(sortedCiphers, func(i, j int) bool { return sortedCiphers[i] < sortedCiphers[j] })
+	var sortedExtensions []uint16
+	for _, ext := range extensions {
+		if ext != tlsExtServerName && ext != tlsExtALPN {
+			sortedExtensions = append(sortedExtensions, ext)
+		}
+	}
+	sort.Slice(sortedExtensions, func(i, j int) bool { return sortedExtensions[i] < sortedExtensions[j] })
+
+	extensionList := joinHex(sortedExtensions)
+	if sigs := joinHex(hello.signatureAlgs); sigs != "" {
+		extensionList += "_" + sigs
+	}
+	return prefix + "_" + truncatedSHA256(joinHex(sortedCiphers), len(ciphers)) + "_" + truncatedSHA256(extensionList, len(sortedExtensions))
+}
+
+func truncatedSHA256(s string, count int) string {
+	if count == 0 {
+		return "000000000000"
+	}
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(s)))[:12]
+}
+
+// isGREASE reports whether v is one of the reserved GREASE values (RFC 8701) clients add to
+// keep servers tolerant of unknown values.
+func isGREASE(v uint16) bool {
+	return v&0x0f0f == 0x0a0a && v>>8 == v&0xff
+}
+
+func withoutGREASE(values []uint16) []uint16 {
+	out := make([]uint16, 0, len(values))
+	for _, v := range values {
+		if !isGREASE(v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func joinDecimal(values []uint16) string {
+	parts := make([]string, 0, len(values))
+	for _, v := range withoutGREASE(values) {
+		parts = append(parts, strconv.Itoa(int(v)))
+	}
+	return strings.Join(parts, "-")
+}
+
+func joinHex(values []uint16) string {
+	parts := make([]string, 0, len(values))
+	for _, v := range values {
+		parts = append(parts, fmt.Sprintf("%04x", v))
+	}
+	return strings.Join(parts, ",")
+}
+
+func isAlphanumeric(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func tlsVersionName(version uint16) string {
+	if name, ok := tlsVersionNames[version]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%04X", version)
+}
+
+// cipherSuiteName names a cipher suite, including the legacy suites Go does not know.
+func cipherSuiteName(id uint16) string {
+	if weak, ok := weakCiphers[id]; ok {
+		return weak.name
+	}
+	return tls.CipherSuiteName(id)
+}
+
+func uint24(b []byte) int {
+	return int(b[0])<<16 | int(b[1])<<8 | int(b[2])
+}
+
+// tlsReader reads big-endian fields from a handshake message. Reading past the end sets err and
+// yields zero values, so a truncated message can be parsed without checking every field.
+type tlsReader struct {
+	data []byte
+	err  bool
+}
+
+func (r *tlsReader) remaining() int {
+	return len(r.data)
+}
+
+func (r *tlsReader) skip(n int) []byte {
+	if n > len(r.data) {
+		r.err, r.data = true, nil
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *tlsReader) u8() int {
+	if b := r.skip(1); b != nil {
+		return int(b[0])
+	}
+	return 0
+}
+
+func (r *tlsReader) u16() uint16 {
+	if b := r.skip(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+// vector reads a field prefixed with a length of lengthBytes bytes.
+func (r *tlsReader) vector(lengthBytes int) []byte {
+	var n int
+	for _, b := range r.skip(lengthBytes) {
+		n = n<<8 | int(b)
+	}
+	return r.skip(n)
+}
+
+func u16List(data []byte) []uint16 {
+	var values []uint16
+	for i := 0; i+1 < len(data); i += 2 {
+		values = append(values, binary.BigEndian.Uint16(data[i:]))
+	}
+	return values
+}
@@ -0,0 +1,233 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+var (
+	tlsClientMAC = net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x05}
+	tlsServerMAC = net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x0a}
+)
+
+// testPacket builds an Ethernet/IPv4 packet carrying transport and payload between the test
+// client (10.0.0.5) and a server. The transport layer's ports are given from the client's side
+// and swapped for packets from the server.
+func testPacket(t *testing.T, fromClient bool, serverMAC net.HardwareAddr, serverIP net.IP, transport, payload gopacket.SerializableLayer, ts time.Time) gopacket.Packet {
+	t.Helper()
+	eth := &layers.Ethernet{SrcMAC: tlsClientMAC, DstMAC: serverMAC, EthernetType: layers.EthernetTypeIPv4}
+	ip := &layers.IPv4{Version: 4, TTL: 64, SrcIP: net.IP{10, 0, 0, 5}, DstIP: serverIP}
+	if !fromClient {
+		eth.SrcMAC, eth.DstMAC = eth.DstMAC, eth.SrcMAC
+		ip.SrcIP, ip.DstIP = ip.DstIP, ip.SrcIP
+	}
+	switch l := transport.(type) {
+	case *layers.TCP:
+		ip.Protocol = layers.IPProtocolTCP
+		if !fromClient {
+			l.SrcPort, l.DstPort = l.DstPort, l.SrcPort
+		}
+		l.SetNetworkLayerForChecksum(ip)
+	case *layers.UDP:
+		ip.Protocol = layers.IPProtocolUDP
+		if !fromClient {
+			l.SrcPort, l.DstPort = l.DstPort, l.SrcPort
+		}
+		l.SetNetworkLayerForChecksum(ip)
+	}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, eth, ip, transport, payload); err != nil {
+		t.Fatalf("could not build test packet: %v", err)
+	}
+	packet := gopacket.NewPacket(buf.Bytes(), layers.LayerTypeEthernet, gopacket.Default)
+	packet.Metadata().Timestamp = ts
+	return packet
+}
+
+// tcpTestPacket builds a TCP packet between the TLS test client and server (10.0.0.10:443).
+func tcpTestPacket(t *testing.T, fromClient bool, clientPort uint16, payload []byte, ts time.Time) gopacket.Packet {
+	t.Helper()
+	return tcpTestSegment(t, fromClient, clientPort, 443, 0, false, payload, ts)
+}
+
+// tcpTestSegment builds a TCP segment between the test client and a server on 10.0.0.10, with a
+// sequence number for payloads split over several segments.
+func tcpTestSegment(t *testing.T, fromClient bool, clientPort, serverPort uint16, seq uint32, fin bool, payload []byte, ts time.Time) gopacket.Packet {
+	t.Helper()
+	tcp := &layers.TCP{SrcPort: layers.TCPPort(clientPort), DstPort: layers.TCPPort(serverPort), Seq: seq, ACK: true, PSH: len(payload) > 0, FIN: fin, Window: 65535}
+	return testPacket(t, fromClient, tlsServerMAC, net.IP{10, 0, 0, 10}, tcp, gopacket.Payload(payload), ts)
+}
+
+// udpTestPacket builds a UDP datagram between the test client and a server on 10.0.0.10.
+func udpTestPacket(t *testing.T, fromClient bool, clientPort, serverPort uint16, payload []byte, ts time.Time) gopacket.Packet {
+	t.Helper()
+	udp := &layers.UDP{SrcPort: layers.UDPPort(clientPort), DstPort: layers.UDPPort(serverPort)}
+	return testPacket(t, fromClient, tlsServerMAC, net.IP{10, 0, 0, 10}, udp, gopacket.Payload(payload), ts)
+}
+
+func u16s(values ...uint16) []byte {
+	b := make([]byte, 2*len(values))
+	for i, v := range values {
+		binary.BigEndian.PutUint16(b[2*i:], v)
+	}
+	return b
+}
+
+// vec prefixes data with its length in n bytes.
+func vec(n int, data ...byte) []byte {
+	length := make([]byte, n)
+	for i, l := 0, len(data); i < n; i, l = i+1, l>>8 {
+		length[n-1-i] = byte(l)
+	}
+	return append(length, data...)
+}
+
+func tlsExtension(extType uint16, data ...byte) []byte {
+	return append(u16s(extType), vec(2, data...)...)
+}
+
+func handshakeMessage(msgType byte, body []byte) []byte {
+	return append(append([]byte{msgType}, u24(len(body))...), body...)
+}
+
+func tlsRecord(messages ...[]byte) []byte {
+	var body []byte
+	for _, m := range messages {
+		body = append(body, m...)
+	}
+	return append([]byte{tlsRecordHandshake, 3, 1}, vec(2, body...)...)
+}
+
+func testClientHello() []byte {
+	var extensions []byte
+	extensions = append(extensions, tlsExtension(0x1a1a)...) // GREASE
+	extensions = append(extensions, tlsExtension(tlsExtServerName, vec(2, append([]byte{0}, vec(2, []byte("intranet.corp.example")...)...)...)...)...)
+	extensions = append(extensions, tlsExtension(tlsExtSupportedGroups, vec(2, u16s(0x001d, 0x0017)...)...)...)
+	extensions = append(extensions, tlsExtension(tlsExtECPointFormats, vec(1, 0)...)...)
+	extensions = append(extensions, tlsExtension(tlsExtSignatureAlgorithms, vec(2, u16s(0x0403, 0x0804)...)...)...)
+	extensions = append(extensions, tlsExtension(tlsExtALPN, vec(2, append(vec(1, []byte("h2")...), vec(1, []byte("http/1.1")...)...)...)...)...)
+	extensions = append(extensions, tlsExtension(tlsExtSupportedVersions, vec(1, u16s(0x0304, 0x0303)...)...)...)
+
+	body := u16s(0x0303)
+	body = append(body, make([]byte, 32)...) // Random
+	body = append(body, vec(1)...)           // Session ID
+	body = append(body, vec(2, u16s(0x0a0a, 0x1301, 0xc02f, 0x000a)...)...)
+	body = append(body, vec(1, 0)...) // Compression
+	body = append(body, vec(2, extensions...)...)
+	return tlsRecord(handshakeMessage(tlsClientHello, body))
+}
+
+// TestTLSMetadata verifies that TLS handshakes are recorded with their SNI, ALPN, negotiated
+// parameters, fingerprints and certificate, and that weak protection raises findings.
+func TestTLSMetadata(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "intranet.corp.example"},
+		DNSNames:     []string{"intranet.corp.example"},
+		NotBefore:    time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverHello := u16s(0x0301)
+	serverHello = append(serverHello, make([]byte, 32)...)
+	serverHello = append(serverHello, vec(1)...)
+	serverHello = append(serverHello, u16s(0x000a)...) // TLS_RSA_WITH_3DES_EDE_CBC_SHA
+	serverHello = append(serverHello, 0)
+	serverHello = append(serverHello, vec(2, tlsExtension(0xff01, 0)...)...)
+	flight := tlsRecord(
+		handshakeMessage(tlsServerHello, serverHello),
+		handshakeMessage(tlsCertificate, vec(3, vec(3, der...)...)),
+		handshakeMessage(tlsServerHelloDone, nil),
+	)
+
+	networkMap, summary := model.NewNetworkMap(), model.NewPcapSummary()
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, port := range []uint16{50000, 50001} {
+		ts := start.Add(time.Duration(i) * time.Minute)
+		// The server's flight is split over two segments, which the second connection receives
+		// out of order and with a retransmission.
+		first, second := tcpTestSegment(t, false, port, 443, 1000, false, flight[:100], ts), tcpTestSegment(t, false, port, 443, 1100, false, flight[100:], ts)
+		server := []gopacket.Packet{first, second}
+		if i == 1 {
+			server = []gopacket.Packet{second, first, first}
+		}
+		packets := append([]gopacket.Packet{tcpTestPacket(t, true, port, testClientHello(), ts)}, server...)
+		packets = append(packets, tcpTestSegment(t, true, port, 443, uint32(len(testClientHello())), false, []byte{20, 3, 1, 0, 1, 1}, ts)) // ChangeCipherSpec
+		for _, packet := range packets {
+			ProcessPacket(packet, networkMap, summary, "tls.pcap")
+		}
+	}
+
+	if len(summary.TLSStreams) != 0 {
+		t.Errorf("Expected finished handshakes to stop being tracked, got %d streams", len(summary.TLSStreams))
+	}
+
+	client := networkMap.Hosts["02:00:00:00:00:05"]
+	if client == nil || len(client.TLSSessions) != 1 {
+		t.Fatalf("Expected one TLS session on the client, got %+v", client)
+	}
+	var session *model.TLSSession
+	for _, s := range client.TLSSessions {
+		session = s
+	}
+	if session.ServerIP != "10.0.0.10" || session.ServerPort != 443 || session.SNI != "intranet.corp.example" || session.ALPN != "h2, http/1.1" {
+		t.Errorf("Unexpected endpoint, SNI or ALPN: %+v", session)
+	}
+	if session.Version != "TLS 1.0" || session.Cipher != "TLS_RSA_WITH_3DES_EDE_CBC_SHA" || session.Connections != 2 {
+		t.Errorf("Unexpected negotiated parameters: %+v", session)
+	}
+	if !session.FirstSeen.Equal(start) || !session.LastSeen.Equal(start.Add(time.Minute)) || session.PcapFile != "tls.pcap" {
+		t.Errorf("Unexpected seen window or source: %+v", session)
+	}
+	// 771,4865-49199-10,0-10-11-13-16-43,29-23,0 and 769,10,65281
+	if session.JA3 != "2b686b83311cff6b8fe1b1cfb091216c" || session.JA3S != "1cd011e6a890cdd03300231698e37028" {
+		t.Errorf("Unexpected JA3/JA3S %s/%s", session.JA3, session.JA3S)
+	}
+	if want := "t13d0306h2_5a6cbdaee546_fb71836bce29"; session.JA4 != want {
+		t.Errorf("Expected JA4 %s, got %s", want, session.JA4)
+	}
+	cert := session.Certificate
+	if cert == nil || cert.Subject != "CN=intranet.corp.example" || !cert.SelfSigned || len(cert.SANs) != 1 || !cert.NotAfter.Equal(template.NotAfter) {
+		t.Errorf("Unexpected certificate: %+v", cert)
+	}
+	if !client.DNSLookups["intranet.corp.example"] {
+		t.Error("Expected the SNI to be recorded as a lookup of the client")
+	}
+
+	server := networkMap.Hosts["02:00:00:00:00:0A"]
+	for _, id := range []string{"TLS-WEAK-VERSION", "TLS-WEAK-CIPHER", "TLS-EXPIRED-CERT"} {
+		var found int
+		for _, v := range server.Findings[model.PotentialFinding] {
+			if v.CVE == id && v.PortID == 443 && v.Source == tlsSource {
+				found++
+			}
+		}
+		if found != 1 {
+			t.Errorf("Expected one %s finding on the server's port 443, got %d", id, found)
+		}
+	}
+	if len(client.Findings) != 0 {
+		t.Errorf("Expected no findings on the client, got %v", client.Findings)
+	}
+}
@@ -0,0 +1,443 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// NessusReport represents the top-level structure of a .nessus (v2) file.
+type NessusReport struct {
+	Hosts []NessusHost `xml:"Report>ReportHost"`
+}
+
+// NessusHost holds the properties and findings for one scanned host.
+type NessusHost struct {
+	Name       string       `xml:"name,attr"`
+	Properties []NessusTag  `xml:"HostProperties>tag"`
+	Items      []NessusItem `xml:"ReportItem"`
+}
+
+// NessusTag is a single host property (e.g. host-ip, mac-address, operating-system).
+type NessusTag struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:",chardata"`
+}
+
+// NessusItem is the result of a single plugin against a host and port.
+type NessusItem struct {
+	Port         int      `xml:"port,attr"`
+	ServiceName  string   `xml:"svc_name,attr"`
+	Protocol     string   `xml:"protocol,attr"`
+	Severity     int      `xml:"severity,attr"`
+	PluginID     string   `xml:"pluginID,attr"`
+	PluginName   string   `xml:"pluginName,attr"`
+	CVEs         []string `xml:"cve"`
+	CVSS3Score   float64  `xml:"cvss3_base_score"`
+	CVSSScore    float64  `xml:"cvss_base_score"`
+	Synopsis     string   `xml:"synopsis"`
+	PluginOutput string   `xml:"plugin_output"`
+	SeeAlso      string   `xml:"see_also"`
+	ExploitAvail bool     `xml:"exploit_available"`
+}
+
+// OpenVASResult is a single NVT result in an OpenVAS/GVM XML report.
+type OpenVASResult struct {
+	Name string `xml:"name"`
+	Host struct {
+		Address  string `xml:",chardata"`
+		Hostname string `xml:"hostname"`
+	} `xml:"host"`
+	Port        string     `xml:"port"` // e.g. "443/tcp" or "general/tcp"
+	NVT         OpenVASNVT `xml:"nvt"`
+	Threat      string     `xml:"threat"`
+	Severity    float64    `xml:"severity"`
+	Description string     `xml:"description"`
+}
+
+// OpenVASNVT describes the vulnerability test that produced a result.
+type OpenVASNVT struct {
+	OID      string       `xml:"oid,attr"`
+	Name     string       `xml:"name"`
+	CVSSBase float64      `xml:"cvss_base"`
+	Tags     string       `xml:"tags"`
+	Refs     []OpenVASRef `xml:"refs>ref"`
+	CVE      string       `xml:"cve"`  // Older report formats
+	XRef     string       `xml:"xref"` // Older report formats
+}
+
+// OpenVASRef is a reference (CVE, URL, advisory) attached to an NVT.
+type OpenVASRef struct {
+	Type string `xml:"type,attr"`
+	ID   string `xml:"id,attr"`
+}
+
+// OpenVASHost holds the per-host details collected during a GVM scan.
+type OpenVASHost struct {
+	IP      string `xml:"ip"`
+	Details []struct {
+		Name  string `xml:"name"`
+		Value string `xml:"value"`
+	} `xml:"detail"`
+}
+
+// MergeFromVulnScanFile parses a Nessus or OpenVAS/GVM report and merges its hosts and
+// findings into the NetworkMap. The format is detected from the file content.
+func MergeFromVulnScanFile(filename string, networkMap *model.NetworkMap) error {
+	fileType, err := DetectFileType(filename)
+	if err != nil {
+		return err
+	}
+	data, err := ReadDataFile(filename)
+	if err != nil {
+		return fmt.Errorf("could not read vulnerability report %s: %w", filename, err)
+	}
+	switch fileType {
+	case FileTypeNessus:
+		return MergeFromNessusXML(data, networkMap)
+	case FileTypeOpenVAS:
+		return MergeFromOpenVASXML(data, networkMap)
+	}
+	return fmt.Errorf("unrecognised vulnerability report format in %s", filename)
+}
+
+// MergeFromNessusXML parses a .nessus report and merges it into the NetworkMap.
+func MergeFromNessusXML(data []byte, networkMap *model.NetworkMap) error {
+	var report NessusReport
+	if err := xml.Unmarshal(data, &report); err != nil {
+		return fmt.Errorf("could not unmarshal nessus xml: %w", err)
+	}
+
+	for _, nessusHost := range report.Hosts {
+		props := make(map[string]string)
+		for _, tag := range nessusHost.Properties {
+			props[tag.Name] = strings.TrimSpace(tag.Value)
+		}
+		ip := defaultIfEmpty(props["host-ip"], nessusHost.Name)
+		// Nessus lists every interface MAC, one per line; the first one identifies the host.
+		mac, _, _ := strings.Cut(props["mac-address"], "\n")
+
+		host := findOrCreateScannedHost(networkMap, strings.TrimSpace(mac), ip, "Nessus")
+		if host == nil {
+			continue
+		}
+		if fqdn := props["host-fqdn"]; fqdn != "" {
+			host.Hostnames[fqdn] = "user"
+		}
+		if netbios := props["netbios-name"]; netbios != "" {
+			host.Hostnames[netbios] = "NetBIOS"
+		}
+		if osName := props["operating-system"]; osName != "" && host.Fingerprint.OperatingSystem == "" {
+			host.Fingerprint.OperatingSystem, _, _ = strings.Cut(osName, "\n")
+		}
+
+		for _, item := range nessusHost.Items {
+			if item.Port > 0 {
+				addScannedPort(host, item.Port, item.Protocol, item.ServiceName)
+			}
+			for _, vuln := range toNessusFindings(item) {
+				host.Findings[vuln.Category] = append(host.Findings[vuln.Category], vuln)
+			}
+		}
+	}
+
+	networkMap.ScanRuns = append(networkMap.ScanRuns, model.ScanRun{
+		Scanner:    "nessus",
+		HostsUp:    len(report.Hosts),
+		HostsTotal: len(report.Hosts),
+	})
+	return nil
+}
+
+// toNessusFindings converts a Nessus plugin result into vulnerabilities: one per CVE the plugin
+// lists, or a single one under the plugin ID for plugins without a CVE.
+func toNessusFindings(item NessusItem) []model.Vulnerability {
+	vuln := model.Vulnerability{
+		PortID: item.Port,
+		CVSS:   item.CVSS3Score,
+		Source: "Nessus plugin " + item.PluginID,
+	}
+	if vuln.CVSS == 0 {
+		vuln.CVSS = item.CVSSScore
+	}
+	vuln.Category = nessusCategory(item.Severity, vuln.CVSS)
+	if item.Severity > 0 {
+		vuln.State = stateVulnerable
+	}
+	for _, url := range strings.Fields(item.SeeAlso) {
+		vuln.References = append(vuln.References, url)
+	}
+
+	description := item.PluginName
+	if item.Synopsis != "" {
+		description += "\n" + strings.TrimSpace(item.Synopsis)
+	}
+	if item.PluginOutput != "" {
+		description += "\n\nPlugin output:\n" + strings.TrimSpace(item.PluginOutput)
+	}
+	if item.ExploitAvail {
+		description += "\n\nA public exploit is available."
+	}
+	vuln.Description = description
+
+	var cves []string
+	for _, cve := range item.CVEs {
+		if cve = strings.TrimSpace(cve); cve != "" {
+			cves = append(cves, cve)
+		}
+	}
+	if len(cves) == 0 {
+		cves = append(cves, "NESSUS-"+item.PluginID)
+	}
+	findings := make([]model.Vulnerability, 0, len(cves))
+	for _, cve := range cves {
+		finding := vuln
+		finding.CVE = cve
+		findings = append(findings, finding)
+	}
+	return findings
+}
+
+// nessusCategory maps a Nessus severity (0 = info ... 4 = critical) and CVSS score to a finding
+// category. High severity results are critical when their score reaches the configured threshold.
+func nessusCategory(severity int, cvss float64) model.FindingCategory {
+	criticalCVSS, _ := cvssThresholds()
+	switch {
+	case severity >= 4:
+		return model.CriticalFinding
+	case severity == 3 && cvss >= criticalCVSS:
+		return model.CriticalFinding
+	case severity >= 2:
+		return model.PotentialFinding
+	}
+	return model.InformationalFinding
+}
+
+// MergeFromOpenVASXML parses an OpenVAS/GVM XML report and merges it into the NetworkMap.
+// The report is streamed so both exported files and raw GMP responses are accepted.
+func MergeFromOpenVASXML(data []byte, networkMap *model.NetworkMap) error {
+	var results []OpenVASResult
+	var hosts []OpenVASHost
+
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("could not parse openvas xml: %w", err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "result":
+			var result OpenVASResult
+			if err := decoder.DecodeElement(&result, &start); err != nil {
+				return fmt.Errorf("could not decode openvas result: %w", err)
+			}
+			if result.NVT.OID != "" {
+				results = append(results, result)
+			}
+		case "host":
+			// Results embed their own <host> element, so this only sees the report's host details.
+			var host OpenVASHost
+			if err := decoder.DecodeElement(&host, &start); err != nil {
+				return fmt.Errorf("could not decode openvas host: %w", err)
+			}
+			if host.IP != "" {
+				hosts = append(hosts, host)
+			}
+		}
+	}
+
+	for _, h := range hosts {
+		var mac, osName string
+		for _, detail := range h.Details {
+			switch detail.Name {
+			case "MAC":
+				mac = detail.Value
+			case "best_os_txt":
+				osName = detail.Value
+			}
+		}
+		host := findOrCreateScannedHost(networkMap, mac, h.IP, "OpenVAS")
+		if host != nil && osName != "" && host.Fingerprint.OperatingSystem == "" {
+			host.Fingerprint.OperatingSystem = osName
+		}
+	}
+
+	scanned := make(map[string]bool)
+	for _, result := range results {
+		if strings.EqualFold(result.Threat, "False Positive") {
+			continue
+		}
+		ip := strings.TrimSpace(result.Host.Address)
+		host := findOrCreateScannedHost(networkMap, "", ip, "OpenVAS")
+		if host == nil {
+			continue
+		}
+		scanned[ip] = true
+		if result.Host.Hostname != "" {
+			host.Hostnames[result.Host.Hostname] = "user"
+		}
+
+		portID, protocol := parseOpenVASPort(result.Port)
+		if portID > 0 {
+			addScannedPort(host, portID, protocol, "")
+		}
+		vuln := toOpenVASFinding(result, portID)
+		host.Findings[vuln.Category] = append(host.Findings[vuln.Category], vuln)
+	}
+
+	networkMap.ScanRuns = append(networkMap.ScanRuns, model.ScanRun{
+		Scanner:    "openvas",
+		HostsUp:    len(scanned),
+		HostsTotal: len(scanned),
+	})
+	return nil
+}
+
+// toOpenVASFinding converts an NVT result into a vulnerability, following the same CVE and
+// reference conventions as the Nessus importer.
+func toOpenVASFinding(result OpenVASResult, portID int) model.Vulnerability {
+	vuln := model.Vulnerability{
+		CVE:      "OID-" + result.NVT.OID,
+		Category: openVASCategory(result.Threat, result.Severity),
+		PortID:   portID,
+		CVSS:     result.Severity,
+		Source:   "OpenVAS NVT " + result.NVT.OID,
+	}
+	if vuln.CVSS == 0 {
+		vuln.CVSS = result.NVT.CVSSBase
+	}
+	if vuln.Category != model.InformationalFinding {
+		vuln.State = stateVulnerable
+	}
+
+	var cves, urls []string
+	for _, ref := range result.NVT.Refs {
+		switch strings.ToLower(ref.Type) {
+		case "cve":
+			cves = append(cves, ref.ID)
+		case "url":
+			urls = append(urls, ref.ID)
+		}
+	}
+	if len(cves) == 0 && result.NVT.CVE != "" && result.NVT.CVE != "NOCVE" {
+		for _, cve := range strings.Split(result.NVT.CVE, ",") {
+			cves = append(cves, strings.TrimSpace(cve))
+		}
+	}
+	if len(urls) == 0 && result.NVT.XRef != "" && result.NVT.XRef != "NOXREF" {
+		for _, xref := range strings.Split(result.NVT.XRef, ",") {
+			urls = append(urls, strings.TrimPrefix(strings.TrimSpace(xref), "URL:"))
+		}
+	}
+	if len(cves) > 0 {
+		vuln.CVE = cves[0]
+	}
+	vuln.References = append(cves, urls...)
+
+	name := defaultIfEmpty(result.NVT.Name, result.Name)
+	description := name
+	if summary := openVASTag(result.NVT.Tags, "summary"); summary != "" {
+		description += "\n" + summary
+	}
+	if output := strings.TrimSpace(result.Description); output != "" {
+		description += "\n\nResult:\n" + output
+	}
+	vuln.Description = description
+	return vuln
+}
+
+// openVASCategory maps a GVM threat level and severity score to a finding category.
+func openVASCategory(threat string, severity float64) model.FindingCategory {
+	criticalCVSS, _ := cvssThresholds()
+	switch strings.ToLower(threat) {
+	case "critical":
+		return model.CriticalFinding
+	case "high":
+		if severity >= criticalCVSS {
+			return model.CriticalFinding
+		}
+		return model.PotentialFinding
+	case "medium":
+		return model.PotentialFinding
+	}
+	return model.InformationalFinding
+}
+
+// openVASTag returns a value from the pipe-separated "key=value" NVT tag string.
+func openVASTag(tags, key string) string {
+	for _, tag := range strings.Split(tags, "|") {
+		if k, v, found := strings.Cut(tag, "="); found && k == key {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+// parseOpenVASPort splits "443/tcp" into its number and protocol. "general/tcp" and similar
+// host-level entries return port 0.
+func parseOpenVASPort(port string) (int, string) {
+	number, protocol, _ := strings.Cut(strings.TrimSpace(port), "/")
+	id, err := strconv.Atoi(number)
+	if err != nil {
+		return 0, protocol
+	}
+	return id, protocol
+}
+
+// findOrCreateScannedHost returns the host for a MAC or IP reported by a vulnerability
+// scanner, creating it (keyed by MAC, or by an IP placeholder) if necessary.
+func findOrCreateScannedHost(networkMap *model.NetworkMap, mac, ip, scanner string) *model.Host {
+	mac = strings.ToUpper(strings.TrimSpace(mac))
+	var host *model.Host
+	if mac != "" {
+		host = networkMap.Hosts[mac]
+	}
+	if host == nil && ip != "" {
+		host = findHostByIP(networkMap, ip)
+		if host != nil && mac != "" && strings.HasPrefix(host.MACAddress, "IP:") {
+			// The MAC is now known, so re-key the placeholder host.
+			delete(networkMap.Hosts, host.MACAddress)
+			host.MACAddress = mac
+			networkMap.Hosts[mac] = host
+		}
+	}
+	if host == nil {
+		key := mac
+		if key == "" {
+			key = "IP:" + ip
+		}
+		if key == "IP:" {
+			return nil
+		}
+		host = model.NewHost(key)
+		networkMap.Hosts[key] = host
+	}
+	if ip != "" {
+		host.IPv4Addresses[ip] = true
+	}
+	if host.DiscoveredBy == "" {
+		host.DiscoveredBy = scanner
+	}
+	host.Status = "up"
+	return host
+}
+
+// addScannedPort records an open port reported by a vulnerability scanner, keeping any
+// service details that are already known.
+func addScannedPort(host *model.Host, portID int, protocol, service string) {
+	if existing, ok := host.Ports[portID]; ok && existing.Service != "" {
+		return
+	}
+	host.Ports[portID] = model.Port{ID: portID, Protocol: strings.ToLower(protocol), State: "open", Service: strings.TrimSuffix(service, "?")}
+}
@@ -0,0 +1,162 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"testing"
+)
+
+const testNessusReport = `<?xml version="1.0" ?>
+<NessusClientData_v2>
+<Report name="Internal scan">
+<ReportHost name="192.168.1.10">
+<HostProperties>
+<tag name="host-ip">192.168.1.10</tag>
+<tag name="mac-address">00:0c:29:aa:bb:cc
+00:0c:29:aa:bb:cd</tag>
+<tag name="host-fqdn">dc01.corp.local</tag>
+<tag name="operating-system">Microsoft Windows Server 2016</tag>
+</HostProperties>
+<ReportItem port="445" svc_name="cifs" protocol="tcp" severity="4" pluginID="97833" pluginName="MS17-010: Security Update for Microsoft Windows SMB Server">
+<cve>CVE-2017-0143</cve>
+<cve>CVE-2017-0144</cve>
+<cvss_base_score>9.3</cvss_base_score>
+<cvss3_base_score>8.1</cvss3_base_score>
+<synopsis>The remote Windows host is affected by multiple vulnerabilities.</synopsis>
+<plugin_output>Sent: 00000000 Received: c0000205</plugin_output>
+<see_also>https://technet.microsoft.com/library/security/MS17-010</see_also>
+<exploit_available>true</exploit_available>
+</ReportItem>
+<ReportItem port="3389" svc_name="msrdp" protocol="tcp" severity="2" pluginID="57690" pluginName="Terminal Services Encryption Level is Medium or Low">
+<cvss_base_score>4.3</cvss_base_score>
+</ReportItem>
+<ReportItem port="0" svc_name="general" protocol="tcp" severity="0" pluginID="19506" pluginName="Nessus Scan Information">
+<plugin_output>Nessus version : 10.6.1</plugin_output>
+</ReportItem>
+</ReportHost>
+</Report>
+</NessusClientData_v2>`
+
+const testOpenVASReport = `<report id="a1" format_id="a994b278-1f62-11e1-96ac-406186ea4fc5" extension="xml" content_type="text/xml">
+<report id="a1">
+<results start="1" max="-1">
+<result id="r1">
+<name>OpenSSH Multiple Vulnerabilities</name>
+<host>192.168.1.20<asset asset_id="x"/><hostname>web01.corp.local</hostname></host>
+<port>22/tcp</port>
+<nvt oid="1.3.6.1.4.1.25623.1.0.105000">
+<type>nvt</type>
+<name>OpenSSH Multiple Vulnerabilities</name>
+<cvss_base>7.5</cvss_base>
+<tags>cvss_base_vector=AV:N/AC:L/Au:N/C:P/I:P/A:P|summary=OpenSSH is prone to multiple vulnerabilities.|solution_type=VendorFix</tags>
+<refs><ref type="cve" id="CVE-2016-0777"/><ref type="cve" id="CVE-2016-0778"/><ref type="url" id="https://www.openssh.com/txt/release-7.1p2"/></refs>
+</nvt>
+<threat>High</threat>
+<severity>7.5</severity>
+<description>Installed version: 6.6</description>
+</result>
+<result id="r2">
+<name>ICMP Timestamp Detection</name>
+<host>192.168.1.20</host>
+<port>general/icmp</port>
+<nvt oid="1.3.6.1.4.1.25623.1.0.103190"><name>ICMP Timestamp Detection</name><cvss_base>0.0</cvss_base><cve>CVE-1999-0524</cve></nvt>
+<threat>Log</threat>
+<severity>0.0</severity>
+</result>
+<result id="r3">
+<name>Some noise</name>
+<host>192.168.1.20</host>
+<port>80/tcp</port>
+<nvt oid="1.3.6.1.4.1.25623.1.0.1"><name>Some noise</name></nvt>
+<threat>False Positive</threat>
+</result>
+</results>
+<host><ip>192.168.1.20</ip><detail><name>MAC</name><value>00:50:56:11:22:33</value></detail><detail><name>best_os_txt</name><value>Ubuntu 20.04</value></detail></host>
+</report>
+</report>`
+
+// TestMergeFromNessusXML verifies that plugin results become findings with their CVE list and severity.
+func TestMergeFromNessusXML(t *testing.T) {
+	if got := detectContentType([]byte(testNessusReport)); got != FileTypeNessus {
+		t.Fatalf("Expected Nessus detection, got %q", got)
+	}
+
+	networkMap := model.NewNetworkMap()
+	if err := MergeFromNessusXML([]byte(testNessusReport), networkMap); err != nil {
+		t.Fatalf("MergeFromNessusXML failed: %v", err)
+	}
+
+	host, ok := networkMap.Hosts["00:0C:29:AA:BB:CC"]
+	if !ok {
+		t.Fatalf("Expected host keyed by first MAC, got %v", networkMap.Hosts)
+	}
+	if !host.IPv4Addresses["192.168.1.10"] || host.Hostnames["dc01.corp.local"] == "" || host.Fingerprint.OperatingSystem != "Microsoft Windows Server 2016" {
+		t.Errorf("Host properties not merged: %+v", host)
+	}
+	if host.Ports[445].Service != "cifs" {
+		t.Errorf("Expected port 445/cifs, got %+v", host.Ports[445])
+	}
+
+	// MS17-010 lists two CVEs, and each gets its own finding.
+	critical := host.Findings[model.CriticalFinding]
+	if len(critical) != 2 {
+		t.Fatalf("Expected 2 critical findings, got %d", len(critical))
+	}
+	for i, cve := range []string{"CVE-2017-0143", "CVE-2017-0144"} {
+		ms17 := critical[i]
+		if ms17.CVE != cve || ms17.CVSS != 8.1 || ms17.PortID != 445 || ms17.State != stateVulnerable || ms17.Source != "Nessus plugin 97833" {
+			t.Errorf("Unexpected MS17-010 finding: %+v", ms17)
+		}
+		if len(ms17.References) != 1 {
+			t.Errorf("Expected the URL as reference, got %v", ms17.References)
+		}
+	}
+	if len(host.Findings[model.PotentialFinding]) != 1 || host.Findings[model.PotentialFinding][0].CVE != "NESSUS-57690" {
+		t.Errorf("Expected plugin ID for CVE-less finding, got %+v", host.Findings[model.PotentialFinding])
+	}
+	if len(host.Findings[model.InformationalFinding]) != 1 {
+		t.Errorf("Expected 1 informational finding, got %d", len(host.Findings[model.InformationalFinding]))
+	}
+	if nessusCategory(3, 9.8) != model.CriticalFinding || nessusCategory(3, 7.5) != model.PotentialFinding {
+		t.Error("Expected high severity results to be critical only above the CVSS threshold")
+	}
+}
+
+// TestMergeFromOpenVASXML verifies that NVT results and host details are merged, and that false positives are dropped.
+func TestMergeFromOpenVASXML(t *testing.T) {
+	if got := detectContentType([]byte(testOpenVASReport)); got != FileTypeOpenVAS {
+		t.Fatalf("Expected OpenVAS detection, got %q", got)
+	}
+
+	networkMap := model.NewNetworkMap()
+	if err := MergeFromOpenVASXML([]byte(testOpenVASReport), networkMap); err != nil {
+		t.Fatalf("MergeFromOpenVASXML failed: %v", err)
+	}
+	if len(networkMap.Hosts) != 1 {
+		t.Fatalf("Expected 1 host, got %d", len(networkMap.Hosts))
+	}
+
+	host, ok := networkMap.Hosts["00:50:56:11:22:33"]
+	if !ok {
+		t.Fatalf("Expected host keyed by MAC from host details, got %v", networkMap.Hosts)
+	}
+	if host.Fingerprint.OperatingSystem != "Ubuntu 20.04" || host.Hostnames["web01.corp.local"] == "" {
+		t.Errorf("Host details not merged: %+v", host)
+	}
+
+	potential := host.Findings[model.PotentialFinding]
+	if len(potential) != 1 {
+		t.Fatalf("Expected 1 potential finding, got %d", len(potential))
+	}
+	ssh := potential[0]
+	if ssh.CVE != "CVE-2016-0777" || ssh.CVSS != 7.5 || ssh.PortID != 22 || len(ssh.References) != 3 {
+		t.Errorf("Unexpected OpenSSH finding: %+v", ssh)
+	}
+
+	info := host.Findings[model.InformationalFinding]
+	if len(info) != 1 || info[0].CVE != "CVE-1999-0524" || info[0].PortID != 0 {
+		t.Errorf("Unexpected informational findings: %+v", info)
+	}
+	if _, ok := host.Ports[80]; ok {
+		t.Error("False positive result should not add port 80")
+	}
+}
@@ -0,0 +1,429 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"encoding/binary"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// 802.11 information element IDs and OUIs used when parsing beacons and probe responses.
+const (
+	ieSSID           = 0
+	ieDSParameterSet = 3
+	ieRSN            = 48
+	ieHTOperation    = 61
+	ieVendor         = 221
+
+	capabilityPrivacy = 0x0010
+)
+
+var (
+	ouiIEEE      = []byte{0x00, 0x0f, 0xac} // RSN cipher and AKM suites
+	ouiMicrosoft = []byte{0x00, 0x50, 0xf2} // WPA (type 1) and WPS (type 4) vendor elements
+)
+
+// akmNames maps RSN AKM suite types (IEEE OUI) to readable names.
+var akmNames = map[byte]string{
+	1: "802.1X", 2: "PSK", 3: "FT-802.1X", 4: "FT-PSK", 5: "802.1X-SHA256", 6: "PSK-SHA256",
+	8: "SAE", 9: "FT-SAE", 11: "802.1X-SuiteB", 12: "802.1X-SuiteB-192", 13: "FT-802.1X-SHA384",
+	18: "OWE", 24: "SAE-EXT-KEY", 25: "FT-SAE-EXT-KEY",
+}
+
+// cipherNames maps RSN/WPA cipher suite types to readable names.
+var cipherNames = map[byte]string{
+	1: "WEP-40", 2: "TKIP", 4: "CCMP", 5: "WEP-104", 6: "BIP-CMAC-128", 8: "GCMP", 9: "GCMP-256", 10: "CCMP-256",
+}
+
+// bssElements holds what a beacon or probe response advertises about its network.
+type bssElements struct {
+	ssid    string // Empty for hidden networks, which send an empty or zeroed SSID
+	channel int
+	rsn     *securitySuites
+	wpa     *securitySuites
+	wps     bool
+}
+
+// securitySuites are the pairwise ciphers and key management suites of an RSN or WPA element.
+type securitySuites struct {
+	ciphers []string
+	akms    []string
+}
+
+// processWirelessFrame updates the access point and client inventory from an 802.11 frame and
+// records deauthentication and disassociation frames.
+func processWirelessFrame(packet gopacket.Packet, dot11 *layers.Dot11, summary *model.PcapSummary, pcapFile string) {
+	ts := packet.Metadata().Timestamp
+	rssi, frequency := radioInfo(packet)
+
+	switch {
+	case dot11.Type == layers.Dot11TypeMgmtBeacon || dot11.Type == layers.Dot11TypeMgmtProbeResp:
+		var capability uint16
+		var elements []byte
+		if beacon, ok := packet.Layer(layers.LayerTypeDot11MgmtBeacon).(*layers.Dot11MgmtBeacon); ok {
+			capability, elements = beacon.Flags, beacon.LayerPayload()
+		} else if resp, ok := packet.Layer(layers.LayerTypeDot11MgmtProbeResp).(*layers.Dot11MgmtProbeResp); ok {
+			capability, elements = resp.Flags, resp.LayerPayload()
+		} else {
+			return
+		}
+		ap := accessPoint(summary, dot11.Address3, ts)
+		if ap == nil {
+			return
+		}
+		bss := parseBSSElements(elements)
+		if bss.ssid != "" {
+			ap.SSID = bss.ssid
+			if ap.SSIDs == nil {
+				ap.SSIDs = make(map[string]bool)
+			}
+			ap.SSIDs[bss.ssid] = true
+		}
+		if bss.channel != 0 {
+			ap.Channel = bss.channel
+		} else if ap.Channel == 0 && frequency != 0 {
+			ap.Channel = channelForFrequency(frequency)
+		}
+		if frequency != 0 {
+			ap.Frequency = frequency
+		}
+		ap.Encryption, ap.AKM, ap.Ciphers = classifySecurity(capability, bss)
+		ap.WPS = ap.WPS || bss.wps
+		if dot11.Type == layers.Dot11TypeMgmtBeacon {
+			ap.Beacons++
+		}
+		ap.RSSI = strongestSignal(ap.RSSI, rssi)
+
+	case dot11.Type == layers.Dot11TypeMgmtProbeReq:
+		client := wirelessClient(summary, dot11.Address2, ts, rssi, true)
+		if client == nil {
+			return
+		}
+		// gopacket leaves a probe request's elements in its contents rather than its payload.
+		if req, ok := packet.Layer(layers.LayerTypeDot11MgmtProbeReq).(*layers.Dot11MgmtProbeReq); ok {
+			if ssid := parseBSSElements(req.LayerContents()).ssid; ssid != "" {
+				client.ProbedSSIDs[ssid] = true
+			}
+		}
+
+	case dot11.Type == layers.Dot11TypeMgmtAssociationReq || dot11.Type == layers.Dot11TypeMgmtReassociationReq:
+		if client := wirelessClient(summary, dot11.Address2, ts, rssi, true); client != nil {
+			client.AssociatedBSSID = strings.ToUpper(dot11.Address1.String())
+			if ts.After(client.LastAssociation) {
+				client.LastAssociation = ts
+			}
+		}
+
+	case dot11.Type == layers.Dot11TypeMgmtDeauthentication || dot11.Type == layers.Dot11TypeMgmtDisassociation:
+		event := model.DeauthEvent{
+			BSSID:       strings.ToUpper(dot11.Address3.String()),
+			Source:      strings.ToUpper(dot11.Address2.String()),
+			Destination: strings.ToUpper(dot11.Address1.String()),
+			Timestamp:   ts,
+			PcapFile:    pcapFile,
+		}
+		if deauth, ok := packet.Layer(layers.LayerTypeDot11MgmtDeauthentication).(*layers.Dot11MgmtDeauthentication); ok {
+			event.Subtype, event.Reason = "Deauthentication", int(deauth.Reason)
+		} else if disassoc, ok := packet.Layer(layers.LayerTypeDot11MgmtDisassociation).(*layers.Dot11MgmtDisassociation); ok {
+			event.Subtype, event.Reason = "Disassociation", int(disassoc.Reason)
+		} else {
+			return
+		}
+		summary.DeauthEvents = append(summary.DeauthEvents, event)
+
+	case dot11.Type.MainType() == layers.Dot11TypeData:
+		toDS, fromDS := dot11.Flags.ToDS(), dot11.Flags.FromDS()
+		switch {
+		case toDS && !fromDS:
+			if client := wirelessClient(summary, dot11.Address2, ts, rssi, true); client != nil {
+				client.AssociatedBSSID = strings.ToUpper(dot11.Address1.String())
+			}
+		case fromDS && !toDS:
+			if client := wirelessClient(summary, dot11.Address1, ts, 0, false); client != nil {
+				client.AssociatedBSSID = strings.ToUpper(dot11.Address2.String())
+			}
+		}
+	}
+}
+
+// accessPoint returns the inventory entry for a BSSID, creating it on first sight.
+func accessPoint(summary *model.PcapSummary, bssid net.HardwareAddr, ts time.Time) *model.WirelessAP {
+	if len(bssid) != 6 || isMulticastMAC(bssid) {
+		return nil
+	}
+	key := strings.ToUpper(bssid.String())
+	ap, ok := summary.AccessPoints[key]
+	if !ok {
+		ap = &model.WirelessAP{BSSID: key}
+		summary.AccessPoints[key] = ap
+		// A station entry created before the first beacon was seen is dropped.
+		delete(summary.WirelessClients, key)
+	}
+	seen(&ap.FirstSeen, &ap.LastSeen, ts)
+	return ap
+}
+
+// wirelessClient returns the inventory entry for a station, creating it on first sight. It
+// returns nil for group addresses and for addresses known to be access points. Frame counts and
+// signal strength only include frames the station sent itself.
+func wirelessClient(summary *model.PcapSummary, mac net.HardwareAddr, ts time.Time, rssi int, sent bool) *model.WirelessClient {
+	if len(mac) != 6 || isMulticastMAC(mac) {
+		return nil
+	}
+	key := strings.ToUpper(mac.String())
+	if _, isAP := summary.AccessPoints[key]; isAP {
+		return nil
+	}
+	client, ok := summary.WirelessClients[key]
+	if !ok {
+		client = &model.WirelessClient{MAC: key, ProbedSSIDs: make(map[string]bool)}
+		summary.WirelessClients[key] = client
+	}
+	if sent {
+		client.Frames++
+		client.RSSI = strongestSignal(client.RSSI, rssi)
+	}
+	seen(&client.FirstSeen, &client.LastSeen, ts)
+	return client
+}
+
+// seen widens a first/last seen window to include ts.
+func seen(first, last *time.Time, ts time.Time) {
+	if ts.IsZero() {
+		return
+	}
+	if first.IsZero() || ts.Before(*first) {
+		*first = ts
+	}
+	if ts.After(*last) {
+		*last = ts
+	}
+}
+
+// strongestSignal keeps the strongest of two dBm readings, treating 0 as unknown.
+func strongestSignal(current, reading int) int {
+	if reading == 0 || (current != 0 && current >= reading) {
+		return current
+	}
+	return reading
+}
+
+func isMulticastMAC(mac net.HardwareAddr) bool {
+	return len(mac) > 0 && mac[0]&0x01 != 0
+}
+
+// radioInfo returns the antenna signal (dBm) and channel frequency (MHz) from a radiotap
+// header, or zeros when the capture has none.
+func radioInfo(packet gopacket.Packet) (rssi, frequency int) {
+	radio, ok := packet.Layer(layers.LayerTypeRadioTap).(*layers.RadioTap)
+	if !ok {
+		return 0, 0
+	}
+	if radio.Present.DBMAntennaSignal() {
+		rssi = int(radio.DBMAntennaSignal)
+	}
+	if radio.Present.Channel() {
+		frequency = int(radio.ChannelFrequency)
+	}
+	return rssi, frequency
+}
+
+// channelForFrequency converts a 2.4, 5 or 6 GHz centre frequency in MHz to its channel number.
+func channelForFrequency(mhz int) int {
+	switch {
+	case mhz == 2484:
+		return 14
+	case mhz >= 2412 && mhz < 2484:
+		return (mhz - 2407) / 5
+	case mhz >= 5955 && mhz <= 7115:
+		return (mhz - 5950) / 5
+	case mhz >= 5000 && mhz < 5955:
+		return (mhz - 5000) / 5
+	}
+	return 0
+}
+
+// parseBSSElements walks the information elements of a beacon, probe request or probe response.
+func parseBSSElements(payload []byte) bssElements {
+	var bss bssElements
+	for len(payload) >= 2 {
+		id, length := payload[0], int(payload[1])
+		if len(payload) < 2+length {
+			break
+		}
+		body := payload[2 : 2+length]
+		switch id {
+		case ieSSID:
+			if strings.Trim(string(body), "\x00") != "" {
+				bss.ssid = string(body)
+			}
+		case ieDSParameterSet:
+			if length >= 1 {
+				bss.channel = int(body[0])
+			}
+		case ieHTOperation:
+			if length >= 1 && bss.channel == 0 {
+				bss.channel = int(body[0])
+			}
+		case ieRSN:
+			if length >= 2 {
+				bss.rsn = parseSecuritySuites(body[2:], ouiIEEE)
+			}
+		case ieVendor:
+			if length >= 4 && string(body[:3]) == string(ouiMicrosoft) {
+				switch body[3] {
+				case 1:
+					if length >= 6 {
+						bss.wpa = parseSecuritySuites(body[6:], ouiMicrosoft)
+					}
+				case 4:
+					bss.wps = true
+				}
+			}
+		}
+		payload = payload[2+length:]
+	}
+	return bss
+}
+
+// parseSecuritySuites reads the group cipher, pairwise cipher list and AKM list that follow the
+// version field of an RSN or WPA element. Truncated elements yield what could be read.
+func parseSecuritySuites(data []byte, oui []byte) *securitySuites {
+	suites := &securitySuites{}
+	if len(data) < 4 {
+		return suites
+	}
+	data = data[4:] // Group cipher
+
+	readList := func(names map[byte]string) []string {
+		if len(data) < 2 {
+			data = nil
+			return nil
+		}
+		count := int(binary.LittleEndian.Uint16(data[:2]))
+		data = data[2:]
+		var list []string
+		for i := 0; i < count && len(data) >= 4; i++ {
+			suite := data[:4]
+			data = data[4:]
+			if string(suite[:3]) != string(oui) {
+				list = append(list, "Vendor")
+				continue
+			}
+			if name, ok := names[suite[3]]; ok {
+				list = append(list, name)
+			}
+		}
+		return list
+	}
+	suites.ciphers = readList(cipherNames)
+	suites.akms = readList(akmNames)
+	return suites
+}
+
+// classifySecurity summarises an access point's security as an encryption label plus the AKM
+// suites and pairwise ciphers it offers.
+func classifySecurity(capability uint16, bss bssElements) (encryption, akm, ciphers string) {
+	akms := make(map[string]bool)
+	cipherSet := make(map[string]bool)
+	for _, s := range []*securitySuites{bss.rsn, bss.wpa} {
+		if s == nil {
+			continue
+		}
+		for _, a := range s.akms {
+			akms[a] = true
+		}
+		for _, c := range s.ciphers {
+			cipherSet[c] = true
+		}
+	}
+	akm, ciphers = joinSorted(akms), joinSorted(cipherSet)
+
+	enterprise := false
+	for a := range akms {
+		if strings.Contains(a, "802.1X") {
+			enterprise = true
+		}
+	}
+	sae := akms["SAE"] || akms["FT-SAE"] || akms["SAE-EXT-KEY"] || akms["FT-SAE-EXT-KEY"]
+	psk := akms["PSK"] || akms["FT-PSK"] || akms["PSK-SHA256"]
+
+	switch {
+	case akms["OWE"]:
+		encryption = "OWE"
+	case bss.rsn != nil && (akms["802.1X-SuiteB"] || akms["802.1X-SuiteB-192"]):
+		encryption = "WPA3-Enterprise"
+	case bss.rsn != nil && sae && psk:
+		encryption = "WPA2/WPA3"
+	case bss.rsn != nil && sae:
+		encryption = "WPA3"
+	case bss.rsn != nil && bss.wpa != nil:
+		encryption = "WPA/WPA2"
+	case bss.rsn != nil:
+		encryption = "WPA2"
+	case bss.wpa != nil:
+		encryption = "WPA"
+	case capability&capabilityPrivacy != 0:
+		return "WEP", "", ""
+	default:
+		return "Open", "", ""
+	}
+	if enterprise && !strings.HasSuffix(encryption, "-Enterprise") {
+		encryption += "-Enterprise"
+	}
+	return encryption, akm, ciphers
+}
+
+func joinSorted(set map[string]bool) string {
+	list := make([]string, 0, len(set))
+	for item := range set {
+		list = append(list, item)
+	}
+	sort.Strings(list)
+	return strings.Join(list, ", ")
+}
+
+// linkWirelessHosts copies the wireless inventory onto the hosts that share its MAC addresses,
+// so host pages and handshakes know a device's role, network and probes.
+func linkWirelessHosts(networkMap *model.NetworkMap, summary *model.PcapSummary) {
+	for mac, ap := range summary.AccessPoints {
+		host, ok := networkMap.Hosts[mac]
+		if !ok {
+			continue
+		}
+		if host.Wifi == nil {
+			host.Wifi = &model.WifiInfo{ProbeRequests: make(map[string]bool)}
+		}
+		host.Wifi.DeviceRole = "Access Point"
+		if ap.SSID != "" {
+			host.Wifi.SSID = ap.SSID
+		}
+	}
+	for mac, client := range summary.WirelessClients {
+		host, ok := networkMap.Hosts[mac]
+		if !ok {
+			continue
+		}
+		if host.Wifi == nil {
+			host.Wifi = &model.WifiInfo{ProbeRequests: make(map[string]bool)}
+		}
+		if host.Wifi.ProbeRequests == nil {
+			host.Wifi.ProbeRequests = make(map[string]bool)
+		}
+		host.Wifi.DeviceRole = "Client"
+		if client.AssociatedBSSID != "" {
+			host.Wifi.AssociatedAP = client.AssociatedBSSID
+		}
+		if ap, ok := summary.AccessPoints[client.AssociatedBSSID]; ok && ap.SSID != "" {
+			host.Wifi.SSID = ap.SSID
+		}
+		for ssid := range client.ProbedSSIDs {
+			host.Wifi.ProbeRequests[ssid] = true
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+var (
+	wifiTestBSSID  = []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+	wifiTestClient = []byte{0x02, 0xaa, 0xbb, 0xcc, 0xdd, 0x01}
+	wifiBroadcast  = []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+)
+
+// radiotapFrame wraps an 802.11 frame in a radiotap header carrying a channel and antenna signal.
+func radiotapFrame(frame []byte, mhz uint16, dbm int8) []byte {
+	h := make([]byte, 13)
+	binary.LittleEndian.PutUint16(h[2:4], uint16(len(h)))
+	binary.LittleEndian.PutUint32(h[4:8], 1<<3|1<<5) // Channel, dBm antenna signal
+	binary.LittleEndian.PutUint16(h[8:10], mhz)
+	h[12] = byte(dbm)
+	return append(h, frame...)
+}
+
+// dot11Header builds an 802.11 MAC header with the given frame control bytes and addresses.
+func dot11Header(fc0, fc1 byte, addr1, addr2, addr3 []byte) []byte {
+	h := []byte{fc0, fc1, 0, 0}
+	h = append(h, addr1...)
+	h = append(h, addr2...)
+	h = append(h, addr3...)
+	return append(h, 0, 0)
+}
+
+func element(id byte, body ...byte) []byte {
+	return append([]byte{id, byte(len(body))}, body...)
+}
+
+// rsnPSKSAE is an RSN element offering CCMP with both PSK and SAE key management.
+var rsnPSKSAE = []byte{
+	1, 0, // Version
+	0x00, 0x0f, 0xac, 4, // Group cipher CCMP
+	1, 0, 0x00, 0x0f, 0xac, 4, // Pairwise: CCMP
+	2, 0, 0x00, 0x0f, 0xac, 2, 0x00, 0x0f, 0xac, 8, // AKM: PSK, SAE
+	0, 0, // Capabilities
+}
+
+func wifiTestPacket(t *testing.T, data []byte, ts time.Time) gopacket.Packet {
+	t.Helper()
+	packet := gopacket.NewPacket(data, layers.LayerTypeRadioTap, gopacket.Default)
+	if packet.Layer(layers.LayerTypeDot11) == nil {
+		t.Fatalf("Test frame did not decode as 802.11: %v", packet.ErrorLayer())
+	}
+	packet.Metadata().Timestamp = ts
+	return packet
+}
+
+// TestWirelessInventory verifies that beacons, probe requests and data frames build the access
+// point and client inventory, and that it is copied onto the matching hosts.
+func TestWirelessInventory(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	var beacon []byte
+	beacon = append(beacon, dot11Header(0x80, 0x00, wifiBroadcast, wifiTestBSSID, wifiTestBSSID)...)
+	beacon = append(beacon, make([]byte, 10)...) // Timestamp and interval
+	beacon = append(beacon, 0x11, 0x00)          // Capabilities: ESS, privacy
+	beacon = append(beacon, element(ieSSID, []byte("CorpNet")...)...)
+	beacon = append(beacon, element(ieDSParameterSet, 6)...)
+	beacon = append(beacon, element(ieRSN, rsnPSKSAE...)...)
+	beacon = append(beacon, element(ieVendor, 0x00, 0x50, 0xf2, 4, 0x10)...)
+
+	var probe []byte
+	probe = append(probe, dot11Header(0x40, 0x00, wifiBroadcast, wifiTestClient, wifiBroadcast)...)
+	probe = append(probe, element(ieSSID, []byte("HomeWiFi")...)...)
+
+	data := dot11Header(0x08, 0x01, wifiTestBSSID, wifiTestClient, wifiBroadcast)
+	data = append(data, 0xaa, 0xaa, 0x03, 0, 0, 0, 0x88, 0x8e)
+
+	networkMap, summary := model.NewNetworkMap(), model.NewPcapSummary()
+	for i, frame := range [][]byte{
+		radiotapFrame(beacon, 2437, -40),
+		radiotapFrame(beacon, 2437, -55),
+		radiotapFrame(probe, 2437, -70),
+		radiotapFrame(data, 2437, -62),
+	} {
+		ProcessPacket(wifiTestPacket(t, frame, start.Add(time.Duration(i)*time.Second)), networkMap, summary, "wifi.pcap")
+	}
+
+	ap := summary.AccessPoints["00:11:22:33:44:55"]
+	if ap == nil {
+		t.Fatalf("Expected access point 00:11:22:33:44:55, got %v", summary.AccessPoints)
+	}
+	if ap.SSID != "CorpNet" || ap.Channel != 6 || ap.Frequency != 2437 {
+		t.Errorf("Unexpected SSID/channel/frequency: %q/%d/%d", ap.SSID, ap.Channel, ap.Frequency)
+	}
+	if ap.Encryption != "WPA2/WPA3" || ap.AKM != "PSK, SAE" || ap.Ciphers != "CCMP" || !ap.WPS {
+		t.Errorf("Unexpected security: %q (%q / %q), WPS %v", ap.Encryption, ap.AKM, ap.Ciphers, ap.WPS)
+	}
+	if ap.RSSI != -40 || ap.Beacons != 2 || !ap.FirstSeen.Equal(start) || !ap.LastSeen.Equal(start.Add(time.Second)) {
+		t.Errorf("Unexpected RSSI/beacons/seen: %d/%d/%v-%v", ap.RSSI, ap.Beacons, ap.FirstSeen, ap.LastSeen)
+	}
+
+	client := summary.WirelessClients["02:AA:BB:CC:DD:01"]
+	if client == nil {
+		t.Fatalf("Expected client 02:AA:BB:CC:DD:01, got %v", summary.WirelessClients)
+	}
+	if !client.ProbedSSIDs["HomeWiFi"] || client.AssociatedBSSID != "00:11:22:33:44:55" || client.Frames != 2 || client.RSSI != -62 {
+		t.Errorf("Unexpected client: %+v", client)
+	}
+	if _, ok := summary.WirelessClients["FF:FF:FF:FF:FF:FF"]; ok {
+		t.Error("Broadcast address recorded as a client")
+	}
+
+	networkMap.Hosts["00:11:22:33:44:55"] = model.NewHost("00:11:22:33:44:55")
+	networkMap.Hosts["02:AA:BB:CC:DD:01"] = model.NewHost("02:AA:BB:CC:DD:01")
+	ProcessHandshakes(networkMap, summary)
+	if wifi := networkMap.Hosts["00:11:22:33:44:55"].Wifi; wifi == nil || wifi.DeviceRole != "Access Point" || wifi.SSID != "CorpNet" {
+		t.Errorf("Expected the AP host to carry its SSID, got %+v", wifi)
+	}
+	if wifi := networkMap.Hosts["02:AA:BB:CC:DD:01"].Wifi; wifi == nil || wifi.AssociatedAP != "00:11:22:33:44:55" || wifi.SSID != "CorpNet" || !wifi.ProbeRequests["HomeWiFi"] {
+		t.Errorf("Expected the client host to carry its association and probes, got %+v", wifi)
+	}
+}
+
+// TestClassifySecurity verifies the encryption labels derived from capability bits and RSN/WPA elements.
+func TestClassifySecurity(t *testing.T) {
+	suites := func(akms ...string) *securitySuites {
+		return &securitySuites{ciphers: []string{"CCMP"}, akms: akms}
+	}
+	testCases := []struct {
+		name       string
+		capability uint16
+		bss        bssElements
+		want       string
+	}{
+		{"open", 0, bssElements{}, "Open"},
+		{"WEP", capabilityPrivacy, bssElements{}, "WEP"},
+		{"WPA", capabilityPrivacy, bssElements{wpa: suites("PSK")}, "WPA"},
+		{"WPA/WPA2", capabilityPrivacy, bssElements{rsn: suites("PSK"), wpa: suites("PSK")}, "WPA/WPA2"},
+		{"WPA2", capabilityPrivacy, bssElements{rsn: suites("PSK")}, "WPA2"},
+		{"WPA2-Enterprise", capabilityPrivacy, bssElements{rsn: suites("802.1X")}, "WPA2-Enterprise"},
+		{"WPA3", capabilityPrivacy, bssElements{rsn: suites("SAE")}, "WPA3"},
+		{"WPA3-Enterprise", capabilityPrivacy, bssElements{rsn: suites("802.1X-SuiteB-192")}, "WPA3-Enterprise"},
+		{"OWE", capabilityPrivacy, bssElements{rsn: suites("OWE")}, "OWE"},
+	}
+	for _, tc := range testCases {
+		if got, _, _ := classifySecurity(tc.capability, tc.bss); got != tc.want {
+			t.Errorf("%s: expected %q, got %q", tc.name, tc.want, got)
+		}
+	}
+}
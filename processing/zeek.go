@@ -0,0 +1,184 @@
+package processing
+
+import (
+	"SnailsHell/model"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// zeekRecord is a single Zeek log entry with every field rendered as a string. Unset and
+// empty fields are stored as "", and set/vector fields are joined with commas.
+type zeekRecord map[string]string
+
+// processZeekFile reads a Zeek log in either the default TSV format or JSON (LogAscii::use_json).
+func processZeekFile(filename string, fileType FileType, networkMap *model.NetworkMap, summary *model.PcapSummary) error {
+	f, err := OpenDataFile(filename)
+	if err != nil {
+		return fmt.Errorf("could not open zeek log %s: %w", filename, err)
+	}
+	defer f.Close()
+
+	lines := bufio.NewScanner(f)
+	lines.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	logFile := filepath.Base(filename)
+
+	if fileType == FileTypeZeekJSON {
+		for lines.Scan() {
+			line := strings.TrimSpace(lines.Text())
+			if line == "" {
+				continue
+			}
+			var raw map[string]interface{}
+			if err := json.Unmarshal([]byte(line), &raw); err != nil {
+				continue
+			}
+			record := make(zeekRecord, len(raw))
+			for key, value := range raw {
+				record[key] = zeekJSONValue(value)
+			}
+			path := record["_path"]
+			if path == "" {
+				path = inferZeekPath(record)
+			}
+			applyZeekRecord(path, record, networkMap, summary, logFile)
+		}
+		return lines.Err()
+	}
+
+	separator, setSeparator := "\t", ","
+	emptyField, unsetField := "(empty)", "-"
+	var path string
+	var fields []string
+	for lines.Scan() {
+		line := lines.Text()
+		if strings.HasPrefix(line, "#") {
+			directive, value, _ := strings.Cut(line[1:], separator)
+			switch {
+			case strings.HasPrefix(line, "#separator "):
+				separator = unescapeZeekSeparator(strings.TrimPrefix(line, "#separator "))
+			case directive == "set_separator":
+				setSeparator = value
+			case directive == "empty_field":
+				emptyField = value
+			case directive == "unset_field":
+				unsetField = value
+			case directive == "path":
+				path = value
+			case directive == "fields":
+				fields = strings.Split(value, separator)
+			}
+			continue
+		}
+		if len(fields) == 0 || line == "" {
+			continue
+		}
+		values := strings.Split(line, separator)
+		record := make(zeekRecord, len(fields))
+		for i, field := range fields {
+			if i >= len(values) || values[i] == emptyField || values[i] == unsetField {
+				record[field] = ""
+				continue
+			}
+			record[field] = strings.ReplaceAll(values[i], setSeparator, ",")
+		}
+		applyZeekRecord(path, record, networkMap, summary, logFile)
+	}
+	return lines.Err()
+}
+
+// applyZeekRecord merges a single log entry into the model according to its log type.
+func applyZeekRecord(path string, r zeekRecord, networkMap *model.NetworkMap, summary *model.PcapSummary, logFile string) {
+	const source = "Zeek"
+	origIP, respIP := r["id.orig_h"], r["id.resp_h"]
+
+	switch path {
+	case "conn":
+		packets := r.int("orig_pkts") + r.int("resp_pkts")
+		addLogFlow(networkMap, origIP, respIP, packets, source)
+		if r["proto"] == "tcp" && zeekEstablishedStates[r["conn_state"]] {
+			addLogOpenPort(networkMap, respIP, r.int("id.resp_p"), r["proto"], r["service"], source)
+		}
+	case "dns":
+		var answers []string
+		if r["answers"] != "" {
+			answers = strings.Split(r["answers"], ",")
+		}
+		addLogDNSQuery(networkMap, origIP, respIP, r["query"], answers, source)
+	case "http":
+		addLogHostname(networkMap, respIP, r["host"], "HTTP", source)
+		if r["username"] != "" {
+			value := r["username"]
+			if r["password"] != "" {
+				value += ":" + r["password"]
+			}
+			addLogCredential(networkMap, summary, origIP, respIP, "HTTP Basic Auth", value, logFile, source)
+		}
+		if host := logHost(networkMap, origIP, source); host != nil && r["uri"] != "" {
+			checkForSecrets([]byte(r["uri"]), host.MACAddress, respIP, summary, logFile)
+		}
+	case "ssl":
+		addLogHostname(networkMap, respIP, r["server_name"], "TLS SNI", source)
+	case "dhcp":
+		ip := r["assigned_addr"]
+		if ip == "" {
+			ip = defaultIfEmpty(r["assigned_ip"], r["client_addr"])
+		}
+		addLogDHCPLease(networkMap, r["mac"], ip, r["host_name"], source)
+	}
+}
+
+// inferZeekPath identifies the log type of a JSON record that lacks a "_path" field.
+func inferZeekPath(r zeekRecord) string {
+	has := func(key string) bool { _, ok := r[key]; return ok }
+	switch {
+	case has("query") && has("qtype_name"):
+		return "dns"
+	case has("method") || has("uri"):
+		return "http"
+	case has("server_name") || has("cipher"):
+		return "ssl"
+	case has("msg_types") || has("assigned_addr") || has("assigned_ip"):
+		return "dhcp"
+	case has("conn_state") || has("orig_pkts"):
+		return "conn"
+	}
+	return ""
+}
+
+// int returns the integer value of a field, or 0 if it is unset or not a number.
+func (r zeekRecord) int(key string) int {
+	value, _ := strconv.Atoi(r[key])
+	return value
+}
+
+func zeekJSONValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case []interface{}:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			parts = append(parts, zeekJSONValue(item))
+		}
+		return strings.Join(parts, ",")
+	}
+	return ""
+}
+
+// unescapeZeekSeparator decodes the "\x09" notation used by the #separator header.
+func unescapeZeekSeparator(value string) string {
+	if strings.HasPrefix(value, `\x`) && len(value) == 4 {
+		if b, err := strconv.ParseUint(value[2:], 16, 8); err == nil {
+			return string(rune(b))
+		}
+	}
+	return value
+}
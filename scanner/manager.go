@@ -8,7 +8,10 @@ import (
 	"SnailsHell/storage"
 	"SnailsHell/webenum"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
@@ -55,7 +58,7 @@ func (sm *ScanManager) StartNmapScanTask(target, campaignName string) (int64, er
 	}
 
 	go func() {
-		networkMap, err := livecapture.RunNmapScan(ctx, target, campaignName)
+		networkMap, err := livecapture.RunNmapScan(ctx, target)
 
 		sm.mu.Lock()
 		if err != nil {
@@ -72,6 +75,7 @@ func (sm *ScanManager) StartNmapScanTask(target, campaignName string) (int64, er
 			}
 
 			sm.Status = "Scanning: Saving results..."
+			analyzeBeforeSave(networkMap)
 			if err := storage.SaveScanResults(campaignID, networkMap, &model.PcapSummary{}); err != nil {
 				log.Printf("Error saving results for '%s': %v", campaignName, err)
 				sm.Status = fmt.Sprintf("Failed: Could not save results for '%s'.", campaignName)
@@ -128,6 +132,8 @@ func (sm *ScanManager) StartLiveScanTask(campaignName, interfaceName string) (in
 			sm.Status = "Scanning: Finalizing data..."
 			processing.ProcessHandshakes(masterMap, globalSummary)
 			processing.EnrichWithLookups(masterMap, globalSummary)
+			processing.AnalyzeWireless(masterMap, globalSummary)
+			processing.AnalyzeDNS(masterMap, globalSummary)
 
 			sm.Status = "Scanning: Running post-exploitation checks..."
 			for _, host := range masterMap.Hosts {
@@ -137,6 +143,7 @@ func (sm *ScanManager) StartLiveScanTask(campaignName, interfaceName string) (in
 			}
 
 			sm.Status = "Scanning: Saving results..."
+			analyzeBeforeSave(masterMap)
 			if err := storage.SaveScanResults(campaignID, masterMap, globalSummary); err != nil {
 				log.Printf("Error saving results for '%s': %v", campaignName, err)
 				sm.Status = fmt.Sprintf("Failed: Could not save results for '%s'.", campaignName)
@@ -160,7 +167,7 @@ func (sm *ScanManager) StartLiveScanTask(campaignName, interfaceName string) (in
 	return campaignID, nil
 }
 
-func (sm *ScanManager) StartFileScanTask(campaignName, dataDir string) (int64, error) {
+func (sm *ScanManager) StartFileScanTask(campaignName, dataDir string, force bool) (int64, error) {
 	sm.mu.Lock()
 	if sm.IsScanning {
 		sm.mu.Unlock()
@@ -178,7 +185,7 @@ func (sm *ScanManager) StartFileScanTask(campaignName, dataDir string) (int64, e
 	}
 
 	go func() {
-		err := RunFileScan(campaignName, dataDir, campaignID)
+		err := RunFileScan(campaignName, dataDir, campaignID, force)
 
 		sm.mu.Lock()
 		if err != nil {
@@ -245,8 +252,13 @@ func RunNmapScanBlocking(campaignName, target string) {
 		}
 	}()
 
+	campaignID, err := storage.GetOrCreateCampaign(campaignName)
+	if err != nil {
+		log.Fatalf("Error handling campaign '%s': %v", campaignName, err)
+	}
+
 	fmt.Printf("🚀 Starting Nmap scan on target '%s'. Press Ctrl+C to stop.\n", target)
-	networkMap, err := livecapture.RunNmapScan(ctx, target, campaignName)
+	networkMap, err := livecapture.RunNmapScan(ctx, target)
 	if err != nil && err != context.Canceled {
 		log.Fatalf("FATAL: Nmap scan failed: %v", err)
 	}
@@ -257,20 +269,24 @@ func RunNmapScanBlocking(campaignName, target string) {
 	}
 
 	fmt.Println("\n--- 📡 Nmap Scan Results ---")
-	if networkMap == nil || len(networkMap.Hosts) == 0 {
+	if len(networkMap.Hosts) == 0 {
 		fmt.Println("No hosts found.")
-		return
+	} else {
+		printHostResults(networkMap.Hosts)
+
+		fmt.Println("\n--- 🕵️ Post-Exploitation Checks ---")
+		for _, host := range networkMap.Hosts {
+			postexploitation.CheckFTPAnonymousLogin(host)
+			postexploitation.CheckSSHLogin(host)
+			postexploitation.CheckSMBUnauthenticatedAccess(host)
+		}
 	}
+	analyzeBeforeSave(networkMap)
 
-	printHostResults(networkMap.Hosts)
-
-	fmt.Println("\n--- 🕵️ Post-Exploitation Checks ---")
-	for _, host := range networkMap.Hosts {
-		postexploitation.CheckFTPAnonymousLogin(host)
-		postexploitation.CheckSSHLogin(host)
-		postexploitation.CheckSMBUnauthenticatedAccess(host)
+	fmt.Println("\n--- Saving results to database ---")
+	if err := storage.SaveScanResults(campaignID, networkMap, &model.PcapSummary{}); err != nil {
+		log.Fatalf("FATAL: Could not save results to database: %v", err)
 	}
-
 	fmt.Println("✅ Nmap scan results processed and saved.")
 }
 
@@ -314,6 +330,8 @@ func RunLiveScanBlocking(campaignName, interfaceName string) {
 	fmt.Println("\n--- Finalizing data ---")
 	processing.ProcessHandshakes(masterMap, globalSummary)
 	processing.EnrichWithLookups(masterMap, globalSummary)
+	processing.AnalyzeWireless(masterMap, globalSummary)
+	processing.AnalyzeDNS(masterMap, globalSummary)
 
 	fmt.Println("\n--- 🕵️ Post-Exploitation Checks ---")
 	for _, host := range masterMap.Hosts {
@@ -339,6 +357,8 @@ func RunLiveScanBlocking(campaignName, interfaceName string) {
 		}
 	}
 
+	analyzeBeforeSave(masterMap)
+
 	fmt.Println("\n--- Saving results to database ---")
 	if err := storage.SaveScanResults(campaignID, masterMap, globalSummary); err != nil {
 		log.Fatalf("FATAL: Could not save results to database: %v", err)
@@ -346,35 +366,84 @@ func RunLiveScanBlocking(campaignName, interfaceName string) {
 	fmt.Println("✅ Scan results saved successfully.")
 }
 
-func RunFileScanBlocking(campaignName, dataDir string) error {
+func RunFileScanBlocking(campaignName, dataDir string, force bool) error {
 	campaignID, err := storage.GetOrCreateCampaign(campaignName)
 	if err != nil {
 		return fmt.Errorf("error handling campaign '%s': %w", campaignName, err)
 	}
-	return RunFileScan(campaignName, dataDir, campaignID)
+	return RunFileScan(campaignName, dataDir, campaignID, force)
 }
 
-func RunFileScan(campaignName, dataDir string, campaignID int64) error {
+// RunFileScan processes the data files in a directory into a campaign. Files that were already
+// ingested into the campaign and have not changed since are skipped, unless force is set.
+func RunFileScan(campaignName, dataDir string, campaignID int64, force bool) error {
 	cleanDataDir := strings.TrimSpace(dataDir)
 	cleanDataDir = strings.Trim(cleanDataDir, "\"")
 	cleanDataDir = filepath.Clean(cleanDataDir)
 
+	// Archives that are unchanged since they were ingested are not read again, so a member that
+	// failed to parse is only retried once the archive changes or the scan is forced.
+	var registry map[string]model.IngestedFile
+	if !force {
+		var err error
+		if registry, err = storage.GetIngestedFiles(campaignID); err != nil {
+			return err
+		}
+	}
 	fmt.Printf("🔎 Searching for files in '%s'...\n", cleanDataDir)
-	xmlFiles, pcapFiles, err := findDataFiles(cleanDataDir)
+	found, err := findDataFiles(cleanDataDir, registry)
 	if err != nil {
 		return err
 	}
-	if len(xmlFiles) == 0 && len(pcapFiles) == 0 {
-		fmt.Printf("No new data files found in '%s'.\n", cleanDataDir)
-		return nil
+	_, err = ingestFiles(campaignName, campaignID, cleanDataDir, found, force)
+	return err
+}
+
+// ingestFiles runs the file processing pipeline over the given files, skipping those already
+// ingested unchanged (unless force is set), and records the result as an ingest run. It returns
+// the files that could not be processed.
+func ingestFiles(campaignName string, campaignID int64, cleanDataDir string, found []model.IngestedFile, force bool) ([]string, error) {
+	runID, err := storage.StartIngestRun(campaignID, cleanDataDir, force)
+	if err != nil {
+		return nil, err
+	}
+	// fail closes the ingest run with the error, so a failed run is not left open.
+	skipped := 0
+	fail := func(err error) ([]string, error) {
+		if finishErr := storage.FinishIngestRun(runID, 0, skipped, err); finishErr != nil {
+			log.Printf("Warning: %v", finishErr)
+		}
+		return nil, err
+	}
+	newFiles, unchanged, err := selectNewFiles(campaignID, found, force)
+	if err != nil {
+		return fail(err)
+	}
+	skipped = len(found) - len(newFiles)
+	if len(unchanged) > 0 {
+		// Files that were only touched get their new timestamps recorded, so they are not hashed again.
+		if err := storage.RecordIngestedFiles(campaignID, runID, unchanged); err != nil {
+			return fail(err)
+		}
+	}
+	if len(newFiles) == 0 {
+		fmt.Printf("No new data files found in '%s' (%d unchanged files skipped).\n", cleanDataDir, skipped)
+		return nil, storage.FinishIngestRun(runID, 0, skipped, nil)
 	}
 
-	fmt.Printf("Found %d Nmap and %d Pcap files. Processing...\n", len(xmlFiles), len(pcapFiles))
-	masterMap, globalSummary := processing.ProcessFiles(xmlFiles, pcapFiles)
+	var files processing.FileSet
+	for _, f := range newFiles {
+		files.Add(f.Path, processing.FileType(f.FileType))
+	}
+	fmt.Printf("Found %d Nmap, %d port scan, %d vulnerability report, %d Zeek/Suricata log and %d Pcap files (%d unchanged files skipped). Processing...\n",
+		len(files.Nmap), len(files.PortScans), len(files.VulnScans), len(files.NetLogs), len(files.Pcap), skipped)
+	masterMap, globalSummary, failed := processing.ProcessFiles(files)
 
 	fmt.Println("\n--- Finalizing data ---")
 	processing.ProcessHandshakes(masterMap, globalSummary)
 	processing.EnrichWithLookups(masterMap, globalSummary)
+	processing.AnalyzeWireless(masterMap, globalSummary)
+	processing.AnalyzeDNS(masterMap, globalSummary)
 
 	// After processing files, probe web servers
 	fmt.Println("\n--- Probing discovered web servers ---")
@@ -390,16 +459,16 @@ func RunFileScan(campaignName, dataDir string, campaignID int64) error {
 		postexploitation.CheckSMBUnauthenticatedAccess(host)
 	}
 
-	nmapHosts := make(map[string]*model.Host)
+	scannedHosts := make(map[string]*model.Host)
 	for key, host := range masterMap.Hosts {
-		if host.DiscoveredBy == "Nmap" {
-			nmapHosts[key] = host
+		if len(host.Ports) > 0 || host.DiscoveredBy == "Nmap" {
+			scannedHosts[key] = host
 		}
 	}
 
-	if len(nmapHosts) > 0 {
-		fmt.Println("\n--- 📡 Nmap Scan Results ---")
-		printHostResults(nmapHosts)
+	if len(scannedHosts) > 0 {
+		fmt.Println("\n--- 📡 Scan Results ---")
+		printHostResults(scannedHosts)
 	}
 
 	if len(globalSummary.CapturedHandshakes) > 0 {
@@ -414,12 +483,43 @@ func RunFileScan(campaignName, dataDir string, campaignID int64) error {
 		}
 	}
 
+	analyzeBeforeSave(masterMap)
+
 	fmt.Println("\n--- Saving results to database ---")
-	if err := storage.SaveScanResults(campaignID, masterMap, globalSummary); err != nil {
-		return fmt.Errorf("error saving results for '%s': %w", campaignName, err)
+	if err := storage.SaveScanResultsWithOptions(campaignID, masterMap, globalSummary, storage.SaveOptions{Reprocess: force}); err != nil {
+		return fail(fmt.Errorf("error saving results for '%s': %w", campaignName, err))
+	}
+
+	// Files that failed to parse stay out of the registry so the next scan retries them.
+	failedFiles := make(map[string]bool, len(failed))
+	for _, path := range failed {
+		failedFiles[path] = true
+	}
+	var ingested []model.IngestedFile
+	for _, f := range newFiles {
+		if !failedFiles[f.Path] {
+			ingested = append(ingested, f)
+		}
+	}
+	if err := storage.RecordIngestedFiles(campaignID, runID, ingested); err != nil {
+		return fail(err)
+	}
+	if err := storage.FinishIngestRun(runID, len(ingested), skipped, nil); err != nil {
+		return nil, err
 	}
 	fmt.Println("✅ Scan results saved successfully.")
-	return nil
+	return failed, nil
+}
+
+// analyzeBeforeSave matches service versions against the offline CVE database and applies the
+// detection rules. Every scan path calls it once, after all enrichment and just before saving, so
+// findings are derived from the final state of the map.
+func analyzeBeforeSave(networkMap *model.NetworkMap) {
+	fmt.Println("\n--- Matching service versions against the offline CVE database ---")
+	processing.InferVulnerabilities(networkMap, storage.LookupCVEs)
+
+	fmt.Println("\n--- Applying detection rules ---")
+	processing.ApplyDetectionRules(networkMap)
 }
 
 func printHostResults(hostMap map[string]*model.Host) {
@@ -459,34 +559,130 @@ func printHostResults(hostMap map[string]*model.Host) {
 	}
 }
 
-func findDataFiles(rootDir string) ([]string, []string, error) {
-	var xmlFiles, pcapFiles []string
+// findDataFiles walks a directory and returns every file with a recognised data format,
+// along with its size and modification time. Compressed files are recognised by their content,
+// and ZIP/tar archives are expanded into their members ("archive.zip::member"), which carry the
+// size and modification time of the archive itself. Archives are read in a single pass that also
+// hashes their members. Files listed in previous with an unchanged size and modification time
+// are reused without being opened.
+func findDataFiles(rootDir string, previous map[string]model.IngestedFile) ([]model.IngestedFile, error) {
+	var files []model.IngestedFile
+	bySource := filesBySource(previous)
 	info, err := os.Stat(rootDir)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, nil, fmt.Errorf("directory does not exist: %s", rootDir)
+			return files, fmt.Errorf("directory does not exist: %s", rootDir)
 		}
-		return nil, nil, fmt.Errorf("could not access directory %s: %w", rootDir, err)
+		return files, fmt.Errorf("could not access directory %s: %w", rootDir, err)
 	}
 	if !info.IsDir() {
-		return nil, nil, fmt.Errorf("path is not a directory: %s", rootDir)
+		return files, fmt.Errorf("path is not a directory: %s", rootDir)
 	}
 	walkErr := filepath.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
-		if !info.IsDir() {
-			ext := strings.ToLower(filepath.Ext(path))
-			if ext == ".xml" {
-				xmlFiles = append(xmlFiles, path)
-			} else if ext == ".pcap" || ext == ".pcapng" {
-				pcapFiles = append(pcapFiles, path)
+		if info.IsDir() {
+			return nil
+		}
+		if known := unchangedFiles(bySource, path, info); len(known) > 0 {
+			files = append(files, known...)
+			return nil
+		}
+		var members []model.IngestedFile
+		err = processing.WalkDataFile(path, func(member string, fileType processing.FileType, content io.Reader) error {
+			// Files are recognised by their content, so extensions don't matter.
+			if fileType == processing.FileTypeUnknown {
+				return nil
+			}
+			f := model.IngestedFile{Path: member, Size: info.Size(), ModTime: info.ModTime(), FileType: string(fileType)}
+			if member != path {
+				// Reopening a tar member means reading the archive up to it, so hash it now.
+				hash := sha256.New()
+				if _, err := io.Copy(hash, content); err != nil {
+					return fmt.Errorf("could not hash %s: %w", member, err)
+				}
+				f.SHA256 = hex.EncodeToString(hash.Sum(nil))
 			}
+			members = append(members, f)
+			return nil
+		})
+		if err != nil {
+			log.Printf("Warning: skipping %s: %v", path, err)
+			return nil
 		}
+		files = append(files, members...)
 		return nil
 	})
 	if walkErr != nil {
-		return nil, nil, fmt.Errorf("error walking directory %s: %w", rootDir, walkErr)
+		return files, fmt.Errorf("error walking directory %s: %w", rootDir, walkErr)
+	}
+	return files, nil
+}
+
+// filesBySource groups ingested files by the file on disk they came from: the file itself, or
+// the outermost archive for an archive member.
+func filesBySource(files map[string]model.IngestedFile) map[string][]model.IngestedFile {
+	bySource := make(map[string][]model.IngestedFile)
+	for _, f := range files {
+		source, _, _ := strings.Cut(f.Path, processing.ArchiveSeparator)
+		bySource[source] = append(bySource[source], f)
+	}
+	return bySource
+}
+
+// unchangedFiles returns the previously found files that came from the file at path, provided
+// its size and modification time have not changed since.
+func unchangedFiles(bySource map[string][]model.IngestedFile, path string, info os.FileInfo) []model.IngestedFile {
+	files := bySource[path]
+	for _, f := range files {
+		if f.Size != info.Size() || !f.ModTime.Equal(info.ModTime()) {
+			return nil
+		}
+	}
+	return files
+}
+
+// selectNewFiles compares the files found on disk with the campaign's ingested-files registry.
+// A file whose size and modification time are unchanged is skipped without being read; one that
+// was only touched (same SHA-256) is skipped but returned in unchanged so its timestamps can be
+// refreshed. With force, every file is selected.
+func selectNewFiles(campaignID int64, found []model.IngestedFile, force bool) (newFiles, unchanged []model.IngestedFile, err error) {
+	registry, err := storage.GetIngestedFiles(campaignID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, f := range found {
+		previous, seen := registry[f.Path]
+		if seen && !force && previous.Size == f.Size && previous.ModTime.Equal(f.ModTime) {
+			continue
+		}
+		if f.SHA256 == "" {
+			if f.SHA256, err = fileSHA256(f.Path); err != nil {
+				log.Printf("Warning: skipping %s: %v", f.Path, err)
+				continue
+			}
+		}
+		if seen && !force && previous.SHA256 == f.SHA256 {
+			unchanged = append(unchanged, f)
+			continue
+		}
+		newFiles = append(newFiles, f)
+	}
+	return newFiles, unchanged, nil
+}
+
+// fileSHA256 returns the hex-encoded SHA-256 digest of a file's decompressed contents.
+func fileSHA256(path string) (string, error) {
+	f, err := processing.OpenDataFile(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", fmt.Errorf("could not hash %s: %w", path, err)
 	}
-	return xmlFiles, pcapFiles, nil
+	return hex.EncodeToString(hash.Sum(nil)), nil
 }
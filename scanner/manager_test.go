@@ -0,0 +1,136 @@
+package scanner
+
+import (
+	"SnailsHell/model"
+	"SnailsHell/storage"
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestSelectNewFiles verifies that unchanged files are skipped, touched files are only re-stamped,
+// and modified files or a forced scan are processed again.
+func TestSelectNewFiles(t *testing.T) {
+	if err := storage.InitDB("file::memory:?cache=shared"); err != nil {
+		t.Fatalf("Failed to initialize in-memory database: %v", err)
+	}
+	campaignID, _ := storage.GetOrCreateCampaign("Incremental Ingest Test")
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "scan.xml")
+	if err := os.WriteFile(path, []byte(`<?xml version="1.0"?><nmaprun scanner="nmap"></nmaprun>`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	scan := func(force bool) (int, int) {
+		t.Helper()
+		found, err := findDataFiles(dir, nil)
+		if err != nil {
+			t.Fatalf("findDataFiles failed: %v", err)
+		}
+		newFiles, unchanged, err := selectNewFiles(campaignID, found, force)
+		if err != nil {
+			t.Fatalf("selectNewFiles failed: %v", err)
+		}
+		runID, _ := storage.StartIngestRun(campaignID, dir, force)
+		if err := storage.RecordIngestedFiles(campaignID, runID, append(newFiles, unchanged...)); err != nil {
+			t.Fatalf("RecordIngestedFiles failed: %v", err)
+		}
+		return len(newFiles), len(unchanged)
+	}
+
+	if n, _ := scan(false); n != 1 {
+		t.Fatalf("Expected the first scan to select the file, got %d", n)
+	}
+	if n, u := scan(false); n != 0 || u != 0 {
+		t.Errorf("Expected an unchanged file to be skipped without hashing, got %d new / %d unchanged", n, u)
+	}
+
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(path, later, later)
+	if n, u := scan(false); n != 0 || u != 1 {
+		t.Errorf("Expected a touched file to be skipped by hash, got %d new / %d unchanged", n, u)
+	}
+
+	if n, _ := scan(true); n != 1 {
+		t.Errorf("Expected force to select the file, got %d", n)
+	}
+
+	os.WriteFile(path, []byte(`<?xml version="1.0"?><nmaprun scanner="nmap" args="nmap -sV"></nmaprun>`), 0644)
+	os.Chtimes(path, later, later)
+	if n, _ := scan(false); n != 1 {
+		t.Errorf("Expected a modified file to be selected, got %d", n)
+	}
+}
+
+// TestIngestFilesFinishesFailedRun verifies that an ingest run that fails before processing is
+// still closed, with its error recorded.
+func TestIngestFilesFinishesFailedRun(t *testing.T) {
+	if err := storage.InitDB("file::memory:?cache=shared"); err != nil {
+		t.Fatalf("Failed to initialize in-memory database: %v", err)
+	}
+	campaignID, _ := storage.GetOrCreateCampaign("Failed Ingest Test")
+
+	// Hiding the registry makes selecting the new files fail after the run was started.
+	if _, err := storage.DB.Exec("ALTER TABLE ingested_files RENAME TO ingested_files_hidden"); err != nil {
+		t.Fatal(err)
+	}
+	defer storage.DB.Exec("ALTER TABLE ingested_files_hidden RENAME TO ingested_files")
+	dir := t.TempDir()
+	found := []model.IngestedFile{{Path: filepath.Join(dir, "scan.xml"), FileType: "nmap", Size: 10, ModTime: time.Now()}}
+	if _, err := ingestFiles("Failed Ingest Test", campaignID, dir, found, false); err == nil {
+		t.Fatal("Expected ingestFiles to fail without the registry")
+	}
+	var finished bool
+	var runErr string
+	if err := storage.DB.QueryRow("SELECT finished_at IS NOT NULL, error FROM ingest_runs WHERE campaign_id = ?", campaignID).Scan(&finished, &runErr); err != nil {
+		t.Fatalf("Could not read the ingest run: %v", err)
+	}
+	if !finished || runErr == "" {
+		t.Errorf("Expected the run to be finished with an error, got finished=%v error=%q", finished, runErr)
+	}
+}
+
+// TestFindDataFilesArchive verifies that archive members are hashed while the archive is walked,
+// and that an unchanged archive is taken from the previous listing without being read again.
+func TestFindDataFilesArchive(t *testing.T) {
+	dir := t.TempDir()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range []string{"a.xml", "b.xml"} {
+		data := []byte(`<?xml version="1.0"?><nmaprun scanner="nmap" args="` + name + `"></nmaprun>`)
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg})
+		tw.Write(data)
+	}
+	tw.Close()
+	archive := filepath.Join(dir, "scans.tar")
+	if err := os.WriteFile(archive, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := findDataFiles(dir, nil)
+	if err != nil {
+		t.Fatalf("findDataFiles failed: %v", err)
+	}
+	if len(found) != 2 || found[0].SHA256 == "" || found[0].SHA256 == found[1].SHA256 {
+		t.Fatalf("Expected two hashed members, got %+v", found)
+	}
+
+	previous := make(map[string]model.IngestedFile)
+	for _, f := range found {
+		f.SHA256 = "from previous poll"
+		previous[f.Path] = f
+	}
+	again, _ := findDataFiles(dir, previous)
+	if len(again) != 2 || again[0].SHA256 != "from previous poll" {
+		t.Errorf("Expected the unchanged archive to be reused, got %+v", again)
+	}
+
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(archive, later, later)
+	if again, _ := findDataFiles(dir, previous); len(again) != 2 || again[0].SHA256 == "from previous poll" {
+		t.Errorf("Expected a touched archive to be walked again, got %+v", again)
+	}
+}
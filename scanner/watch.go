@@ -0,0 +1,232 @@
+package scanner
+
+import (
+	"SnailsHell/model"
+	"SnailsHell/storage"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// defaultWatchInterval is used when no positive poll interval is configured.
+const defaultWatchInterval = 30 * time.Second
+
+// folderWatcher polls a directory tree and ingests data files into a campaign once they have
+// stopped changing.
+type folderWatcher struct {
+	campaignName string
+	campaignID   int64
+	dir          string
+	lastSeen     map[string]model.IngestedFile // Size and mtime observed on the previous poll
+	failed       map[string]model.IngestedFile // Files that failed to process, retried once they change
+	ingested     int
+}
+
+func newFolderWatcher(campaignName string, campaignID int64, dir string) *folderWatcher {
+	return &folderWatcher{
+		campaignName: campaignName,
+		campaignID:   campaignID,
+		dir:          dir,
+		lastSeen:     make(map[string]model.IngestedFile),
+		failed:       make(map[string]model.IngestedFile),
+	}
+}
+
+// poll checks the directory once. New or changed files are only ingested when their size and
+// modification time match the previous poll, so files still being written (e.g. a pcap that is
+// being rotated) are left for a later poll. It returns the number of files ingested.
+func (w *folderWatcher) poll() (int, error) {
+	found, err := findDataFiles(w.dir, w.lastSeen)
+	if err != nil {
+		return 0, err
+	}
+	registry, err := storage.GetIngestedFiles(w.campaignID)
+	if err != nil {
+		return 0, err
+	}
+
+	seen := make(map[string]model.IngestedFile, len(found))
+	var ready []model.IngestedFile
+	for _, f := range found {
+		seen[f.Path] = f
+		if previous, ok := registry[f.Path]; ok && sameFileState(previous, f) {
+			continue
+		}
+		if previous, ok := w.failed[f.Path]; ok && sameFileState(previous, f) {
+			continue
+		}
+		if previous, ok := w.lastSeen[f.Path]; !ok || !sameFileState(previous, f) {
+			continue // Still growing, or seen for the first time.
+		}
+		ready = append(ready, f)
+	}
+	w.lastSeen = seen
+	if len(ready) == 0 {
+		return 0, nil
+	}
+
+	failed, err := ingestFiles(w.campaignName, w.campaignID, w.dir, ready, false)
+	if err != nil {
+		return 0, err
+	}
+	failedPaths := make(map[string]bool, len(failed))
+	for _, path := range failed {
+		failedPaths[path] = true
+	}
+	for _, f := range ready {
+		if failedPaths[f.Path] {
+			w.failed[f.Path] = f
+		} else {
+			delete(w.failed, f.Path)
+		}
+	}
+	w.ingested += len(ready) - len(failed)
+	return len(ready) - len(failed), nil
+}
+
+// sameFileState reports whether two observations of a file have the same size and modification time.
+func sameFileState(a, b model.IngestedFile) bool {
+	return a.Size == b.Size && a.ModTime.Equal(b.ModTime)
+}
+
+// watchDirectory polls dir every interval until ctx is cancelled. Each poll's outcome is passed
+// to report; a failed poll is reported and retried on the next tick rather than ending the watch.
+func watchDirectory(ctx context.Context, w *folderWatcher, interval time.Duration, report func(status string)) {
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := w.poll()
+		switch {
+		case err != nil:
+			log.Printf("Error while watching '%s': %v", w.dir, err)
+			report(fmt.Sprintf("Watching '%s' for '%s': %d files ingested. Last poll failed: %v", w.dir, w.campaignName, w.ingested, err))
+		case n > 0:
+			report(fmt.Sprintf("Watching '%s' for '%s': %d files ingested (%d at %s).", w.dir, w.campaignName, w.ingested, n, time.Now().Format("15:04:05")))
+		case len(w.failed) > 0:
+			report(fmt.Sprintf("Watching '%s' for '%s': %d files ingested, %d could not be processed.", w.dir, w.campaignName, w.ingested, len(w.failed)))
+		default:
+			report(fmt.Sprintf("Watching '%s' for '%s': %d files ingested.", w.dir, w.campaignName, w.ingested))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// cleanWatchDir normalises a user-supplied directory and checks that it exists.
+func cleanWatchDir(dataDir string) (string, error) {
+	dir := filepath.Clean(strings.Trim(strings.TrimSpace(dataDir), "\""))
+	info, err := os.Stat(dir)
+	if err != nil {
+		return "", fmt.Errorf("could not access directory %s: %w", dir, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("path is not a directory: %s", dir)
+	}
+	return dir, nil
+}
+
+func (sm *ScanManager) StartWatchTask(campaignName, dataDir string, interval time.Duration) (int64, error) {
+	dir, err := cleanWatchDir(dataDir)
+	if err != nil {
+		return 0, err
+	}
+
+	sm.mu.Lock()
+	if sm.IsScanning {
+		sm.mu.Unlock()
+		return 0, fmt.Errorf("a scan is already in progress")
+	}
+
+	sm.IsScanning = true
+	sm.Status = fmt.Sprintf("Scanning: Watching '%s' for '%s'...", dir, campaignName)
+	ctx, cancel := context.WithCancel(context.Background())
+	sm.cancelFunc = cancel
+	sm.mu.Unlock()
+
+	campaignID, err := storage.GetOrCreateCampaign(campaignName)
+	if err != nil {
+		sm.resetState()
+		return 0, fmt.Errorf("could not create campaign: %w", err)
+	}
+
+	go func() {
+		w := newFolderWatcher(campaignName, campaignID, dir)
+		watchDirectory(ctx, w, interval, func(status string) {
+			sm.mu.Lock()
+			if ctx.Err() == nil {
+				sm.Status = "Scanning: " + status
+			}
+			sm.mu.Unlock()
+		})
+
+		sm.mu.Lock()
+		sm.Status = fmt.Sprintf("Success: Stopped watching '%s' for '%s' (%d files ingested).", dir, campaignName, w.ingested)
+		sm.IsScanning = false
+		sm.cancelFunc = nil
+		sm.mu.Unlock()
+
+		<-time.After(5 * time.Second)
+		sm.mu.Lock()
+		if !strings.HasPrefix(sm.Status, "Scanning:") {
+			sm.Status = "Idle"
+		}
+		sm.mu.Unlock()
+	}()
+
+	return campaignID, nil
+}
+
+// RunWatchBlocking watches a directory from the CLI until interrupted with Ctrl+C.
+func RunWatchBlocking(campaignName, dataDir string, interval time.Duration) error {
+	dir, err := cleanWatchDir(dataDir)
+	if err != nil {
+		return err
+	}
+	campaignID, err := storage.GetOrCreateCampaign(campaignName)
+	if err != nil {
+		return fmt.Errorf("error handling campaign '%s': %w", campaignName, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(c)
+
+	go func() {
+		select {
+		case <-c:
+			fmt.Println("\n🛑 Stopping watch...")
+			cancel()
+		case <-ctx.Done():
+			return
+		}
+	}()
+
+	fmt.Printf("👀 Watching '%s' every %s for campaign '%s'. Press Ctrl+C to stop.\n", dir, interval, campaignName)
+	w := newFolderWatcher(campaignName, campaignID, dir)
+	lastStatus := ""
+	watchDirectory(ctx, w, interval, func(status string) {
+		if status != lastStatus {
+			fmt.Printf("[%s] %s\n", time.Now().Format("15:04:05"), status)
+			lastStatus = status
+		}
+	})
+	fmt.Printf("✅ Watch stopped. %d files ingested.\n", w.ingested)
+	return nil
+}
@@ -0,0 +1,53 @@
+package scanner
+
+import (
+	"SnailsHell/storage"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestFolderWatcherWaitsForStableFiles verifies that a file is only ingested once it has stopped
+// changing between polls, and is not ingested again afterwards.
+func TestFolderWatcherWaitsForStableFiles(t *testing.T) {
+	if err := storage.InitDB("file::memory:?cache=shared"); err != nil {
+		t.Fatalf("Failed to initialize in-memory database: %v", err)
+	}
+	campaignID, _ := storage.GetOrCreateCampaign("Watch Test")
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rotated.xml")
+	if err := os.WriteFile(path, []byte(`<?xml version="1.0"?><nmaprun scanner="nmap">`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w := newFolderWatcher("Watch Test", campaignID, dir)
+	poll := func() int {
+		t.Helper()
+		n, err := w.poll()
+		if err != nil {
+			t.Fatalf("poll failed: %v", err)
+		}
+		return n
+	}
+
+	if n := poll(); n != 0 {
+		t.Fatalf("Expected a newly seen file to wait for the next poll, got %d ingested", n)
+	}
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString(`<runstats><finished time="1700000000" elapsed="1.5"/><hosts up="0" down="0" total="0"/></runstats></nmaprun>`)
+	f.Close()
+	if n := poll(); n != 0 {
+		t.Fatalf("Expected a growing file to be left alone, got %d ingested", n)
+	}
+	if n := poll(); n != 1 {
+		t.Fatalf("Expected the stable file to be ingested, got %d", n)
+	}
+	if n := poll(); n != 0 || w.ingested != 1 {
+		t.Errorf("Expected an ingested file not to be processed again, got %d (total %d)", n, w.ingested)
+	}
+
+	if files, _ := storage.GetIngestedFiles(campaignID); len(files) != 1 {
+		t.Errorf("Expected the file in the ingested-files registry, got %+v", files)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"SnailsHell/model"
 	"SnailsHell/storage"
 	"fmt"
+	"sort"
 )
 
 // ComparisonResult holds the results of comparing two campaigns.
@@ -101,12 +102,22 @@ func compareSingleHost(base, comp *model.Host) HostChange {
 
 	// Compare Vulnerabilities
 	change.NewVulns, change.RemovedVulns = compareVulns(base.Findings, comp.Findings)
-	if len(change.NewVulns) > 0 {
-		change.Changes = append(change.Changes, fmt.Sprintf("Found %d new vulnerability/ies", len(change.NewVulns)))
+	dismissed := 0
+	for _, v := range change.NewVulns {
+		if v.Dismissed() {
+			dismissed++
+		}
+	}
+	if n := len(change.NewVulns) - dismissed; n > 0 {
+		change.Changes = append(change.Changes, fmt.Sprintf("Found %d new vulnerability/ies", n))
+	}
+	if dismissed > 0 {
+		change.Changes = append(change.Changes, fmt.Sprintf("Found %d new vulnerability/ies already triaged as false positive or fixed", dismissed))
 	}
 	if len(change.RemovedVulns) > 0 {
 		change.Changes = append(change.Changes, fmt.Sprintf("Found %d removed vulnerability/ies", len(change.RemovedVulns)))
 	}
+	change.Changes = append(change.Changes, compareTriage(base.Findings, comp.Findings)...)
 
 	return change
 }
@@ -154,3 +165,39 @@ func compareVulns(base, comp map[model.FindingCategory][]model.Vulnerability) (n
 	}
 	return
 }
+
+// compareTriage describes how the triage of the findings present in both campaigns compares: a
+// finding triaged as fixed that is still present, one that was triaged in the base campaign but not
+// yet in the other, and a status that changed.
+func compareTriage(base, comp map[model.FindingCategory][]model.Vulnerability) []string {
+	baseVulns := make(map[string]model.Vulnerability)
+	for _, cat := range base {
+		for _, v := range cat {
+			baseVulns[fmt.Sprintf("%d-%s", v.PortID, v.CVE)] = v
+		}
+	}
+
+	var changes []string
+	for _, cat := range comp {
+		for _, v := range cat {
+			baseVuln, found := baseVulns[fmt.Sprintf("%d-%s", v.PortID, v.CVE)]
+			if !found {
+				continue
+			}
+			name := v.CVE
+			if v.PortID != 0 {
+				name = fmt.Sprintf("%s on port %d", v.CVE, v.PortID)
+			}
+			switch {
+			case baseVuln.TriageStatus == model.TriageFixed && !v.Dismissed():
+				changes = append(changes, fmt.Sprintf("%s was triaged as fixed but is still present", name))
+			case v.TriageStatus == model.TriageNew && baseVuln.TriageStatus != model.TriageNew:
+				changes = append(changes, fmt.Sprintf("%s is still present, previously triaged as '%s'", name, baseVuln.TriageStatus))
+			case baseVuln.TriageStatus != v.TriageStatus:
+				changes = append(changes, fmt.Sprintf("Triage status of %s changed from '%s' to '%s'", name, baseVuln.TriageStatus, v.TriageStatus))
+			}
+		}
+	}
+	sort.Strings(changes)
+	return changes
+}
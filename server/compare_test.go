@@ -85,3 +85,69 @@ func TestCompareCampaigns(t *testing.T) {
 		}
 	}
 }
+
+// TestCompareCampaignsTriage verifies that the triage of the base campaign is carried into the
+// comparison and that new findings already dismissed are reported apart.
+func TestCompareCampaignsTriage(t *testing.T) {
+	setupTestDB(t)
+
+	newMap := func(cves ...string) *model.NetworkMap {
+		networkMap := model.NewNetworkMap()
+		host := model.NewHost("00:00:00:DD:DD:DD")
+		host.Status = "up"
+		host.Ports[80] = model.Port{ID: 80, Protocol: "tcp", State: "open"}
+		for _, cve := range cves {
+			host.Findings[model.CriticalFinding] = append(host.Findings[model.CriticalFinding],
+				model.Vulnerability{CVE: cve, State: "VULNERABLE", Category: model.CriticalFinding, PortID: 80})
+		}
+		networkMap.Hosts[host.MACAddress] = host
+		return networkMap
+	}
+	baseCampaignID, _ := storage.GetOrCreateCampaign("Triage Base")
+	if err := storage.SaveScanResults(baseCampaignID, newMap("CVE-2021-0001", "CVE-2021-0002"), model.NewPcapSummary()); err != nil {
+		t.Fatalf("Failed to save base campaign data: %v", err)
+	}
+	baseHosts, _ := storage.GetFullHostsForCampaign(baseCampaignID)
+	for _, v := range baseHosts["00:00:00:DD:DD:DD"].Findings[model.CriticalFinding] {
+		status := model.TriageFixed
+		if v.CVE == "CVE-2021-0002" {
+			status = model.TriageConfirmed
+		}
+		if err := storage.UpdateFindingTriage(baseCampaignID, v.ID, status, ""); err != nil {
+			t.Fatalf("UpdateFindingTriage failed: %v", err)
+		}
+	}
+
+	compCampaignID, _ := storage.GetOrCreateCampaign("Triage Comparison")
+	if _, _, err := storage.CreateSuppressionRule(compCampaignID, model.SuppressionRule{CVE: "CVE-2021-0003"}); err != nil {
+		t.Fatalf("CreateSuppressionRule failed: %v", err)
+	}
+	if err := storage.SaveScanResults(compCampaignID, newMap("CVE-2021-0001", "CVE-2021-0002", "CVE-2021-0003"), model.NewPcapSummary()); err != nil {
+		t.Fatalf("Failed to save comparison campaign data: %v", err)
+	}
+
+	result, err := CompareCampaigns(baseCampaignID, compCampaignID)
+	if err != nil {
+		t.Fatalf("CompareCampaigns failed: %v", err)
+	}
+	if len(result.ChangedHosts) != 1 {
+		t.Fatalf("Expected 1 changed host, got %+v", result.ChangedHosts)
+	}
+	change := result.ChangedHosts[0]
+	want := []string{
+		"Found 1 new vulnerability/ies already triaged as false positive or fixed",
+		"CVE-2021-0001 on port 80 was triaged as fixed but is still present",
+		"CVE-2021-0002 on port 80 is still present, previously triaged as 'Confirmed'",
+	}
+	if len(change.Changes) != len(want) {
+		t.Fatalf("Expected changes %q, got %q", want, change.Changes)
+	}
+	for i := range want {
+		if change.Changes[i] != want[i] {
+			t.Errorf("Change %d = %q, want %q", i, change.Changes[i], want[i])
+		}
+	}
+	if len(change.NewVulns) != 1 || change.NewVulns[0].TriageStatus != model.TriageFalsePositive {
+		t.Errorf("Expected the new finding to carry its status, got %+v", change.NewVulns)
+	}
+}
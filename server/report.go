@@ -1,16 +1,21 @@
 package server
 
 import (
+	"SnailsHell/model"
 	"SnailsHell/storage"
 	"archive/zip"
 	"bytes"
 	"encoding/csv"
 	"fmt"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 )
 
-// GenerateReportZip creates a ZIP archive in memory containing multiple CSV files.
-func GenerateReportZip(campaignID int64) ([]byte, error) {
+// GenerateReportZip creates a ZIP archive in memory containing multiple CSV files and, when
+// includeFiles is set, the files carved from the campaign's traffic under files/.
+func GenerateReportZip(campaignID int64, includeFiles bool) ([]byte, error) {
 	buf := new(bytes.Buffer)
 	zipWriter := zip.NewWriter(buf)
 
@@ -23,10 +28,11 @@ func GenerateReportZip(campaignID int64) ([]byte, error) {
 		hostData = append(hostData, []string{
 			strconv.FormatInt(h.ID, 10), h.IPAddress, h.MACAddress, h.Vendor,
 			h.OSGuess, h.DeviceType, h.Status, strconv.FormatBool(h.HasVulns),
+			strconv.FormatFloat(h.RiskScore, 'f', 1, 64), model.RiskLevel(h.RiskScore),
 		})
 	}
 	err = createCSVInZip(zipWriter, "hosts.csv",
-		[]string{"Host ID", "IP Address", "MAC Address", "Vendor", "OS Guess", "Device Type", "Status", "Has Vulnerabilities"},
+		[]string{"Host ID", "IP Address", "MAC Address", "Vendor", "OS Guess", "Device Type", "Status", "Has Vulnerabilities", "Risk Score", "Risk Level"},
 		hostData)
 	if err != nil {
 		return nil, err
@@ -48,7 +54,8 @@ func GenerateReportZip(campaignID int64) ([]byte, error) {
 		return nil, fmt.Errorf("could not get vulnerabilities for report: %w", err)
 	}
 	err = createCSVInZip(zipWriter, "vulnerabilities.csv",
-		[]string{"Host MAC", "CVE", "Category", "State", "Description"},
+		[]string{"Host MAC", "CVE", "Category", "State", "CVSS", "Source", "Description", "References", "Evidence", "Known Exploited", "EPSS",
+			"Triage Status", "Assignee", "Notes"},
 		vulns)
 	if err != nil {
 		return nil, err
@@ -59,7 +66,7 @@ func GenerateReportZip(campaignID int64) ([]byte, error) {
 		return nil, fmt.Errorf("could not get communications for report: %w", err)
 	}
 	err = createCSVInZip(zipWriter, "communications.csv",
-		[]string{"Host MAC", "Counterpart IP", "Packet Count", "City", "Country", "ISP"},
+		[]string{"Host MAC", "Counterpart IP", "Packet Count", "City", "Country", "ISP", "Hostnames"},
 		comms)
 	if err != nil {
 		return nil, err
@@ -76,6 +83,63 @@ func GenerateReportZip(campaignID int64) ([]byte, error) {
 		return nil, err
 	}
 
+	dnsAnswers, err := storage.GetAllDNSAnswersForReport(campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get DNS answers for report: %w", err)
+	}
+	err = createCSVInZip(zipWriter, "dns_answers.csv",
+		[]string{"Host MAC", "Name", "Type", "Answer", "TTL", "Resolver", "Count", "First Seen", "Last Seen"},
+		dnsAnswers)
+	if err != nil {
+		return nil, err
+	}
+
+	dnsResolvers, err := storage.GetAllDNSResolversForReport(campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get DNS resolvers for report: %w", err)
+	}
+	err = createCSVInZip(zipWriter, "dns_resolvers.csv",
+		[]string{"Host MAC", "Resolver IP", "Protocol", "Hostname", "Queries", "Responses", "NXDOMAIN", "Connections", "Avg Response (ms)", "First Seen", "Last Seen"},
+		dnsResolvers)
+	if err != nil {
+		return nil, err
+	}
+
+	otOperations, err := storage.GetAllOTOperationsForReport(campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get OT operations for report: %w", err)
+	}
+	err = createCSVInZip(zipWriter, "ot_operations.csv",
+		[]string{"Host MAC", "OT Role", "Protocol", "Role", "Peer", "Port", "Unit ID", "Function", "Operation", "Count", "First Seen", "Last Seen", "Identity"},
+		otOperations)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsSessions, err := storage.GetAllTLSSessionsForReport(campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get TLS sessions for report: %w", err)
+	}
+	err = createCSVInZip(zipWriter, "tls_sessions.csv",
+		[]string{"Host MAC", "Client IP", "Server IP", "Server Port", "SNI", "ALPN", "Version", "Cipher", "JA3", "JA3S", "JA4",
+			"Certificate Subject", "Certificate Issuer", "Certificate SANs", "Certificate Expiry", "Self-Signed", "Connections"},
+		tlsSessions)
+	if err != nil {
+		return nil, err
+	}
+
+	httpTransactions, err := storage.GetAllHTTPTransactionsForReport(campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get HTTP transactions for report: %w", err)
+	}
+	err = createCSVInZip(zipWriter, "http_transactions.csv",
+		[]string{"Host MAC", "Timestamp", "Client IP", "Server IP", "Server Port", "Method", "Host", "URI", "Status",
+			"User-Agent", "Server", "Content-Type", "Request Size", "Response Size", "Pcap File"},
+		httpTransactions)
+	if err != nil {
+		return nil, err
+	}
+
 	handshakes, err := storage.GetAllHandshakesForReport(campaignID)
 	if err != nil {
 		return nil, fmt.Errorf("could not get handshakes for report: %w", err)
@@ -93,6 +157,117 @@ func GenerateReportZip(campaignID int64) ([]byte, error) {
 		return nil, err
 	}
 
+	deauths, err := storage.GetDeauthPairs(campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get deauthentication activity for report: %w", err)
+	}
+	var deauthData [][]string
+	for _, d := range deauths {
+		deauthData = append(deauthData, []string{
+			d.SSID, d.APMAC, d.ClientMAC, strconv.Itoa(d.Deauths), strconv.Itoa(d.Disassocs), d.ReasonSummary(),
+			formatReportTime(d.FirstSeen), formatReportTime(d.LastSeen), formatReportTime(d.ReconnectedAt), d.HandshakeState,
+		})
+	}
+	err = createCSVInZip(zipWriter, "deauths.csv",
+		[]string{"SSID", "AP MAC", "Client MAC", "Deauths", "Disassocs", "Reason Codes", "First Seen", "Last Seen", "Reconnected At", "Handshake"},
+		deauthData)
+	if err != nil {
+		return nil, err
+	}
+
+	accessPoints, err := storage.GetWirelessAccessPoints(campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get access points for report: %w", err)
+	}
+	var accessPointData [][]string
+	for _, ap := range accessPoints {
+		accessPointData = append(accessPointData, []string{
+			ap.SSID, ap.BSSID, ap.Vendor, strconv.Itoa(ap.Channel), ap.Encryption, ap.AKM, ap.Ciphers,
+			strconv.FormatBool(ap.WPS), strconv.Itoa(ap.RSSI), strconv.Itoa(ap.Clients), formatReportTime(ap.FirstSeen), formatReportTime(ap.LastSeen),
+		})
+	}
+	err = createCSVInZip(zipWriter, "wifi_access_points.csv",
+		[]string{"SSID", "BSSID", "Vendor", "Channel", "Encryption", "AKM", "Ciphers", "WPS", "RSSI (dBm)", "Clients", "First Seen", "Last Seen"},
+		accessPointData)
+	if err != nil {
+		return nil, err
+	}
+
+	clients, err := storage.GetWirelessClients(campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get wireless clients for report: %w", err)
+	}
+	var clientData [][]string
+	for _, client := range clients {
+		var probes []string
+		for ssid := range client.ProbedSSIDs {
+			probes = append(probes, ssid)
+		}
+		sort.Strings(probes)
+		clientData = append(clientData, []string{
+			client.MAC, client.Vendor, client.AssociatedBSSID, strings.Join(probes, "; "),
+			strconv.Itoa(client.RSSI), strconv.Itoa(client.Frames), formatReportTime(client.FirstSeen), formatReportTime(client.LastSeen),
+		})
+	}
+	err = createCSVInZip(zipWriter, "wifi_clients.csv",
+		[]string{"MAC", "Vendor", "Associated BSSID", "Probed SSIDs", "RSSI (dBm)", "Frames", "First Seen", "Last Seen"},
+		clientData)
+	if err != nil {
+		return nil, err
+	}
+
+	carvedFiles, err := storage.GetCarvedFilesByCampaign(campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get carved files for report: %w", err)
+	}
+	var carvedFileData [][]string
+	for _, f := range carvedFiles {
+		carvedFileData = append(carvedFileData, []string{
+			strconv.FormatInt(f.ID, 10), f.Filename, f.MIMEType, strconv.Itoa(f.Size), f.MD5, f.SHA1, f.SHA256, f.Protocol,
+			f.SourceIP, f.DestinationIP, f.HostMAC, formatReportTime(f.Timestamp), f.PcapFile,
+		})
+	}
+	err = createCSVInZip(zipWriter, "carved_files.csv",
+		[]string{"File ID", "Filename", "MIME Type", "Size", "MD5", "SHA1", "SHA256", "Protocol", "Source IP", "Destination IP", "Host MAC", "Timestamp", "Pcap File"},
+		carvedFileData)
+	if err != nil {
+		return nil, err
+	}
+	if includeFiles {
+		for _, f := range carvedFiles {
+			// Contents are loaded one file at a time rather than with the listing.
+			file, err := storage.GetCarvedFileByID(f.ID)
+			if err != nil {
+				return nil, fmt.Errorf("could not get carved file %d for report: %w", f.ID, err)
+			}
+			fileWriter, err := zipWriter.Create(fmt.Sprintf("files/%d_%s", f.ID, safeFilename(f.Filename)))
+			if err != nil {
+				return nil, fmt.Errorf("failed to add carved file %d to zip: %w", f.ID, err)
+			}
+			if _, err := fileWriter.Write(file.Data); err != nil {
+				return nil, fmt.Errorf("failed to write carved file %d to zip: %w", f.ID, err)
+			}
+		}
+	}
+
+	scanRuns, err := storage.GetScanRunsByCampaign(campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get scan runs for report: %w", err)
+	}
+	var scanRunData [][]string
+	for _, r := range scanRuns {
+		scanRunData = append(scanRunData, []string{
+			r.Scanner, r.Version, r.Args, formatReportTime(r.StartTime), formatReportTime(r.EndTime),
+			strconv.FormatFloat(r.Elapsed, 'f', 2, 64), strconv.Itoa(r.HostsUp), strconv.Itoa(r.HostsTotal),
+		})
+	}
+	err = createCSVInZip(zipWriter, "scan_runs.csv",
+		[]string{"Scanner", "Version", "Command Line", "Start", "End", "Elapsed (s)", "Hosts Up", "Hosts Total"},
+		scanRunData)
+	if err != nil {
+		return nil, err
+	}
+
 	if err := zipWriter.Close(); err != nil {
 		return nil, fmt.Errorf("could not close zip writer: %w", err)
 	}
@@ -115,3 +290,24 @@ func createCSVInZip(zipWriter *zip.Writer, filename string, header []string, dat
 	csvWriter.Flush()
 	return csvWriter.Error()
 }
+
+func formatReportTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// safeFilename keeps a carved file's name from escaping the directory it is written to.
+func safeFilename(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r < 0x20 || r == 0x7f {
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" || name == "." || name == ".." {
+		return "file"
+	}
+	return name
+}
@@ -3,12 +3,15 @@ package server
 import (
 	"SnailsHell/config"
 	"SnailsHell/livecapture"
+	"SnailsHell/model"
 	"SnailsHell/scanner"
 	"SnailsHell/storage"
 	"embed"
+	"errors"
 	"fmt"
 	"html/template"
 	"log"
+	"mime"
 	"net/http"
 	"strconv"
 	"strings"
@@ -40,6 +43,7 @@ func Start(embeddedTemplates embed.FS) {
 			return make([]struct{}, n)
 		},
 		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
 		"default": func(dflt, val string) string {
 			if val == "" {
 				return dflt
@@ -61,6 +65,8 @@ func Start(embeddedTemplates embed.FS) {
 		campaignRoutes.GET("/hosts/:id", handleHostDetail)
 		campaignRoutes.GET("/handshakes", handleHandshakes)
 		campaignRoutes.GET("/credentials", handleCredentialsPage)
+		campaignRoutes.GET("/wifi", handleWifiPage)
+		campaignRoutes.GET("/files", handleFilesPage)
 		campaignRoutes.GET("/report/zip", handleReportDownload)
 	}
 
@@ -74,11 +80,13 @@ func Start(embeddedTemplates embed.FS) {
 
 		// New endpoint to serve screenshots
 		api.GET("/screenshot/:id", handleGetScreenshot)
+		api.GET("/files/:id", handleGetCarvedFile)
 
 		scansAPI := api.Group("/scans")
 		{
 			scansAPI.POST("/live/start", handleStartLiveScan)
 			scansAPI.POST("/file/start", handleStartFileScan)
+			scansAPI.POST("/watch/start", handleStartWatch)
 			scansAPI.POST("/nmap/start", handleStartNmapScan)
 			scansAPI.GET("/status", handleGetScanStatus)
 			scansAPI.POST("/stop", handleStopScan)
@@ -88,6 +96,11 @@ func Start(embeddedTemplates embed.FS) {
 		{
 			apiCampaignRoutes.GET("/hosts", handleGetHosts)
 			apiCampaignRoutes.GET("/hosts/:id/communications", handleGetHostCommunications)
+			apiCampaignRoutes.PUT("/findings/:findingID", handleUpdateFindingTriage)
+			apiCampaignRoutes.POST("/findings/:findingID/notes", handleAddFindingNote)
+			apiCampaignRoutes.GET("/suppressions", handleGetSuppressionRules)
+			apiCampaignRoutes.POST("/suppressions", handleCreateSuppressionRule)
+			apiCampaignRoutes.DELETE("/suppressions/:ruleID", handleDeleteSuppressionRule)
 		}
 	}
 
@@ -108,6 +121,7 @@ func handleCampaignList(c *gin.Context) {
 	}
 	data := getBaseTemplateData()
 	data["Campaigns"] = campaigns
+	data["LiveCaptureAvailable"] = livecapture.Available
 
 	c.HTML(http.StatusOK, "campaign_list.html", data)
 }
@@ -167,6 +181,7 @@ func handleHostDetail(c *gin.Context) {
 	data := getBaseTemplateData()
 	data["Host"] = host
 	data["CampaignID"] = campaignID
+	data["TriageStatuses"] = model.TriageStatuses
 
 	c.HTML(http.StatusOK, "host_detail.html", data)
 }
@@ -198,10 +213,23 @@ func handleHandshakes(c *gin.Context) {
 		c.String(http.StatusInternalServerError, "Could not load handshakes.")
 		return
 	}
+	deauths, err := storage.GetDeauthPairs(campaignID)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Could not load deauthentication activity.")
+		return
+	}
+	pendingTargets := 0
+	for _, d := range deauths {
+		if d.HandshakeState != "Full" {
+			pendingTargets++
+		}
+	}
 
 	data := getBaseTemplateData()
 	data["Campaign"] = campaign
 	data["Handshakes"] = handshakes
+	data["Deauths"] = deauths
+	data["PendingTargets"] = pendingTargets
 	data["TotalPages"] = totalPages
 	data["CurrentPage"] = page
 
@@ -228,6 +256,52 @@ func handleCredentialsPage(c *gin.Context) {
 	c.HTML(http.StatusOK, "credentials.html", data)
 }
 
+func handleWifiPage(c *gin.Context) {
+	campaignID, _ := strconv.ParseInt(c.Param("campaignID"), 10, 64)
+	campaign, err := storage.GetCampaignByID(campaignID)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Could not load campaign details.")
+		return
+	}
+	accessPoints, err := storage.GetWirelessAccessPoints(campaignID)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Could not load access points.")
+		return
+	}
+	clients, err := storage.GetWirelessClients(campaignID)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Could not load wireless clients.")
+		return
+	}
+
+	data := getBaseTemplateData()
+	data["Campaign"] = campaign
+	data["AccessPoints"] = accessPoints
+	data["Clients"] = clients
+
+	c.HTML(http.StatusOK, "wifi.html", data)
+}
+
+func handleFilesPage(c *gin.Context) {
+	campaignID, _ := strconv.ParseInt(c.Param("campaignID"), 10, 64)
+	campaign, err := storage.GetCampaignByID(campaignID)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Could not load campaign details.")
+		return
+	}
+	files, err := storage.GetCarvedFilesByCampaign(campaignID)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Could not load carved files.")
+		return
+	}
+
+	data := getBaseTemplateData()
+	data["Campaign"] = campaign
+	data["Files"] = files
+
+	c.HTML(http.StatusOK, "files.html", data)
+}
+
 func handleReportDownload(c *gin.Context) {
 	campaignID, _ := strconv.ParseInt(c.Param("campaignID"), 10, 64)
 	campaign, err := storage.GetCampaignByID(campaignID)
@@ -235,7 +309,7 @@ func handleReportDownload(c *gin.Context) {
 		c.String(http.StatusNotFound, "Campaign not found")
 		return
 	}
-	zipData, err := GenerateReportZip(campaignID)
+	zipData, err := GenerateReportZip(campaignID, c.Query("files") == "1")
 	if err != nil {
 		c.String(http.StatusInternalServerError, "Could not generate ZIP report.")
 		return
@@ -253,6 +327,10 @@ func handleGetNmapStatus(c *gin.Context) {
 
 func handleGetInterfaces(c *gin.Context) {
 	interfaces, err := livecapture.ListInterfaces()
+	if errors.Is(err, livecapture.ErrLiveCaptureUnavailable) {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not get interfaces"})
 		return
@@ -311,12 +389,13 @@ func handleStartFileScan(c *gin.Context) {
 	var req struct {
 		CampaignName string `json:"campaignName"`
 		Directory    string `json:"directory"`
+		Force        bool   `json:"force"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
 		return
 	}
-	_, err := scanner.Manager.StartFileScanTask(req.CampaignName, req.Directory)
+	_, err := scanner.Manager.StartFileScanTask(req.CampaignName, req.Directory, req.Force)
 	if err != nil {
 		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 		return
@@ -324,6 +403,27 @@ func handleStartFileScan(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "File scan started for " + req.CampaignName})
 }
 
+func handleStartWatch(c *gin.Context) {
+	var req struct {
+		CampaignName    string `json:"campaignName"`
+		Directory       string `json:"directory"`
+		IntervalSeconds int    `json:"intervalSeconds"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	if req.IntervalSeconds <= 0 {
+		req.IntervalSeconds = config.Cfg.Watch.PollIntervalSeconds
+	}
+	_, err := scanner.Manager.StartWatchTask(req.CampaignName, req.Directory, time.Duration(req.IntervalSeconds)*time.Second)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Watching " + req.Directory + " for " + req.CampaignName})
+}
+
 func handleGetScanStatus(c *gin.Context) {
 	isScanning, status := scanner.Manager.GetStatus()
 	c.JSON(http.StatusOK, gin.H{"isScanning": isScanning, "status": status})
@@ -342,9 +442,10 @@ func handleGetHosts(c *gin.Context) {
 	}
 	searchQuery := c.DefaultQuery("search", "")
 	filterQuery := c.DefaultQuery("filter", "all")
+	sortQuery := c.DefaultQuery("sort", "")
 	const pageSize = 21
 	offset := (page - 1) * pageSize
-	hosts, totalHosts, err := storage.GetHostsByCampaignPaginated(campaignID, pageSize, offset, searchQuery, filterQuery)
+	hosts, totalHosts, err := storage.GetHostsByCampaignPaginated(campaignID, pageSize, offset, searchQuery, filterQuery, sortQuery)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve hosts: " + err.Error()})
 		return
@@ -392,6 +493,11 @@ func handleGetHostCommunications(c *gin.Context) {
 		nodeID := strings.ReplaceAll(ip, ".", "_")
 		label := ip
 		tooltip := ip
+		if len(comm.Hostnames) > 0 {
+			// The first name keeps the node readable; the tooltip lists them all.
+			label = comm.Hostnames[0] + "\n" + ip
+			tooltip += "\n" + strings.Join(comm.Hostnames, "\n")
+		}
 		var locationParts []string
 		if comm.Geo != nil {
 			if comm.Geo.City != "" {
@@ -458,3 +564,23 @@ func handleGetScreenshot(c *gin.Context) {
 
 	c.Data(http.StatusOK, "image/png", imageData)
 }
+
+// handleGetCarvedFile serves a carved file as a download. It is never rendered inline, since
+// captured content is untrusted.
+func handleGetCarvedFile(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Invalid file ID.")
+		return
+	}
+
+	file, err := storage.GetCarvedFileByID(id)
+	if err != nil {
+		c.String(http.StatusNotFound, "File not found.")
+		return
+	}
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": safeFilename(file.Filename)}))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, "application/octet-stream", file.Data)
+}
@@ -35,6 +35,11 @@ func setupTestRouter(t *testing.T) *gin.Engine {
 	api := router.Group("/api")
 	{
 		api.GET("/screenshot/:id", handleGetScreenshot)
+		api.PUT("/campaign/:campaignID/findings/:findingID", handleUpdateFindingTriage)
+		api.POST("/campaign/:campaignID/findings/:findingID/notes", handleAddFindingNote)
+		api.GET("/campaign/:campaignID/suppressions", handleGetSuppressionRules)
+		api.POST("/campaign/:campaignID/suppressions", handleCreateSuppressionRule)
+		api.DELETE("/campaign/:campaignID/suppressions/:ruleID", handleDeleteSuppressionRule)
 	}
 
 	return router
@@ -85,3 +90,54 @@ func TestGetScreenshotAPI(t *testing.T) {
 		t.Errorf("Handler returned wrong content type: got %s want %s", ctype, expectedContentType)
 	}
 }
+
+func TestFindingTriageAPI(t *testing.T) {
+	router := setupTestRouter(t)
+
+	campaignID, _ := storage.GetOrCreateCampaign("API Triage Test")
+	networkMap := model.NewNetworkMap()
+	host := model.NewHost("12:34:56:78:90:CD")
+	host.Ports[445] = model.Port{ID: 445, Protocol: "tcp", State: "open"}
+	host.Findings[model.CriticalFinding] = []model.Vulnerability{{CVE: "smb-vuln-ms17-010", State: "VULNERABLE", Category: model.CriticalFinding, PortID: 445}}
+	networkMap.Hosts[host.MACAddress] = host
+	if err := storage.SaveScanResults(campaignID, networkMap, &model.PcapSummary{}); err != nil {
+		t.Fatalf("Failed to save test data: %v", err)
+	}
+	var findingID int64
+	storage.DB.QueryRow("SELECT v.id FROM vulnerabilities v JOIN hosts h ON v.host_id = h.id WHERE h.campaign_id = ?", campaignID).Scan(&findingID)
+
+	for _, tc := range []struct {
+		method, path, body string
+		want               int
+	}{
+		{"PUT", fmt.Sprintf("/api/campaign/%d/findings/%d", campaignID, findingID), `{"status": "Confirmed", "assignee": "bob"}`, http.StatusOK},
+		{"PUT", fmt.Sprintf("/api/campaign/%d/findings/%d", campaignID, findingID), `{"status": "Done"}`, http.StatusBadRequest},
+		{"PUT", fmt.Sprintf("/api/campaign/%d/findings/%d", campaignID+1, findingID), `{"status": "Fixed"}`, http.StatusNotFound},
+		{"POST", fmt.Sprintf("/api/campaign/%d/findings/%d/notes", campaignID, findingID), `{"author": "bob", "text": "Patch pending", "evidence": "CHG-1234"}`, http.StatusCreated},
+		{"POST", fmt.Sprintf("/api/campaign/%d/findings/%d/notes", campaignID, findingID), `{"text": " "}`, http.StatusBadRequest},
+		{"POST", fmt.Sprintf("/api/campaign/%d/suppressions", campaignID), `{"cve": "ssl-cert-expired", "port": 443}`, http.StatusCreated},
+		{"POST", fmt.Sprintf("/api/campaign/%d/suppressions", campaignID), `{"reason": "no ID"}`, http.StatusBadRequest},
+		{"POST", fmt.Sprintf("/api/campaign/%d/suppressions", campaignID), `{"cve": "ssl-cert-expired", "port": 70000}`, http.StatusBadRequest},
+		{"POST", fmt.Sprintf("/api/campaign/%d/suppressions", campaignID), `{"cve": "ssl-cert-expired", "port": -1}`, http.StatusBadRequest},
+		{"GET", fmt.Sprintf("/api/campaign/%d/suppressions", campaignID), "", http.StatusOK},
+		{"DELETE", fmt.Sprintf("/api/campaign/%d/suppressions/999", campaignID), "", http.StatusNotFound},
+	} {
+		req, _ := http.NewRequest(tc.method, tc.path, bytes.NewBufferString(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != tc.want {
+			t.Errorf("%s %s %s: got status %d, want %d (%s)", tc.method, tc.path, tc.body, rr.Code, tc.want, rr.Body.String())
+		}
+	}
+
+	var hostID int64
+	storage.DB.QueryRow("SELECT id FROM hosts WHERE campaign_id = ?", campaignID).Scan(&hostID)
+	saved, err := storage.GetHostByID(hostID, campaignID)
+	if err != nil {
+		t.Fatalf("GetHostByID failed: %v", err)
+	}
+	if v := saved.Findings[model.CriticalFinding][0]; v.TriageStatus != model.TriageConfirmed || v.Assignee != "bob" || len(v.Notes) != 1 || v.Notes[0].Evidence != "CHG-1234" {
+		t.Errorf("Unexpected finding %+v", v)
+	}
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"SnailsHell/model"
+	"SnailsHell/storage"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// triageErrorStatus maps a storage error to 404 for a finding or rule missing from the campaign.
+func triageErrorStatus(err error) int {
+	if errors.Is(err, sql.ErrNoRows) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// handleUpdateFindingTriage sets the triage status and assignee of a finding.
+func handleUpdateFindingTriage(c *gin.Context) {
+	campaignID, _ := strconv.ParseInt(c.Param("campaignID"), 10, 64)
+	findingID, err := strconv.ParseInt(c.Param("findingID"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid finding ID"})
+		return
+	}
+	var req struct {
+		Status   string `json:"status"`
+		Assignee string `json:"assignee"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if !model.ValidTriageStatus(req.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Status must be one of: " + strings.Join(model.TriageStatuses, ", ")})
+		return
+	}
+	if err := storage.UpdateFindingTriage(campaignID, findingID, req.Status, req.Assignee); err != nil {
+		c.JSON(triageErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Finding updated."})
+}
+
+// handleAddFindingNote adds a note, with optional evidence, to a finding.
+func handleAddFindingNote(c *gin.Context) {
+	campaignID, _ := strconv.ParseInt(c.Param("campaignID"), 10, 64)
+	findingID, err := strconv.ParseInt(c.Param("findingID"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid finding ID"})
+		return
+	}
+	var req struct {
+		Author   string `json:"author"`
+		Text     string `json:"text"`
+		Evidence string `json:"evidence"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Text) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A note needs text"})
+		return
+	}
+	note := model.FindingNote{Author: strings.TrimSpace(req.Author), Text: strings.TrimSpace(req.Text), Evidence: strings.TrimSpace(req.Evidence)}
+	noteID, err := storage.AddFindingNote(campaignID, findingID, note)
+	if err != nil {
+		c.JSON(triageErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"id": noteID})
+}
+
+// handleGetSuppressionRules lists the suppression rules of the campaign, oldest first.
+func handleGetSuppressionRules(c *gin.Context) {
+	campaignID, _ := strconv.ParseInt(c.Param("campaignID"), 10, 64)
+	rules, err := storage.GetSuppressionRules(campaignID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if rules == nil {
+		rules = []model.SuppressionRule{}
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+// handleCreateSuppressionRule adds a suppression rule to the campaign and applies it to the
+// campaign's untriaged findings.
+func handleCreateSuppressionRule(c *gin.Context) {
+	campaignID, _ := strconv.ParseInt(c.Param("campaignID"), 10, 64)
+	var req struct {
+		CVE    string `json:"cve"`
+		Host   string `json:"host"`
+		Port   int    `json:"port"`
+		Reason string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.CVE) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A suppression rule needs a CVE or check ID"})
+		return
+	}
+	if req.Port < 0 || req.Port > 65535 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Port must be between 0 (any port) and 65535"})
+		return
+	}
+	rule := model.SuppressionRule{CVE: req.CVE, Host: req.Host, Port: req.Port, Reason: strings.TrimSpace(req.Reason)}
+	ruleID, suppressed, err := storage.CreateSuppressionRule(campaignID, rule)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"id": ruleID, "suppressed": suppressed})
+}
+
+// handleDeleteSuppressionRule removes a suppression rule; the findings it suppressed become new again.
+func handleDeleteSuppressionRule(c *gin.Context) {
+	campaignID, _ := strconv.ParseInt(c.Param("campaignID"), 10, 64)
+	ruleID, err := strconv.ParseInt(c.Param("ruleID"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+		return
+	}
+	if err := storage.DeleteSuppressionRule(campaignID, ruleID); err != nil {
+		c.JSON(triageErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Suppression rule deleted."})
+}
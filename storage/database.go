@@ -7,6 +7,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"math"
 	"sort"
 	"strconv"
 	"strings"
@@ -18,7 +19,7 @@ import (
 var DB *sql.DB
 
 // LatestSchemaVersion defines the most recent schema version this application supports.
-const LatestSchemaVersion = 5
+const LatestSchemaVersion = 20
 
 // InitDB initializes the database connection and runs schema migrations.
 func InitDB(filepath string) error {
@@ -107,8 +108,21 @@ func migrateDB(db *sql.DB) error {
 	return tx.Commit()
 }
 
+// SaveOptions controls how SaveScanResultsWithOptions merges data into rows that already exist.
+type SaveOptions struct {
+	// Reprocess marks results re-read from sources that were saved before, such as a forced
+	// file re-ingest. Packet counts then keep the larger value instead of being added up.
+	Reprocess bool
+}
+
 // SaveScanResults intelligently saves or merges host data into the database.
 func SaveScanResults(campaignID int64, networkMap *model.NetworkMap, summary *model.PcapSummary) error {
+	return SaveScanResultsWithOptions(campaignID, networkMap, summary, SaveOptions{})
+}
+
+// SaveScanResultsWithOptions saves or merges host data into the database. Every table is keyed
+// on its natural key, so saving the same results again updates rows rather than adding new ones.
+func SaveScanResultsWithOptions(campaignID int64, networkMap *model.NetworkMap, summary *model.PcapSummary, opts SaveOptions) error {
 	tx, err := DB.Begin()
 	if err != nil {
 		return fmt.Errorf("could not begin database transaction: %w", err)
@@ -116,36 +130,206 @@ func SaveScanResults(campaignID int64, networkMap *model.NetworkMap, summary *mo
 	defer tx.Rollback()
 
 	// Prepare statements for reuse
-	hostInsertStmt, _ := tx.Prepare(`INSERT INTO hosts(campaign_id, mac_address, ip_address, os_guess, vendor, status, discovered_by, device_type, behavioral_clues) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	hostInsertStmt, _ := tx.Prepare(`INSERT INTO hosts(campaign_id, mac_address, ip_address, os_guess, vendor, status, discovered_by, device_type, behavioral_clues, distance, uptime_seconds, last_boot, srtt, rttvar, rto) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
 	defer hostInsertStmt.Close()
-	hostUpdateStmt, _ := tx.Prepare(`UPDATE hosts SET ip_address=?, os_guess=?, vendor=?, status=?, device_type=?, behavioral_clues=?, mac_address=? WHERE id=?`)
+	// Existing values are only overwritten when the new scan actually reported them, and a
+	// placeholder key ("IP:<addr>") never replaces a real MAC address.
+	hostUpdateStmt, _ := tx.Prepare(`UPDATE hosts SET ip_address=COALESCE(NULLIF(?, ''), ip_address), os_guess=COALESCE(NULLIF(?, ''), os_guess),
+		vendor=COALESCE(NULLIF(?, ''), vendor), status=COALESCE(NULLIF(?, ''), status), device_type=COALESCE(NULLIF(?, ''), device_type),
+		behavioral_clues=COALESCE(NULLIF(?, ''), behavioral_clues), mac_address=CASE WHEN ? LIKE 'IP:%' THEN mac_address ELSE ? END,
+		distance=COALESCE(NULLIF(?, 0), distance), uptime_seconds=COALESCE(NULLIF(?, 0), uptime_seconds), last_boot=COALESCE(NULLIF(?, ''), last_boot),
+		srtt=COALESCE(NULLIF(?, 0), srtt), rttvar=COALESCE(NULLIF(?, 0), rttvar), rto=COALESCE(NULLIF(?, 0), rto) WHERE id=?`)
 	defer hostUpdateStmt.Close()
-	portStmt, _ := tx.Prepare(`INSERT INTO ports(host_id, port_number, protocol, state, service, version) VALUES(?, ?, ?, ?, ?, ?) ON CONFLICT(host_id, port_number, protocol) DO UPDATE SET state=excluded.state, service=excluded.service, version=excluded.version;`)
+	portStmt, _ := tx.Prepare(`INSERT INTO ports(host_id, port_number, protocol, state, service, version, extra_info, os_type, tunnel, cpe) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT(host_id, port_number, protocol) DO UPDATE SET state=excluded.state,
+		service=COALESCE(NULLIF(excluded.service, ''), service), version=COALESCE(NULLIF(TRIM(excluded.version), ''), version), extra_info=COALESCE(NULLIF(excluded.extra_info, ''), extra_info),
+		os_type=COALESCE(NULLIF(excluded.os_type, ''), os_type), tunnel=COALESCE(NULLIF(excluded.tunnel, ''), tunnel), cpe=COALESCE(NULLIF(excluded.cpe, ''), cpe) RETURNING id;`)
 	defer portStmt.Close()
-	vulnStmt, _ := tx.Prepare(`INSERT INTO vulnerabilities(host_id, port_id, cve, description, state, category) VALUES(?, ?, ?, ?, ?, ?);`)
+	hostnameStmt, _ := tx.Prepare(`INSERT INTO hostnames(host_id, name, type) VALUES(?, ?, ?) ON CONFLICT(host_id, name) DO UPDATE SET type=excluded.type;`)
+	defer hostnameStmt.Close()
+	osMatchStmt, _ := tx.Prepare(`INSERT INTO os_matches(host_id, name, accuracy, os_family, os_gen, vendor, device_type, cpe) VALUES(?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT(host_id, name) DO UPDATE SET accuracy=excluded.accuracy, os_family=excluded.os_family, os_gen=excluded.os_gen, vendor=excluded.vendor, device_type=excluded.device_type, cpe=excluded.cpe;`)
+	defer osMatchStmt.Close()
+	traceHopStmt, _ := tx.Prepare(`INSERT INTO traceroute_hops(host_id, ttl, ip_address, rtt, hostname) VALUES(?, ?, ?, ?, ?) ON CONFLICT(host_id, ttl) DO UPDATE SET ip_address=excluded.ip_address, rtt=excluded.rtt, hostname=excluded.hostname;`)
+	defer traceHopStmt.Close()
+	traceClearStmt, _ := tx.Prepare(`DELETE FROM traceroute_hops WHERE host_id = ?`)
+	defer traceClearStmt.Close()
+	scanRunStmt, _ := tx.Prepare(`INSERT INTO scan_runs(campaign_id, scanner, args, version, start_time, end_time, elapsed, summary, hosts_up, hosts_down, hosts_total, scan_info, source_file) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`)
+	defer scanRunStmt.Close()
+	vulnStmt, _ := tx.Prepare(`INSERT INTO vulnerabilities(host_id, port_id, cve, description, state, category, cvss, source, refs, evidence_file, evidence_packet) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(host_id, IFNULL(port_id, 0), cve, source) DO UPDATE SET description=excluded.description, state=excluded.state, category=excluded.category, cvss=excluded.cvss, refs=excluded.refs,
+			evidence_file=CASE WHEN evidence_file = '' THEN excluded.evidence_file ELSE evidence_file END,
+			evidence_packet=CASE WHEN evidence_file = '' THEN excluded.evidence_packet ELSE evidence_packet END;`)
 	defer vulnStmt.Close()
-	commStmt, _ := tx.Prepare(`INSERT INTO communications(host_id, counterpart_ip, packet_count, geo_country, geo_city, geo_isp) VALUES(?, ?, ?, ?, ?, ?);`)
+	packetCountMerge := "packet_count + excluded.packet_count"
+	if opts.Reprocess {
+		packetCountMerge = "MAX(packet_count, excluded.packet_count)"
+	}
+	commStmt, _ := tx.Prepare(`INSERT INTO communications(host_id, counterpart_ip, packet_count, geo_country, geo_city, geo_isp) VALUES(?, ?, ?, ?, ?, ?)
+		ON CONFLICT(host_id, counterpart_ip) DO UPDATE SET packet_count=` + packetCountMerge + `, geo_country=COALESCE(NULLIF(excluded.geo_country, ''), geo_country),
+		geo_city=COALESCE(NULLIF(excluded.geo_city, ''), geo_city), geo_isp=COALESCE(NULLIF(excluded.geo_isp, ''), geo_isp) RETURNING id;`)
 	defer commStmt.Close()
 	dnsStmt, _ := tx.Prepare(`INSERT OR IGNORE INTO dns_lookups(host_id, domain) VALUES(?, ?);`)
 	defer dnsStmt.Close()
-	handshakeStmt, _ := tx.Prepare(`INSERT INTO handshakes(campaign_id, ap_mac, client_mac, ssid, state, pcap_file, hccapx_data) VALUES (?, ?, ?, ?, ?, ?, ?);`)
+	handshakeStmt, _ := tx.Prepare(`INSERT INTO handshakes(campaign_id, ap_mac, client_mac, ssid, state, pcap_file, hccapx_data) VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(campaign_id, ap_mac, client_mac, pcap_file) DO UPDATE SET ssid=COALESCE(NULLIF(excluded.ssid, ''), ssid), state=excluded.state, hccapx_data=COALESCE(excluded.hccapx_data, hccapx_data);`)
 	defer handshakeStmt.Close()
-	credentialStmt, _ := tx.Prepare(`INSERT INTO credentials(campaign_id, host_id, endpoint, type, value, pcap_file) VALUES (?, ?, ?, ?, ?, ?);`)
+	credentialStmt, _ := tx.Prepare(`INSERT INTO credentials(campaign_id, host_id, endpoint, type, value, pcap_file) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(host_id, endpoint, type, value) DO NOTHING;`)
 	defer credentialStmt.Close()
-	webResponseStmt, _ := tx.Prepare(`INSERT INTO web_responses(host_id, port_id, method, status_code, headers) VALUES (?, ?, ?, ?, ?);`)
+	carvedFileStmt, _ := tx.Prepare(`INSERT INTO carved_files(campaign_id, host_id, filename, mime_type, size, md5, sha1, sha256, protocol, source_ip, destination_ip,
+		timestamp, pcap_file, data) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(campaign_id, sha256, protocol, filename, source_ip, destination_ip) DO NOTHING;`)
+	defer carvedFileStmt.Close()
+	webResponseStmt, _ := tx.Prepare(`INSERT INTO web_responses(host_id, port_id, method, status_code, headers) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(port_id, method) DO UPDATE SET status_code=excluded.status_code, headers=excluded.headers;`)
 	defer webResponseStmt.Close()
 	screenshotStmt, _ := tx.Prepare(`INSERT INTO screenshots(host_id, port_id, image_data, capture_time) VALUES (?, ?, ?, ?);`)
 	defer screenshotStmt.Close()
-	ftpResultStmt, _ := tx.Prepare(`INSERT INTO ftp_results(host_id, port_id, address, status, error, anonymous_login_possible, current_dir, directory_listing) VALUES (?, ?, ?, ?, ?, ?, ?, ?);`)
+	ftpResultStmt, _ := tx.Prepare(`INSERT INTO ftp_results(host_id, port_id, address, status, error, anonymous_login_possible, current_dir, directory_listing) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(port_id) DO UPDATE SET address=excluded.address, status=excluded.status, error=excluded.error,
+		anonymous_login_possible=excluded.anonymous_login_possible, current_dir=excluded.current_dir, directory_listing=excluded.directory_listing;`)
 	defer ftpResultStmt.Close()
-	sshResultStmt, _ := tx.Prepare(`INSERT INTO ssh_results(host_id, port_id, address, user, status, error, successful, output) VALUES (?, ?, ?, ?, ?, ?, ?, ?);`)
+	sshResultStmt, _ := tx.Prepare(`INSERT INTO ssh_results(host_id, port_id, address, user, status, error, successful, output) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(port_id, user) DO UPDATE SET address=excluded.address, status=excluded.status, error=excluded.error, successful=excluded.successful, output=excluded.output;`)
 	defer sshResultStmt.Close()
-	smbResultStmt, _ := tx.Prepare(`INSERT INTO smb_results(host_id, port_id, address, status, error, successful, shares) VALUES (?, ?, ?, ?, ?, ?, ?);`)
+	smbResultStmt, _ := tx.Prepare(`INSERT INTO smb_results(host_id, port_id, address, status, error, successful, shares) VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(port_id) DO UPDATE SET address=excluded.address, status=excluded.status, error=excluded.error, successful=excluded.successful, shares=excluded.shares;`)
 	defer smbResultStmt.Close()
+	beaconMerge, frameMerge := "beacons + excluded.beacons", "frames + excluded.frames"
+	if opts.Reprocess {
+		beaconMerge, frameMerge = "MAX(beacons, excluded.beacons)", "MAX(frames, excluded.frames)"
+	}
+	// Signal strengths are negative dBm values where 0 means unknown, so the strongest reading is
+	// the largest non-zero one.
+	accessPointStmt, _ := tx.Prepare(`INSERT INTO wifi_access_points(campaign_id, bssid, ssid, vendor, channel, frequency, encryption, akm, ciphers, wps, rssi, beacons, first_seen, last_seen)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(campaign_id, bssid) DO UPDATE SET ssid=COALESCE(NULLIF(excluded.ssid, ''), ssid), vendor=COALESCE(NULLIF(excluded.vendor, ''), vendor),
+		channel=COALESCE(NULLIF(excluded.channel, 0), channel), frequency=COALESCE(NULLIF(excluded.frequency, 0), frequency),
+		encryption=COALESCE(NULLIF(excluded.encryption, ''), encryption), akm=COALESCE(NULLIF(excluded.akm, ''), akm), ciphers=COALESCE(NULLIF(excluded.ciphers, ''), ciphers),
+		wps=MAX(wps, excluded.wps), rssi=CASE WHEN rssi = 0 THEN excluded.rssi WHEN excluded.rssi = 0 THEN rssi ELSE MAX(rssi, excluded.rssi) END, beacons=` + beaconMerge + `,
+		first_seen=MIN(COALESCE(first_seen, excluded.first_seen), COALESCE(excluded.first_seen, first_seen)),
+		last_seen=MAX(COALESCE(last_seen, excluded.last_seen), COALESCE(excluded.last_seen, last_seen));`)
+	defer accessPointStmt.Close()
+	wirelessClientStmt, _ := tx.Prepare(`INSERT INTO wifi_clients(campaign_id, mac_address, vendor, associated_bssid, rssi, frames, first_seen, last_seen) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(campaign_id, mac_address) DO UPDATE SET vendor=COALESCE(NULLIF(excluded.vendor, ''), vendor), associated_bssid=COALESCE(NULLIF(excluded.associated_bssid, ''), associated_bssid),
+		rssi=CASE WHEN rssi = 0 THEN excluded.rssi WHEN excluded.rssi = 0 THEN rssi ELSE MAX(rssi, excluded.rssi) END, frames=` + frameMerge + `,
+		first_seen=MIN(COALESCE(first_seen, excluded.first_seen), COALESCE(excluded.first_seen, first_seen)),
+		last_seen=MAX(COALESCE(last_seen, excluded.last_seen), COALESCE(excluded.last_seen, last_seen)) RETURNING id;`)
+	defer wirelessClientStmt.Close()
+	probeStmt, _ := tx.Prepare(`INSERT OR IGNORE INTO wifi_probes(client_id, ssid) VALUES (?, ?);`)
+	defer probeStmt.Close()
+	deauthMerge, disassocMerge, reasonMerge := "deauth_count + excluded.deauth_count", "disassoc_count + excluded.disassoc_count", "frame_count + excluded.frame_count"
+	if opts.Reprocess {
+		deauthMerge, disassocMerge, reasonMerge = "MAX(deauth_count, excluded.deauth_count)", "MAX(disassoc_count, excluded.disassoc_count)", "MAX(frame_count, excluded.frame_count)"
+	}
+	// A captured handshake is never downgraded by a later capture that only saw part of one.
+	deauthPairStmt, _ := tx.Prepare(`INSERT INTO deauth_pairs(campaign_id, ap_mac, client_mac, ssid, deauth_count, disassoc_count, first_seen, last_seen, reconnected_at, handshake_state, pcap_file)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(campaign_id, ap_mac, client_mac) DO UPDATE SET ssid=COALESCE(NULLIF(excluded.ssid, ''), ssid), deauth_count=` + deauthMerge + `, disassoc_count=` + disassocMerge + `,
+		first_seen=MIN(COALESCE(first_seen, excluded.first_seen), COALESCE(excluded.first_seen, first_seen)),
+		last_seen=MAX(COALESCE(last_seen, excluded.last_seen), COALESCE(excluded.last_seen, last_seen)),
+		reconnected_at=COALESCE(reconnected_at, excluded.reconnected_at),
+		handshake_state=CASE WHEN handshake_state = 'Full' OR excluded.handshake_state = '' THEN handshake_state ELSE excluded.handshake_state END,
+		pcap_file=excluded.pcap_file RETURNING id;`)
+	defer deauthPairStmt.Close()
+	connectionMerge := "connections + excluded.connections"
+	if opts.Reprocess {
+		connectionMerge = "MAX(connections, excluded.connections)"
+	}
+	// Handshakes whose server answer was not captured leave the negotiated fields empty, so they
+	// never clear what an earlier capture recorded.
+	tlsSessionStmt, _ := tx.Prepare(`INSERT INTO tls_sessions(host_id, client_ip, server_ip, server_port, sni, alpn, version, cipher, ja3, ja3s, ja4,
+		cert_subject, cert_issuer, cert_sans, cert_not_before, cert_not_after, cert_self_signed, cert_sha256, connections, first_seen, last_seen, pcap_file)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(host_id, client_ip, server_ip, server_port, sni, ja3) DO UPDATE SET alpn=COALESCE(NULLIF(excluded.alpn, ''), alpn),
+		version=COALESCE(NULLIF(excluded.version, ''), version), cipher=COALESCE(NULLIF(excluded.cipher, ''), cipher),
+		ja3s=COALESCE(NULLIF(excluded.ja3s, ''), ja3s), ja4=COALESCE(NULLIF(excluded.ja4, ''), ja4),
+		cert_subject=CASE WHEN excluded.cert_sha256 = '' THEN cert_subject ELSE excluded.cert_subject END,
+		cert_issuer=CASE WHEN excluded.cert_sha256 = '' THEN cert_issuer ELSE excluded.cert_issuer END,
+		cert_sans=CASE WHEN excluded.cert_sha256 = '' THEN cert_sans ELSE excluded.cert_sans END,
+		cert_not_before=CASE WHEN excluded.cert_sha256 = '' THEN cert_not_before ELSE excluded.cert_not_before END,
+		cert_not_after=CASE WHEN excluded.cert_sha256 = '' THEN cert_not_after ELSE excluded.cert_not_after END,
+		cert_self_signed=CASE WHEN excluded.cert_sha256 = '' THEN cert_self_signed ELSE excluded.cert_self_signed END,
+		cert_sha256=COALESCE(NULLIF(excluded.cert_sha256, ''), cert_sha256), connections=` + connectionMerge + `,
+		first_seen=MIN(COALESCE(first_seen, excluded.first_seen), COALESCE(excluded.first_seen, first_seen)),
+		last_seen=MAX(COALESCE(last_seen, excluded.last_seen), COALESCE(excluded.last_seen, last_seen)), pcap_file=excluded.pcap_file;`)
+	defer tlsSessionStmt.Close()
+	// Transactions are identified by their connection, where in it and when they were requested, so
+	// re-importing a capture adds nothing while identical pipelined requests are all kept.
+	httpStmt, _ := tx.Prepare(`INSERT INTO http_transactions(host_id, communication_id, client_ip, client_port, server_ip, server_port, stream_offset, method, host, uri,
+		status_code, user_agent, server, content_type, request_size, response_size, timestamp, pcap_file) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(host_id, client_ip, client_port, server_ip, server_port, timestamp, stream_offset, method, uri) DO NOTHING;`)
+	defer httpStmt.Close()
+	answerCountMerge := "count + excluded.count"
+	if opts.Reprocess {
+		answerCountMerge = "MAX(count, excluded.count)"
+	}
+	dnsAnswerStmt, _ := tx.Prepare(`INSERT INTO dns_answers(host_id, name, type, answer, ttl, resolver_ip, count, first_seen, last_seen) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(host_id, name, type, answer) DO UPDATE SET ttl=excluded.ttl, resolver_ip=COALESCE(NULLIF(excluded.resolver_ip, ''), resolver_ip), count=` + answerCountMerge + `,
+		first_seen=MIN(COALESCE(first_seen, excluded.first_seen), COALESCE(excluded.first_seen, first_seen)),
+		last_seen=MAX(COALESCE(last_seen, excluded.last_seen), COALESCE(excluded.last_seen, last_seen));`)
+	defer dnsAnswerStmt.Close()
+	// The average response time is weighted by the number of timed responses on each side.
+	resolverMerge := `queries=queries + excluded.queries, responses=responses + excluded.responses, nxdomain=nxdomain + excluded.nxdomain,
+		connections=connections + excluded.connections, timed_responses=timed_responses + excluded.timed_responses,
+		avg_response_ms=CASE WHEN timed_responses + excluded.timed_responses = 0 THEN 0
+			ELSE (avg_response_ms * timed_responses + excluded.avg_response_ms * excluded.timed_responses) / (timed_responses + excluded.timed_responses) END`
+	if opts.Reprocess {
+		resolverMerge = `queries=MAX(queries, excluded.queries), responses=MAX(responses, excluded.responses), nxdomain=MAX(nxdomain, excluded.nxdomain),
+		connections=MAX(connections, excluded.connections), timed_responses=MAX(timed_responses, excluded.timed_responses),
+		avg_response_ms=CASE WHEN excluded.timed_responses >= timed_responses THEN excluded.avg_response_ms ELSE avg_response_ms END`
+	}
+	dnsResolverStmt, _ := tx.Prepare(`INSERT INTO dns_resolvers(host_id, resolver_ip, protocol, hostname, queries, responses, nxdomain, connections, avg_response_ms, timed_responses,
+		first_seen, last_seen) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(host_id, protocol, resolver_ip) DO UPDATE SET hostname=COALESCE(NULLIF(excluded.hostname, ''), hostname), ` + resolverMerge + `,
+		first_seen=MIN(COALESCE(first_seen, excluded.first_seen), COALESCE(excluded.first_seen, first_seen)),
+		last_seen=MAX(COALESCE(last_seen, excluded.last_seen), COALESCE(excluded.last_seen, last_seen));`)
+	defer dnsResolverStmt.Close()
+	otCountMerge := "count + excluded.count"
+	if opts.Reprocess {
+		otCountMerge = "MAX(count, excluded.count)"
+	}
+	otOperationStmt, _ := tx.Prepare(`INSERT INTO ot_operations(host_id, protocol, role, peer, port, unit_id, function, operation, count, first_seen, last_seen) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(host_id, protocol, role, peer, unit_id, function) DO UPDATE SET port=excluded.port, operation=excluded.operation, count=` + otCountMerge + `,
+		first_seen=MIN(COALESCE(first_seen, excluded.first_seen), COALESCE(excluded.first_seen, first_seen)),
+		last_seen=MAX(COALESCE(last_seen, excluded.last_seen), COALESCE(excluded.last_seen, last_seen));`)
+	defer otOperationStmt.Close()
+	otIdentityStmt, _ := tx.Prepare(`INSERT INTO ot_identities(host_id, protocol, identity) VALUES (?, ?, ?) ON CONFLICT(host_id, protocol) DO UPDATE SET identity=excluded.identity;`)
+	defer otIdentityStmt.Close()
+	deauthReasonStmt, _ := tx.Prepare(`INSERT INTO deauth_reasons(pair_id, reason, frame_count) VALUES (?, ?, ?)
+		ON CONFLICT(pair_id, reason) DO UPDATE SET frame_count=` + reasonMerge + `;`)
+	defer deauthReasonStmt.Close()
+
+	// A re-processed data file replaces the runs previously imported from it. Runs that did not
+	// come from a file, such as live Nmap scans, replace an earlier save of the same run instead.
+	replacedSources := make(map[string]bool)
+	for _, run := range networkMap.ScanRuns {
+		if run.SourceFile == "" && !run.StartTime.IsZero() {
+			if _, err := tx.Exec("DELETE FROM scan_runs WHERE campaign_id = ? AND source_file = '' AND scanner = ? AND start_time = ? AND args = ?",
+				campaignID, run.Scanner, run.StartTime, run.Args); err != nil {
+				return fmt.Errorf("could not replace scan run %s: %w", run.Args, err)
+			}
+		}
+		if run.SourceFile == "" || replacedSources[run.SourceFile] {
+			continue
+		}
+		replacedSources[run.SourceFile] = true
+		if _, err := tx.Exec("DELETE FROM scan_runs WHERE campaign_id = ? AND source_file = ?", campaignID, run.SourceFile); err != nil {
+			return fmt.Errorf("could not replace scan runs from %s: %w", run.SourceFile, err)
+		}
+	}
+	for _, run := range networkMap.ScanRuns {
+		scanInfoJSON, _ := json.Marshal(run.ScanInfo)
+		_, err := scanRunStmt.Exec(campaignID, run.Scanner, run.Args, run.Version, run.StartTime, run.EndTime, run.Elapsed, run.Summary, run.HostsUp, run.HostsDown, run.HostsTotal, string(scanInfoJSON), run.SourceFile)
+		if err != nil {
+			return fmt.Errorf("could not save scan run: %w", err)
+		}
+	}
 
 	for _, host := range networkMap.Hosts {
 		var hostID int64
 		var mainIP, vendor, osGuess, deviceType, clues string
+		var uptimeSeconds, srtt, rttVar, rto int64
+		var lastBoot string
 
 		// Extract primary IP and fingerprint data from the host model
 		if len(host.IPv4Addresses) > 0 {
@@ -164,6 +348,12 @@ func SaveScanResults(campaignID int64, networkMap *model.NetworkMap, summary *mo
 			}
 			clues = strings.Join(clueList, ", ")
 		}
+		if host.Uptime != nil {
+			uptimeSeconds, lastBoot = host.Uptime.Seconds, host.Uptime.LastBoot
+		}
+		if host.Timing != nil {
+			srtt, rttVar, rto = host.Timing.SRTT, host.Timing.RTTVar, host.Timing.Timeout
+		}
 
 		// --- Intelligent Host Merging Logic ---
 		var existingHostID int64
@@ -184,13 +374,13 @@ func SaveScanResults(campaignID int64, networkMap *model.NetworkMap, summary *mo
 		if existingHostID != 0 {
 			// **UPDATE/MERGE**: We found an existing host. Update it with new info.
 			hostID = existingHostID
-			_, err = hostUpdateStmt.Exec(mainIP, osGuess, vendor, host.Status, deviceType, clues, host.MACAddress, hostID)
+			_, err = hostUpdateStmt.Exec(mainIP, osGuess, vendor, host.Status, deviceType, clues, host.MACAddress, host.MACAddress, host.Distance, uptimeSeconds, lastBoot, srtt, rttVar, rto, hostID)
 			if err != nil {
 				return fmt.Errorf("could not update host %d: %w", hostID, err)
 			}
 		} else {
 			// **INSERT**: This is a new host. Insert it.
-			res, err := hostInsertStmt.Exec(campaignID, host.MACAddress, mainIP, osGuess, vendor, host.Status, host.DiscoveredBy, deviceType, clues)
+			res, err := hostInsertStmt.Exec(campaignID, host.MACAddress, mainIP, osGuess, vendor, host.Status, host.DiscoveredBy, deviceType, clues, host.Distance, uptimeSeconds, lastBoot, srtt, rttVar, rto)
 			if err != nil {
 				return fmt.Errorf("could not insert host %s: %w", host.MACAddress, err)
 			}
@@ -201,18 +391,38 @@ func SaveScanResults(campaignID int64, networkMap *model.NetworkMap, summary *mo
 
 		portNumberToDBID := make(map[int]int64)
 		for _, port := range host.Ports {
-			res, err := portStmt.Exec(hostID, port.ID, port.Protocol, port.State, port.Service, port.Version)
+			// RETURNING yields the row ID for both new and updated ports; LastInsertId is stale after an update.
+			var portDBID int64
+			err := portStmt.QueryRow(hostID, port.ID, port.Protocol, port.State, port.Service, port.Version, port.ExtraInfo, port.OSType, port.Tunnel, strings.Join(port.CPEs, "\n")).Scan(&portDBID)
 			if err != nil {
 				return fmt.Errorf("could not save port %d for host %d: %w", port.ID, hostID, err)
 			}
-			portDBID, err := res.LastInsertId()
-			if err != nil { // This happens on conflict/update
-				err = tx.QueryRow("SELECT id FROM ports WHERE host_id = ? AND port_number = ? AND protocol = ?", hostID, port.ID, port.Protocol).Scan(&portDBID)
+			portNumberToDBID[port.ID] = portDBID
+		}
+
+		for name, nameType := range host.Hostnames {
+			if _, err := hostnameStmt.Exec(hostID, name, nameType); err != nil {
+				return fmt.Errorf("could not save hostname %s for host %d: %w", name, hostID, err)
+			}
+		}
+		if host.Fingerprint != nil {
+			for _, match := range host.Fingerprint.OSMatches {
+				_, err := osMatchStmt.Exec(hostID, match.Name, match.Accuracy, match.Family, match.Generation, match.Vendor, match.DeviceType, strings.Join(match.CPEs, "\n"))
 				if err != nil {
-					return fmt.Errorf("could not get existing port ID for port %d: %w", port.ID, err)
+					return fmt.Errorf("could not save OS match for host %d: %w", hostID, err)
 				}
 			}
-			portNumberToDBID[port.ID] = portDBID
+		}
+		// A new trace replaces the previous one, which may have been longer.
+		if len(host.Traceroute) > 0 {
+			if _, err := traceClearStmt.Exec(hostID); err != nil {
+				return fmt.Errorf("could not replace traceroute for host %d: %w", hostID, err)
+			}
+		}
+		for _, hop := range host.Traceroute {
+			if _, err := traceHopStmt.Exec(hostID, hop.TTL, hop.IP, hop.RTT, hop.Hostname); err != nil {
+				return fmt.Errorf("could not save traceroute hop %d for host %d: %w", hop.TTL, hostID, err)
+			}
 		}
 
 		for _, findingList := range host.Findings {
@@ -223,22 +433,25 @@ func SaveScanResults(campaignID int64, networkMap *model.NetworkMap, summary *mo
 						portDBID = sql.NullInt64{Int64: id, Valid: true}
 					}
 				}
-				_, err := vulnStmt.Exec(hostID, portDBID, vuln.CVE, vuln.Description, vuln.State, vuln.Category)
+				_, err := vulnStmt.Exec(hostID, portDBID, vuln.CVE, vuln.Description, vuln.State, vuln.Category, vuln.CVSS, vuln.Source, strings.Join(vuln.References, "\n"), vuln.EvidenceFile, vuln.EvidencePacket)
 				if err != nil {
 					return fmt.Errorf("could not save vulnerability for host %d: %w", hostID, err)
 				}
 			}
 		}
 
+		commIDs := make(map[string]int64, len(host.Communications))
 		for _, comm := range host.Communications {
 			var country, city, isp string
 			if comm.Geo != nil {
 				country, city, isp = comm.Geo.Country, comm.Geo.City, comm.Geo.ISP
 			}
-			_, err := commStmt.Exec(hostID, comm.CounterpartIP, comm.PacketCount, country, city, isp)
+			var commID int64
+			err := commStmt.QueryRow(hostID, comm.CounterpartIP, comm.PacketCount, country, city, isp).Scan(&commID)
 			if err != nil {
 				return fmt.Errorf("could not save communication for host %d: %w", hostID, err)
 			}
+			commIDs[comm.CounterpartIP] = commID
 		}
 		for domain := range host.DNSLookups {
 			_, err := dnsStmt.Exec(hostID, domain)
@@ -246,6 +459,56 @@ func SaveScanResults(campaignID int64, networkMap *model.NetworkMap, summary *mo
 				return fmt.Errorf("could not save DNS lookup for host %d: %w", hostID, err)
 			}
 		}
+		for _, answer := range host.DNSAnswers {
+			_, err := dnsAnswerStmt.Exec(hostID, answer.Name, answer.Type, answer.Answer, answer.TTL, answer.ResolverIP, answer.Count,
+				nullTime(answer.FirstSeen), nullTime(answer.LastSeen))
+			if err != nil {
+				return fmt.Errorf("could not save DNS answer for host %d: %w", hostID, err)
+			}
+		}
+		for _, r := range host.DNSResolvers {
+			_, err := dnsResolverStmt.Exec(hostID, r.IP, r.Protocol, r.Hostname, r.Queries, r.Responses, r.NXDomain, r.Connections, r.AvgResponseMs,
+				r.TimedResponses, nullTime(r.FirstSeen), nullTime(r.LastSeen))
+			if err != nil {
+				return fmt.Errorf("could not save DNS resolver for host %d: %w", hostID, err)
+			}
+		}
+		for _, op := range host.OTOperations {
+			_, err := otOperationStmt.Exec(hostID, op.Protocol, op.Role, op.Peer, op.Port, op.UnitID, op.Function, op.Operation, op.Count,
+				nullTime(op.FirstSeen), nullTime(op.LastSeen))
+			if err != nil {
+				return fmt.Errorf("could not save OT operation for host %d: %w", hostID, err)
+			}
+		}
+		for protocol, identity := range host.OTIdentities {
+			if _, err := otIdentityStmt.Exec(hostID, protocol, identity); err != nil {
+				return fmt.Errorf("could not save OT identity for host %d: %w", hostID, err)
+			}
+		}
+		for _, session := range host.TLSSessions {
+			var cert model.TLSCertificate
+			if session.Certificate != nil {
+				cert = *session.Certificate
+			}
+			_, err := tlsSessionStmt.Exec(hostID, session.ClientIP, session.ServerIP, session.ServerPort, session.SNI, session.ALPN, session.Version, session.Cipher,
+				session.JA3, session.JA3S, session.JA4, cert.Subject, cert.Issuer, strings.Join(cert.SANs, "\n"), nullTime(cert.NotBefore), nullTime(cert.NotAfter),
+				cert.SelfSigned, cert.SHA256, session.Connections, nullTime(session.FirstSeen), nullTime(session.LastSeen), session.PcapFile)
+			if err != nil {
+				return fmt.Errorf("could not save TLS session for host %d: %w", hostID, err)
+			}
+		}
+		for _, t := range host.HTTPRequests {
+			// The communication with the other end of the transaction, from this host's side.
+			commID, ok := commIDs[t.ServerIP]
+			if !ok {
+				commID, ok = commIDs[t.ClientIP]
+			}
+			_, err := httpStmt.Exec(hostID, sql.NullInt64{Int64: commID, Valid: ok}, t.ClientIP, t.ClientPort, t.ServerIP, t.ServerPort, t.StreamOffset, t.Method, t.Host, t.URI,
+				t.StatusCode, t.UserAgent, t.Server, t.ContentType, t.RequestSize, t.ResponseSize, nullTime(t.Timestamp), t.PcapFile)
+			if err != nil {
+				return fmt.Errorf("could not save HTTP transaction for host %d: %w", hostID, err)
+			}
+		}
 		for _, webResponse := range host.WebResponses {
 			portDBID, ok := portNumberToDBID[webResponse.PortID]
 			if !ok {
@@ -321,7 +584,59 @@ func SaveScanResults(campaignID int64, networkMap *model.NetworkMap, summary *mo
 		}
 	}
 
-	return tx.Commit()
+	for _, file := range summary.CarvedFiles {
+		// Files are kept even when the host they were seen on is not in the campaign.
+		var hostID sql.NullInt64
+		if err := tx.QueryRow("SELECT id FROM hosts WHERE campaign_id = ? AND mac_address = ?", campaignID, file.HostMAC).Scan(&hostID); err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("could not look up host for carved file %s: %w", file.Filename, err)
+		}
+		_, err := carvedFileStmt.Exec(campaignID, hostID, file.Filename, file.MIMEType, file.Size, file.MD5, file.SHA1, file.SHA256, file.Protocol,
+			file.SourceIP, file.DestinationIP, nullTime(file.Timestamp), file.PcapFile, file.Data)
+		if err != nil {
+			return fmt.Errorf("could not save carved file %s: %w", file.Filename, err)
+		}
+	}
+
+	for _, ap := range summary.AccessPoints {
+		_, err := accessPointStmt.Exec(campaignID, ap.BSSID, ap.SSID, ap.Vendor, ap.Channel, ap.Frequency, ap.Encryption, ap.AKM, ap.Ciphers, ap.WPS, ap.RSSI, ap.Beacons, nullTime(ap.FirstSeen), nullTime(ap.LastSeen))
+		if err != nil {
+			return fmt.Errorf("could not save access point %s: %w", ap.BSSID, err)
+		}
+	}
+	for _, client := range summary.WirelessClients {
+		var clientID int64
+		err := wirelessClientStmt.QueryRow(campaignID, client.MAC, client.Vendor, client.AssociatedBSSID, client.RSSI, client.Frames, nullTime(client.FirstSeen), nullTime(client.LastSeen)).Scan(&clientID)
+		if err != nil {
+			return fmt.Errorf("could not save wireless client %s: %w", client.MAC, err)
+		}
+		for ssid := range client.ProbedSSIDs {
+			if _, err := probeStmt.Exec(clientID, ssid); err != nil {
+				return fmt.Errorf("could not save probe request for %s: %w", client.MAC, err)
+			}
+		}
+	}
+	for _, pair := range summary.DeauthPairs {
+		var pairID int64
+		err := deauthPairStmt.QueryRow(campaignID, pair.APMAC, pair.ClientMAC, pair.SSID, pair.Deauths, pair.Disassocs, nullTime(pair.FirstSeen), nullTime(pair.LastSeen),
+			nullTime(pair.ReconnectedAt), pair.HandshakeState, pair.PcapFile).Scan(&pairID)
+		if err != nil {
+			return fmt.Errorf("could not save deauth activity for %s/%s: %w", pair.APMAC, pair.ClientMAC, err)
+		}
+		for reason, count := range pair.Reasons {
+			if _, err := deauthReasonStmt.Exec(pairID, reason, count); err != nil {
+				return fmt.Errorf("could not save deauth reason %d for %s/%s: %w", reason, pair.APMAC, pair.ClientMAC, err)
+			}
+		}
+	}
+
+	if _, err := applySuppressionRules(tx, campaignID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return UpdateRiskScores(campaignID)
 }
 
 // GetOrCreateCampaign gets the ID of a campaign by name, creating it if it doesn't exist.
@@ -355,14 +670,16 @@ func GetOrCreateCampaign(name string) (int64, error) {
 
 // HostInfo is a simplified struct for display in the UI.
 type HostInfo struct {
-	ID           int64  `json:"id"`
-	MACAddress   string `json:"mac_address"`
-	IPAddress    string `json:"ip_address"`
-	Vendor       string `json:"vendor"`
-	Status       string `json:"status"`
-	DiscoveredBy string `json:"discovered_by"`
-	DeviceType   string `json:"device_type"`
-	HasVulns     bool   `json:"has_vulns"`
+	ID           int64   `json:"id"`
+	MACAddress   string  `json:"mac_address"`
+	IPAddress    string  `json:"ip_address"`
+	Vendor       string  `json:"vendor"`
+	Status       string  `json:"status"`
+	DiscoveredBy string  `json:"discovered_by"`
+	DeviceType   string  `json:"device_type"`
+	HasVulns     bool    `json:"has_vulns"`
+	RiskScore    float64 `json:"risk_score"`
+	RiskLevel    string  `json:"risk_level"`
 }
 
 // ReportHostInfo is a more detailed struct for report generation.
@@ -375,6 +692,7 @@ type ReportHostInfo struct {
 	DeviceType string
 	Status     string
 	HasVulns   bool
+	RiskScore  float64
 }
 
 // CampaignInfo is a simple struct for listing campaigns.
@@ -407,12 +725,15 @@ func ListCampaigns() ([]CampaignInfo, error) {
 func GetHostByID(hostID int64, campaignID int64) (*model.Host, error) {
 	host := model.NewHost("")
 	host.ID = hostID
-	var ipAddress, vendor, osGuess, deviceType, clues string
+	var ipAddress, vendor, osGuess, deviceType, clues, lastBoot string
+	var uptimeSeconds, srtt, rttVar, rto int64
 
 	err := DB.QueryRow(`
-		SELECT mac_address, ip_address, vendor, os_guess, status, device_type, behavioral_clues
+		SELECT mac_address, ip_address, vendor, os_guess, status, device_type, behavioral_clues,
+		       distance, uptime_seconds, last_boot, srtt, rttvar, rto
 		FROM hosts WHERE id = ? AND campaign_id = ?`, hostID, campaignID).Scan(
 		&host.MACAddress, &ipAddress, &vendor, &osGuess, &host.Status, &deviceType, &clues,
+		&host.Distance, &uptimeSeconds, &lastBoot, &srtt, &rttVar, &rto,
 	)
 
 	if err != nil {
@@ -433,8 +754,14 @@ func GetHostByID(hostID int64, campaignID int64) (*model.Host, error) {
 			host.Fingerprint.BehavioralClues[clue] = true
 		}
 	}
+	if uptimeSeconds > 0 {
+		host.Uptime = &model.Uptime{Seconds: uptimeSeconds, LastBoot: lastBoot}
+	}
+	if srtt > 0 || rto > 0 {
+		host.Timing = &model.HostTiming{SRTT: srtt, RTTVar: rttVar, Timeout: rto}
+	}
 
-	portRows, err := DB.Query("SELECT id, port_number, protocol, state, service, version FROM ports WHERE host_id = ?", hostID)
+	portRows, err := DB.Query("SELECT id, port_number, protocol, state, service, version, extra_info, os_type, tunnel, cpe FROM ports WHERE host_id = ?", hostID)
 	if err != nil {
 		return nil, fmt.Errorf("could not query ports for host %d: %w", hostID, err)
 	}
@@ -444,27 +771,67 @@ func GetHostByID(hostID int64, campaignID int64) (*model.Host, error) {
 	for portRows.Next() {
 		var p model.Port
 		var dbPortID int64
-		if err := portRows.Scan(&dbPortID, &p.ID, &p.Protocol, &p.State, &p.Service, &p.Version); err != nil {
+		var cpes string
+		if err := portRows.Scan(&dbPortID, &p.ID, &p.Protocol, &p.State, &p.Service, &p.Version, &p.ExtraInfo, &p.OSType, &p.Tunnel, &cpes); err != nil {
 			return nil, fmt.Errorf("could not scan port row for host %d: %w", hostID, err)
 		}
+		if cpes != "" {
+			p.CPEs = strings.Split(cpes, "\n")
+		}
 		host.Ports[p.ID] = p
 		portIDMap[dbPortID] = p.ID
 	}
 
-	vulnRows, err := DB.Query("SELECT port_id, cve, description, state, category FROM vulnerabilities WHERE host_id = ?", hostID)
+	vulnRows, err := DB.Query(`
+        SELECT v.id, v.port_id, v.cve, v.description, v.state, v.category, v.cvss, v.source, v.refs, v.evidence_file, v.evidence_packet, k.cve_id IS NOT NULL, COALESCE(e.epss, 0),
+            v.triage_status, v.assignee, COALESCE(v.suppression_id, 0)
+        FROM vulnerabilities v
+        LEFT JOIN kev_entries k ON k.cve_id = v.cve
+        LEFT JOIN epss_scores e ON e.cve_id = v.cve
+        WHERE v.host_id = ? ORDER BY v.cvss DESC`, hostID)
 	if err != nil {
 		return nil, fmt.Errorf("could not query vulnerabilities for host %d: %w", hostID, err)
 	}
 	defer vulnRows.Close()
+	var vulns []model.Vulnerability
 	for vulnRows.Next() {
 		var v model.Vulnerability
 		var portID sql.NullInt64
-		if err := vulnRows.Scan(&portID, &v.CVE, &v.Description, &v.State, &v.Category); err != nil {
+		var refs string
+		if err := vulnRows.Scan(&v.ID, &portID, &v.CVE, &v.Description, &v.State, &v.Category, &v.CVSS, &v.Source, &refs, &v.EvidenceFile, &v.EvidencePacket, &v.KnownExploited, &v.EPSS,
+			&v.TriageStatus, &v.Assignee, &v.SuppressionID); err != nil {
 			return nil, fmt.Errorf("could not scan vulnerability row for host %d: %w", hostID, err)
 		}
+		if refs != "" {
+			v.References = strings.Split(refs, "\n")
+		}
 		if portID.Valid {
 			v.PortID = portIDMap[portID.Int64]
 		}
+		vulns = append(vulns, v)
+	}
+
+	noteRows, err := DB.Query(`
+        SELECT n.id, n.vulnerability_id, n.author, n.text, n.evidence, n.created_at
+        FROM finding_notes n JOIN vulnerabilities v ON n.vulnerability_id = v.id
+        WHERE v.host_id = ? ORDER BY n.created_at, n.id`, hostID)
+	if err != nil {
+		return nil, fmt.Errorf("could not query finding notes for host %d: %w", hostID, err)
+	}
+	defer noteRows.Close()
+	notes := make(map[int64][]model.FindingNote)
+	for noteRows.Next() {
+		var n model.FindingNote
+		var vulnID int64
+		var createdAt sql.NullTime
+		if err := noteRows.Scan(&n.ID, &vulnID, &n.Author, &n.Text, &n.Evidence, &createdAt); err != nil {
+			return nil, fmt.Errorf("could not scan finding note for host %d: %w", hostID, err)
+		}
+		n.CreatedAt = createdAt.Time
+		notes[vulnID] = append(notes[vulnID], n)
+	}
+	for _, v := range vulns {
+		v.Notes = notes[v.ID]
 		host.Findings[v.Category] = append(host.Findings[v.Category], v)
 	}
 
@@ -502,6 +869,125 @@ func GetHostByID(hostID int64, campaignID int64) (*model.Host, error) {
 		host.DNSLookups[domain] = true
 	}
 
+	answerRows, err := DB.Query(`SELECT name, type, answer, ttl, resolver_ip, count, first_seen, last_seen FROM dns_answers WHERE host_id = ?`, hostID)
+	if err != nil {
+		return nil, fmt.Errorf("could not query DNS answers for host %d: %w", hostID, err)
+	}
+	defer answerRows.Close()
+	for answerRows.Next() {
+		var a model.DNSAnswer
+		var firstSeen, lastSeen sql.NullTime
+		if err := answerRows.Scan(&a.Name, &a.Type, &a.Answer, &a.TTL, &a.ResolverIP, &a.Count, &firstSeen, &lastSeen); err != nil {
+			return nil, fmt.Errorf("could not scan DNS answer row for host %d: %w", hostID, err)
+		}
+		a.FirstSeen, a.LastSeen = firstSeen.Time, lastSeen.Time
+		host.DNSAnswers[a.Key()] = &a
+	}
+
+	resolverRows, err := DB.Query(`SELECT resolver_ip, protocol, hostname, queries, responses, nxdomain, connections, avg_response_ms, timed_responses, first_seen, last_seen
+		FROM dns_resolvers WHERE host_id = ?`, hostID)
+	if err != nil {
+		return nil, fmt.Errorf("could not query DNS resolvers for host %d: %w", hostID, err)
+	}
+	defer resolverRows.Close()
+	for resolverRows.Next() {
+		var r model.DNSResolver
+		var firstSeen, lastSeen sql.NullTime
+		if err := resolverRows.Scan(&r.IP, &r.Protocol, &r.Hostname, &r.Queries, &r.Responses, &r.NXDomain, &r.Connections, &r.AvgResponseMs, &r.TimedResponses,
+			&firstSeen, &lastSeen); err != nil {
+			return nil, fmt.Errorf("could not scan DNS resolver row for host %d: %w", hostID, err)
+		}
+		r.FirstSeen, r.LastSeen = firstSeen.Time, lastSeen.Time
+		host.DNSResolvers[r.Key()] = &r
+	}
+
+	otRows, err := DB.Query(`SELECT protocol, role, peer, port, unit_id, function, operation, count, first_seen, last_seen FROM ot_operations WHERE host_id = ?`, hostID)
+	if err != nil {
+		return nil, fmt.Errorf("could not query OT operations for host %d: %w", hostID, err)
+	}
+	defer otRows.Close()
+	for otRows.Next() {
+		var op model.OTOperation
+		var firstSeen, lastSeen sql.NullTime
+		if err := otRows.Scan(&op.Protocol, &op.Role, &op.Peer, &op.Port, &op.UnitID, &op.Function, &op.Operation, &op.Count, &firstSeen, &lastSeen); err != nil {
+			return nil, fmt.Errorf("could not scan OT operation row for host %d: %w", hostID, err)
+		}
+		op.FirstSeen, op.LastSeen = firstSeen.Time, lastSeen.Time
+		host.OTOperations[op.Key()] = &op
+	}
+
+	identityRows, err := DB.Query(`SELECT protocol, identity FROM ot_identities WHERE host_id = ?`, hostID)
+	if err != nil {
+		return nil, fmt.Errorf("could not query OT identities for host %d: %w", hostID, err)
+	}
+	defer identityRows.Close()
+	for identityRows.Next() {
+		var protocol, identity string
+		if err := identityRows.Scan(&protocol, &identity); err != nil {
+			return nil, fmt.Errorf("could not scan OT identity row for host %d: %w", hostID, err)
+		}
+		host.OTIdentities[protocol] = identity
+	}
+
+	counterparts := make([]string, 0, len(host.Communications))
+	for ip := range host.Communications {
+		counterparts = append(counterparts, ip)
+	}
+	names, err := resolvedNames(campaignID, counterparts)
+	if err != nil {
+		return nil, err
+	}
+	for ip, comm := range host.Communications {
+		comm.Hostnames = names[ip]
+	}
+
+	tlsRows, err := DB.Query(`
+		SELECT id, client_ip, server_ip, server_port, sni, alpn, version, cipher, ja3, ja3s, ja4, cert_subject, cert_issuer, cert_sans,
+		       cert_not_before, cert_not_after, cert_self_signed, cert_sha256, connections, first_seen, last_seen, pcap_file
+		FROM tls_sessions WHERE host_id = ? ORDER BY server_ip, server_port, sni`, hostID)
+	if err != nil {
+		return nil, fmt.Errorf("could not query TLS sessions for host %d: %w", hostID, err)
+	}
+	defer tlsRows.Close()
+	for tlsRows.Next() {
+		var s model.TLSSession
+		var cert model.TLSCertificate
+		var sans string
+		var notBefore, notAfter, firstSeen, lastSeen sql.NullTime
+		if err := tlsRows.Scan(&s.ID, &s.ClientIP, &s.ServerIP, &s.ServerPort, &s.SNI, &s.ALPN, &s.Version, &s.Cipher, &s.JA3, &s.JA3S, &s.JA4,
+			&cert.Subject, &cert.Issuer, &sans, &notBefore, &notAfter, &cert.SelfSigned, &cert.SHA256, &s.Connections, &firstSeen, &lastSeen, &s.PcapFile); err != nil {
+			return nil, fmt.Errorf("could not scan TLS session row for host %d: %w", hostID, err)
+		}
+		if cert.SHA256 != "" {
+			if sans != "" {
+				cert.SANs = strings.Split(sans, "\n")
+			}
+			cert.NotBefore, cert.NotAfter = notBefore.Time, notAfter.Time
+			s.Certificate = &cert
+		}
+		s.FirstSeen, s.LastSeen = firstSeen.Time, lastSeen.Time
+		host.TLSSessions[s.Key()] = &s
+	}
+
+	httpRows, err := DB.Query(`
+		SELECT id, client_ip, client_port, server_ip, server_port, stream_offset, method, host, uri, status_code, user_agent, server, content_type,
+		       request_size, response_size, timestamp, pcap_file
+		FROM http_transactions WHERE host_id = ? ORDER BY timestamp, id`, hostID)
+	if err != nil {
+		return nil, fmt.Errorf("could not query HTTP transactions for host %d: %w", hostID, err)
+	}
+	defer httpRows.Close()
+	for httpRows.Next() {
+		var t model.HTTPTransaction
+		var timestamp sql.NullTime
+		if err := httpRows.Scan(&t.ID, &t.ClientIP, &t.ClientPort, &t.ServerIP, &t.ServerPort, &t.StreamOffset, &t.Method, &t.Host, &t.URI, &t.StatusCode, &t.UserAgent, &t.Server,
+			&t.ContentType, &t.RequestSize, &t.ResponseSize, &timestamp, &t.PcapFile); err != nil {
+			return nil, fmt.Errorf("could not scan HTTP transaction row for host %d: %w", hostID, err)
+		}
+		t.Timestamp = timestamp.Time
+		host.HTTPRequests = append(host.HTTPRequests, t)
+	}
+
 	webRows, err := DB.Query("SELECT p.port_number, wr.method, wr.status_code, wr.headers FROM web_responses wr JOIN ports p ON wr.port_id = p.id WHERE wr.host_id = ?", hostID)
 	if err != nil {
 		return nil, fmt.Errorf("could not query web responses for host %d: %w", hostID, err)
@@ -590,9 +1076,80 @@ func GetHostByID(hostID int64, campaignID int64) (*model.Host, error) {
 		host.SMBResults = append(host.SMBResults, smr)
 	}
 
+	hostnameRows, err := DB.Query("SELECT name, type FROM hostnames WHERE host_id = ?", hostID)
+	if err != nil {
+		return nil, fmt.Errorf("could not query hostnames for host %d: %w", hostID, err)
+	}
+	defer hostnameRows.Close()
+	for hostnameRows.Next() {
+		var name string
+		var nameType sql.NullString
+		if err := hostnameRows.Scan(&name, &nameType); err != nil {
+			return nil, fmt.Errorf("could not scan hostname row for host %d: %w", hostID, err)
+		}
+		host.Hostnames[name] = nameType.String
+	}
+
+	osRows, err := DB.Query("SELECT name, accuracy, os_family, os_gen, vendor, device_type, cpe FROM os_matches WHERE host_id = ? ORDER BY accuracy DESC", hostID)
+	if err != nil {
+		return nil, fmt.Errorf("could not query os matches for host %d: %w", hostID, err)
+	}
+	defer osRows.Close()
+	for osRows.Next() {
+		var m model.OSMatch
+		var cpes string
+		if err := osRows.Scan(&m.Name, &m.Accuracy, &m.Family, &m.Generation, &m.Vendor, &m.DeviceType, &cpes); err != nil {
+			return nil, fmt.Errorf("could not scan os match row for host %d: %w", hostID, err)
+		}
+		if cpes != "" {
+			m.CPEs = strings.Split(cpes, "\n")
+		}
+		host.Fingerprint.OSMatches = append(host.Fingerprint.OSMatches, m)
+	}
+
+	hopRows, err := DB.Query("SELECT ttl, ip_address, rtt, hostname FROM traceroute_hops WHERE host_id = ? ORDER BY ttl", hostID)
+	if err != nil {
+		return nil, fmt.Errorf("could not query traceroute for host %d: %w", hostID, err)
+	}
+	defer hopRows.Close()
+	for hopRows.Next() {
+		var hop model.TraceHop
+		if err := hopRows.Scan(&hop.TTL, &hop.IP, &hop.RTT, &hop.Hostname); err != nil {
+			return nil, fmt.Errorf("could not scan traceroute hop for host %d: %w", hostID, err)
+		}
+		host.Traceroute = append(host.Traceroute, hop)
+	}
+
 	return host, nil
 }
 
+// GetScanRunsByCampaign retrieves the recorded scanner runs for a campaign, newest first.
+func GetScanRunsByCampaign(campaignID int64) ([]model.ScanRun, error) {
+	rows, err := DB.Query(`
+		SELECT id, scanner, args, version, start_time, end_time, elapsed, summary, hosts_up, hosts_down, hosts_total, scan_info, source_file
+		FROM scan_runs
+		WHERE campaign_id = ?
+		ORDER BY start_time DESC, id DESC`, campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("could not query scan runs for campaign %d: %w", campaignID, err)
+	}
+	defer rows.Close()
+
+	var runs []model.ScanRun
+	for rows.Next() {
+		var r model.ScanRun
+		var scanInfoJSON string
+		if err := rows.Scan(&r.ID, &r.Scanner, &r.Args, &r.Version, &r.StartTime, &r.EndTime, &r.Elapsed, &r.Summary, &r.HostsUp, &r.HostsDown, &r.HostsTotal, &scanInfoJSON, &r.SourceFile); err != nil {
+			return nil, fmt.Errorf("could not scan scan run row: %w", err)
+		}
+		if err := json.Unmarshal([]byte(scanInfoJSON), &r.ScanInfo); err != nil {
+			return nil, fmt.Errorf("could not unmarshal scan info for run %d: %w", r.ID, err)
+		}
+		runs = append(runs, r)
+	}
+	return runs, nil
+}
+
 // GetCampaignByID retrieves details for a single campaign by its ID.
 func GetCampaignByID(id int64) (*CampaignInfo, error) {
 	var c CampaignInfo
@@ -606,8 +1163,9 @@ func GetCampaignByID(id int64) (*CampaignInfo, error) {
 	return &c, nil
 }
 
-// GetHostsByCampaignPaginated retrieves a paginated list of hosts for a given campaign.
-func GetHostsByCampaignPaginated(campaignID int64, limit, offset int, search, filter string) ([]HostInfo, int, error) {
+// GetHostsByCampaignPaginated retrieves a paginated list of hosts for a given campaign. Sorting by
+// "risk" lists the riskiest hosts first; otherwise hosts are ordered by IP address.
+func GetHostsByCampaignPaginated(campaignID int64, limit, offset int, search, filter, sortBy string) ([]HostInfo, int, error) {
 	var hosts []HostInfo
 	var totalHosts int
 
@@ -644,10 +1202,14 @@ func GetHostsByCampaignPaginated(campaignID int64, limit, offset int, search, fi
 		return nil, 0, fmt.Errorf("could not count filtered hosts: %w", err)
 	}
 
+	orderBy := " ORDER BY h.ip_address DESC, h.id DESC"
+	if sortBy == "risk" {
+		orderBy = " ORDER BY h.risk_score DESC, h.ip_address DESC, h.id DESC"
+	}
 	selectQuery := `
         SELECT DISTINCT h.id, h.mac_address, h.ip_address, h.vendor, h.status,
-        (CASE WHEN EXISTS (SELECT 1 FROM vulnerabilities WHERE host_id = h.id) THEN 1 ELSE 0 END) as has_vulns
-    ` + baseQuery + " ORDER BY h.ip_address DESC, h.id DESC LIMIT ? OFFSET ?"
+        (CASE WHEN EXISTS (SELECT 1 FROM vulnerabilities WHERE host_id = h.id) THEN 1 ELSE 0 END) as has_vulns, h.risk_score
+    ` + baseQuery + orderBy + " LIMIT ? OFFSET ?"
 
 	args = append(args, limit, offset)
 
@@ -659,9 +1221,10 @@ func GetHostsByCampaignPaginated(campaignID int64, limit, offset int, search, fi
 
 	for rows.Next() {
 		var h HostInfo
-		if err := rows.Scan(&h.ID, &h.MACAddress, &h.IPAddress, &h.Vendor, &h.Status, &h.HasVulns); err != nil {
+		if err := rows.Scan(&h.ID, &h.MACAddress, &h.IPAddress, &h.Vendor, &h.Status, &h.HasVulns, &h.RiskScore); err != nil {
 			return nil, 0, fmt.Errorf("could not scan paginated host row: %w", err)
 		}
+		h.RiskLevel = model.RiskLevel(h.RiskScore)
 		hosts = append(hosts, h)
 	}
 
@@ -682,7 +1245,7 @@ func GetAllHostsForReport(campaignID int64) ([]ReportHostInfo, error) {
 	query := `
         SELECT
             h.id, h.mac_address, h.ip_address, h.vendor, h.os_guess, h.device_type, h.status,
-            (CASE WHEN EXISTS (SELECT 1 FROM vulnerabilities WHERE host_id = h.id) THEN 1 ELSE 0 END) as has_vulns
+            (CASE WHEN EXISTS (SELECT 1 FROM vulnerabilities WHERE host_id = h.id) THEN 1 ELSE 0 END) as has_vulns, h.risk_score
         FROM hosts h
         WHERE h.campaign_id = ? ORDER BY h.ip_address`
 	rows, err := DB.Query(query, campaignID)
@@ -694,7 +1257,7 @@ func GetAllHostsForReport(campaignID int64) ([]ReportHostInfo, error) {
 	var hosts []ReportHostInfo
 	for rows.Next() {
 		var h ReportHostInfo
-		if err := rows.Scan(&h.ID, &h.MACAddress, &h.IPAddress, &h.Vendor, &h.OSGuess, &h.DeviceType, &h.Status, &h.HasVulns); err != nil {
+		if err := rows.Scan(&h.ID, &h.MACAddress, &h.IPAddress, &h.Vendor, &h.OSGuess, &h.DeviceType, &h.Status, &h.HasVulns, &h.RiskScore); err != nil {
 			return nil, fmt.Errorf("could not scan host row for report: %w", err)
 		}
 		hosts = append(hosts, h)
@@ -730,9 +1293,15 @@ func GetAllPortsForReport(campaignID int64) ([][]string, error) {
 // GetAllVulnsForReport retrieves all vulnerabilities for a campaign for report generation.
 func GetAllVulnsForReport(campaignID int64) ([][]string, error) {
 	query := `
-        SELECT h.mac_address, v.cve, v.category, v.state, v.description
+        SELECT h.mac_address, v.cve, v.category, v.state, v.cvss, v.source, v.description, v.refs, v.evidence_file, v.evidence_packet,
+            k.cve_id IS NOT NULL, e.epss, v.triage_status, v.assignee,
+            COALESCE((SELECT GROUP_CONCAT(CASE WHEN n.author = '' THEN n.text ELSE n.author || ': ' || n.text END
+                || CASE WHEN n.evidence = '' THEN '' ELSE ' (evidence: ' || n.evidence || ')' END, ' | ')
+                FROM finding_notes n WHERE n.vulnerability_id = v.id), '')
         FROM vulnerabilities v JOIN hosts h ON v.host_id = h.id
-        WHERE h.campaign_id = ? ORDER BY h.mac_address, v.category`
+        LEFT JOIN kev_entries k ON k.cve_id = v.cve
+        LEFT JOIN epss_scores e ON e.cve_id = v.cve
+        WHERE h.campaign_id = ? ORDER BY h.mac_address, v.category, v.cvss DESC`
 	rows, err := DB.Query(query, campaignID)
 	if err != nil {
 		return nil, fmt.Errorf("could not query vulnerabilities for report: %w", err)
@@ -741,11 +1310,25 @@ func GetAllVulnsForReport(campaignID int64) ([][]string, error) {
 
 	var results [][]string
 	for rows.Next() {
-		var mac, cve, category, state, description string
-		if err := rows.Scan(&mac, &cve, &category, &state, &description); err != nil {
+		var mac, cve, category, state, source, description, refs string
+		var cvss float64
+		var evidence model.Vulnerability
+		var knownExploited bool
+		var epss sql.NullFloat64
+		var triageStatus, assignee, notes string
+		if err := rows.Scan(&mac, &cve, &category, &state, &cvss, &source, &description, &refs, &evidence.EvidenceFile, &evidence.EvidencePacket, &knownExploited, &epss,
+			&triageStatus, &assignee, &notes); err != nil {
 			return nil, err
 		}
-		results = append(results, []string{mac, cve, category, state, description})
+		exploited, epssScore := "No", ""
+		if knownExploited {
+			exploited = "Yes"
+		}
+		if epss.Valid {
+			epssScore = strconv.FormatFloat(epss.Float64, 'f', 4, 64)
+		}
+		results = append(results, []string{mac, cve, category, state, strconv.FormatFloat(cvss, 'f', 1, 64), source, description, strings.ReplaceAll(refs, "\n", " "), evidence.Evidence(),
+			exploited, epssScore, triageStatus, assignee, strings.ReplaceAll(notes, "\n", " ")})
 	}
 	return results, nil
 }
@@ -771,9 +1354,200 @@ func GetAllCommsForReport(campaignID int64) ([][]string, error) {
 		}
 		results = append(results, []string{mac, counterpart, strconv.Itoa(packetCount), city, country, isp})
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	seenIPs := make(map[string]bool)
+	var counterparts []string
+	for _, row := range results {
+		if !seenIPs[row[1]] {
+			seenIPs[row[1]] = true
+			counterparts = append(counterparts, row[1])
+		}
+	}
+	names, err := resolvedNames(campaignID, counterparts)
+	if err != nil {
+		return nil, err
+	}
+	for i, row := range results {
+		results[i] = append(row, strings.Join(names[row[1]], "; "))
+	}
+	return results, nil
+}
+
+// GetAllDNSAnswersForReport retrieves the DNS answers seen by each host of a campaign.
+func GetAllDNSAnswersForReport(campaignID int64) ([][]string, error) {
+	rows, err := DB.Query(`
+        SELECT h.mac_address, a.name, a.type, a.answer, a.ttl, a.resolver_ip, a.count, a.first_seen, a.last_seen
+        FROM dns_answers a JOIN hosts h ON a.host_id = h.id
+        WHERE h.campaign_id = ? ORDER BY h.mac_address, a.name, a.type, a.answer`, campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("could not query DNS answers for report: %w", err)
+	}
+	defer rows.Close()
+
+	var results [][]string
+	for rows.Next() {
+		var mac, name, recordType, answer, resolver string
+		var ttl, count int
+		var firstSeen, lastSeen sql.NullTime
+		if err := rows.Scan(&mac, &name, &recordType, &answer, &ttl, &resolver, &count, &firstSeen, &lastSeen); err != nil {
+			return nil, err
+		}
+		results = append(results, []string{mac, name, recordType, answer, strconv.Itoa(ttl), resolver, strconv.Itoa(count),
+			formatNullTime(firstSeen), formatNullTime(lastSeen)})
+	}
+	return results, rows.Err()
+}
+
+// formatNullTime formats a timestamp for a report, leaving unknown times empty.
+func formatNullTime(t sql.NullTime) string {
+	if !t.Valid || t.Time.IsZero() {
+		return ""
+	}
+	return t.Time.Format(time.RFC3339)
+}
+
+// GetAllDNSResolversForReport retrieves the resolvers each host of a campaign used.
+func GetAllDNSResolversForReport(campaignID int64) ([][]string, error) {
+	rows, err := DB.Query(`
+        SELECT h.mac_address, r.resolver_ip, r.protocol, r.hostname, r.queries, r.responses, r.nxdomain, r.connections, r.avg_response_ms,
+               r.first_seen, r.last_seen
+        FROM dns_resolvers r JOIN hosts h ON r.host_id = h.id
+        WHERE h.campaign_id = ? ORDER BY h.mac_address, r.protocol, r.resolver_ip`, campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("could not query DNS resolvers for report: %w", err)
+	}
+	defer rows.Close()
+
+	var results [][]string
+	for rows.Next() {
+		var mac, ip, protocol, hostname string
+		var queries, responses, nxdomain, connections int
+		var avgResponse float64
+		var firstSeen, lastSeen sql.NullTime
+		if err := rows.Scan(&mac, &ip, &protocol, &hostname, &queries, &responses, &nxdomain, &connections, &avgResponse, &firstSeen, &lastSeen); err != nil {
+			return nil, err
+		}
+		results = append(results, []string{mac, ip, protocol, hostname, strconv.Itoa(queries), strconv.Itoa(responses), strconv.Itoa(nxdomain),
+			strconv.Itoa(connections), strconv.FormatFloat(avgResponse, 'f', 1, 64), formatNullTime(firstSeen), formatNullTime(lastSeen)})
+	}
+	return results, rows.Err()
+}
+
+// GetAllOTOperationsForReport retrieves the industrial protocol operations of each host of a
+// campaign, with the host's OT role and the identity it reported for the protocol.
+func GetAllOTOperationsForReport(campaignID int64) ([][]string, error) {
+	hosts := make(map[int64]*model.Host)
+	host := func(id int64, mac string) *model.Host {
+		if hosts[id] == nil {
+			hosts[id] = model.NewHost(mac)
+		}
+		return hosts[id]
+	}
+	identityRows, err := DB.Query(`SELECT h.id, h.mac_address, i.protocol, i.identity FROM ot_identities i JOIN hosts h ON i.host_id = h.id WHERE h.campaign_id = ?`, campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("could not query OT identities for report: %w", err)
+	}
+	defer identityRows.Close()
+	for identityRows.Next() {
+		var id int64
+		var mac, protocol, identity string
+		if err := identityRows.Scan(&id, &mac, &protocol, &identity); err != nil {
+			return nil, err
+		}
+		host(id, mac).OTIdentities[protocol] = identity
+	}
+
+	rows, err := DB.Query(`
+        SELECT h.id, h.mac_address, o.protocol, o.role, o.peer, o.port, o.unit_id, o.function, o.operation, o.count, o.first_seen, o.last_seen
+        FROM ot_operations o JOIN hosts h ON o.host_id = h.id
+        WHERE h.campaign_id = ? ORDER BY h.mac_address, o.protocol, o.role, o.peer, o.function`, campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("could not query OT operations for report: %w", err)
+	}
+	defer rows.Close()
+	var ids []int64
+	var ops []*model.OTOperation
+	var seen [][2]string
+	for rows.Next() {
+		var id int64
+		var mac string
+		var op model.OTOperation
+		var firstSeen, lastSeen sql.NullTime
+		if err := rows.Scan(&id, &mac, &op.Protocol, &op.Role, &op.Peer, &op.Port, &op.UnitID, &op.Function, &op.Operation, &op.Count, &firstSeen, &lastSeen); err != nil {
+			return nil, err
+		}
+		op.FirstSeen, op.LastSeen = firstSeen.Time, lastSeen.Time
+		host(id, mac).OTOperations[op.Key()] = &op
+		ids, ops = append(ids, id), append(ops, &op)
+		seen = append(seen, [2]string{formatNullTime(firstSeen), formatNullTime(lastSeen)})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	results := make([][]string, 0, len(ops))
+	for i, op := range ops {
+		h := hosts[ids[i]]
+		results = append(results, []string{h.MACAddress, h.OTRole(), op.Protocol, op.Role, op.Peer, strconv.Itoa(op.Port), op.UnitID, op.Function,
+			op.Operation, strconv.Itoa(op.Count), seen[i][0], seen[i][1], h.OTIdentities[op.Protocol]})
+	}
 	return results, nil
 }
 
+// resolvedNames returns the names that DNS answers seen anywhere in the campaign resolved to
+// each of the given addresses, following CNAME records back to the names that were looked up.
+func resolvedNames(campaignID int64, ips []string) (map[string][]string, error) {
+	found := make(map[string]map[string]bool)
+	targets := make(map[string][]string) // Answer value -> addresses it leads to
+	for _, ip := range ips {
+		targets[ip] = []string{ip}
+	}
+	types := "'A', 'AAAA'"
+	// CNAME chains are short; the limit guards against loops.
+	for depth := 0; depth < 8 && len(targets) > 0; depth++ {
+		args := []interface{}{campaignID}
+		for value := range targets {
+			args = append(args, value)
+		}
+		rows, err := DB.Query(`SELECT DISTINCT a.name, a.answer FROM dns_answers a JOIN hosts h ON a.host_id = h.id
+			WHERE h.campaign_id = ? AND a.type IN (`+types+`) AND a.answer IN (?`+strings.Repeat(", ?", len(targets)-1)+`)`, args...)
+		if err != nil {
+			return nil, fmt.Errorf("could not query resolved names for campaign %d: %w", campaignID, err)
+		}
+		next := make(map[string][]string)
+		for rows.Next() {
+			var name, answer string
+			if err := rows.Scan(&name, &answer); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("could not scan resolved name row: %w", err)
+			}
+			for _, ip := range targets[answer] {
+				if found[ip] == nil {
+					found[ip] = make(map[string]bool)
+				}
+				if !found[ip][name] {
+					found[ip][name] = true
+					next[name] = append(next[name], ip)
+				}
+			}
+		}
+		rows.Close()
+		targets, types = next, "'CNAME'"
+	}
+
+	names := make(map[string][]string, len(found))
+	for ip, set := range found {
+		for name := range set {
+			names[ip] = append(names[ip], name)
+		}
+		sort.Strings(names[ip])
+	}
+	return names, nil
+}
+
 // GetAllDNSForReport retrieves all DNS lookups for a campaign for report generation.
 func GetAllDNSForReport(campaignID int64) ([][]string, error) {
 	query := `
@@ -797,6 +1571,78 @@ func GetAllDNSForReport(campaignID int64) ([][]string, error) {
 	return results, nil
 }
 
+// GetAllTLSSessionsForReport retrieves every TLS session of a campaign, with the server
+// certificate's details, for the CSV report.
+func GetAllTLSSessionsForReport(campaignID int64) ([][]string, error) {
+	query := `
+        SELECT h.mac_address, t.client_ip, t.server_ip, t.server_port, t.sni, t.alpn, t.version, t.cipher, t.ja3, t.ja3s, t.ja4,
+               t.cert_subject, t.cert_issuer, t.cert_sans, t.cert_not_after, t.cert_self_signed, t.connections
+        FROM tls_sessions t JOIN hosts h ON t.host_id = h.id
+        WHERE h.campaign_id = ? ORDER BY h.mac_address, t.server_ip, t.server_port, t.sni`
+	rows, err := DB.Query(query, campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("could not query TLS sessions for report: %w", err)
+	}
+	defer rows.Close()
+
+	var results [][]string
+	for rows.Next() {
+		var mac, clientIP, serverIP, sni, alpn, version, cipher, ja3, ja3s, ja4, subject, issuer, sans string
+		var port, connections int
+		var selfSigned bool
+		var notAfter sql.NullTime
+		if err := rows.Scan(&mac, &clientIP, &serverIP, &port, &sni, &alpn, &version, &cipher, &ja3, &ja3s, &ja4,
+			&subject, &issuer, &sans, &notAfter, &selfSigned, &connections); err != nil {
+			return nil, err
+		}
+		var expiry, selfSignedText string
+		if notAfter.Valid {
+			expiry = notAfter.Time.Format(time.RFC3339)
+		}
+		if subject != "" {
+			selfSignedText = strconv.FormatBool(selfSigned)
+		}
+		results = append(results, []string{mac, clientIP, serverIP, strconv.Itoa(port), sni, alpn, version, cipher, ja3, ja3s, ja4,
+			subject, issuer, strings.ReplaceAll(sans, "\n", " "), expiry, selfSignedText, strconv.Itoa(connections)})
+	}
+	return results, nil
+}
+
+// GetAllHTTPTransactionsForReport retrieves every HTTP transaction of a campaign for the CSV report.
+func GetAllHTTPTransactionsForReport(campaignID int64) ([][]string, error) {
+	query := `
+        SELECT h.mac_address, t.timestamp, t.client_ip, t.server_ip, t.server_port, t.method, t.host, t.uri, t.status_code,
+               t.user_agent, t.server, t.content_type, t.request_size, t.response_size, t.pcap_file
+        FROM http_transactions t JOIN hosts h ON t.host_id = h.id
+        WHERE h.campaign_id = ? ORDER BY h.mac_address, t.timestamp, t.id`
+	rows, err := DB.Query(query, campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("could not query HTTP transactions for report: %w", err)
+	}
+	defer rows.Close()
+
+	var results [][]string
+	for rows.Next() {
+		var mac, clientIP, serverIP, method, host, uri, userAgent, server, contentType, pcapFile string
+		var port, status, requestSize, responseSize int
+		var timestamp sql.NullTime
+		if err := rows.Scan(&mac, &timestamp, &clientIP, &serverIP, &port, &method, &host, &uri, &status,
+			&userAgent, &server, &contentType, &requestSize, &responseSize, &pcapFile); err != nil {
+			return nil, err
+		}
+		var when, statusText string
+		if timestamp.Valid {
+			when = timestamp.Time.Format(time.RFC3339)
+		}
+		if status != 0 {
+			statusText = strconv.Itoa(status)
+		}
+		results = append(results, []string{mac, when, clientIP, serverIP, strconv.Itoa(port), method, host, uri, statusText,
+			userAgent, server, contentType, strconv.Itoa(requestSize), strconv.Itoa(responseSize), pcapFile})
+	}
+	return results, nil
+}
+
 // GetHandshakesByCampaignPaginated retrieves a paginated list of handshakes for a campaign.
 func GetHandshakesByCampaignPaginated(campaignID int64, limit, offset int) ([]model.ReportHandshakeInfo, error) {
 	rows, err := DB.Query(`
@@ -838,17 +1684,147 @@ func CountHandshakesByCampaign(campaignID int64) (int, error) {
 	return count, err
 }
 
-// GetFullHostsForCampaign retrieves all hosts and their related data for a campaign.
-func GetFullHostsForCampaign(campaignID int64) (map[string]*model.Host, error) {
-	hosts := make(map[string]*model.Host)
+// nullTime stores zero times as NULL and others in UTC, so stored times compare in order.
+func nullTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC()
+}
 
-	rows, err := DB.Query("SELECT id, mac_address, ip_address, vendor, os_guess, status, device_type FROM hosts WHERE campaign_id = ?", campaignID)
+// GetWirelessAccessPoints retrieves a campaign's access points with their associated client counts.
+func GetWirelessAccessPoints(campaignID int64) ([]model.WirelessAP, error) {
+	rows, err := DB.Query(`
+		SELECT ap.id, ap.bssid, ap.ssid, ap.vendor, ap.channel, ap.frequency, ap.encryption, ap.akm, ap.ciphers, ap.wps, ap.rssi, ap.beacons, ap.first_seen, ap.last_seen,
+			(SELECT COUNT(*) FROM wifi_clients c WHERE c.campaign_id = ap.campaign_id AND c.associated_bssid = ap.bssid)
+		FROM wifi_access_points ap
+		WHERE ap.campaign_id = ?
+		ORDER BY ap.ssid, ap.bssid`, campaignID)
 	if err != nil {
-		return nil, fmt.Errorf("could not query hosts for campaign %d: %w", campaignID, err)
+		return nil, fmt.Errorf("could not query access points for campaign %d: %w", campaignID, err)
 	}
 	defer rows.Close()
 
-	hostIDtoMac := make(map[int64]string)
+	var aps []model.WirelessAP
+	for rows.Next() {
+		var ap model.WirelessAP
+		var firstSeen, lastSeen sql.NullTime
+		if err := rows.Scan(&ap.ID, &ap.BSSID, &ap.SSID, &ap.Vendor, &ap.Channel, &ap.Frequency, &ap.Encryption, &ap.AKM, &ap.Ciphers, &ap.WPS, &ap.RSSI, &ap.Beacons, &firstSeen, &lastSeen, &ap.Clients); err != nil {
+			return nil, fmt.Errorf("could not scan access point row: %w", err)
+		}
+		ap.FirstSeen, ap.LastSeen = firstSeen.Time, lastSeen.Time
+		aps = append(aps, ap)
+	}
+	return aps, rows.Err()
+}
+
+// GetWirelessClients retrieves a campaign's wireless stations with the SSIDs they probed for.
+func GetWirelessClients(campaignID int64) ([]model.WirelessClient, error) {
+	rows, err := DB.Query(`
+		SELECT id, mac_address, vendor, associated_bssid, rssi, frames, first_seen, last_seen
+		FROM wifi_clients
+		WHERE campaign_id = ?
+		ORDER BY associated_bssid = '', associated_bssid, mac_address`, campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("could not query wireless clients for campaign %d: %w", campaignID, err)
+	}
+	defer rows.Close()
+
+	var clients []model.WirelessClient
+	clientIndex := make(map[int64]int)
+	for rows.Next() {
+		c := model.WirelessClient{ProbedSSIDs: make(map[string]bool)}
+		var firstSeen, lastSeen sql.NullTime
+		if err := rows.Scan(&c.ID, &c.MAC, &c.Vendor, &c.AssociatedBSSID, &c.RSSI, &c.Frames, &firstSeen, &lastSeen); err != nil {
+			return nil, fmt.Errorf("could not scan wireless client row: %w", err)
+		}
+		c.FirstSeen, c.LastSeen = firstSeen.Time, lastSeen.Time
+		clientIndex[c.ID] = len(clients)
+		clients = append(clients, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	probeRows, err := DB.Query(`SELECT p.client_id, p.ssid FROM wifi_probes p JOIN wifi_clients c ON p.client_id = c.id WHERE c.campaign_id = ?`, campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("could not query probe requests for campaign %d: %w", campaignID, err)
+	}
+	defer probeRows.Close()
+	for probeRows.Next() {
+		var clientID int64
+		var ssid string
+		if err := probeRows.Scan(&clientID, &ssid); err != nil {
+			return nil, fmt.Errorf("could not scan probe request row: %w", err)
+		}
+		if i, ok := clientIndex[clientID]; ok {
+			clients[i].ProbedSSIDs[ssid] = true
+		}
+	}
+	return clients, probeRows.Err()
+}
+
+// GetDeauthPairs retrieves a campaign's deauthentication activity per access point and client.
+// A pair counts as captured once a full handshake for it is stored, even if that handshake came
+// from a capture without the deauths; broadcast deauths match a handshake from any client.
+func GetDeauthPairs(campaignID int64) ([]model.DeauthPair, error) {
+	rows, err := DB.Query(`
+		SELECT d.id, d.ap_mac, d.client_mac, d.ssid, d.deauth_count, d.disassoc_count, d.first_seen, d.last_seen, d.reconnected_at, d.pcap_file,
+			CASE WHEN EXISTS (SELECT 1 FROM handshakes h WHERE h.campaign_id = d.campaign_id AND h.ap_mac = d.ap_mac
+				AND (h.client_mac = d.client_mac OR d.client_mac = 'FF:FF:FF:FF:FF:FF')) THEN 'Full' ELSE d.handshake_state END
+		FROM deauth_pairs d
+		WHERE d.campaign_id = ?
+		ORDER BY d.last_seen DESC, d.ap_mac, d.client_mac`, campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("could not query deauth activity for campaign %d: %w", campaignID, err)
+	}
+	defer rows.Close()
+
+	var pairs []model.DeauthPair
+	pairIndex := make(map[int64]int)
+	for rows.Next() {
+		p := model.DeauthPair{Reasons: make(map[int]int)}
+		var firstSeen, lastSeen, reconnectedAt sql.NullTime
+		if err := rows.Scan(&p.ID, &p.APMAC, &p.ClientMAC, &p.SSID, &p.Deauths, &p.Disassocs, &firstSeen, &lastSeen, &reconnectedAt, &p.PcapFile, &p.HandshakeState); err != nil {
+			return nil, fmt.Errorf("could not scan deauth row: %w", err)
+		}
+		p.FirstSeen, p.LastSeen, p.ReconnectedAt = firstSeen.Time, lastSeen.Time, reconnectedAt.Time
+		pairIndex[p.ID] = len(pairs)
+		pairs = append(pairs, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	reasonRows, err := DB.Query(`SELECT r.pair_id, r.reason, r.frame_count FROM deauth_reasons r JOIN deauth_pairs d ON r.pair_id = d.id WHERE d.campaign_id = ?`, campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("could not query deauth reasons for campaign %d: %w", campaignID, err)
+	}
+	defer reasonRows.Close()
+	for reasonRows.Next() {
+		var pairID int64
+		var reason, count int
+		if err := reasonRows.Scan(&pairID, &reason, &count); err != nil {
+			return nil, fmt.Errorf("could not scan deauth reason row: %w", err)
+		}
+		if i, ok := pairIndex[pairID]; ok {
+			pairs[i].Reasons[reason] = count
+		}
+	}
+	return pairs, reasonRows.Err()
+}
+
+// GetFullHostsForCampaign retrieves all hosts and their related data for a campaign.
+func GetFullHostsForCampaign(campaignID int64) (map[string]*model.Host, error) {
+	hosts := make(map[string]*model.Host)
+
+	rows, err := DB.Query("SELECT id, mac_address, ip_address, vendor, os_guess, status, device_type FROM hosts WHERE campaign_id = ?", campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("could not query hosts for campaign %d: %w", campaignID, err)
+	}
+	defer rows.Close()
+
+	hostIDtoMac := make(map[int64]string)
 	for rows.Next() {
 		h := model.NewHost("")
 		var ipAddress, vendor, osGuess, deviceType string
@@ -879,18 +1855,26 @@ func GetFullHostsForCampaign(campaignID int64) (map[string]*model.Host, error) {
 		}
 	}
 
-	vulnRows, err := DB.Query("SELECT host_id, port_id, cve, description, state, category FROM vulnerabilities v JOIN hosts h ON v.host_id = h.id WHERE h.campaign_id = ?", campaignID)
+	vulnRows, err := DB.Query(`
+        SELECT v.host_id, COALESCE(p.port_number, 0), v.id, v.cve, v.description, v.state, v.category, v.cvss, v.source, v.evidence_file, v.evidence_packet, k.cve_id IS NOT NULL, COALESCE(e.epss, 0),
+            v.triage_status, v.assignee, COALESCE(v.suppression_id, 0)
+        FROM vulnerabilities v JOIN hosts h ON v.host_id = h.id
+        LEFT JOIN ports p ON v.port_id = p.id
+        LEFT JOIN kev_entries k ON k.cve_id = v.cve
+        LEFT JOIN epss_scores e ON e.cve_id = v.cve
+        WHERE h.campaign_id = ?`, campaignID)
 	if err != nil {
 		return nil, err
 	}
 	defer vulnRows.Close()
 	for vulnRows.Next() {
-		var hostID, portDBID sql.NullInt64
+		var hostID int64
 		var v model.Vulnerability
-		if err := vulnRows.Scan(&hostID, &portDBID, &v.CVE, &v.Description, &v.State, &v.Category); err != nil {
+		if err := vulnRows.Scan(&hostID, &v.PortID, &v.ID, &v.CVE, &v.Description, &v.State, &v.Category, &v.CVSS, &v.Source, &v.EvidenceFile, &v.EvidencePacket, &v.KnownExploited, &v.EPSS,
+			&v.TriageStatus, &v.Assignee, &v.SuppressionID); err != nil {
 			return nil, err
 		}
-		if mac, ok := hostIDtoMac[hostID.Int64]; ok {
+		if mac, ok := hostIDtoMac[hostID]; ok {
 			hosts[mac].Findings[v.Category] = append(hosts[mac].Findings[v.Category], v)
 		}
 	}
@@ -898,6 +1882,10 @@ func GetFullHostsForCampaign(campaignID int64) (map[string]*model.Host, error) {
 	return hosts, nil
 }
 
+// activeFinding is the condition on a finding "v" that leaves out those triaged as false positives
+// or fixed, so they are not counted on the dashboard.
+const activeFinding = "v.triage_status NOT IN ('" + model.TriageFalsePositive + "', '" + model.TriageFixed + "')"
+
 // DashboardSummary holds aggregated data for the main dashboard view.
 type DashboardSummary struct {
 	TotalHosts                int
@@ -910,6 +1898,18 @@ type DashboardSummary struct {
 	CapturedHandshakesCount   int
 	CapturedCredentialsCount  int
 	TotalVulnerabilitiesCount int
+	WirelessAPCount           int
+	CarvedFileCount           int
+	Risk                      CampaignRisk
+}
+
+// CampaignRisk summarizes the risk scores of a campaign's hosts.
+type CampaignRisk struct {
+	MaxScore            float64
+	AverageScore        float64
+	Levels              map[string]int // Hosts per risk level
+	KnownExploitedCount int            // Findings on a known exploited vulnerability
+	TopHosts            []HostInfo
 }
 
 // GetDashboardSummary retrieves aggregated data for the dashboard.
@@ -948,15 +1948,15 @@ func GetDashboardSummary(campaignID int64) (*DashboardSummary, error) {
 		summary.MostCommonPorts = append(summary.MostCommonPorts, port)
 	}
 
-	err = DB.QueryRow("SELECT COUNT(*) FROM vulnerabilities v JOIN hosts h ON v.host_id = h.id WHERE h.campaign_id = ? AND v.category = ?", campaignID, model.CriticalFinding).Scan(&summary.CriticalVulnCount)
+	err = DB.QueryRow("SELECT COUNT(*) FROM vulnerabilities v JOIN hosts h ON v.host_id = h.id WHERE h.campaign_id = ? AND v.category = ? AND "+activeFinding, campaignID, model.CriticalFinding).Scan(&summary.CriticalVulnCount)
 	if err != nil {
 		return nil, fmt.Errorf("could not count critical vulnerabilities for dashboard: %w", err)
 	}
-	err = DB.QueryRow("SELECT COUNT(*) FROM vulnerabilities v JOIN hosts h ON v.host_id = h.id WHERE h.campaign_id = ? AND v.category = ?", campaignID, model.PotentialFinding).Scan(&summary.PotentialVulnCount)
+	err = DB.QueryRow("SELECT COUNT(*) FROM vulnerabilities v JOIN hosts h ON v.host_id = h.id WHERE h.campaign_id = ? AND v.category = ? AND "+activeFinding, campaignID, model.PotentialFinding).Scan(&summary.PotentialVulnCount)
 	if err != nil {
 		return nil, fmt.Errorf("could not count potential vulnerabilities for dashboard: %w", err)
 	}
-	err = DB.QueryRow("SELECT COUNT(*) FROM vulnerabilities v JOIN hosts h ON v.host_id = h.id WHERE h.campaign_id = ? AND v.category = ?", campaignID, model.InformationalFinding).Scan(&summary.InformationalVulnCount)
+	err = DB.QueryRow("SELECT COUNT(*) FROM vulnerabilities v JOIN hosts h ON v.host_id = h.id WHERE h.campaign_id = ? AND v.category = ? AND "+activeFinding, campaignID, model.InformationalFinding).Scan(&summary.InformationalVulnCount)
 	if err != nil {
 		return nil, fmt.Errorf("could not count informational vulnerabilities for dashboard: %w", err)
 	}
@@ -973,9 +1973,59 @@ func GetDashboardSummary(campaignID int64) (*DashboardSummary, error) {
 		return nil, fmt.Errorf("could not count credentials for dashboard: %w", err)
 	}
 
+	err = DB.QueryRow("SELECT COUNT(*) FROM wifi_access_points WHERE campaign_id = ?", campaignID).Scan(&summary.WirelessAPCount)
+	if err != nil {
+		return nil, fmt.Errorf("could not count access points for dashboard: %w", err)
+	}
+
+	err = DB.QueryRow("SELECT COUNT(*) FROM carved_files WHERE campaign_id = ?", campaignID).Scan(&summary.CarvedFileCount)
+	if err != nil {
+		return nil, fmt.Errorf("could not count carved files for dashboard: %w", err)
+	}
+
+	summary.Risk, err = getCampaignRisk(campaignID)
+	if err != nil {
+		return nil, err
+	}
+
 	return summary, nil
 }
 
+// getCampaignRisk aggregates the risk scores of a campaign's hosts and lists the five riskiest.
+func getCampaignRisk(campaignID int64) (CampaignRisk, error) {
+	risk := CampaignRisk{Levels: make(map[string]int)}
+	err := DB.QueryRow("SELECT COALESCE(MAX(risk_score), 0), COALESCE(AVG(risk_score), 0) FROM hosts WHERE campaign_id = ?", campaignID).Scan(&risk.MaxScore, &risk.AverageScore)
+	if err != nil {
+		return risk, fmt.Errorf("could not aggregate risk scores for dashboard: %w", err)
+	}
+	risk.AverageScore = math.Round(risk.AverageScore*10) / 10
+
+	rows, err := DB.Query("SELECT risk_score FROM hosts WHERE campaign_id = ?", campaignID)
+	if err != nil {
+		return risk, fmt.Errorf("could not query risk scores for dashboard: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var score float64
+		if err := rows.Scan(&score); err != nil {
+			return risk, fmt.Errorf("could not scan risk score for dashboard: %w", err)
+		}
+		risk.Levels[model.RiskLevel(score)]++
+	}
+
+	err = DB.QueryRow(`SELECT COUNT(*) FROM vulnerabilities v JOIN hosts h ON v.host_id = h.id JOIN kev_entries k ON k.cve_id = v.cve
+        WHERE h.campaign_id = ? AND `+activeFinding, campaignID).Scan(&risk.KnownExploitedCount)
+	if err != nil {
+		return risk, fmt.Errorf("could not count known exploited vulnerabilities for dashboard: %w", err)
+	}
+
+	risk.TopHosts, _, err = GetHostsByCampaignPaginated(campaignID, 5, 0, "", "", "risk")
+	if err != nil {
+		return risk, err
+	}
+	return risk, nil
+}
+
 // GetCredentialsByCampaign retrieves all credentials for a campaign.
 func GetCredentialsByCampaign(campaignID int64) ([]model.Credential, error) {
 	rows, err := DB.Query(`
@@ -1010,6 +2060,49 @@ func CountCredentialsByCampaign(campaignID int64) (int, error) {
 	return count, err
 }
 
+// GetCarvedFilesByCampaign retrieves the carved files of a campaign, newest first, without
+// their contents.
+func GetCarvedFilesByCampaign(campaignID int64) ([]model.CarvedFile, error) {
+	rows, err := DB.Query(`
+		SELECT f.id, COALESCE(h.mac_address, ''), f.filename, f.mime_type, f.size, f.md5, f.sha1, f.sha256, f.protocol,
+		       f.source_ip, f.destination_ip, f.timestamp, f.pcap_file
+		FROM carved_files f
+		LEFT JOIN hosts h ON f.host_id = h.id
+		WHERE f.campaign_id = ?
+		ORDER BY f.timestamp DESC, f.id DESC`, campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("could not query carved files for campaign %d: %w", campaignID, err)
+	}
+	defer rows.Close()
+
+	var files []model.CarvedFile
+	for rows.Next() {
+		var f model.CarvedFile
+		var timestamp sql.NullTime
+		if err := rows.Scan(&f.ID, &f.HostMAC, &f.Filename, &f.MIMEType, &f.Size, &f.MD5, &f.SHA1, &f.SHA256, &f.Protocol,
+			&f.SourceIP, &f.DestinationIP, &timestamp, &f.PcapFile); err != nil {
+			return nil, fmt.Errorf("could not scan carved file row: %w", err)
+		}
+		f.Timestamp = timestamp.Time
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// GetCarvedFileByID retrieves a carved file's name and contents.
+func GetCarvedFileByID(id int64) (*model.CarvedFile, error) {
+	f := &model.CarvedFile{ID: id}
+	err := DB.QueryRow("SELECT filename, mime_type, sha256, data FROM carved_files WHERE id = ?", id).Scan(&f.Filename, &f.MIMEType, &f.SHA256, &f.Data)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("carved file with ID %d not found", id)
+		}
+		return nil, err
+	}
+	f.Size = len(f.Data)
+	return f, nil
+}
+
 // GetScreenshotByID retrieves the raw image data for a single screenshot.
 func GetScreenshotByID(id int64) ([]byte, error) {
 	var data []byte
@@ -1022,3 +2115,471 @@ func GetScreenshotByID(id int64) ([]byte, error) {
 	}
 	return data, nil
 }
+
+// StartIngestRun records the start of a file scan over a directory and returns its ID.
+func StartIngestRun(campaignID int64, directory string, forced bool) (int64, error) {
+	res, err := DB.Exec("INSERT INTO ingest_runs(campaign_id, directory, started_at, forced) VALUES(?, ?, ?, ?)", campaignID, directory, time.Now(), forced)
+	if err != nil {
+		return 0, fmt.Errorf("could not record ingest run for campaign %d: %w", campaignID, err)
+	}
+	return res.LastInsertId()
+}
+
+// FinishIngestRun marks a file scan as finished and stores how many files it processed and skipped.
+// A non-nil runErr records the run as failed.
+func FinishIngestRun(runID int64, processed, skipped int, runErr error) error {
+	var errText string
+	if runErr != nil {
+		errText = runErr.Error()
+	}
+	_, err := DB.Exec("UPDATE ingest_runs SET finished_at = ?, files_processed = ?, files_skipped = ?, error = ? WHERE id = ?", time.Now(), processed, skipped, errText, runID)
+	if err != nil {
+		return fmt.Errorf("could not finish ingest run %d: %w", runID, err)
+	}
+	return nil
+}
+
+// GetIngestedFiles retrieves the registry of data files already processed into a campaign, keyed by path.
+func GetIngestedFiles(campaignID int64) (map[string]model.IngestedFile, error) {
+	rows, err := DB.Query(`
+		SELECT path, size, mod_time, sha256, file_type, COALESCE(ingest_run_id, 0), ingested_at
+		FROM ingested_files
+		WHERE campaign_id = ?`, campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("could not query ingested files for campaign %d: %w", campaignID, err)
+	}
+	defer rows.Close()
+
+	files := make(map[string]model.IngestedFile)
+	for rows.Next() {
+		var f model.IngestedFile
+		if err := rows.Scan(&f.Path, &f.Size, &f.ModTime, &f.SHA256, &f.FileType, &f.IngestRunID, &f.IngestedAt); err != nil {
+			return nil, fmt.Errorf("could not scan ingested file row: %w", err)
+		}
+		files[f.Path] = f
+	}
+	return files, nil
+}
+
+// RecordIngestedFiles adds files to a campaign's registry, replacing any earlier entry for the same path.
+func RecordIngestedFiles(campaignID, runID int64, files []model.IngestedFile) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return fmt.Errorf("could not begin database transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO ingested_files(campaign_id, path, size, mod_time, sha256, file_type, ingest_run_id, ingested_at) VALUES(?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(campaign_id, path) DO UPDATE SET size=excluded.size, mod_time=excluded.mod_time, sha256=excluded.sha256,
+		file_type=excluded.file_type, ingest_run_id=excluded.ingest_run_id, ingested_at=excluded.ingested_at;`)
+	if err != nil {
+		return fmt.Errorf("could not prepare ingested file statement: %w", err)
+	}
+	defer stmt.Close()
+
+	now := time.Now()
+	for _, f := range files {
+		if _, err := stmt.Exec(campaignID, f.Path, f.Size, f.ModTime.UTC(), f.SHA256, f.FileType, runID, now); err != nil {
+			return fmt.Errorf("could not record ingested file %s: %w", f.Path, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// SaveCVERecords adds records to the offline vulnerability database. A CVE that is already known
+// is replaced, so importing a newer feed updates it.
+func SaveCVERecords(records []model.CVERecord) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return fmt.Errorf("could not begin database transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	recordStmt, err := tx.Prepare(`INSERT INTO cve_records(id, description, cvss, published, refs) VALUES(?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET description=excluded.description, cvss=excluded.cvss, published=excluded.published, refs=excluded.refs;`)
+	if err != nil {
+		return fmt.Errorf("could not prepare CVE record statement: %w", err)
+	}
+	defer recordStmt.Close()
+	clearStmt, err := tx.Prepare("DELETE FROM cve_affected WHERE cve_id = ?")
+	if err != nil {
+		return fmt.Errorf("could not prepare CVE cleanup statement: %w", err)
+	}
+	defer clearStmt.Close()
+	affectedStmt, err := tx.Prepare(`INSERT INTO cve_affected(cve_id, vendor, ecosystem, product, version, start_including, start_excluding, end_including, end_excluding)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("could not prepare CVE product statement: %w", err)
+	}
+	defer affectedStmt.Close()
+
+	for _, r := range records {
+		if _, err := recordStmt.Exec(r.ID, r.Description, r.CVSS, nullTime(r.Published), strings.Join(r.References, "\n")); err != nil {
+			return fmt.Errorf("could not save %s: %w", r.ID, err)
+		}
+		if _, err := clearStmt.Exec(r.ID); err != nil {
+			return fmt.Errorf("could not replace the products of %s: %w", r.ID, err)
+		}
+		for _, a := range r.Affected {
+			if _, err := affectedStmt.Exec(r.ID, a.Vendor, a.Ecosystem, a.Product, a.Version, a.StartIncluding, a.StartExcluding, a.EndIncluding, a.EndExcluding); err != nil {
+				return fmt.Errorf("could not save the products of %s: %w", r.ID, err)
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// CountCVERecords returns the number of CVEs in the offline vulnerability database.
+func CountCVERecords() (int, error) {
+	var count int
+	err := DB.QueryRow("SELECT COUNT(*) FROM cve_records").Scan(&count)
+	return count, err
+}
+
+// LookupCVEs returns the CVEs of the offline database that list the product, each with only the
+// affected entries for it. Entries without a vendor match any vendor, and so does an empty vendor.
+// OSV entries keep their ecosystem so callers can tell a language package from a service.
+func LookupCVEs(vendor, product string) ([]model.CVERecord, error) {
+	rows, err := DB.Query(`
+        SELECT r.id, r.description, r.cvss, r.published, r.refs, a.vendor, a.ecosystem, a.product, a.version, a.start_including, a.start_excluding, a.end_including, a.end_excluding
+        FROM cve_affected a JOIN cve_records r ON a.cve_id = r.id
+        WHERE a.product = ? AND (a.vendor = '' OR ? = '' OR a.vendor = ?)
+        ORDER BY r.id`, product, vendor, vendor)
+	if err != nil {
+		return nil, fmt.Errorf("could not look up CVEs for %s: %w", product, err)
+	}
+	defer rows.Close()
+
+	var records []model.CVERecord
+	for rows.Next() {
+		var r model.CVERecord
+		var a model.CVEAffected
+		var published sql.NullTime
+		var refs string
+		if err := rows.Scan(&r.ID, &r.Description, &r.CVSS, &published, &refs, &a.Vendor, &a.Ecosystem, &a.Product, &a.Version,
+			&a.StartIncluding, &a.StartExcluding, &a.EndIncluding, &a.EndExcluding); err != nil {
+			return nil, err
+		}
+		if n := len(records); n > 0 && records[n-1].ID == r.ID {
+			records[n-1].Affected = append(records[n-1].Affected, a)
+			continue
+		}
+		r.Published = published.Time
+		if refs != "" {
+			r.References = strings.Split(refs, "\n")
+		}
+		r.Affected = []model.CVEAffected{a}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// SaveKEVEntries replaces the known exploited vulnerabilities list and rescores every host.
+func SaveKEVEntries(entries []model.KEVEntry) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return fmt.Errorf("could not begin database transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM kev_entries"); err != nil {
+		return fmt.Errorf("could not clear the known exploited vulnerabilities: %w", err)
+	}
+	stmt, err := tx.Prepare(`INSERT INTO kev_entries(cve_id, name, date_added, ransomware) VALUES(?, ?, ?, ?)
+		ON CONFLICT(cve_id) DO UPDATE SET name=excluded.name, date_added=excluded.date_added, ransomware=excluded.ransomware`)
+	if err != nil {
+		return fmt.Errorf("could not prepare known exploited vulnerability statement: %w", err)
+	}
+	defer stmt.Close()
+	for _, e := range entries {
+		if _, err := stmt.Exec(e.CVE, e.Name, e.DateAdded, e.RansomwareUse); err != nil {
+			return fmt.Errorf("could not save known exploited vulnerability %s: %w", e.CVE, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return UpdateAllRiskScores()
+}
+
+// SaveEPSSScores replaces the EPSS scores and rescores every host.
+func SaveEPSSScores(scores []model.EPSSScore) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return fmt.Errorf("could not begin database transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM epss_scores"); err != nil {
+		return fmt.Errorf("could not clear the EPSS scores: %w", err)
+	}
+	stmt, err := tx.Prepare(`INSERT INTO epss_scores(cve_id, epss, percentile) VALUES(?, ?, ?)
+		ON CONFLICT(cve_id) DO UPDATE SET epss=excluded.epss, percentile=excluded.percentile`)
+	if err != nil {
+		return fmt.Errorf("could not prepare EPSS statement: %w", err)
+	}
+	defer stmt.Close()
+	for _, e := range scores {
+		if _, err := stmt.Exec(e.CVE, e.Score, e.Percentile); err != nil {
+			return fmt.Errorf("could not save the EPSS score of %s: %w", e.CVE, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return UpdateAllRiskScores()
+}
+
+// UpdateRiskScores recomputes and stores the risk score of every host in the campaign from its
+// findings, the KEV and EPSS data and its exposure.
+func UpdateRiskScores(campaignID int64) error {
+	hosts, err := GetFullHostsForCampaign(campaignID)
+	if err != nil {
+		return fmt.Errorf("could not load hosts of campaign %d for risk scoring: %w", campaignID, err)
+	}
+	byID := make(map[int64]*model.Host, len(hosts))
+	for _, h := range hosts {
+		byID[h.ID] = h
+	}
+
+	exposureQueries := []string{
+		"SELECT r.host_id, p.port_number FROM ftp_results r JOIN ports p ON r.port_id = p.id JOIN hosts h ON r.host_id = h.id WHERE h.campaign_id = ? AND r.anonymous_login_possible",
+		"SELECT r.host_id, p.port_number FROM ssh_results r JOIN ports p ON r.port_id = p.id JOIN hosts h ON r.host_id = h.id WHERE h.campaign_id = ? AND r.successful",
+		"SELECT r.host_id, p.port_number FROM smb_results r JOIN ports p ON r.port_id = p.id JOIN hosts h ON r.host_id = h.id WHERE h.campaign_id = ? AND r.successful",
+	}
+	for i, query := range exposureQueries {
+		rows, err := DB.Query(query, campaignID)
+		if err != nil {
+			return fmt.Errorf("could not query post-exploitation results for risk scoring: %w", err)
+		}
+		for rows.Next() {
+			var hostID int64
+			var port int
+			if err := rows.Scan(&hostID, &port); err != nil {
+				rows.Close()
+				return err
+			}
+			h, ok := byID[hostID]
+			if !ok {
+				continue
+			}
+			switch i {
+			case 0:
+				h.FTPResults = append(h.FTPResults, model.FTPResult{PortID: port, AnonymousLoginPossible: true})
+			case 1:
+				h.SSHResults = append(h.SSHResults, model.SSHResult{PortID: port, Successful: true})
+			case 2:
+				h.SMBResults = append(h.SMBResults, model.SMBResult{PortID: port, Successful: true})
+			}
+		}
+		rows.Close()
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return fmt.Errorf("could not begin database transaction: %w", err)
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare("UPDATE hosts SET risk_score = ? WHERE id = ?")
+	if err != nil {
+		return fmt.Errorf("could not prepare risk score statement: %w", err)
+	}
+	defer stmt.Close()
+	for id, h := range byID {
+		if _, err := stmt.Exec(h.Risk().Score, id); err != nil {
+			return fmt.Errorf("could not save the risk score of host %d: %w", id, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// UpdateAllRiskScores rescores the hosts of every campaign, e.g. after new KEV or EPSS data.
+func UpdateAllRiskScores() error {
+	campaigns, err := ListCampaigns()
+	if err != nil {
+		return err
+	}
+	for _, c := range campaigns {
+		if err := UpdateRiskScores(c.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// errFindingNotFound wraps sql.ErrNoRows for findings that do not exist in the given campaign.
+func errFindingNotFound(findingID, campaignID int64) error {
+	return fmt.Errorf("finding %d not found in campaign %d: %w", findingID, campaignID, sql.ErrNoRows)
+}
+
+// UpdateFindingTriage sets the triage status and assignee of a finding. A manual status replaces
+// one set by a suppression rule, and suppression rules no longer apply to the finding.
+func UpdateFindingTriage(campaignID, findingID int64, status, assignee string) error {
+	if !model.ValidTriageStatus(status) {
+		return fmt.Errorf("unknown triage status %q", status)
+	}
+	res, err := DB.Exec(`UPDATE vulnerabilities SET triage_status = ?, assignee = ?, suppression_id = NULL, triage_manual = 1
+        WHERE id = ? AND host_id IN (SELECT id FROM hosts WHERE campaign_id = ?)`, status, strings.TrimSpace(assignee), findingID, campaignID)
+	if err != nil {
+		return fmt.Errorf("could not update the triage of finding %d: %w", findingID, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errFindingNotFound(findingID, campaignID)
+	}
+	return UpdateRiskScores(campaignID)
+}
+
+// AddFindingNote adds a note to a finding and returns its ID.
+func AddFindingNote(campaignID, findingID int64, note model.FindingNote) (int64, error) {
+	var exists int
+	err := DB.QueryRow("SELECT 1 FROM vulnerabilities v JOIN hosts h ON v.host_id = h.id WHERE v.id = ? AND h.campaign_id = ?", findingID, campaignID).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return 0, errFindingNotFound(findingID, campaignID)
+	} else if err != nil {
+		return 0, fmt.Errorf("could not look up finding %d: %w", findingID, err)
+	}
+	if note.CreatedAt.IsZero() {
+		note.CreatedAt = time.Now()
+	}
+	res, err := DB.Exec("INSERT INTO finding_notes(vulnerability_id, author, text, evidence, created_at) VALUES(?, ?, ?, ?, ?)",
+		findingID, note.Author, note.Text, note.Evidence, nullTime(note.CreatedAt))
+	if err != nil {
+		return 0, fmt.Errorf("could not save note for finding %d: %w", findingID, err)
+	}
+	return res.LastInsertId()
+}
+
+// GetSuppressionRules returns the suppression rules of a campaign, oldest first.
+func GetSuppressionRules(campaignID int64) ([]model.SuppressionRule, error) {
+	rows, err := DB.Query("SELECT id, cve, host, port, reason, created_at FROM suppression_rules WHERE campaign_id = ? ORDER BY id", campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("could not query suppression rules for campaign %d: %w", campaignID, err)
+	}
+	defer rows.Close()
+	return scanSuppressionRules(rows)
+}
+
+func scanSuppressionRules(rows *sql.Rows) ([]model.SuppressionRule, error) {
+	var rules []model.SuppressionRule
+	for rows.Next() {
+		var r model.SuppressionRule
+		var createdAt sql.NullTime
+		if err := rows.Scan(&r.ID, &r.CVE, &r.Host, &r.Port, &r.Reason, &createdAt); err != nil {
+			return nil, fmt.Errorf("could not scan suppression rule: %w", err)
+		}
+		r.CreatedAt = createdAt.Time
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// CreateSuppressionRule adds a suppression rule to a campaign, applies it to the campaign's
+// untriaged findings and returns its ID and the number of findings it suppressed.
+func CreateSuppressionRule(campaignID int64, rule model.SuppressionRule) (int64, int, error) {
+	rule.CVE, rule.Host = strings.TrimSpace(rule.CVE), strings.TrimSpace(rule.Host)
+	if rule.CVE == "" {
+		return 0, 0, fmt.Errorf("a suppression rule needs a CVE or check ID")
+	}
+	if rule.CreatedAt.IsZero() {
+		rule.CreatedAt = time.Now()
+	}
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, 0, fmt.Errorf("could not begin database transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("INSERT INTO suppression_rules(campaign_id, cve, host, port, reason, created_at) VALUES(?, ?, ?, ?, ?, ?)",
+		campaignID, rule.CVE, rule.Host, rule.Port, rule.Reason, nullTime(rule.CreatedAt))
+	if err != nil {
+		return 0, 0, fmt.Errorf("could not save suppression rule: %w", err)
+	}
+	ruleID, err := res.LastInsertId()
+	if err != nil {
+		return 0, 0, err
+	}
+	suppressed, err := applySuppressionRules(tx, campaignID)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+	return ruleID, suppressed, UpdateRiskScores(campaignID)
+}
+
+// DeleteSuppressionRule removes a suppression rule from a campaign. The findings it suppressed are
+// set back to new, unless another rule of the campaign also matches them.
+func DeleteSuppressionRule(campaignID, ruleID int64) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return fmt.Errorf("could not begin database transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE vulnerabilities SET triage_status = ?, suppression_id = NULL WHERE suppression_id = ? AND host_id IN (SELECT id FROM hosts WHERE campaign_id = ?)",
+		model.TriageNew, ruleID, campaignID); err != nil {
+		return fmt.Errorf("could not restore the findings of suppression rule %d: %w", ruleID, err)
+	}
+	res, err := tx.Exec("DELETE FROM suppression_rules WHERE id = ? AND campaign_id = ?", ruleID, campaignID)
+	if err != nil {
+		return fmt.Errorf("could not delete suppression rule %d: %w", ruleID, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("suppression rule %d not found in campaign %d: %w", ruleID, campaignID, sql.ErrNoRows)
+	}
+	if _, err := applySuppressionRules(tx, campaignID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return UpdateRiskScores(campaignID)
+}
+
+// applySuppressionRules marks the campaign's untriaged findings that a suppression rule matches as
+// false positives and returns how many it marked. Findings someone triaged by hand keep their
+// status, even if it is New.
+func applySuppressionRules(tx *sql.Tx, campaignID int64) (int, error) {
+	ruleRows, err := tx.Query("SELECT id, cve, host, port, reason, created_at FROM suppression_rules WHERE campaign_id = ? ORDER BY id", campaignID)
+	if err != nil {
+		return 0, fmt.Errorf("could not query suppression rules for campaign %d: %w", campaignID, err)
+	}
+	rules, err := scanSuppressionRules(ruleRows)
+	ruleRows.Close()
+	if err != nil || len(rules) == 0 {
+		return 0, err
+	}
+
+	rows, err := tx.Query(`
+        SELECT v.id, v.cve, h.mac_address, h.ip_address, COALESCE(p.port_number, 0)
+        FROM vulnerabilities v JOIN hosts h ON v.host_id = h.id LEFT JOIN ports p ON v.port_id = p.id
+        WHERE h.campaign_id = ? AND v.triage_status = ? AND v.triage_manual = 0`, campaignID, model.TriageNew)
+	if err != nil {
+		return 0, fmt.Errorf("could not query findings to suppress: %w", err)
+	}
+	matches := make(map[int64]int64)
+	for rows.Next() {
+		var vulnID int64
+		var cve, mac, ip string
+		var port int
+		if err := rows.Scan(&vulnID, &cve, &mac, &ip, &port); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		for _, r := range rules {
+			if r.Matches(cve, mac, ip, port) {
+				matches[vulnID] = r.ID
+				break
+			}
+		}
+	}
+	rows.Close()
+
+	for vulnID, ruleID := range matches {
+		if _, err := tx.Exec("UPDATE vulnerabilities SET triage_status = ?, suppression_id = ? WHERE id = ?", model.TriageFalsePositive, ruleID, vulnID); err != nil {
+			return 0, fmt.Errorf("could not suppress finding %d: %w", vulnID, err)
+		}
+	}
+	return len(matches), nil
+}
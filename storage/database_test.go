@@ -1,8 +1,12 @@
 package storage
 
 import (
+	"SnailsHell/migrations"
 	"SnailsHell/model"
 	"bytes"
+	"database/sql"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 )
@@ -255,3 +259,917 @@ func TestSaveAndGetScreenshot(t *testing.T) {
 		t.Error("GetScreenshotByID returned incorrect image data")
 	}
 }
+
+// TestSaveAndGetNmapMetadata verifies that hostnames, OS matches, traceroute hops and scan runs are persisted.
+func TestSaveAndGetNmapMetadata(t *testing.T) {
+	setupTestDB(t)
+
+	campaignID, _ := GetOrCreateCampaign("Nmap Metadata Test")
+	networkMap, summary := model.NewNetworkMap(), model.NewPcapSummary()
+
+	host := model.NewHost("IP:10.9.8.7")
+	host.IPv4Addresses["10.9.8.7"] = true
+	host.Hostnames["db.corp.local"] = "PTR"
+	host.Distance = 2
+	host.Uptime = &model.Uptime{Seconds: 3600, LastBoot: "yesterday"}
+	host.Traceroute = []model.TraceHop{{TTL: 1, IP: "10.9.8.1", RTT: 0.4}, {TTL: 2, IP: "10.9.8.7", RTT: 0.9}}
+	host.Fingerprint.OSMatches = []model.OSMatch{{Name: "Linux 5.X", Accuracy: 95, CPEs: []string{"cpe:/o:linux:linux_kernel:5"}}}
+	host.Ports[5432] = model.Port{ID: 5432, Protocol: "tcp", State: "open", Service: "postgresql", CPEs: []string{"cpe:/a:postgresql:postgresql"}}
+	host.Findings[model.CriticalFinding] = []model.Vulnerability{
+		{CVE: "CVE-2023-38408", Category: model.CriticalFinding, State: "LIKELY VULNERABLE", CVSS: 9.8, Source: "vulners", References: []string{"https://vulners.com/cve/CVE-2023-38408"}},
+	}
+	networkMap.Hosts[host.MACAddress] = host
+	networkMap.ScanRuns = []model.ScanRun{{Scanner: "nmap", Args: "nmap -sV 10.9.8.7", StartTime: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC), HostsUp: 1, ScanInfo: []model.ScanInfo{{Type: "syn", Protocol: "tcp", NumServices: 1000}}}}
+
+	if err := SaveScanResults(campaignID, networkMap, summary); err != nil {
+		t.Fatalf("SaveScanResults failed: %v", err)
+	}
+
+	var hostDBID int64
+	DB.QueryRow("SELECT id FROM hosts WHERE mac_address = ? AND campaign_id = ?", host.MACAddress, campaignID).Scan(&hostDBID)
+	retrievedHost, err := GetHostByID(hostDBID, campaignID)
+	if err != nil {
+		t.Fatalf("GetHostByID failed: %v", err)
+	}
+
+	if retrievedHost.Hostnames["db.corp.local"] != "PTR" {
+		t.Errorf("Hostnames not persisted, got: %v", retrievedHost.Hostnames)
+	}
+	if retrievedHost.Distance != 2 || retrievedHost.Uptime == nil || retrievedHost.Uptime.Seconds != 3600 {
+		t.Errorf("Distance/uptime not persisted, got: %d / %+v", retrievedHost.Distance, retrievedHost.Uptime)
+	}
+	if len(retrievedHost.Traceroute) != 2 || retrievedHost.Traceroute[1].IP != "10.9.8.7" {
+		t.Errorf("Traceroute not persisted, got: %+v", retrievedHost.Traceroute)
+	}
+	if len(retrievedHost.Fingerprint.OSMatches) != 1 || retrievedHost.Fingerprint.OSMatches[0].CPEs[0] != "cpe:/o:linux:linux_kernel:5" {
+		t.Errorf("OS matches not persisted, got: %+v", retrievedHost.Fingerprint.OSMatches)
+	}
+	if cpes := retrievedHost.Ports[5432].CPEs; len(cpes) != 1 || cpes[0] != "cpe:/a:postgresql:postgresql" {
+		t.Errorf("Port CPEs not persisted, got: %v", cpes)
+	}
+
+	if vulns := retrievedHost.Findings[model.CriticalFinding]; len(vulns) != 1 || vulns[0].CVSS != 9.8 || vulns[0].Source != "vulners" || len(vulns[0].References) != 1 {
+		t.Errorf("Vulnerability details not persisted, got: %+v", vulns)
+	}
+
+	runs, err := GetScanRunsByCampaign(campaignID)
+	if err != nil {
+		t.Fatalf("GetScanRunsByCampaign failed: %v", err)
+	}
+	if len(runs) != 1 || runs[0].Args != "nmap -sV 10.9.8.7" || len(runs[0].ScanInfo) != 1 {
+		t.Errorf("Scan run not persisted, got: %+v", runs)
+	}
+
+	// A later, shorter trace replaces the old hops instead of merging with them.
+	host.Traceroute = []model.TraceHop{{TTL: 1, IP: "10.9.8.7", RTT: 0.3}}
+	if err := SaveScanResults(campaignID, networkMap, model.NewPcapSummary()); err != nil {
+		t.Fatalf("SaveScanResults (second trace) failed: %v", err)
+	}
+	retrievedHost, _ = GetHostByID(hostDBID, campaignID)
+	if len(retrievedHost.Traceroute) != 1 || retrievedHost.Traceroute[0].IP != "10.9.8.7" {
+		t.Errorf("Traceroute not replaced, got: %+v", retrievedHost.Traceroute)
+	}
+	// Saving the same live run again, which has no source file, does not duplicate it.
+	if runs, _ := GetScanRunsByCampaign(campaignID); len(runs) != 1 {
+		t.Errorf("Expected the resaved scan run to be replaced, got %d runs", len(runs))
+	}
+}
+
+// TestResaveIsIdempotent verifies that saving the same results again updates existing rows
+// instead of duplicating them, and that packet counts add up unless the data is being reprocessed.
+func TestResaveIsIdempotent(t *testing.T) {
+	setupTestDB(t)
+
+	campaignID, _ := GetOrCreateCampaign("Resave Test")
+	networkMap, summary := model.NewNetworkMap(), model.NewPcapSummary()
+
+	host := model.NewHost("00:11:22:33:44:77")
+	host.IPv4Addresses["10.7.7.7"] = true
+	host.Ports[21] = model.Port{ID: 21, Protocol: "tcp", State: "open", Service: "ftp"}
+	host.Findings[model.PotentialFinding] = []model.Vulnerability{
+		{CVE: "CVE-2011-2523", Category: model.PotentialFinding, PortID: 21, Source: "vulners", CVSS: 7.5},
+		{CVE: "SURICATA-2027863", Category: model.PotentialFinding, Source: "Suricata SID 2027863"},
+	}
+	host.Communications["8.8.8.8"] = &model.Communication{CounterpartIP: "8.8.8.8", PacketCount: 10}
+	host.WebResponses = []model.WebResponse{{PortID: 21, Method: "GET", StatusCode: 200}}
+	host.FTPResults = []model.FTPResult{{PortID: 21, Address: "10.7.7.7:21", Status: "Anonymous login successful"}}
+	networkMap.Hosts[host.MACAddress] = host
+	networkMap.ScanRuns = []model.ScanRun{{Scanner: "nmap", SourceFile: "/data/scan.xml"}}
+	summary.Credentials = []model.Credential{{HostMAC: host.MACAddress, Endpoint: "10.7.7.1:21", Type: "FTP", Value: "anonymous:guest", PcapFile: "ftp.pcap"}}
+	summary.CapturedHandshakes = []model.Handshake{{APMAC: "aa:aa:aa:aa:aa:aa", ClientMAC: "bb:bb:bb:bb:bb:bb", SSID: "corp", HandshakeState: "Partial", PcapFile: "wifi.pcap"}}
+
+	for i := 0; i < 2; i++ {
+		if err := SaveScanResults(campaignID, networkMap, summary); err != nil {
+			t.Fatalf("SaveScanResults #%d failed: %v", i+1, err)
+		}
+	}
+	host.Findings[model.PotentialFinding][0].CVSS = 9.8
+	summary.CapturedHandshakes[0].HandshakeState = "Full"
+	if err := SaveScanResultsWithOptions(campaignID, networkMap, summary, SaveOptions{Reprocess: true}); err != nil {
+		t.Fatalf("SaveScanResultsWithOptions failed: %v", err)
+	}
+
+	var hostDBID int64
+	DB.QueryRow("SELECT id FROM hosts WHERE mac_address = ? AND campaign_id = ?", host.MACAddress, campaignID).Scan(&hostDBID)
+	want := map[string]int{"vulnerabilities": 2, "communications": 1, "credentials": 1, "web_responses": 1, "ftp_results": 1}
+	for table, expected := range want {
+		var count int
+		DB.QueryRow("SELECT COUNT(*) FROM "+table+" WHERE host_id = ?", hostDBID).Scan(&count)
+		if count != expected {
+			t.Errorf("Expected %d rows in %s after re-saving, got %d", expected, table, count)
+		}
+	}
+
+	retrievedHost, err := GetHostByID(hostDBID, campaignID)
+	if err != nil {
+		t.Fatalf("GetHostByID failed: %v", err)
+	}
+	if comm := retrievedHost.Communications["8.8.8.8"]; comm == nil || comm.PacketCount != 20 {
+		t.Errorf("Expected packet counts to add up across saves but not on reprocess, got %+v", comm)
+	}
+	for _, vuln := range retrievedHost.Findings[model.PotentialFinding] {
+		if vuln.CVE == "CVE-2011-2523" && vuln.CVSS != 9.8 {
+			t.Errorf("Expected the finding to be updated in place, got %+v", vuln)
+		}
+	}
+
+	var handshakeCount int
+	var handshakeState string
+	DB.QueryRow("SELECT COUNT(*), MAX(state) FROM handshakes WHERE campaign_id = ?", campaignID).Scan(&handshakeCount, &handshakeState)
+	if handshakeCount != 1 || handshakeState != "Full" {
+		t.Errorf("Expected one updated handshake, got %d with state %q", handshakeCount, handshakeState)
+	}
+	if runs, _ := GetScanRunsByCampaign(campaignID); len(runs) != 1 || runs[0].SourceFile != "/data/scan.xml" {
+		t.Errorf("Expected the scan run from the source file to be replaced, got %+v", runs)
+	}
+}
+
+// TestIngestedFileRegistry verifies that ingested files are recorded per campaign and updated in place.
+func TestIngestedFileRegistry(t *testing.T) {
+	setupTestDB(t)
+
+	campaignID, _ := GetOrCreateCampaign("Ingest Registry Test")
+	runID, err := StartIngestRun(campaignID, "/data", false)
+	if err != nil {
+		t.Fatalf("StartIngestRun failed: %v", err)
+	}
+
+	modTime := time.Date(2024, 3, 1, 12, 30, 45, 123456789, time.Local)
+	file := model.IngestedFile{Path: "/data/scan.xml", Size: 2048, ModTime: modTime, SHA256: "abc123", FileType: "nmap-xml"}
+	if err := RecordIngestedFiles(campaignID, runID, []model.IngestedFile{file}); err != nil {
+		t.Fatalf("RecordIngestedFiles failed: %v", err)
+	}
+	file.Size, file.SHA256 = 4096, "def456"
+	if err := RecordIngestedFiles(campaignID, runID, []model.IngestedFile{file}); err != nil {
+		t.Fatalf("RecordIngestedFiles update failed: %v", err)
+	}
+	if err := FinishIngestRun(runID, 1, 0, nil); err != nil {
+		t.Fatalf("FinishIngestRun failed: %v", err)
+	}
+
+	files, err := GetIngestedFiles(campaignID)
+	if err != nil {
+		t.Fatalf("GetIngestedFiles failed: %v", err)
+	}
+	got, ok := files["/data/scan.xml"]
+	if len(files) != 1 || !ok {
+		t.Fatalf("Expected one registry entry, got %+v", files)
+	}
+	if got.Size != 4096 || got.SHA256 != "def456" || got.IngestRunID != runID {
+		t.Errorf("Registry entry not updated, got %+v", got)
+	}
+	if !got.ModTime.Equal(modTime) {
+		t.Errorf("Modification time not preserved exactly, got %v want %v", got.ModTime, modTime)
+	}
+
+	otherID, _ := GetOrCreateCampaign("Ingest Registry Other")
+	if others, _ := GetIngestedFiles(otherID); len(others) != 0 {
+		t.Errorf("Expected registry to be per campaign, got %+v", others)
+	}
+
+	failedRunID, _ := StartIngestRun(campaignID, "/data", false)
+	if err := FinishIngestRun(failedRunID, 0, 0, errors.New("disk full")); err != nil {
+		t.Fatalf("FinishIngestRun with an error failed: %v", err)
+	}
+	var runError string
+	DB.QueryRow("SELECT error FROM ingest_runs WHERE id = ?", failedRunID).Scan(&runError)
+	if runError != "disk full" {
+		t.Errorf("Expected the failed run to record its error, got %q", runError)
+	}
+}
+
+// TestMigrationDeduplicatesRows verifies that upgrading a database with duplicate rows collapses
+// them before the uniqueness constraints are added.
+func TestMigrationDeduplicatesRows(t *testing.T) {
+	db, err := sql.Open("sqlite", "file:dedupe_test?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	setup := []string{"CREATE TABLE db_meta (key TEXT PRIMARY KEY, value TEXT);", "INSERT INTO db_meta (key, value) VALUES ('version', '8');"}
+	for _, m := range migrations.GetMigrations() {
+		if m.Version <= 8 {
+			setup = append(setup, m.Script)
+		}
+	}
+	setup = append(setup,
+		"INSERT INTO campaigns (id, name, created_at) VALUES (1, 'Old', CURRENT_TIMESTAMP);",
+		"INSERT INTO hosts (id, campaign_id, mac_address) VALUES (1, 1, '00:00:00:00:00:01');",
+		"INSERT INTO communications (host_id, counterpart_ip, packet_count) VALUES (1, '1.1.1.1', 5), (1, '1.1.1.1', 7);",
+		"INSERT INTO vulnerabilities (host_id, cve, description) VALUES (1, 'CVE-1', 'old'), (1, 'CVE-1', 'new');",
+		"INSERT INTO credentials (campaign_id, host_id, endpoint, type, value) VALUES (1, 1, 'e', 'FTP', 'a:b'), (1, 1, 'e', 'FTP', 'a:b');",
+	)
+	for _, script := range setup {
+		if _, err := db.Exec(script); err != nil {
+			t.Fatalf("Failed to prepare version 8 database: %v", err)
+		}
+	}
+
+	if err := migrateDB(db); err != nil {
+		t.Fatalf("migrateDB failed: %v", err)
+	}
+
+	var commCount, packets int
+	db.QueryRow("SELECT COUNT(*), SUM(packet_count) FROM communications").Scan(&commCount, &packets)
+	if commCount != 1 || packets != 12 {
+		t.Errorf("Expected one communication with 12 packets, got %d rows with %d packets", commCount, packets)
+	}
+	var vulnCount int
+	var description string
+	db.QueryRow("SELECT COUNT(*), MAX(description) FROM vulnerabilities").Scan(&vulnCount, &description)
+	if vulnCount != 1 || description != "new" {
+		t.Errorf("Expected the newest vulnerability row to be kept, got %d rows (%q)", vulnCount, description)
+	}
+	var credCount int
+	db.QueryRow("SELECT COUNT(*) FROM credentials").Scan(&credCount)
+	if credCount != 1 {
+		t.Errorf("Expected duplicate credentials to be collapsed, got %d", credCount)
+	}
+}
+
+// TestWirelessInventoryRoundTrip verifies that access points, clients and probed SSIDs are saved,
+// merged on re-save and read back with their associated client counts.
+func TestWirelessInventoryRoundTrip(t *testing.T) {
+	setupTestDB(t)
+
+	campaignID, _ := GetOrCreateCampaign("Wireless Test")
+	first := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	summary := model.NewPcapSummary()
+	summary.AccessPoints["00:11:22:33:44:55"] = &model.WirelessAP{BSSID: "00:11:22:33:44:55", SSID: "CorpNet", Channel: 6, Encryption: "WPA2", AKM: "PSK", Ciphers: "CCMP",
+		RSSI: -60, Beacons: 10, FirstSeen: first, LastSeen: first.Add(time.Minute)}
+	summary.WirelessClients["02:AA:BB:CC:DD:01"] = &model.WirelessClient{MAC: "02:AA:BB:CC:DD:01", AssociatedBSSID: "00:11:22:33:44:55",
+		ProbedSSIDs: map[string]bool{"HomeWiFi": true}, RSSI: -70, Frames: 5, FirstSeen: first, LastSeen: first}
+	if err := SaveScanResults(campaignID, model.NewNetworkMap(), summary); err != nil {
+		t.Fatalf("SaveScanResults failed: %v", err)
+	}
+
+	// A later capture sees the AP again, without its SSID but with a stronger signal.
+	later := model.NewPcapSummary()
+	later.AccessPoints["00:11:22:33:44:55"] = &model.WirelessAP{BSSID: "00:11:22:33:44:55", Encryption: "WPA2", WPS: true, RSSI: -45, Beacons: 5,
+		FirstSeen: first.Add(time.Hour), LastSeen: first.Add(2 * time.Hour)}
+	later.WirelessClients["02:AA:BB:CC:DD:01"] = &model.WirelessClient{MAC: "02:AA:BB:CC:DD:01", ProbedSSIDs: map[string]bool{"CoffeeShop": true}, Frames: 3}
+	if err := SaveScanResults(campaignID, model.NewNetworkMap(), later); err != nil {
+		t.Fatalf("SaveScanResults (later) failed: %v", err)
+	}
+
+	aps, err := GetWirelessAccessPoints(campaignID)
+	if err != nil || len(aps) != 1 {
+		t.Fatalf("Expected one access point, got %+v (%v)", aps, err)
+	}
+	ap := aps[0]
+	if ap.SSID != "CorpNet" || !ap.WPS || ap.RSSI != -45 || ap.Beacons != 15 || ap.Clients != 1 || ap.Channel != 6 {
+		t.Errorf("Unexpected merged access point: %+v", ap)
+	}
+	if !ap.FirstSeen.Equal(first) || !ap.LastSeen.Equal(first.Add(2*time.Hour)) {
+		t.Errorf("Expected the seen window to widen, got %v - %v", ap.FirstSeen, ap.LastSeen)
+	}
+
+	clients, err := GetWirelessClients(campaignID)
+	if err != nil || len(clients) != 1 {
+		t.Fatalf("Expected one client, got %+v (%v)", clients, err)
+	}
+	client := clients[0]
+	if client.AssociatedBSSID != "00:11:22:33:44:55" || client.Frames != 8 || client.RSSI != -70 || len(client.ProbedSSIDs) != 2 {
+		t.Errorf("Unexpected merged client: %+v", client)
+	}
+
+	if summary, err := GetDashboardSummary(campaignID); err != nil || summary.WirelessAPCount != 1 {
+		t.Errorf("Expected one access point on the dashboard, got %+v (%v)", summary, err)
+	}
+}
+
+// TestDeauthPairsRoundTrip verifies that deauth activity is merged on re-save with its reason
+// codes, and that a handshake stored later marks the pair as captured.
+func TestDeauthPairsRoundTrip(t *testing.T) {
+	setupTestDB(t)
+
+	campaignID, _ := GetOrCreateCampaign("Deauth Test")
+	first := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	summary := model.NewPcapSummary()
+	summary.DeauthPairs = []model.DeauthPair{{APMAC: "00:11:22:33:44:55", ClientMAC: "02:AA:BB:CC:DD:01", SSID: "CorpNet", Deauths: 3,
+		Reasons: map[int]int{7: 3}, FirstSeen: first, LastSeen: first.Add(time.Second), HandshakeState: "Partial", PcapFile: "a.pcap"}}
+	if err := SaveScanResults(campaignID, model.NewNetworkMap(), summary); err != nil {
+		t.Fatalf("SaveScanResults failed: %v", err)
+	}
+
+	later := model.NewPcapSummary()
+	later.DeauthPairs = []model.DeauthPair{{APMAC: "00:11:22:33:44:55", ClientMAC: "02:AA:BB:CC:DD:01", Deauths: 1, Disassocs: 2,
+		Reasons: map[int]int{7: 1, 8: 2}, FirstSeen: first.Add(time.Hour), LastSeen: first.Add(time.Hour), ReconnectedAt: first.Add(time.Hour + time.Second), PcapFile: "b.pcap"}}
+	if err := SaveScanResults(campaignID, model.NewNetworkMap(), later); err != nil {
+		t.Fatalf("SaveScanResults (later) failed: %v", err)
+	}
+
+	pairs, err := GetDeauthPairs(campaignID)
+	if err != nil || len(pairs) != 1 {
+		t.Fatalf("Expected one deauth pair, got %+v (%v)", pairs, err)
+	}
+	p := pairs[0]
+	if p.SSID != "CorpNet" || p.Deauths != 4 || p.Disassocs != 2 || p.Reasons[7] != 4 || p.Reasons[8] != 2 || p.HandshakeState != "Partial" {
+		t.Errorf("Unexpected merged pair: %+v", p)
+	}
+	if !p.FirstSeen.Equal(first) || !p.LastSeen.Equal(first.Add(time.Hour)) || !p.ReconnectedAt.Equal(first.Add(time.Hour+time.Second)) {
+		t.Errorf("Unexpected merged times: %+v", p)
+	}
+
+	withHandshake := model.NewPcapSummary()
+	withHandshake.CapturedHandshakes = []model.Handshake{{APMAC: "00:11:22:33:44:55", ClientMAC: "02:AA:BB:CC:DD:01", SSID: "CorpNet", HandshakeState: "Full", PcapFile: "c.pcap"}}
+	if err := SaveScanResults(campaignID, model.NewNetworkMap(), withHandshake); err != nil {
+		t.Fatalf("SaveScanResults (handshake) failed: %v", err)
+	}
+	if pairs, _ := GetDeauthPairs(campaignID); len(pairs) != 1 || pairs[0].HandshakeState != "Full" {
+		t.Errorf("Expected the pair to count as captured once a handshake is stored, got %+v", pairs)
+	}
+}
+
+func TestTLSSessionsRoundTrip(t *testing.T) {
+	setupTestDB(t)
+
+	campaignID, _ := GetOrCreateCampaign("TLS Test")
+	first := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	newMap := func(session *model.TLSSession) *model.NetworkMap {
+		networkMap := model.NewNetworkMap()
+		host := model.NewHost("02:00:00:00:00:05")
+		host.IPv4Addresses["10.0.0.5"] = true
+		host.TLSSessions[session.Key()] = session
+		networkMap.Hosts[host.MACAddress] = host
+		return networkMap
+	}
+
+	cert := &model.TLSCertificate{Subject: "CN=intranet.corp.example", Issuer: "CN=intranet.corp.example", SANs: []string{"intranet.corp.example", "10.0.0.10"},
+		NotBefore: first.AddDate(-1, 0, 0), NotAfter: first.AddDate(1, 0, 0), SelfSigned: true, SHA256: "abcd"}
+	session := &model.TLSSession{ClientIP: "10.0.0.5", ServerIP: "10.0.0.10", ServerPort: 443, SNI: "intranet.corp.example", ALPN: "h2", Version: "TLS 1.2",
+		Cipher: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", JA3: "ja3", JA3S: "ja3s", JA4: "ja4", Certificate: cert, Connections: 2, FirstSeen: first, LastSeen: first, PcapFile: "a.pcap"}
+	if err := SaveScanResults(campaignID, newMap(session), model.NewPcapSummary()); err != nil {
+		t.Fatalf("SaveScanResults failed: %v", err)
+	}
+	// A later capture only saw the ClientHello; it must not clear the negotiated fields.
+	partial := &model.TLSSession{ClientIP: "10.0.0.5", ServerIP: "10.0.0.10", ServerPort: 443, SNI: "intranet.corp.example", JA3: "ja3", JA4: "ja4",
+		Connections: 1, FirstSeen: first.Add(time.Hour), LastSeen: first.Add(time.Hour), PcapFile: "b.pcap"}
+	if err := SaveScanResults(campaignID, newMap(partial), model.NewPcapSummary()); err != nil {
+		t.Fatalf("SaveScanResults (partial) failed: %v", err)
+	}
+
+	var hostID int64
+	if err := DB.QueryRow("SELECT id FROM hosts WHERE campaign_id = ? AND mac_address = ?", campaignID, "02:00:00:00:00:05").Scan(&hostID); err != nil {
+		t.Fatalf("Could not find saved host: %v", err)
+	}
+	host, err := GetHostByID(hostID, campaignID)
+	if err != nil || len(host.TLSSessions) != 1 {
+		t.Fatalf("Expected one TLS session, got %+v (%v)", host, err)
+	}
+	got := host.TLSSessions[session.Key()]
+	if got == nil || got.Version != "TLS 1.2" || got.Cipher != session.Cipher || got.JA3S != "ja3s" || got.Connections != 3 || got.PcapFile != "b.pcap" {
+		t.Errorf("Unexpected merged session: %+v", got)
+	}
+	if !got.FirstSeen.Equal(first) || !got.LastSeen.Equal(first.Add(time.Hour)) {
+		t.Errorf("Unexpected seen window: %v - %v", got.FirstSeen, got.LastSeen)
+	}
+	if got.Certificate == nil || got.Certificate.Subject != cert.Subject || len(got.Certificate.SANs) != 2 || !got.Certificate.SelfSigned || !got.Certificate.NotAfter.Equal(cert.NotAfter) {
+		t.Errorf("Unexpected certificate: %+v", got.Certificate)
+	}
+
+	rows, err := GetAllTLSSessionsForReport(campaignID)
+	if err != nil || len(rows) != 1 || rows[0][4] != "intranet.corp.example" || rows[0][13] != "intranet.corp.example 10.0.0.10" {
+		t.Errorf("Unexpected report rows %v (%v)", rows, err)
+	}
+}
+
+func TestHTTPTransactionsRoundTrip(t *testing.T) {
+	setupTestDB(t)
+
+	campaignID, _ := GetOrCreateCampaign("HTTP Test")
+	first := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	networkMap := model.NewNetworkMap()
+	host := model.NewHost("02:00:00:00:00:05")
+	host.IPv4Addresses["10.0.0.5"] = true
+	host.Communications["10.0.0.10"] = &model.Communication{CounterpartIP: "10.0.0.10", PacketCount: 12}
+	host.HTTPRequests = []model.HTTPTransaction{
+		{ClientIP: "10.0.0.5", ServerIP: "10.0.0.10", ServerPort: 80, Method: "POST", Host: "wiki.corp.example", URI: "/login", StatusCode: 302,
+			UserAgent: "TestAgent/1.0", Server: "nginx", RequestSize: 11, Timestamp: first.Add(time.Second), PcapFile: "a.pcap"},
+		{ClientIP: "10.0.0.5", ServerIP: "10.0.0.10", ServerPort: 80, Method: "GET", Host: "wiki.corp.example", URI: "/", StatusCode: 200,
+			ContentType: "text/html", ResponseSize: 512, Timestamp: first, PcapFile: "a.pcap"},
+	}
+	networkMap.Hosts[host.MACAddress] = host
+	// Importing the same capture twice must not duplicate its transactions.
+	for i := 0; i < 2; i++ {
+		if err := SaveScanResults(campaignID, networkMap, model.NewPcapSummary()); err != nil {
+			t.Fatalf("SaveScanResults failed: %v", err)
+		}
+	}
+
+	var hostID int64
+	if err := DB.QueryRow("SELECT id FROM hosts WHERE campaign_id = ? AND mac_address = ?", campaignID, "02:00:00:00:00:05").Scan(&hostID); err != nil {
+		t.Fatalf("Could not find saved host: %v", err)
+	}
+	saved, err := GetHostByID(hostID, campaignID)
+	if err != nil || len(saved.HTTPRequests) != 2 {
+		t.Fatalf("Expected two HTTP transactions, got %+v (%v)", saved, err)
+	}
+	get := saved.HTTPRequests[0]
+	if get.Method != "GET" || get.URL() != "http://wiki.corp.example/" || get.StatusCode != 200 || get.ContentType != "text/html" || get.ResponseSize != 512 || !get.Timestamp.Equal(first) {
+		t.Errorf("Unexpected first transaction: %+v", get)
+	}
+
+	var linked int
+	if err := DB.QueryRow(`SELECT COUNT(*) FROM http_transactions t JOIN communications c ON t.communication_id = c.id
+		WHERE t.host_id = ? AND c.counterpart_ip = '10.0.0.10'`, hostID).Scan(&linked); err != nil || linked != 2 {
+		t.Errorf("Expected both transactions linked to the communication, got %d (%v)", linked, err)
+	}
+
+	rows, err := GetAllHTTPTransactionsForReport(campaignID)
+	if err != nil || len(rows) != 2 || rows[1][5] != "POST" || rows[1][8] != "302" || rows[1][12] != "11" {
+		t.Errorf("Unexpected report rows %v (%v)", rows, err)
+	}
+
+	// An identical request pipelined on the same connection is a transaction of its own.
+	pipelined := host.HTTPRequests[1]
+	pipelined.StreamOffset = 40
+	host.HTTPRequests = append(host.HTTPRequests, pipelined)
+	for i := 0; i < 2; i++ {
+		if err := SaveScanResults(campaignID, networkMap, model.NewPcapSummary()); err != nil {
+			t.Fatalf("SaveScanResults failed: %v", err)
+		}
+	}
+	if saved, _ = GetHostByID(hostID, campaignID); len(saved.HTTPRequests) != 3 || saved.HTTPRequests[1].StreamOffset != 40 {
+		t.Errorf("Expected the pipelined request to be kept once, got %+v", saved.HTTPRequests)
+	}
+}
+
+func TestCarvedFilesRoundTrip(t *testing.T) {
+	setupTestDB(t)
+
+	campaignID, _ := GetOrCreateCampaign("Carving Test")
+	first := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	networkMap := model.NewNetworkMap()
+	host := model.NewHost("02:00:00:00:00:05")
+	host.IPv4Addresses["10.0.0.5"] = true
+	networkMap.Hosts[host.MACAddress] = host
+	summary := model.NewPcapSummary()
+	summary.CarvedFiles = []model.CarvedFile{
+		{HostMAC: "02:00:00:00:00:05", Filename: "fw.bin", MIMEType: "application/octet-stream", Size: 4, SHA256: "aa", Protocol: "HTTP",
+			SourceIP: "10.0.0.10", DestinationIP: "10.0.0.5", Timestamp: first, PcapFile: "a.pcap", Data: []byte{1, 2, 3, 4}},
+		{HostMAC: "02:00:00:00:00:99", Filename: "boot.cfg", MIMEType: "text/plain", Size: 2, SHA256: "bb", Protocol: "TFTP",
+			SourceIP: "10.0.0.20", DestinationIP: "10.0.0.21", Timestamp: first.Add(time.Minute), PcapFile: "a.pcap", Data: []byte("ok")},
+	}
+	// Importing the same capture twice must not duplicate its files.
+	for i := 0; i < 2; i++ {
+		if err := SaveScanResults(campaignID, networkMap, summary); err != nil {
+			t.Fatalf("SaveScanResults failed: %v", err)
+		}
+	}
+
+	files, err := GetCarvedFilesByCampaign(campaignID)
+	if err != nil || len(files) != 2 {
+		t.Fatalf("Expected two carved files, got %+v (%v)", files, err)
+	}
+	if files[0].Filename != "boot.cfg" || files[0].HostMAC != "" || files[0].Data != nil {
+		t.Errorf("Expected the newest file first, without a host or contents: %+v", files[0])
+	}
+	if files[1].HostMAC != "02:00:00:00:00:05" || files[1].Protocol != "HTTP" || !files[1].Timestamp.Equal(first) {
+		t.Errorf("Unexpected carved file: %+v", files[1])
+	}
+
+	file, err := GetCarvedFileByID(files[1].ID)
+	if err != nil || !bytes.Equal(file.Data, []byte{1, 2, 3, 4}) || file.Filename != "fw.bin" {
+		t.Errorf("Unexpected carved file contents %+v (%v)", file, err)
+	}
+	if _, err := GetCarvedFileByID(files[1].ID + 100); err == nil {
+		t.Error("Expected an error for a missing carved file")
+	}
+
+	dashboard, err := GetDashboardSummary(campaignID)
+	if err != nil || dashboard.CarvedFileCount != 2 {
+		t.Errorf("Expected the dashboard to count two carved files, got %+v (%v)", dashboard, err)
+	}
+}
+
+func TestDNSAnswersAndResolversRoundTrip(t *testing.T) {
+	setupTestDB(t)
+
+	campaignID, _ := GetOrCreateCampaign("DNS Test")
+	seen := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	networkMap := model.NewNetworkMap()
+	client := model.NewHost("02:00:00:00:00:05")
+	client.IPv4Addresses["10.0.0.5"] = true
+	client.Communications["93.184.216.34"] = &model.Communication{CounterpartIP: "93.184.216.34", PacketCount: 10}
+	client.DNSAnswers = map[string]*model.DNSAnswer{}
+	for _, a := range []*model.DNSAnswer{
+		{Name: "www.example.com", Type: "CNAME", Answer: "edge.cdn.example", TTL: 300, ResolverIP: "8.8.8.8", Count: 1, FirstSeen: seen, LastSeen: seen},
+		{Name: "edge.cdn.example", Type: "A", Answer: "93.184.216.34", TTL: 60, ResolverIP: "8.8.8.8", Count: 1, FirstSeen: seen, LastSeen: seen},
+	} {
+		client.DNSAnswers[a.Key()] = a
+	}
+	resolver := &model.DNSResolver{IP: "8.8.8.8", Protocol: "DNS", Queries: 4, Responses: 4, NXDomain: 1, AvgResponseMs: 10, TimedResponses: 2, FirstSeen: seen, LastSeen: seen}
+	client.DNSResolvers[resolver.Key()] = resolver
+	networkMap.Hosts[client.MACAddress] = client
+	// Another host only talked to the address; it is labelled from the first host's answers.
+	other := model.NewHost("02:00:00:00:00:06")
+	other.IPv4Addresses["10.0.0.6"] = true
+	other.Communications["93.184.216.34"] = &model.Communication{CounterpartIP: "93.184.216.34", PacketCount: 3}
+	networkMap.Hosts[other.MACAddress] = other
+
+	if err := SaveScanResults(campaignID, networkMap, model.NewPcapSummary()); err != nil {
+		t.Fatalf("SaveScanResults failed: %v", err)
+	}
+	// A second capture adds to the counts and averages the response times.
+	resolver.Queries, resolver.Responses, resolver.NXDomain, resolver.AvgResponseMs, resolver.TimedResponses = 2, 2, 0, 40, 1
+	if err := SaveScanResults(campaignID, networkMap, model.NewPcapSummary()); err != nil {
+		t.Fatalf("SaveScanResults failed: %v", err)
+	}
+
+	var clientID, otherID int64
+	DB.QueryRow("SELECT id FROM hosts WHERE campaign_id = ? AND mac_address = ?", campaignID, "02:00:00:00:00:05").Scan(&clientID)
+	DB.QueryRow("SELECT id FROM hosts WHERE campaign_id = ? AND mac_address = ?", campaignID, "02:00:00:00:00:06").Scan(&otherID)
+	saved, err := GetHostByID(clientID, campaignID)
+	if err != nil {
+		t.Fatalf("GetHostByID failed: %v", err)
+	}
+	if a := saved.DNSAnswers["edge.cdn.example|A|93.184.216.34"]; a == nil || a.Count != 2 || a.TTL != 60 || a.ResolverIP != "8.8.8.8" || !a.FirstSeen.Equal(seen) {
+		t.Errorf("Unexpected saved answers %v", saved.DNSAnswers)
+	}
+	r := saved.DNSResolvers["DNS|8.8.8.8"]
+	if r == nil || r.Queries != 6 || r.Responses != 6 || r.NXDomain != 1 || r.TimedResponses != 3 || r.AvgResponseMs != 20 {
+		t.Errorf("Unexpected saved resolver %+v", r)
+	}
+	want := []string{"edge.cdn.example", "www.example.com"}
+	if comm := saved.Communications["93.184.216.34"]; comm == nil || strings.Join(comm.Hostnames, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected the communication to be labelled %v, got %+v", want, comm)
+	}
+	savedOther, err := GetHostByID(otherID, campaignID)
+	if err != nil || strings.Join(savedOther.Communications["93.184.216.34"].Hostnames, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected the other host's communication to be labelled too, got %+v (%v)", savedOther, err)
+	}
+
+	rows, err := GetAllCommsForReport(campaignID)
+	if err != nil || len(rows) != 2 || rows[0][6] != "edge.cdn.example; www.example.com" {
+		t.Errorf("Unexpected communication rows %v (%v)", rows, err)
+	}
+	if rows, err := GetAllDNSAnswersForReport(campaignID); err != nil || len(rows) != 2 || rows[0][1] != "edge.cdn.example" || rows[0][6] != "2" {
+		t.Errorf("Unexpected DNS answer rows %v (%v)", rows, err)
+	}
+	if rows, err := GetAllDNSResolversForReport(campaignID); err != nil || len(rows) != 1 || rows[0][4] != "6" || rows[0][8] != "20.0" {
+		t.Errorf("Unexpected DNS resolver rows %v (%v)", rows, err)
+	}
+}
+
+func TestFindingEvidenceRoundTrip(t *testing.T) {
+	setupTestDB(t)
+
+	campaignID, _ := GetOrCreateCampaign("Evidence Test")
+	newMap := func(file string, packet int) *model.NetworkMap {
+		networkMap := model.NewNetworkMap()
+		host := model.NewHost("02:00:00:00:00:0A")
+		host.IPv4Addresses["10.0.0.10"] = true
+		host.Findings[model.CriticalFinding] = []model.Vulnerability{{CVE: "INSECURE-TELNET", Description: "Host serves Telnet on port 23.", State: "DETECTED",
+			Category: model.CriticalFinding, Source: "Passive protocol analysis", PortID: 23, EvidenceFile: file, EvidencePacket: packet}}
+		networkMap.Hosts[host.MACAddress] = host
+		return networkMap
+	}
+	if err := SaveScanResults(campaignID, newMap("first.pcap", 42), model.NewPcapSummary()); err != nil {
+		t.Fatalf("SaveScanResults failed: %v", err)
+	}
+	// A later capture showing the same finding keeps the first evidence.
+	if err := SaveScanResults(campaignID, newMap("second.pcap", 7), model.NewPcapSummary()); err != nil {
+		t.Fatalf("SaveScanResults failed: %v", err)
+	}
+
+	var hostID int64
+	DB.QueryRow("SELECT id FROM hosts WHERE campaign_id = ? AND mac_address = ?", campaignID, "02:00:00:00:00:0A").Scan(&hostID)
+	host, err := GetHostByID(hostID, campaignID)
+	if err != nil {
+		t.Fatalf("GetHostByID failed: %v", err)
+	}
+	if vulns := host.Findings[model.CriticalFinding]; len(vulns) != 1 || vulns[0].EvidenceFile != "first.pcap" || vulns[0].EvidencePacket != 42 {
+		t.Errorf("Unexpected findings %+v", vulns)
+	}
+	rows, err := GetAllVulnsForReport(campaignID)
+	if err != nil || len(rows) != 1 || rows[0][8] != "first.pcap, packet 42" {
+		t.Errorf("Unexpected report rows %v (%v)", rows, err)
+	}
+}
+
+func TestOTOperationsRoundTrip(t *testing.T) {
+	setupTestDB(t)
+
+	campaignID, _ := GetOrCreateCampaign("OT Test")
+	seenAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	newMap := func() *model.NetworkMap {
+		networkMap := model.NewNetworkMap()
+		host := model.NewHost("02:00:00:00:00:0A")
+		host.IPv4Addresses["10.0.0.10"] = true
+		host.OTIdentities["Modbus/TCP"] = "Schneider Electric BMX P34 2020 v2.70"
+		op := &model.OTOperation{Protocol: "Modbus/TCP", Role: "server", Peer: "10.0.0.5", Port: 502, UnitID: "1",
+			Function: "Write Single Register (6)", Operation: model.OTWrite, Count: 2, FirstSeen: seenAt, LastSeen: seenAt}
+		host.OTOperations[op.Key()] = op
+		networkMap.Hosts[host.MACAddress] = host
+		return networkMap
+	}
+	for i := 0; i < 2; i++ {
+		if err := SaveScanResults(campaignID, newMap(), model.NewPcapSummary()); err != nil {
+			t.Fatalf("SaveScanResults failed: %v", err)
+		}
+	}
+
+	var hostID int64
+	DB.QueryRow("SELECT id FROM hosts WHERE campaign_id = ? AND mac_address = ?", campaignID, "02:00:00:00:00:0A").Scan(&hostID)
+	host, err := GetHostByID(hostID, campaignID)
+	if err != nil {
+		t.Fatalf("GetHostByID failed: %v", err)
+	}
+	if len(host.OTOperations) != 1 || host.OTIdentities["Modbus/TCP"] != "Schneider Electric BMX P34 2020 v2.70" {
+		t.Fatalf("Unexpected OT data %v %v", host.OTOperations, host.OTIdentities)
+	}
+	for _, op := range host.OTOperations {
+		if op.Count != 4 || op.Port != 502 || op.UnitID != "1" || !op.FirstSeen.Equal(seenAt) {
+			t.Errorf("Unexpected operation %+v", op)
+		}
+	}
+	rows, err := GetAllOTOperationsForReport(campaignID)
+	if err != nil || len(rows) != 1 || rows[0][1] != model.OTRoleController || rows[0][9] != "4" || rows[0][12] != "Schneider Electric BMX P34 2020 v2.70" {
+		t.Errorf("Unexpected report rows %v (%v)", rows, err)
+	}
+}
+
+func TestCVERecordsRoundTrip(t *testing.T) {
+	setupTestDB(t)
+
+	record := model.CVERecord{ID: "CVE-2023-38408", Description: "ssh-agent remote code execution", CVSS: 9.8,
+		Published: time.Date(2023, 7, 20, 3, 15, 0, 0, time.UTC), References: []string{"https://www.openssh.com/txt/release-9.3p2"},
+		Affected: []model.CVEAffected{{Vendor: "openbsd", Product: "openssh", EndExcluding: "9.3"}}}
+	if err := SaveCVERecords([]model.CVERecord{record}); err != nil {
+		t.Fatalf("SaveCVERecords failed: %v", err)
+	}
+	// A newer feed replaces the record and its products.
+	record.CVSS = 9.9
+	record.Affected = []model.CVEAffected{{Vendor: "openbsd", Product: "openssh", EndExcluding: "9.3p2"}, {Ecosystem: "Debian:12", Product: "openssh", Version: "9.3"}}
+	if err := SaveCVERecords([]model.CVERecord{record, {ID: "CVE-2021-41773", Affected: []model.CVEAffected{{Vendor: "apache", Product: "http_server", Version: "2.4.49"}}}}); err != nil {
+		t.Fatalf("SaveCVERecords failed: %v", err)
+	}
+
+	if count, err := CountCVERecords(); err != nil || count != 2 {
+		t.Errorf("Expected 2 CVEs, got %d (%v)", count, err)
+	}
+	records, err := LookupCVEs("openbsd", "openssh")
+	if err != nil || len(records) != 1 {
+		t.Fatalf("Unexpected lookup result %+v (%v)", records, err)
+	}
+	if r := records[0]; r.CVSS != 9.9 || !r.Published.Equal(record.Published) || len(r.References) != 1 || len(r.Affected) != 2 || r.Affected[0].EndExcluding != "9.3p2" {
+		t.Errorf("Unexpected record %+v", r)
+	}
+	if records, _ := LookupCVEs("other", "openssh"); len(records) != 1 || len(records[0].Affected) != 1 || records[0].Affected[0].Version != "9.3" || records[0].Affected[0].Ecosystem != "Debian:12" {
+		t.Errorf("Expected only the vendor-less entry for another vendor, got %+v", records)
+	}
+	if records, _ := LookupCVEs("", "http_server"); len(records) != 1 || records[0].ID != "CVE-2021-41773" {
+		t.Errorf("Unexpected lookup without a vendor %+v", records)
+	}
+}
+
+// TestRiskScoring verifies that hosts are scored when saved and rescored when KEV and EPSS data are
+// imported, and that the scores reach the hosts list, the dashboard and the reports.
+func TestRiskScoring(t *testing.T) {
+	setupTestDB(t)
+
+	campaignID, _ := GetOrCreateCampaign("Risk Test")
+	networkMap := model.NewNetworkMap()
+	exposed := model.NewHost("02:00:00:00:00:0A")
+	exposed.IPv4Addresses["10.0.0.10"] = true
+	exposed.Ports[22] = model.Port{ID: 22, Protocol: "tcp", State: "open", Service: "ssh"}
+	exposed.Ports[8080] = model.Port{ID: 8080, Protocol: "tcp", State: "open", Service: "http"}
+	exposed.SSHResults = append(exposed.SSHResults, model.SSHResult{PortID: 22, User: "root", Successful: true})
+	exposed.Findings[model.CriticalFinding] = []model.Vulnerability{{CVE: "CVE-2021-44228", State: "VULNERABLE", Category: model.CriticalFinding, CVSS: 10, PortID: 8080}}
+	potential := model.NewHost("02:00:00:00:00:0B")
+	potential.IPv4Addresses["10.0.0.11"] = true
+	potential.Ports[80] = model.Port{ID: 80, Protocol: "tcp", State: "open", Service: "http"}
+	potential.Findings[model.PotentialFinding] = []model.Vulnerability{{CVE: "HTTP-TRACE", State: "LIKELY VULNERABLE", Category: model.PotentialFinding, PortID: 80}}
+	rdp := model.NewHost("02:00:00:00:00:0C")
+	rdp.IPv4Addresses["10.0.0.12"] = true
+	rdp.Ports[3389] = model.Port{ID: 3389, Protocol: "tcp", State: "open", Service: "ms-wbt-server"}
+	rdp.Findings[model.InformationalFinding] = []model.Vulnerability{{CVE: "RDP-NLA", State: "DETECTED", Category: model.InformationalFinding, CVSS: 5}}
+	for _, h := range []*model.Host{exposed, potential, rdp} {
+		networkMap.Hosts[h.MACAddress] = h
+	}
+	if err := SaveScanResults(campaignID, networkMap, model.NewPcapSummary()); err != nil {
+		t.Fatalf("SaveScanResults failed: %v", err)
+	}
+
+	hosts, _, err := GetHostsByCampaignPaginated(campaignID, 10, 0, "", "", "risk")
+	if err != nil || len(hosts) != 3 {
+		t.Fatalf("Unexpected hosts %+v (%v)", hosts, err)
+	}
+	if hosts[0].MACAddress != exposed.MACAddress || hosts[0].RiskScore != 73 || hosts[0].RiskLevel != model.RiskHigh {
+		t.Errorf("Unexpected score before the KEV import %+v", hosts[0])
+	}
+
+	if err := SaveKEVEntries([]model.KEVEntry{{CVE: "CVE-2021-44228", Name: "Apache Log4j2 Remote Code Execution", RansomwareUse: true}}); err != nil {
+		t.Fatalf("SaveKEVEntries failed: %v", err)
+	}
+	if err := SaveEPSSScores([]model.EPSSScore{{CVE: "CVE-2021-44228", Score: 0.975, Percentile: 0.99999}}); err != nil {
+		t.Fatalf("SaveEPSSScores failed: %v", err)
+	}
+
+	hosts, _, err = GetHostsByCampaignPaginated(campaignID, 10, 0, "", "", "risk")
+	if err != nil || len(hosts) != 3 {
+		t.Fatalf("Unexpected hosts %+v (%v)", hosts, err)
+	}
+	want := []struct {
+		mac   string
+		score float64
+		level string
+	}{
+		{exposed.MACAddress, 97.8, model.RiskCritical},
+		{potential.MACAddress, 30, model.RiskMedium},
+		{rdp.MACAddress, 3, model.RiskLow},
+	}
+	for i, w := range want {
+		if hosts[i].MACAddress != w.mac || hosts[i].RiskScore != w.score || hosts[i].RiskLevel != w.level {
+			t.Errorf("Host %d = %+v, want %+v", i, hosts[i], w)
+		}
+	}
+
+	host, err := GetHostByID(hosts[0].ID, campaignID)
+	if err != nil {
+		t.Fatalf("GetHostByID failed: %v", err)
+	}
+	if v := host.Findings[model.CriticalFinding]; len(v) != 1 || !v[0].KnownExploited || v[0].EPSS != 0.975 {
+		t.Errorf("Expected the finding to be annotated, got %+v", v)
+	}
+	if risk := host.Risk(); risk.Score != 97.8 || risk.KnownExploited != 1 || len(risk.Exposures) != 2 {
+		t.Errorf("Unexpected risk %+v", risk)
+	}
+
+	summary, err := GetDashboardSummary(campaignID)
+	if err != nil {
+		t.Fatalf("GetDashboardSummary failed: %v", err)
+	}
+	if r := summary.Risk; r.MaxScore != 97.8 || r.AverageScore != 43.6 || r.KnownExploitedCount != 1 || r.Levels[model.RiskCritical] != 1 ||
+		r.Levels[model.RiskLow] != 1 || len(r.TopHosts) != 3 || r.TopHosts[0].ID != hosts[0].ID {
+		t.Errorf("Unexpected campaign risk %+v", r)
+	}
+
+	rows, err := GetAllVulnsForReport(campaignID)
+	if err != nil || len(rows) != 3 {
+		t.Fatalf("Unexpected report rows %v (%v)", rows, err)
+	}
+	for _, row := range rows {
+		if row[1] == "CVE-2021-44228" && (row[9] != "Yes" || row[10] != "0.9750") {
+			t.Errorf("Unexpected report row %v", row)
+		}
+	}
+}
+
+// TestFindingTriage verifies that triage statuses, assignees and notes are kept when results are
+// saved again, that suppression rules apply to existing and later findings, and that dismissed
+// findings leave the dashboard counts and risk scores.
+func TestFindingTriage(t *testing.T) {
+	setupTestDB(t)
+
+	campaignID, _ := GetOrCreateCampaign("Triage Test")
+	newHost := func(mac, ip string, ports ...int) *model.Host {
+		host := model.NewHost(mac)
+		host.IPv4Addresses[ip] = true
+		for _, port := range ports {
+			host.Ports[port] = model.Port{ID: port, Protocol: "tcp", State: "open", Service: "http"}
+			host.Findings[model.CriticalFinding] = append(host.Findings[model.CriticalFinding],
+				model.Vulnerability{CVE: "CVE-2021-41773", State: "VULNERABLE", Category: model.CriticalFinding, CVSS: 7.5, PortID: port})
+		}
+		return host
+	}
+	save := func(hosts ...*model.Host) {
+		t.Helper()
+		networkMap := model.NewNetworkMap()
+		for _, h := range hosts {
+			networkMap.Hosts[h.MACAddress] = h
+		}
+		if err := SaveScanResults(campaignID, networkMap, model.NewPcapSummary()); err != nil {
+			t.Fatalf("SaveScanResults failed: %v", err)
+		}
+	}
+	findings := func(mac string) map[int]model.Vulnerability {
+		t.Helper()
+		var hostID int64
+		DB.QueryRow("SELECT id FROM hosts WHERE campaign_id = ? AND mac_address = ?", campaignID, mac).Scan(&hostID)
+		host, err := GetHostByID(hostID, campaignID)
+		if err != nil {
+			t.Fatalf("GetHostByID failed: %v", err)
+		}
+		byPort := make(map[int]model.Vulnerability)
+		for _, v := range host.Findings[model.CriticalFinding] {
+			byPort[v.PortID] = v
+		}
+		return byPort
+	}
+
+	web := newHost("02:00:00:00:00:0A", "10.0.0.10", 80, 443)
+	save(web)
+	vulns := findings(web.MACAddress)
+	if vulns[80].ID == 0 || vulns[80].TriageStatus != model.TriageNew {
+		t.Fatalf("Expected new findings with IDs, got %+v", vulns)
+	}
+
+	hostRuleID, suppressed, err := CreateSuppressionRule(campaignID, model.SuppressionRule{CVE: "cve-2021-41773", Host: "10.0.0.10", Port: 80, Reason: "Backported fix"})
+	if err != nil || suppressed != 1 {
+		t.Fatalf("CreateSuppressionRule suppressed %d findings (%v)", suppressed, err)
+	}
+	if err := UpdateFindingTriage(campaignID, vulns[443].ID, model.TriageConfirmed, " alice "); err != nil {
+		t.Fatalf("UpdateFindingTriage failed: %v", err)
+	}
+	if err := UpdateFindingTriage(campaignID, vulns[443].ID, "Wontfix", ""); err == nil {
+		t.Error("Expected an error for an unknown status")
+	}
+	if err := UpdateFindingTriage(campaignID+1, vulns[443].ID, model.TriageFixed, ""); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected a not found error for another campaign, got %v", err)
+	}
+	if _, err := AddFindingNote(campaignID, vulns[443].ID, model.FindingNote{Author: "alice", Text: "Reproduced with curl", Evidence: "poc.txt"}); err != nil {
+		t.Fatalf("AddFindingNote failed: %v", err)
+	}
+
+	// Saving the scan again keeps the triage, and a later scan is checked against the rules.
+	save(newHost(web.MACAddress, "10.0.0.10", 80, 443, 8080), newHost("02:00:00:00:00:0B", "10.0.0.11", 80))
+	vulns = findings(web.MACAddress)
+	if v := vulns[80]; v.TriageStatus != model.TriageFalsePositive || v.SuppressionID == 0 {
+		t.Errorf("Expected port 80 to stay suppressed, got %+v", v)
+	}
+	if v := vulns[443]; v.TriageStatus != model.TriageConfirmed || v.Assignee != "alice" || len(v.Notes) != 1 || v.Notes[0].Evidence != "poc.txt" || v.Notes[0].CreatedAt.IsZero() {
+		t.Errorf("Expected port 443 to keep its triage, got %+v", v)
+	}
+	if v := vulns[8080]; v.TriageStatus != model.TriageNew {
+		t.Errorf("Expected port 8080 not to match the rule, got %+v", v)
+	}
+
+	ruleID, suppressed, err := CreateSuppressionRule(campaignID, model.SuppressionRule{CVE: "CVE-2021-41773"})
+	if err != nil || suppressed != 2 {
+		t.Fatalf("Expected the campaign-wide rule to suppress 2 findings, got %d (%v)", suppressed, err)
+	}
+	save(newHost("02:00:00:00:00:0C", "10.0.0.12", 80))
+	if v := findings("02:00:00:00:00:0C")[80]; v.TriageStatus != model.TriageFalsePositive || v.SuppressionID != ruleID {
+		t.Errorf("Expected a new host's finding to be suppressed, got %+v", v)
+	}
+	if rules, err := GetSuppressionRules(campaignID); err != nil || len(rules) != 2 || rules[0].Reason != "Backported fix" || rules[1].Host != "" {
+		t.Errorf("Unexpected rules %+v (%v)", rules, err)
+	}
+
+	summary, err := GetDashboardSummary(campaignID)
+	if err != nil || summary.CriticalVulnCount != 1 {
+		t.Errorf("Expected only the confirmed finding to be counted, got %+v (%v)", summary, err)
+	}
+	hosts, _, _ := GetHostsByCampaignPaginated(campaignID, 10, 0, "", "", "risk")
+	if len(hosts) != 3 || hosts[0].MACAddress != web.MACAddress || hosts[1].RiskScore != 0 {
+		t.Errorf("Expected suppressed findings not to be scored, got %+v", hosts)
+	}
+	rows, err := GetAllVulnsForReport(campaignID)
+	if err != nil || len(rows) != 5 {
+		t.Fatalf("Unexpected report rows %v (%v)", rows, err)
+	}
+	for _, row := range rows {
+		if row[11] == model.TriageConfirmed && (row[12] != "alice" || row[13] != "alice: Reproduced with curl (evidence: poc.txt)") {
+			t.Errorf("Unexpected report row %v", row)
+		}
+	}
+
+	// Setting a finding back to New by hand keeps the rules from suppressing it again.
+	if err := UpdateFindingTriage(campaignID, vulns[8080].ID, model.TriageNew, ""); err != nil {
+		t.Fatalf("UpdateFindingTriage failed: %v", err)
+	}
+	save(newHost(web.MACAddress, "10.0.0.10", 80, 443, 8080))
+	if v := findings(web.MACAddress)[8080]; v.TriageStatus != model.TriageNew || v.SuppressionID != 0 {
+		t.Errorf("Expected the manually reset finding to stay new, got %+v", v)
+	}
+
+	// A finding another rule also matches stays suppressed when its rule is deleted.
+	if err := DeleteSuppressionRule(campaignID, hostRuleID); err != nil {
+		t.Fatalf("DeleteSuppressionRule failed: %v", err)
+	}
+	if v := findings(web.MACAddress)[80]; v.TriageStatus != model.TriageFalsePositive || v.SuppressionID != ruleID {
+		t.Errorf("Expected the campaign-wide rule to suppress port 80, got %+v", v)
+	}
+	if err := DeleteSuppressionRule(campaignID, ruleID); err != nil {
+		t.Fatalf("DeleteSuppressionRule failed: %v", err)
+	}
+	if v := findings("02:00:00:00:00:0C")[80]; v.TriageStatus != model.TriageNew || v.SuppressionID != 0 {
+		t.Errorf("Expected the finding to be new again, got %+v", v)
+	}
+	if err := DeleteSuppressionRule(campaignID, ruleID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected a not found error, got %v", err)
+	}
+}